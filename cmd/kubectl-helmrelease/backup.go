@@ -0,0 +1,197 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+	"github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/release"
+)
+
+// runExport writes the backup of the HelmReleases of the namespace, and of
+// the records of their releases, to the file named by args, - for stdout.
+func runExport(ctx context.Context, c *cli, args []string) error {
+	if len(args) == 0 {
+		return errors.New("the backup file is required, - for stdout")
+	}
+
+	var opts []client.ListOption
+	if !c.allNamespaces {
+		opts = append(opts, client.InNamespace(c.namespace))
+	}
+
+	hrs := &appv1.HelmReleaseList{}
+	if err := c.mgr.GetAPIReader().List(ctx, hrs, opts...); err != nil {
+		return err
+	}
+
+	backup := &release.Backup{Version: release.BackupVersion, Time: metav1.Now()}
+	records := 0
+
+	for i := range hrs.Items {
+		hr := &hrs.Items[i]
+		hr.SetGroupVersionKind(appv1.SchemeGroupVersion.WithKind("HelmRelease"))
+
+		entry := release.BackupHelmRelease{HelmRelease: *hr}
+
+		// the records of the other clusters are not in this one
+		if hr.Repo.ClusterSelector != nil || hr.Repo.PlacementRef != nil || hr.Repo.KubeConfig != nil {
+			fmt.Fprintf(os.Stderr, "HelmRelease %s/%s deploys to other clusters, its release records are not exported\n",
+				hr.Namespace, hr.Name)
+		} else {
+			entry.StorageNamespace = hr.Namespace
+			if hr.Repo.StorageNamespace != "" {
+				entry.StorageNamespace = hr.Repo.StorageNamespace
+			}
+
+			name := release.ReleaseNameFor(hr.Name)
+			if hr.Repo.ReleaseName != "" {
+				name = hr.Repo.ReleaseName
+			}
+
+			if hr.Status.DeployedRelease != nil && hr.Status.DeployedRelease.Name != "" {
+				name = hr.Status.DeployedRelease.Name
+			}
+
+			history, err := release.ReleaseRecords(c.cfg, c.storage, entry.StorageNamespace, name)
+			if err != nil {
+				return fmt.Errorf("failed to export the release records of HelmRelease %s/%s: %w", hr.Namespace, hr.Name, err)
+			}
+
+			entry.Records = history
+			records += len(history)
+		}
+
+		backup.HelmReleases = append(backup.HelmReleases, entry)
+	}
+
+	w := io.Writer(os.Stdout)
+
+	if args[0] != "-" {
+		// the release records hold the values of the releases, secrets included
+		f, err := os.OpenFile(args[0], os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		w = f
+	}
+
+	if err := release.WriteBackup(w, backup); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "Exported %d HelmReleases and %d release records\n", len(backup.HelmReleases), records)
+
+	return nil
+}
+
+// runImport restores the backup of the file named by args, - for stdin: the
+// release records are created first, so that the first reconcile of the
+// HelmReleases upgrades their releases instead of installing them, then the
+// HelmReleases and their status. The existing records and HelmReleases are
+// left as is.
+func runImport(ctx context.Context, c *cli, args []string) error {
+	if len(args) == 0 {
+		return errors.New("the backup file is required, - for stdin")
+	}
+
+	r := io.Reader(os.Stdin)
+
+	if args[0] != "-" {
+		f, err := os.Open(args[0])
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		r = f
+	}
+
+	backup, err := release.ReadBackup(r)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range backup.HelmReleases {
+		hr := release.RestoredHelmRelease(&entry.HelmRelease)
+
+		for _, namespace := range []string{hr.Namespace, entry.StorageNamespace} {
+			if err := c.ensureNamespace(ctx, namespace); err != nil {
+				return err
+			}
+		}
+
+		created, err := release.ImportReleaseRecords(c.cfg, c.storage, entry.StorageNamespace, hr.Name, hr.Namespace,
+			entry.Records)
+		if err != nil {
+			return fmt.Errorf("failed to import the release records of HelmRelease %s/%s: %w", hr.Namespace, hr.Name, err)
+		}
+
+		status := hr.Status
+
+		if err := c.mgr.GetClient().Create(ctx, hr); err != nil {
+			if !apierrors.IsAlreadyExists(err) {
+				return err
+			}
+
+			fmt.Printf("HelmRelease %s/%s already exists, %d release records imported\n", hr.Namespace, hr.Name, created)
+
+			continue
+		}
+
+		// the status is not created with the HelmRelease, the operator may
+		// have reconciled it already
+		hr.Status = status
+		if err := c.mgr.GetClient().Status().Update(ctx, hr); err != nil {
+			if !apierrors.IsConflict(err) {
+				return err
+			}
+
+			fmt.Printf("HelmRelease %s/%s was reconciled before its status was restored\n", hr.Namespace, hr.Name)
+		}
+
+		fmt.Printf("Imported HelmRelease %s/%s, %d release records imported\n", hr.Namespace, hr.Name, created)
+	}
+
+	return nil
+}
+
+// ensureNamespace creates the namespace if it does not exist.
+func (c *cli) ensureNamespace(ctx context.Context, namespace string) error {
+	if namespace == "" {
+		return nil
+	}
+
+	err := c.mgr.GetClient().Create(ctx, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}})
+	if apierrors.IsAlreadyExists(err) {
+		return nil
+	}
+
+	return err
+}
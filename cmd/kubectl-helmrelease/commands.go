@@ -0,0 +1,232 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ghodss/yaml"
+	rpb "helm.sh/helm/v3/pkg/release"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+	"github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/release"
+)
+
+// status prints the deployed release and the conditions of the HelmRelease.
+func status(ctx context.Context, c *cli, hr *appv1.HelmRelease, args []string) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+
+	fmt.Fprintf(w, "NAME:\t%s\n", hr.Name)
+	fmt.Fprintf(w, "NAMESPACE:\t%s\n", hr.Namespace)
+
+	if deployed := hr.Status.DeployedRelease; deployed != nil {
+		fmt.Fprintf(w, "RELEASE:\t%s\n", deployed.Name)
+		fmt.Fprintf(w, "REVISION:\t%d\n", deployed.Revision)
+		fmt.Fprintf(w, "CHART VERSION:\t%s\n", deployed.ChartVersion)
+		fmt.Fprintf(w, "APP VERSION:\t%s\n", deployed.AppVersion)
+
+		if deployed.LastDeployed != nil {
+			fmt.Fprintf(w, "LAST DEPLOYED:\t%s\n", deployed.LastDeployed.Format(time.ANSIC))
+		}
+	}
+
+	if hr.Status.Progress != "" {
+		fmt.Fprintf(w, "PROGRESS:\t%s\n", hr.Status.Progress)
+	}
+
+	if hr.Status.UpgradeSummary != "" {
+		fmt.Fprintf(w, "LAST UPGRADE:\t%s\n", hr.Status.UpgradeSummary)
+	}
+
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if len(hr.Status.Conditions) == 0 {
+		return nil
+	}
+
+	fmt.Println()
+
+	w = tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "TYPE\tSTATUS\tREASON\tMESSAGE")
+
+	for _, condition := range hr.Status.Conditions {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", condition.Type, condition.Status, condition.Reason, condition.Message)
+	}
+
+	return w.Flush()
+}
+
+// render prints the resources rendered from the HelmRelease spec, in install
+// order.
+func render(ctx context.Context, c *cli, hr *appv1.HelmRelease, args []string) error {
+	manager, err := c.releaseManager(hr, false)
+	if err != nil {
+		return err
+	}
+
+	objects, err := manager.Render(ctx, release.RenderOptions{})
+	if err != nil {
+		return err
+	}
+
+	for _, obj := range objects {
+		b, err := yaml.Marshal(obj.Object)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("---\n%s", b)
+	}
+
+	return nil
+}
+
+// diff prints the resources the next upgrade of the release would change.
+func diff(ctx context.Context, c *cli, hr *appv1.HelmRelease, args []string) error {
+	manager, err := c.releaseManager(hr, true)
+	if err != nil {
+		return err
+	}
+
+	d, err := manager.Diff(ctx)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(d.Summary())
+
+	for _, r := range d.Added {
+		fmt.Printf("+ %s\n", r.Resource)
+	}
+
+	for _, r := range d.Modified {
+		fmt.Printf("~ %s\n", r.Resource)
+
+		for _, change := range r.Changes {
+			fmt.Printf("    %s: %s -> %s\n", change.Path, jsonValue(change.Old), jsonValue(change.New))
+		}
+	}
+
+	for _, r := range d.Removed {
+		fmt.Printf("- %s\n", r.Resource)
+	}
+
+	return nil
+}
+
+// history prints the revisions of the release recorded in the helm storage.
+func history(ctx context.Context, c *cli, hr *appv1.HelmRelease, args []string) error {
+	manager, err := c.releaseManager(hr, true)
+	if err != nil {
+		return err
+	}
+
+	releases, err := manager.History(ctx)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "REVISION\tUPDATED\tSTATUS\tCHART\tAPP VERSION\tDESCRIPTION")
+
+	for _, rel := range releases {
+		var updated, state, description string
+		if rel.Info != nil {
+			updated = rel.Info.LastDeployed.Format(time.ANSIC)
+			state = rel.Info.Status.String()
+			description = rel.Info.Description
+		}
+
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\n", rel.Version, updated, state, chartName(rel), appVersion(rel), description)
+	}
+
+	return w.Flush()
+}
+
+// rollback rolls the release back to the revision of args, or to the
+// previous one.
+func rollback(ctx context.Context, c *cli, hr *appv1.HelmRelease, args []string) error {
+	revision := 0
+
+	if len(args) > 0 {
+		var err error
+		if revision, err = strconv.Atoi(args[0]); err != nil || revision <= 0 {
+			return fmt.Errorf("invalid revision %q", args[0])
+		}
+	}
+
+	manager, err := c.releaseManager(hr, true)
+	if err != nil {
+		return err
+	}
+
+	rel, err := manager.RollbackRelease(ctx, revision)
+	if err != nil {
+		return err
+	}
+
+	target := "the previous revision"
+	if revision != 0 {
+		target = fmt.Sprintf("revision %d", revision)
+	}
+
+	fmt.Printf("Rolled back release %s to %s, deployed as revision %d\n", rel.Name, target, rel.Version)
+
+	if !hr.Repo.Suspend {
+		fmt.Fprintln(os.Stderr, "Warning: the HelmRelease is not suspended, its next reconcile upgrades the release again")
+	}
+
+	return nil
+}
+
+func chartName(rel *rpb.Release) string {
+	if rel.Chart == nil || rel.Chart.Metadata == nil {
+		return ""
+	}
+
+	return rel.Chart.Metadata.Name + "-" + rel.Chart.Metadata.Version
+}
+
+func appVersion(rel *rpb.Release) string {
+	if rel.Chart == nil || rel.Chart.Metadata == nil {
+		return ""
+	}
+
+	return rel.Chart.Metadata.AppVersion
+}
+
+// jsonValue formats a field value of a diff, <none> if it is not set.
+func jsonValue(v interface{}) string {
+	if v == nil {
+		return "<none>"
+	}
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+
+	return string(b)
+}
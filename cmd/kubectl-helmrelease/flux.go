@@ -0,0 +1,82 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/flux"
+)
+
+// convertFlux prints the HelmReleases of the Flux HelmReleases of the
+// namespace, or of the one named by args, and the settings that could not be
+// converted on stderr.
+func convertFlux(ctx context.Context, c *cli, args []string) error {
+	reader := c.mgr.GetAPIReader()
+
+	var fhrs []unstructured.Unstructured
+
+	if len(args) > 0 {
+		fhr := unstructured.Unstructured{}
+		fhr.SetGroupVersionKind(flux.HelmReleaseGVK)
+
+		if err := reader.Get(ctx, types.NamespacedName{Namespace: c.namespace, Name: args[0]}, &fhr); err != nil {
+			return err
+		}
+
+		fhrs = append(fhrs, fhr)
+	} else {
+		list := &unstructured.UnstructuredList{}
+		list.SetGroupVersionKind(flux.HelmReleaseGVK.GroupVersion().WithKind(flux.HelmReleaseGVK.Kind + "List"))
+
+		var opts []client.ListOption
+		if !c.allNamespaces {
+			opts = append(opts, client.InNamespace(c.namespace))
+		}
+
+		if err := reader.List(ctx, list, opts...); err != nil {
+			return err
+		}
+
+		fhrs = list.Items
+	}
+
+	for i := range fhrs {
+		fhr := &fhrs[i]
+
+		hr, warnings, err := flux.Convert(ctx, reader, fhr)
+		if err != nil {
+			return err
+		}
+
+		for _, warning := range warnings {
+			fmt.Fprintf(os.Stderr, "Warning: Flux HelmRelease %s/%s: %s\n", fhr.GetNamespace(), fhr.GetName(), warning)
+		}
+
+		if err := printHelmRelease(hr); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,247 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// kubectl-helmrelease is a kubectl plugin reading and rolling back the helm
+// releases of the HelmReleases with the code of the operator, migrating the
+// helm CLI releases and the Flux HelmReleases to HelmReleases, and backing up
+// the HelmReleases and their releases, e.g.
+//
+//	kubectl helmrelease history -n default nginx
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/spf13/pflag"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/client-go/rest"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+
+	"github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis"
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+	"github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/controller/helmrelease"
+	"github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/release"
+)
+
+const usage = `kubectl helmrelease reads and rolls back the helm releases of the HelmReleases, migrates
+the helm CLI releases and the Flux HelmReleases to HelmReleases, and backs up the HelmReleases and
+their releases.
+
+Usage:
+  kubectl helmrelease status NAME               show the status of the HelmRelease
+  kubectl helmrelease render NAME               render the release from the HelmRelease spec
+  kubectl helmrelease diff NAME                 diff the deployed release and the HelmRelease spec
+  kubectl helmrelease history NAME              list the revisions of the release
+  kubectl helmrelease rollback NAME [REVISION]  roll the release back, to the previous revision by default
+  kubectl helmrelease migrate [RELEASE]         print the HelmReleases of the helm CLI releases, or create them with --adopt
+  kubectl helmrelease convert-flux [NAME]       print the HelmReleases of the Flux HelmReleases
+  kubectl helmrelease export FILE               back up the HelmReleases and their release records, - for stdout
+  kubectl helmrelease import FILE               restore a backup in a rebuilt cluster, - for stdin
+
+Flags:
+`
+
+// command runs a subcommand on the HelmRelease, args are the arguments
+// following its name.
+type command func(ctx context.Context, c *cli, hr *appv1.HelmRelease, args []string) error
+
+var commands = map[string]command{
+	"status":   status,
+	"render":   render,
+	"diff":     diff,
+	"history":  history,
+	"rollback": rollback,
+}
+
+// namespaceCommand runs a subcommand on the namespace of the cli, or on all
+// the namespaces, args are the arguments following its name.
+type namespaceCommand func(ctx context.Context, c *cli, args []string) error
+
+var namespaceCommands = map[string]namespaceCommand{
+	"migrate":      runMigrate,
+	"convert-flux": convertFlux,
+	"export":       runExport,
+	"import":       runImport,
+}
+
+// cli holds the controller-runtime manager the release managers are created
+// with. It is never started.
+type cli struct {
+	mgr       manager.Manager
+	cfg       *rest.Config
+	storage   release.StorageOptions
+	namespace string
+	migrate   migrateOptions
+	// allNamespaces runs the namespace commands on all the namespaces
+	allNamespaces bool
+}
+
+// migrateOptions are the flags of the migrate command.
+type migrateOptions struct {
+	repoURL   string
+	namespace string
+	adopt     bool
+}
+
+func main() {
+	configFlags := genericclioptions.NewConfigFlags(true)
+
+	storage := release.StorageOptions{Driver: release.SecretsStorageDriver}
+	migrate := migrateOptions{}
+	allNamespaces := false
+
+	flags := pflag.NewFlagSet("kubectl-helmrelease", pflag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+		flags.PrintDefaults()
+	}
+
+	configFlags.AddFlags(flags)
+	flags.StringVar(&storage.Driver, "helm-storage-driver", storage.Driver,
+		"The helm storage driver of the release records: secret, configmap or sql.")
+	flags.StringVar(&storage.SQLConnectionString, "helm-storage-sql-connection", "",
+		"The postgres connection string used by the sql helm storage driver.")
+	flags.StringVar(&storage.VaultAddress, "helm-storage-vault-address", "",
+		"The address of the Vault server encrypting the payloads of the release records.")
+	flags.StringVar(&storage.VaultTransitKey, "helm-storage-vault-transit-key", "",
+		"The mount/name of the Vault transit key envelope encrypting the payloads of the release records.")
+	flags.StringVar(&storage.VaultTokenFile, "helm-storage-vault-token-file", "",
+		"The file of the Vault token. The VAULT_TOKEN environment variable is used by default.")
+	flags.StringVar(&helmrelease.Options.ChartBundleNamespace, "chart-bundle-namespace", "",
+		"The namespace of the ConfigMaps bundling the charts, as set on the operator.")
+	flags.StringVar(&migrate.repoURL, "repo-url", "",
+		"migrate: the URL of the helm repo serving the charts of the releases.")
+	flags.StringVar(&migrate.namespace, "helmrelease-namespace", "",
+		"migrate: the namespace of the HelmReleases, the namespace of their release by default.")
+	flags.BoolVarP(&allNamespaces, "all-namespaces", "A", false,
+		"migrate, convert-flux, export: run on all the namespaces.")
+	flags.BoolVar(&migrate.adopt, "adopt", false,
+		"migrate: create the HelmReleases and label the records of their releases instead of printing them.")
+
+	if err := flags.Parse(os.Args[1:]); err != nil {
+		if err == pflag.ErrHelp {
+			return
+		}
+
+		os.Exit(2)
+	}
+
+	args := flags.Args()
+	if len(args) == 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	run, ok := commands[args[0]]
+	runNamespace, namespaced := namespaceCommands[args[0]]
+
+	if (!ok && !namespaced) || (ok && len(args) < 2) {
+		if !ok && !namespaced {
+			fmt.Fprintf(os.Stderr, "unknown command %q\n\n", args[0])
+		}
+
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	if err := storage.Validate(); err != nil {
+		exit(err)
+	}
+
+	helmrelease.Options.Storage = storage
+
+	namespace, _, err := configFlags.ToRawKubeConfigLoader().Namespace()
+	if err != nil {
+		exit(err)
+	}
+
+	cfg, err := configFlags.ToRESTConfig()
+	if err != nil {
+		exit(err)
+	}
+
+	c, err := newCLI(cfg)
+	if err != nil {
+		exit(err)
+	}
+
+	c.storage = storage
+	c.namespace = namespace
+	c.migrate = migrate
+	c.allNamespaces = allNamespaces
+
+	ctx := context.Background()
+
+	// the releases to migrate have no HelmRelease yet
+	if namespaced {
+		if err := runNamespace(ctx, c, args[1:]); err != nil {
+			exit(err)
+		}
+
+		return
+	}
+
+	hr := &appv1.HelmRelease{}
+
+	if err := c.mgr.GetAPIReader().Get(ctx, types.NamespacedName{Namespace: namespace, Name: args[1]}, hr); err != nil {
+		exit(err)
+	}
+
+	if err := run(ctx, c, hr, args[2:]); err != nil {
+		exit(err)
+	}
+}
+
+func newCLI(cfg *rest.Config) (*cli, error) {
+	// the manager is not started, its cache would never sync
+	mgr, err := ctrl.NewManager(cfg, ctrl.Options{
+		MetricsBindAddress: "0",
+		NewClient: func(_ cache.Cache, config *rest.Config, options client.Options) (client.Client, error) {
+			return client.New(config, options)
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := apis.AddToScheme(mgr.GetScheme()); err != nil {
+		return nil, err
+	}
+
+	return &cli{mgr: mgr, cfg: cfg}, nil
+}
+
+// releaseManager returns the manager of the release of hr, with its chart
+// downloaded. The releases deployed on the managed clusters are only
+// rendered, their helm storage is not in this cluster.
+func (c *cli) releaseManager(hr *appv1.HelmRelease, stored bool) (release.Manager, error) {
+	if stored && (hr.Repo.ClusterSelector != nil || hr.Repo.PlacementRef != nil) {
+		return nil, fmt.Errorf("the release of HelmRelease %s/%s is deployed on the managed clusters", hr.Namespace, hr.Name)
+	}
+
+	return helmrelease.NewReleaseManager(c.mgr, hr)
+}
+
+func exit(err error) {
+	fmt.Fprintln(os.Stderr, "Error:", err)
+	os.Exit(1)
+}
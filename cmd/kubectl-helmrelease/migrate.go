@@ -0,0 +1,117 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/ghodss/yaml"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+	"github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/release"
+)
+
+// runMigrate prints the HelmReleases deploying the helm CLI releases of the
+// namespace, or of the release named by args. With --adopt, it creates them
+// and labels the records of their releases instead, so that their first
+// reconcile upgrades nothing.
+func runMigrate(ctx context.Context, c *cli, args []string) error {
+	if c.migrate.repoURL == "" {
+		return errors.New("the --repo-url flag is required")
+	}
+
+	namespace := c.namespace
+	if c.allNamespaces {
+		namespace = ""
+	}
+
+	releases, err := release.UnmanagedReleases(c.cfg, c.storage, namespace)
+	if err != nil {
+		return err
+	}
+
+	migrated := 0
+
+	for _, rel := range releases {
+		if len(args) > 0 && rel.Name != args[0] {
+			continue
+		}
+
+		hr, err := release.MigratedHelmRelease(rel, c.migrate.repoURL, c.migrate.namespace)
+		if err != nil {
+			return err
+		}
+
+		migrated++
+
+		if !c.migrate.adopt {
+			if err := printHelmRelease(hr); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		if err := c.mgr.GetClient().Create(ctx, hr); err != nil {
+			if apierrors.IsAlreadyExists(err) {
+				fmt.Printf("HelmRelease %s/%s already exists, release %s/%s skipped\n", hr.Namespace, hr.Name, rel.Namespace, rel.Name)
+				continue
+			}
+
+			return err
+		}
+
+		labeled, err := release.AdoptReleaseRecords(c.cfg, c.storage, rel.Namespace, hr.Name, hr.Namespace)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Created HelmRelease %s/%s adopting release %s/%s, %d records labeled\n",
+			hr.Namespace, hr.Name, rel.Namespace, rel.Name, labeled)
+	}
+
+	if migrated == 0 && len(args) > 0 {
+		return fmt.Errorf("no deployed helm CLI release %q", args[0])
+	}
+
+	return nil
+}
+
+// printHelmRelease prints hr as a YAML document, without its status.
+func printHelmRelease(hr *appv1.HelmRelease) error {
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(hr)
+	if err != nil {
+		return err
+	}
+
+	unstructured.RemoveNestedField(content, "status")
+	unstructured.RemoveNestedField(content, "metadata", "creationTimestamp")
+
+	b, err := yaml.Marshal(content)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("---\n%s", b)
+
+	return nil
+}
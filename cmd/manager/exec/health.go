@@ -0,0 +1,146 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"time"
+
+	"k8s.io/client-go/rest"
+	"k8s.io/klog"
+	"sigs.k8s.io/controller-runtime/pkg/healthz"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+	"github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/controller/helmrelease"
+	"github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/release"
+)
+
+// healthServer serves the liveness and readiness probes of the operator, and
+// the detailed health of the releases for the external monitoring probes.
+type healthServer struct {
+	address string
+	checks  map[string]healthz.Checker
+}
+
+// healthDetail is the detailed health served at /readyz/detail
+type healthDetail struct {
+	Status string `json:"status"`
+	// Checks are the results of the readiness checks, ok or their error
+	Checks map[string]string `json:"checks"`
+	// Releases are the numbers of HelmReleases by state
+	Releases map[string]int `json:"releases"`
+}
+
+// newHealthServer returns the health server of the operator, reading the
+// release records of namespace to check the storage backend.
+func newHealthServer(address string, cfg *rest.Config, storage release.StorageOptions, namespace string) healthServer {
+	return healthServer{
+		address: address,
+		checks: map[string]healthz.Checker{
+			"storage": func(_ *http.Request) error {
+				return release.PingStorage(cfg, storage, namespace)
+			},
+			"chart-cache": chartCacheCheck,
+		},
+	}
+}
+
+// chartCacheCheck returns an error if the charts cannot be downloaded to the
+// charts directory.
+func chartCacheCheck(_ *http.Request) error {
+	dir := os.Getenv(appv1.ChartsDir)
+	if dir == "" {
+		dir = os.TempDir()
+	}
+
+	f, err := ioutil.TempFile(dir, ".healthz")
+	if err != nil {
+		return fmt.Errorf("the charts directory %s is not writable: %w", dir, err)
+	}
+
+	_ = f.Close()
+
+	return os.Remove(f.Name())
+}
+
+func (s healthServer) serveDetail(w http.ResponseWriter, r *http.Request) {
+	detail := healthDetail{
+		Status:   "ok",
+		Checks:   map[string]string{},
+		Releases: helmrelease.ReleaseCounts(),
+	}
+
+	for name, check := range s.checks {
+		detail.Checks[name] = "ok"
+
+		if err := check(r); err != nil {
+			detail.Checks[name] = err.Error()
+			detail.Status = "failed"
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if detail.Status != "ok" {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+
+	_ = json.NewEncoder(w).Encode(detail)
+}
+
+// Start implements manager.Runnable.
+func (s healthServer) Start(stop <-chan struct{}) error {
+	mux := http.NewServeMux()
+
+	liveness := &healthz.Handler{Checks: map[string]healthz.Checker{"ping": healthz.Ping}}
+	mux.Handle("/healthz", http.StripPrefix("/healthz", liveness))
+	mux.Handle("/healthz/", http.StripPrefix("/healthz", liveness))
+
+	readiness := &healthz.Handler{Checks: s.checks}
+	mux.Handle("/readyz", http.StripPrefix("/readyz", readiness))
+	mux.Handle("/readyz/", http.StripPrefix("/readyz", readiness))
+	mux.HandleFunc("/readyz/detail", s.serveDetail)
+
+	server := &http.Server{Addr: s.address, Handler: mux}
+
+	errs := make(chan error, 1)
+
+	go func() {
+		klog.Info("Serving the health probes on ", s.address)
+		errs <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-stop:
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	return server.Shutdown(ctx)
+}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable, the standby
+// operators are probed too.
+func (s healthServer) NeedLeaderElection() bool {
+	return false
+}
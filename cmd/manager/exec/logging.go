@@ -0,0 +1,221 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"encoding/json"
+	goflag "flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/go-logr/logr"
+	"github.com/spf13/pflag"
+	uberzap "go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"k8s.io/klog"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+)
+
+// formats of the log entries
+const (
+	logFormatText = "text"
+	logFormatJSON = "json"
+)
+
+// logLevelPath is the path of the log level endpoint, served with the metrics
+const logLevelPath = "/log-level"
+
+// logLevel is the level of the entries of the controllers, changed at runtime
+// with the log level endpoint
+var logLevel = uberzap.NewAtomicLevel()
+
+// klogHeader matches the header of the klog entries, e.g.
+// I1016 12:00:00.000000       1 manager.go:66] message
+var klogHeader = regexp.MustCompile(`^([IWEF])\d{4} [\d:.]+\s+\d+ ([^\]]+)\] (.*)$`)
+
+// setupLogging sets the logger of the controllers from the log-format and
+// log-level flags. In the json format, the klog entries of the other
+// packages are logged as json entries too.
+func setupLogging(format, level string) error {
+	zapLevel, err := parseLogLevel(level)
+	if err != nil {
+		return err
+	}
+
+	logLevel.SetLevel(zapLevel)
+	opts := []zap.Opts{zap.Level(&logLevel)}
+
+	switch format {
+	case logFormatText:
+		opts = append(opts, zap.Encoder(zapcore.NewConsoleEncoder(uberzap.NewDevelopmentEncoderConfig())))
+	case logFormatJSON:
+		opts = append(opts, zap.Encoder(zapcore.NewJSONEncoder(uberzap.NewProductionEncoderConfig())))
+	default:
+		return fmt.Errorf("unknown log format %s, text or json", format)
+	}
+
+	logger := zap.New(opts...)
+	ctrl.SetLogger(logger)
+
+	// the klog verbosity follows the level, unless only -v is set
+	if pflag.CommandLine.Changed("log-level") {
+		if err := setKlogVerbosity(zapLevel); err != nil {
+			return err
+		}
+	}
+
+	if format == logFormatJSON {
+		return routeKlog(logger.WithName("klog"))
+	}
+
+	return nil
+}
+
+// parseLogLevel parses error, info, debug or a verbosity, e.g. 3 logs the
+// entries up to V(3).
+func parseLogLevel(level string) (zapcore.Level, error) {
+	switch strings.ToLower(level) {
+	case "error":
+		return zapcore.ErrorLevel, nil
+	case "info":
+		return zapcore.InfoLevel, nil
+	case "debug":
+		return zapcore.DebugLevel, nil
+	}
+
+	verbosity, err := strconv.Atoi(level)
+	if err != nil || verbosity < 0 {
+		return 0, fmt.Errorf("invalid log level %s, error, info, debug or a verbosity", level)
+	}
+
+	return zapcore.Level(-verbosity), nil
+}
+
+// formatLogLevel is the inverse of parseLogLevel.
+func formatLogLevel(level zapcore.Level) string {
+	switch {
+	case level >= zapcore.ErrorLevel:
+		return "error"
+	case level >= zapcore.InfoLevel:
+		return "info"
+	case level == zapcore.DebugLevel:
+		return "debug"
+	}
+
+	return strconv.Itoa(int(-level))
+}
+
+func setKlogVerbosity(level zapcore.Level) error {
+	verbosity := 0
+	if level < 0 {
+		verbosity = int(-level)
+	}
+
+	return goflag.Set("v", strconv.Itoa(verbosity))
+}
+
+// logLevelHandler serves the log level: GET returns it, PUT sets it, e.g.
+// {"level":"debug"}. The klog verbosity is set too.
+type logLevelHandler struct{}
+
+type logLevelPayload struct {
+	Level string `json:"level"`
+}
+
+func (logLevelHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		var payload logLevelPayload
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			http.Error(w, fmt.Sprintf("invalid payload: %s", err), http.StatusBadRequest)
+			return
+		}
+
+		level, err := parseLogLevel(payload.Level)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if err := setKlogVerbosity(level); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		logLevel.SetLevel(level)
+
+		klog.Info("Log level set to ", formatLogLevel(level))
+	default:
+		w.Header().Set("Allow", "GET, PUT")
+		http.Error(w, "only GET and PUT are supported", http.StatusMethodNotAllowed)
+
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(logLevelPayload{Level: formatLogLevel(logLevel.Level())})
+}
+
+// routeKlog logs the klog entries with logger instead of writing them to
+// stderr.
+func routeKlog(logger logr.Logger) error {
+	for name, value := range map[string]string{"logtostderr": "false", "alsologtostderr": "false", "stderrthreshold": "FATAL"} {
+		if err := goflag.Set(name, value); err != nil {
+			return err
+		}
+	}
+
+	// each entry is written to the writers of its severity and of the lower
+	// ones, only the info writer logs them
+	klog.SetOutputBySeverity("INFO", klogWriter{logger: logger})
+
+	for _, severity := range []string{"WARNING", "ERROR", "FATAL"} {
+		klog.SetOutputBySeverity(severity, ioutil.Discard)
+	}
+
+	return nil
+}
+
+// klogWriter logs the klog entries written to it with logger.
+type klogWriter struct {
+	logger logr.Logger
+}
+
+func (w klogWriter) Write(p []byte) (int, error) {
+	for _, line := range strings.Split(strings.TrimRight(string(p), "\n"), "\n") {
+		m := klogHeader.FindStringSubmatch(line)
+		if m == nil {
+			w.logger.Info(line)
+			continue
+		}
+
+		switch m[1] {
+		case "E", "F":
+			w.logger.Error(nil, m[3], "caller", m[2])
+		case "W":
+			w.logger.Info(m[3], "caller", m[2], "severity", "warning")
+		default:
+			w.logger.Info(m[3], "caller", m[2])
+		}
+	}
+
+	return len(p), nil
+}
@@ -16,15 +16,30 @@ package exec
 
 import (
 	"fmt"
+	"hash/fnv"
 	"os"
+	"os/exec"
+	"strings"
 
 	"github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis"
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
 	"github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/controller"
+	"github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/controller/helmrelease"
+	"github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/release"
+	"github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/tracing"
+	"github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/utils"
 
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	workv1 "github.com/open-cluster-management/api/work/v1"
+	plrv1 "github.com/open-cluster-management/multicloud-operators-placementrule/pkg/apis/apps/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/rest"
 	"k8s.io/klog"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/manager/signals"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
 )
 
 // Change below variables to serve metrics on different host or port.
@@ -36,22 +51,246 @@ var (
 
 // RunManager starts the actual manager
 func RunManager() {
+	if err := setupLogging(options.LogFormat, options.LogLevel); err != nil {
+		klog.Error(err, " - Invalid logging flags")
+		os.Exit(1)
+	}
+
+	helmrelease.Options.Storage = release.StorageOptions{
+		Driver:              options.StorageDriver,
+		SQLConnectionString: options.SQLConnectionString,
+		VaultAddress:        options.VaultAddress,
+		VaultTransitKey:     options.VaultTransitKey,
+		VaultTokenFile:      options.VaultTokenFile,
+	}
+
+	if err := helmrelease.Options.Storage.Validate(); err != nil {
+		klog.Error(err, "")
+		os.Exit(1)
+	}
+
+	klog.Info("Helm release records are stored with the ", options.StorageDriver, " storage driver")
+
+	if options.VaultTransitKey != "" {
+		klog.Info("Helm release payloads are encrypted with the vault transit key ", options.VaultTransitKey)
+	}
+
+	switch options.ReleaseRecordGC {
+	case helmrelease.RecordGCDisabled, helmrelease.RecordGCDryRun, helmrelease.RecordGCEnabled:
+		helmrelease.Options.RecordGC = options.ReleaseRecordGC
+	default:
+		klog.Error("unknown release record gc mode ", options.ReleaseRecordGC)
+		os.Exit(1)
+	}
+
+	if options.MaxConcurrent < 1 {
+		klog.Error("max-concurrent-reconciles must be at least 1, got ", options.MaxConcurrent)
+		os.Exit(1)
+	}
+
+	helmrelease.Options.MaxConcurrentReconciles = options.MaxConcurrent
+
+	if options.DownloadWorkers < 1 || options.DownloadPerHost < 1 {
+		klog.Error("download-concurrency and download-concurrency-per-host must be at least 1, got ",
+			options.DownloadWorkers, " and ", options.DownloadPerHost)
+		os.Exit(1)
+	}
+
+	utils.SetDownloadConcurrency(options.DownloadWorkers, options.DownloadPerHost)
+
+	if options.KubeAPIQPS <= 0 || options.KubeAPIBurst < 1 || options.KubeAPITimeout < 0 ||
+		options.HelmAPIQPS < 0 || options.HelmAPIBurst < 0 || options.HelmAPITimeout < 0 {
+		klog.Error("the kube-api and helm-kube-api qps and burst must be positive and their timeouts must not be negative")
+		os.Exit(1)
+	}
+
+	helmrelease.Options.KubeClient = release.ClientOptions{
+		QPS: options.KubeAPIQPS, Burst: options.KubeAPIBurst, Timeout: options.KubeAPITimeout,
+	}
+	helmrelease.Options.HelmClient = release.ClientOptions{
+		QPS: options.HelmAPIQPS, Burst: options.HelmAPIBurst, Timeout: options.HelmAPITimeout,
+	}.Or(helmrelease.Options.KubeClient)
+
+	klog.Info("Sending up to ", options.KubeAPIQPS, " requests per second to the Kubernetes API, ",
+		helmrelease.Options.HelmClient.QPS, " per release from helm")
+
+	patchStrategy, err := parsePatchStrategy(options.PatchStrategy)
+	if err != nil {
+		klog.Error(err, " - Invalid default-patch-strategy")
+		os.Exit(1)
+	}
+
+	helmrelease.Options.PatchStrategy = patchStrategy
+
+	if patchStrategy != "" {
+		klog.Info("Updating the resources without a patch strategy of their own with ", patchStrategy, " patches")
+	}
+
+	leaderElectionID := options.LeaderElectionID
+
+	if options.ShardSelector != "" {
+		selector, err := labels.Parse(options.ShardSelector)
+		if err != nil {
+			klog.Error(err, " - Invalid shard selector")
+			os.Exit(1)
+		}
+
+		helmrelease.Options.ShardSelector = selector
+
+		// each shard elects its own leader
+		leaderElectionID = shardLeaderElectionID(leaderElectionID, selector)
+
+		klog.Info("Reconciling the HelmReleases selected by ", selector.String())
+	}
+
+	if options.RenewDeadline >= options.LeaseDuration || options.RetryPeriod >= options.RenewDeadline {
+		klog.Error("the leader election retry period must be shorter than the renew deadline, itself shorter than the lease duration")
+		os.Exit(1)
+	}
+
+	helmrelease.Options.WatchNamespaces = options.WatchNamespaces
+
+	if options.FinalizerTimeout < 0 {
+		klog.Error("finalizer-timeout must not be negative, got ", options.FinalizerTimeout)
+		os.Exit(1)
+	}
+
+	helmrelease.Options.FinalizerTimeout = options.FinalizerTimeout
+	helmrelease.Options.WatchReleaseResources = options.WatchResources
+
+	if options.ResyncJitter < 0 {
+		klog.Error("resync-jitter must not be negative, got ", options.ResyncJitter)
+		os.Exit(1)
+	}
+
+	helmrelease.Options.ResyncJitter = options.ResyncJitter
+
+	if options.StatusInterval < 0 {
+		klog.Error("status-update-interval must not be negative, got ", options.StatusInterval)
+		os.Exit(1)
+	}
+
+	helmrelease.Options.StatusUpdateInterval = options.StatusInterval
+	helmrelease.Options.ChartBundleNamespace = options.ChartBundleNS
+
+	if options.NamespaceScoped {
+		// the cache of the cluster-wide watches needs cluster-wide permissions
+		if len(options.WatchNamespaces) == 0 {
+			klog.Error("namespace-scoped requires watch-namespaces")
+			os.Exit(1)
+		}
+
+		allowed, err := parseAllowedTargetNamespaces(options.AllowedTargetNS)
+		if err != nil {
+			klog.Error(err, " - Invalid allowed target namespaces")
+			os.Exit(1)
+		}
+
+		helmrelease.Options.NamespaceScoped = true
+		helmrelease.Options.AllowedTargetNamespaces = allowed
+
+		klog.Info("Running in namespace-scoped mode, cluster-scoped resources are rejected")
+	}
+
+	helmrelease.Options.PolicyURL = options.PolicyURL
+
+	if options.PolicyRules != "" {
+		rules, err := helmrelease.LoadPolicyRules(options.PolicyRules)
+		if err != nil {
+			klog.Error(err, " - Invalid policy rules")
+			os.Exit(1)
+		}
+
+		helmrelease.Options.PolicyRules = rules
+
+		klog.Info("Evaluating ", len(rules), " policy rules")
+	}
+
+	helmrelease.Options.AuditLogPath = options.AuditLogPath
+	helmrelease.Options.PodSecurityPreflight = options.PodSecurityCheck
+	helmrelease.Options.AllowClusterScopedResources = options.AllowClusterScoped
+	helmrelease.Options.PermissionsPreflight = options.PermissionsCheck
+
+	if options.NotificationConfig != "" {
+		sinks, err := helmrelease.LoadNotificationSinks(options.NotificationConfig)
+		if err != nil {
+			klog.Error(err, " - Invalid notification config")
+			os.Exit(1)
+		}
+
+		helmrelease.Options.NotificationSinks = sinks
+
+		klog.Info("Posting the notifications to ", len(sinks), " sinks")
+	}
+
+	if options.ManifestExport != "" {
+		sinks, err := helmrelease.LoadManifestSinks(options.ManifestExport)
+		if err != nil {
+			klog.Error(err, " - Invalid manifest export config")
+			os.Exit(1)
+		}
+
+		helmrelease.Options.ManifestSinks = sinks
+
+		klog.Info("Exporting the release manifests to ", len(sinks), " sinks")
+	}
+
+	// read by the validating webhook too
+	appv1.AllowedChartSources = parseAllowedChartSources(options.AllowedSources)
+
+	if options.ImageSignatureKey != "" {
+		if _, err := exec.LookPath(options.CosignPath); err != nil {
+			klog.Error(err, " - image-signature-key requires the cosign binary")
+			os.Exit(1)
+		}
+
+		helmrelease.Options.ImageSignatureKey = options.ImageSignatureKey
+		helmrelease.Options.CosignPath = options.CosignPath
+		helmrelease.Options.SignedImages = options.SignedImages
+	}
+
+	if options.DrainTimeout < 0 {
+		klog.Error("drain-timeout must not be negative, got ", options.DrainTimeout)
+		os.Exit(1)
+	}
+
+	ctrlOptions := ctrl.Options{
+		MetricsBindAddress: fmt.Sprintf("%s:%d", metricsHost, metricsPort),
+		Port:               operatorMetricsPort,
+		// the Secrets and ConfigMaps are read from the API server, not cached
+		NewClient: helmrelease.NewClient,
+	}
+
+	switch len(options.WatchNamespaces) {
+	case 0:
+		klog.Info("Watching the HelmReleases of all namespaces")
+	case 1:
+		ctrlOptions.Namespace = options.WatchNamespaces[0]
+		klog.Info("Watching the HelmReleases of namespace ", ctrlOptions.Namespace)
+	default:
+		ctrlOptions.NewCache = cache.MultiNamespacedCacheBuilder(options.WatchNamespaces)
+		klog.Info("Watching the HelmReleases of namespaces ", options.WatchNamespaces)
+	}
+
 	enableLeaderElection := false
-	if _, err := rest.InClusterConfig(); err == nil {
+	if _, err := rest.InClusterConfig(); err != nil {
+		klog.Info("LeaderElection disabled as not running in a cluster")
+	} else if !options.LeaderElect {
+		klog.Info("LeaderElection disabled by the leader-elect flag")
+	} else {
 		klog.Info("LeaderElection enabled as running in a cluster")
 		enableLeaderElection = true
-	} else {
-		klog.Info("LeaderElection disabled as not running in a cluster")
 	}
 
 	// Create a new Cmd to provide shared dependencies and start components
-	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
-		MetricsBindAddress:      fmt.Sprintf("%s:%d", metricsHost, metricsPort),
-		Port:                    operatorMetricsPort,
-		LeaderElection:          enableLeaderElection,
-		LeaderElectionID:        "multicloud-operators-subscription-release-leader.open-cluster-management.io",
-		LeaderElectionNamespace: "kube-system",
-	})
+	ctrlOptions.LeaderElection = enableLeaderElection
+	ctrlOptions.LeaderElectionID = leaderElectionID
+	ctrlOptions.LeaderElectionNamespace = options.LeaderElectionNS
+	ctrlOptions.LeaseDuration = &options.LeaseDuration
+	ctrlOptions.RenewDeadline = &options.RenewDeadline
+	ctrlOptions.RetryPeriod = &options.RetryPeriod
+
+	mgr, err := ctrl.NewManager(helmrelease.Options.KubeClient.Apply(ctrl.GetConfigOrDie()), ctrlOptions)
 
 	if err != nil {
 		klog.Error(err, "")
@@ -66,12 +305,88 @@ func RunManager() {
 		os.Exit(1)
 	}
 
+	// the HelmReleases with a clusterSelector or a placementRef are deployed with ManifestWorks
+	for _, install := range []func(*runtime.Scheme) error{
+		clusterv1.Install, workv1.Install, plrv1.SchemeBuilder.AddToScheme,
+	} {
+		if err := install(mgr.GetScheme()); err != nil {
+			klog.Error(err, "")
+			os.Exit(1)
+		}
+	}
+
 	// Setup all Controllers
 	if err := controller.AddToManager(mgr); err != nil {
 		klog.Error(err, "")
 		os.Exit(1)
 	}
 
+	// changes the log level without restarting the operator
+	if err := mgr.AddMetricsExtraHandler(logLevelPath, logLevelHandler{}); err != nil {
+		klog.Error(err, " - Failed to add the log level endpoint")
+		os.Exit(1)
+	}
+
+	if options.HealthProbeAddr != "" {
+		// the release records of the first watched namespace, all of them may not be readable
+		storageNamespace := ""
+		if len(options.WatchNamespaces) > 0 {
+			storageNamespace = options.WatchNamespaces[0]
+		}
+
+		health := newHealthServer(options.HealthProbeAddr, mgr.GetConfig(), helmrelease.Options.Storage, storageNamespace)
+		if err := mgr.Add(health); err != nil {
+			klog.Error(err, " - Failed to add the health probes")
+			os.Exit(1)
+		}
+	}
+
+	if err := registerRuntimeMetrics(); err != nil {
+		klog.Error(err, " - Failed to register the go runtime metrics")
+		os.Exit(1)
+	}
+
+	if options.PprofAddress != "" {
+		if err := mgr.Add(pprofServer{address: options.PprofAddress}); err != nil {
+			klog.Error(err, " - Failed to add the pprof server")
+			os.Exit(1)
+		}
+	}
+
+	if err := mgr.AddMetricsExtraHandler(helmrelease.SummaryPath, helmrelease.SummaryHandler()); err != nil {
+		klog.Error(err, " - Failed to add the fleet summary endpoint")
+		os.Exit(1)
+	}
+
+	otlpEndpoint := options.OTLPEndpoint
+	if otlpEndpoint == "" {
+		otlpEndpoint = os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT")
+	}
+
+	var exporter *tracing.Exporter
+
+	if otlpEndpoint != "" {
+		exporter = tracing.Setup(otlpEndpoint)
+
+		if err := mgr.Add(exporter); err != nil {
+			klog.Error(err, " - Failed to add the span exporter")
+			os.Exit(1)
+		}
+
+		klog.Info("Exporting the spans of the reconciles to ", otlpEndpoint)
+	}
+
+	if options.EnableWebhooks {
+		if err := ctrl.NewWebhookManagedBy(mgr).For(&appv1.HelmRelease{}).Complete(); err != nil {
+			klog.Error(err, " - Failed to register the HelmRelease webhooks")
+			os.Exit(1)
+		}
+
+		mgr.GetWebhookServer().Register(appv1.ApprovalWebhookPath, &webhook.Admission{Handler: appv1.WaveApprovalHandler{}})
+
+		klog.Info("Serving the HelmRelease webhooks on port ", operatorMetricsPort)
+	}
+
 	sig := signals.SetupSignalHandler()
 
 	klog.Info("Starting the Cmd.")
@@ -81,4 +396,74 @@ func RunManager() {
 		klog.Error(err, "Manager exited non-zero")
 		os.Exit(1)
 	}
+
+	// a helm action killed midway leaves its release pending
+	klog.Info("Draining the running reconciles for up to ", options.DrainTimeout)
+	helmrelease.Drain(mgr.GetClient(), options.DrainTimeout)
+
+	// the spans of the drained reconciles
+	if exporter != nil {
+		exporter.Flush()
+	}
+}
+
+// shardLeaderElectionID prefixes id with a hash of the shard selector so that
+// the operator instances of different shards do not compete for leadership.
+func shardLeaderElectionID(id string, selector labels.Selector) string {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(selector.String()))
+
+	return fmt.Sprintf("%08x-%s", h.Sum32(), id)
+}
+
+// parseAllowedTargetNamespaces parses the namespace=target pairs of the
+// allowed target namespaces into the targets of each namespace.
+func parseAllowedTargetNamespaces(pairs []string) (map[string][]string, error) {
+	allowed := make(map[string][]string)
+
+	for _, pair := range pairs {
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("%q is not a namespace=target pair", pair)
+		}
+
+		allowed[parts[0]] = append(allowed[parts[0]], parts[1])
+	}
+
+	return allowed, nil
+}
+
+// parsePatchStrategy parses the default patch strategy, empty to update the
+// resources as helm does.
+func parsePatchStrategy(strategy string) (appv1.PatchStrategyEnum, error) {
+	switch s := appv1.PatchStrategyEnum(strategy); s {
+	case "", appv1.StrategicMergePatchStrategy, appv1.MergePatchStrategy, appv1.JSONPatchStrategy,
+		appv1.ReplacePatchStrategy:
+		return s, nil
+	default:
+		return "", fmt.Errorf("unknown patch strategy %q, expected strategic, merge, json or replace", strategy)
+	}
+}
+
+// parseAllowedChartSources parses the allowed chart sources into the sources
+// of each namespace, the ones without a namespace= prefix under "".
+func parseAllowedChartSources(sources []string) map[string][]string {
+	if len(sources) == 0 {
+		return nil
+	}
+
+	allowed := make(map[string][]string)
+
+	for _, source := range sources {
+		namespace := ""
+
+		// the namespaces have no dots, unlike the hosts and the URLs
+		if i := strings.Index(source, "="); i > 0 && !strings.ContainsAny(source[:i], "./:") {
+			namespace, source = source[:i], source[i+1:]
+		}
+
+		allowed[namespace] = append(allowed[namespace], source)
+	}
+
+	return allowed
 }
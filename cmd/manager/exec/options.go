@@ -15,16 +15,94 @@
 package exec
 
 import (
+	"time"
+
 	pflag "github.com/spf13/pflag"
+
+	"github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/controller/helmrelease"
+	"github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/release"
+	"github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/utils"
 )
 
 // SubscriptionReleaseCMDOptions for command line flag parsing
 type SubscriptionReleaseCMDOptions struct {
-	MetricsAddr string
+	MetricsAddr         string
+	StorageDriver       string
+	SQLConnectionString string
+	VaultAddress        string
+	VaultTransitKey     string
+	VaultTokenFile      string
+	ReleaseRecordGC     string
+	MaxConcurrent       int
+	DownloadWorkers     int
+	DownloadPerHost     int
+	KubeAPIQPS          float32
+	KubeAPIBurst        int
+	KubeAPITimeout      time.Duration
+	HelmAPIQPS          float32
+	HelmAPIBurst        int
+	HelmAPITimeout      time.Duration
+	PatchStrategy       string
+	ShardSelector       string
+	LeaderElect         bool
+	LeaseDuration       time.Duration
+	RenewDeadline       time.Duration
+	RetryPeriod         time.Duration
+	LeaderElectionNS    string
+	LeaderElectionID    string
+	WatchNamespaces     []string
+	FinalizerTimeout    time.Duration
+	WatchResources      bool
+	ResyncJitter        time.Duration
+	StatusInterval      time.Duration
+	EnableWebhooks      bool
+	DrainTimeout        time.Duration
+	ChartBundleNS       string
+	NamespaceScoped     bool
+	AllowedTargetNS     []string
+	PolicyURL           string
+	PolicyRules         string
+	AuditLogPath        string
+	ImageSignatureKey   string
+	CosignPath          string
+	SignedImages        []string
+	PodSecurityCheck    bool
+	AllowedSources      []string
+	AllowClusterScoped  bool
+	PermissionsCheck    bool
+	OTLPEndpoint        string
+	LogFormat           string
+	LogLevel            string
+	PprofAddress        string
+	NotificationConfig  string
+	ManifestExport      string
+	HealthProbeAddr     string
 }
 
 var options = SubscriptionReleaseCMDOptions{
-	MetricsAddr: "",
+	MetricsAddr:        "",
+	StorageDriver:      release.SecretsStorageDriver,
+	ReleaseRecordGC:    helmrelease.RecordGCDryRun,
+	MaxConcurrent:      helmrelease.DefaultMaxConcurrentReconciles,
+	DownloadWorkers:    utils.DefaultDownloadConcurrency,
+	DownloadPerHost:    utils.DefaultDownloadConcurrencyPerHost,
+	KubeAPIQPS:         20,
+	KubeAPIBurst:       30,
+	LeaderElect:        true,
+	LeaseDuration:      15 * time.Second,
+	RenewDeadline:      10 * time.Second,
+	RetryPeriod:        2 * time.Second,
+	LeaderElectionNS:   "kube-system",
+	LeaderElectionID:   "multicloud-operators-subscription-release-leader.open-cluster-management.io",
+	StatusInterval:     helmrelease.DefaultStatusUpdateInterval,
+	DrainTimeout:       helmrelease.DefaultDrainTimeout,
+	CosignPath:         helmrelease.DefaultCosignPath,
+	PodSecurityCheck:   true,
+	AllowClusterScoped: true,
+	PermissionsCheck:   true,
+	LogFormat:          "text",
+	LogLevel:           "info",
+	HealthProbeAddr:    ":8383",
 }
 
 // ProcessFlags parses command line parameters into options
@@ -37,4 +115,354 @@ func ProcessFlags() {
 		options.MetricsAddr,
 		"The address the metric endpoint binds to.",
 	)
+
+	flag.StringVar(
+		&options.StorageDriver,
+		"helm-storage-driver",
+		options.StorageDriver,
+		"The helm storage driver of the release records: secret, configmap or sql.",
+	)
+
+	flag.StringVar(
+		&options.SQLConnectionString,
+		"helm-storage-sql-connection",
+		options.SQLConnectionString,
+		"The postgres connection string used by the sql helm storage driver.",
+	)
+
+	flag.StringVar(
+		&options.VaultAddress,
+		"helm-storage-vault-address",
+		options.VaultAddress,
+		"The address of the Vault server encrypting the payloads of the release records.",
+	)
+
+	flag.StringVar(
+		&options.VaultTransitKey,
+		"helm-storage-vault-transit-key",
+		options.VaultTransitKey,
+		"The mount/name of the Vault transit key envelope encrypting the payloads of the release records, e.g. transit/helm-releases. Disabled by default.",
+	)
+
+	flag.StringVar(
+		&options.VaultTokenFile,
+		"helm-storage-vault-token-file",
+		options.VaultTokenFile,
+		"The file of the Vault token, read at each request. The VAULT_TOKEN environment variable is used by default.",
+	)
+
+	flag.StringVar(
+		&options.ReleaseRecordGC,
+		"release-record-gc",
+		options.ReleaseRecordGC,
+		"Garbage collection of the release records of deleted HelmReleases: disabled, dry-run or enabled.",
+	)
+
+	flag.IntVar(
+		&options.MaxConcurrent,
+		"max-concurrent-reconciles",
+		options.MaxConcurrent,
+		"The number of HelmReleases reconciled in parallel.",
+	)
+
+	flag.IntVar(
+		&options.DownloadWorkers,
+		"download-concurrency",
+		options.DownloadWorkers,
+		"The number of chart downloads and git clones running in parallel, shared by the reconciles and the chart prefetch.",
+	)
+
+	flag.IntVar(
+		&options.DownloadPerHost,
+		"download-concurrency-per-host",
+		options.DownloadPerHost,
+		"The number of chart downloads and git clones from the same host running in parallel.",
+	)
+
+	flag.Float32Var(
+		&options.KubeAPIQPS,
+		"kube-api-qps",
+		options.KubeAPIQPS,
+		"The requests per second the clients of the controller send to the Kubernetes API on average.",
+	)
+
+	flag.IntVar(
+		&options.KubeAPIBurst,
+		"kube-api-burst",
+		options.KubeAPIBurst,
+		"The requests the clients of the controller may send to the Kubernetes API at once above kube-api-qps.",
+	)
+
+	flag.DurationVar(
+		&options.KubeAPITimeout,
+		"kube-api-timeout",
+		options.KubeAPITimeout,
+		"The timeout of each request of the clients of the controller to the Kubernetes API. None by default.",
+	)
+
+	flag.Float32Var(
+		&options.HelmAPIQPS,
+		"helm-kube-api-qps",
+		options.HelmAPIQPS,
+		"The requests per second the helm client of each release sends to the Kubernetes API on average. Defaults to kube-api-qps.",
+	)
+
+	flag.IntVar(
+		&options.HelmAPIBurst,
+		"helm-kube-api-burst",
+		options.HelmAPIBurst,
+		"The requests the helm client of each release may send to the Kubernetes API at once above helm-kube-api-qps. Defaults to kube-api-burst.",
+	)
+
+	flag.DurationVar(
+		&options.HelmAPITimeout,
+		"helm-kube-api-timeout",
+		options.HelmAPITimeout,
+		"The timeout of each request of the helm client of each release to the Kubernetes API, the watches of the hooks included. Defaults to kube-api-timeout.",
+	)
+
+	flag.StringVar(
+		&options.PatchStrategy,
+		"default-patch-strategy",
+		options.PatchStrategy,
+		"The patch strategy of the resources whose kind has none in repo.patchStrategies: strategic, merge, json or replace. The resources are updated as helm does if empty. Ignored with repo.serverSideApply.",
+	)
+
+	flag.StringVar(
+		&options.ShardSelector,
+		"shard-selector",
+		options.ShardSelector,
+		"The label selector of the HelmReleases reconciled by this operator instance, e.g. shard=team-a. All of them by default.",
+	)
+
+	flag.BoolVar(
+		&options.LeaderElect,
+		"leader-elect",
+		options.LeaderElect,
+		"Elect a leader among the operator replicas. Leader election is always disabled when not running in a cluster.",
+	)
+
+	flag.DurationVar(
+		&options.LeaseDuration,
+		"leader-election-lease-duration",
+		options.LeaseDuration,
+		"The duration the non-leader replicas wait before taking over the leadership of a leader that stopped renewing it.",
+	)
+
+	flag.DurationVar(
+		&options.RenewDeadline,
+		"leader-election-renew-deadline",
+		options.RenewDeadline,
+		"The duration the leader retries renewing its leadership before giving it up.",
+	)
+
+	flag.DurationVar(
+		&options.RetryPeriod,
+		"leader-election-retry-period",
+		options.RetryPeriod,
+		"The duration the replicas wait between the leader election actions.",
+	)
+
+	flag.StringVar(
+		&options.LeaderElectionNS,
+		"leader-election-namespace",
+		options.LeaderElectionNS,
+		"The namespace of the leader election lock.",
+	)
+
+	flag.StringVar(
+		&options.LeaderElectionID,
+		"leader-election-id",
+		options.LeaderElectionID,
+		"The name of the leader election lock. Shards prefix it with a hash of their selector.",
+	)
+
+	flag.StringSliceVar(
+		&options.WatchNamespaces,
+		"watch-namespaces",
+		options.WatchNamespaces,
+		"Comma-separated namespaces of the HelmReleases reconciled by this operator instance. All namespaces by default.",
+	)
+
+	flag.DurationVar(
+		&options.FinalizerTimeout,
+		"finalizer-timeout",
+		options.FinalizerTimeout,
+		"The duration after which a deleted HelmRelease whose release fails to uninstall loses its finalizer. Never by default.",
+	)
+
+	flag.BoolVar(
+		&options.WatchResources,
+		"watch-release-resources",
+		options.WatchResources,
+		"Reconcile a HelmRelease as soon as one of its resources is modified or deleted. The resources of the deployed kinds are cached.",
+	)
+
+	flag.DurationVar(
+		&options.ResyncJitter,
+		"resync-jitter",
+		options.ResyncJitter,
+		"The duration the reconciles of the healthy HelmReleases are spread over when the operator starts. All at once by default.",
+	)
+
+	flag.DurationVar(
+		&options.StatusInterval,
+		"status-update-interval",
+		options.StatusInterval,
+		"The minimum interval between two status writes of a HelmRelease. A status changed sooner is written once the interval elapsed, coalesced with the later changes. 0 writes every change right away.",
+	)
+
+	flag.BoolVar(
+		&options.EnableWebhooks,
+		"enable-webhooks",
+		options.EnableWebhooks,
+		"Serve the HelmRelease admission and conversion webhooks, with the certificate mounted in /tmp/k8s-webhook-server/serving-certs.",
+	)
+
+	flag.DurationVar(
+		&options.DrainTimeout,
+		"drain-timeout",
+		options.DrainTimeout,
+		"The duration the running installs, upgrades and uninstalls are waited for when the operator stops. Must fit in the termination grace period of the pod.",
+	)
+
+	flag.StringVar(
+		&options.ChartBundleNS,
+		"chart-bundle-namespace",
+		options.ChartBundleNS,
+		"The namespace of the ConfigMaps bundling pre-staged chart archives, used instead of downloading the charts. Disabled by default.",
+	)
+
+	flag.BoolVar(
+		&options.NamespaceScoped,
+		"namespace-scoped",
+		options.NamespaceScoped,
+		"Run with namespaced permissions only, in the namespaces of --watch-namespaces. The HelmReleases may only deploy namespaced resources to their own namespace or to their allowed target namespaces.",
+	)
+
+	flag.StringSliceVar(
+		&options.AllowedTargetNS,
+		"allowed-target-namespaces",
+		options.AllowedTargetNS,
+		"Comma-separated namespace=target pairs allowing the HelmReleases of namespace to deploy to target in namespace-scoped mode.",
+	)
+
+	flag.StringVar(
+		&options.PolicyURL,
+		"policy-url",
+		options.PolicyURL,
+		"The OPA data API URL of the policy decision evaluated for each rendered resource before the installs and upgrades, e.g. http://opa:8181/v1/data/helmrelease/violation. Disabled by default.",
+	)
+
+	flag.StringVar(
+		&options.PolicyRules,
+		"policy-rules",
+		options.PolicyRules,
+		"File listing the CEL rules evaluated for each rendered resource before the installs and upgrades, blocking the release or recording a warning per rule severity. Disabled by default.",
+	)
+
+	flag.StringVar(
+		&options.AuditLogPath,
+		"audit-log-path",
+		options.AuditLogPath,
+		"The file the install, upgrade, rollback and uninstall of the releases are appended to as JSON lines. The actions are only recorded in the HelmRelease status history by default.",
+	)
+
+	flag.StringVar(
+		&options.ImageSignatureKey,
+		"image-signature-key",
+		options.ImageSignatureKey,
+		"The cosign public key, a file or a KMS URI, verifying the signatures of the images of the rendered workloads before the installs and upgrades. Disabled by default.",
+	)
+
+	flag.StringVar(
+		&options.CosignPath,
+		"cosign-path",
+		options.CosignPath,
+		"The cosign binary verifying the image signatures.",
+	)
+
+	flag.StringSliceVar(
+		&options.SignedImages,
+		"signed-images",
+		options.SignedImages,
+		"Comma-separated prefixes of the images that must be signed, e.g. registry.example.com/team/. All the images by default.",
+	)
+
+	flag.BoolVar(
+		&options.PodSecurityCheck,
+		"pod-security-preflight",
+		options.PodSecurityCheck,
+		"Check the rendered workloads against the Pod Security Admission levels of their namespaces before the installs and upgrades.",
+	)
+
+	flag.StringSliceVar(
+		&options.AllowedSources,
+		"allowed-chart-sources",
+		options.AllowedSources,
+		"Comma-separated chart sources allowed for the HelmReleases, hosts or URL prefixes, prefixed with namespace= to only allow them in namespace, e.g. charts.example.com,team-a=https://github.com/team-a/. All sources by default.",
+	)
+
+	flag.BoolVar(
+		&options.AllowClusterScoped,
+		"allow-cluster-scoped-resources",
+		options.AllowClusterScoped,
+		"Allow the charts to deploy cluster-scoped resources, the default of repo.allowClusterScopedResources.",
+	)
+
+	flag.BoolVar(
+		&options.PermissionsCheck,
+		"permissions-preflight",
+		options.PermissionsCheck,
+		"Review the permissions of the operator, or of the impersonated ServiceAccount, on the rendered resources before the installs and upgrades.",
+	)
+
+	flag.StringVar(
+		&options.OTLPEndpoint,
+		"otlp-endpoint",
+		options.OTLPEndpoint,
+		"OTLP/HTTP endpoint of the OpenTelemetry collector the spans of the reconciles are exported to, e.g. http://otel-collector:4318. Defaults to the OTEL_EXPORTER_OTLP_ENDPOINT environment variable, the spans are not recorded if neither is set.",
+	)
+
+	flag.StringVar(
+		&options.LogFormat,
+		"log-format",
+		options.LogFormat,
+		"Format of the log entries: text or json. The klog entries are logged as json entries too in the json format.",
+	)
+
+	flag.StringVar(
+		&options.LogLevel,
+		"log-level",
+		options.LogLevel,
+		"Level of the log entries: error, info, debug or a verbosity, e.g. 3. Sets the klog -v verbosity too.",
+	)
+
+	flag.StringVar(
+		&options.PprofAddress,
+		"pprof-bind-address",
+		options.PprofAddress,
+		"Address the pprof profiles are served on, e.g. localhost:6060. The profiles are not served if empty.",
+	)
+
+	flag.StringVar(
+		&options.NotificationConfig,
+		"notification-config",
+		options.NotificationConfig,
+		"File listing the webhook, Slack and Microsoft Teams sinks the installs, upgrades, rollbacks and failures of the HelmReleases are posted to, e.g. mounted from a Secret. Nothing is posted if empty.",
+	)
+
+	flag.StringVar(
+		&options.ManifestExport,
+		"manifest-export-config",
+		options.ManifestExport,
+		"File listing the ConfigMap, S3 and git sinks the manifests of the installed and upgraded releases are exported to, e.g. mounted from a Secret. Nothing is exported if empty.",
+	)
+
+	flag.StringVar(
+		&options.HealthProbeAddr,
+		"health-probe-bind-address",
+		options.HealthProbeAddr,
+		"Address the /healthz and /readyz probes are served on. The probes are not served if empty.",
+	)
 }
@@ -0,0 +1,91 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/pprof"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/klog"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// registerRuntimeMetrics adds the go runtime and process metrics, e.g. the
+// goroutines, the heap and the GC pauses, to the metrics of the operator.
+func registerRuntimeMetrics() error {
+	for _, collector := range []prometheus.Collector{
+		prometheus.NewGoCollector(),
+		prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}),
+	} {
+		if err := metrics.Registry.Register(collector); err != nil {
+			// already registered by controller-runtime
+			var registered prometheus.AlreadyRegisteredError
+			if errors.As(err, &registered) {
+				continue
+			}
+
+			return err
+		}
+	}
+
+	return nil
+}
+
+// pprofServer serves the net/http/pprof profiles on its address while the
+// manager runs. The profiles are not served with the metrics, they expose the
+// memory of the operator.
+type pprofServer struct {
+	address string
+}
+
+// Start implements manager.Runnable.
+func (s pprofServer) Start(stop <-chan struct{}) error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+
+	server := &http.Server{Addr: s.address, Handler: mux}
+
+	errs := make(chan error, 1)
+
+	go func() {
+		klog.Info("Serving the pprof profiles on ", s.address)
+		errs <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-stop:
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	return server.Shutdown(ctx)
+}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable, the standby
+// operators are profiled too.
+func (s pprofServer) NeedLeaderElection() bool {
+	return false
+}
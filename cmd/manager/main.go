@@ -18,6 +18,8 @@ package main
 
 import (
 	"flag"
+	// the time zones of the upgrade windows do not depend on the image
+	_ "time/tzdata"
 
 	"github.com/spf13/pflag"
 
@@ -0,0 +1,104 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// AllowedChartSources restricts the chart sources of the HelmReleases, by
+// namespace. The sources of the "" key are allowed in every namespace. A
+// source is a host, e.g. charts.example.com, or a URL prefix, e.g.
+// https://github.com/example/. All the sources are allowed if it is empty.
+// It is set from the operator flags.
+var AllowedChartSources map[string][]string
+
+// CheckChartSource returns an error if a location of source is not allowed
+// for the HelmReleases of namespace by AllowedChartSources.
+func CheckChartSource(namespace string, source *Source) error {
+	if len(AllowedChartSources) == 0 || source == nil {
+		return nil
+	}
+
+	var urls []string
+
+	if source.HelmRepo != nil {
+		urls = append(urls, source.HelmRepo.Urls...)
+	}
+
+	if source.GitHub != nil {
+		urls = append(urls, source.GitHub.Urls...)
+	}
+
+	if source.Git != nil {
+		urls = append(urls, source.Git.Urls...)
+	}
+
+	allowed := make([]string, 0, len(AllowedChartSources[""])+len(AllowedChartSources[namespace]))
+	allowed = append(allowed, AllowedChartSources[""]...)
+	allowed = append(allowed, AllowedChartSources[namespace]...)
+
+	for _, u := range urls {
+		if !chartSourceAllowed(u, allowed) {
+			return fmt.Errorf("chart source %s is not allowed for the HelmReleases of namespace %s", u, namespace)
+		}
+	}
+
+	return nil
+}
+
+// chartSourceAllowed returns true if the host of location is one of the
+// allowed hosts, or location starts with one of the allowed URL prefixes.
+func chartSourceAllowed(location string, allowed []string) bool {
+	host := chartSourceHost(location)
+
+	for _, a := range allowed {
+		if !strings.Contains(a, "://") {
+			// the port is optional
+			if host != "" && (strings.EqualFold(host, a) || strings.EqualFold(strings.Split(host, ":")[0], a)) {
+				return true
+			}
+
+			continue
+		}
+
+		// the prefix ends at a path boundary, https://example.com/team does
+		// not allow https://example.com/team-other
+		if location == a || (strings.HasPrefix(location, a) &&
+			(strings.HasSuffix(a, "/") || location[len(a)] == '/')) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// chartSourceHost returns the host of location, a URL or a scp-like git
+// location such as git@github.com:example/charts.git.
+func chartSourceHost(location string) string {
+	if u, err := url.Parse(location); err == nil && u.Scheme != "" && u.Host != "" {
+		return u.Host
+	}
+
+	if i := strings.Index(location, ":"); i > 0 && !strings.Contains(location[:i], "/") {
+		return location[strings.Index(location[:i], "@")+1 : i]
+	}
+
+	return ""
+}
@@ -0,0 +1,79 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+func TestCheckChartSource(t *testing.T) {
+	defer func(allowed map[string][]string) { AllowedChartSources = allowed }(AllowedChartSources)
+
+	helmRepo := func(urls ...string) *Source {
+		return &Source{SourceType: HelmRepoSourceType, HelmRepo: &HelmRepo{Urls: urls}}
+	}
+
+	// every source is allowed without restrictions
+	assert.NoError(t, CheckChartSource("team-a", helmRepo("https://charts.example.com/webapp-1.0.0.tgz")))
+
+	AllowedChartSources = map[string][]string{
+		"":       {"charts.example.com"},
+		"team-a": {"https://github.com/team-a", "git.example.com"},
+	}
+
+	for namespace, sources := range map[string]map[*Source]bool{
+		"team-a": {
+			helmRepo("https://charts.example.com/webapp-1.0.0.tgz"):                                              true,
+			helmRepo("https://charts.example.com:8443/webapp-1.0.0.tgz"):                                         true,
+			helmRepo("https://charts.example.com/webapp-1.0.0.tgz", "https://mirror.example.com/webapp.tgz"):     false,
+			{SourceType: GitHubSourceType, GitHub: &GitHub{Urls: []string{"https://github.com/team-a/charts"}}}:  true,
+			{SourceType: GitHubSourceType, GitHub: &GitHub{Urls: []string{"https://github.com/team-a"}}}:         true,
+			{SourceType: GitHubSourceType, GitHub: &GitHub{Urls: []string{"https://github.com/team-ab/charts"}}}: false,
+			{SourceType: GitSourceType, Git: &Git{Urls: []string{"git@git.example.com:team-a/charts.git"}}}:      true,
+		},
+		"team-b": {
+			helmRepo("https://charts.example.com/webapp-1.0.0.tgz"):                                             true,
+			{SourceType: GitHubSourceType, GitHub: &GitHub{Urls: []string{"https://github.com/team-a/charts"}}}: false,
+			{SourceType: GitSourceType, Git: &Git{Urls: []string{"git@git.example.com:team-a/charts.git"}}}:     false,
+		},
+	} {
+		for source, allowed := range sources {
+			err := CheckChartSource(namespace, source)
+			assert.Equal(t, allowed, err == nil, "%s: %+v: %v", namespace, source, err)
+		}
+	}
+}
+
+func TestHelmReleaseValidateChartSource(t *testing.T) {
+	defer func(allowed map[string][]string) { AllowedChartSources = allowed }(AllowedChartSources)
+
+	AllowedChartSources = map[string][]string{"": {"mirror.example.com"}}
+
+	hr := newWebhookTestHelmRelease()
+	assert.True(t, apierrors.IsInvalid(hr.ValidateCreate()))
+
+	// a HelmRelease whose source is no longer allowed can still be updated
+	old := newWebhookTestHelmRelease()
+	hr.SetFinalizers([]string{})
+	assert.NoError(t, hr.ValidateUpdate(old))
+
+	hr.Repo.Source.HelmRepo.Urls = []string{"https://charts.example.com/webapp-1.1.0.tgz"}
+	assert.True(t, apierrors.IsInvalid(hr.ValidateUpdate(old)))
+}
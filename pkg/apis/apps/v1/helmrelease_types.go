@@ -20,11 +20,13 @@ import (
 	"encoding/json"
 	"fmt"
 	"strings"
+	"time"
 
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	runtime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
 // EDIT THIS FILE!  THIS IS SCAFFOLDING FOR YOU TO OWN!
@@ -33,6 +35,20 @@ import (
 //ChartsDir env variable name which contains the directory where the charts are installed
 const ChartsDir = "CHARTS_DIR"
 
+// Defaults of the repo settings, set explicitly by the defaulting webhook
+const (
+	// DefaultMaxHistory is the number of release revisions kept, like the helm CLI does
+	DefaultMaxHistory = 10
+	// DefaultWaitTimeout is the time waited for the resources of a release to be ready, like the helm CLI does
+	DefaultWaitTimeout = 5 * time.Minute
+	// DefaultInterval is how often a deployed release is re-reconciled
+	DefaultInterval = 10 * time.Minute
+	// DefaultCanarySoak is how long the canary clusters must stay available before the others are upgraded
+	DefaultCanarySoak = 10 * time.Minute
+	// DefaultFieldManager is the field manager recorded in managedFields for the resources of the releases
+	DefaultFieldManager = "multicluster-operators-subscription-release"
+)
+
 //SourceTypeEnum types of sources
 type SourceTypeEnum string
 
@@ -85,6 +101,342 @@ func (s Source) String() string {
 	}
 }
 
+// PatchStrategyEnum types of patches used to update the resources of a release
+type PatchStrategyEnum string
+
+const (
+	// StrategicMergePatchStrategy updates resources with a three-way strategic merge patch
+	StrategicMergePatchStrategy PatchStrategyEnum = "strategic"
+	// MergePatchStrategy updates resources with a three-way JSON merge patch
+	MergePatchStrategy PatchStrategyEnum = "merge"
+	// JSONPatchStrategy updates resources with a JSON patch
+	JSONPatchStrategy PatchStrategyEnum = "json"
+	// ReplacePatchStrategy replaces resources with the rendered object
+	ReplacePatchStrategy PatchStrategyEnum = "replace"
+)
+
+// ConflictPolicyEnum decides how server-side apply conflicts are handled
+type ConflictPolicyEnum string
+
+const (
+	// FailConflictPolicy fails the apply and reports the conflicting field managers
+	FailConflictPolicy ConflictPolicyEnum = "fail"
+	// ForceConflictPolicy takes the ownership of the conflicting fields
+	ForceConflictPolicy ConflictPolicyEnum = "force"
+)
+
+// DeletionPolicyEnum decides what happens to the release of a deleted HelmRelease
+type DeletionPolicyEnum string
+
+const (
+	// DeleteDeletionPolicy uninstalls the release
+	DeleteDeletionPolicy DeletionPolicyEnum = "Delete"
+	// OrphanDeletionPolicy deletes the release records and leaves the resources running
+	OrphanDeletionPolicy DeletionPolicyEnum = "Orphan"
+)
+
+// PendingReleasePolicyEnum decides how a release left pending by a helm action that never
+// completed is recovered
+type PendingReleasePolicyEnum string
+
+const (
+	// RetryPendingReleasePolicy deletes the pending revision and runs the action again
+	RetryPendingReleasePolicy PendingReleasePolicyEnum = "Retry"
+	// RollbackPendingReleasePolicy rolls the pending revision back to the deployed revision,
+	// or uninstalls it if there is none
+	RollbackPendingReleasePolicy PendingReleasePolicyEnum = "Rollback"
+	// FailPendingReleasePolicy leaves the pending revision as is until a reconcile is requested
+	FailPendingReleasePolicy PendingReleasePolicyEnum = "Fail"
+)
+
+// DeprecatedAPIPolicyEnum decides what the rendered resources using deprecated Kubernetes API
+// versions do to the release
+type DeprecatedAPIPolicyEnum string
+
+const (
+	// WarnDeprecatedAPIPolicy reports the deprecated API versions, the release is still deployed
+	WarnDeprecatedAPIPolicy DeprecatedAPIPolicyEnum = "Warn"
+	// BlockDeprecatedAPIPolicy keeps the release from being installed or upgraded while it uses
+	// API versions removed in the version of the cluster or in the next minor version
+	BlockDeprecatedAPIPolicy DeprecatedAPIPolicyEnum = "Block"
+	// IgnoreDeprecatedAPIPolicy does not scan the rendered resources
+	IgnoreDeprecatedAPIPolicy DeprecatedAPIPolicyEnum = "Ignore"
+)
+
+// PriorityEnum orders the reconciles of the HelmReleases after a restart of the operator
+type PriorityEnum string
+
+const (
+	// HighPriority HelmReleases are reconciled first, e.g. ingress controllers or cert-manager
+	HighPriority PriorityEnum = "high"
+	// NormalPriority HelmReleases are reconciled after the high priority ones
+	NormalPriority PriorityEnum = "normal"
+	// LowPriority HelmReleases are reconciled last
+	LowPriority PriorityEnum = "low"
+)
+
+// PatchStrategy overrides the patch type used to update the resources of a kind
+type PatchStrategy struct {
+	// APIVersion of the resources, matches all versions if empty
+	APIVersion string `json:"apiVersion,omitempty"`
+	// Kind of the resources
+	Kind string `json:"kind"`
+	// Strategy is one of strategic, merge, json or replace
+	Strategy PatchStrategyEnum `json:"strategy"`
+}
+
+// ResourceIgnoreDifferences excludes fields of the matching resources when comparing
+// the deployed and the candidate release manifests
+type ResourceIgnoreDifferences struct {
+	// APIVersion of the resources, matches all versions if empty
+	APIVersion string `json:"apiVersion,omitempty"`
+	// Kind of the resources
+	Kind string `json:"kind"`
+	// Name of the resource, matches all resources of the kind if empty
+	Name string `json:"name,omitempty"`
+	// JSONPointers to the ignored fields, e.g. /spec/replicas
+	JSONPointers []string `json:"jsonPointers,omitempty"`
+	// JQPathExpressions to the ignored fields, e.g. .spec.template.spec.containers[0].image
+	JQPathExpressions []string `json:"jqPathExpressions,omitempty"`
+}
+
+// HistoryCompaction deletes the release revisions that are not worth keeping. The first
+// deployed revision, the currently deployed revision and the revisions pinned with the
+// apps.open-cluster-management.io/pinned-revisions annotation are always kept.
+type HistoryCompaction struct {
+	// KeepLast is the number of most recent revisions kept
+	KeepLast int `json:"keepLast"`
+}
+
+// ResourceTimeout overrides the timeout of the resources of a kind
+type ResourceTimeout struct {
+	// APIVersion of the resources, matches all versions if empty
+	APIVersion string `json:"apiVersion,omitempty"`
+	// Kind of the resources
+	Kind string `json:"kind"`
+	// Timeout to wait for the resources to be ready, or for the hooks of the kind to complete
+	Timeout metav1.Duration `json:"timeout"`
+}
+
+// ResourceKind identifies the resources of a kind
+type ResourceKind struct {
+	// APIVersion of the resources, matches all versions if empty
+	APIVersion string `json:"apiVersion,omitempty"`
+	// Kind of the resources
+	Kind string `json:"kind"`
+}
+
+// ResourceSelector selects the resources of a kind, or a single resource
+type ResourceSelector struct {
+	// APIVersion of the resources, matches all versions if empty
+	APIVersion string `json:"apiVersion,omitempty"`
+	// Kind of the resources
+	Kind string `json:"kind"`
+	// Name of the resource, matches all resources of the kind if empty
+	Name string `json:"name,omitempty"`
+}
+
+// ResourcePatch patches the matching rendered resources
+type ResourcePatch struct {
+	// Target selects the patched resources
+	Target ResourceSelector `json:"target"`
+	// Type is strategic, merge or json. Defaults to strategic, the custom resources are merged
+	// with a JSON merge patch.
+	// +kubebuilder:validation:Enum=strategic;merge;json
+	Type PatchStrategyEnum `json:"type,omitempty"`
+	// Patch in YAML or JSON, a partial resource for the strategic and merge patches or a list of
+	// operations for the json patches
+	Patch string `json:"patch"`
+}
+
+// ResourceWave sets the sync wave of the resources of a kind
+type ResourceWave struct {
+	// APIVersion of the resources, matches all versions if empty
+	APIVersion string `json:"apiVersion,omitempty"`
+	// Kind of the resources
+	Kind string `json:"kind"`
+	// Wave the resources are applied in. The resources of a wave are applied once those of the
+	// lower waves are ready.
+	Wave int `json:"wave"`
+}
+
+// KubeConfig references the kubeconfig of a remote cluster
+type KubeConfig struct {
+	// SecretRef is the Secret of the namespace of the HelmRelease holding the kubeconfig
+	SecretRef corev1.LocalObjectReference `json:"secretRef"`
+	// Key of the kubeconfig in the Secret, defaults to value
+	Key string `json:"key,omitempty"`
+}
+
+// ClusterValuesOverride merges extra values into the values of the chart for the
+// ManagedClusters matching its selector
+type ClusterValuesOverride struct {
+	// ClusterSelector selects the ManagedClusters by their labels, e.g. region=eu
+	ClusterSelector metav1.LabelSelector `json:"clusterSelector"`
+	// Values are merged into the values of the chart, overriding them
+	// +kubebuilder:pruning:PreserveUnknownFields
+	Values runtime.RawExtension `json:"values,omitempty"`
+}
+
+// Preconditions are the capabilities a cluster needs for a release to be installed
+type Preconditions struct {
+	// KubeVersion is a semver constraint on the Kubernetes version of the cluster, e.g. >= 1.18
+	KubeVersion string `json:"kubeVersion,omitempty"`
+	// CRDs are the names of the CustomResourceDefinitions that must be installed, e.g.
+	// certificates.cert-manager.io
+	CRDs []string `json:"crds,omitempty"`
+	// APIGroups are the API groups, or group versions, that must be served, e.g.
+	// monitoring.coreos.com or networking.k8s.io/v1
+	APIGroups []string `json:"apiGroups,omitempty"`
+}
+
+// RolloutStrategy decides the order the selected ManagedClusters are upgraded in
+type RolloutStrategy struct {
+	// Canary upgrades a subset of the clusters first and verifies them before the others
+	Canary *CanaryRollout `json:"canary,omitempty"`
+	// Waves upgrade the clusters in groups, e.g. dev, stage then prod, each wave once the previous
+	// one is available. The clusters of no wave are upgraded last.
+	Waves []RolloutWave `json:"waves,omitempty"`
+}
+
+// CanaryRollout upgrades the canary clusters first. The other clusters are upgraded once all
+// the canary clusters have been available for the soak duration, the rollout halts if one of
+// them fails.
+type CanaryRollout struct {
+	// ClusterSelector selects the canary clusters among the selected ManagedClusters, by their labels
+	ClusterSelector metav1.LabelSelector `json:"clusterSelector"`
+	// SoakDuration is how long the canary clusters must stay available. Defaults to 10m.
+	SoakDuration *metav1.Duration `json:"soakDuration,omitempty"`
+}
+
+// RolloutWave is a group of clusters upgraded together. A wave requiring an approval is only
+// upgraded once the ApproveWaveAnnotation names it for the revision being rolled out.
+type RolloutWave struct {
+	// Name of the wave, e.g. prod
+	Name string `json:"name"`
+	// ClusterSelector selects the clusters of the wave among the selected ManagedClusters not in
+	// a previous wave, by their labels
+	ClusterSelector metav1.LabelSelector `json:"clusterSelector"`
+	// MaxUnavailable is the number, or percentage, of clusters of the wave being upgraded and not
+	// available at once. All the clusters of the wave are upgraded at once if unset.
+	MaxUnavailable *intstr.IntOrString `json:"maxUnavailable,omitempty"`
+	// RequireApproval waits for the approval of the wave before upgrading it
+	RequireApproval bool `json:"requireApproval,omitempty"`
+}
+
+// RolloutPhaseEnum is the phase of the rollout of a release across the clusters
+type RolloutPhaseEnum string
+
+const (
+	// CanaryRolloutPhase waits for the canary clusters to be available
+	CanaryRolloutPhase RolloutPhaseEnum = "Canary"
+	// SoakingRolloutPhase waits for the soak duration of the available canary clusters
+	SoakingRolloutPhase RolloutPhaseEnum = "Soaking"
+	// CompletedRolloutPhase upgraded all the clusters
+	CompletedRolloutPhase RolloutPhaseEnum = "Completed"
+	// HaltedRolloutPhase stopped after the failure of a canary cluster, until the spec changes
+	HaltedRolloutPhase RolloutPhaseEnum = "Halted"
+	// RollingOutRolloutPhase waits for the clusters of a wave to be upgraded and available
+	RollingOutRolloutPhase RolloutPhaseEnum = "RollingOut"
+	// PendingApprovalRolloutPhase waits for the approval of the next wave
+	PendingApprovalRolloutPhase RolloutPhaseEnum = "PendingApproval"
+)
+
+// NamespaceMetadata is the metadata set on the target namespace of a release
+type NamespaceMetadata struct {
+	Labels      map[string]string `json:"labels,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// DependencyReference references a HelmRelease
+type DependencyReference struct {
+	// Namespace of the HelmRelease, defaults to the namespace of the dependent HelmRelease
+	Namespace string `json:"namespace,omitempty"`
+	// Name of the HelmRelease
+	Name string `json:"name"`
+}
+
+// UpgradeWindow is a weekly maintenance window the upgrades are run in
+type UpgradeWindow struct {
+	// Days of the week the window opens, e.g. Sat and Sun. The window opens every day if empty.
+	Days []string `json:"days,omitempty"`
+	// Start is the time of day the window opens, e.g. 02:00
+	Start string `json:"start"`
+	// Duration of the window, e.g. 4h
+	Duration metav1.Duration `json:"duration"`
+	// TimeZone of Start, e.g. Europe/Paris. Defaults to UTC.
+	TimeZone string `json:"timeZone,omitempty"`
+}
+
+// ImageMirror pulls the images of a registry from a mirror
+type ImageMirror struct {
+	// Registry of the images, e.g. docker.io
+	Registry string `json:"registry"`
+	// Mirror is the registry the images are pulled from instead, with an optional path prefix,
+	// e.g. mirror.example.com/dockerhub
+	Mirror string `json:"mirror"`
+}
+
+// PodScheduling is injected into the pod specs of the rendered workloads
+type PodScheduling struct {
+	// NodeSelector labels are added to the nodeSelector of the pods, replacing the values of
+	// the same labels
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+	// Tolerations are appended to the tolerations of the pods, except those they already have
+	Tolerations []corev1.Toleration `json:"tolerations,omitempty"`
+	// Affinity replaces the nodeAffinity, podAffinity and podAntiAffinity of the pods that it sets,
+	// the others are kept
+	// +kubebuilder:pruning:PreserveUnknownFields
+	Affinity *corev1.Affinity `json:"affinity,omitempty"`
+}
+
+// TopologySpread spreads the pods of the rendered Deployments and StatefulSets across the
+// topology domains of the nodes
+type TopologySpread struct {
+	// TopologyKeys are the node labels of the domains, e.g. topology.kubernetes.io/zone and
+	// kubernetes.io/hostname. A constraint is added for each of them.
+	TopologyKeys []string `json:"topologyKeys"`
+	// MaxSkew is the largest difference of the number of pods between two domains. Defaults to 1.
+	// +kubebuilder:validation:Minimum=1
+	MaxSkew int32 `json:"maxSkew,omitempty"`
+	// WhenUnsatisfiable is DoNotSchedule or ScheduleAnyway. Defaults to ScheduleAnyway.
+	// +kubebuilder:validation:Enum=DoNotSchedule;ScheduleAnyway
+	WhenUnsatisfiable corev1.UnsatisfiableConstraintAction `json:"whenUnsatisfiable,omitempty"`
+}
+
+// SecurityContextDefaults are set on the pod specs and containers of the rendered workloads for
+// the fields they leave unset
+type SecurityContextDefaults struct {
+	// Pod defaults the securityContext of the pod specs
+	// +kubebuilder:pruning:PreserveUnknownFields
+	Pod *corev1.PodSecurityContext `json:"pod,omitempty"`
+	// Container defaults the securityContext of the containers and init containers. The fields
+	// the pod securityContext sets are not defaulted.
+	// +kubebuilder:pruning:PreserveUnknownFields
+	Container *corev1.SecurityContext `json:"container,omitempty"`
+}
+
+// UmbrellaChart is one of the charts of an umbrella HelmRelease, deployed as its own
+// sub-release
+type UmbrellaChart struct {
+	// Name of the chart in the umbrella HelmRelease, the HelmRelease of the chart is named
+	// <helmrelease>-<name>
+	Name string `json:"name"`
+	// Source holds the url toward the helm-chart, defaults to the source of the umbrella
+	// HelmRelease
+	Source *Source `json:"source,omitempty"`
+	// ChartName is the name of the chart within the repo
+	ChartName string `json:"chartName,omitempty"`
+	// Version is the chart version
+	Version string `json:"version,omitempty"`
+	// TargetNamespace is the namespace the chart is deployed to, defaults to the target
+	// namespace of the umbrella HelmRelease
+	TargetNamespace string `json:"targetNamespace,omitempty"`
+	// Values are merged into the shared values of the umbrella HelmRelease, overriding them
+	// +kubebuilder:pruning:PreserveUnknownFields
+	Values runtime.RawExtension `json:"values,omitempty"`
+}
+
 // HelmReleaseRepo defines the repository of HelmRelease
 // +k8s:openapi-gen=true
 type HelmReleaseRepo struct {
@@ -97,12 +449,233 @@ type HelmReleaseRepo struct {
 	ChartName string `json:"chartName,omitempty"`
 	// Version is the chart version
 	Version string `json:"version,omitempty"`
+	// Charts deploys several charts instead of the chart of the repo, as the sub-releases of
+	// an umbrella HelmRelease. Each chart is deployed by a HelmRelease named
+	// <helmrelease>-<name> owned by this one, with its repo settings and with the values of the
+	// chart merged into its shared values. The charts are installed and upgraded in their order,
+	// each one once the previous one is ready, and uninstalled in the reverse order.
+	Charts []UmbrellaChart `json:"charts,omitempty"`
 	// Secret to use to access the helm-repo defined in the CatalogSource.
 	SecretRef *corev1.ObjectReference `json:"secretRef,omitempty"`
 	// Configuration parameters to access the helm-repo defined in the CatalogSource
 	ConfigMapRef *corev1.ObjectReference `json:"configMapRef,omitempty"`
 	// InsecureSkipVerify is used to skip repo server's TLS certificate verification
 	InsecureSkipVerify bool `json:"insecureSkipVerify,omitempty"`
+	// ServerSideApply applies the rendered resources using server-side apply instead of
+	// three-way merge patches. The target cluster must have server-side apply enabled.
+	ServerSideApply bool `json:"serverSideApply,omitempty"`
+	// ConflictPolicy decides how fields owned by other field managers, e.g. replicas set by
+	// an autoscaler, are handled by server-side apply: fail reports the conflicting managers
+	// and force takes the ownership of the fields. Defaults to fail.
+	ConflictPolicy ConflictPolicyEnum `json:"conflictPolicy,omitempty"`
+	// PatchStrategies overrides the patch type used to update the resources of the given kinds.
+	// Cannot be set with ServerSideApply.
+	PatchStrategies []PatchStrategy `json:"patchStrategies,omitempty"`
+	// FieldManager is the field manager recorded in managedFields for the resources applied
+	// or patched by the operator. Defaults to multicluster-operators-subscription-release.
+	FieldManager string `json:"fieldManager,omitempty"`
+	// IgnoreDifferences lists fields excluded when deciding if the deployed release needs an upgrade,
+	// e.g. replicas managed by an autoscaler or fields set by mutating webhooks.
+	IgnoreDifferences []ResourceIgnoreDifferences `json:"ignoreDifferences,omitempty"`
+	// Prune deletes the resources no longer rendered by the chart on upgrade. Defaults to true,
+	// set it to false to leave the removed resources orphaned in the cluster.
+	Prune *bool `json:"prune,omitempty"`
+	// KubeConfig deploys the release to the remote cluster of the kubeconfig, the release
+	// records are stored there too. The release is deployed to the cluster of the operator if
+	// empty.
+	KubeConfig *KubeConfig `json:"kubeConfig,omitempty"`
+	// TargetNamespace is the namespace the chart is deployed to, defaults to the namespace of the
+	// HelmRelease. The target namespace must allow the HelmReleases of this namespace with its
+	// apps.open-cluster-management.io/allowed-helmrelease-namespaces annotation. It cannot be
+	// changed once the release is installed. When deployed to ManagedClusters, it can be a
+	// template resolved for each cluster, e.g. ingress-{{ .clusterName }}.
+	TargetNamespace string `json:"targetNamespace,omitempty"`
+	// ReleaseName is the name of the helm release instead of the one derived from the name of
+	// the HelmRelease, e.g. to adopt a release installed with the helm CLI or for a readable
+	// name. It must be a DNS subdomain of at most 53 characters and cannot be changed once the
+	// release is installed. It is the default clusterReleaseName on the ManagedClusters.
+	ReleaseName string `json:"releaseName,omitempty"`
+	// OverrideNamespace sets the namespace of the rendered resources that set another one to
+	// the target namespace, for the charts hardcoding namespaces in their templates. The
+	// ServiceAccount subjects of the RoleBindings and ClusterRoleBindings follow the
+	// ServiceAccounts of the chart.
+	OverrideNamespace bool `json:"overrideNamespace,omitempty"`
+	// CreateNamespace creates the target namespace before the install if it does not exist.
+	// A namespace created for a HelmRelease of another namespace allows it.
+	CreateNamespace bool `json:"createNamespace,omitempty"`
+	// NamespaceMetadata labels and annotates the target namespace created with CreateNamespace,
+	// e.g. with pod security or sidecar injection labels, before each install and upgrade.
+	NamespaceMetadata *NamespaceMetadata `json:"namespaceMetadata,omitempty"`
+	// ServiceAccountName is the ServiceAccount of the namespace of the HelmRelease impersonated
+	// for every request of the release, so that its RBAC bounds what the release can do. The
+	// requests are sent with the operator's permissions if empty.
+	ServiceAccountName string `json:"serviceAccountName,omitempty"`
+	// ImagePullSecrets are appended to the pod specs of the rendered workloads, e.g. for the
+	// charts with no value for them. The Secrets must exist in the namespaces of the workloads.
+	ImagePullSecrets []corev1.LocalObjectReference `json:"imagePullSecrets,omitempty"`
+	// ImageMirrors replace the registries of the images of the rendered workloads with their
+	// mirrors, e.g. for the charts with no value for every image.
+	ImageMirrors []ImageMirror `json:"imageMirrors,omitempty"`
+	// ImagePullPolicy overrides the imagePullPolicy of the containers of the rendered workloads,
+	// e.g. IfNotPresent on air-gapped clusters. The policies of the chart are kept if empty.
+	// +kubebuilder:validation:Enum=Always;IfNotPresent;Never
+	ImagePullPolicy corev1.PullPolicy `json:"imagePullPolicy,omitempty"`
+	// Scheduling injects a nodeSelector, tolerations and an affinity into the pod specs of the
+	// rendered workloads, e.g. to run them on the infra nodes for the charts with no value for them.
+	Scheduling *PodScheduling `json:"scheduling,omitempty"`
+	// TopologySpread adds topology spread constraints to the rendered Deployments and
+	// StatefulSets that have none, e.g. to spread the replicas of the charts ignoring topology
+	// across the zones.
+	TopologySpread *TopologySpread `json:"topologySpread,omitempty"`
+	// DefaultResources are set on the containers of the rendered workloads for the requests and
+	// limits the chart leaves unset. The resources set by the chart are never overridden.
+	DefaultResources *corev1.ResourceRequirements `json:"defaultResources,omitempty"`
+	// SecurityContext defaults the securityContext of the pod specs and containers of the
+	// rendered workloads, e.g. runAsNonRoot, dropped capabilities and a seccomp profile for the
+	// restricted pod security namespaces. The fields set by the chart are never overridden.
+	SecurityContext *SecurityContextDefaults `json:"securityContext,omitempty"`
+	// Env variables are appended to the containers and init containers of the rendered
+	// workloads, e.g. for the proxies and telemetry endpoints the chart has no value for. The
+	// variables a container already defines are kept.
+	Env []corev1.EnvVar `json:"env,omitempty"`
+	// EnvFrom sources are appended to the containers and init containers of the rendered
+	// workloads, e.g. a ConfigMap holding the proxy settings
+	EnvFrom []corev1.EnvFromSource `json:"envFrom,omitempty"`
+	// PriorityClassName is set on the pod specs of the rendered workloads that have no priority
+	// class, e.g. so that the platform workloads are evicted last under node pressure.
+	PriorityClassName string `json:"priorityClassName,omitempty"`
+	// CommonLabels are added to the metadata of the rendered resources and of the pod templates of
+	// the rendered workloads, e.g. for cost attribution. The labels set by the chart are kept.
+	CommonLabels map[string]string `json:"commonLabels,omitempty"`
+	// CommonAnnotations are added to the metadata of the rendered resources and of the pod templates
+	// of the rendered workloads. The annotations set by the chart are kept.
+	CommonAnnotations map[string]string `json:"commonAnnotations,omitempty"`
+	// SkipResources drops the matching rendered resources before they are applied, e.g. a
+	// PrometheusRule of the chart managed separately. The rendered resources annotated
+	// apps.open-cluster-management.io/skip: "true" are dropped too.
+	SkipResources []ResourceSelector `json:"skipResources,omitempty"`
+	// IncludeKinds only keeps the rendered resources of these kinds, every kind is kept if empty
+	IncludeKinds []ResourceKind `json:"includeKinds,omitempty"`
+	// ExcludeKinds drops the rendered resources of these kinds, e.g. the Ingress of the chart
+	// replaced by another one
+	ExcludeKinds []ResourceKind `json:"excludeKinds,omitempty"`
+	// Patches are applied to the matching rendered resources, once every other setting is
+	// injected, e.g. to add an environment variable to a Deployment of the chart.
+	Patches []ResourcePatch `json:"patches,omitempty"`
+	// AllowClusterScopedResources allows the chart to deploy cluster-scoped resources, e.g.
+	// ClusterRoles, CRDs or webhook configurations. Defaults to the operator setting, true
+	// unless set otherwise.
+	AllowClusterScopedResources *bool `json:"allowClusterScopedResources,omitempty"`
+	// StorageNamespace is the namespace holding the helm release records, e.g. a central
+	// helm-storage namespace. Defaults to the namespace of the HelmRelease.
+	StorageNamespace string `json:"storageNamespace,omitempty"`
+	// HistoryCompaction deletes the release revisions that are not worth keeping.
+	// The release history is not compacted if empty.
+	HistoryCompaction *HistoryCompaction `json:"historyCompaction,omitempty"`
+	// DriftRemediation re-applies the deployed resources whose live state no longer matches
+	// the deployed release. Drift is only reported in the Drifted condition if false.
+	DriftRemediation bool `json:"driftRemediation,omitempty"`
+	// MaxHistory is the number of release revisions kept, including the deployed one. Failed and
+	// pending revisions are always deleted. Defaults to 10, 0 keeps every superseded revision.
+	MaxHistory *int `json:"maxHistory,omitempty"`
+	// SnapshotUpgrades creates a HelmReleaseSnapshot of each installed and upgraded revision,
+	// owned by the HelmRelease, so that the release can be restored to it. The HelmReleaseSnapshot
+	// CRD must be installed.
+	SnapshotUpgrades bool `json:"snapshotUpgrades,omitempty"`
+	// Wait waits for the resources of the release to be ready before marking an install or
+	// an upgrade successful.
+	Wait bool `json:"wait,omitempty"`
+	// Timeout to wait for the resources to be ready and for the hooks to complete. Defaults
+	// to 5m when Wait is set, hooks are waited for without a timeout otherwise.
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+	// ResourceTimeouts overrides the timeout of the resources of the given kinds, e.g. 30m for
+	// StatefulSets, so that a single slow workload does not need a long timeout for all.
+	ResourceTimeouts []ResourceTimeout `json:"resourceTimeouts,omitempty"`
+	// WaitExclusions lists the kinds whose readiness is not waited for, e.g. custom resources
+	// without a status the operator could assess.
+	WaitExclusions []ResourceKind `json:"waitExclusions,omitempty"`
+	// SyncWaves sets the sync wave of the resources of the given kinds, e.g. to apply an operator
+	// before its custom resources. The resources are applied in wave order, each wave once the
+	// previous ones are ready. The apps.open-cluster-management.io/sync-wave annotation of a
+	// resource overrides the wave of its kind, the other resources are in wave 0.
+	SyncWaves []ResourceWave `json:"syncWaves,omitempty"`
+	// Interval is how often a deployed release is re-reconciled, e.g. to check it for drift
+	// and orphaned resources. Defaults to 10m.
+	Interval *metav1.Duration `json:"interval,omitempty"`
+	// Suspend pauses the reconciliation: nothing is installed, upgraded or uninstalled, e.g.
+	// during an incident or a maintenance, and the HelmRelease keeps its last reported status.
+	// A deleted HelmRelease is only uninstalled once resumed.
+	Suspend bool `json:"suspend,omitempty"`
+	// MaxFailures is the number of consecutive failed reconciles after which the HelmRelease is
+	// Stalled and no longer retried, until its spec changes or a reconcile is requested with the
+	// apps.open-cluster-management.io/reconcile-at annotation. Retried forever if 0.
+	MaxFailures int `json:"maxFailures,omitempty"`
+	// DeletionPolicy decides what happens to the release when the HelmRelease is deleted: Delete
+	// uninstalls it and Orphan only deletes its release records, leaving its resources running,
+	// e.g. when their ownership moves to another tool. Defaults to Delete.
+	DeletionPolicy DeletionPolicyEnum `json:"deletionPolicy,omitempty"`
+	// DependsOn lists the HelmReleases that must be deployed before this release is installed
+	// or upgraded, e.g. cert-manager before an ingress controller.
+	DependsOn []DependencyReference `json:"dependsOn,omitempty"`
+	// UpgradeWindows are the maintenance windows the upgrades triggered by a change of the chart
+	// or of the values are run in, they are pending until the next window otherwise. Installs and
+	// uninstalls are not delayed. Upgrades run at any time if empty.
+	UpgradeWindows []UpgradeWindow `json:"upgradeWindows,omitempty"`
+	// UpgradeAfter delays the upgrades triggered by a change of the chart or of the values until
+	// this time, e.g. to roll out a version bump committed now at night. The upgrade then waits
+	// for the next upgrade window, if any.
+	UpgradeAfter *metav1.Time `json:"upgradeAfter,omitempty"`
+	// Priority orders the reconciles of the HelmReleases when the operator starts, so that the
+	// critical platform releases, e.g. DNS or cert-manager, are reconciled before the application
+	// releases. The high priority failing HelmReleases are retried more often. Defaults to normal.
+	Priority PriorityEnum `json:"priority,omitempty"`
+	// PendingReleasePolicy decides how a release left pending-install, pending-upgrade or
+	// pending-rollback, e.g. by an operator killed during an upgrade, is recovered: Retry deletes
+	// the pending revision and runs the action again, Rollback rolls it back to the deployed
+	// revision, or uninstalls it if there is none, and Fail leaves it as is until a reconcile is
+	// requested with the apps.open-cluster-management.io/reconcile-at annotation. Defaults to Retry.
+	PendingReleasePolicy PendingReleasePolicyEnum `json:"pendingReleasePolicy,omitempty"`
+	// DeprecatedAPIPolicy decides what the rendered resources using Kubernetes API versions
+	// deprecated or removed in the version of the cluster, or in the next minor version, do before
+	// each install and upgrade: Warn reports them, Block also keeps the release from being
+	// deployed while it uses removed API versions, and Ignore skips the scan. Defaults to Warn.
+	// +kubebuilder:validation:Enum=Warn;Block;Ignore
+	DeprecatedAPIPolicy DeprecatedAPIPolicyEnum `json:"deprecatedAPIPolicy,omitempty"`
+	// PublishChartMetadata publishes the metadata, the README and the default values of the
+	// fetched chart to the <name>-chart-metadata ConfigMap, so that the consoles can show what is
+	// deployed. The status always lists the metadata of the chart.
+	PublishChartMetadata bool `json:"publishChartMetadata,omitempty"`
+	// ClusterSelector selects the ManagedClusters the chart is deployed to when the operator runs
+	// on an Open Cluster Management hub: the rendered resources are sent to each cluster in a
+	// ManifestWork instead of being installed locally. The release is installed locally if empty.
+	ClusterSelector *metav1.LabelSelector `json:"clusterSelector,omitempty"`
+	// PlacementRef references a PlacementRule of the namespace of the HelmRelease whose decisions
+	// are the ManagedClusters the chart is deployed to, following them as they change. Combined
+	// with ClusterSelector, only the decided clusters matching the selector are deployed to.
+	PlacementRef *corev1.LocalObjectReference `json:"placementRef,omitempty"`
+	// ClusterOverrides merge extra values into the values of the chart for the selected
+	// ManagedClusters matching their selectors, so that one HelmRelease serves a heterogeneous
+	// fleet. The matching overrides are merged in order, the last one wins.
+	ClusterOverrides []ClusterValuesOverride `json:"clusterOverrides,omitempty"`
+	// Rollout decides the order the selected ManagedClusters are upgraded in, e.g. canary
+	// clusters first. All the clusters are upgraded at once if empty.
+	Rollout *RolloutStrategy `json:"rollout,omitempty"`
+	// Preconditions are checked against the cluster the release is deployed to before each
+	// install and upgrade, so that a release needing missing capabilities is not half installed.
+	Preconditions *Preconditions `json:"preconditions,omitempty"`
+	// ClusterReleaseName is the name of the release rendered for each selected ManagedCluster, a
+	// template resolved from the name, the labels and the cluster claims of the cluster, e.g.
+	// ingress-{{ .clusterName }}. Defaults to the name of the HelmRelease.
+	ClusterReleaseName string `json:"clusterReleaseName,omitempty"`
+	// TLSSecretRef is a kubernetes.io/tls Secret, e.g. issued by cert-manager, holding the client
+	// certificate presented to the helm repo in tls.crt and tls.key, and the CA of the repo server
+	// in ca.crt. It is read at each download, so that rotated certificates are picked up.
+	TLSSecretRef *corev1.ObjectReference `json:"tlsSecretRef,omitempty"`
+}
+
+// PruneEnabled returns false only when pruning was disabled explicitly
+func (r *HelmReleaseRepo) PruneEnabled() bool {
+	return r.Prune == nil || *r.Prune
 }
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
@@ -111,6 +684,12 @@ type HelmReleaseRepo struct {
 // +k8s:openapi-gen=true
 // +kubebuilder:resource:scope=Namespaced
 // +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Chart",type=string,JSONPath=`.repo.chartName`,description="The name of the chart"
+// +kubebuilder:printcolumn:name="Version",type=string,JSONPath=`.status.deployedRelease.chartVersion`,description="The version of the chart of the deployed release"
+// +kubebuilder:printcolumn:name="Revision",type=integer,JSONPath=`.status.deployedRelease.revision`,description="The helm revision of the deployed release"
+// +kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`,description="Whether the release is deployed and its resources are ready"
+// +kubebuilder:printcolumn:name="Reason",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].reason`,priority=1,description="The reason of the Ready condition"
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 type HelmRelease struct {
 	metav1.TypeMeta   `json:",inline"`
 	metav1.ObjectMeta `json:"metadata,omitempty"`
@@ -149,35 +728,403 @@ type HelmAppCondition struct {
 	Message string                 `json:"message,omitempty"`
 
 	LastTransitionTime metav1.Time `json:"lastTransitionTime,omitempty"`
+	// ObservedGeneration is the generation of the HelmRelease the condition was set for
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
 }
 
 type HelmAppRelease struct {
 	Name     string `json:"name,omitempty"`
 	Manifest string `json:"manifest,omitempty"`
+	// Digest of the chart, values and settings the release was rendered from
+	Digest string `json:"digest,omitempty"`
+	// ChartVersion is the version of the chart of the release
+	ChartVersion string `json:"chartVersion,omitempty"`
+	// ChartDigest is the sha256 digest of the content of the chart of the release
+	ChartDigest string `json:"chartDigest,omitempty"`
+	// AppVersion is the version of the application deployed by the chart
+	AppVersion string `json:"appVersion,omitempty"`
+	// Revision is the helm revision of the release
+	Revision int `json:"revision,omitempty"`
+	// FirstDeployed is when the first revision of the release was deployed
+	FirstDeployed *metav1.Time `json:"firstDeployed,omitempty"`
+	// LastDeployed is when the revision of the release was deployed
+	LastDeployed *metav1.Time `json:"lastDeployed,omitempty"`
+	// ManifestDigest is the sha256 digest of the manifest of the release
+	ManifestDigest string `json:"manifestDigest,omitempty"`
+	// ValuesDigest is the sha256 digest of the merged values of the release
+	ValuesDigest string `json:"valuesDigest,omitempty"`
+}
+
+// HelmAppChartSource is the source url a chart was downloaded from
+type HelmAppChartSource struct {
+	// URL is the url of the source, without its credentials
+	URL string `json:"url,omitempty"`
+	// Failover is true if the first url of the source failed and an alternate url served the chart
+	Failover bool `json:"failover,omitempty"`
+	// LastFetched is when the chart was downloaded
+	LastFetched *metav1.Time `json:"lastFetched,omitempty"`
+}
+
+// HelmAppChartMetadata is the metadata of the chart last fetched for the release
+type HelmAppChartMetadata struct {
+	Name        string `json:"name,omitempty"`
+	Version     string `json:"version,omitempty"`
+	AppVersion  string `json:"appVersion,omitempty"`
+	Description string `json:"description,omitempty"`
+	Home        string `json:"home,omitempty"`
+	Icon        string `json:"icon,omitempty"`
+	// Deprecated is true if the chart is no longer maintained
+	Deprecated bool `json:"deprecated,omitempty"`
+	// Digest is the sha256 digest of the content of the chart
+	Digest string `json:"digest,omitempty"`
+	// ConfigMap is the name of the ConfigMap the metadata, the README and the default values of
+	// the chart are published to, if enabled
+	ConfigMap string `json:"configMap,omitempty"`
+}
+
+// HelmAppResource identifies a resource of the release
+type HelmAppResource struct {
+	APIVersion string `json:"apiVersion,omitempty"`
+	Kind       string `json:"kind,omitempty"`
+	Namespace  string `json:"namespace,omitempty"`
+	Name       string `json:"name,omitempty"`
+}
+
+func (r HelmAppResource) String() string {
+	if r.Namespace == "" {
+		return fmt.Sprintf("%s/%s %s", r.APIVersion, r.Kind, r.Name)
+	}
+
+	return fmt.Sprintf("%s/%s %s/%s", r.APIVersion, r.Kind, r.Namespace, r.Name)
+}
+
+// HelmAppFieldConflict lists the fields of a release resource owned by another
+// field manager with a different value than the release sets
+type HelmAppFieldConflict struct {
+	Resource HelmAppResource `json:"resource"`
+	Manager  string          `json:"manager"`
+	// Fields are paths like .spec.replicas or .spec.template.spec.containers[name=web].image
+	Fields []string `json:"fields"`
+}
+
+// AuditActionEnum is a helm action recorded in the audit trail of a release
+type AuditActionEnum string
+
+const (
+	// AuditInstall installs the release
+	AuditInstall AuditActionEnum = "Install"
+	// AuditUpgrade upgrades the release
+	AuditUpgrade AuditActionEnum = "Upgrade"
+	// AuditRollback rolls a failed upgrade back
+	AuditRollback AuditActionEnum = "Rollback"
+	// AuditUninstall uninstalls the release
+	AuditUninstall AuditActionEnum = "Uninstall"
+	// AuditOrphan deletes the release records, leaving the resources running
+	AuditOrphan AuditActionEnum = "Orphan"
+)
+
+// HelmAppAuditRecord records a helm action run on the release
+type HelmAppAuditRecord struct {
+	Time metav1.Time `json:"time"`
+	// Action is Install, Upgrade, Rollback, Uninstall or Orphan
+	// +kubebuilder:validation:Enum=Install;Upgrade;Rollback;Uninstall;Orphan
+	Action AuditActionEnum `json:"action"`
+	// Succeeded is false if the action failed, Message is then its error
+	Succeeded bool   `json:"succeeded"`
+	Message   string `json:"message,omitempty"`
+	// Revision is the release revision installed, upgraded to or uninstalled, 0 if the action failed
+	Revision     int    `json:"revision,omitempty"`
+	Chart        string `json:"chart,omitempty"`
+	ChartVersion string `json:"chartVersion,omitempty"`
+	// ValuesDigest is the sha256 of the merged values of the release
+	ValuesDigest string `json:"valuesDigest,omitempty"`
+	// Generation is the generation of the HelmRelease the action was run for
+	Generation int64 `json:"generation,omitempty"`
+	// Trigger is the change that triggered the action: SpecChanged, ReconcileRequested,
+	// ForceUpgrade, ReleaseMissing, Deleted, Resync or SnapshotRestore
+	Trigger string `json:"trigger,omitempty"`
+}
+
+// HelmAppPolicyViolation is a violation of a policy by a rendered resource of the release
+type HelmAppPolicyViolation struct {
+	Resource HelmAppResource `json:"resource"`
+	// Policy is the policy engine that reported the violation, e.g. opa
+	Policy  string `json:"policy,omitempty"`
+	Message string `json:"message"`
+}
+
+// HelmAppDeprecatedAPI is a rendered resource of the release using a Kubernetes API version
+// deprecated in the version of its cluster or in the next minor version
+type HelmAppDeprecatedAPI struct {
+	Resource HelmAppResource `json:"resource"`
+	// DeprecatedIn is the Kubernetes version deprecating the API version, e.g. 1.19
+	DeprecatedIn string `json:"deprecatedIn,omitempty"`
+	// RemovedIn is the Kubernetes version no longer serving the API version, e.g. 1.22
+	RemovedIn string `json:"removedIn,omitempty"`
+	// Replacement is the API version to use instead, if any, e.g. networking.k8s.io/v1
+	Replacement string `json:"replacement,omitempty"`
+	// Removed is true if the API version is not served by the version of the cluster or by the
+	// next minor version
+	Removed bool `json:"removed,omitempty"`
+}
+
+// ResourceStatusEnum is the computed status of a release resource
+type ResourceStatusEnum string
+
+const (
+	// ResourceCurrent the resource is fully reconciled and ready
+	ResourceCurrent ResourceStatusEnum = "Current"
+	// ResourceInProgress the resource is still being rolled out
+	ResourceInProgress ResourceStatusEnum = "InProgress"
+	// ResourceFailed the rollout of the resource failed
+	ResourceFailed ResourceStatusEnum = "Failed"
+	// ResourceNotFound the resource does not exist
+	ResourceNotFound ResourceStatusEnum = "NotFound"
+	// ResourceUnknown the status of the resource could not be computed
+	ResourceUnknown ResourceStatusEnum = "Unknown"
+)
+
+// HelmAppResourceStatus is the readiness of a resource of the deployed release
+type HelmAppResourceStatus struct {
+	Resource HelmAppResource    `json:"resource"`
+	Status   ResourceStatusEnum `json:"status"`
+	Message  string             `json:"message,omitempty"`
+}
+
+// HelmAppClusterStatus is the state of the ManifestWork of a selected ManagedCluster
+type HelmAppClusterStatus struct {
+	Cluster string `json:"cluster"`
+	// Applied is true once the work agent of the cluster applied the resources
+	Applied bool `json:"applied,omitempty"`
+	// Available is true once the applied resources exist on the cluster
+	Available bool `json:"available,omitempty"`
+	// Digest of the chart, values and settings of the release the ManifestWork carries
+	Digest string `json:"digest,omitempty"`
+	// OutOfDate is true while the ManifestWork does not carry the desired release, e.g. held back
+	// by the rollout
+	OutOfDate bool `json:"outOfDate,omitempty"`
+	// Conditions are the conditions of the ManifestWork reported by the work agent, with their
+	// failure messages
+	Conditions []HelmAppCondition `json:"conditions,omitempty"`
+}
+
+// HelmAppChartStatus is the state of the HelmRelease of a chart of an umbrella HelmRelease
+type HelmAppChartStatus struct {
+	Name string `json:"name"`
+	// HelmRelease is the name of the HelmRelease deploying the chart
+	HelmRelease string `json:"helmRelease"`
+	// Revision is the deployed revision of the release of the chart
+	Revision int `json:"revision,omitempty"`
+	// Ready is the status of the Ready condition of the HelmRelease of the chart, Unknown until
+	// it is reconciled for its current generation
+	Ready ConditionStatus `json:"ready"`
+	// Reason and Message explain why the chart is not ready
+	Reason  HelmAppConditionReason `json:"reason,omitempty"`
+	Message string                 `json:"message,omitempty"`
+}
+
+// HelmAppWaveApproval is the approval of a rollout wave
+type HelmAppWaveApproval struct {
+	Wave string `json:"wave"`
+	// ApprovedBy is the user who set the ApproveWaveAnnotation, as recorded by the admission webhook
+	ApprovedBy string `json:"approvedBy,omitempty"`
+	// ApprovedTime is when the approval was handled
+	ApprovedTime metav1.Time `json:"approvedTime,omitempty"`
+}
+
+// HelmAppRolloutStatus is the progress of the rollout of a release across the clusters
+type HelmAppRolloutStatus struct {
+	// Revision identifies the renders of the release being rolled out
+	Revision string           `json:"revision"`
+	Phase    RolloutPhaseEnum `json:"phase"`
+	// StartTime is when the rollout of the revision started
+	StartTime metav1.Time `json:"startTime,omitempty"`
+	// CanaryAvailableTime is when all the canary clusters were available with the revision
+	CanaryAvailableTime *metav1.Time `json:"canaryAvailableTime,omitempty"`
+	// Wave is the wave being upgraded or waiting for its approval, canary for the canary clusters
+	Wave string `json:"wave,omitempty"`
+	// CompletedWaves are the waves of the revision upgraded and available, canary once the canary
+	// clusters are verified
+	CompletedWaves []string `json:"completedWaves,omitempty"`
+	// Approvals record who approved the waves of the revision, and when
+	Approvals []HelmAppWaveApproval `json:"approvals,omitempty"`
+	// Message explains the phase, e.g. the failure of a canary cluster
+	Message string `json:"message,omitempty"`
 }
 
 const (
-	ConditionInitialized    HelmAppConditionType = "Initialized"
-	ConditionDeployed       HelmAppConditionType = "Deployed"
-	ConditionReleaseFailed  HelmAppConditionType = "ReleaseFailed"
-	ConditionIrreconcilable HelmAppConditionType = "Irreconcilable"
+	ConditionInitialized         HelmAppConditionType = "Initialized"
+	ConditionDeployed            HelmAppConditionType = "Deployed"
+	ConditionReleaseFailed       HelmAppConditionType = "ReleaseFailed"
+	ConditionIrreconcilable      HelmAppConditionType = "Irreconcilable"
+	ConditionDrifted             HelmAppConditionType = "Drifted"
+	ConditionSuspended           HelmAppConditionType = "Suspended"
+	ConditionDependencyNotReady  HelmAppConditionType = "DependencyNotReady"
+	ConditionResourcesReady      HelmAppConditionType = "ResourcesReady"
+	ConditionUpgradePending      HelmAppConditionType = "UpgradePending"
+	ConditionNameConflict        HelmAppConditionType = "NameConflict"
+	ConditionPreconditionsNotMet HelmAppConditionType = "PreconditionsNotMet"
+
+	// Ready, Released, TestSuccessful and Stalled follow the Kubernetes API
+	// conventions, e.g. for kubectl wait --for=condition=Ready
+	ConditionReady          HelmAppConditionType = "Ready"
+	ConditionReleased       HelmAppConditionType = "Released"
+	ConditionTestSuccessful HelmAppConditionType = "TestSuccessful"
+	ConditionStalled        HelmAppConditionType = "Stalled"
 
 	StatusTrue    ConditionStatus = "True"
 	StatusFalse   ConditionStatus = "False"
 	StatusUnknown ConditionStatus = "Unknown"
 
-	ReasonInstallSuccessful   HelmAppConditionReason = "InstallSuccessful"
-	ReasonUpgradeSuccessful   HelmAppConditionReason = "UpgradeSuccessful"
-	ReasonUninstallSuccessful HelmAppConditionReason = "UninstallSuccessful"
-	ReasonInstallError        HelmAppConditionReason = "InstallError"
-	ReasonUpgradeError        HelmAppConditionReason = "UpgradeError"
-	ReasonReconcileError      HelmAppConditionReason = "ReconcileError"
-	ReasonUninstallError      HelmAppConditionReason = "UninstallError"
+	ReasonInstallSuccessful        HelmAppConditionReason = "InstallSuccessful"
+	ReasonUpgradeSuccessful        HelmAppConditionReason = "UpgradeSuccessful"
+	ReasonUninstallSuccessful      HelmAppConditionReason = "UninstallSuccessful"
+	ReasonInstallError             HelmAppConditionReason = "InstallError"
+	ReasonUpgradeError             HelmAppConditionReason = "UpgradeError"
+	ReasonReconcileError           HelmAppConditionReason = "ReconcileError"
+	ReasonUninstallError           HelmAppConditionReason = "UninstallError"
+	ReasonDriftDetected            HelmAppConditionReason = "DriftDetected"
+	ReasonDriftRemediated          HelmAppConditionReason = "DriftRemediated"
+	ReasonHooksError               HelmAppConditionReason = "HooksError"
+	ReasonRollbackError            HelmAppConditionReason = "RollbackError"
+	ReasonApplyConflict            HelmAppConditionReason = "ApplyConflict"
+	ReasonSuspended                HelmAppConditionReason = "Suspended"
+	ReasonDependencyNotFound       HelmAppConditionReason = "DependencyNotFound"
+	ReasonDependencyNotReady       HelmAppConditionReason = "DependencyNotReady"
+	ReasonReconcileSuccessful      HelmAppConditionReason = "ReconcileSuccessful"
+	ReasonNotReleased              HelmAppConditionReason = "NotReleased"
+	ReasonResourcesCurrent         HelmAppConditionReason = "ResourcesCurrent"
+	ReasonResourcesInProgress      HelmAppConditionReason = "ResourcesInProgress"
+	ReasonResourcesFailed          HelmAppConditionReason = "ResourcesFailed"
+	ReasonTargetNamespaceForbidden HelmAppConditionReason = "TargetNamespaceForbidden"
+	ReasonPendingWindow            HelmAppConditionReason = "PendingWindow"
+	ReasonPendingSchedule          HelmAppConditionReason = "PendingSchedule"
+	ReasonRetriesExhausted         HelmAppConditionReason = "RetriesExhausted"
+	ReasonReleaseNameInUse         HelmAppConditionReason = "ReleaseNameInUse"
+	ReasonActionInterrupted        HelmAppConditionReason = "ActionInterrupted"
+	ReasonReleasePending           HelmAppConditionReason = "ReleasePending"
+	ReasonManifestWorksApplied     HelmAppConditionReason = "ManifestWorksApplied"
+	ReasonManifestWorkError        HelmAppConditionReason = "ManifestWorkError"
+	ReasonRolloutInProgress        HelmAppConditionReason = "RolloutInProgress"
+	ReasonCanaryFailed             HelmAppConditionReason = "CanaryFailed"
+	ReasonApprovalPending          HelmAppConditionReason = "ApprovalPending"
+	ReasonClusterCapabilityMissing HelmAppConditionReason = "ClusterCapabilityMissing"
+	ReasonNamespaceScopeViolation  HelmAppConditionReason = "NamespaceScopeViolation"
+	ReasonPolicyViolation          HelmAppConditionReason = "PolicyViolation"
+	ReasonUnverifiedImage          HelmAppConditionReason = "UnverifiedImage"
+	ReasonPodSecurityViolation     HelmAppConditionReason = "PodSecurityViolation"
+	ReasonChartSourceForbidden     HelmAppConditionReason = "ChartSourceForbidden"
+	ReasonClusterScopedResource    HelmAppConditionReason = "ClusterScopedResource"
+	ReasonPermissionsMissing       HelmAppConditionReason = "PermissionsMissing"
+	ReasonChartPullError           HelmAppConditionReason = "ChartPullError"
+	ReasonRenderError              HelmAppConditionReason = "RenderError"
+	ReasonTimeout                  HelmAppConditionReason = "Timeout"
+	ReasonRBACDenied               HelmAppConditionReason = "RBACDenied"
+	ReasonStorageError             HelmAppConditionReason = "StorageError"
+	ReasonChartsDeployed           HelmAppConditionReason = "ChartsDeployed"
+	ReasonChartNotReady            HelmAppConditionReason = "ChartNotReady"
+	ReasonHeldBack                 HelmAppConditionReason = "HeldBack"
+	ReasonDeprecatedAPI            HelmAppConditionReason = "DeprecatedAPI"
 )
 
+// SubscriptionPhaseEnum is the phase of a release in the status of its Subscription
+type SubscriptionPhaseEnum string
+
+const (
+	// SubscribedPhase is a release deployed and ready
+	SubscribedPhase SubscriptionPhaseEnum = "Subscribed"
+	// InProgressPhase is a release being deployed or waiting to be
+	InProgressPhase SubscriptionPhaseEnum = "InProgress"
+	// FailedPhase is a release that failed to be deployed
+	FailedPhase SubscriptionPhaseEnum = "Failed"
+)
+
+// HelmAppSubscriptionStatus is the state of a HelmRelease for the Subscription it was created by
+type HelmAppSubscriptionStatus struct {
+	// Subscription is the namespace/name of the Subscription
+	Subscription string `json:"subscription"`
+	// Phase is Failed if the release failed on one of the clusters, Subscribed once it is ready
+	// on all of them
+	Phase SubscriptionPhaseEnum `json:"phase"`
+	// Clusters is the state of the release on each cluster, with the failure of the clusters it
+	// failed on
+	Clusters []HelmAppSubscriptionUnitStatus `json:"clusters,omitempty"`
+}
+
+// HelmAppSubscriptionUnitStatus is the state of the release on a cluster
+type HelmAppSubscriptionUnitStatus struct {
+	// Cluster is the ManagedCluster, empty for the cluster the release is installed on
+	Cluster string                `json:"cluster,omitempty"`
+	Phase   SubscriptionPhaseEnum `json:"phase"`
+	// Reason and Message explain the phase, e.g. the failure of the release
+	Reason  HelmAppConditionReason `json:"reason,omitempty"`
+	Message string                 `json:"message,omitempty"`
+	// LastUpdateTime is when the phase last changed
+	LastUpdateTime metav1.Time `json:"lastUpdateTime,omitempty"`
+}
+
 type HelmAppStatus struct {
 	Conditions      []HelmAppCondition `json:"conditions"`
 	DeployedRelease *HelmAppRelease    `json:"deployedRelease,omitempty"`
+	// ReleaseName is the name of the helm release, the name of the HelmRelease shortened to 53
+	// characters with a hash suffix if it is longer
+	ReleaseName string `json:"releaseName,omitempty"`
+	// ChartSource is the source url that served the last successful chart download.
+	ChartSource *HelmAppChartSource `json:"chartSource,omitempty"`
+	// ChartMetadata is the metadata of the chart last fetched, the chart the next install or
+	// upgrade deploys.
+	ChartMetadata *HelmAppChartMetadata `json:"chartMetadata,omitempty"`
+	// PrunedResources lists the resources deleted by the last upgrade because
+	// the chart no longer renders them.
+	PrunedResources []HelmAppResource `json:"prunedResources,omitempty"`
+	// UpgradeSummary counts the resources changed, added and removed by the last
+	// upgrade by kind, e.g. "2 Deployments changed, 1 Secret added".
+	UpgradeSummary string `json:"upgradeSummary,omitempty"`
+	// OrphanedResources lists the resources labeled with the release that are
+	// no longer part of its deployed manifest. They are reported, not deleted.
+	OrphanedResources []HelmAppResource `json:"orphanedResources,omitempty"`
+	// FieldConflicts lists the fields of the deployed resources that other field
+	// managers, e.g. an autoscaler, set to a different value than the release.
+	FieldConflicts []HelmAppFieldConflict `json:"fieldConflicts,omitempty"`
+	// History is the audit trail of the last helm actions run on the release, the oldest first.
+	History []HelmAppAuditRecord `json:"history,omitempty"`
+	// PolicyViolations lists the violations of the policies by the rendered resources that
+	// blocked the last install or upgrade.
+	PolicyViolations []HelmAppPolicyViolation `json:"policyViolations,omitempty"`
+	// DeprecatedAPIs lists the rendered resources using deprecated Kubernetes API versions that
+	// blocked the last install or upgrade, or that it was deployed with.
+	DeprecatedAPIs []HelmAppDeprecatedAPI `json:"deprecatedAPIs,omitempty"`
+	// Resources is the readiness of each resource of the deployed release.
+	Resources []HelmAppResourceStatus `json:"resources,omitempty"`
+	// Progress summarizes the readiness of the resources an install or upgrade
+	// is waiting for, e.g. "Deployment 3/5 ready". It is empty once done.
+	Progress string `json:"progress,omitempty"`
+	// Failures is the number of consecutive failed reconciles, reset on success.
+	Failures int `json:"failures,omitempty"`
+	// NextRetryTime is when a failed reconcile is retried. The delay between the
+	// retries doubles with each consecutive failure.
+	NextRetryTime *metav1.Time `json:"nextRetryTime,omitempty"`
+	// ObservedGeneration is the last generation of the HelmRelease that was fully reconciled.
+	// The status does not reflect the latest spec yet while it is lower than the generation.
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// LastHandledReconcileAt is the value of the apps.open-cluster-management.io/reconcile-at
+	// annotation handled by the last full reconcile.
+	LastHandledReconcileAt string `json:"lastHandledReconcileAt,omitempty"`
+	// ClusterID identifies the cluster the release is deployed to, the UID of its kube-system
+	// namespace. The release is installed again if the cluster is replaced.
+	ClusterID string `json:"clusterID,omitempty"`
+	// Clusters is the state of the ManifestWork of each ManagedCluster selected by the
+	// clusterSelector.
+	Clusters []HelmAppClusterStatus `json:"clusters,omitempty"`
+	// Charts is the state of the HelmRelease of each chart of an umbrella HelmRelease, in the
+	// order of the charts.
+	Charts []HelmAppChartStatus `json:"charts,omitempty"`
+	// Rollout is the progress of the rollout of the release across the clusters, with a
+	// rollout strategy.
+	Rollout *HelmAppRolloutStatus `json:"rollout,omitempty"`
+	// Subscription rolls the conditions up into the per-cluster form of the status of the
+	// Subscription the HelmRelease was created by, if any.
+	Subscription *HelmAppSubscriptionStatus `json:"subscription,omitempty"`
 }
 
 func (s *HelmAppStatus) ToMap() (map[string]interface{}, error) {
@@ -220,6 +1167,18 @@ func (s *HelmAppStatus) SetCondition(condition HelmAppCondition) *HelmAppStatus
 	return s
 }
 
+// GetCondition returns the condition with the passed condition type, or nil
+// if the status object does not have it.
+func (s *HelmAppStatus) GetCondition(conditionType HelmAppConditionType) *HelmAppCondition {
+	for i := range s.Conditions {
+		if s.Conditions[i].Type == conditionType {
+			return &s.Conditions[i]
+		}
+	}
+
+	return nil
+}
+
 // RemoveCondition removes the condition with the passed condition type from
 // the status object. If the condition is not already present, the returned
 // status object is returned unchanged. RemoveCondition does not update the
@@ -236,6 +1195,21 @@ func (s *HelmAppStatus) RemoveCondition(conditionType HelmAppConditionType) *Hel
 	return s
 }
 
+// RepoFor safely returns a typed repo block from a custom resource.
+func RepoFor(cr *unstructured.Unstructured) *HelmReleaseRepo {
+	s, ok := cr.Object["repo"].(map[string]interface{})
+	if !ok {
+		return &HelmReleaseRepo{}
+	}
+
+	var repo *HelmReleaseRepo
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(s, &repo); err != nil {
+		return &HelmReleaseRepo{}
+	}
+
+	return repo
+}
+
 // StatusFor safely returns a typed status block from a custom resource.
 func StatusFor(cr *unstructured.Unstructured) *HelmAppStatus {
 	switch s := cr.Object["status"].(type) {
@@ -0,0 +1,669 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"text/template"
+
+	"github.com/Masterminds/semver/v3"
+	jsonpatch "github.com/evanphx/json-patch"
+	"github.com/ghodss/yaml"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	apivalidation "k8s.io/apimachinery/pkg/api/validation"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	metav1validation "k8s.io/apimachinery/pkg/apis/meta/v1/validation"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// +kubebuilder:webhook:path=/mutate-apps-open-cluster-management-io-v1-helmrelease,mutating=true,failurePolicy=fail,groups=apps.open-cluster-management.io,resources=helmreleases,verbs=create;update,versions=v1,name=mhelmrelease.apps.open-cluster-management.io
+
+var _ admission.Defaulter = &HelmRelease{}
+
+// Default sets the defaults of the repo settings explicitly so that the
+// stored HelmReleases show the settings they are reconciled with.
+func (r *HelmRelease) Default() {
+	repo := &r.Repo
+
+	if repo.Source != nil {
+		repo.Source.SourceType = SourceTypeEnum(strings.ToLower(string(repo.Source.SourceType)))
+	}
+
+	if repo.MaxHistory == nil {
+		maxHistory := DefaultMaxHistory
+		repo.MaxHistory = &maxHistory
+	}
+
+	if repo.Interval == nil {
+		repo.Interval = &metav1.Duration{Duration: DefaultInterval}
+	}
+
+	if repo.Wait && repo.Timeout == nil {
+		repo.Timeout = &metav1.Duration{Duration: DefaultWaitTimeout}
+	}
+
+	if repo.Prune == nil {
+		prune := true
+		repo.Prune = &prune
+	}
+
+	if repo.FieldManager == "" {
+		repo.FieldManager = DefaultFieldManager
+	}
+
+	if repo.ServerSideApply && repo.ConflictPolicy == "" {
+		repo.ConflictPolicy = FailConflictPolicy
+	}
+
+	if repo.DeletionPolicy == "" {
+		repo.DeletionPolicy = DeleteDeletionPolicy
+	}
+
+	if repo.Priority == "" {
+		repo.Priority = NormalPriority
+	}
+
+	if repo.PendingReleasePolicy == "" {
+		repo.PendingReleasePolicy = RetryPendingReleasePolicy
+	}
+
+	if repo.DeprecatedAPIPolicy == "" {
+		repo.DeprecatedAPIPolicy = WarnDeprecatedAPIPolicy
+	}
+
+	if repo.Rollout != nil && repo.Rollout.Canary != nil && repo.Rollout.Canary.SoakDuration == nil {
+		repo.Rollout.Canary.SoakDuration = &metav1.Duration{Duration: DefaultCanarySoak}
+	}
+
+	if spread := repo.TopologySpread; spread != nil {
+		if spread.MaxSkew == 0 {
+			spread.MaxSkew = 1
+		}
+
+		if spread.WhenUnsatisfiable == "" {
+			spread.WhenUnsatisfiable = corev1.ScheduleAnyway
+		}
+	}
+}
+
+// +kubebuilder:webhook:path=/validate-apps-open-cluster-management-io-v1-helmrelease,mutating=false,failurePolicy=fail,groups=apps.open-cluster-management.io,resources=helmreleases,verbs=create;update,versions=v1,name=vhelmrelease.apps.open-cluster-management.io
+
+var _ admission.Validator = &HelmRelease{}
+
+// ValidateCreate rejects the HelmReleases whose repo is invalid, e.g. with an
+// unknown source type, so that the error is reported on creation instead of
+// by a failed reconcile.
+func (r *HelmRelease) ValidateCreate() error {
+	return r.validate(nil)
+}
+
+// ValidateUpdate rejects the invalid repos and the changes of the fields
+// that cannot change once the release is installed.
+func (r *HelmRelease) ValidateUpdate(old runtime.Object) error {
+	oldHR, _ := old.(*HelmRelease)
+	return r.validate(oldHR)
+}
+
+// ValidateDelete accepts all the deletes.
+func (r *HelmRelease) ValidateDelete() error {
+	return nil
+}
+
+// patchedWithServerSideApply returns true if repo sets patch strategies and
+// server-side apply.
+func patchedWithServerSideApply(repo *HelmReleaseRepo) bool {
+	return repo.ServerSideApply && len(repo.PatchStrategies) != 0
+}
+
+func (r *HelmRelease) validate(old *HelmRelease) error {
+	repo := field.NewPath("repo")
+
+	var errs field.ErrorList
+
+	// the charts of an umbrella HelmRelease may all set their own source
+	if r.Repo.Source != nil || !r.chartsSetSources() {
+		errs = append(errs, validateSource(r.Repo.Source, repo.Child("source"))...)
+	}
+
+	// the HelmReleases whose source is no longer allowed can still be updated,
+	// e.g. to remove their finalizer, they are not reconciled
+	if old == nil || !reflect.DeepEqual(old.Repo.Source, r.Repo.Source) {
+		if err := CheckChartSource(r.GetNamespace(), r.Repo.Source); err != nil {
+			errs = append(errs, field.Forbidden(repo.Child("source"), err.Error()))
+		}
+	}
+
+	if r.Repo.Version != "" {
+		if _, err := semver.NewConstraint(r.Repo.Version); err != nil {
+			errs = append(errs, field.Invalid(repo.Child("version"), r.Repo.Version, err.Error()))
+		}
+	}
+
+	for name, ns := range map[string]string{
+		"targetNamespace":  r.Repo.TargetNamespace,
+		"storageNamespace": r.Repo.StorageNamespace,
+	} {
+		if ns == "" {
+			continue
+		}
+
+		// resolved for each ManagedCluster, checked once resolved
+		if name == "targetNamespace" && isClusterTemplate(r, ns) {
+			if _, err := template.New(name).Parse(ns); err != nil {
+				errs = append(errs, field.Invalid(repo.Child(name), ns, err.Error()))
+			}
+
+			continue
+		}
+
+		for _, msg := range validation.IsDNS1123Label(ns) {
+			errs = append(errs, field.Invalid(repo.Child(name), ns, msg))
+		}
+	}
+
+	if name := r.Repo.ClusterReleaseName; name != "" {
+		if _, err := template.New("clusterReleaseName").Parse(name); err != nil {
+			errs = append(errs, field.Invalid(repo.Child("clusterReleaseName"), name, err.Error()))
+		}
+	}
+
+	for i, secret := range r.Repo.ImagePullSecrets {
+		for _, msg := range validation.IsDNS1123Subdomain(secret.Name) {
+			errs = append(errs, field.Invalid(repo.Child("imagePullSecrets").Index(i).Child("name"), secret.Name, msg))
+		}
+	}
+
+	if name := r.Repo.PriorityClassName; name != "" {
+		for _, msg := range validation.IsDNS1123Subdomain(name) {
+			errs = append(errs, field.Invalid(repo.Child("priorityClassName"), name, msg))
+		}
+	}
+
+	errs = append(errs, metav1validation.ValidateLabels(r.Repo.CommonLabels, repo.Child("commonLabels"))...)
+	errs = append(errs, apivalidation.ValidateAnnotations(r.Repo.CommonAnnotations, repo.Child("commonAnnotations"))...)
+	errs = append(errs, validateImageMirrors(r.Repo.ImageMirrors, repo.Child("imageMirrors"))...)
+
+	if r.Repo.Scheduling != nil {
+		errs = append(errs, validateScheduling(r.Repo.Scheduling, repo.Child("scheduling"))...)
+	}
+
+	if r.Repo.TopologySpread != nil {
+		errs = append(errs, validateTopologySpread(r.Repo.TopologySpread, repo.Child("topologySpread"))...)
+	}
+
+	errs = append(errs, validateEnv(r.Repo.Env, repo.Child("env"))...)
+	errs = append(errs, validatePatches(r.Repo.Patches, repo.Child("patches"))...)
+
+	// server-side apply has no patch types, the HelmReleases created with
+	// both before can still be updated, their patch strategies are ignored
+	if patchedWithServerSideApply(&r.Repo) && (old == nil || !patchedWithServerSideApply(&old.Repo)) {
+		errs = append(errs, field.Forbidden(repo.Child("patchStrategies"), "cannot be set with serverSideApply"))
+	}
+
+	if p := r.Repo.Preconditions; p != nil && p.KubeVersion != "" {
+		if _, err := semver.NewConstraint(p.KubeVersion); err != nil {
+			errs = append(errs, field.Invalid(repo.Child("preconditions", "kubeVersion"), p.KubeVersion, err.Error()))
+		}
+	}
+
+	if r.Repo.Rollout != nil {
+		errs = append(errs, validateWaves(r.Repo.Rollout.Waves, repo.Child("rollout", "waves"))...)
+	}
+
+	if len(r.Repo.Charts) != 0 {
+		errs = append(errs, r.validateCharts(old, repo.Child("charts"))...)
+
+		// the umbrella HelmRelease has no release of its own
+		if r.Repo.ReleaseName != "" {
+			errs = append(errs, field.Forbidden(repo.Child("releaseName"), "cannot be set with charts"))
+		}
+	}
+
+	if old != nil && (len(old.Repo.Charts) == 0) != (len(r.Repo.Charts) == 0) {
+		errs = append(errs, field.Forbidden(repo.Child("charts"),
+			"cannot switch between a chart and charts, create another HelmRelease instead"))
+	}
+
+	if old != nil && old.Status.DeployedRelease != nil && old.Repo.TargetNamespace != r.Repo.TargetNamespace {
+		errs = append(errs, field.Forbidden(repo.Child("targetNamespace"),
+			"cannot be changed once the release is installed"))
+	}
+
+	if name := r.Repo.ReleaseName; name != "" {
+		for _, msg := range validation.IsDNS1123Subdomain(name) {
+			errs = append(errs, field.Invalid(repo.Child("releaseName"), name, msg))
+		}
+
+		if len(name) > maxReleaseNameLength {
+			errs = append(errs, field.TooLong(repo.Child("releaseName"), name, maxReleaseNameLength))
+		}
+	}
+
+	if old != nil && old.Status.DeployedRelease != nil && old.Repo.ReleaseName != r.Repo.ReleaseName {
+		errs = append(errs, field.Forbidden(repo.Child("releaseName"),
+			"cannot be changed once the release is installed"))
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+
+	return apierrors.NewInvalid(SchemeGroupVersion.WithKind("HelmRelease").GroupKind(), r.GetName(), errs)
+}
+
+// maxReleaseNameLength is the longest release name helm accepts.
+const maxReleaseNameLength = 53
+
+// isClusterTemplate returns true if s is a template resolved for each
+// ManagedCluster r is deployed to.
+func isClusterTemplate(r *HelmRelease, s string) bool {
+	return (r.Repo.ClusterSelector != nil || r.Repo.PlacementRef != nil) && strings.Contains(s, "{{")
+}
+
+// validateImageMirrors checks that the mirrors are registries, not URLs, and
+// that each registry has a single mirror.
+func validateImageMirrors(mirrors []ImageMirror, path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+
+	registries := map[string]bool{}
+
+	for i, m := range mirrors {
+		if m.Registry == "" {
+			errs = append(errs, field.Required(path.Index(i).Child("registry"), ""))
+		} else if registries[m.Registry] {
+			errs = append(errs, field.Duplicate(path.Index(i).Child("registry"), m.Registry))
+		}
+
+		registries[m.Registry] = true
+
+		if m.Mirror == "" {
+			errs = append(errs, field.Required(path.Index(i).Child("mirror"), ""))
+		}
+
+		if strings.Contains(m.Registry, "://") {
+			errs = append(errs, field.Invalid(path.Index(i).Child("registry"), m.Registry, "must be a registry, without a scheme"))
+		}
+
+		if strings.Contains(m.Mirror, "://") {
+			errs = append(errs, field.Invalid(path.Index(i).Child("mirror"), m.Mirror, "must be a registry, without a scheme"))
+		}
+	}
+
+	return errs
+}
+
+// validateScheduling checks the nodeSelector labels and the operators and
+// effects of the tolerations.
+func validateScheduling(scheduling *PodScheduling, path *field.Path) field.ErrorList {
+	errs := metav1validation.ValidateLabels(scheduling.NodeSelector, path.Child("nodeSelector"))
+
+	for i, t := range scheduling.Tolerations {
+		switch t.Operator {
+		case "", corev1.TolerationOpEqual, corev1.TolerationOpExists:
+		default:
+			errs = append(errs, field.NotSupported(path.Child("tolerations").Index(i).Child("operator"), t.Operator,
+				[]string{string(corev1.TolerationOpEqual), string(corev1.TolerationOpExists)}))
+		}
+
+		switch t.Effect {
+		case "", corev1.TaintEffectNoSchedule, corev1.TaintEffectPreferNoSchedule, corev1.TaintEffectNoExecute:
+		default:
+			errs = append(errs, field.NotSupported(path.Child("tolerations").Index(i).Child("effect"), t.Effect,
+				[]string{string(corev1.TaintEffectNoSchedule), string(corev1.TaintEffectPreferNoSchedule),
+					string(corev1.TaintEffectNoExecute)}))
+		}
+	}
+
+	return errs
+}
+
+// validateTopologySpread checks that the topology keys are label keys, each
+// listed once.
+func validateTopologySpread(spread *TopologySpread, path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+
+	if len(spread.TopologyKeys) == 0 {
+		errs = append(errs, field.Required(path.Child("topologyKeys"), ""))
+	}
+
+	keys := map[string]bool{}
+
+	for i, key := range spread.TopologyKeys {
+		for _, msg := range validation.IsQualifiedName(key) {
+			errs = append(errs, field.Invalid(path.Child("topologyKeys").Index(i), key, msg))
+		}
+
+		if keys[key] {
+			errs = append(errs, field.Duplicate(path.Child("topologyKeys").Index(i), key))
+		}
+
+		keys[key] = true
+	}
+
+	return errs
+}
+
+// validateEnv checks that the variables have a valid name, each set once,
+// and either a value or a source.
+func validateEnv(env []corev1.EnvVar, path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+
+	names := map[string]bool{}
+
+	for i, v := range env {
+		if v.Name == "" {
+			errs = append(errs, field.Required(path.Index(i).Child("name"), ""))
+		}
+
+		for _, msg := range validation.IsEnvVarName(v.Name) {
+			errs = append(errs, field.Invalid(path.Index(i).Child("name"), v.Name, msg))
+		}
+
+		if names[v.Name] {
+			errs = append(errs, field.Duplicate(path.Index(i).Child("name"), v.Name))
+		}
+
+		names[v.Name] = true
+
+		if v.Value != "" && v.ValueFrom != nil {
+			errs = append(errs, field.Invalid(path.Index(i).Child("valueFrom"), "",
+				"may not be specified when `value` is not empty"))
+		}
+	}
+
+	return errs
+}
+
+// validatePatches checks that the patches are YAML or JSON objects, or lists
+// of operations for the json patches.
+func validatePatches(patches []ResourcePatch, path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+
+	for i, p := range patches {
+		if strings.TrimSpace(p.Patch) == "" {
+			errs = append(errs, field.Required(path.Index(i).Child("patch"), ""))
+			continue
+		}
+
+		patch, err := yaml.YAMLToJSON([]byte(p.Patch))
+		if err != nil {
+			errs = append(errs, field.Invalid(path.Index(i).Child("patch"), p.Patch, err.Error()))
+			continue
+		}
+
+		if p.Type == JSONPatchStrategy {
+			if _, err := jsonpatch.DecodePatch(patch); err != nil {
+				errs = append(errs, field.Invalid(path.Index(i).Child("patch"), p.Patch, err.Error()))
+			}
+
+			continue
+		}
+
+		if err := json.Unmarshal(patch, &map[string]interface{}{}); err != nil {
+			errs = append(errs, field.Invalid(path.Index(i).Child("patch"), p.Patch, "must be an object"))
+		}
+	}
+
+	return errs
+}
+
+// validateSource checks that the source has a known type and only the
+// location of that type.
+func validateSource(source *Source, path *field.Path) field.ErrorList {
+	if source == nil {
+		return field.ErrorList{field.Required(path, "the source of the chart must be set")}
+	}
+
+	locations := []struct {
+		sourceType SourceTypeEnum
+		field      string
+		set        bool
+	}{
+		{HelmRepoSourceType, "helmRepo", source.HelmRepo != nil},
+		{GitHubSourceType, "github", source.GitHub != nil},
+		{GitSourceType, "git", source.Git != nil},
+	}
+
+	sourceType := SourceTypeEnum(strings.ToLower(string(source.SourceType)))
+
+	known := false
+	for _, l := range locations {
+		known = known || l.sourceType == sourceType
+	}
+
+	if !known {
+		return field.ErrorList{field.NotSupported(path.Child("type"), source.SourceType,
+			[]string{string(HelmRepoSourceType), string(GitHubSourceType), string(GitSourceType)})}
+	}
+
+	var errs field.ErrorList
+
+	for _, l := range locations {
+		switch {
+		case l.sourceType == sourceType && !l.set:
+			errs = append(errs, field.Required(path.Child(l.field), "must be set for the "+string(sourceType)+" type"))
+		case l.sourceType != sourceType && l.set:
+			errs = append(errs, field.Forbidden(path.Child(l.field), "must not be set for the "+string(sourceType)+" type"))
+		}
+	}
+
+	return errs
+}
+
+// validateWaves checks that the waves have unique names and valid
+// maxUnavailable. The canary clusters are recorded as the canary wave.
+func validateWaves(waves []RolloutWave, path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+
+	names := map[string]bool{CanaryWave: true}
+
+	for i, wave := range waves {
+		switch {
+		case wave.Name == "":
+			errs = append(errs, field.Required(path.Index(i).Child("name"), "the waves must be named"))
+		case names[wave.Name]:
+			errs = append(errs, field.Duplicate(path.Index(i).Child("name"), wave.Name))
+		}
+
+		names[wave.Name] = true
+
+		if wave.MaxUnavailable == nil {
+			continue
+		}
+
+		if _, err := intstr.GetValueFromIntOrPercent(wave.MaxUnavailable, 100, false); err != nil {
+			errs = append(errs, field.Invalid(path.Index(i).Child("maxUnavailable"), wave.MaxUnavailable.String(), err.Error()))
+		}
+	}
+
+	return errs
+}
+
+// validateCharts checks the charts of an umbrella HelmRelease: their names
+// must be unique and name valid HelmReleases, and their sources must be
+// valid and allowed.
+func (r *HelmRelease) validateCharts(old *HelmRelease, path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+
+	names := make(map[string]bool, len(r.Repo.Charts))
+
+	for i, chart := range r.Repo.Charts {
+		switch {
+		case chart.Name == "":
+			errs = append(errs, field.Required(path.Index(i).Child("name"), "the charts must be named"))
+		case names[chart.Name]:
+			errs = append(errs, field.Duplicate(path.Index(i).Child("name"), chart.Name))
+		default:
+			for _, msg := range validation.IsDNS1123Label(chart.Name) {
+				errs = append(errs, field.Invalid(path.Index(i).Child("name"), chart.Name, msg))
+			}
+
+			for _, msg := range validation.IsDNS1123Subdomain(r.GetName() + "-" + chart.Name) {
+				errs = append(errs, field.Invalid(path.Index(i).Child("name"), chart.Name, "HelmRelease name: "+msg))
+			}
+		}
+
+		names[chart.Name] = true
+
+		if chart.ChartName == "" {
+			errs = append(errs, field.Required(path.Index(i).Child("chartName"), "the charts must set their chart name"))
+		}
+
+		if chart.Source != nil {
+			errs = append(errs, validateSource(chart.Source, path.Index(i).Child("source"))...)
+
+			if old == nil || !reflect.DeepEqual(old.chartSource(chart.Name), chart.Source) {
+				if err := CheckChartSource(r.GetNamespace(), chart.Source); err != nil {
+					errs = append(errs, field.Forbidden(path.Index(i).Child("source"), err.Error()))
+				}
+			}
+		}
+
+		if chart.Version != "" {
+			if _, err := semver.NewConstraint(chart.Version); err != nil {
+				errs = append(errs, field.Invalid(path.Index(i).Child("version"), chart.Version, err.Error()))
+			}
+		}
+
+		if ns := chart.TargetNamespace; ns != "" {
+			for _, msg := range validation.IsDNS1123Label(ns) {
+				errs = append(errs, field.Invalid(path.Index(i).Child("targetNamespace"), ns, msg))
+			}
+		}
+
+		if len(chart.Values.Raw) != 0 {
+			var values map[string]interface{}
+			if err := json.Unmarshal(chart.Values.Raw, &values); err != nil {
+				errs = append(errs, field.Invalid(path.Index(i).Child("values"), string(chart.Values.Raw), err.Error()))
+			}
+		}
+	}
+
+	return errs
+}
+
+// chartsSetSources returns true if r is an umbrella HelmRelease whose charts
+// all set their source.
+func (r *HelmRelease) chartsSetSources() bool {
+	for _, chart := range r.Repo.Charts {
+		if chart.Source == nil {
+			return false
+		}
+	}
+
+	return len(r.Repo.Charts) != 0
+}
+
+// chartSource returns the source of the chart name of the umbrella
+// HelmRelease r, nil if it has no such chart.
+func (r *HelmRelease) chartSource(name string) *Source {
+	for _, chart := range r.Repo.Charts {
+		if chart.Name == name {
+			return chart.Source
+		}
+	}
+
+	return nil
+}
+
+const (
+	// ApproveWaveAnnotation approves a rollout wave of a HelmRelease. Its value
+	// is the revision of the rollout and the name of the wave, e.g.
+	// 3fa2b1c4d5e6f708/prod, so that the approval does not carry over to the
+	// next rollouts.
+	ApproveWaveAnnotation = "apps.open-cluster-management.io/approve-wave"
+
+	// WaveApprovedByAnnotation is the user who set the ApproveWaveAnnotation,
+	// set by the approval webhook.
+	WaveApprovedByAnnotation = "apps.open-cluster-management.io/wave-approved-by"
+
+	// ApprovalWebhookPath is the path the WaveApprovalHandler is served on.
+	ApprovalWebhookPath = "/approve-apps-open-cluster-management-io-v1-helmrelease"
+
+	// CanaryWave is the name of the wave of the canary clusters.
+	CanaryWave = "canary"
+)
+
+// +kubebuilder:webhook:path=/approve-apps-open-cluster-management-io-v1-helmrelease,mutating=true,failurePolicy=fail,groups=apps.open-cluster-management.io,resources=helmreleases,verbs=create;update,versions=v1,name=ahelmrelease.apps.open-cluster-management.io
+
+// WaveApprovalHandler records the user changing the ApproveWaveAnnotation of a
+// HelmRelease in its WaveApprovedByAnnotation, and keeps the recorded user
+// otherwise, so that the approvers cannot be forged.
+type WaveApprovalHandler struct{}
+
+var _ admission.Handler = WaveApprovalHandler{}
+
+// Handle stamps the approver of the wave approved by the request.
+func (WaveApprovalHandler) Handle(ctx context.Context, req admission.Request) admission.Response {
+	obj := &unstructured.Unstructured{}
+	if err := json.Unmarshal(req.Object.Raw, &obj.Object); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	annotations := obj.GetAnnotations()
+	approval := annotations[ApproveWaveAnnotation]
+
+	approver := ""
+
+	if approval != "" {
+		approver = req.UserInfo.Username
+
+		if len(req.OldObject.Raw) != 0 {
+			old := &unstructured.Unstructured{}
+			if err := json.Unmarshal(req.OldObject.Raw, &old.Object); err != nil {
+				return admission.Errored(http.StatusBadRequest, err)
+			}
+
+			if old.GetAnnotations()[ApproveWaveAnnotation] == approval {
+				approver = old.GetAnnotations()[WaveApprovedByAnnotation]
+			}
+		}
+	}
+
+	if annotations[WaveApprovedByAnnotation] == approver {
+		return admission.Allowed("")
+	}
+
+	if approver == "" {
+		delete(annotations, WaveApprovedByAnnotation)
+	} else {
+		if annotations == nil {
+			annotations = make(map[string]string)
+		}
+
+		annotations[WaveApprovedByAnnotation] = approver
+	}
+
+	obj.SetAnnotations(annotations)
+
+	marshaled, err := json.Marshal(obj.Object)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+
+	return admission.PatchResponseFromRaw(req.Object.Raw, marshaled)
+}
@@ -0,0 +1,203 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+func newWebhookTestHelmRelease() *HelmRelease {
+	return &HelmRelease{
+		Repo: HelmReleaseRepo{
+			Source: &Source{
+				SourceType: HelmRepoSourceType,
+				HelmRepo:   &HelmRepo{Urls: []string{"https://charts.example.com/webapp-1.0.0.tgz"}},
+			},
+			ChartName: "webapp",
+		},
+	}
+}
+
+func TestHelmReleaseDefault(t *testing.T) {
+	hr := newWebhookTestHelmRelease()
+	hr.Repo.Source.SourceType = "HelmRepo"
+	hr.Default()
+
+	assert.Equal(t, HelmRepoSourceType, hr.Repo.Source.SourceType)
+	assert.Equal(t, DefaultMaxHistory, *hr.Repo.MaxHistory)
+	assert.Equal(t, DefaultInterval, hr.Repo.Interval.Duration)
+	assert.True(t, *hr.Repo.Prune)
+	assert.Equal(t, DefaultFieldManager, hr.Repo.FieldManager)
+	assert.Equal(t, DeleteDeletionPolicy, hr.Repo.DeletionPolicy)
+	assert.Equal(t, NormalPriority, hr.Repo.Priority)
+	assert.Equal(t, RetryPendingReleasePolicy, hr.Repo.PendingReleasePolicy)
+	// the timeout and the conflict policy only apply to waited and applied releases
+	assert.Nil(t, hr.Repo.Timeout)
+	assert.Empty(t, hr.Repo.ConflictPolicy)
+
+	hr = newWebhookTestHelmRelease()
+	hr.Repo.Wait = true
+	hr.Repo.ServerSideApply = true
+	hr.Repo.Interval = &metav1.Duration{Duration: DefaultInterval * 2}
+	hr.Default()
+
+	assert.Equal(t, DefaultWaitTimeout, hr.Repo.Timeout.Duration)
+	assert.Equal(t, FailConflictPolicy, hr.Repo.ConflictPolicy)
+	assert.Equal(t, DefaultInterval*2, hr.Repo.Interval.Duration)
+}
+
+func TestHelmReleaseValidateCreate(t *testing.T) {
+	assert.NoError(t, newWebhookTestHelmRelease().ValidateCreate())
+
+	// the names of the ManagedClusters are templates
+	hub := newWebhookTestHelmRelease()
+	hub.Repo.ClusterSelector = &metav1.LabelSelector{}
+	hub.Repo.TargetNamespace = "{{ .clusterClaims.region }}-webapp"
+	assert.NoError(t, hub.ValidateCreate())
+
+	hub.Repo.ClusterReleaseName = "webapp-{{ .clusterName"
+	assert.True(t, apierrors.IsInvalid(hub.ValidateCreate()))
+
+	for name, mutate := range map[string]func(hr *HelmRelease){
+		"no source":         func(hr *HelmRelease) { hr.Repo.Source = nil },
+		"unknown type":      func(hr *HelmRelease) { hr.Repo.Source.SourceType = "s3" },
+		"missing location":  func(hr *HelmRelease) { hr.Repo.Source.SourceType = GitSourceType },
+		"invalid version":   func(hr *HelmRelease) { hr.Repo.Version = "latest" },
+		"invalid namespace": func(hr *HelmRelease) { hr.Repo.TargetNamespace = "Team_A" },
+		"unnamed wave": func(hr *HelmRelease) {
+			hr.Repo.Rollout = &RolloutStrategy{Waves: []RolloutWave{{}}}
+		},
+		"canary wave": func(hr *HelmRelease) {
+			hr.Repo.Rollout = &RolloutStrategy{Waves: []RolloutWave{{Name: CanaryWave}}}
+		},
+		"invalid max unavailable": func(hr *HelmRelease) {
+			maxUnavailable := intstr.FromString("half")
+			hr.Repo.Rollout = &RolloutStrategy{Waves: []RolloutWave{{Name: "prod", MaxUnavailable: &maxUnavailable}}}
+		},
+		"invalid release name": func(hr *HelmRelease) { hr.Repo.ReleaseName = "Webapp_Release" },
+		"long release name": func(hr *HelmRelease) {
+			hr.Repo.ReleaseName = "webapp-" + strings.Repeat("x", maxReleaseNameLength)
+		},
+		"invalid pull secret": func(hr *HelmRelease) {
+			hr.Repo.ImagePullSecrets = []corev1.LocalObjectReference{{Name: "Registry_Creds"}}
+		},
+		"two locations": func(hr *HelmRelease) {
+			hr.Repo.Source.GitHub = &GitHub{Urls: []string{"https://github.com/example/charts.git"}}
+		},
+		"patch strategies with server-side apply": func(hr *HelmRelease) {
+			hr.Repo.ServerSideApply = true
+			hr.Repo.PatchStrategies = []PatchStrategy{{Kind: "Deployment", Strategy: MergePatchStrategy}}
+		},
+	} {
+		hr := newWebhookTestHelmRelease()
+		mutate(hr)
+
+		err := hr.ValidateCreate()
+		assert.True(t, apierrors.IsInvalid(err), "%s: %v", name, err)
+	}
+}
+
+func TestHelmReleaseValidateUpdate(t *testing.T) {
+	old := newWebhookTestHelmRelease()
+	hr := newWebhookTestHelmRelease()
+	hr.Repo.TargetNamespace = "team-a"
+
+	assert.NoError(t, hr.ValidateUpdate(old))
+
+	// the target namespace cannot change once the release is installed
+	old.Status.DeployedRelease = &HelmAppRelease{Name: "webapp"}
+	assert.True(t, apierrors.IsInvalid(hr.ValidateUpdate(old)))
+
+	// nor the release name
+	hr = newWebhookTestHelmRelease()
+	hr.Repo.ReleaseName = "webapp-readable"
+	assert.True(t, apierrors.IsInvalid(hr.ValidateUpdate(old)))
+
+	// the patch strategies set before with server-side apply are kept
+	old = newWebhookTestHelmRelease()
+	old.Repo.ServerSideApply = true
+	old.Repo.PatchStrategies = []PatchStrategy{{Kind: "Deployment", Strategy: MergePatchStrategy}}
+
+	hr = old.DeepCopy()
+	hr.SetFinalizers([]string{"helmrelease.apps.open-cluster-management.io"})
+	assert.NoError(t, hr.ValidateUpdate(old))
+
+	old.Repo.ServerSideApply = false
+	assert.True(t, apierrors.IsInvalid(hr.ValidateUpdate(old)))
+}
+
+func TestWaveApprovalHandler(t *testing.T) {
+	approve := func(username string, obj, old map[string]string) admission.Response {
+		req := admission.Request{}
+		req.UserInfo.Username = username
+
+		for raw, annotations := range map[*runtime.RawExtension]map[string]string{&req.Object: obj, &req.OldObject: old} {
+			if annotations == nil {
+				continue
+			}
+
+			hr := newWebhookTestHelmRelease()
+			hr.SetAnnotations(annotations)
+
+			var err error
+			raw.Raw, err = json.Marshal(hr)
+			require.NoError(t, err)
+		}
+
+		return WaveApprovalHandler{}.Handle(context.TODO(), req)
+	}
+
+	// patches returns the JSON patches of the allowed response
+	patches := func(resp admission.Response) string {
+		require.True(t, resp.Allowed)
+
+		raw, err := json.Marshal(resp.Patches)
+		require.NoError(t, err)
+
+		return string(raw)
+	}
+
+	// the approver is the user setting the approval
+	assert.Contains(t, patches(approve("alice", map[string]string{ApproveWaveAnnotation: "rev1/prod"}, nil)), `"alice"`)
+
+	// and cannot be forged
+	forged := patches(approve("alice", map[string]string{
+		ApproveWaveAnnotation:    "rev1/prod",
+		WaveApprovedByAnnotation: "bob",
+	}, map[string]string{}))
+	assert.Contains(t, forged, `"alice"`)
+	assert.NotContains(t, forged, `"bob"`)
+
+	// it is kept by the other updates
+	approved := map[string]string{ApproveWaveAnnotation: "rev1/prod", WaveApprovedByAnnotation: "alice"}
+	assert.Equal(t, "null", patches(approve("controller", approved, approved)))
+
+	// and removed without an approval
+	assert.Contains(t, patches(approve("bob", map[string]string{WaveApprovedByAnnotation: "alice"}, nil)), `"remove"`)
+}
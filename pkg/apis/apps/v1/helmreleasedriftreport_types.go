@@ -0,0 +1,106 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// DriftReasonEnum is why a release differs from its HelmRelease
+type DriftReasonEnum string
+
+const (
+	// ResourcesDriftReason means the live resources no longer match the deployed release
+	ResourcesDriftReason DriftReasonEnum = "ResourcesDrifted"
+	// OutOfDateDriftReason means the deployed release is not rendered from the desired chart and
+	// values yet, e.g. held back by an upgrade window or a rollout
+	OutOfDateDriftReason DriftReasonEnum = "OutOfDate"
+	// FailedDriftReason means the desired release failed to deploy
+	FailedDriftReason DriftReasonEnum = "Failed"
+)
+
+// HelmReleaseDriftReportSpec selects the HelmReleases summarized by the report
+type HelmReleaseDriftReportSpec struct {
+	// Selector selects the HelmReleases of the namespace of the report by their labels. All of
+	// them are summarized if it is nil.
+	Selector *metav1.LabelSelector `json:"selector,omitempty"`
+	// Interval is how often the report is produced. Defaults to 1h.
+	Interval *metav1.Duration `json:"interval,omitempty"`
+}
+
+// HelmReleaseDrift is a HelmRelease whose release differs from it on a cluster
+type HelmReleaseDrift struct {
+	// HelmRelease is the name of the HelmRelease
+	HelmRelease string `json:"helmRelease"`
+	// Reason is ResourcesDrifted, OutOfDate or Failed
+	Reason  DriftReasonEnum `json:"reason"`
+	Message string          `json:"message,omitempty"`
+}
+
+// HelmReleaseClusterDrift summarizes the releases deployed to a cluster
+type HelmReleaseClusterDrift struct {
+	// Cluster is the name of the ManagedCluster, empty for the cluster the operator deploys to
+	// directly or with a kubeConfig
+	Cluster string `json:"cluster,omitempty"`
+	// Releases is the number of releases deployed to the cluster
+	Releases int `json:"releases"`
+	// Drifted lists the releases differing from their HelmRelease
+	Drifted []HelmReleaseDrift `json:"drifted,omitempty"`
+}
+
+// HelmReleaseDriftReportStatus is the last report produced
+type HelmReleaseDriftReportStatus struct {
+	// LastReportTime is when the report was produced
+	LastReportTime *metav1.Time `json:"lastReportTime,omitempty"`
+	// ObservedGeneration is the generation of the report the last report was produced for
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// Releases is the number of releases summarized, one per HelmRelease and cluster
+	Releases int `json:"releases"`
+	// DriftedReleases is the number of releases differing from their HelmRelease
+	DriftedReleases int `json:"driftedReleases"`
+	// Clusters summarizes the releases of each cluster, sorted by name
+	Clusters []HelmReleaseClusterDrift `json:"clusters,omitempty"`
+	// Error is why the last report could not be produced, if it failed
+	Error string `json:"error,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// HelmReleaseDriftReport summarizes periodically, per cluster, the releases
+// differing from their HelmRelease
+// +kubebuilder:resource:scope=Namespaced
+// +kubebuilder:subresource:status
+type HelmReleaseDriftReport struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   HelmReleaseDriftReportSpec   `json:"spec,omitempty"`
+	Status HelmReleaseDriftReportStatus `json:"status,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// HelmReleaseDriftReportList contains a list of HelmReleaseDriftReport
+type HelmReleaseDriftReportList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []HelmReleaseDriftReport `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&HelmReleaseDriftReport{}, &HelmReleaseDriftReportList{})
+}
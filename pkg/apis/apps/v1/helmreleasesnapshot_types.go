@@ -0,0 +1,121 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// SnapshotPhaseEnum is the state of a snapshot
+type SnapshotPhaseEnum string
+
+const (
+	// SnapshotCaptured means the revision was captured
+	SnapshotCaptured SnapshotPhaseEnum = "Captured"
+	// SnapshotFailed means the revision could not be captured, e.g. it is not in the release
+	// history
+	SnapshotFailed SnapshotPhaseEnum = "Failed"
+)
+
+// HelmReleaseSnapshotSpec selects the revision of the release captured by the snapshot
+type HelmReleaseSnapshotSpec struct {
+	// HelmRelease is the name of the HelmRelease of the namespace of the snapshot
+	HelmRelease string `json:"helmRelease"`
+	// Revision is the revision of the release captured. Defaults to the deployed revision.
+	Revision int `json:"revision,omitempty"`
+	// RestoreRequest rolls the release back to the captured revision when it changes, e.g. set
+	// to the current time. The HelmRelease must be suspended, its next reconcile would upgrade
+	// the release again otherwise.
+	RestoreRequest string `json:"restoreRequest,omitempty"`
+}
+
+// HelmReleaseSnapshotChart is the chart the captured revision was rendered from
+type HelmReleaseSnapshotChart struct {
+	Name    string `json:"name,omitempty"`
+	Version string `json:"version,omitempty"`
+	// Digest is the sha256 digest of the content of the chart and of its dependencies
+	Digest string `json:"digest,omitempty"`
+	// Source is the source of the chart of the HelmRelease when the snapshot was captured
+	Source *Source `json:"source,omitempty"`
+}
+
+// HelmReleaseSnapshotRestore is the outcome of the last restore
+type HelmReleaseSnapshotRestore struct {
+	// Request is the restoreRequest handled
+	Request string `json:"request"`
+	// Time is when the release was rolled back
+	Time *metav1.Time `json:"time,omitempty"`
+	// Revision is the revision created by the rollback
+	Revision int `json:"revision,omitempty"`
+	// Error is why the restore failed, if it did
+	Error string `json:"error,omitempty"`
+}
+
+// HelmReleaseSnapshotStatus is the captured revision
+type HelmReleaseSnapshotStatus struct {
+	// Phase is Captured or Failed
+	Phase SnapshotPhaseEnum `json:"phase,omitempty"`
+	// CaptureTime is when the revision was captured
+	CaptureTime *metav1.Time `json:"captureTime,omitempty"`
+	// Release is the name of the release
+	Release string `json:"release,omitempty"`
+	// Revision is the revision captured
+	Revision int `json:"revision,omitempty"`
+	// Chart is the chart the revision was rendered from
+	Chart HelmReleaseSnapshotChart `json:"chart,omitempty"`
+	// Values are the values the revision was rendered from, in YAML. The secret-shaped values
+	// are replaced by references to the keys of ValuesSecret.
+	Values string `json:"values,omitempty"`
+	// ValuesDigest is the digest of the values, secret values included
+	ValuesDigest string `json:"valuesDigest,omitempty"`
+	// ValuesSecret is the Secret of the namespace of the snapshot holding the secret values,
+	// owned by the snapshot
+	ValuesSecret string `json:"valuesSecret,omitempty"`
+	// Manifest is the manifest of the revision, the Secret data redacted
+	Manifest string `json:"manifest,omitempty"`
+	// Error is why the revision could not be captured
+	Error string `json:"error,omitempty"`
+	// LastRestore is the outcome of the last restore
+	LastRestore *HelmReleaseSnapshotRestore `json:"lastRestore,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// HelmReleaseSnapshot captures a revision of the release of a HelmRelease, so that the release
+// can be rolled back to it
+// +kubebuilder:resource:scope=Namespaced
+// +kubebuilder:subresource:status
+type HelmReleaseSnapshot struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   HelmReleaseSnapshotSpec   `json:"spec,omitempty"`
+	Status HelmReleaseSnapshotStatus `json:"status,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// HelmReleaseSnapshotList contains a list of HelmReleaseSnapshot
+type HelmReleaseSnapshotList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []HelmReleaseSnapshot `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&HelmReleaseSnapshot{}, &HelmReleaseSnapshotList{})
+}
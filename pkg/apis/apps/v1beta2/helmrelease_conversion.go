@@ -0,0 +1,224 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta2
+
+import (
+	"encoding/json"
+	"fmt"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"sigs.k8s.io/controller-runtime/pkg/conversion"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+var _ conversion.Convertible = &HelmRelease{}
+
+// ConvertTo converts the HelmRelease to the v1 storage version.
+func (in *HelmRelease) ConvertTo(dstRaw conversion.Hub) error {
+	dst, ok := dstRaw.(*appv1.HelmRelease)
+	if !ok {
+		return fmt.Errorf("unsupported conversion to %T", dstRaw)
+	}
+
+	dst.ObjectMeta = in.ObjectMeta
+	dst.Status = in.Status
+
+	spec := in.Spec
+	dst.Repo = appv1.HelmReleaseRepo{
+		Source:                      spec.Chart.Source,
+		ChartName:                   spec.Chart.Name,
+		Version:                     spec.Chart.Version,
+		Charts:                      spec.Charts,
+		SecretRef:                   spec.Chart.SecretRef,
+		ConfigMapRef:                spec.Chart.ConfigMapRef,
+		TLSSecretRef:                spec.Chart.TLSSecretRef,
+		InsecureSkipVerify:          spec.Chart.InsecureSkipVerify,
+		ServerSideApply:             spec.Apply.ServerSideApply,
+		ConflictPolicy:              spec.Apply.ConflictPolicy,
+		PatchStrategies:             spec.Apply.PatchStrategies,
+		FieldManager:                spec.Apply.FieldManager,
+		SyncWaves:                   spec.Apply.Waves,
+		IgnoreDifferences:           spec.Upgrade.IgnoreDifferences,
+		Prune:                       spec.Upgrade.Prune,
+		KubeConfig:                  spec.KubeConfig,
+		TargetNamespace:             spec.TargetNamespace,
+		ReleaseName:                 spec.ReleaseName,
+		OverrideNamespace:           spec.OverrideNamespace,
+		CreateNamespace:             spec.Install.CreateNamespace,
+		NamespaceMetadata:           spec.Install.NamespaceMetadata,
+		ServiceAccountName:          spec.ServiceAccountName,
+		ImagePullSecrets:            spec.ImagePullSecrets,
+		ImageMirrors:                spec.ImageMirrors,
+		ImagePullPolicy:             spec.ImagePullPolicy,
+		Scheduling:                  spec.Scheduling,
+		TopologySpread:              spec.TopologySpread,
+		DefaultResources:            spec.DefaultResources,
+		SecurityContext:             spec.SecurityContext,
+		Env:                         spec.Env,
+		EnvFrom:                     spec.EnvFrom,
+		PriorityClassName:           spec.PriorityClassName,
+		CommonLabels:                spec.CommonLabels,
+		CommonAnnotations:           spec.CommonAnnotations,
+		SkipResources:               spec.SkipResources,
+		IncludeKinds:                spec.IncludeKinds,
+		ExcludeKinds:                spec.ExcludeKinds,
+		Patches:                     spec.Patches,
+		AllowClusterScopedResources: spec.AllowClusterScopedResources,
+		StorageNamespace:            spec.StorageNamespace,
+		HistoryCompaction:           spec.HistoryCompaction,
+		DriftRemediation:            spec.Upgrade.DriftRemediation,
+		MaxHistory:                  spec.MaxHistory,
+		SnapshotUpgrades:            spec.SnapshotUpgrades,
+		Wait:                        spec.Wait.Enabled,
+		Timeout:                     spec.Wait.Timeout,
+		ResourceTimeouts:            spec.Wait.ResourceTimeouts,
+		WaitExclusions:              spec.Wait.Exclusions,
+		Interval:                    spec.Interval,
+		Suspend:                     spec.Suspend,
+		MaxFailures:                 spec.MaxFailures,
+		DeletionPolicy:              spec.Uninstall.DeletionPolicy,
+		DependsOn:                   spec.DependsOn,
+		UpgradeWindows:              spec.Upgrade.Windows,
+		UpgradeAfter:                spec.Upgrade.After,
+		Priority:                    spec.Priority,
+		PendingReleasePolicy:        spec.PendingReleasePolicy,
+		DeprecatedAPIPolicy:         spec.DeprecatedAPIPolicy,
+		PublishChartMetadata:        spec.PublishChartMetadata,
+		ClusterSelector:             spec.ClusterSelector,
+		PlacementRef:                spec.PlacementRef,
+		ClusterOverrides:            spec.ClusterOverrides,
+		Rollout:                     spec.Rollout,
+		Preconditions:               spec.Preconditions,
+		ClusterReleaseName:          spec.ClusterReleaseName,
+	}
+
+	dst.Spec = nil
+	if spec.Values != nil && len(spec.Values.Raw) != 0 {
+		var values interface{}
+		if err := json.Unmarshal(spec.Values.Raw, &values); err != nil {
+			return fmt.Errorf("failed to convert the values: %w", err)
+		}
+
+		dst.Spec = values
+	}
+
+	return nil
+}
+
+// ConvertFrom converts the v1 storage version to the HelmRelease.
+func (in *HelmRelease) ConvertFrom(srcRaw conversion.Hub) error {
+	src, ok := srcRaw.(*appv1.HelmRelease)
+	if !ok {
+		return fmt.Errorf("unsupported conversion from %T", srcRaw)
+	}
+
+	in.ObjectMeta = src.ObjectMeta
+	in.Status = src.Status
+
+	repo := src.Repo
+	in.Spec = HelmReleaseSpec{
+		Chart: ChartSpec{
+			Source:             repo.Source,
+			Name:               repo.ChartName,
+			Version:            repo.Version,
+			SecretRef:          repo.SecretRef,
+			ConfigMapRef:       repo.ConfigMapRef,
+			TLSSecretRef:       repo.TLSSecretRef,
+			InsecureSkipVerify: repo.InsecureSkipVerify,
+		},
+		Charts:                      repo.Charts,
+		TargetNamespace:             repo.TargetNamespace,
+		ReleaseName:                 repo.ReleaseName,
+		OverrideNamespace:           repo.OverrideNamespace,
+		StorageNamespace:            repo.StorageNamespace,
+		ServiceAccountName:          repo.ServiceAccountName,
+		ImagePullSecrets:            repo.ImagePullSecrets,
+		ImageMirrors:                repo.ImageMirrors,
+		ImagePullPolicy:             repo.ImagePullPolicy,
+		Scheduling:                  repo.Scheduling,
+		TopologySpread:              repo.TopologySpread,
+		DefaultResources:            repo.DefaultResources,
+		SecurityContext:             repo.SecurityContext,
+		Env:                         repo.Env,
+		EnvFrom:                     repo.EnvFrom,
+		PriorityClassName:           repo.PriorityClassName,
+		CommonLabels:                repo.CommonLabels,
+		CommonAnnotations:           repo.CommonAnnotations,
+		SkipResources:               repo.SkipResources,
+		IncludeKinds:                repo.IncludeKinds,
+		ExcludeKinds:                repo.ExcludeKinds,
+		Patches:                     repo.Patches,
+		AllowClusterScopedResources: repo.AllowClusterScopedResources,
+		KubeConfig:                  repo.KubeConfig,
+		DependsOn:                   repo.DependsOn,
+		Preconditions:               repo.Preconditions,
+		Interval:                    repo.Interval,
+		Suspend:                     repo.Suspend,
+		MaxFailures:                 repo.MaxFailures,
+		Priority:                    repo.Priority,
+		PendingReleasePolicy:        repo.PendingReleasePolicy,
+		DeprecatedAPIPolicy:         repo.DeprecatedAPIPolicy,
+		PublishChartMetadata:        repo.PublishChartMetadata,
+		ClusterSelector:             repo.ClusterSelector,
+		PlacementRef:                repo.PlacementRef,
+		ClusterOverrides:            repo.ClusterOverrides,
+		Rollout:                     repo.Rollout,
+		ClusterReleaseName:          repo.ClusterReleaseName,
+		MaxHistory:                  repo.MaxHistory,
+		HistoryCompaction:           repo.HistoryCompaction,
+		SnapshotUpgrades:            repo.SnapshotUpgrades,
+		Install: InstallSpec{
+			CreateNamespace:   repo.CreateNamespace,
+			NamespaceMetadata: repo.NamespaceMetadata,
+		},
+		Upgrade: UpgradeSpec{
+			Prune:             repo.Prune,
+			IgnoreDifferences: repo.IgnoreDifferences,
+			DriftRemediation:  repo.DriftRemediation,
+			Windows:           repo.UpgradeWindows,
+			After:             repo.UpgradeAfter,
+		},
+		Uninstall: UninstallSpec{
+			DeletionPolicy: repo.DeletionPolicy,
+		},
+		Apply: ApplySpec{
+			ServerSideApply: repo.ServerSideApply,
+			ConflictPolicy:  repo.ConflictPolicy,
+			PatchStrategies: repo.PatchStrategies,
+			FieldManager:    repo.FieldManager,
+			Waves:           repo.SyncWaves,
+		},
+		Wait: WaitSpec{
+			Enabled:          repo.Wait,
+			Timeout:          repo.Timeout,
+			ResourceTimeouts: repo.ResourceTimeouts,
+			Exclusions:       repo.WaitExclusions,
+		},
+	}
+
+	if src.Spec != nil {
+		raw, err := json.Marshal(src.Spec)
+		if err != nil {
+			return fmt.Errorf("failed to convert the values: %w", err)
+		}
+
+		in.Spec.Values = &apiextensionsv1.JSON{Raw: raw}
+	}
+
+	return nil
+}
@@ -0,0 +1,233 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta2
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+// ChartSpec is where the chart is downloaded from
+type ChartSpec struct {
+	// Source holds the url toward the helm-chart
+	Source *appv1.Source `json:"source,omitempty"`
+	// Name is the name of the chart within the repo
+	Name string `json:"name,omitempty"`
+	// Version is the chart version, or a semver constraint
+	Version string `json:"version,omitempty"`
+	// SecretRef is the Secret used to access the repo
+	SecretRef *corev1.ObjectReference `json:"secretRef,omitempty"`
+	// ConfigMapRef holds the configuration parameters to access the repo
+	ConfigMapRef *corev1.ObjectReference `json:"configMapRef,omitempty"`
+	// TLSSecretRef is the kubernetes.io/tls Secret holding the client certificate presented to the repo
+	TLSSecretRef *corev1.ObjectReference `json:"tlsSecretRef,omitempty"`
+	// InsecureSkipVerify is used to skip repo server's TLS certificate verification
+	InsecureSkipVerify bool `json:"insecureSkipVerify,omitempty"`
+}
+
+// InstallSpec holds the settings of the installs
+type InstallSpec struct {
+	// CreateNamespace creates the target namespace before the install if it does not exist
+	CreateNamespace bool `json:"createNamespace,omitempty"`
+	// NamespaceMetadata labels and annotates the target namespace created with CreateNamespace
+	NamespaceMetadata *appv1.NamespaceMetadata `json:"namespaceMetadata,omitempty"`
+}
+
+// UpgradeSpec holds the settings of the upgrades
+type UpgradeSpec struct {
+	// Prune deletes the resources no longer rendered by the chart. Defaults to true.
+	Prune *bool `json:"prune,omitempty"`
+	// IgnoreDifferences lists fields excluded when deciding if the deployed release needs an upgrade
+	IgnoreDifferences []appv1.ResourceIgnoreDifferences `json:"ignoreDifferences,omitempty"`
+	// DriftRemediation re-applies the deployed resources whose live state no longer matches the
+	// deployed release
+	DriftRemediation bool `json:"driftRemediation,omitempty"`
+	// Windows are the maintenance windows the upgrades are run in
+	Windows []appv1.UpgradeWindow `json:"windows,omitempty"`
+	// After delays the upgrades until this time
+	After *metav1.Time `json:"after,omitempty"`
+}
+
+// UninstallSpec holds the settings of the uninstall
+type UninstallSpec struct {
+	// DeletionPolicy is Delete or Orphan. Defaults to Delete.
+	DeletionPolicy appv1.DeletionPolicyEnum `json:"deletionPolicy,omitempty"`
+}
+
+// ApplySpec decides how the resources of a release are created and updated
+type ApplySpec struct {
+	// ServerSideApply applies the rendered resources using server-side apply
+	ServerSideApply bool `json:"serverSideApply,omitempty"`
+	// ConflictPolicy is fail or force. Defaults to fail.
+	ConflictPolicy appv1.ConflictPolicyEnum `json:"conflictPolicy,omitempty"`
+	// PatchStrategies overrides the patch type used to update the resources of the given kinds
+	PatchStrategies []appv1.PatchStrategy `json:"patchStrategies,omitempty"`
+	// FieldManager is the field manager recorded in managedFields
+	FieldManager string `json:"fieldManager,omitempty"`
+	// Waves sets the sync wave of the resources of the given kinds
+	Waves []appv1.ResourceWave `json:"waves,omitempty"`
+}
+
+// WaitSpec decides how long the installs and upgrades wait for the resources to be ready
+type WaitSpec struct {
+	// Enabled waits for the resources of the release to be ready
+	Enabled bool `json:"enabled,omitempty"`
+	// Timeout to wait for the resources to be ready and for the hooks to complete
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+	// ResourceTimeouts overrides the timeout of the resources of the given kinds
+	ResourceTimeouts []appv1.ResourceTimeout `json:"resourceTimeouts,omitempty"`
+	// Exclusions lists the kinds whose readiness is not waited for
+	Exclusions []appv1.ResourceKind `json:"exclusions,omitempty"`
+}
+
+// HelmReleaseSpec defines the desired state of HelmRelease. The settings are the same
+// as the v1 repo settings, grouped by what they apply to.
+type HelmReleaseSpec struct {
+	// Chart is where the chart is downloaded from
+	Chart ChartSpec `json:"chart,omitempty"`
+	// Values are the values of the chart
+	// +kubebuilder:pruning:PreserveUnknownFields
+	Values *apiextensionsv1.JSON `json:"values,omitempty"`
+	// Charts deploys several charts as ordered sub-releases sharing the values, instead of the chart
+	Charts []appv1.UmbrellaChart `json:"charts,omitempty"`
+	// TargetNamespace is the namespace the chart is deployed to, a template for the ManagedClusters
+	TargetNamespace string `json:"targetNamespace,omitempty"`
+	// ReleaseName is the name of the helm release, defaults to the one derived from the name of the HelmRelease
+	ReleaseName string `json:"releaseName,omitempty"`
+	// OverrideNamespace sets the namespace of the rendered resources that set another one to the target namespace
+	OverrideNamespace bool `json:"overrideNamespace,omitempty"`
+	// StorageNamespace is the namespace holding the helm release records
+	StorageNamespace string `json:"storageNamespace,omitempty"`
+	// ServiceAccountName is the ServiceAccount impersonated for every request of the release
+	ServiceAccountName string `json:"serviceAccountName,omitempty"`
+	// ImagePullSecrets are appended to the pod specs of the rendered workloads
+	ImagePullSecrets []corev1.LocalObjectReference `json:"imagePullSecrets,omitempty"`
+	// ImageMirrors replace the registries of the images of the rendered workloads with their mirrors
+	ImageMirrors []appv1.ImageMirror `json:"imageMirrors,omitempty"`
+	// ImagePullPolicy overrides the imagePullPolicy of the containers of the rendered workloads
+	ImagePullPolicy corev1.PullPolicy `json:"imagePullPolicy,omitempty"`
+	// Scheduling injects a nodeSelector, tolerations and an affinity into the pod specs of the rendered workloads
+	Scheduling *appv1.PodScheduling `json:"scheduling,omitempty"`
+	// TopologySpread adds topology spread constraints to the rendered Deployments and StatefulSets that have none
+	TopologySpread *appv1.TopologySpread `json:"topologySpread,omitempty"`
+	// DefaultResources are set on the containers of the rendered workloads for the requests and limits left unset
+	DefaultResources *corev1.ResourceRequirements `json:"defaultResources,omitempty"`
+	// SecurityContext defaults the securityContext of the pod specs and containers of the rendered workloads
+	SecurityContext *appv1.SecurityContextDefaults `json:"securityContext,omitempty"`
+	// Env variables are appended to the containers of the rendered workloads
+	Env []corev1.EnvVar `json:"env,omitempty"`
+	// EnvFrom sources are appended to the containers of the rendered workloads
+	EnvFrom []corev1.EnvFromSource `json:"envFrom,omitempty"`
+	// PriorityClassName is set on the pod specs of the rendered workloads that have no priority class
+	PriorityClassName string `json:"priorityClassName,omitempty"`
+	// CommonLabels are added to the metadata of the rendered resources and of the pod templates
+	CommonLabels map[string]string `json:"commonLabels,omitempty"`
+	// CommonAnnotations are added to the metadata of the rendered resources and of the pod templates
+	CommonAnnotations map[string]string `json:"commonAnnotations,omitempty"`
+	// SkipResources drops the matching rendered resources before they are applied
+	SkipResources []appv1.ResourceSelector `json:"skipResources,omitempty"`
+	// IncludeKinds only keeps the rendered resources of these kinds
+	IncludeKinds []appv1.ResourceKind `json:"includeKinds,omitempty"`
+	// ExcludeKinds drops the rendered resources of these kinds
+	ExcludeKinds []appv1.ResourceKind `json:"excludeKinds,omitempty"`
+	// Patches are applied to the matching rendered resources
+	Patches []appv1.ResourcePatch `json:"patches,omitempty"`
+	// AllowClusterScopedResources allows the chart to deploy cluster-scoped resources
+	AllowClusterScopedResources *bool `json:"allowClusterScopedResources,omitempty"`
+	// KubeConfig deploys the release to a remote cluster
+	KubeConfig *appv1.KubeConfig `json:"kubeConfig,omitempty"`
+	// DependsOn lists the HelmReleases that must be deployed first
+	DependsOn []appv1.DependencyReference `json:"dependsOn,omitempty"`
+	// Preconditions are checked against the target cluster before each install and upgrade
+	Preconditions *appv1.Preconditions `json:"preconditions,omitempty"`
+	// Interval is how often a deployed release is re-reconciled
+	Interval *metav1.Duration `json:"interval,omitempty"`
+	// Suspend pauses the reconciliation
+	Suspend bool `json:"suspend,omitempty"`
+	// MaxFailures is the number of consecutive failed reconciles after which the HelmRelease is Stalled
+	MaxFailures int `json:"maxFailures,omitempty"`
+	// Priority orders the reconciles of the HelmReleases when the operator starts
+	Priority appv1.PriorityEnum `json:"priority,omitempty"`
+	// PendingReleasePolicy decides how a release left pending is recovered
+	PendingReleasePolicy appv1.PendingReleasePolicyEnum `json:"pendingReleasePolicy,omitempty"`
+	// DeprecatedAPIPolicy decides what the rendered resources using deprecated API versions do
+	DeprecatedAPIPolicy appv1.DeprecatedAPIPolicyEnum `json:"deprecatedAPIPolicy,omitempty"`
+	// PublishChartMetadata publishes the metadata, the README and the default values of the chart
+	PublishChartMetadata bool `json:"publishChartMetadata,omitempty"`
+	// ClusterSelector deploys the chart to the selected ManagedClusters with ManifestWorks
+	ClusterSelector *metav1.LabelSelector `json:"clusterSelector,omitempty"`
+	// PlacementRef deploys the chart to the ManagedClusters decided by a PlacementRule
+	PlacementRef *corev1.LocalObjectReference `json:"placementRef,omitempty"`
+	// ClusterOverrides merge extra values for the ManagedClusters matching their selectors
+	ClusterOverrides []appv1.ClusterValuesOverride `json:"clusterOverrides,omitempty"`
+	// Rollout decides the order the selected ManagedClusters are upgraded in
+	Rollout *appv1.RolloutStrategy `json:"rollout,omitempty"`
+	// ClusterReleaseName is the release name rendered for each selected ManagedCluster, a template
+	ClusterReleaseName string `json:"clusterReleaseName,omitempty"`
+	// MaxHistory is the number of release revisions kept
+	MaxHistory *int `json:"maxHistory,omitempty"`
+	// HistoryCompaction deletes the release revisions that are not worth keeping
+	HistoryCompaction *appv1.HistoryCompaction `json:"historyCompaction,omitempty"`
+	// SnapshotUpgrades creates a HelmReleaseSnapshot of each installed and upgraded revision
+	SnapshotUpgrades bool `json:"snapshotUpgrades,omitempty"`
+	// Install holds the settings of the installs
+	Install InstallSpec `json:"install,omitempty"`
+	// Upgrade holds the settings of the upgrades
+	Upgrade UpgradeSpec `json:"upgrade,omitempty"`
+	// Uninstall holds the settings of the uninstall
+	Uninstall UninstallSpec `json:"uninstall,omitempty"`
+	// Apply decides how the resources are created and updated
+	Apply ApplySpec `json:"apply,omitempty"`
+	// Wait decides how long the installs and upgrades wait for the resources to be ready
+	Wait WaitSpec `json:"wait,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// HelmRelease is the Schema for the helmreleases API. It is converted to and from
+// the v1 storage version by the conversion webhook.
+// +kubebuilder:resource:scope=Namespaced
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Chart",type=string,JSONPath=`.spec.chart.name`,description="The name of the chart"
+// +kubebuilder:printcolumn:name="Version",type=string,JSONPath=`.status.deployedRelease.chartVersion`,description="The version of the chart of the deployed release"
+// +kubebuilder:printcolumn:name="Revision",type=integer,JSONPath=`.status.deployedRelease.revision`,description="The helm revision of the deployed release"
+// +kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`,description="Whether the release is deployed and its resources are ready"
+// +kubebuilder:printcolumn:name="Reason",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].reason`,priority=1,description="The reason of the Ready condition"
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+type HelmRelease struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   HelmReleaseSpec     `json:"spec,omitempty"`
+	Status appv1.HelmAppStatus `json:"status,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// HelmReleaseList contains a list of HelmRelease
+type HelmReleaseList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []HelmRelease `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&HelmRelease{}, &HelmReleaseList{})
+}
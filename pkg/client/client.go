@@ -30,7 +30,6 @@ import (
 	"k8s.io/cli-runtime/pkg/genericclioptions"
 	"k8s.io/cli-runtime/pkg/resource"
 	"k8s.io/client-go/discovery"
-	cached "k8s.io/client-go/discovery/cached"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
@@ -85,17 +84,21 @@ func (c namespaceClientConfig) ConfigAccess() clientcmd.ConfigAccess {
 }
 
 func NewRESTClientGetter(mgr manager.Manager, ns string) (genericclioptions.RESTClientGetter, error) {
-	cfg := mgr.GetConfig()
-	dc, err := discovery.NewDiscoveryClientForConfig(cfg)
+	return NewRESTClientGetterForConfig(mgr.GetConfig(), mgr.GetRESTMapper(), ns)
+}
+
+// NewRESTClientGetterForConfig returns a RESTClientGetter of the namespace ns
+// sending the requests with cfg, e.g. a config impersonating a ServiceAccount.
+// The discovery client is shared by the releases of the cluster of cfg.
+func NewRESTClientGetterForConfig(cfg *rest.Config, rm meta.RESTMapper, ns string) (genericclioptions.RESTClientGetter, error) {
+	dc, err := SharedDiscoveryClient(cfg)
 	if err != nil {
 		return nil, err
 	}
-	cdc := cached.NewMemCacheClient(dc)
-	rm := mgr.GetRESTMapper()
 
 	return &restClientGetter{
 		restConfig:      cfg,
-		discoveryClient: cdc,
+		discoveryClient: dc,
 		restMapper:      rm,
 		namespaceConfig: &namespaceClientConfig{ns},
 	}, nil
@@ -0,0 +1,149 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/client-go/discovery"
+	cached "k8s.io/client-go/discovery/cached"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+// MinDiscoveryAge is the age under which the shared discovery cache of a
+// cluster is not invalidated on request, e.g. by helm before each action. The
+// cache is refreshed at most once per MinDiscoveryAge by these requests,
+// InvalidateDiscovery refreshes it right away.
+var MinDiscoveryAge = 30 * time.Second
+
+// sharedDiscoveryClient is a cached discovery client shared by the releases of
+// a cluster, rate limiting the invalidations of its cache.
+type sharedDiscoveryClient struct {
+	discovery.CachedDiscoveryInterface
+
+	mu          sync.Mutex
+	invalidated time.Time
+}
+
+// Invalidate invalidates the cache unless it was invalidated less than
+// MinDiscoveryAge ago.
+func (d *sharedDiscoveryClient) Invalidate() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if time.Since(d.invalidated) < MinDiscoveryAge {
+		return
+	}
+
+	d.invalidate()
+}
+
+func (d *sharedDiscoveryClient) invalidate() {
+	d.CachedDiscoveryInterface.Invalidate()
+	d.invalidated = time.Now()
+}
+
+// clusterDiscovery is the discovery shared by the releases of a cluster
+type clusterDiscovery struct {
+	client     *sharedDiscoveryClient
+	restMapper meta.RESTMapper
+}
+
+var (
+	discoveryMu sync.Mutex
+	// clusters holds the shared discovery by API server host
+	clusters = map[string]*clusterDiscovery{}
+)
+
+// sharedDiscovery returns the discovery of the cluster of cfg, created on
+// first use. The discovery requests are sent without the impersonation of
+// cfg, they do not depend on the identity of the release.
+func sharedDiscovery(cfg *rest.Config) (*clusterDiscovery, error) {
+	discoveryMu.Lock()
+	defer discoveryMu.Unlock()
+
+	if d, ok := clusters[cfg.Host]; ok {
+		return d, nil
+	}
+
+	cfg = rest.CopyConfig(cfg)
+	cfg.Impersonate = rest.ImpersonationConfig{}
+
+	dc, err := discovery.NewDiscoveryClientForConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &clusterDiscovery{
+		client: &sharedDiscoveryClient{CachedDiscoveryInterface: cached.NewMemCacheClient(dc), invalidated: time.Now()},
+	}
+
+	clusters[cfg.Host] = d
+
+	return d, nil
+}
+
+// SharedDiscoveryClient returns the cached discovery client shared by the
+// releases of the cluster of cfg.
+func SharedDiscoveryClient(cfg *rest.Config) (discovery.CachedDiscoveryInterface, error) {
+	d, err := sharedDiscovery(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return d.client, nil
+}
+
+// SharedRESTMapper returns the REST mapper shared by the releases of the
+// cluster of cfg, e.g. a remote cluster. It reloads its mappings when a kind
+// is not found, so that the kinds of the new CustomResourceDefinitions are
+// mapped.
+func SharedRESTMapper(cfg *rest.Config) (meta.RESTMapper, error) {
+	d, err := sharedDiscovery(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	discoveryMu.Lock()
+	defer discoveryMu.Unlock()
+
+	if d.restMapper == nil {
+		if d.restMapper, err = apiutil.NewDynamicRESTMapper(cfg, apiutil.WithLazyDiscovery); err != nil {
+			return nil, err
+		}
+	}
+
+	return d.restMapper, nil
+}
+
+// InvalidateDiscovery invalidates the shared discovery cache of the cluster of
+// cfg right away, e.g. when its CustomResourceDefinitions change.
+func InvalidateDiscovery(cfg *rest.Config) {
+	discoveryMu.Lock()
+	d, ok := clusters[cfg.Host]
+	discoveryMu.Unlock()
+
+	if !ok {
+		return
+	}
+
+	d.client.mu.Lock()
+	defer d.client.mu.Unlock()
+
+	d.client.invalidate()
+}
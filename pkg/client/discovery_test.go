@@ -0,0 +1,83 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/rest"
+)
+
+type countingDiscoveryClient struct {
+	discovery.CachedDiscoveryInterface
+	invalidations int
+}
+
+func (c *countingDiscoveryClient) Invalidate() {
+	c.invalidations++
+}
+
+func TestSharedDiscoveryClientInvalidate(t *testing.T) {
+	counting := &countingDiscoveryClient{}
+	d := &sharedDiscoveryClient{CachedDiscoveryInterface: counting, invalidated: time.Now()}
+
+	d.Invalidate()
+	assert.Equal(t, 0, counting.invalidations)
+
+	d.invalidated = time.Now().Add(-MinDiscoveryAge)
+	d.Invalidate()
+	assert.Equal(t, 1, counting.invalidations)
+
+	d.Invalidate()
+	assert.Equal(t, 1, counting.invalidations)
+}
+
+func TestSharedDiscoveryClient(t *testing.T) {
+	cfg := &rest.Config{Host: "https://shared.example.com:6443"}
+
+	first, err := SharedDiscoveryClient(cfg)
+	assert.NoError(t, err)
+
+	impersonating := rest.CopyConfig(cfg)
+	impersonating.Impersonate = rest.ImpersonationConfig{UserName: "system:serviceaccount:default:deployer"}
+
+	second, err := SharedDiscoveryClient(impersonating)
+	assert.NoError(t, err)
+	assert.Same(t, first, second)
+
+	other, err := SharedDiscoveryClient(&rest.Config{Host: "https://other.example.com:6443"})
+	assert.NoError(t, err)
+	assert.NotSame(t, first, other)
+}
+
+func TestInvalidateDiscovery(t *testing.T) {
+	cfg := &rest.Config{Host: "https://invalidate.example.com:6443"}
+
+	// nothing to invalidate before the first use
+	InvalidateDiscovery(cfg)
+
+	_, err := SharedDiscoveryClient(cfg)
+	assert.NoError(t, err)
+
+	counting := &countingDiscoveryClient{}
+	clusters[cfg.Host].client.CachedDiscoveryInterface = counting
+
+	InvalidateDiscovery(cfg)
+	InvalidateDiscovery(cfg)
+	assert.Equal(t, 2, counting.invalidations)
+}
@@ -0,0 +1,166 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helmrelease
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"sync"
+
+	rpb "helm.sh/helm/v3/pkg/release"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+	"github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/release"
+)
+
+// maxAuditHistory is the number of audit records kept in the status
+const maxAuditHistory = 20
+
+// triggers of the audited helm actions
+const (
+	triggerSpecChanged        = "SpecChanged"
+	triggerReconcileRequested = "ReconcileRequested"
+	triggerForceUpgrade       = "ForceUpgrade"
+	triggerReleaseMissing     = "ReleaseMissing"
+	triggerDeleted            = "Deleted"
+	triggerResync             = "Resync"
+	triggerSnapshotRestore    = "SnapshotRestore"
+)
+
+// auditLogMu serializes the writes of the reconcile workers to the audit log
+var auditLogMu sync.Mutex
+
+// auditLogEntry is a line of the audit log
+type auditLogEntry struct {
+	// HelmRelease is the namespace/name of the HelmRelease
+	HelmRelease string `json:"helmRelease"`
+	Release     string `json:"release"`
+	appv1.HelmAppAuditRecord
+}
+
+// auditTrigger returns the change that triggered the install or upgrade of
+// hr. requested is the reconcile request handled by the current reconcile.
+func auditTrigger(hr *appv1.HelmRelease, requested string, forceUpgrade, installed bool) string {
+	switch {
+	case requested != "":
+		return triggerReconcileRequested
+	case forceUpgrade:
+		return triggerForceUpgrade
+	case hr.GetGeneration() != hr.Status.ObservedGeneration:
+		return triggerSpecChanged
+	case !installed:
+		return triggerReleaseMissing
+	default:
+		return triggerResync
+	}
+}
+
+// recordAudit appends the helm action run on the release of hr to its status
+// history and to the audit log. rel is the release returned by the action, err
+// its error.
+func recordAudit(hr *appv1.HelmRelease, manager release.Manager, action appv1.AuditActionEnum,
+	trigger string, rel *rpb.Release, err error) {
+	record := appv1.HelmAppAuditRecord{
+		Time:         metav1.Now(),
+		Action:       action,
+		Succeeded:    err == nil,
+		ValuesDigest: manager.ValuesDigest(),
+		Generation:   hr.GetGeneration(),
+		Trigger:      trigger,
+	}
+
+	if err != nil {
+		record.Message = release.RedactMessage(err.Error())
+	}
+
+	chart := manager.Chart()
+
+	if rel != nil {
+		record.Revision = rel.Version
+
+		if rel.Chart != nil {
+			chart = rel.Chart
+		}
+	}
+
+	if chart != nil && chart.Metadata != nil {
+		record.Chart = chart.Metadata.Name
+		record.ChartVersion = chart.Metadata.Version
+	}
+
+	hr.Status.History = append(hr.Status.History, record)
+	if len(hr.Status.History) > maxAuditHistory {
+		hr.Status.History = hr.Status.History[len(hr.Status.History)-maxAuditHistory:]
+	}
+
+	if Options.AuditLogPath == "" {
+		return
+	}
+
+	if err := writeAuditLog(Options.AuditLogPath, auditLogEntry{
+		HelmRelease:        hr.GetNamespace() + "/" + hr.GetName(),
+		Release:            manager.ReleaseName(),
+		HelmAppAuditRecord: record,
+	}); err != nil {
+		logFor(hr).Error(err, "Failed to write the audit log")
+	}
+}
+
+// recordUpgradeAudit records the failed upgrade of hr and the rollback to the
+// previous revision, if any.
+func recordUpgradeAudit(hr *appv1.HelmRelease, manager release.Manager, trigger string, err error) {
+	var upgradeErr *release.ErrUpgradeFailed
+	if !errors.As(err, &upgradeErr) {
+		recordAudit(hr, manager, appv1.AuditUpgrade, trigger, nil, err)
+		return
+	}
+
+	recordAudit(hr, manager, appv1.AuditUpgrade, trigger, nil, upgradeErr.Err)
+
+	switch {
+	case upgradeErr.RollbackErr != nil:
+		recordAudit(hr, manager, appv1.AuditRollback, trigger, nil, upgradeErr.RollbackErr)
+	case upgradeErr.RolledBack:
+		recordAudit(hr, manager, appv1.AuditRollback, trigger, nil, nil)
+	}
+}
+
+// writeAuditLog appends entry to the audit log at path, one JSON object per
+// line. The log is only ever appended to.
+func writeAuditLog(path string, entry auditLogEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	auditLogMu.Lock()
+	defer auditLogMu.Unlock()
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	return f.Close()
+}
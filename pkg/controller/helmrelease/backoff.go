@@ -0,0 +1,105 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helmrelease
+
+import (
+	"fmt"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+const (
+	// minRetryDelay is the delay before retrying the first failed reconcile of a HelmRelease
+	minRetryDelay = 10 * time.Second
+	// maxRetryDelay caps the delay between the retries of a failing HelmRelease of normal priority
+	maxRetryDelay = 10 * time.Minute
+)
+
+// retryAfter records a failed reconcile in the status of hr and returns the
+// delay before the next attempt. The delay doubles with each consecutive
+// failure, up to maxRetryDelay, a quarter of it for the high priority
+// HelmReleases so that they recover first, e.g. after a repository outage,
+// and twice it for the low priority ones. It is 0, i.e. no retry, once the
+// failures reach the maxFailures of hr.
+func retryAfter(hr *appv1.HelmRelease) time.Duration {
+	hr.Status.Failures++
+
+	if failuresExhausted(hr) {
+		hr.Status.NextRetryTime = nil
+		return 0
+	}
+
+	maxDelay := maxRetryDelay
+
+	switch hr.Repo.Priority {
+	case appv1.HighPriority:
+		maxDelay = maxRetryDelay / 4
+	case appv1.LowPriority:
+		maxDelay = 2 * maxRetryDelay
+	}
+
+	delay := maxDelay
+	if shift := hr.Status.Failures - 1; shift < 16 {
+		if d := minRetryDelay << uint(shift); d < maxDelay {
+			delay = d
+		}
+	}
+
+	next := metav1.NewTime(time.Now().Add(delay))
+	hr.Status.NextRetryTime = &next
+
+	return delay
+}
+
+// resetRetries clears the failures recorded in the status of hr after a
+// successful reconcile.
+func resetRetries(hr *appv1.HelmRelease) {
+	hr.Status.Failures = 0
+	hr.Status.NextRetryTime = nil
+}
+
+// failuresExhausted returns true if the consecutive failures of hr reached
+// its maxFailures.
+func failuresExhausted(hr *appv1.HelmRelease) bool {
+	return hr.Repo.MaxFailures > 0 && hr.Status.Failures >= hr.Repo.MaxFailures
+}
+
+// retriesExhausted returns true if hr stalled after exhausting its retries,
+// for its current generation.
+func retriesExhausted(hr *appv1.HelmRelease) bool {
+	c := hr.Status.GetCondition(appv1.ConditionStalled)
+
+	return c != nil && c.Status == appv1.StatusTrue && c.Reason == appv1.ReasonRetriesExhausted &&
+		c.ObservedGeneration == hr.GetGeneration()
+}
+
+// exhaustedMessage aggregates the failures of hr into the message of its
+// Stalled condition.
+func exhaustedMessage(hr *appv1.HelmRelease) string {
+	message := fmt.Sprintf("Stopped retrying after %d consecutive failed reconciles", hr.Status.Failures)
+
+	for _, t := range []appv1.HelmAppConditionType{appv1.ConditionIrreconcilable, appv1.ConditionReleaseFailed} {
+		if c := hr.Status.GetCondition(t); c != nil && c.Status == appv1.StatusTrue {
+			return fmt.Sprintf("%s, the last one with %s: %s", message, c.Reason, c.Message)
+		}
+	}
+
+	return message
+}
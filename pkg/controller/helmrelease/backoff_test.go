@@ -0,0 +1,89 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helmrelease
+
+import (
+	"testing"
+	"time"
+
+	"github.com/onsi/gomega"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+func TestRetryAfter(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	hr := &appv1.HelmRelease{}
+
+	// the delay doubles with each failed reconcile
+	for i, delay := range []time.Duration{minRetryDelay, 2 * minRetryDelay, 4 * minRetryDelay} {
+		g.Expect(retryAfter(hr)).To(gomega.Equal(delay))
+		g.Expect(hr.Status.Failures).To(gomega.Equal(i + 1))
+		g.Expect(hr.Status.NextRetryTime).NotTo(gomega.BeNil())
+	}
+
+	// up to the maximum delay
+	hr.Status.Failures = 100
+	g.Expect(retryAfter(hr)).To(gomega.Equal(maxRetryDelay))
+
+	// which depends on the priority
+	hr.Repo.Priority = appv1.HighPriority
+	g.Expect(retryAfter(hr)).To(gomega.Equal(maxRetryDelay / 4))
+
+	hr.Repo.Priority = appv1.LowPriority
+	g.Expect(retryAfter(hr)).To(gomega.Equal(2 * maxRetryDelay))
+
+	// and is reset by a successful one
+	resetRetries(hr)
+	g.Expect(hr.Status.Failures).To(gomega.BeZero())
+	g.Expect(hr.Status.NextRetryTime).To(gomega.BeNil())
+	g.Expect(retryAfter(hr)).To(gomega.Equal(minRetryDelay))
+}
+
+func TestRetriesExhausted(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	hr := &appv1.HelmRelease{}
+	hr.SetGeneration(1)
+	hr.Repo.MaxFailures = 2
+	hr.Status.SetCondition(appv1.HelmAppCondition{
+		Type:    appv1.ConditionReleaseFailed,
+		Status:  appv1.StatusTrue,
+		Reason:  appv1.ReasonInstallError,
+		Message: "timed out",
+	})
+
+	g.Expect(retryAfter(hr)).To(gomega.Equal(minRetryDelay))
+	setStandardConditions(hr)
+	g.Expect(retriesExhausted(hr)).To(gomega.BeFalse())
+
+	// the last failure is not retried
+	g.Expect(retryAfter(hr)).To(gomega.BeZero())
+	g.Expect(hr.Status.NextRetryTime).To(gomega.BeNil())
+
+	setStandardConditions(hr)
+	g.Expect(retriesExhausted(hr)).To(gomega.BeTrue())
+
+	stalled := hr.Status.GetCondition(appv1.ConditionStalled)
+	g.Expect(stalled.Message).To(gomega.Equal(
+		"Stopped retrying after 2 consecutive failed reconciles, the last one with InstallError: timed out"))
+
+	// until the spec changes
+	hr.SetGeneration(2)
+	g.Expect(retriesExhausted(hr)).To(gomega.BeFalse())
+}
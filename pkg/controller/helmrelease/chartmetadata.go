@@ -0,0 +1,186 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helmrelease
+
+import (
+	"context"
+	"reflect"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	cpb "helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chartutil"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+	"github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/release"
+)
+
+// maxChartMetadataSize is the size of the published chart metadata, below the
+// 1MiB limit of the ConfigMaps
+const maxChartMetadataSize = 900 * 1024
+
+// chartMetadataName returns the name of the ConfigMap the chart metadata of hr
+// is published to.
+func chartMetadataName(hr *appv1.HelmRelease) string {
+	return hr.GetName() + "-chart-metadata"
+}
+
+// syncChartMetadata sets the metadata of the chart of manager in the status of
+// hr and, with repo.publishChartMetadata, publishes it with the README and the
+// default values of the chart to the chart metadata ConfigMap, once per chart.
+// The ConfigMap is deleted once the publication is disabled. A failure is
+// logged and retried at the next reconcile, it never fails the reconcile.
+func (r *ReconcileHelmRelease) syncChartMetadata(hr *appv1.HelmRelease, manager release.Manager) {
+	chrt := manager.Chart()
+	if chrt == nil || chrt.Metadata == nil {
+		return
+	}
+
+	metadata := chartMetadataStatus(chrt)
+
+	var published string
+	if hr.Status.ChartMetadata != nil {
+		published = hr.Status.ChartMetadata.ConfigMap
+	}
+
+	switch {
+	case !hr.Repo.PublishChartMetadata && published != "":
+		cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: published, Namespace: hr.GetNamespace()}}
+
+		if err := r.GetClient().Delete(context.TODO(), cm); err != nil && !apierrors.IsNotFound(err) {
+			logFor(hr).Error(err, "Failed to delete the chart metadata", "configMap", published)
+
+			metadata.ConfigMap = published
+		}
+	case !hr.Repo.PublishChartMetadata:
+		// nothing published
+	case published != "" && hr.Status.ChartMetadata.Digest == metadata.Digest:
+		metadata.ConfigMap = published
+	default:
+		if err := r.publishChartMetadata(hr, chrt); err != nil {
+			logFor(hr).Error(err, "Failed to publish the chart metadata", "configMap", chartMetadataName(hr))
+		} else {
+			metadata.ConfigMap = chartMetadataName(hr)
+		}
+	}
+
+	hr.Status.ChartMetadata = metadata
+}
+
+// chartMetadataStatus returns the status of the metadata of chrt.
+func chartMetadataStatus(chrt *cpb.Chart) *appv1.HelmAppChartMetadata {
+	return &appv1.HelmAppChartMetadata{
+		Name:        chrt.Metadata.Name,
+		Version:     chrt.Metadata.Version,
+		AppVersion:  chrt.Metadata.AppVersion,
+		Description: chrt.Metadata.Description,
+		Home:        chrt.Metadata.Home,
+		Icon:        chrt.Metadata.Icon,
+		Deprecated:  chrt.Metadata.Deprecated,
+		Digest:      release.ChartDigest(chrt),
+	}
+}
+
+// publishChartMetadata creates or updates the chart metadata ConfigMap of hr,
+// owned by hr.
+func (r *ReconcileHelmRelease) publishChartMetadata(hr *appv1.HelmRelease, chrt *cpb.Chart) error {
+	data, err := chartMetadataData(chrt)
+	if err != nil {
+		return err
+	}
+
+	cm := &corev1.ConfigMap{}
+
+	key := types.NamespacedName{Namespace: hr.GetNamespace(), Name: chartMetadataName(hr)}
+
+	err = r.GetClient().Get(context.TODO(), key, cm)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+
+	exists := err == nil
+
+	cm.SetName(chartMetadataName(hr))
+	cm.SetNamespace(hr.GetNamespace())
+	cm.SetLabels(map[string]string{
+		release.HelmReleaseNameLabel:      hr.GetName(),
+		release.HelmReleaseNamespaceLabel: hr.GetNamespace(),
+	})
+
+	if err := controllerutil.SetControllerReference(hr, cm, r.GetScheme()); err != nil {
+		return err
+	}
+
+	if !exists {
+		cm.Data = data
+
+		return r.GetClient().Create(context.TODO(), cm)
+	}
+
+	if reflect.DeepEqual(cm.Data, data) {
+		return nil
+	}
+
+	cm.Data = data
+
+	return r.GetClient().Update(context.TODO(), cm)
+}
+
+// chartMetadataData returns the keys of the chart metadata ConfigMap:
+// Chart.yaml, README.md and values.yaml, the default values as written in the
+// chart, comments included. The values, then the README, are left out if the
+// ConfigMap would exceed maxChartMetadataSize with them.
+func chartMetadataData(chrt *cpb.Chart) (map[string]string, error) {
+	metadata, err := yaml.Marshal(chrt.Metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	data := map[string]string{chartutil.ChartfileName: string(metadata)}
+	size := len(metadata)
+
+	var values, readme string
+
+	for _, f := range chrt.Raw {
+		if f.Name == chartutil.ValuesfileName {
+			values = string(f.Data)
+		}
+	}
+
+	for _, f := range chrt.Files {
+		if strings.EqualFold(f.Name, "README.md") || strings.EqualFold(f.Name, "README") ||
+			strings.EqualFold(f.Name, "README.txt") {
+			readme = string(f.Data)
+		}
+	}
+
+	for _, f := range []struct{ key, content string }{{chartutil.ValuesfileName, values}, {"README.md", readme}} {
+		if f.content == "" || size+len(f.content) > maxChartMetadataSize {
+			continue
+		}
+
+		data[f.key] = f.content
+		size += len(f.content)
+	}
+
+	return data, nil
+}
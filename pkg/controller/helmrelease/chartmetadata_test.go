@@ -0,0 +1,105 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helmrelease
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/onsi/gomega"
+	cpb "helm.sh/helm/v3/pkg/chart"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+	"github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/release"
+)
+
+// chartManager is a release manager of a fixed chart.
+type chartManager struct {
+	release.Manager
+	chart *cpb.Chart
+}
+
+func (m chartManager) Chart() *cpb.Chart {
+	return m.chart
+}
+
+func TestSyncChartMetadata(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	hr := &appv1.HelmRelease{ObjectMeta: metav1.ObjectMeta{Name: "webapp", Namespace: "default", UID: "webapp-uid"}}
+	hr.SetGroupVersionKind(appv1.SchemeGroupVersion.WithKind("HelmRelease"))
+
+	c := fake.NewFakeClientWithScheme(scheme.Scheme)
+	r := &ReconcileHelmRelease{clientManager{client: c, recorder: record.NewFakeRecorder(10)}}
+
+	manager := chartManager{chart: &cpb.Chart{
+		Metadata: &cpb.Metadata{Name: "webapp", Version: "1.0.0", AppVersion: "1.19", Description: "A web application"},
+		Raw:      []*cpb.File{{Name: "values.yaml", Data: []byte("# replicas\nreplicaCount: 1\n")}},
+		Files:    []*cpb.File{{Name: "README.md", Data: []byte("# webapp\n")}},
+	}}
+
+	// the status always lists the metadata of the chart
+	r.syncChartMetadata(hr, manager)
+	g.Expect(hr.Status.ChartMetadata.Name).To(gomega.Equal("webapp"))
+	g.Expect(hr.Status.ChartMetadata.AppVersion).To(gomega.Equal("1.19"))
+	g.Expect(hr.Status.ChartMetadata.Digest).To(gomega.Equal(release.ChartDigest(manager.chart)))
+	g.Expect(hr.Status.ChartMetadata.ConfigMap).To(gomega.BeEmpty())
+
+	// and it is published with the README and the default values on request
+	hr.Repo.PublishChartMetadata = true
+	r.syncChartMetadata(hr, manager)
+	g.Expect(hr.Status.ChartMetadata.ConfigMap).To(gomega.Equal("webapp-chart-metadata"))
+
+	key := types.NamespacedName{Namespace: "default", Name: "webapp-chart-metadata"}
+
+	cm := &corev1.ConfigMap{}
+	g.Expect(c.Get(context.TODO(), key, cm)).To(gomega.Succeed())
+	g.Expect(metav1.IsControlledBy(cm, hr)).To(gomega.BeTrue())
+	g.Expect(cm.Data["Chart.yaml"]).To(gomega.ContainSubstring("description: A web application"))
+	g.Expect(cm.Data["values.yaml"]).To(gomega.Equal("# replicas\nreplicaCount: 1\n"))
+	g.Expect(cm.Data["README.md"]).To(gomega.Equal("# webapp\n"))
+
+	// the ConfigMap is deleted once the publication is disabled
+	hr.Repo.PublishChartMetadata = false
+	r.syncChartMetadata(hr, manager)
+	g.Expect(hr.Status.ChartMetadata.ConfigMap).To(gomega.BeEmpty())
+	g.Expect(apierrors.IsNotFound(c.Get(context.TODO(), key, &corev1.ConfigMap{}))).To(gomega.BeTrue())
+}
+
+func TestChartMetadataDataSize(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	chrt := &cpb.Chart{
+		Metadata: &cpb.Metadata{Name: "webapp", Version: "1.0.0"},
+		Raw:      []*cpb.File{{Name: "values.yaml", Data: []byte(strings.Repeat("#", maxChartMetadataSize))}},
+		Files:    []*cpb.File{{Name: "README", Data: []byte("# webapp\n")}},
+	}
+
+	// the values that do not fit are left out, not the README
+	data, err := chartMetadataData(chrt)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(data).NotTo(gomega.HaveKey("values.yaml"))
+	g.Expect(data).To(gomega.HaveKeyWithValue("README.md", "# webapp\n"))
+}
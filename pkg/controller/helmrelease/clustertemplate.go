@@ -0,0 +1,171 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helmrelease
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+	"github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/release"
+)
+
+var managedClusterListGVK = schema.GroupVersionKind{
+	Group:   "cluster.open-cluster-management.io",
+	Version: "v1",
+	Kind:    "ManagedClusterList",
+}
+
+// clusterNames are the release name and the target namespace of the release
+// of a HelmRelease on a ManagedCluster
+type clusterNames struct {
+	releaseName string
+	namespace   string
+}
+
+// resolveClusterNames resolves the clusterReleaseName and targetNamespace
+// templates of hr for each of the clusters. The templates get the
+// clusterName, the clusterLabels and the clusterClaims of the cluster, e.g.
+// ingress-{{ .clusterName }} or {{ .clusterClaims.region }}-ingress. The
+// names are the same for all the clusters without templates. The release
+// names longer than helm accepts are shortened with a hash suffix.
+func (r *ReconcileHelmRelease) resolveClusterNames(hr *appv1.HelmRelease, clusters []string) (map[string]clusterNames, error) {
+	names := make(map[string]clusterNames, len(clusters))
+
+	releaseName := hr.Repo.ClusterReleaseName
+	if releaseName == "" {
+		releaseName = hr.Repo.ReleaseName
+	}
+
+	if releaseName == "" {
+		releaseName = hr.GetName()
+	}
+
+	namespace := targetNamespace(hr)
+
+	if !strings.Contains(releaseName, "{{") && !strings.Contains(namespace, "{{") {
+		for _, cluster := range clusters {
+			names[cluster] = clusterNames{releaseName: release.ReleaseNameFor(releaseName), namespace: namespace}
+		}
+
+		return names, nil
+	}
+
+	releaseNameTmpl, err := template.New("clusterReleaseName").Option("missingkey=error").Parse(releaseName)
+	if err != nil {
+		return nil, fmt.Errorf("invalid clusterReleaseName: %w", err)
+	}
+
+	namespaceTmpl, err := template.New("targetNamespace").Option("missingkey=error").Parse(namespace)
+	if err != nil {
+		return nil, fmt.Errorf("invalid targetNamespace: %w", err)
+	}
+
+	data, err := r.clusterTemplateData()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, cluster := range clusters {
+		resolved := clusterNames{}
+
+		if resolved.releaseName, err = executeClusterTemplate(releaseNameTmpl, data[cluster]); err != nil {
+			return nil, fmt.Errorf("failed to resolve the release name of cluster %s: %w", cluster, err)
+		}
+
+		if resolved.namespace, err = executeClusterTemplate(namespaceTmpl, data[cluster]); err != nil {
+			return nil, fmt.Errorf("failed to resolve the target namespace of cluster %s: %w", cluster, err)
+		}
+
+		resolved.releaseName = release.ReleaseNameFor(resolved.releaseName)
+
+		if msgs := validation.IsDNS1123Subdomain(resolved.releaseName); len(msgs) != 0 {
+			return nil, fmt.Errorf("invalid release name %q of cluster %s: %s", resolved.releaseName, cluster, strings.Join(msgs, ", "))
+		}
+
+		if msgs := validation.IsDNS1123Label(resolved.namespace); len(msgs) != 0 {
+			return nil, fmt.Errorf("invalid target namespace %q of cluster %s: %s", resolved.namespace, cluster, strings.Join(msgs, ", "))
+		}
+
+		names[cluster] = resolved
+	}
+
+	return names, nil
+}
+
+// clusterTemplateData returns the data of the templates of each
+// ManagedCluster, by name. The cluster claims are read from the status of the
+// ManagedClusters, they are empty with the registration agents not reporting
+// them.
+func (r *ReconcileHelmRelease) clusterTemplateData() (map[string]map[string]interface{}, error) {
+	list := &unstructured.UnstructuredList{}
+	list.SetGroupVersionKind(managedClusterListGVK)
+
+	if err := r.GetAPIReader().List(context.TODO(), list); err != nil {
+		return nil, fmt.Errorf("failed to list ManagedClusters: %w", err)
+	}
+
+	data := make(map[string]map[string]interface{}, len(list.Items))
+
+	for _, c := range list.Items {
+		claims := make(map[string]string)
+
+		items, _, _ := unstructured.NestedSlice(c.Object, "status", "clusterClaims")
+		for _, item := range items {
+			claim, ok := item.(map[string]interface{})
+			if !ok {
+				continue
+			}
+
+			name, _, _ := unstructured.NestedString(claim, "name")
+			value, _, _ := unstructured.NestedString(claim, "value")
+
+			if name != "" {
+				claims[name] = value
+			}
+		}
+
+		labels := c.GetLabels()
+		if labels == nil {
+			labels = make(map[string]string)
+		}
+
+		data[c.GetName()] = map[string]interface{}{
+			"clusterName":   c.GetName(),
+			"clusterLabels": labels,
+			"clusterClaims": claims,
+		}
+	}
+
+	return data, nil
+}
+
+func executeClusterTemplate(tmpl *template.Template, data map[string]interface{}) (string, error) {
+	var b bytes.Buffer
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", err
+	}
+
+	return b.String(), nil
+}
@@ -0,0 +1,87 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helmrelease
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+	"github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/release"
+)
+
+func TestResolveClusterNames(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	// the ManagedClusters are read unstructured, with the claims of the newer
+	// registration agents
+	s := runtime.NewScheme()
+	s.AddKnownTypeWithName(managedClusterListGVK.GroupVersion().WithKind("ManagedCluster"), &unstructured.Unstructured{})
+	s.AddKnownTypeWithName(managedClusterListGVK, &unstructured.UnstructuredList{})
+
+	newCluster := func(name string, labels map[string]string, claims ...interface{}) runtime.Object {
+		c := &unstructured.Unstructured{Object: map[string]interface{}{
+			"status": map[string]interface{}{"clusterClaims": claims},
+		}}
+		c.SetGroupVersionKind(managedClusterListGVK.GroupVersion().WithKind("ManagedCluster"))
+		c.SetName(name)
+		c.SetLabels(labels)
+
+		return c
+	}
+
+	r := &ReconcileHelmRelease{readerManager{reader: fake.NewFakeClientWithScheme(s,
+		newCluster("cluster1", map[string]string{"tier": "gold"}, map[string]interface{}{"name": "region", "value": "eu"}),
+		newCluster("cluster2", nil))}}
+
+	hr := &appv1.HelmRelease{ObjectMeta: metav1.ObjectMeta{Name: "ingress", Namespace: "default"}}
+	hr.Repo.ClusterSelector = &metav1.LabelSelector{}
+
+	// the same names for all the clusters without templates
+	g.Expect(r.resolveClusterNames(hr, []string{"cluster1", "cluster2"})).To(gomega.Equal(map[string]clusterNames{
+		"cluster1": {releaseName: "ingress", namespace: "default"},
+		"cluster2": {releaseName: "ingress", namespace: "default"},
+	}))
+
+	hr.Repo.ClusterReleaseName = "ingress-{{ .clusterName }}"
+	hr.Repo.TargetNamespace = "{{ .clusterClaims.region }}-{{ .clusterLabels.tier }}"
+	g.Expect(r.resolveClusterNames(hr, []string{"cluster1"})).To(gomega.Equal(map[string]clusterNames{
+		"cluster1": {releaseName: "ingress-cluster1", namespace: "eu-gold"},
+	}))
+
+	// the release names longer than helm accepts are shortened
+	hr.Repo.ClusterReleaseName = "ingress-{{ .clusterName }}-" + strings.Repeat("x", 50)
+	names, err := r.resolveClusterNames(hr, []string{"cluster1"})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(names["cluster1"].releaseName).To(gomega.Equal(release.ReleaseNameFor("ingress-cluster1-" + strings.Repeat("x", 50))))
+	g.Expect(len(names["cluster1"].releaseName)).To(gomega.Equal(release.MaxReleaseNameLength))
+
+	// the clusters must have the claims and labels of the templates
+	_, err = r.resolveClusterNames(hr, []string{"cluster2"})
+	g.Expect(err).To(gomega.MatchError(gomega.ContainSubstring("failed to resolve the target namespace of cluster cluster2")))
+
+	// and the resolved names must be valid
+	hr.Repo.TargetNamespace = "{{ .clusterLabels.tier }}_ns"
+	_, err = r.resolveClusterNames(hr, []string{"cluster1"})
+	g.Expect(err).To(gomega.MatchError(gomega.ContainSubstring(`invalid target namespace "gold_ns" of cluster cluster1`)))
+}
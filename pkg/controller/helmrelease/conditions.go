@@ -0,0 +1,98 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helmrelease
+
+import (
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+// setStandardConditions derives the Released, Stalled and Ready conditions of
+// hr from the conditions set while reconciling it and its failures, and
+// records the generation they were set for. Unlike the other conditions, they follow the Kubernetes
+// API conventions so that generic tooling, e.g.
+// kubectl wait --for=condition=Ready, works.
+func setStandardConditions(hr *appv1.HelmRelease) {
+	status := &hr.Status
+	generation := hr.GetGeneration()
+
+	released := appv1.HelmAppCondition{
+		Type:   appv1.ConditionReleased,
+		Status: appv1.StatusFalse,
+		Reason: appv1.ReasonNotReleased,
+	}
+
+	if failed := status.GetCondition(appv1.ConditionReleaseFailed); failed != nil && failed.Status == appv1.StatusTrue {
+		released.Reason, released.Message = failed.Reason, failed.Message
+	} else if deployed := status.GetCondition(appv1.ConditionDeployed); deployed != nil {
+		released.Status, released.Reason = deployed.Status, deployed.Reason
+	}
+
+	released.ObservedGeneration = generation
+	status.SetCondition(released)
+
+	// the exhausted retries come first, the stalled HelmRelease is skipped
+	// until its spec changes or a reconcile is requested
+	if failuresExhausted(hr) {
+		status.SetCondition(appv1.HelmAppCondition{
+			Type:               appv1.ConditionStalled,
+			Status:             appv1.StatusTrue,
+			Reason:             appv1.ReasonRetriesExhausted,
+			Message:            exhaustedMessage(hr),
+			ObservedGeneration: generation,
+		})
+	} else if irreconcilable := status.GetCondition(appv1.ConditionIrreconcilable); irreconcilable != nil &&
+		irreconcilable.Status == appv1.StatusTrue {
+		status.SetCondition(appv1.HelmAppCondition{
+			Type:               appv1.ConditionStalled,
+			Status:             appv1.StatusTrue,
+			Reason:             irreconcilable.Reason,
+			Message:            irreconcilable.Message,
+			ObservedGeneration: generation,
+		})
+	} else {
+		status.RemoveCondition(appv1.ConditionStalled)
+	}
+
+	ready := appv1.HelmAppCondition{
+		Type:               appv1.ConditionReady,
+		Status:             appv1.StatusTrue,
+		Reason:             appv1.ReasonReconcileSuccessful,
+		ObservedGeneration: generation,
+	}
+
+	// the first condition keeping the release from being ready explains why
+	for _, t := range []appv1.HelmAppConditionType{
+		appv1.ConditionSuspended,
+		appv1.ConditionStalled,
+		appv1.ConditionNameConflict,
+		appv1.ConditionReleaseFailed,
+		appv1.ConditionDependencyNotReady,
+		appv1.ConditionPreconditionsNotMet,
+		appv1.ConditionUpgradePending,
+	} {
+		if c := status.GetCondition(t); c != nil && c.Status == appv1.StatusTrue {
+			ready.Status, ready.Reason, ready.Message = appv1.StatusFalse, c.Reason, c.Message
+			break
+		}
+	}
+
+	if ready.Status == appv1.StatusTrue && released.Status != appv1.StatusTrue {
+		ready.Status, ready.Reason, ready.Message = appv1.StatusFalse, released.Reason, released.Message
+	}
+
+	status.SetCondition(ready)
+}
@@ -0,0 +1,78 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helmrelease
+
+import (
+	"testing"
+
+	"github.com/onsi/gomega"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+func TestSetStandardConditions(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	hr := &appv1.HelmRelease{}
+	hr.SetGeneration(3)
+	hr.Status.SetCondition(appv1.HelmAppCondition{
+		Type:   appv1.ConditionDeployed,
+		Status: appv1.StatusTrue,
+		Reason: appv1.ReasonInstallSuccessful,
+	})
+
+	setStandardConditions(hr)
+
+	released := hr.Status.GetCondition(appv1.ConditionReleased)
+	g.Expect(released.Status).To(gomega.Equal(appv1.StatusTrue))
+	g.Expect(released.Reason).To(gomega.Equal(appv1.ReasonInstallSuccessful))
+	g.Expect(released.ObservedGeneration).To(gomega.Equal(int64(3)))
+
+	ready := hr.Status.GetCondition(appv1.ConditionReady)
+	g.Expect(ready.Status).To(gomega.Equal(appv1.StatusTrue))
+	g.Expect(ready.Reason).To(gomega.Equal(appv1.ReasonReconcileSuccessful))
+	g.Expect(hr.Status.GetCondition(appv1.ConditionStalled)).To(gomega.BeNil())
+
+	// a failed upgrade keeps the release from being ready
+	hr.Status.SetCondition(appv1.HelmAppCondition{
+		Type:    appv1.ConditionReleaseFailed,
+		Status:  appv1.StatusTrue,
+		Reason:  appv1.ReasonUpgradeError,
+		Message: "upgrade failed",
+	})
+
+	setStandardConditions(hr)
+
+	g.Expect(hr.Status.GetCondition(appv1.ConditionReleased).Status).To(gomega.Equal(appv1.StatusFalse))
+
+	ready = hr.Status.GetCondition(appv1.ConditionReady)
+	g.Expect(ready.Status).To(gomega.Equal(appv1.StatusFalse))
+	g.Expect(ready.Reason).To(gomega.Equal(appv1.ReasonUpgradeError))
+	g.Expect(ready.Message).To(gomega.Equal("upgrade failed"))
+
+	// an irreconcilable release is stalled
+	hr.Status.SetCondition(appv1.HelmAppCondition{
+		Type:   appv1.ConditionIrreconcilable,
+		Status: appv1.StatusTrue,
+		Reason: appv1.ReasonReconcileError,
+	})
+
+	setStandardConditions(hr)
+
+	g.Expect(hr.Status.GetCondition(appv1.ConditionStalled).Status).To(gomega.Equal(appv1.StatusTrue))
+	g.Expect(hr.Status.GetCondition(appv1.ConditionReady).Reason).To(gomega.Equal(appv1.ReasonReconcileError))
+}
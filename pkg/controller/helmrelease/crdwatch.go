@@ -0,0 +1,73 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helmrelease
+
+import (
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/metadata"
+	"k8s.io/client-go/metadata/metadatainformer"
+	"k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+
+	helmclient "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/client"
+)
+
+var crdGVR = schema.GroupVersionResource{Group: "apiextensions.k8s.io", Version: "v1", Resource: "customresourcedefinitions"}
+
+// crdWatcher invalidates the shared discovery cache of the cluster of the
+// operator when a CustomResourceDefinition is created, changed or deleted, so
+// that the releases see the new kinds right away. Only the metadata of the
+// CustomResourceDefinitions is watched.
+type crdWatcher struct {
+	mgr manager.Manager
+}
+
+func (w crdWatcher) Start(stop <-chan struct{}) error {
+	mc, err := metadata.NewForConfig(w.mgr.GetConfig())
+	if err != nil {
+		return err
+	}
+
+	informer := metadatainformer.NewFilteredMetadataInformer(mc, crdGVR, "", 10*time.Minute, cache.Indexers{}, nil)
+
+	invalidate := func() {
+		helmclient.InvalidateDiscovery(w.mgr.GetConfig())
+	}
+
+	informer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(interface{}) { invalidate() },
+		UpdateFunc: func(interface{}, interface{}) { invalidate() },
+		DeleteFunc: func(interface{}) { invalidate() },
+	})
+
+	informer.Informer().Run(stop)
+
+	return nil
+}
+
+// watchCRDs adds the crdWatcher to mgr. The CustomResourceDefinitions are not
+// watched in namespace scoped mode, the shared discovery cache is then only
+// refreshed every MinDiscoveryAge.
+func watchCRDs(mgr manager.Manager) error {
+	if Options.NamespaceScoped {
+		return nil
+	}
+
+	return mgr.Add(crdWatcher{mgr: mgr})
+}
@@ -0,0 +1,93 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helmrelease
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/onsi/gomega"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+
+	helmclient "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/client"
+)
+
+// configManager is a manager of the cluster of a rest config.
+type configManager struct {
+	manager.Manager
+	cfg *rest.Config
+}
+
+func (m configManager) GetConfig() *rest.Config {
+	return m.cfg
+}
+
+func TestCRDWatcher(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	var discoveries int32
+
+	// an API server with a single CustomResourceDefinition
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		switch {
+		case req.URL.Path == "/api":
+			atomic.AddInt32(&discoveries, 1)
+			_, _ = w.Write([]byte(`{"kind":"APIVersions","versions":["v1"]}`))
+		case req.URL.Path == "/api/v1":
+			_, _ = w.Write([]byte(`{"kind":"APIResourceList","groupVersion":"v1","resources":[]}`))
+		case req.URL.Path == "/apis":
+			_, _ = w.Write([]byte(`{"kind":"APIGroupList","groups":[]}`))
+		case req.URL.Query().Get("watch") == "true":
+			// the watch ends right away, without any event
+		default:
+			_, _ = w.Write([]byte(`{"apiVersion":"meta.k8s.io/v1","kind":"PartialObjectMetadataList",` +
+				`"metadata":{"resourceVersion":"1"},"items":[{"apiVersion":"apiextensions.k8s.io/v1",` +
+				`"kind":"CustomResourceDefinition","metadata":{"name":"widgets.example.com","resourceVersion":"1"}}]}`))
+		}
+	}))
+	defer server.Close()
+
+	cfg := &rest.Config{Host: server.URL}
+
+	dc, err := helmclient.SharedDiscoveryClient(cfg)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	// the discovery of the releases is cached
+	_, err = dc.ServerGroups()
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	_, err = dc.ServerGroups()
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(atomic.LoadInt32(&discoveries)).To(gomega.Equal(int32(1)))
+
+	stop := make(chan struct{})
+	defer close(stop)
+
+	go func() { _ = crdWatcher{mgr: configManager{cfg: cfg}}.Start(stop) }()
+
+	// and refreshed once the CustomResourceDefinition is seen, without waiting
+	// for the minimum age of the cache
+	g.Eventually(func() int32 {
+		_, _ = dc.ServerGroups()
+		return atomic.LoadInt32(&discoveries)
+	}, 5*time.Second, 50*time.Millisecond).Should(gomega.BeNumerically(">", 1))
+}
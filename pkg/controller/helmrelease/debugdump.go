@@ -0,0 +1,164 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helmrelease
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ghodss/yaml"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+	"github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/release"
+)
+
+// debugDumpAnnotation makes the next reconcile write the chart metadata, the
+// merged values and the rendered manifest of the release to the
+// <name>-debug-dump ConfigMap, or Secret if set to secret. It is removed once
+// the dump is written.
+const debugDumpAnnotation = "apps.open-cluster-management.io/debug-dump"
+
+// the kinds of objects the debug dump is written to
+const (
+	debugDumpConfigMap = "configmap"
+	debugDumpSecret    = "secret"
+)
+
+// debugDumpRequested returns the kind of object the debug dump of hr is
+// written to, an empty string if none is requested.
+func debugDumpRequested(hr *appv1.HelmRelease) string {
+	value, ok := hr.GetAnnotations()[debugDumpAnnotation]
+	if !ok {
+		return ""
+	}
+
+	if strings.EqualFold(value, debugDumpSecret) {
+		return debugDumpSecret
+	}
+
+	return debugDumpConfigMap
+}
+
+// debugDumpName returns the name of the object the debug dump of hr is
+// written to.
+func debugDumpName(hr *appv1.HelmRelease) string {
+	return hr.GetName() + "-debug-dump"
+}
+
+// writeDebugDump writes the debug dump of the release of hr to the object
+// requested by its annotation, owned by hr, and removes the annotation. A
+// failed dump is recorded as a warning event, it never fails the reconcile.
+func (r *ReconcileHelmRelease) writeDebugDump(hr *appv1.HelmRelease, manager release.Manager, kind string) {
+	if err := r.dumpRelease(hr, manager, kind); err != nil {
+		logFor(hr).Error(err, "Failed to write the debug dump")
+		r.recordWarning(hr, eventDebugDumpFailed, fmt.Errorf("failed to write the debug dump: %w", err))
+	} else {
+		r.recordEvent(hr, eventDebugDumped, "Wrote the debug dump of release %s to %s %s",
+			manager.ReleaseName(), kind, debugDumpName(hr))
+	}
+
+	patch := client.MergeFrom(hr.DeepCopy())
+
+	annotations := hr.GetAnnotations()
+	delete(annotations, debugDumpAnnotation)
+	hr.SetAnnotations(annotations)
+
+	if err := r.GetClient().Patch(context.TODO(), hr, patch); err != nil {
+		logFor(hr).Error(err, "Failed to remove the debug dump annotation")
+	}
+}
+
+func (r *ReconcileHelmRelease) dumpRelease(hr *appv1.HelmRelease, manager release.Manager, kind string) error {
+	dump, err := manager.DebugDump(context.TODO())
+	if err != nil {
+		return err
+	}
+
+	data, err := debugDumpData(dump)
+	if err != nil {
+		return err
+	}
+
+	meta := metav1.ObjectMeta{
+		Name:      debugDumpName(hr),
+		Namespace: hr.GetNamespace(),
+		Labels: map[string]string{
+			release.HelmReleaseNameLabel:      hr.GetName(),
+			release.HelmReleaseNamespaceLabel: hr.GetNamespace(),
+		},
+		Annotations: map[string]string{
+			"apps.open-cluster-management.io/dumped-at": time.Now().UTC().Format(time.RFC3339),
+		},
+	}
+
+	var obj interface {
+		metav1.Object
+		runtime.Object
+	}
+
+	if kind == debugDumpSecret {
+		obj = &corev1.Secret{ObjectMeta: meta, Type: corev1.SecretTypeOpaque, StringData: data}
+	} else {
+		obj = &corev1.ConfigMap{ObjectMeta: meta, Data: data}
+	}
+
+	if err := controllerutil.SetControllerReference(hr, obj, r.GetScheme()); err != nil {
+		return err
+	}
+
+	err = r.GetClient().Create(context.TODO(), obj)
+	if apierrors.IsAlreadyExists(err) {
+		// the dump of a previous request is replaced
+		return r.GetClient().Update(context.TODO(), obj)
+	}
+
+	return err
+}
+
+// debugDumpData returns the keys of the debug dump: chart.yaml, values.yaml,
+// manifest.yaml and error.txt if the render failed.
+func debugDumpData(dump *release.DebugDump) (map[string]string, error) {
+	chart, err := yaml.Marshal(dump.Chart)
+	if err != nil {
+		return nil, err
+	}
+
+	values, err := yaml.Marshal(dump.Values)
+	if err != nil {
+		return nil, err
+	}
+
+	data := map[string]string{
+		"chart.yaml":    string(chart),
+		"values.yaml":   string(values),
+		"manifest.yaml": fmt.Sprintf("# release %s in namespace %s\n%s", dump.ReleaseName, dump.Namespace, dump.Manifest),
+	}
+
+	if dump.RenderError != "" {
+		data["error.txt"] = dump.RenderError
+	}
+
+	return data, nil
+}
@@ -0,0 +1,100 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helmrelease
+
+import (
+	"context"
+	"testing"
+
+	"github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+	"github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/release"
+)
+
+// dumpManager is a release manager returning a fixed debug dump.
+type dumpManager struct {
+	release.Manager
+	dump *release.DebugDump
+}
+
+func (m dumpManager) ReleaseName() string {
+	return m.dump.ReleaseName
+}
+
+func (m dumpManager) DebugDump(context.Context) (*release.DebugDump, error) {
+	return m.dump, nil
+}
+
+func TestWriteDebugDump(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	hr := &appv1.HelmRelease{ObjectMeta: metav1.ObjectMeta{
+		Name:        "webapp",
+		Namespace:   "default",
+		UID:         "webapp-uid",
+		Annotations: map[string]string{debugDumpAnnotation: ""},
+	}}
+	hr.SetGroupVersionKind(appv1.SchemeGroupVersion.WithKind("HelmRelease"))
+
+	c := fake.NewFakeClientWithScheme(scheme.Scheme, hr.DeepCopy())
+	recorder := record.NewFakeRecorder(10)
+	r := &ReconcileHelmRelease{clientManager{client: c, recorder: recorder}}
+
+	manager := dumpManager{dump: &release.DebugDump{
+		ReleaseName: "webapp",
+		Namespace:   "apps",
+		Values:      map[string]interface{}{"replicaCount": "2"},
+		Manifest:    "kind: ConfigMap\n",
+	}}
+
+	g.Expect(debugDumpRequested(hr)).To(gomega.Equal(debugDumpConfigMap))
+	r.writeDebugDump(hr, manager, debugDumpRequested(hr))
+
+	// the dump is written to a ConfigMap owned by the HelmRelease
+	name := types.NamespacedName{Namespace: "default", Name: "webapp-debug-dump"}
+
+	cm := &corev1.ConfigMap{}
+	g.Expect(c.Get(context.TODO(), name, cm)).To(gomega.Succeed())
+	g.Expect(metav1.IsControlledBy(cm, hr)).To(gomega.BeTrue())
+	g.Expect(cm.Data["values.yaml"]).To(gomega.Equal("replicaCount: \"2\"\n"))
+	g.Expect(cm.Data["manifest.yaml"]).To(gomega.Equal("# release webapp in namespace apps\nkind: ConfigMap\n"))
+	g.Expect(cm.Data).NotTo(gomega.HaveKey("error.txt"))
+	g.Expect(recorder.Events).To(gomega.Receive(gomega.ContainSubstring(eventDebugDumped)))
+
+	// the request is removed once served
+	stored := &appv1.HelmRelease{}
+	g.Expect(c.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: "webapp"}, stored)).To(gomega.Succeed())
+	g.Expect(stored.GetAnnotations()).NotTo(gomega.HaveKey(debugDumpAnnotation))
+
+	// or to a Secret on request, with the render error
+	hr.SetAnnotations(map[string]string{debugDumpAnnotation: "Secret"})
+	manager.dump.RenderError = "template: webapp/templates/cm.yaml: nil pointer"
+
+	g.Expect(debugDumpRequested(hr)).To(gomega.Equal(debugDumpSecret))
+	r.writeDebugDump(hr, manager, debugDumpRequested(hr))
+
+	secret := &corev1.Secret{}
+	g.Expect(c.Get(context.TODO(), name, secret)).To(gomega.Succeed())
+	g.Expect(secret.StringData["error.txt"]).To(gomega.ContainSubstring("nil pointer"))
+}
@@ -0,0 +1,107 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helmrelease
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/ghodss/yaml"
+	"helm.sh/helm/v3/pkg/releaseutil"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+	"github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/release"
+)
+
+// orphanRelease deletes the release records of the deleted hr and leaves its
+// resources running. Their owner references to hr are removed first so that
+// the Kubernetes garbage collector does not delete them with hr.
+func (r *ReconcileHelmRelease) orphanRelease(ctx context.Context, hr *appv1.HelmRelease,
+	manager release.Manager) error {
+	if hr.Status.DeployedRelease != nil && hr.Status.DeployedRelease.Manifest != "" {
+		c, err := r.clusterClient(hr)
+		if err != nil {
+			return err
+		}
+
+		for _, resource := range releaseutil.SplitManifests(hr.Status.DeployedRelease.Manifest) {
+			var u unstructured.Unstructured
+			if err := yaml.Unmarshal([]byte(resource), &u); err != nil {
+				return err
+			}
+
+			if u.GroupVersionKind().Empty() {
+				continue
+			}
+
+			if u.GetNamespace() == "" {
+				u.SetNamespace(targetNamespace(hr))
+			}
+
+			if err := removeOwnerReference(c, &u, hr.GetUID()); err != nil {
+				return fmt.Errorf("failed to remove the owner reference of %s %s/%s: %w",
+					u.GroupVersionKind(), u.GetNamespace(), u.GetName(), err)
+			}
+		}
+	}
+
+	if _, err := manager.OrphanRelease(ctx); err != nil && !errors.Is(err, release.ErrReleaseNotFound) {
+		return err
+	}
+
+	return nil
+}
+
+// removeOwnerReference removes the owner references to owner from the live
+// state of u, if it still exists.
+func removeOwnerReference(c client.Client, u *unstructured.Unstructured, owner types.UID) error {
+	live := &unstructured.Unstructured{}
+	live.SetGroupVersionKind(u.GroupVersionKind())
+
+	err := c.Get(context.TODO(), types.NamespacedName{Namespace: u.GetNamespace(), Name: u.GetName()}, live)
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	refs := live.GetOwnerReferences()
+	kept := make([]metav1.OwnerReference, 0, len(refs))
+
+	for _, ref := range refs {
+		if ref.UID != owner {
+			kept = append(kept, ref)
+		}
+	}
+
+	if len(kept) == len(refs) {
+		return nil
+	}
+
+	patch := client.MergeFrom(live.DeepCopy())
+	live.SetOwnerReferences(kept)
+
+	return c.Patch(context.TODO(), live, patch)
+}
@@ -0,0 +1,92 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helmrelease
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+// dependencyRetryInterval is how often a HelmRelease waiting for its dependencies is checked again
+const dependencyRetryInterval = 30 * time.Second
+
+// checkDependencies returns true if every HelmRelease hr depends on is ready.
+// Otherwise the DependencyNotReady condition of hr names the first one that
+// is not.
+func (r *ReconcileHelmRelease) checkDependencies(hr *appv1.HelmRelease) (bool, error) {
+	for _, dep := range hr.Repo.DependsOn {
+		key := types.NamespacedName{Namespace: dep.Namespace, Name: dep.Name}
+		if key.Namespace == "" {
+			key.Namespace = hr.GetNamespace()
+		}
+
+		dependency := &appv1.HelmRelease{}
+
+		// the dependencies in the namespaces not watched are not cached
+		reader := r.GetAPIReader()
+		if watchesNamespace(key.Namespace) {
+			reader = r.GetClient()
+		}
+
+		err := reader.Get(context.TODO(), key, dependency)
+		if apierrors.IsNotFound(err) {
+			setDependencyNotReady(hr, appv1.ReasonDependencyNotFound, fmt.Sprintf("HelmRelease %s not found", key))
+			return false, nil
+		}
+
+		if err != nil {
+			return false, err
+		}
+
+		if !dependencyReady(dependency) {
+			setDependencyNotReady(hr, appv1.ReasonDependencyNotReady, fmt.Sprintf("HelmRelease %s is not ready", key))
+			return false, nil
+		}
+	}
+
+	hr.Status.RemoveCondition(appv1.ConditionDependencyNotReady)
+
+	return true, nil
+}
+
+// dependencyReady returns true if hr is Ready for its current generation.
+func dependencyReady(hr *appv1.HelmRelease) bool {
+	if hr.GetDeletionTimestamp() != nil {
+		return false
+	}
+
+	ready := hr.Status.GetCondition(appv1.ConditionReady)
+
+	return ready != nil && ready.Status == appv1.StatusTrue && ready.ObservedGeneration == hr.GetGeneration()
+}
+
+func setDependencyNotReady(hr *appv1.HelmRelease, reason appv1.HelmAppConditionReason, message string) {
+	logFor(hr).Info("Waiting for the dependencies", "reason", message)
+
+	hr.Status.SetCondition(appv1.HelmAppCondition{
+		Type:    appv1.ConditionDependencyNotReady,
+		Status:  appv1.StatusTrue,
+		Reason:  reason,
+		Message: message,
+	})
+}
@@ -0,0 +1,63 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helmrelease
+
+import (
+	"testing"
+
+	"github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+func TestDependencyReady(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	newReady := func() *appv1.HelmRelease {
+		hr := &appv1.HelmRelease{}
+		hr.SetGeneration(2)
+		hr.Status.SetCondition(appv1.HelmAppCondition{
+			Type:               appv1.ConditionReady,
+			Status:             appv1.StatusTrue,
+			ObservedGeneration: 2,
+		})
+
+		return hr
+	}
+
+	g.Expect(dependencyReady(newReady())).To(gomega.BeTrue())
+
+	// not reconciled yet
+	g.Expect(dependencyReady(&appv1.HelmRelease{})).To(gomega.BeFalse())
+
+	// not ready
+	hr := newReady()
+	hr.Status.SetCondition(appv1.HelmAppCondition{Type: appv1.ConditionReady, Status: appv1.StatusFalse, ObservedGeneration: 2})
+	g.Expect(dependencyReady(hr)).To(gomega.BeFalse())
+
+	// ready for a previous generation
+	hr = newReady()
+	hr.SetGeneration(3)
+	g.Expect(dependencyReady(hr)).To(gomega.BeFalse())
+
+	// it is being deleted
+	hr = newReady()
+	now := metav1.Now()
+	hr.SetDeletionTimestamp(&now)
+	g.Expect(dependencyReady(hr)).To(gomega.BeFalse())
+}
@@ -0,0 +1,69 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helmrelease
+
+import (
+	rpb "helm.sh/helm/v3/pkg/release"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+	"github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/release"
+)
+
+// deployedRelease returns the status of the deployed release rel, rendered
+// by manager: what is running and since when. The digests let the external
+// tools compare it to another source without rendering the chart.
+func deployedRelease(rel *rpb.Release, manager release.Manager) *appv1.HelmAppRelease {
+	deployed := &appv1.HelmAppRelease{
+		Name:           rel.Name,
+		Manifest:       rel.Manifest,
+		Digest:         manager.ReleaseDigest(),
+		ChartVersion:   chartVersion(rel),
+		Revision:       rel.Version,
+		ManifestDigest: release.ManifestDigest(rel.Manifest),
+		ValuesDigest:   manager.ValuesDigest(),
+	}
+
+	if rel.Chart != nil {
+		deployed.ChartDigest = release.ChartDigest(rel.Chart)
+
+		if rel.Chart.Metadata != nil {
+			deployed.AppVersion = rel.Chart.Metadata.AppVersion
+		}
+	}
+
+	if rel.Info != nil {
+		if !rel.Info.FirstDeployed.IsZero() {
+			deployed.FirstDeployed = &metav1.Time{Time: rel.Info.FirstDeployed.Time}
+		}
+
+		if !rel.Info.LastDeployed.IsZero() {
+			deployed.LastDeployed = &metav1.Time{Time: rel.Info.LastDeployed.Time}
+		}
+	}
+
+	return deployed
+}
+
+// chartVersion returns the version of the chart of rel.
+func chartVersion(rel *rpb.Release) string {
+	if rel == nil || rel.Chart == nil || rel.Chart.Metadata == nil {
+		return ""
+	}
+
+	return rel.Chart.Metadata.Version
+}
@@ -0,0 +1,79 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helmrelease
+
+import (
+	"testing"
+	"time"
+
+	"github.com/onsi/gomega"
+	cpb "helm.sh/helm/v3/pkg/chart"
+	rpb "helm.sh/helm/v3/pkg/release"
+	helmtime "helm.sh/helm/v3/pkg/time"
+
+	"github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/release"
+)
+
+// digestManager is a release manager returning fixed digests.
+type digestManager struct {
+	release.Manager
+}
+
+func (digestManager) ReleaseDigest() string {
+	return "digest"
+}
+
+func (digestManager) ValuesDigest() string {
+	return "values"
+}
+
+func TestDeployedRelease(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	firstDeployed := time.Date(2020, 11, 1, 12, 0, 0, 0, time.UTC)
+	lastDeployed := firstDeployed.Add(time.Hour)
+
+	rel := &rpb.Release{
+		Name:     "webapp",
+		Version:  2,
+		Manifest: "kind: ConfigMap",
+		Chart:    &cpb.Chart{Metadata: &cpb.Metadata{Name: "webapp", Version: "1.0.0", AppVersion: "1.19"}},
+		Info: &rpb.Info{
+			FirstDeployed: helmtime.Time{Time: firstDeployed},
+			LastDeployed:  helmtime.Time{Time: lastDeployed},
+		},
+	}
+
+	deployed := deployedRelease(rel, digestManager{})
+	g.Expect(deployed.Name).To(gomega.Equal("webapp"))
+	g.Expect(deployed.Manifest).To(gomega.Equal("kind: ConfigMap"))
+	g.Expect(deployed.Digest).To(gomega.Equal("digest"))
+	g.Expect(deployed.ValuesDigest).To(gomega.Equal("values"))
+	g.Expect(deployed.ManifestDigest).To(gomega.Equal(release.ManifestDigest("kind: ConfigMap")))
+	g.Expect(deployed.Revision).To(gomega.Equal(2))
+	g.Expect(deployed.ChartVersion).To(gomega.Equal("1.0.0"))
+	g.Expect(deployed.AppVersion).To(gomega.Equal("1.19"))
+	g.Expect(deployed.ChartDigest).To(gomega.Equal(release.ChartDigest(rel.Chart)))
+	g.Expect(deployed.FirstDeployed.Time).To(gomega.Equal(firstDeployed))
+	g.Expect(deployed.LastDeployed.Time).To(gomega.Equal(lastDeployed))
+
+	// a release without chart nor info only reports its revision
+	deployed = deployedRelease(&rpb.Release{Name: "webapp", Version: 1}, digestManager{})
+	g.Expect(deployed.ChartDigest).To(gomega.BeEmpty())
+	g.Expect(deployed.FirstDeployed).To(gomega.BeNil())
+	g.Expect(deployed.LastDeployed).To(gomega.BeNil())
+}
@@ -0,0 +1,101 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helmrelease
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+	helmclient "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/client"
+	"github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/release"
+)
+
+// checkDeprecatedAPIs renders the release of hr and returns its resources
+// using API versions deprecated in the Kubernetes version of the cluster or in
+// the next minor version. The hooks are not rendered and so not checked.
+func (r *ReconcileHelmRelease) checkDeprecatedAPIs(hr *appv1.HelmRelease,
+	manager release.Manager) ([]appv1.HelmAppDeprecatedAPI, error) {
+	if hr.Repo.DeprecatedAPIPolicy == appv1.IgnoreDeprecatedAPIPolicy {
+		return nil, nil
+	}
+
+	cfg, err := r.clusterConfig(hr)
+	if err != nil {
+		return nil, err
+	}
+
+	dc, err := helmclient.SharedDiscoveryClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := dc.ServerVersion()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the Kubernetes version: %w", err)
+	}
+
+	v, err := semver.NewVersion(info.GitVersion)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Kubernetes version %q: %w", info.GitVersion, err)
+	}
+
+	objects, err := manager.Render(context.TODO(), release.RenderOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	return release.DeprecatedAPIs(objects, int(v.Minor())), nil
+}
+
+// removedAPIs returns the findings of API versions removed in the version of
+// the cluster or in the next minor version.
+func removedAPIs(found []appv1.HelmAppDeprecatedAPI) []appv1.HelmAppDeprecatedAPI {
+	var removed []appv1.HelmAppDeprecatedAPI
+
+	for _, d := range found {
+		if d.Removed {
+			removed = append(removed, d)
+		}
+	}
+
+	return removed
+}
+
+// deprecatedAPIsMessage is the message of the resources using deprecated API
+// versions.
+func deprecatedAPIsMessage(found []appv1.HelmAppDeprecatedAPI) string {
+	apis := make([]string, 0, len(found))
+
+	for _, d := range found {
+		api := fmt.Sprintf("%s deprecated in %s", d.Resource, d.DeprecatedIn)
+		if d.Removed {
+			api = fmt.Sprintf("%s removed in %s", d.Resource, d.RemovedIn)
+		}
+
+		if d.Replacement != "" {
+			api += ", use " + d.Replacement
+		}
+
+		apis = append(apis, api)
+	}
+
+	return "the chart deploys resources using deprecated API versions: " + strings.Join(apis, "; ")
+}
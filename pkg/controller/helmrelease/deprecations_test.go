@@ -0,0 +1,52 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helmrelease
+
+import (
+	"testing"
+
+	"github.com/onsi/gomega"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+func TestDeprecatedAPIsMessage(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	found := []appv1.HelmAppDeprecatedAPI{
+		{
+			Resource:     appv1.HelmAppResource{APIVersion: "extensions/v1beta1", Kind: "Deployment", Namespace: "default", Name: "webapp"},
+			DeprecatedIn: "1.9",
+			RemovedIn:    "1.16",
+			Replacement:  "apps/v1",
+			Removed:      true,
+		},
+		{
+			Resource:     appv1.HelmAppResource{APIVersion: "policy/v1beta1", Kind: "PodSecurityPolicy", Name: "restricted"},
+			DeprecatedIn: "1.21",
+			RemovedIn:    "1.25",
+		},
+	}
+
+	// only the removed API versions block the release
+	g.Expect(removedAPIs(found)).To(gomega.Equal(found[:1]))
+	g.Expect(removedAPIs(found[1:])).To(gomega.BeEmpty())
+
+	g.Expect(deprecatedAPIsMessage(found)).To(gomega.Equal("the chart deploys resources using deprecated API versions: " +
+		"extensions/v1beta1/Deployment default/webapp removed in 1.16, use apps/v1; " +
+		"policy/v1beta1/PodSecurityPolicy restricted deprecated in 1.21"))
+}
@@ -0,0 +1,146 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helmrelease
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+// DefaultDrainTimeout is how long the running reconciles are waited for when
+// the operator stops, within the default termination grace period of a pod
+const DefaultDrainTimeout = 25 * time.Second
+
+// inflightReconciles tracks the running reconciles and the helm action each
+// one runs, if any, so that the operator can let them complete before it
+// exits. A helm action killed midway leaves its release pending.
+type inflightReconciles struct {
+	mu       sync.Mutex
+	wg       sync.WaitGroup
+	draining bool
+	actions  map[types.NamespacedName]string
+}
+
+var inflight = &inflightReconciles{actions: make(map[types.NamespacedName]string)}
+
+// begin records the start of the reconcile of name. It returns false once
+// the operator is draining, the reconcile must not start then. The returned
+// function records its end.
+func (t *inflightReconciles) begin(name types.NamespacedName) (func(), bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.draining {
+		return nil, false
+	}
+
+	t.wg.Add(1)
+
+	return func() {
+		t.mu.Lock()
+		delete(t.actions, name)
+		t.mu.Unlock()
+
+		t.wg.Done()
+	}, true
+}
+
+// action records the helm action run by the reconcile of name, e.g.
+// upgrade. The returned function records its end.
+func (t *inflightReconciles) action(name types.NamespacedName, action string) func() {
+	t.mu.Lock()
+	t.actions[name] = action
+	t.mu.Unlock()
+
+	return func() {
+		t.mu.Lock()
+		delete(t.actions, name)
+		t.mu.Unlock()
+	}
+}
+
+// Drain stops the new reconciles and waits up to timeout for the running
+// ones to complete. The HelmReleases whose helm action is still running after
+// the timeout are marked as interrupted in their status, their release is
+// left pending.
+func Drain(c client.Client, timeout time.Duration) {
+	inflight.mu.Lock()
+	inflight.draining = true
+	inflight.mu.Unlock()
+
+	done := make(chan struct{})
+
+	go func() {
+		inflight.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		log.Info("Drained the running reconciles")
+		statusWrites.flushAll(c)
+
+		return
+	case <-time.After(timeout):
+	}
+
+	// the interrupted HelmReleases are marked from their last status
+	statusWrites.flushAll(c)
+
+	inflight.mu.Lock()
+	interrupted := make(map[types.NamespacedName]string, len(inflight.actions))
+
+	for name, action := range inflight.actions {
+		interrupted[name] = action
+	}
+	inflight.mu.Unlock()
+
+	for name, action := range interrupted {
+		log.Info("Interrupting the helm action", "helmrelease", name.String(), "action", action)
+
+		if err := markInterrupted(c, name, action); err != nil {
+			log.Error(err, "Failed to mark the interrupted helm action", "helmrelease", name.String(), "action", action)
+		}
+	}
+}
+
+// markInterrupted reports the interrupted helm action in the status of the
+// HelmRelease name.
+func markInterrupted(c client.Client, name types.NamespacedName, action string) error {
+	hr := &appv1.HelmRelease{}
+	if err := c.Get(context.TODO(), name, hr); err != nil {
+		return err
+	}
+
+	hr.Status.SetCondition(appv1.HelmAppCondition{
+		Type:   appv1.ConditionReleaseFailed,
+		Status: appv1.StatusTrue,
+		Reason: appv1.ReasonActionInterrupted,
+		Message: fmt.Sprintf("The operator stopped during the %s of the release, it is recovered with the "+
+			"pendingReleasePolicy", action),
+	})
+	setStandardConditions(hr)
+
+	return c.Status().Update(context.TODO(), hr)
+}
@@ -0,0 +1,78 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helmrelease
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+func TestDrain(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	defer func(i *inflightReconciles) { inflight = i }(inflight)
+
+	inflight = &inflightReconciles{actions: make(map[types.NamespacedName]string)}
+
+	name := types.NamespacedName{Namespace: "default", Name: "drain"}
+	c := fake.NewFakeClientWithScheme(scheme.Scheme, &appv1.HelmRelease{
+		ObjectMeta: metav1.ObjectMeta{Namespace: name.Namespace, Name: name.Name},
+	})
+
+	end, ok := inflight.begin(name)
+	g.Expect(ok).To(gomega.BeTrue())
+
+	defer end()
+
+	inflight.action(name, "upgrade")
+
+	// the upgrade still running after the timeout is reported as interrupted
+	Drain(c, 10*time.Millisecond)
+
+	hr := &appv1.HelmRelease{}
+	g.Expect(c.Get(context.TODO(), name, hr)).To(gomega.Succeed())
+
+	failed := hr.Status.GetCondition(appv1.ConditionReleaseFailed)
+	g.Expect(failed).NotTo(gomega.BeNil())
+	g.Expect(failed.Reason).To(gomega.Equal(appv1.ReasonActionInterrupted))
+	g.Expect(failed.Message).To(gomega.ContainSubstring("during the upgrade"))
+
+	// and no reconcile starts any more
+	_, ok = inflight.begin(types.NamespacedName{Namespace: "default", Name: "other"})
+	g.Expect(ok).To(gomega.BeFalse())
+}
+
+func TestDrainCompleted(t *testing.T) {
+	defer func(i *inflightReconciles) { inflight = i }(inflight)
+
+	inflight = &inflightReconciles{actions: make(map[types.NamespacedName]string)}
+
+	end, _ := inflight.begin(types.NamespacedName{Namespace: "default", Name: "drain"})
+	time.AfterFunc(10*time.Millisecond, end)
+
+	// the reconciles completed in time are not reported
+	Drain(nil, time.Minute)
+}
@@ -0,0 +1,69 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helmrelease
+
+import (
+	"context"
+	"strings"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+	"github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/release"
+)
+
+// checkDrift sets the Drifted condition of hr from the live state of the
+// deployed resources. The drifted resources are re-applied first when drift
+// remediation is enabled.
+func checkDrift(hr *appv1.HelmRelease, manager release.Manager) {
+	reason := appv1.ReasonDriftDetected
+	message := "Resources drifted from the deployed release: "
+
+	var drifted []appv1.HelmAppResource
+
+	var err error
+
+	if hr.Repo.DriftRemediation {
+		reason = appv1.ReasonDriftRemediated
+		message = "Re-applied resources drifted from the deployed release: "
+		drifted, err = manager.RemediateDrift(context.TODO())
+	} else {
+		drifted, err = manager.DetectDrift(context.TODO())
+	}
+
+	if err != nil {
+		logFor(hr).Error(err, "Failed to check drift")
+		return
+	}
+
+	if len(drifted) == 0 {
+		hr.Status.RemoveCondition(appv1.ConditionDrifted)
+		return
+	}
+
+	names := make([]string, 0, len(drifted))
+	for _, d := range drifted {
+		names = append(names, d.String())
+	}
+
+	logFor(hr).Info(strings.TrimSuffix(message, ": "), "resources", names)
+
+	hr.Status.SetCondition(appv1.HelmAppCondition{
+		Type:    appv1.ConditionDrifted,
+		Status:  appv1.StatusTrue,
+		Reason:  reason,
+		Message: message + strings.Join(names, ", "),
+	})
+}
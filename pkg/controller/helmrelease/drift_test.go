@@ -0,0 +1,73 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helmrelease
+
+import (
+	"context"
+	"testing"
+
+	"github.com/onsi/gomega"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+	"github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/release"
+)
+
+// driftManager is a release manager whose deployed resources drifted.
+type driftManager struct {
+	release.Manager
+
+	drifted    []appv1.HelmAppResource
+	remediated bool
+}
+
+func (m *driftManager) DetectDrift(ctx context.Context) ([]appv1.HelmAppResource, error) {
+	return m.drifted, nil
+}
+
+func (m *driftManager) RemediateDrift(ctx context.Context) ([]appv1.HelmAppResource, error) {
+	m.remediated = true
+	return m.drifted, nil
+}
+
+func TestCheckDrift(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	hr := &appv1.HelmRelease{}
+	config := appv1.HelmAppResource{APIVersion: "v1", Kind: "ConfigMap", Namespace: "default", Name: "config"}
+	m := &driftManager{drifted: []appv1.HelmAppResource{config}}
+
+	checkDrift(hr, m)
+	g.Expect(m.remediated).To(gomega.BeFalse())
+
+	g.Expect(hr.Status.Conditions).To(gomega.HaveLen(1))
+
+	drifted := hr.Status.Conditions[0]
+	g.Expect(drifted.Type).To(gomega.Equal(appv1.ConditionDrifted))
+	g.Expect(drifted.Reason).To(gomega.Equal(appv1.ReasonDriftDetected))
+	g.Expect(drifted.Message).To(gomega.ContainSubstring(config.String()))
+
+	// and remediated on request
+	hr.Repo.DriftRemediation = true
+	checkDrift(hr, m)
+	g.Expect(m.remediated).To(gomega.BeTrue())
+	g.Expect(hr.Status.Conditions[0].Reason).To(gomega.Equal(appv1.ReasonDriftRemediated))
+
+	// the condition is removed once nothing drifted
+	m.drifted = nil
+	checkDrift(hr, m)
+	g.Expect(hr.Status.Conditions).To(gomega.BeEmpty())
+}
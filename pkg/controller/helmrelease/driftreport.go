@@ -0,0 +1,235 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helmrelease
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+// defaultDriftReportInterval is how often a drift report is produced when it
+// does not set its interval
+const defaultDriftReportInterval = time.Hour
+
+// addDriftReports adds the controller producing the HelmReleaseDriftReports to
+// mgr, if they are installed.
+func addDriftReports(mgr manager.Manager) error {
+	gvk := appv1.SchemeGroupVersion.WithKind("HelmReleaseDriftReport")
+
+	_, err := mgr.GetRESTMapper().RESTMapping(gvk.GroupKind(), gvk.Version)
+	if meta.IsNoMatchError(err) {
+		log.Info("HelmReleaseDriftReports are not installed, no drift report is produced")
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	c, err := controller.New("helmreleasedriftreport-controller", mgr, controller.Options{Reconciler: &driftReporter{mgr}})
+	if err != nil {
+		return err
+	}
+
+	// the reports are sharded by their labels like the HelmReleases
+	return c.Watch(&source.Kind{Type: &appv1.HelmReleaseDriftReport{}}, &handler.EnqueueRequestForObject{},
+		shardPredicate{})
+}
+
+// driftReporter produces the HelmReleaseDriftReports at their interval
+type driftReporter struct {
+	manager.Manager
+}
+
+func (r *driftReporter) Reconcile(request reconcile.Request) (reconcile.Result, error) {
+	report := &appv1.HelmReleaseDriftReport{}
+
+	if err := r.GetClient().Get(context.TODO(), request.NamespacedName, report); err != nil {
+		if apierrors.IsNotFound(err) {
+			return reconcile.Result{}, nil
+		}
+
+		return reconcile.Result{}, err
+	}
+
+	interval := defaultDriftReportInterval
+	if report.Spec.Interval != nil && report.Spec.Interval.Duration > 0 {
+		interval = report.Spec.Interval.Duration
+	}
+
+	// an updated spec is reported right away
+	if last := report.Status.LastReportTime; last != nil && report.Status.ObservedGeneration == report.GetGeneration() {
+		if wait := time.Until(last.Add(interval)); wait > 0 {
+			return reconcile.Result{RequeueAfter: wait}, nil
+		}
+	}
+
+	now := metav1.Now()
+	report.Status = appv1.HelmReleaseDriftReportStatus{
+		LastReportTime:     &now,
+		ObservedGeneration: report.GetGeneration(),
+	}
+
+	hrs, err := r.reportedReleases(report)
+	if err != nil {
+		log.Error(err, "Failed to list the HelmReleases of drift report", "driftreport", request.NamespacedName.String())
+		report.Status.Error = err.Error()
+	} else {
+		summarizeDrift(&report.Status, hrs)
+	}
+
+	if err := r.GetClient().Status().Update(context.TODO(), report); err != nil {
+		return reconcile.Result{}, err
+	}
+
+	log.V(1).Info("Produced drift report", "driftreport", request.NamespacedName.String(),
+		"driftedReleases", report.Status.DriftedReleases, "releases", report.Status.Releases)
+
+	return reconcile.Result{RequeueAfter: interval}, nil
+}
+
+// reportedReleases returns the HelmReleases summarized by report.
+func (r *driftReporter) reportedReleases(report *appv1.HelmReleaseDriftReport) ([]appv1.HelmRelease, error) {
+	opts := []client.ListOption{client.InNamespace(report.GetNamespace())}
+
+	if report.Spec.Selector != nil {
+		selector, err := metav1.LabelSelectorAsSelector(report.Spec.Selector)
+		if err != nil {
+			return nil, err
+		}
+
+		opts = append(opts, client.MatchingLabelsSelector{Selector: selector})
+	}
+
+	hrs := &appv1.HelmReleaseList{}
+	if err := r.GetClient().List(context.TODO(), hrs, opts...); err != nil {
+		return nil, err
+	}
+
+	return hrs.Items, nil
+}
+
+// summarizeDrift sets the per-cluster summary of the releases of hrs in
+// status, from their conditions and the state of their ManifestWorks.
+func summarizeDrift(status *appv1.HelmReleaseDriftReportStatus, hrs []appv1.HelmRelease) {
+	clusters := make(map[string]*appv1.HelmReleaseClusterDrift)
+
+	add := func(cluster string, drift *appv1.HelmReleaseDrift) {
+		summary, ok := clusters[cluster]
+		if !ok {
+			summary = &appv1.HelmReleaseClusterDrift{Cluster: cluster}
+			clusters[cluster] = summary
+		}
+
+		summary.Releases++
+		status.Releases++
+
+		if drift != nil {
+			summary.Drifted = append(summary.Drifted, *drift)
+			status.DriftedReleases++
+		}
+	}
+
+	for i := range hrs {
+		hr := &hrs[i]
+		local := releaseDrift(hr)
+
+		// a HelmRelease failing before selecting its clusters is reported on the hub
+		if !hubMode(hr) || (len(hr.Status.Clusters) == 0 && local != nil) {
+			add("", local)
+			continue
+		}
+
+		// the failures of the hub, e.g. a chart failing to render, keep every
+		// cluster from getting the desired release
+		for _, s := range hr.Status.Clusters {
+			drift := clusterDrift(hr, s)
+			if local != nil && local.Reason == appv1.FailedDriftReason {
+				drift = local
+			}
+
+			add(s.Cluster, drift)
+		}
+	}
+
+	for _, summary := range clusters {
+		status.Clusters = append(status.Clusters, *summary)
+	}
+
+	sort.Slice(status.Clusters, func(i, j int) bool {
+		return status.Clusters[i].Cluster < status.Clusters[j].Cluster
+	})
+}
+
+// releaseDrift returns why the release of hr differs from it, nil if it does
+// not.
+func releaseDrift(hr *appv1.HelmRelease) *appv1.HelmReleaseDrift {
+	for _, check := range []struct {
+		condition appv1.HelmAppConditionType
+		reason    appv1.DriftReasonEnum
+	}{
+		{appv1.ConditionReleaseFailed, appv1.FailedDriftReason},
+		{appv1.ConditionStalled, appv1.FailedDriftReason},
+		{appv1.ConditionDrifted, appv1.ResourcesDriftReason},
+		{appv1.ConditionUpgradePending, appv1.OutOfDateDriftReason},
+	} {
+		if c := hr.Status.GetCondition(check.condition); c != nil && c.Status == appv1.StatusTrue {
+			return &appv1.HelmReleaseDrift{HelmRelease: hr.GetName(), Reason: check.reason, Message: c.Message}
+		}
+	}
+
+	if hr.Status.ObservedGeneration != 0 && hr.Status.ObservedGeneration < hr.GetGeneration() {
+		return &appv1.HelmReleaseDrift{
+			HelmRelease: hr.GetName(),
+			Reason:      appv1.OutOfDateDriftReason,
+			Message:     "The latest spec of the HelmRelease is not reconciled yet",
+		}
+	}
+
+	return nil
+}
+
+// clusterDrift returns why the release of hr on a ManagedCluster differs from
+// it, nil if it does not.
+func clusterDrift(hr *appv1.HelmRelease, s appv1.HelmAppClusterStatus) *appv1.HelmReleaseDrift {
+	if failure := workFailure(s); failure != "" {
+		return &appv1.HelmReleaseDrift{HelmRelease: hr.GetName(), Reason: appv1.FailedDriftReason, Message: failure}
+	}
+
+	if s.OutOfDate {
+		return &appv1.HelmReleaseDrift{
+			HelmRelease: hr.GetName(),
+			Reason:      appv1.OutOfDateDriftReason,
+			Message:     "The ManifestWork does not carry the desired release yet",
+		}
+	}
+
+	return nil
+}
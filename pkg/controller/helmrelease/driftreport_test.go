@@ -0,0 +1,94 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helmrelease
+
+import (
+	"testing"
+
+	"github.com/onsi/gomega"
+	workv1 "github.com/open-cluster-management/api/work/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+func newDriftedHelmRelease(name string, conditions ...appv1.HelmAppCondition) appv1.HelmRelease {
+	hr := appv1.HelmRelease{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"}}
+	hr.Status.Conditions = conditions
+
+	return hr
+}
+
+func TestSummarizeDrift(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	current := newDriftedHelmRelease("current")
+	failed := newDriftedHelmRelease("failed", appv1.HelmAppCondition{
+		Type:    appv1.ConditionReleaseFailed,
+		Status:  appv1.StatusTrue,
+		Message: "install failed",
+	})
+	outdated := newDriftedHelmRelease("outdated")
+	outdated.SetGeneration(2)
+	outdated.Status.ObservedGeneration = 1
+
+	hub := newDriftedHelmRelease("hub")
+	hub.Repo.ClusterSelector = &metav1.LabelSelector{}
+	hub.Status.Clusters = []appv1.HelmAppClusterStatus{
+		{Cluster: "cluster2", OutOfDate: true},
+		{Cluster: "cluster1", Available: true},
+		{Cluster: "cluster3", Conditions: []appv1.HelmAppCondition{{
+			Type:    appv1.HelmAppConditionType(workv1.WorkApplied),
+			Status:  appv1.StatusFalse,
+			Message: "forbidden",
+		}}},
+	}
+
+	status := &appv1.HelmReleaseDriftReportStatus{}
+	summarizeDrift(status, []appv1.HelmRelease{current, failed, outdated, hub})
+
+	g.Expect(status.Releases).To(gomega.Equal(6))
+	g.Expect(status.DriftedReleases).To(gomega.Equal(4))
+	g.Expect(status.Clusters).To(gomega.Equal([]appv1.HelmReleaseClusterDrift{
+		{Releases: 3, Drifted: []appv1.HelmReleaseDrift{
+			{HelmRelease: "failed", Reason: appv1.FailedDriftReason, Message: "install failed"},
+			{HelmRelease: "outdated", Reason: appv1.OutOfDateDriftReason,
+				Message: "The latest spec of the HelmRelease is not reconciled yet"},
+		}},
+		{Cluster: "cluster1", Releases: 1},
+		{Cluster: "cluster2", Releases: 1, Drifted: []appv1.HelmReleaseDrift{
+			{HelmRelease: "hub", Reason: appv1.OutOfDateDriftReason,
+				Message: "The ManifestWork does not carry the desired release yet"},
+		}},
+		{Cluster: "cluster3", Releases: 1, Drifted: []appv1.HelmReleaseDrift{
+			{HelmRelease: "hub", Reason: appv1.FailedDriftReason, Message: "forbidden"},
+		}},
+	}))
+
+	// a failure of the hub is reported on each of its clusters
+	hub.Status.Conditions = failed.Status.Conditions
+	status = &appv1.HelmReleaseDriftReportStatus{}
+	summarizeDrift(status, []appv1.HelmRelease{hub})
+
+	g.Expect(status.DriftedReleases).To(gomega.Equal(3))
+
+	for _, c := range status.Clusters {
+		g.Expect(c.Drifted).To(gomega.ConsistOf(appv1.HelmReleaseDrift{
+			HelmRelease: "hub", Reason: appv1.FailedDriftReason, Message: "install failed",
+		}))
+	}
+}
@@ -0,0 +1,145 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helmrelease
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+	"github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/release"
+)
+
+// eventSource is the component recorded in the events of the HelmReleases
+const eventSource = "helmrelease-controller"
+
+// reasons of the events recorded on the HelmReleases
+const (
+	eventInstallStarted       = "InstallStarted"
+	eventInstallSucceeded     = "InstallSucceeded"
+	eventInstallFailed        = "InstallFailed"
+	eventUpgradeSucceeded     = "UpgradeSucceeded"
+	eventUpgradeFailed        = "UpgradeFailed"
+	eventRollbackSucceeded    = "RollbackSucceeded"
+	eventRollbackFailed       = "RollbackFailed"
+	eventUninstallSucceeded   = "UninstallSucceeded"
+	eventUninstallFailed      = "UninstallFailed"
+	eventReleaseOrphaned      = "ReleaseOrphaned"
+	eventChartDownloadFailed  = "ChartDownloadFailed"
+	eventForceDeleted         = "ForceDeleted"
+	eventNameConflict         = "NameConflict"
+	eventPreconditionsNotMet  = "PreconditionsNotMet"
+	eventClusterReplaced      = "ClusterReplaced"
+	eventPodSecurityWarning   = "PodSecurityWarning"
+	eventDeprecatedAPIWarning = "DeprecatedAPIWarning"
+	eventPolicyWarning        = "PolicyWarning"
+	eventSnapshotRestored     = "SnapshotRestored"
+	eventDebugDumped          = "DebugDumped"
+	eventDebugDumpFailed      = "DebugDumpFailed"
+)
+
+// failureReasonAnnotation annotates the warning events with the failure reason
+// of the condition of the HelmRelease, e.g. RenderError, when it is known
+const failureReasonAnnotation = "apps.open-cluster-management.io/failure-reason"
+
+// warningDedupWindow is how long a warning is not recorded again on the same
+// HelmRelease with the same reason and message
+const warningDedupWindow = 10 * time.Minute
+
+type warningKey struct {
+	uid     types.UID
+	reason  string
+	message string
+}
+
+var (
+	warningsMu sync.Mutex
+	// warnings are the times the warnings were last recorded
+	warnings = make(map[warningKey]time.Time)
+)
+
+// recordEvent records a Normal event on hr, its secret-shaped data redacted.
+func (r ReconcileHelmRelease) recordEvent(hr *appv1.HelmRelease, reason, messageFmt string, args ...interface{}) {
+	message := release.RedactMessage(fmt.Sprintf(messageFmt, args...))
+	r.GetEventRecorderFor(eventSource).Event(hr, corev1.EventTypeNormal, reason, message)
+	notify(hr, corev1.EventTypeNormal, reason, message)
+}
+
+// recordWarning records a Warning event on hr, its secret-shaped data redacted.
+// A failure repeated by each retry is recorded once per warningDedupWindow, the
+// event recorder aggregates the repeats into the count of the same Event.
+func (r ReconcileHelmRelease) recordWarning(hr *appv1.HelmRelease, reason string, err error) {
+	message := release.RedactMessage(err.Error())
+
+	if !shouldRecordWarning(warningKey{uid: hr.GetUID(), reason: reason, message: message}, time.Now()) {
+		logFor(hr).V(1).Info("Skipping the repeated warning event", "reason", reason)
+		return
+	}
+
+	var annotations map[string]string
+	if class := failureReason(err, ""); class != "" {
+		annotations = map[string]string{failureReasonAnnotation: string(class)}
+	}
+
+	r.GetEventRecorderFor(eventSource).AnnotatedEventf(hr, annotations, corev1.EventTypeWarning, reason, "%s", message)
+	notify(hr, corev1.EventTypeWarning, reason, message)
+}
+
+// shouldRecordWarning returns false if the warning key was recorded less than
+// warningDedupWindow before now.
+func shouldRecordWarning(key warningKey, now time.Time) bool {
+	warningsMu.Lock()
+	defer warningsMu.Unlock()
+
+	if last, ok := warnings[key]; ok && now.Sub(last) < warningDedupWindow {
+		return false
+	}
+
+	// the warnings of the deleted HelmReleases are forgotten with the others
+	for k, last := range warnings {
+		if now.Sub(last) >= warningDedupWindow {
+			delete(warnings, k)
+		}
+	}
+
+	warnings[key] = now
+
+	return true
+}
+
+// recordUpgradeFailure records the failed upgrade of hr and the outcome of
+// the rollback to the previous revision, if any.
+func (r ReconcileHelmRelease) recordUpgradeFailure(hr *appv1.HelmRelease, err error) {
+	r.recordWarning(hr, eventUpgradeFailed, err)
+
+	var upgradeErr *release.ErrUpgradeFailed
+	if !errors.As(err, &upgradeErr) {
+		return
+	}
+
+	switch {
+	case upgradeErr.RollbackErr != nil:
+		r.recordWarning(hr, eventRollbackFailed, upgradeErr.RollbackErr)
+	case upgradeErr.RolledBack:
+		r.recordEvent(hr, eventRollbackSucceeded, "Rolled back to the previous revision after the failed upgrade")
+	}
+}
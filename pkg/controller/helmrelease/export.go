@@ -0,0 +1,466 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helmrelease
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ghodss/yaml"
+	git "gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	githttp "gopkg.in/src-d/go-git.v4/plumbing/transport/http"
+	rpb "helm.sh/helm/v3/pkg/release"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+	"github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/release"
+)
+
+// types of the manifest export sinks
+const (
+	ExportConfigMap = "configmap"
+	ExportS3        = "s3"
+	ExportGit       = "git"
+)
+
+// defaultExportHistory is the number of exported ConfigMaps kept per
+// HelmRelease by default
+const defaultExportHistory = 10
+
+// exportRevisionLabel is the revision of the release exported to a ConfigMap
+const exportRevisionLabel = "apps.open-cluster-management.io/release-revision"
+
+// exportClient uploads the manifests to the S3 sinks
+var exportClient = &http.Client{Timeout: 30 * time.Second}
+
+// gitExportMu serializes the commits to the git sinks, concurrent pushes
+// would be rejected
+var gitExportMu sync.Mutex
+
+// ManifestSink is where the manifests of the deployed releases are exported
+// to, so that they can be reviewed without access to the helm storage
+type ManifestSink struct {
+	// Name identifies the sink in the logs
+	Name string `json:"name"`
+	// Type is configmap, s3 or git
+	Type string `json:"type"`
+	// Namespaces restricts the sink to the HelmReleases of these namespaces, all
+	// the namespaces if empty
+	Namespaces []string `json:"namespaces,omitempty"`
+
+	// Namespace of the configmap sink the ConfigMaps are created in, the
+	// namespace of the HelmRelease if empty
+	Namespace string `json:"namespace,omitempty"`
+	// History is the number of ConfigMaps kept per HelmRelease by the configmap
+	// sink, 10 if 0
+	History int `json:"history,omitempty"`
+
+	// Endpoint of the s3 sink, e.g. https://s3.eu-west-1.amazonaws.com
+	Endpoint string `json:"endpoint,omitempty"`
+	// Bucket of the s3 sink, addressed in the path of the endpoint
+	Bucket string `json:"bucket,omitempty"`
+	// Region of the bucket of the s3 sink
+	Region string `json:"region,omitempty"`
+
+	// URL of the repository of the git sink
+	URL string `json:"url,omitempty"`
+	// Branch of the git sink the manifests are committed to, master if empty
+	Branch string `json:"branch,omitempty"`
+	// Username authenticating to the git sink
+	Username string `json:"username,omitempty"`
+	// PasswordFile is the file of the password or token authenticating to the
+	// git sink, read at each commit
+	PasswordFile string `json:"passwordFile,omitempty"`
+
+	// Prefix of the keys of the s3 sink and of the paths of the git sink
+	Prefix string `json:"prefix,omitempty"`
+}
+
+// manifestExportConfig is the manifest export configuration file
+type manifestExportConfig struct {
+	Sinks []ManifestSink `json:"sinks"`
+}
+
+// LoadManifestSinks reads the manifest export sinks of the configuration file
+// at path.
+func LoadManifestSinks(path string) ([]ManifestSink, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	config := manifestExportConfig{}
+	if err := yaml.Unmarshal(b, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse the manifest export config %s: %w", path, err)
+	}
+
+	for _, sink := range config.Sinks {
+		var missing string
+
+		switch sink.Type {
+		case ExportConfigMap:
+		case ExportS3:
+			if sink.Endpoint == "" || sink.Bucket == "" || sink.Region == "" {
+				missing = "endpoint, bucket or region"
+			}
+		case ExportGit:
+			if sink.URL == "" {
+				missing = "url"
+			}
+		default:
+			return nil, fmt.Errorf("unknown type %q of manifest sink %s, configmap, s3 or git", sink.Type, sink.Name)
+		}
+
+		if missing != "" {
+			return nil, fmt.Errorf("%s manifest sink %s has no %s", sink.Type, sink.Name, missing)
+		}
+	}
+
+	return config.Sinks, nil
+}
+
+// exportManifest exports the manifest of the release rel deployed by hr to
+// the sinks it matches, in the background: an export is best effort and never
+// fails the reconcile. The Secret data is redacted.
+func (r ReconcileHelmRelease) exportManifest(hr *appv1.HelmRelease, rel *rpb.Release) {
+	if len(Options.ManifestSinks) == 0 || rel == nil {
+		return
+	}
+
+	export := exportedManifest{
+		namespace: hr.GetNamespace(),
+		name:      hr.GetName(),
+		release:   rel.Name,
+		revision:  rel.Version,
+		manifest:  release.RedactManifest(rel.Manifest),
+	}
+
+	if rel.Chart != nil && rel.Chart.Metadata != nil {
+		export.chart = rel.Chart.Metadata.Name
+		export.chartVersion = rel.Chart.Metadata.Version
+	}
+
+	for _, sink := range Options.ManifestSinks {
+		if len(sink.Namespaces) != 0 && !contains(sink.Namespaces, export.namespace) {
+			continue
+		}
+
+		go func(sink ManifestSink) {
+			var err error
+
+			switch sink.Type {
+			case ExportConfigMap:
+				err = sink.exportConfigMap(r.GetClient(), export)
+			case ExportS3:
+				err = sink.exportS3(export)
+			case ExportGit:
+				err = sink.exportGit(export)
+			}
+
+			if err != nil {
+				logFor(hr).Error(err, "Failed to export the manifest", "sink", sink.Name, "revision", export.revision)
+				return
+			}
+
+			logFor(hr).V(1).Info("Exported the manifest", "sink", sink.Name, "revision", export.revision)
+		}(sink)
+	}
+}
+
+// exportedManifest is the manifest of a revision of a release
+type exportedManifest struct {
+	namespace    string
+	name         string
+	release      string
+	revision     int
+	chart        string
+	chartVersion string
+	manifest     string
+}
+
+// document returns the manifest with a header identifying it.
+func (e exportedManifest) document() []byte {
+	return []byte(fmt.Sprintf("# HelmRelease: %s/%s\n# Release: %s\n# Revision: %d\n# Chart: %s-%s\n%s",
+		e.namespace, e.name, e.release, e.revision, e.chart, e.chartVersion, e.manifest))
+}
+
+// exportConfigMap creates the ConfigMap of the revision and deletes the ones
+// of the revisions beyond the history of the sink.
+func (s ManifestSink) exportConfigMap(c client.Client, e exportedManifest) error {
+	namespace := s.Namespace
+	if namespace == "" {
+		namespace = e.namespace
+	}
+
+	labels := client.MatchingLabels{
+		release.HelmReleaseNameLabel:      e.name,
+		release.HelmReleaseNamespaceLabel: e.namespace,
+	}
+
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%s.v%d", e.name, e.revision),
+			Namespace: namespace,
+			Labels: map[string]string{
+				release.HelmReleaseNameLabel:      e.name,
+				release.HelmReleaseNamespaceLabel: e.namespace,
+				exportRevisionLabel:               strconv.Itoa(e.revision),
+			},
+			Annotations: map[string]string{
+				"apps.open-cluster-management.io/chart":         e.chart,
+				"apps.open-cluster-management.io/chart-version": e.chartVersion,
+			},
+		},
+		Data: map[string]string{"manifest.yaml": e.manifest},
+	}
+
+	if err := c.Create(context.TODO(), cm); err != nil && !apierrors.IsAlreadyExists(err) {
+		return err
+	}
+
+	history := s.History
+	if history <= 0 {
+		history = defaultExportHistory
+	}
+
+	cms := &corev1.ConfigMapList{}
+	if err := c.List(context.TODO(), cms, client.InNamespace(namespace), labels); err != nil {
+		return err
+	}
+
+	for i := range cms.Items {
+		revision, err := strconv.Atoi(cms.Items[i].Labels[exportRevisionLabel])
+		if err != nil || revision > e.revision-history {
+			continue
+		}
+
+		if err := c.Delete(context.TODO(), &cms.Items[i]); err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// s3Key returns the key of the manifest of the revision in the bucket,
+// <prefix>/<namespace>/<name>/v<revision>.yaml.
+func (s ManifestSink) s3Key(e exportedManifest) string {
+	return path.Join(s.Prefix, e.namespace, e.name, fmt.Sprintf("v%d.yaml", e.revision))
+}
+
+// gitPath returns the path of the manifest of the HelmRelease in the
+// repository, <prefix>/<namespace>/<name>.yaml.
+func (s ManifestSink) gitPath(e exportedManifest) string {
+	return path.Join(s.Prefix, e.namespace, e.name+".yaml")
+}
+
+// exportS3 uploads the manifest of the revision to the bucket of the sink,
+// signed with the AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY of the operator.
+func (s ManifestSink) exportS3(e exportedManifest) error {
+	accessKey, secretKey := os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY")
+	if accessKey == "" || secretKey == "" {
+		return fmt.Errorf("no AWS_ACCESS_KEY_ID or AWS_SECRET_ACCESS_KEY to sign the upload")
+	}
+
+	endpoint, err := url.Parse(strings.TrimSuffix(s.Endpoint, "/"))
+	if err != nil {
+		return fmt.Errorf("invalid endpoint: %w", err)
+	}
+
+	// path-style, the bucket is the first segment of the path
+	endpoint.Path += "/" + path.Join(s.Bucket, s.s3Key(e))
+
+	body := e.document()
+
+	req, err := http.NewRequest(http.MethodPut, endpoint.String(), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/yaml")
+	signS3Request(req, body, s.Region, accessKey, secretKey, os.Getenv("AWS_SESSION_TOKEN"), time.Now().UTC())
+
+	resp, err := exportClient.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		msg, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("upload of %s failed with status %d: %s", s.s3Key(e), resp.StatusCode, msg)
+	}
+
+	return nil
+}
+
+// signS3Request signs req with AWS signature version 4 for the s3 service.
+func signS3Request(req *http.Request, body []byte, region, accessKey, secretKey, sessionToken string, now time.Time) {
+	payloadHash := sha256Hex(body)
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	req.Header.Set("X-Amz-Date", amzDate)
+
+	headers := []string{"host", "x-amz-content-sha256", "x-amz-date"}
+	values := []string{req.URL.Host, payloadHash, amzDate}
+
+	if sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", sessionToken)
+		headers = append(headers, "x-amz-security-token")
+		values = append(values, sessionToken)
+	}
+
+	var canonicalHeaders strings.Builder
+	for i, h := range headers {
+		canonicalHeaders.WriteString(h + ":" + values[i] + "\n")
+	}
+
+	signedHeaders := strings.Join(headers, ";")
+	canonicalRequest := strings.Join([]string{
+		req.Method, req.URL.EscapedPath(), req.URL.RawQuery, canonicalHeaders.String(), signedHeaders, payloadHash,
+	}, "\n")
+
+	scope := date + "/" + region + "/s3/aws4_request"
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, sha256Hex([]byte(canonicalRequest))}, "\n")
+
+	key := []byte("AWS4" + secretKey)
+	for _, part := range []string{date, region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		accessKey, scope, signedHeaders, hex.EncodeToString(hmacSHA256(key, stringToSign))))
+}
+
+func sha256Hex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	_, _ = h.Write([]byte(data))
+
+	return h.Sum(nil)
+}
+
+// exportGit commits the manifest of the HelmRelease to the repository of the
+// sink and pushes it. The file of the HelmRelease is replaced by each
+// revision, so that the history of the repository diffs the revisions.
+func (s ManifestSink) exportGit(e exportedManifest) error {
+	gitExportMu.Lock()
+	defer gitExportMu.Unlock()
+
+	var auth *githttp.BasicAuth
+
+	if s.PasswordFile != "" {
+		password, err := ioutil.ReadFile(s.PasswordFile)
+		if err != nil {
+			return err
+		}
+
+		auth = &githttp.BasicAuth{Username: s.Username, Password: strings.TrimSpace(string(password))}
+	}
+
+	branch := plumbing.Master
+	if s.Branch != "" {
+		branch = plumbing.NewBranchReferenceName(s.Branch)
+	}
+
+	dir, err := ioutil.TempDir("", "manifest-export")
+	if err != nil {
+		return err
+	}
+
+	defer os.RemoveAll(dir)
+
+	options := &git.CloneOptions{URL: s.URL, ReferenceName: branch, SingleBranch: true, Depth: 1}
+	if auth != nil {
+		options.Auth = auth
+	}
+
+	repo, err := git.PlainClone(dir, false, options)
+	if err != nil {
+		return fmt.Errorf("failed to clone %s: %w", s.URL, err)
+	}
+
+	worktree, err := repo.Worktree()
+	if err != nil {
+		return err
+	}
+
+	file := s.gitPath(e)
+	if err := os.MkdirAll(filepath.Join(dir, filepath.Dir(file)), 0750); err != nil {
+		return err
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dir, file), e.document(), 0600); err != nil {
+		return err
+	}
+
+	if _, err := worktree.Add(file); err != nil {
+		return err
+	}
+
+	// the revision was already exported, e.g. by another replica
+	if status, err := worktree.Status(); err == nil && status.IsClean() {
+		return nil
+	}
+
+	_, err = worktree.Commit(fmt.Sprintf("%s/%s: release %s revision %d", e.namespace, e.name, e.release, e.revision),
+		&git.CommitOptions{Author: &object.Signature{
+			Name:  "multicloud-operators-subscription-release",
+			Email: "multicloud-operators-subscription-release@open-cluster-management.io",
+			When:  time.Now(),
+		}})
+	if err != nil {
+		return err
+	}
+
+	pushOptions := &git.PushOptions{}
+	if auth != nil {
+		pushOptions.Auth = auth
+	}
+
+	if err := repo.Push(pushOptions); err != nil {
+		return fmt.Errorf("failed to push to %s: %w", s.URL, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,122 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helmrelease
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestExportConfigMap(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	c := fake.NewFakeClientWithScheme(scheme.Scheme)
+	sink := ManifestSink{Name: "review", Type: ExportConfigMap, History: 2}
+
+	for revision := 1; revision <= 3; revision++ {
+		g.Expect(sink.exportConfigMap(c, exportedManifest{namespace: "default", name: "webapp", release: "webapp",
+			revision: revision, chart: "nginx", chartVersion: "1.0.0", manifest: "kind: ConfigMap"})).To(gomega.Succeed())
+	}
+
+	// the ConfigMaps of the last two revisions are kept
+	cms := &corev1.ConfigMapList{}
+	g.Expect(c.List(context.TODO(), cms, client.InNamespace("default"))).To(gomega.Succeed())
+
+	var names []string
+	for _, cm := range cms.Items {
+		names = append(names, cm.Name)
+	}
+
+	sort.Strings(names)
+	g.Expect(names).To(gomega.Equal([]string{"webapp.v2", "webapp.v3"}))
+	g.Expect(cms.Items[0].Data).To(gomega.HaveKeyWithValue("manifest.yaml", "kind: ConfigMap"))
+}
+
+func TestExportS3(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	uploads := make(chan *http.Request, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = ioutil.ReadAll(r.Body)
+		uploads <- r
+	}))
+	defer server.Close()
+
+	for k, v := range map[string]string{"AWS_ACCESS_KEY_ID": "AKIDEXAMPLE", "AWS_SECRET_ACCESS_KEY": "secret"} {
+		previous, ok := os.LookupEnv(k)
+
+		defer func(k string) {
+			if ok {
+				os.Setenv(k, previous)
+			} else {
+				os.Unsetenv(k)
+			}
+		}(k)
+
+		g.Expect(os.Setenv(k, v)).To(gomega.Succeed())
+	}
+
+	sink := ManifestSink{Name: "archive", Type: ExportS3, Endpoint: server.URL, Bucket: "manifests", Region: "eu-west-1",
+		Prefix: "exports"}
+	g.Expect(sink.exportS3(exportedManifest{namespace: "default", name: "webapp", release: "webapp", revision: 2,
+		manifest: "kind: ConfigMap"})).To(gomega.Succeed())
+
+	// the upload is signed and keyed by the HelmRelease and the revision
+	var upload *http.Request
+
+	g.Expect(uploads).To(gomega.Receive(&upload))
+	g.Expect(upload.Method).To(gomega.Equal(http.MethodPut))
+	g.Expect(upload.URL.Path).To(gomega.Equal("/manifests/exports/default/webapp/v2.yaml"))
+	g.Expect(upload.Header.Get("Authorization")).To(gomega.HavePrefix("AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/"))
+	g.Expect(upload.Header.Get("Authorization")).To(gomega.ContainSubstring("/eu-west-1/s3/aws4_request"))
+}
+
+func TestLoadManifestSinks(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	dir, err := ioutil.TempDir("", "manifest-sinks")
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "sinks.yaml")
+
+	g.Expect(ioutil.WriteFile(path, []byte("sinks:\n- name: review\n  type: configmap\n  history: 3\n"), 0600)).
+		To(gomega.Succeed())
+
+	sinks, err := LoadManifestSinks(path)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(sinks).To(gomega.Equal([]ManifestSink{{Name: "review", Type: ExportConfigMap, History: 3}}))
+
+	g.Expect(ioutil.WriteFile(path, []byte("sinks:\n- name: archive\n  type: s3\n  bucket: manifests\n"), 0600)).
+		To(gomega.Succeed())
+
+	_, err = LoadManifestSinks(path)
+	g.Expect(err).To(gomega.MatchError("s3 manifest sink archive has no endpoint, bucket or region"))
+}
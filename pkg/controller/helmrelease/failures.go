@@ -0,0 +1,86 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helmrelease
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+	"github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/release"
+)
+
+// errChartPull matches the errors of the chart downloads
+var errChartPull = errors.New("chart pull failed")
+
+// chartPullError keeps the error of a chart download while matching errChartPull.
+type chartPullError struct {
+	err error
+}
+
+func (e *chartPullError) Error() string {
+	return e.err.Error()
+}
+
+func (e *chartPullError) Unwrap() error {
+	return e.err
+}
+
+func (e *chartPullError) Is(target error) bool {
+	return target == errChartPull
+}
+
+// failureClass returns the stable reason of the class of failure of err, so
+// that the alerts and the automations do not need to match the messages.
+func failureClass(err error) (appv1.HelmAppConditionReason, bool) {
+	switch {
+	case errors.Is(err, errChartPull):
+		return appv1.ReasonChartPullError, true
+	case forbiddenErr(err):
+		return appv1.ReasonRBACDenied, true
+	case timeoutErr(err):
+		return appv1.ReasonTimeout, true
+	case errors.Is(err, release.ErrStorageFailed):
+		return appv1.ReasonStorageError, true
+	case errors.Is(err, release.ErrRenderFailed):
+		return appv1.ReasonRenderError, true
+	}
+
+	return "", false
+}
+
+// forbiddenErr returns true if err is a forbidden API error. Helm often only
+// keeps the message of the errors of the API server.
+func forbiddenErr(err error) bool {
+	return apierrors.IsForbidden(err) || strings.Contains(err.Error(), " is forbidden: ")
+}
+
+// timeoutErr returns true if err is a timeout, e.g. waiting for the resources
+// of the release to be ready.
+func timeoutErr(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, wait.ErrWaitTimeout) {
+		return true
+	}
+
+	msg := err.Error()
+
+	return strings.Contains(msg, wait.ErrWaitTimeout.Error()) || strings.Contains(msg, context.DeadlineExceeded.Error())
+}
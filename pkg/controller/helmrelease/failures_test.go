@@ -0,0 +1,70 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helmrelease
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/onsi/gomega"
+	rpb "helm.sh/helm/v3/pkg/release"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+	"github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/release"
+)
+
+func TestFailureReason(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	// the failed rollback of a failed upgrade is reported as such
+	rollbackErr := &release.ErrUpgradeFailed{Err: errors.New("deployment webapp failed"),
+		RollbackErr: errors.New("timed out waiting for the condition")}
+	g.Expect(failureReason(fmt.Errorf("reconcile: %w", rollbackErr), appv1.ReasonUpgradeError)).
+		To(gomega.Equal(appv1.ReasonRollbackError))
+
+	// but not the upgrade rolled back
+	rolledBack := &release.ErrUpgradeFailed{Err: errors.New("deployment webapp failed")}
+	g.Expect(failureReason(rolledBack, appv1.ReasonUpgradeError)).To(gomega.Equal(appv1.ReasonUpgradeError))
+
+	g.Expect(failureReason(fmt.Errorf("install: %w", release.ErrHooksFailed), appv1.ReasonInstallError)).
+		To(gomega.Equal(appv1.ReasonHooksError))
+	g.Expect(failureReason(&release.ErrApplyConflict{Conflicts: []string{`conflict with "kubectl": .data.key`}},
+		appv1.ReasonUpgradeError)).To(gomega.Equal(appv1.ReasonApplyConflict))
+	g.Expect(failureReason(&release.ErrReleasePending{Version: 2, Status: rpb.StatusPendingUpgrade},
+		appv1.ReasonReconcileError)).To(gomega.Equal(appv1.ReasonReleasePending))
+	g.Expect(failureReason(errors.New("connection refused"), appv1.ReasonInstallError)).
+		To(gomega.Equal(appv1.ReasonInstallError))
+
+	// the classes of failures have stable reasons whatever the action
+	for err, reason := range map[error]appv1.HelmAppConditionReason{
+		&chartPullError{errors.New("connection refused")}:                 appv1.ReasonChartPullError,
+		fmt.Errorf("%w: unexpected EOF", release.ErrRenderFailed):         appv1.ReasonRenderError,
+		fmt.Errorf("%w: request timed out", release.ErrStorageFailed):     appv1.ReasonStorageError,
+		fmt.Errorf("release webapp failed: %w", wait.ErrWaitTimeout):      appv1.ReasonTimeout,
+		fmt.Errorf("install: %w", context.DeadlineExceeded):               appv1.ReasonTimeout,
+		errors.New(`deployments.apps "webapp" is forbidden: not allowed`): appv1.ReasonRBACDenied,
+		apierrors.NewForbidden(schema.GroupResource{Group: "apps", Resource: "deployments"}, "webapp",
+			errors.New("not allowed")): appv1.ReasonRBACDenied,
+	} {
+		g.Expect(failureReason(err, appv1.ReasonInstallError)).To(gomega.Equal(reason), err.Error())
+	}
+}
@@ -0,0 +1,45 @@
+//go:build faults
+// +build faults
+
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helmrelease
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+	"github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/faults"
+)
+
+func TestChartDownloadFault(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	defer faults.Set()
+
+	faults.Set(faults.Fault{Point: faults.ChartDownload, Action: faults.Error, Key: "default/webapp", Times: 1})
+
+	// the download fails before the chart source is read
+	hr := &appv1.HelmRelease{ObjectMeta: metav1.ObjectMeta{Name: "webapp", Namespace: "default"}}
+
+	_, err := downloadChart(nil, hr)
+	g.Expect(errors.Is(err, faults.ErrInjected)).To(gomega.BeTrue())
+}
@@ -0,0 +1,86 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helmrelease
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+	"github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/release"
+)
+
+// forceDeleteAnnotation set to true on a deleted HelmRelease removes its
+// finalizer without uninstalling its release
+const forceDeleteAnnotation = "apps.open-cluster-management.io/force-delete"
+
+// forceDeleteReason returns why the finalizer of the deleted hr is removed
+// without uninstalling its release, or an empty string if it is not.
+func forceDeleteReason(hr *appv1.HelmRelease) string {
+	if hr.GetDeletionTimestamp() == nil {
+		return ""
+	}
+
+	if force, err := strconv.ParseBool(hr.GetAnnotations()[forceDeleteAnnotation]); err == nil && force {
+		return "the " + forceDeleteAnnotation + " annotation is set"
+	}
+
+	if Options.FinalizerTimeout > 0 && time.Since(hr.GetDeletionTimestamp().Time) > Options.FinalizerTimeout {
+		return fmt.Sprintf("the uninstall did not complete within %s", Options.FinalizerTimeout)
+	}
+
+	return ""
+}
+
+// forceDelete removes the finalizer of hr without uninstalling its release.
+// The resources of its deployed release are left behind, except those the
+// Kubernetes garbage collector deletes with their HelmRelease owner.
+func (r *ReconcileHelmRelease) forceDelete(hr *appv1.HelmRelease, reason string) (reconcile.Result, error) {
+	logFor(hr).Info("Force deleting HelmRelease", "reason", reason)
+
+	var skipped []appv1.HelmAppResource
+
+	if hr.Status.DeployedRelease != nil {
+		var err error
+		if skipped, err = release.RemovedResources(hr.Status.DeployedRelease.Manifest, ""); err != nil {
+			logFor(hr).Error(err, "Failed to list the deployed resources")
+		}
+	}
+
+	names := make([]string, 0, len(skipped))
+	for _, resource := range skipped {
+		names = append(names, resource.String())
+	}
+
+	r.recordWarning(hr, eventForceDeleted, fmt.Errorf("removed the finalizer without uninstalling the release as %s, "+
+		"skipped resources: [%s]", reason, strings.Join(names, ", ")))
+
+	controllerutil.RemoveFinalizer(hr, finalizer)
+
+	if err := r.updateResource(hr); err != nil {
+		logFor(hr).Error(err, "Failed to strip HelmRelease uninstall finalizer")
+
+		return reconcile.Result{}, err
+	}
+
+	return reconcile.Result{}, nil
+}
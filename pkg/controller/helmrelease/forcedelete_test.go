@@ -0,0 +1,55 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helmrelease
+
+import (
+	"testing"
+	"time"
+
+	"github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+func TestForceDeleteReason(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	defer func(timeout time.Duration) { Options.FinalizerTimeout = timeout }(Options.FinalizerTimeout)
+
+	Options.FinalizerTimeout = 0
+
+	hr := &appv1.HelmRelease{}
+	hr.SetAnnotations(map[string]string{forceDeleteAnnotation: "true"})
+
+	// only a deleted HelmRelease is force deleted
+	g.Expect(forceDeleteReason(hr)).To(gomega.BeEmpty())
+
+	deleted := metav1.NewTime(time.Now().Add(-time.Hour))
+	hr.SetDeletionTimestamp(&deleted)
+	g.Expect(forceDeleteReason(hr)).To(gomega.ContainSubstring(forceDeleteAnnotation))
+
+	// without the annotation, once the finalizer timed out
+	hr.SetAnnotations(nil)
+	g.Expect(forceDeleteReason(hr)).To(gomega.BeEmpty())
+
+	Options.FinalizerTimeout = 2 * time.Hour
+	g.Expect(forceDeleteReason(hr)).To(gomega.BeEmpty())
+
+	Options.FinalizerTimeout = 30 * time.Minute
+	g.Expect(forceDeleteReason(hr)).To(gomega.Equal("the uninstall did not complete within 30m0s"))
+}
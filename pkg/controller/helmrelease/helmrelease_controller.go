@@ -30,30 +30,32 @@ import (
 	"time"
 
 	"github.com/ghodss/yaml"
-	"github.com/prometheus/common/log"
+	rpb "helm.sh/helm/v3/pkg/release"
 	"helm.sh/helm/v3/pkg/releaseutil"
-	"helm.sh/helm/v3/pkg/storage/driver"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/util/retry"
-	"k8s.io/klog"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
-	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+	"github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/faults"
 	"github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/release"
+	"github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/tracing"
 )
 
 const (
 	finalizer = "uninstall-helm-release"
 
-	defaultMaxConcurrent = 10
+	// defaultReconcileInterval is how often a deployed release is re-reconciled
+	// when the HelmRelease does not set an interval
+	defaultReconcileInterval = appv1.DefaultInterval
 )
 
 // Add creates a new HelmRelease Controller and adds it to the Manager. The Manager will set fields on the Controller
@@ -82,20 +84,77 @@ func add(mgr manager.Manager, r reconcile.Reconciler) error {
 		}
 	}
 
-	klog.Info("The MaxConcurrentReconciles is set to: ", defaultMaxConcurrent)
+	maxConcurrent := Options.MaxConcurrentReconciles
+	if maxConcurrent < 1 {
+		maxConcurrent = DefaultMaxConcurrentReconciles
+	}
+
+	log.Info("Setting the concurrent reconciles", "maxConcurrentReconciles", maxConcurrent)
+	reconcileWorkers.Set(float64(maxConcurrent))
 
 	// Create a new controller
-	c, err := controller.New("helmrelease-controller", mgr, controller.Options{Reconciler: r, MaxConcurrentReconciles: defaultMaxConcurrent})
+	c, err := controller.New("helmrelease-controller", mgr, controller.Options{
+		Reconciler: measuredReconciler{r}, MaxConcurrentReconciles: maxConcurrent})
 	if err != nil {
 		return err
 	}
 
+	// the charts of the HelmReleases listed at startup are downloaded in parallel
+	prefetcher = newChartPrefetcher(mgr)
+	if err := mgr.Add(prefetcher); err != nil {
+		return err
+	}
+
+	if err := watchCRDs(mgr); err != nil {
+		return err
+	}
+
 	// Watch for changes to primary resource HelmRelease
-	if err := c.Watch(&source.Kind{Type: &appv1.HelmRelease{}}, &handler.EnqueueRequestForObject{},
-		predicate.GenerationChangedPredicate{}); err != nil {
+	if err := c.Watch(&source.Kind{Type: &appv1.HelmRelease{}}, &jitteredResyncHandler{},
+		shardPredicate{}); err != nil {
+		return err
+	}
+
+	// the HelmReleases whose delayed status write failed
+	if err := c.Watch(&source.Channel{Source: statusWrites.requeue}, &handler.EnqueueRequestForObject{},
+		shardPredicate{}); err != nil {
 		return err
 	}
 
+	if Options.WatchReleaseResources {
+		watcher = newResourceWatcher(c)
+	}
+
+	if err := watchPlacementRules(mgr, c); err != nil {
+		return err
+	}
+
+	if err := watchManifestWorks(mgr, c); err != nil {
+		return err
+	}
+
+	if err := watchTLSSecrets(mgr, c); err != nil {
+		return err
+	}
+
+	if err := watchUmbrellaCharts(c); err != nil {
+		return err
+	}
+
+	if err := addDriftReports(mgr); err != nil {
+		return err
+	}
+
+	if err := addSnapshots(mgr); err != nil {
+		return err
+	}
+
+	if Options.RecordGC != RecordGCDisabled {
+		if err := mgr.Add(newRecordJanitor(mgr, Options.RecordGC)); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -112,21 +171,54 @@ type ReconcileHelmRelease struct {
 // Note:
 // The Controller will requeue the Request to be processed again if the returned error is non-nil or
 // Result.Requeue is true, otherwise upon completion it will remove the work from the queue.
-func (r *ReconcileHelmRelease) Reconcile(request reconcile.Request) (reconcile.Result, error) {
-	klog.V(1).Info("Reconciling HelmRelease: ", request.Namespace, "/", request.Name)
+func (r *ReconcileHelmRelease) Reconcile(request reconcile.Request) (_ reconcile.Result, reconcileErr error) {
+	log.V(1).Info("Reconciling", "helmrelease", request.NamespacedName.String())
+
+	// no reconcile starts while the operator drains the running ones
+	end, ok := inflight.begin(request.NamespacedName)
+	if !ok {
+		log.Info("Operator is stopping, skipping reconciliation", "helmrelease", request.NamespacedName.String())
+		return reconcile.Result{}, nil
+	}
+
+	defer end()
+	defer beginReconcileLog(request.NamespacedName)()
+
+	ctx, span := tracing.Start(context.Background(), "Reconcile",
+		"helmrelease.namespace", request.Namespace, "helmrelease.name", request.Name)
+	defer func() { span.End(reconcileErr) }()
 
 	// Fetch the HelmRelease instance
 	instance := &appv1.HelmRelease{}
 
 	err := r.GetClient().Get(context.TODO(), request.NamespacedName, instance)
 	if apierrors.IsNotFound(err) {
+		managerCache.Delete(request.NamespacedName)
+		forgetResources(request.NamespacedName)
+		forgetReleaseState(request.NamespacedName)
+		forgetOrphanCheck(request.NamespacedName)
+		statusWrites.forget(request.NamespacedName)
+
 		return reconcile.Result{}, nil
 	}
 	if err != nil {
-		klog.Error(err, "Failed to lookup resource ", request.Namespace, "/", request.Name)
+		log.Error(err, "Failed to lookup resource", "helmrelease", request.NamespacedName.String())
 		return reconcile.Result{}, err
 	}
 
+	statusWrites.observe(instance)
+
+	// requeued requests of a HelmRelease relabeled out of the shard are dropped
+	if !inShard(instance) {
+		logFor(instance).V(1).Info("HelmRelease is not in the shard, skipping reconciliation")
+		managerCache.Delete(request.NamespacedName)
+		forgetReleaseState(request.NamespacedName)
+		forgetOrphanCheck(request.NamespacedName)
+		statusWrites.forget(request.NamespacedName)
+
+		return reconcile.Result{}, nil
+	}
+
 	// setting the nil spec to "":"" allows helmrelease to reconcile with default chart values.
 	if instance.Spec == nil {
 		spec := make(map[string]interface{})
@@ -138,61 +230,210 @@ func (r *ReconcileHelmRelease) Reconcile(request reconcile.Request) (reconcile.R
 
 		instance.Spec = spec
 
-		err = r.GetClient().Update(context.TODO(), instance)
-		if err != nil {
+		if err := r.updateResource(instance); err != nil {
 			return reconcile.Result{}, err
 		}
 	}
 
-	// handles the download of the chart as well
-	helmOperatorManagerFactory, err := r.newHelmOperatorManagerFactory(instance)
-	if err != nil {
-		klog.Error(err, "- Failed to create new HelmOperatorManagerFactory: ", instance.GetNamespace(), "/", instance.GetName())
+	// the Subscription the HelmRelease was created by lists it by its labels
+	if linkSubscription(instance) {
+		logFor(instance).V(1).Info("Labeling HelmRelease with its Subscription")
+
+		if err := r.updateResource(instance); err != nil {
+			logFor(instance).Error(err, "Failed to label HelmRelease with its Subscription")
+			return reconcile.Result{}, err
+		}
+	}
+
+	// the charts of an umbrella HelmRelease are deployed by their own HelmReleases
+	if isUmbrella(instance) {
+		return r.reconcileUmbrella(instance)
+	}
+
+	// a suspended HelmRelease keeps its last reported status, nothing is
+	// installed, upgraded or uninstalled until it is resumed
+	if instance.Repo.Suspend {
+		logFor(instance).Info("HelmRelease is suspended, skipping reconciliation")
 
 		instance.Status.SetCondition(appv1.HelmAppCondition{
-			Type:    appv1.ConditionIrreconcilable,
+			Type:    appv1.ConditionSuspended,
 			Status:  appv1.StatusTrue,
-			Reason:  appv1.ReasonReconcileError,
-			Message: err.Error(),
+			Reason:  appv1.ReasonSuspended,
+			Message: "Reconciliation is suspended",
 		})
-		_ = r.updateResourceStatus(instance)
 
-		klog.Info("Requeue HelmRelease after one minute ", instance.GetNamespace(), "/", instance.GetName())
+		return reconcile.Result{}, r.updateResourceStatus(instance)
+	}
 
-		return reconcile.Result{RequeueAfter: time.Minute * 1}, nil
+	instance.Status.RemoveCondition(appv1.ConditionSuspended)
+
+	// an uninstall that keeps failing, e.g. with a broken webhook, would
+	// otherwise block the deletion forever
+	if reason := forceDeleteReason(instance); reason != "" && contains(instance.GetFinalizers(), finalizer) {
+		return r.forceDelete(instance, reason)
+	}
+
+	requested := reconcileRequested(instance)
+
+	// a HelmRelease that exhausted its retries is only retried once its spec
+	// changes or a reconcile is requested, it is still uninstalled if deleted
+	if instance.GetDeletionTimestamp() == nil && retriesExhausted(instance) && requested == "" {
+		logFor(instance).Info("HelmRelease exhausted its retries, skipping reconciliation")
+
+		return reconcile.Result{}, nil
+	}
+
+	if c := instance.Status.GetCondition(appv1.ConditionStalled); c != nil && c.Reason == appv1.ReasonRetriesExhausted {
+		logFor(instance).Info("Retrying HelmRelease after its exhausted retries")
+		resetRetries(instance)
+
+		// the request is handled even if the retries are exhausted again
+		if requested != "" {
+			instance.Status.LastHandledReconcileAt = requested
+		}
+	}
+
+	// a HelmRelease no longer allowed to deploy to its target namespace can
+	// still be uninstalled
+	if instance.GetDeletionTimestamp() == nil {
+		// the allow-list may have changed since the HelmRelease was admitted
+		if err := appv1.CheckChartSource(instance.GetNamespace(), instance.Repo.Source); err != nil {
+			logFor(instance).Error(err, "Forbidden chart source")
+
+			instance.Status.SetCondition(appv1.HelmAppCondition{
+				Type:    appv1.ConditionIrreconcilable,
+				Status:  appv1.StatusTrue,
+				Reason:  appv1.ReasonChartSourceForbidden,
+				Message: err.Error(),
+			})
+			delay := retryAfter(instance)
+			_ = r.updateResourceStatus(instance)
+
+			return reconcile.Result{RequeueAfter: delay}, nil
+		}
+
+		if err := checkNamespaceScope(instance); err != nil {
+			logFor(instance).Error(err, "HelmRelease not allowed in namespace-scoped mode")
+
+			instance.Status.SetCondition(appv1.HelmAppCondition{
+				Type:    appv1.ConditionIrreconcilable,
+				Status:  appv1.StatusTrue,
+				Reason:  appv1.ReasonNamespaceScopeViolation,
+				Message: err.Error(),
+			})
+			delay := retryAfter(instance)
+			_ = r.updateResourceStatus(instance)
+
+			return reconcile.Result{RequeueAfter: delay}, nil
+		}
+
+		if err := r.checkTargetNamespace(instance); err != nil {
+			logFor(instance).Error(err, "Forbidden target namespace")
+
+			instance.Status.SetCondition(appv1.HelmAppCondition{
+				Type:    appv1.ConditionIrreconcilable,
+				Status:  appv1.StatusTrue,
+				Reason:  appv1.ReasonTargetNamespaceForbidden,
+				Message: err.Error(),
+			})
+			delay := retryAfter(instance)
+			_ = r.updateResourceStatus(instance)
+
+			return reconcile.Result{RequeueAfter: delay}, nil
+		}
+	}
+
+	// a full reconcile downloads the chart again, the changed annotation
+	// already keeps the cached manager from being reused
+	if requested != "" {
+		logFor(instance).Info("Full reconcile requested", "requestedAt", requested)
+		discardChart(instance)
+	}
+
+	manager, err := r.getHelmOperatorManager(ctx, instance, request)
+
+	var conflict *release.ErrNameConflict
+	if errors.As(err, &conflict) {
+		return r.nameConflict(instance, conflict)
 	}
 
-	manager, err := r.newHelmOperatorManager(instance, request, helmOperatorManagerFactory)
 	if err != nil {
-		klog.Error(err, "- Failed to create new HelmOperatorManager: ", instance.GetNamespace(), "/", instance.GetName())
+		logFor(instance).Error(err, "Failed to get HelmOperatorManager")
 
 		instance.Status.SetCondition(appv1.HelmAppCondition{
 			Type:    appv1.ConditionIrreconcilable,
 			Status:  appv1.StatusTrue,
-			Reason:  appv1.ReasonReconcileError,
+			Reason:  failureReason(err, appv1.ReasonReconcileError),
 			Message: err.Error(),
 		})
+		delay := retryAfter(instance)
 		_ = r.updateResourceStatus(instance)
 
-		klog.Info("Requeue HelmRelease after one minute ", instance.GetNamespace(), "/", instance.GetName())
+		logFor(instance).Info("Requeue HelmRelease", "after", delay.String())
 
-		return reconcile.Result{RequeueAfter: time.Minute * 1}, nil
+		return reconcile.Result{RequeueAfter: delay}, nil
+	}
+
+	instance.Status.RemoveCondition(appv1.ConditionNameConflict)
+	instance.Status.ReleaseName = manager.ReleaseName()
+
+	if kind := debugDumpRequested(instance); kind != "" && instance.GetDeletionTimestamp() == nil {
+		r.writeDebugDump(instance, manager, kind)
 	}
 
-	if err := manager.Sync(context.TODO()); err != nil {
-		klog.Error(err, "Failed to sync HelmRelease ", instance.GetNamespace(), "/", instance.GetName())
+	if instance.GetDeletionTimestamp() == nil {
+		r.syncChartMetadata(instance, manager)
+	}
+
+	// the release is deployed by the work agents of the selected clusters
+	if hubMode(instance) {
+		return r.reconcileManifestWorks(instance, manager, requested)
+	}
+
+	// another operator process, e.g. the previous leader, may still be
+	// running a helm action on the release
+	lockCtx, unlock, err := manager.Lock(context.TODO())
+	if errors.Is(err, release.ErrReleaseLocked) {
+		logFor(instance).Info("Release is locked by another operator, requeue after 30 seconds")
+
+		return reconcile.Result{RequeueAfter: time.Second * 30}, nil
+	}
+
+	if err != nil {
+		logFor(instance).Error(err, "Failed to lock the release")
+		return reconcile.Result{}, err
+	}
+
+	defer unlock()
+
+	// the release of a replaced cluster is installed again
+	if err := r.checkClusterReplaced(lockCtx, instance, manager); err != nil {
+		logFor(instance).Error(err, "Failed to check if the cluster was replaced")
+	}
+
+	_, renderSpan := tracing.Start(ctx, "Render", "release", manager.ReleaseName())
+	err = manager.Sync(lockCtx)
+	renderSpan.End(err)
+
+	if err != nil {
+		logFor(instance).Error(err, "Failed to sync")
 
 		instance.Status.SetCondition(appv1.HelmAppCondition{
 			Type:    appv1.ConditionIrreconcilable,
 			Status:  appv1.StatusTrue,
-			Reason:  appv1.ReasonReconcileError,
+			Reason:  failureReason(err, appv1.ReasonReconcileError),
 			Message: err.Error(),
 		})
+		delay := retryAfter(instance)
 		_ = r.updateResourceStatus(instance)
 
-		klog.Info("Requeue HelmRelease after one minute ", instance.GetNamespace(), "/", instance.GetName())
+		logFor(instance).Info("Requeue HelmRelease", "after", delay.String())
 
-		return reconcile.Result{RequeueAfter: time.Minute * 1}, nil
+		return reconcile.Result{RequeueAfter: delay}, nil
+	}
+
+	if requested != "" {
+		instance.Status.LastHandledReconcileAt = requested
 	}
 
 	instance.Status.RemoveCondition(appv1.ConditionIrreconcilable)
@@ -200,25 +441,69 @@ func (r *ReconcileHelmRelease) Reconcile(request reconcile.Request) (reconcile.R
 	// helm uninstall
 	if instance.GetDeletionTimestamp() != nil {
 		if !contains(instance.GetFinalizers(), finalizer) {
-			klog.Info("HelmRelease is terminated, skipping reconciliation ", instance.GetNamespace(), "/", instance.GetName())
+			logFor(instance).Info("HelmRelease is terminated, skipping reconciliation")
+
+			return reconcile.Result{}, nil
+		}
+
+		if instance.Repo.DeletionPolicy == appv1.OrphanDeletionPolicy {
+			if err := r.orphanRelease(lockCtx, instance, manager); err != nil {
+				logFor(instance).Error(err, "Failed to orphan the release")
+				r.recordWarning(instance, eventUninstallFailed, err)
+				recordAudit(instance, manager, appv1.AuditOrphan, triggerDeleted, nil, err)
+				instance.Status.SetCondition(appv1.HelmAppCondition{
+					Type:    appv1.ConditionReleaseFailed,
+					Status:  appv1.StatusTrue,
+					Reason:  appv1.ReasonUninstallError,
+					Message: err.Error(),
+				})
+				delay := retryAfter(instance)
+				_ = r.updateResourceStatus(instance)
+				return reconcile.Result{RequeueAfter: delay}, nil
+			}
+
+			r.recordEvent(instance, eventReleaseOrphaned, "Deleted the records of release %s, its resources are left running",
+				manager.ReleaseName())
+			recordAudit(instance, manager, appv1.AuditOrphan, triggerDeleted, nil, nil)
+
+			controllerutil.RemoveFinalizer(instance, finalizer)
+
+			if err := r.updateResource(instance); err != nil {
+				logFor(instance).Error(err, "Failed to strip HelmRelease uninstall finalizer")
+
+				return reconcile.Result{}, err
+			}
 
 			return reconcile.Result{}, nil
 		}
 
-		_, err := manager.UninstallRelease(context.TODO())
-		if err != nil && !errors.Is(err, driver.ErrReleaseNotFound) {
-			klog.Error(err, "Failed to uninstall HelmRelease ", instance.GetNamespace(), "/", instance.GetName())
+		endAction := inflight.action(request.NamespacedName, "uninstall")
+		_, applySpan := tracing.Start(ctx, "Uninstall", "release", manager.ReleaseName())
+		uninstalledRelease, err := manager.UninstallRelease(lockCtx)
+		applySpan.End(err)
+		endAction()
+
+		if err != nil && !errors.Is(err, release.ErrReleaseNotFound) {
+			logFor(instance).Error(err, "Failed to uninstall")
+			r.recordWarning(instance, eventUninstallFailed, err)
+			recordAudit(instance, manager, appv1.AuditUninstall, triggerDeleted, nil, err)
 			instance.Status.SetCondition(appv1.HelmAppCondition{
 				Type:    appv1.ConditionReleaseFailed,
 				Status:  appv1.StatusTrue,
-				Reason:  appv1.ReasonUninstallError,
+				Reason:  failureReason(err, appv1.ReasonUninstallError),
 				Message: err.Error(),
 			})
+			delay := retryAfter(instance)
 			_ = r.updateResourceStatus(instance)
-			return reconcile.Result{}, err
+			return reconcile.Result{RequeueAfter: delay}, nil
 		}
 
-		klog.Info("Uninstalled HelmRelease ", instance.GetNamespace(), ",", instance.GetName())
+		logFor(instance).Info("Uninstalled")
+
+		if err == nil {
+			r.recordEvent(instance, eventUninstallSucceeded, "Uninstalled release %s", manager.ReleaseName())
+			recordAudit(instance, manager, appv1.AuditUninstall, triggerDeleted, uninstalledRelease, nil)
+		}
 
 		// no need to check for remaining resources when there is no DeployedRelease
 		// skip ahead to removing the finalizer and let the helmrelease terminate
@@ -226,18 +511,25 @@ func (r *ReconcileHelmRelease) Reconcile(request reconcile.Request) (reconcile.R
 			controllerutil.RemoveFinalizer(instance, finalizer)
 
 			if err := r.updateResource(instance); err != nil {
-				klog.Error(err, " - Failed to strip HelmRelease uninstall finalizer ", instance.GetNamespace(), "/", instance.GetName())
+				logFor(instance).Error(err, "Failed to strip HelmRelease uninstall finalizer")
 
 				return reconcile.Result{}, err
 			}
 
-			klog.Info("Removed finalizer from HelmRelease ", instance.GetNamespace(), ",", instance.GetName(), " requeue after 1 minute")
+			logFor(instance).Info("Removed finalizer, requeue after 1 minute")
 
 			return reconcile.Result{RequeueAfter: time.Minute * 1}, nil
 		}
 
 		instance.Status.RemoveCondition(appv1.ConditionReleaseFailed)
 
+		c, err := r.clusterClient(instance)
+		if err != nil {
+			logFor(instance).Error(err, "Failed to get the cluster client")
+
+			return reconcile.Result{}, err
+		}
+
 		// find all the deployed resources and check to see if they still exists
 		isFoundResource := false
 		foundResource := &unstructured.Unstructured{}
@@ -246,7 +538,7 @@ func (r *ReconcileHelmRelease) Reconcile(request reconcile.Request) (reconcile.R
 		for _, resource := range resources {
 			var u unstructured.Unstructured
 			if err := yaml.Unmarshal([]byte(resource), &u); err != nil {
-				klog.Error(err, " - Failed to unmarshal resource ", resource)
+				logFor(instance).Error(err, "Failed to unmarshal resource", "resource", resource)
 
 				return reconcile.Result{}, err
 			}
@@ -261,10 +553,10 @@ func (r *ReconcileHelmRelease) Reconcile(request reconcile.Request) (reconcile.R
 			o.SetGroupVersionKind(u.GroupVersionKind())
 
 			if u.GetNamespace() == "" {
-				o.SetNamespace(instance.GetNamespace())
+				o.SetNamespace(targetNamespace(instance))
 			}
 
-			if r.isResourceDeleted(o, instance) {
+			if r.isResourceDeleted(c, o, instance) {
 				// resource is already delete, check the next one.
 				continue
 			}
@@ -286,13 +578,12 @@ func (r *ReconcileHelmRelease) Reconcile(request reconcile.Request) (reconcile.R
 			})
 			_ = r.updateResourceStatus(instance)
 
-			klog.Info("Requeue HelmRelease after one minute ", instance.GetNamespace(), "/", instance.GetName())
+			logFor(instance).Info("Requeue HelmRelease after one minute")
 
 			return reconcile.Result{RequeueAfter: time.Minute * 1}, nil
 		}
 
-		klog.Info("HelmRelease ", instance.GetNamespace(), "/", instance.GetName(),
-			" all DeployedRelease resources are deleted/terminating")
+		logFor(instance).Info("All DeployedRelease resources are deleted/terminating")
 
 		instance.Status.RemoveCondition(appv1.ConditionReleaseFailed)
 		instance.Status.SetCondition(appv1.HelmAppCondition{
@@ -300,12 +591,14 @@ func (r *ReconcileHelmRelease) Reconcile(request reconcile.Request) (reconcile.R
 			Status: appv1.StatusFalse,
 			Reason: appv1.ReasonUninstallSuccessful,
 		})
+		resetRetries(instance)
+		instance.Status.ObservedGeneration = instance.GetGeneration()
 		_ = r.updateResourceStatus(instance)
 
 		controllerutil.RemoveFinalizer(instance, finalizer)
 
 		if err := r.updateResource(instance); err != nil {
-			klog.Error(err, " - Failed to strip HelmRelease uninstall finalizer ", instance.GetNamespace(), "/", instance.GetName())
+			logFor(instance).Error(err, "Failed to strip HelmRelease uninstall finalizer")
 
 			return reconcile.Result{}, err
 		}
@@ -318,31 +611,316 @@ func (r *ReconcileHelmRelease) Reconcile(request reconcile.Request) (reconcile.R
 		Status: appv1.StatusTrue,
 	})
 
+	forceUpgrade := manager.IsInstalled() && forceUpgradeRequested(instance)
+	if forceUpgrade && !manager.IsUpgradeRequired() {
+		logFor(instance).Info("Forcing the upgrade of the up to date release")
+	}
+
+	// a held release keeps its deployed revision, even for a forced upgrade
+	if manager.IsInstalled() && (manager.IsUpgradeRequired() || forceUpgrade) && holdRequested(instance) {
+		return r.holdBack(instance)
+	}
+
+	// the upgrades of a changed chart or values wait for their scheduled time
+	// and an upgrade window, unlike the forced ones requested explicitly
+	if manager.IsUpgradeRequired() && !forceUpgrade {
+		pendingUntil, reason, err := upgradePendingUntil(instance, time.Now())
+		if err != nil {
+			logFor(instance).Error(err, "Invalid upgrade windows")
+
+			instance.Status.SetCondition(appv1.HelmAppCondition{
+				Type:    appv1.ConditionIrreconcilable,
+				Status:  appv1.StatusTrue,
+				Reason:  appv1.ReasonReconcileError,
+				Message: err.Error(),
+			})
+			delay := retryAfter(instance)
+			_ = r.updateResourceStatus(instance)
+
+			return reconcile.Result{RequeueAfter: delay}, nil
+		}
+
+		if !pendingUntil.IsZero() {
+			logFor(instance).Info("Upgrade is pending", "until", pendingUntil.String())
+
+			instance.Status.SetCondition(appv1.HelmAppCondition{
+				Type:    appv1.ConditionUpgradePending,
+				Status:  appv1.StatusTrue,
+				Reason:  reason,
+				Message: "The upgrade is pending until " + pendingUntil.Format(time.RFC3339),
+			})
+			err := r.updateResourceStatus(instance)
+
+			return reconcile.Result{RequeueAfter: time.Until(pendingUntil)}, err
+		}
+	}
+
+	instance.Status.RemoveCondition(appv1.ConditionUpgradePending)
+
+	// the release is only installed or upgraded once its dependencies are ready
+	if !manager.IsInstalled() || manager.IsUpgradeRequired() || forceUpgrade {
+		ready, err := r.checkDependencies(instance)
+		if err != nil {
+			logFor(instance).Error(err, "Failed to check the dependencies")
+			return reconcile.Result{}, err
+		}
+
+		if !ready {
+			_ = r.updateResourceStatus(instance)
+
+			logFor(instance).Info("Requeue HelmRelease", "after", dependencyRetryInterval.String())
+
+			return reconcile.Result{RequeueAfter: dependencyRetryInterval}, nil
+		}
+
+		// a release needing missing capabilities would fail halfway
+		met, err := r.checkPreconditions(instance)
+		if err != nil {
+			logFor(instance).Error(err, "Failed to check the preconditions")
+			return reconcile.Result{}, err
+		}
+
+		if !met {
+			_ = r.updateResourceStatus(instance)
+
+			logFor(instance).Info("Requeue HelmRelease", "after", preconditionRetryInterval.String())
+
+			return reconcile.Result{RequeueAfter: preconditionRetryInterval}, nil
+		}
+
+		// the cluster-scoped resources would fail halfway on the missing permissions
+		forbidden, err := checkRenderedScope(instance, manager)
+		if err != nil || len(forbidden) != 0 {
+			reason, message := appv1.ReasonNamespaceScopeViolation, namespaceScopeMessage(forbidden)
+			if err != nil {
+				logFor(instance).Error(err, "Failed to render the release")
+				reason, message = appv1.ReasonReconcileError, err.Error()
+			}
+
+			instance.Status.SetCondition(appv1.HelmAppCondition{
+				Type:    appv1.ConditionIrreconcilable,
+				Status:  appv1.StatusTrue,
+				Reason:  reason,
+				Message: message,
+			})
+			delay := retryAfter(instance)
+			_ = r.updateResourceStatus(instance)
+
+			return reconcile.Result{RequeueAfter: delay}, nil
+		}
+
+		// the tenants of a shared cluster must not deploy cluster-wide resources
+		denied, err := checkClusterScoped(instance, manager)
+		if err != nil || len(denied) != 0 {
+			reason, message := appv1.ReasonClusterScopedResource, clusterScopedMessage(denied)
+			if err != nil {
+				logFor(instance).Error(err, "Failed to render the release")
+				reason, message = appv1.ReasonReconcileError, err.Error()
+			}
+
+			instance.Status.SetCondition(appv1.HelmAppCondition{
+				Type:    appv1.ConditionIrreconcilable,
+				Status:  appv1.StatusTrue,
+				Reason:  reason,
+				Message: message,
+			})
+			delay := retryAfter(instance)
+			_ = r.updateResourceStatus(instance)
+
+			return reconcile.Result{RequeueAfter: delay}, nil
+		}
+
+		missing, err := checkPermissions(instance, manager)
+		if err != nil || len(missing) != 0 {
+			reason, message := appv1.ReasonPermissionsMissing, permissionsMessage(instance, missing)
+			if err != nil {
+				logFor(instance).Error(err, "Failed to review the permissions")
+				reason, message = appv1.ReasonReconcileError, err.Error()
+			}
+
+			instance.Status.SetCondition(appv1.HelmAppCondition{
+				Type:    appv1.ConditionIrreconcilable,
+				Status:  appv1.StatusTrue,
+				Reason:  reason,
+				Message: message,
+			})
+			delay := retryAfter(instance)
+			_ = r.updateResourceStatus(instance)
+
+			return reconcile.Result{RequeueAfter: delay}, nil
+		}
+
+		// the policies are enforced even without admission webhooks for the resources
+		violations, policyWarnings, err := checkPolicies(instance, manager)
+		if err != nil || len(violations) != 0 {
+			reason, message := appv1.ReasonPolicyViolation, policyViolationsMessage(violations)
+			if err != nil {
+				logFor(instance).Error(err, "Failed to evaluate the policies")
+				reason, message = appv1.ReasonReconcileError, err.Error()
+			}
+
+			instance.Status.PolicyViolations = violations
+			instance.Status.SetCondition(appv1.HelmAppCondition{
+				Type:    appv1.ConditionIrreconcilable,
+				Status:  appv1.StatusTrue,
+				Reason:  reason,
+				Message: message,
+			})
+			delay := retryAfter(instance)
+			_ = r.updateResourceStatus(instance)
+
+			return reconcile.Result{RequeueAfter: delay}, nil
+		}
+
+		instance.Status.PolicyViolations = nil
+
+		if len(policyWarnings) != 0 {
+			r.recordWarning(instance, eventPolicyWarning, errors.New(policyWarningsMessage(policyWarnings)))
+		}
+
+		// the unsigned images must not reach the cluster
+		unverified, err := checkImageSignatures(instance, manager)
+		if err != nil || len(unverified) != 0 {
+			reason, message := appv1.ReasonUnverifiedImage, unverifiedImagesMessage(unverified)
+			if err != nil {
+				logFor(instance).Error(err, "Failed to verify the image signatures")
+				reason, message = appv1.ReasonReconcileError, err.Error()
+			}
+
+			instance.Status.SetCondition(appv1.HelmAppCondition{
+				Type:    appv1.ConditionIrreconcilable,
+				Status:  appv1.StatusTrue,
+				Reason:  reason,
+				Message: message,
+			})
+			delay := retryAfter(instance)
+			_ = r.updateResourceStatus(instance)
+
+			return reconcile.Result{RequeueAfter: delay}, nil
+		}
+
+		// the pods rejected by the admission would only show in the events of their owners
+		rejected, warned, err := r.checkPodSecurity(instance, manager)
+		if err != nil || len(rejected) != 0 {
+			reason, message := appv1.ReasonPodSecurityViolation, podSecurityMessage(rejected)
+			if err != nil {
+				logFor(instance).Error(err, "Failed to check the pod security")
+				reason, message = appv1.ReasonReconcileError, err.Error()
+			}
+
+			instance.Status.SetCondition(appv1.HelmAppCondition{
+				Type:    appv1.ConditionIrreconcilable,
+				Status:  appv1.StatusTrue,
+				Reason:  reason,
+				Message: message,
+			})
+			delay := retryAfter(instance)
+			_ = r.updateResourceStatus(instance)
+
+			return reconcile.Result{RequeueAfter: delay}, nil
+		}
+
+		if len(warned) != 0 {
+			r.recordWarning(instance, eventPodSecurityWarning, errors.New(podSecurityMessage(warned)))
+		}
+
+		// the removed API versions would fail the apply, or the next cluster upgrade
+		deprecated, err := r.checkDeprecatedAPIs(instance, manager)
+		blocking := removedAPIs(deprecated)
+		if instance.Repo.DeprecatedAPIPolicy != appv1.BlockDeprecatedAPIPolicy {
+			blocking = nil
+		}
+
+		if err != nil || len(blocking) != 0 {
+			reason, message := appv1.ReasonDeprecatedAPI, deprecatedAPIsMessage(blocking)
+			if err != nil {
+				logFor(instance).Error(err, "Failed to scan the deprecated APIs")
+				reason, message = appv1.ReasonReconcileError, err.Error()
+			}
+
+			instance.Status.DeprecatedAPIs = deprecated
+			instance.Status.SetCondition(appv1.HelmAppCondition{
+				Type:    appv1.ConditionIrreconcilable,
+				Status:  appv1.StatusTrue,
+				Reason:  reason,
+				Message: message,
+			})
+			delay := retryAfter(instance)
+			_ = r.updateResourceStatus(instance)
+
+			return reconcile.Result{RequeueAfter: delay}, nil
+		}
+
+		if len(deprecated) != 0 {
+			r.recordWarning(instance, eventDeprecatedAPIWarning, errors.New(deprecatedAPIsMessage(deprecated)))
+		}
+
+		instance.Status.DeprecatedAPIs = deprecated
+
+		if err := r.ensureTargetNamespace(instance); err != nil {
+			logFor(instance).Error(err, "Failed to create the target namespace")
+
+			instance.Status.SetCondition(appv1.HelmAppCondition{
+				Type:    appv1.ConditionReleaseFailed,
+				Status:  appv1.StatusTrue,
+				Reason:  appv1.ReasonReconcileError,
+				Message: err.Error(),
+			})
+			delay := retryAfter(instance)
+			_ = r.updateResourceStatus(instance)
+
+			return reconcile.Result{RequeueAfter: delay}, nil
+		}
+	} else {
+		instance.Status.RemoveCondition(appv1.ConditionDependencyNotReady)
+		instance.Status.RemoveCondition(appv1.ConditionPreconditionsNotMet)
+	}
+
+	trigger := auditTrigger(instance, requested, forceUpgrade, manager.IsInstalled())
+
 	// helm install
 	if !manager.IsInstalled() {
-		installedRelease, err := manager.InstallRelease(context.TODO())
+		r.recordEvent(instance, eventInstallStarted, "Installing release %s", manager.ReleaseName())
+
+		stopProgress := r.reportProgress(instance, manager)
+		endAction := inflight.action(request.NamespacedName, "install")
+		_, applySpan := tracing.Start(ctx, "Install", "release", manager.ReleaseName(),
+			"wait", strconv.FormatBool(instance.Repo.Wait))
+		installedRelease, err := manager.InstallRelease(lockCtx)
+		applySpan.End(err)
+		endAction()
+		stopProgress()
+
 		if err != nil {
-			klog.Error(err, "Failed to install HelmRelease ", instance.GetNamespace(), "/", instance.GetName())
+			logFor(instance).Error(err, "Failed to install")
+			r.recordWarning(instance, eventInstallFailed, err)
+			recordAudit(instance, manager, appv1.AuditInstall, trigger, nil, err)
 			instance.Status.SetCondition(appv1.HelmAppCondition{
 				Type:    appv1.ConditionReleaseFailed,
 				Status:  appv1.StatusTrue,
-				Reason:  appv1.ReasonInstallError,
+				Reason:  failureReason(err, appv1.ReasonInstallError),
 				Message: err.Error(),
 			})
+			delay := retryAfter(instance)
 			_ = r.updateResourceStatus(instance)
-			return reconcile.Result{}, err
+			return reconcile.Result{RequeueAfter: delay}, nil
 		}
 
 		instance.Status.RemoveCondition(appv1.ConditionReleaseFailed)
 
-		klog.V(1).Info("Adding finalizer (", finalizer, ") to ", instance.GetNamespace(), "/", instance.GetName())
+		logFor(instance).V(1).Info("Adding finalizer", "finalizer", finalizer)
 		controllerutil.AddFinalizer(instance, finalizer)
 		if err := r.updateResource(instance); err != nil {
-			klog.Error("Failed to add uninstall finalizer to ", instance.GetNamespace(), "/", instance.GetName())
+			logFor(instance).Error(err, "Failed to add the uninstall finalizer")
 			return reconcile.Result{}, err
 		}
 
-		klog.Info("Installed HelmRelease ", instance.GetNamespace(), "/", instance.GetName())
+		logFor(instance).Info("Installed")
+		r.recordEvent(instance, eventInstallSucceeded, "Installed release %s revision %d",
+			installedRelease.Name, installedRelease.Version)
+		recordAudit(instance, manager, appv1.AuditInstall, trigger, installedRelease, nil)
+		r.exportManifest(instance, installedRelease)
+		r.snapshotRelease(instance, installedRelease)
 
 		message := ""
 		if installedRelease.Info != nil {
@@ -354,41 +932,69 @@ func (r *ReconcileHelmRelease) Reconcile(request reconcile.Request) (reconcile.R
 			Reason:  appv1.ReasonInstallSuccessful,
 			Message: message,
 		})
-		instance.Status.DeployedRelease = &appv1.HelmAppRelease{
-			Name:     installedRelease.Name,
-			Manifest: installedRelease.Manifest,
+		instance.Status.DeployedRelease = deployedRelease(installedRelease, manager)
+		checkResources(instance, manager, installedRelease.Manifest)
+		watchResources(instance, manager.ReleaseName(), installedRelease.Manifest)
+		resetRetries(instance)
+		instance.Status.ObservedGeneration = instance.GetGeneration()
+
+		if err := faults.Inject(faults.StatusWrite, request.NamespacedName.String()); err != nil {
+			return reconcile.Result{}, err
 		}
+
 		err = r.updateResourceStatus(instance)
-		return reconcile.Result{}, err
+		return reconcile.Result{RequeueAfter: reconcileInterval(instance)}, err
 	}
 
 	if !contains(instance.GetFinalizers(), finalizer) {
-		klog.V(1).Info("Adding finalizer (", finalizer, ") to ", instance.GetNamespace(), "/", instance.GetName())
+		logFor(instance).V(1).Info("Adding finalizer", "finalizer", finalizer)
 		controllerutil.AddFinalizer(instance, finalizer)
 		if err := r.updateResource(instance); err != nil {
-			klog.Error("Failed to add uninstall finalizer to ", instance.GetNamespace(), "/", instance.GetName())
+			logFor(instance).Error(err, "Failed to add the uninstall finalizer")
 			return reconcile.Result{}, err
 		}
 	}
 
 	// helm upgrade
-	if manager.IsUpgradeRequired() {
+	if manager.IsUpgradeRequired() || forceUpgrade {
 		force := hasHelmUpgradeForceAnnotation(instance)
-		_, upgradedRelease, err := manager.UpgradeRelease(context.TODO(), release.ForceUpgrade(force))
+		stopProgress := r.reportProgress(instance, manager)
+		endAction := inflight.action(request.NamespacedName, "upgrade")
+		_, applySpan := tracing.Start(ctx, "Upgrade", "release", manager.ReleaseName(),
+			"wait", strconv.FormatBool(instance.Repo.Wait))
+		previousRelease, upgradedRelease, err := manager.UpgradeRelease(lockCtx, release.ForceUpgrade(force))
+		applySpan.End(err)
+		endAction()
+		stopProgress()
+
 		if err != nil {
-			klog.Error(err, "Failed to upgrade HelmRelease ", instance.GetNamespace(), "/", instance.GetName())
+			logFor(instance).Error(err, "Failed to upgrade")
+			r.recordUpgradeFailure(instance, err)
+			recordUpgradeAudit(instance, manager, trigger, err)
 			instance.Status.SetCondition(appv1.HelmAppCondition{
 				Type:    appv1.ConditionReleaseFailed,
 				Status:  appv1.StatusTrue,
-				Reason:  appv1.ReasonUpgradeError,
+				Reason:  failureReason(err, appv1.ReasonUpgradeError),
 				Message: err.Error(),
 			})
+			delay := retryAfter(instance)
 			_ = r.updateResourceStatus(instance)
-			return reconcile.Result{}, err
+			return reconcile.Result{RequeueAfter: delay}, nil
 		}
 		instance.Status.RemoveCondition(appv1.ConditionReleaseFailed)
 
-		klog.Info("Upgraded HelmRelease ", "force=", force, " for ", instance.GetNamespace(), "/", instance.GetName())
+		summary, err := release.DiffSummary(previousRelease.Manifest, upgradedRelease.Manifest, instance.Repo.IgnoreDifferences)
+		if err != nil {
+			logFor(instance).Error(err, "Failed to summarize the upgrade")
+		}
+
+		logFor(instance).Info("Upgraded", "force", force, "summary", summary)
+		r.recordEvent(instance, eventUpgradeSucceeded, "Upgraded release %s from revision %d to %d: %s",
+			upgradedRelease.Name, previousRelease.Version, upgradedRelease.Version, summary)
+		recordAudit(instance, manager, appv1.AuditUpgrade, trigger, upgradedRelease, nil)
+		r.exportManifest(instance, upgradedRelease)
+		r.snapshotRelease(instance, upgradedRelease)
+
 		message := ""
 		if upgradedRelease.Info != nil {
 			message = upgradedRelease.Info.Notes
@@ -399,13 +1005,25 @@ func (r *ReconcileHelmRelease) Reconcile(request reconcile.Request) (reconcile.R
 			Reason:  appv1.ReasonUpgradeSuccessful,
 			Message: message,
 		})
-		instance.Status.DeployedRelease = &appv1.HelmAppRelease{
-			Name:     upgradedRelease.Name,
-			Manifest: upgradedRelease.Manifest,
+		instance.Status.DeployedRelease = deployedRelease(upgradedRelease, manager)
+		instance.Status.PrunedResources = prunedResources(instance, previousRelease, upgradedRelease)
+		instance.Status.UpgradeSummary = summary
+		checkResources(instance, manager, upgradedRelease.Manifest)
+		watchResources(instance, manager.ReleaseName(), upgradedRelease.Manifest)
+		resetRetries(instance)
+		instance.Status.ObservedGeneration = instance.GetGeneration()
+
+		if err := faults.Inject(faults.StatusWrite, request.NamespacedName.String()); err != nil {
+			return reconcile.Result{}, err
 		}
+
 		err = r.updateResourceStatus(instance)
 
-		return reconcile.Result{}, err
+		if err == nil && forceUpgrade {
+			err = r.consumeForceUpgrade(instance)
+		}
+
+		return reconcile.Result{RequeueAfter: reconcileInterval(instance)}, err
 	}
 
 	// If a change is made to the CR spec that causes a release failure, a
@@ -419,15 +1037,16 @@ func (r *ReconcileHelmRelease) Reconcile(request reconcile.Request) (reconcile.R
 	// ensure the status is populated with install/upgrade reason
 	expectedRelease, err := manager.GetDeployedRelease()
 	if err != nil {
-		log.Error(err, "Failed to get deployed release for HelmRelease ", instance.GetNamespace(), "/", instance.GetName())
+		logFor(instance).Error(err, "Failed to get deployed release")
 		instance.Status.SetCondition(appv1.HelmAppCondition{
 			Type:    appv1.ConditionIrreconcilable,
 			Status:  appv1.StatusTrue,
 			Reason:  appv1.ReasonReconcileError,
 			Message: err.Error(),
 		})
+		delay := retryAfter(instance)
 		_ = r.updateResourceStatus(instance)
-		return reconcile.Result{}, err
+		return reconcile.Result{RequeueAfter: delay}, nil
 	}
 	instance.Status.RemoveCondition(appv1.ConditionIrreconcilable)
 
@@ -445,21 +1064,112 @@ func (r *ReconcileHelmRelease) Reconcile(request reconcile.Request) (reconcile.R
 		Reason:  reason,
 		Message: message,
 	})
-	instance.Status.DeployedRelease = &appv1.HelmAppRelease{
-		Name:     expectedRelease.Name,
-		Manifest: expectedRelease.Manifest,
+	instance.Status.DeployedRelease = deployedRelease(expectedRelease, manager)
+
+	// the orphans found by the last check stay in the status until the next one
+	if now := time.Now(); orphanCheckDue(instance, expectedRelease.Version, now) {
+		orphaned, err := r.orphanedResources(instance, manager.ReleaseName(), expectedRelease.Manifest)
+		if err != nil {
+			logFor(instance).Error(err, "Failed to check orphaned resources")
+		} else {
+			instance.Status.OrphanedResources = orphaned
+			recordOrphanCheck(instance, expectedRelease.Version, now)
+		}
 	}
+
+	checkDrift(instance, manager)
+	checkResources(instance, manager, expectedRelease.Manifest)
+	watchResources(instance, manager.ReleaseName(), expectedRelease.Manifest)
+
+	conflicts, err := manager.FieldConflicts(context.TODO())
+	if err != nil {
+		logFor(instance).Error(err, "Failed to check field conflicts")
+	} else {
+		instance.Status.FieldConflicts = conflicts
+	}
+
+	resetRetries(instance)
+	instance.Status.ObservedGeneration = instance.GetGeneration()
 	err = r.updateResourceStatus(instance)
-	return reconcile.Result{}, err
+	return reconcile.Result{RequeueAfter: reconcileInterval(instance)}, err
+}
+
+// prunedResources returns the resources deleted by the upgrade from previous
+// to upgraded because the chart no longer renders them.
+func prunedResources(hr *appv1.HelmRelease, previous, upgraded *rpb.Release) []appv1.HelmAppResource {
+	if !hr.Repo.PruneEnabled() || previous == nil || upgraded == nil {
+		return nil
+	}
+
+	pruned, err := release.RemovedResources(previous.Manifest, upgraded.Manifest)
+	if err != nil {
+		logFor(hr).Error(err, "Failed to get the resources pruned")
+		return nil
+	}
+
+	for _, p := range pruned {
+		logFor(hr).Info("Pruned resource removed from the chart", "resource", p.String())
+	}
+
+	return pruned
+}
+
+// failureReason returns the condition reason of a failed release action,
+// or reason if the error is not a hook, rollback or apply conflict failure.
+func failureReason(err error, reason appv1.HelmAppConditionReason) appv1.HelmAppConditionReason {
+	var upgradeErr *release.ErrUpgradeFailed
+	if errors.As(err, &upgradeErr) && upgradeErr.RollbackErr != nil {
+		return appv1.ReasonRollbackError
+	}
+
+	var conflictErr *release.ErrApplyConflict
+	if errors.As(err, &conflictErr) {
+		return appv1.ReasonApplyConflict
+	}
+
+	var pendingErr *release.ErrReleasePending
+	if errors.As(err, &pendingErr) {
+		return appv1.ReasonReleasePending
+	}
+
+	if errors.Is(err, release.ErrHooksFailed) {
+		return appv1.ReasonHooksError
+	}
+
+	if class, ok := failureClass(err); ok {
+		return class
+	}
+
+	return reason
 }
 
 func (r ReconcileHelmRelease) updateResourceStatus(hr *appv1.HelmRelease) error {
-	return retry.RetryOnConflict(retry.DefaultBackoff, func() error {
-		return r.GetClient().Status().Update(context.TODO(), hr)
-	})
+	redactStatus(hr)
+	setStandardConditions(hr)
+
+	hr.Status.Subscription = subscriptionStatus(hr)
+
+	// the rollout progress is only reported while a release action waits
+	hr.Status.Progress = ""
+
+	// patched without conflicts, and coalesced with the next writes if the
+	// previous one is too recent
+	if err := statusWrites.write(r.GetClient(), hr); err != nil {
+		return err
+	}
+
+	recordReleaseState(hr)
+
+	return nil
 }
 
+// updateResource updates the HelmRelease without its status. The status of hr,
+// which may be a coalesced one not written yet, is kept and not replaced by
+// the stored one the update returns.
 func (r ReconcileHelmRelease) updateResource(hr *appv1.HelmRelease) error {
+	status := *hr.Status.DeepCopy()
+	defer func() { hr.Status = status }()
+
 	return retry.RetryOnConflict(retry.DefaultBackoff, func() error {
 		return r.GetClient().Update(context.TODO(), hr)
 	})
@@ -476,31 +1186,31 @@ func contains(l []string, s string) bool {
 
 //isResourceDeleted finds the given resource, if it exists then delete it.
 // return true if the resource is already deleted.
-func (r *ReconcileHelmRelease) isResourceDeleted(resource *unstructured.Unstructured, hr *appv1.HelmRelease) bool {
-	klog.V(2).Info("Getting resource: ", resource.GetNamespace(), "/", resource.GetName(),
-		" ", resource.GroupVersionKind())
+func (r *ReconcileHelmRelease) isResourceDeleted(c client.Client, resource *unstructured.Unstructured,
+	hr *appv1.HelmRelease) bool {
+	logFor(hr).V(2).Info("Getting resource", "resource", resourceName(resource))
 
 	nsn := types.NamespacedName{Name: resource.GetName(), Namespace: resource.GetNamespace()}
 
 	// try to get the resource in the namespace
-	err := r.GetClient().Get(context.TODO(), nsn, resource)
+	err := c.Get(context.TODO(), nsn, resource)
 	if err != nil {
 		if apierrors.IsNotFound(err) {
 			return true // resource is already deleted
 		}
 
-		klog.V(2).Info("Ignorable error while attempting to fetch resource from namespace: ",
-			resource.GetNamespace(), "/", resource.GetName(), " ", resource.GroupVersionKind(), " - ", err)
+		logFor(hr).V(2).Info("Ignorable error while attempting to fetch resource from namespace",
+			"resource", resourceName(resource), "error", err.Error())
 
 		// it's not in the namespace try looking for the resource in cluster scope
 		resource.SetNamespace("")
 
 		nsn = types.NamespacedName{Name: resource.GetName()}
 
-		err := r.GetClient().Get(context.TODO(), nsn, resource)
+		err := c.Get(context.TODO(), nsn, resource)
 		if err != nil {
-			klog.V(2).Info("Ignorable error while attempting to fetch resource from cluster: ",
-				resource.GetName(), " ", resource.GroupVersionKind(), " - ", err)
+			logFor(hr).V(2).Info("Ignorable error while attempting to fetch resource from cluster",
+				"resource", resourceName(resource), "error", err.Error())
 
 			return true // resource is already deleted
 		}
@@ -508,18 +1218,32 @@ func (r *ReconcileHelmRelease) isResourceDeleted(resource *unstructured.Unstruct
 
 	// found the resource so it's not deleted yet
 
-	klog.Info("Removal of HelmRelease ", hr.GetNamespace(), "/", hr.GetName(),
-		" is blocked by resource: ", resource.GetNamespace(), "/", resource.GetName(),
-		" ", resource.GroupVersionKind())
+	logFor(hr).Info("Removal of HelmRelease is blocked by resource", "resource", resourceName(resource))
 
-	if err = r.GetClient().Delete(context.TODO(), resource); err != nil {
-		klog.Error(err, " - Failed to delete resource: ", resource.GetNamespace(), "/", resource.GetName(),
-			" ", resource.GroupVersionKind())
+	if err = c.Delete(context.TODO(), resource); err != nil {
+		logFor(hr).Error(err, "Failed to delete resource", "resource", resourceName(resource))
 	}
 
 	return false
 }
 
+// reconcileInterval returns how often the deployed release of hr is
+// re-reconciled, e.g. to check it for drift and orphaned resources. It is
+// checked sooner while its resources are rolling out.
+func reconcileInterval(hr *appv1.HelmRelease) time.Duration {
+	interval := defaultReconcileInterval
+	if hr.Repo.Interval != nil && hr.Repo.Interval.Duration > 0 {
+		interval = hr.Repo.Interval.Duration
+	}
+
+	if c := hr.Status.GetCondition(appv1.ConditionResourcesReady); c != nil &&
+		c.Reason == appv1.ReasonResourcesInProgress && resourcesCheckInterval < interval {
+		return resourcesCheckInterval
+	}
+
+	return interval
+}
+
 // returns the boolean representation of the annotation string
 // will return false if annotation is not set
 func hasHelmUpgradeForceAnnotation(hr *appv1.HelmRelease) bool {
@@ -530,9 +1254,8 @@ func hasHelmUpgradeForceAnnotation(hr *appv1.HelmRelease) bool {
 	}
 	value := false
 	if i, err := strconv.ParseBool(force); err != nil {
-		klog.Info("Could not parse annotation as a boolean ",
-			"annotation=", helmUpgradeForceAnnotation, " value informed ", force,
-			" for ", hr.GetNamespace(), "/", hr.GetName())
+		logFor(hr).Info("Could not parse annotation as a boolean",
+			"annotation", helmUpgradeForceAnnotation, "value", force)
 	} else {
 		value = i
 	}
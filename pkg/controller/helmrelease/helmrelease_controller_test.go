@@ -107,13 +107,17 @@ func TestReconcile(t *testing.T) {
 	g.Expect(err).NotTo(gomega.HaveOccurred())
 
 	g.Expect(instanceResp.Status.DeployedRelease).NotTo(gomega.BeNil())
+	g.Expect(instanceResp.Status.ObservedGeneration).To(gomega.Equal(instanceResp.GetGeneration()))
 
 	// check if there exists an InstallSuccessful reason
 	instanceResp.Status.RemoveCondition(appv1.ConditionInitialized)
 	g.Expect(instanceResp.Status.Conditions[0].Reason).To(gomega.Equal(appv1.ReasonInstallSuccessful))
 
-	// remove the deployed condition (InstallSuccessful)
+	// remove the deployed condition (InstallSuccessful) and the conditions derived from it
 	instanceResp.Status.RemoveCondition(appv1.ConditionDeployed)
+	instanceResp.Status.RemoveCondition(appv1.ConditionReleased)
+	instanceResp.Status.RemoveCondition(appv1.ConditionReady)
+	instanceResp.Status.RemoveCondition(appv1.ConditionResourcesReady)
 
 	err = c.Status().Update(context.TODO(), instanceResp)
 	g.Expect(err).NotTo(gomega.HaveOccurred())
@@ -138,7 +142,8 @@ func TestReconcile(t *testing.T) {
 	instanceResp = &appv1.HelmRelease{}
 	err = c.Get(context.TODO(), helmReleaseKey, instanceResp)
 	g.Expect(err).NotTo(gomega.HaveOccurred())
-	g.Expect(len(instanceResp.Status.Conditions)).To(gomega.Equal(2))
+	g.Expect(len(instanceResp.Status.Conditions)).To(gomega.Equal(5))
+	g.Expect(instanceResp.Status.GetCondition(appv1.ConditionReady).Status).To(gomega.Equal(appv1.StatusTrue))
 	g.Expect(instanceResp.Status.Conditions[1].Reason).To(gomega.Equal(appv1.ReasonInstallSuccessful))
 
 	// trigger a update
@@ -159,8 +164,11 @@ func TestReconcile(t *testing.T) {
 	instanceResp.Status.RemoveCondition(appv1.ConditionInitialized)
 	g.Expect(instanceResp.Status.Conditions[0].Reason).To(gomega.Equal(appv1.ReasonUpgradeSuccessful))
 
-	// remove the deployed condition (UpdateSuccessful)
+	// remove the deployed condition (UpdateSuccessful) and the conditions derived from it
 	instanceResp.Status.RemoveCondition(appv1.ConditionDeployed)
+	instanceResp.Status.RemoveCondition(appv1.ConditionReleased)
+	instanceResp.Status.RemoveCondition(appv1.ConditionReady)
+	instanceResp.Status.RemoveCondition(appv1.ConditionResourcesReady)
 
 	err = c.Status().Update(context.TODO(), instanceResp)
 	g.Expect(err).NotTo(gomega.HaveOccurred())
@@ -185,7 +193,8 @@ func TestReconcile(t *testing.T) {
 	instanceResp = &appv1.HelmRelease{}
 	err = c.Get(context.TODO(), helmReleaseKey, instanceResp)
 	g.Expect(err).NotTo(gomega.HaveOccurred())
-	g.Expect(len(instanceResp.Status.Conditions)).To(gomega.Equal(2))
+	g.Expect(len(instanceResp.Status.Conditions)).To(gomega.Equal(5))
+	g.Expect(instanceResp.Status.GetCondition(appv1.ConditionReady).Status).To(gomega.Equal(appv1.StatusTrue))
 	g.Expect(instanceResp.Status.Conditions[1].Reason).To(gomega.Equal(appv1.ReasonUpgradeSuccessful))
 
 	//
@@ -750,3 +759,16 @@ func Test_generateResourceListForHelm(t *testing.T) {
 	g.Expect(err).NotTo(gomega.HaveOccurred())
 	g.Expect(resourceList).NotTo(gomega.BeNil())
 }
+
+func TestReconcileInterval(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	hr := &appv1.HelmRelease{}
+	g.Expect(reconcileInterval(hr)).To(gomega.Equal(defaultReconcileInterval))
+
+	hr.Repo.Interval = &metav1.Duration{Duration: 2 * time.Minute}
+	g.Expect(reconcileInterval(hr)).To(gomega.Equal(2 * time.Minute))
+
+	hr.Repo.Interval = &metav1.Duration{}
+	g.Expect(reconcileInterval(hr)).To(gomega.Equal(defaultReconcileInterval))
+}
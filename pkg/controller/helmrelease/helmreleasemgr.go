@@ -23,10 +23,10 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"strings"
 
 	"github.com/ghodss/yaml"
 
-	"helm.sh/helm/v3/pkg/chart/loader"
 	"helm.sh/helm/v3/pkg/storage"
 
 	helmclient "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/client"
@@ -35,28 +35,66 @@ import (
 	"helm.sh/helm/v3/pkg/kube"
 	"helm.sh/helm/v3/pkg/storage/driver"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
 	v1 "k8s.io/client-go/kubernetes/typed/core/v1"
-	"k8s.io/klog"
-	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+	"github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/faults"
+	"github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/tracing"
 	"github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/utils"
 )
 
+// managerCache holds the helm operator managers of the HelmReleases across reconciles
+var managerCache = helmoperator.NewManagerCache()
+
+// getHelmOperatorManager returns the cached helm operator manager of the HelmRelease, or a new one
+// if the HelmRelease spec changed since it was cached. The charts from git sources are not cached so
+// that their new commits are picked up on every reconcile.
+func (r ReconcileHelmRelease) getHelmOperatorManager(ctx context.Context,
+	s *appv1.HelmRelease, request reconcile.Request) (helmoperator.Manager, error) {
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(s)
+	if err != nil {
+		return nil, err
+	}
+
+	if manager, ok := managerCache.Get(&unstructured.Unstructured{Object: content}); ok {
+		logFor(s).V(3).Info("Reusing the cached helm operator manager")
+		return manager, nil
+	}
+
+	// handles the download of the chart as well
+	_, fetchSpan := tracing.Start(ctx, "FetchChart", "chart", s.Repo.ChartName)
+	factory, err := r.newHelmOperatorManagerFactory(s)
+	fetchSpan.End(err)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// loads the chart and merges the values
+	_, loadSpan := tracing.Start(ctx, "LoadChart", "chart", s.Repo.ChartName)
+	manager, err := r.newHelmOperatorManager(s, request, factory)
+	loadSpan.End(err)
+
+	return manager, err
+}
+
 //newHelmOperatorManagerFactory create a new manager returns a helmManagerFactory
 func (r ReconcileHelmRelease) newHelmOperatorManagerFactory(
 	s *appv1.HelmRelease) (helmoperator.ManagerFactory, error) {
-	chartDir, err := downloadChart(r.GetClient(), s)
+	chartDir, err := downloadChart(r.Manager, s)
 	if err != nil {
-		klog.Error(err, " - Failed to download the chart")
-		return nil, err
+		logFor(s).Error(err, "Failed to download the chart")
+		r.recordWarning(s, eventChartDownloadFailed, err)
+
+		return nil, &chartPullError{err}
 	}
 
-	klog.V(3).Info("ChartDir: ", chartDir)
+	logFor(s).V(3).Info("Downloaded the chart", "chartDir", chartDir)
 
-	f := helmoperator.NewManagerFactory(r.Manager, chartDir)
+	f := helmoperator.NewManagerFactory(r.Manager, chartDir, Options.Storage, Options.HelmClient, Options.PatchStrategy)
 
 	return f, nil
 }
@@ -71,58 +109,116 @@ func (r ReconcileHelmRelease) newHelmOperatorManager(
 
 	err := r.GetClient().Get(context.TODO(), request.NamespacedName, o)
 	if err != nil {
-		klog.Error(err, " - Failed to lookup resource")
+		logFor(s).Error(err, "Failed to lookup resource")
 		return nil, err
 	}
 
 	manager, err := factory.NewManager(o, nil)
 	if err != nil {
-		klog.Error(err, " - Failed to get helm operator manager")
+		logFor(s).Error(err, "Failed to get helm operator manager")
 		return nil, err
 	}
 
+	if s.Repo.Source != nil && strings.EqualFold(string(s.Repo.Source.SourceType), string(appv1.HelmRepoSourceType)) {
+		managerCache.Put(o, manager)
+	}
+
 	return manager, nil
 }
 
-//downloadChart downloads the chart
-func downloadChart(client client.Client, s *appv1.HelmRelease) (string, error) {
-	configMap, err := utils.GetConfigMap(client, s.Namespace, s.Repo.ConfigMapRef)
+// NewReleaseManager returns a new helm operator manager of the HelmRelease, with its chart downloaded
+// and its values merged as on a reconcile. It is not cached. It is used by the kubectl plugin, the
+// HelmRelease is not read again from the cluster.
+func NewReleaseManager(mgr manager.Manager, s *appv1.HelmRelease) (helmoperator.Manager, error) {
+	chartDir, err := downloadChart(mgr, s)
 	if err != nil {
-		klog.Error(err)
-		return "", err
+		return nil, err
 	}
 
-	secret, err := utils.GetSecret(client, s.Namespace, s.Repo.SecretRef)
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(s)
 	if err != nil {
-		klog.Error(err, " - Failed to retrieve secret ", s.Repo.SecretRef.Name)
+		return nil, err
+	}
+
+	o := &unstructured.Unstructured{Object: content}
+	o.SetGroupVersionKind(appv1.SchemeGroupVersion.WithKind("HelmRelease"))
+
+	return helmoperator.NewManagerFactory(mgr, chartDir, Options.Storage, Options.HelmClient,
+		Options.PatchStrategy).NewManager(o, nil)
+}
+
+// downloadChart downloads the chart, or expands its pre-staged bundle
+func downloadChart(mgr manager.Manager, s *appv1.HelmRelease) (string, error) {
+	if err := faults.Inject(faults.ChartDownload, s.Namespace+"/"+s.Name); err != nil {
 		return "", err
 	}
 
 	chartsDir := os.Getenv(appv1.ChartsDir)
 	if chartsDir == "" {
+		var err error
+
 		chartsDir, err = ioutil.TempDir("/tmp", "charts")
 		if err != nil {
-			klog.Error(err, " - Can not create tempdir")
+			logFor(s).Error(err, "Can not create tempdir")
+			return "", err
+		}
+	}
+
+	if Options.ChartBundleNamespace != "" {
+		bundle, err := utils.GetChartBundle(mgr.GetAPIReader(), Options.ChartBundleNamespace, s)
+		if err != nil {
+			logFor(s).Error(err, "Failed to look up the chart bundles")
 			return "", err
 		}
+
+		if bundle != nil {
+			logFor(s).V(3).Info("Using chart bundle", "bundle", bundle.Namespace+"/"+bundle.Name)
+			s.Status.ChartSource = nil
+
+			return utils.StageChartBundle(bundle, chartsDir, s)
+		}
 	}
 
-	chartDir, err := utils.DownloadChart(configMap, secret, chartsDir, s)
-	klog.V(3).Info("ChartDir: ", chartDir)
+	client := mgr.GetClient()
 
+	configMap, err := utils.GetConfigMap(client, s.Namespace, s.Repo.ConfigMapRef)
 	if err != nil {
-		klog.Error(err, " - Failed to download the chart")
+		logFor(s).Error(err, "Failed to retrieve configmap")
 		return "", err
 	}
 
+	secret, err := utils.GetSecret(client, s.Namespace, s.Repo.SecretRef)
+	if err != nil {
+		logFor(s).Error(err, "Failed to retrieve secret", "secret", s.Repo.SecretRef.Name)
+		return "", err
+	}
+
+	// read at each download, so that the rotated certificates are used
+	tlsSecret, err := utils.GetSecret(client, s.Namespace, s.Repo.TLSSecretRef)
+	if err != nil {
+		logFor(s).Error(err, "Failed to retrieve TLS secret", "secret", s.Repo.TLSSecretRef.Name)
+		return "", err
+	}
+
+	chartDir, source, err := utils.DownloadChartSource(configMap, secret, tlsSecret, chartsDir, s)
+	logFor(s).V(3).Info("Downloaded the chart", "chartDir", chartDir)
+
+	if err != nil {
+		logFor(s).Error(err, "Failed to download the chart")
+		return "", err
+	}
+
+	// kept until the next successful download
+	s.Status.ChartSource = source
+
 	return chartDir, nil
 }
 
 //generateResourceList generates the resource list for given HelmRelease
 func generateResourceList(mgr manager.Manager, s *appv1.HelmRelease) (kube.ResourceList, error) {
-	chartDir, err := downloadChart(mgr.GetClient(), s)
+	chartDir, err := downloadChart(mgr, s)
 	if err != nil {
-		klog.Error(err, " - Failed to download the chart")
+		logFor(s).Error(err, "Failed to download the chart")
 		return nil, err
 	}
 
@@ -137,15 +233,15 @@ func generateResourceList(mgr manager.Manager, s *appv1.HelmRelease) (kube.Resou
 
 	err = yaml.Unmarshal(reqBodyBytes.Bytes(), &values)
 	if err != nil {
-		klog.Error(err, " - Failed to Unmarshal the spec ", s.Spec)
+		logFor(s).Error(err, "Failed to Unmarshal the spec")
 		return nil, err
 	}
 
-	klog.V(3).Info("ChartDir: ", chartDir)
+	logFor(s).V(3).Info("Downloaded the chart", "chartDir", chartDir)
 
-	chart, err := loader.LoadDir(chartDir)
+	chart, err := helmoperator.LoadChart(chartDir)
 	if err != nil {
-		return nil, fmt.Errorf("failed to load chart dir: %w", err)
+		return nil, fmt.Errorf("failed to load chart: %w", err)
 	}
 
 	clientv1, err := v1.NewForConfig(mgr.GetConfig())
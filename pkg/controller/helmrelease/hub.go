@@ -0,0 +1,623 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helmrelease
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	workv1 "github.com/open-cluster-management/api/work/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+	"github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/release"
+)
+
+// releaseDigestAnnotation records on a ManifestWork the digest of the release
+// it carries
+const releaseDigestAnnotation = "apps.open-cluster-management.io/release-digest"
+
+// hubMode returns true if hr is deployed to ManagedClusters with ManifestWorks
+// instead of being installed on the cluster of the operator.
+func hubMode(hr *appv1.HelmRelease) bool {
+	return hr.Repo.ClusterSelector != nil || hr.Repo.PlacementRef != nil
+}
+
+// manifestWorkName is the name of the ManifestWork of hr in the namespace of
+// each selected ManagedCluster, the namespaces of the clusters are shared by
+// the HelmReleases of every namespace.
+func manifestWorkName(hr *appv1.HelmRelease) string {
+	return hr.GetNamespace() + "-" + hr.GetName()
+}
+
+// manifestWorkLabels labels the ManifestWorks of hr.
+func manifestWorkLabels(hr *appv1.HelmRelease) client.MatchingLabels {
+	return client.MatchingLabels{
+		release.HelmReleaseNameLabel:      hr.GetName(),
+		release.HelmReleaseNamespaceLabel: hr.GetNamespace(),
+	}
+}
+
+// watchManifestWorks reconciles the HelmReleases deployed with ManifestWorks
+// as soon as the work agents report a new state, so that their status is
+// current. The ManifestWorks are only watched if their CRD is installed and
+// every namespace is watched, they live in the namespaces of the clusters.
+func watchManifestWorks(mgr manager.Manager, c controller.Controller) error {
+	gvk := workv1.GroupVersion.WithKind("ManifestWork")
+
+	_, err := mgr.GetRESTMapper().RESTMapping(gvk.GroupKind(), gvk.Version)
+	if meta.IsNoMatchError(err) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if len(Options.WatchNamespaces) != 0 {
+		log.Info("Not watching the ManifestWorks, the status of the clusters is refreshed at each interval")
+		return nil
+	}
+
+	log.Info("Watching the status of the ManifestWorks")
+
+	return c.Watch(&source.Kind{Type: &workv1.ManifestWork{}}, &handler.EnqueueRequestsFromMapFunc{
+		ToRequests: handler.ToRequestsFunc(helmReleaseOfWork),
+	}, workStatusChangedPredicate{})
+}
+
+// helmReleaseOfWork maps a ManifestWork to the HelmRelease labeled on it.
+func helmReleaseOfWork(obj handler.MapObject) []reconcile.Request {
+	labels := obj.Meta.GetLabels()
+
+	name, namespace := labels[release.HelmReleaseNameLabel], labels[release.HelmReleaseNamespaceLabel]
+	if name == "" || namespace == "" {
+		return nil
+	}
+
+	return []reconcile.Request{{NamespacedName: types.NamespacedName{Namespace: namespace, Name: name}}}
+}
+
+// workStatusChangedPredicate only passes the updates of the ManifestWorks
+// changing their status, the reconcile updates their spec.
+type workStatusChangedPredicate struct {
+	predicate.Funcs
+}
+
+func (workStatusChangedPredicate) Create(e event.CreateEvent) bool {
+	return false
+}
+
+func (workStatusChangedPredicate) Update(e event.UpdateEvent) bool {
+	oldWork, ok := e.ObjectOld.(*workv1.ManifestWork)
+	if !ok {
+		return false
+	}
+
+	newWork, ok := e.ObjectNew.(*workv1.ManifestWork)
+	if !ok {
+		return false
+	}
+
+	return !reflect.DeepEqual(oldWork.Status, newWork.Status)
+}
+
+// reconcileManifestWorks deploys hr to the ManagedClusters selected by its
+// clusterSelector and its PlacementRule. The release is rendered on the hub and its resources are
+// sent to each cluster in a ManifestWork, applied by the work agent of the
+// cluster. Nothing is installed on the hub and no release record is kept. The
+// ManifestWorks of the clusters no longer selected are deleted, all of them
+// once hr is deleted.
+func (r *ReconcileHelmRelease) reconcileManifestWorks(hr *appv1.HelmRelease, manager release.Manager,
+	requested string) (reconcile.Result, error) {
+	if hr.GetDeletionTimestamp() != nil {
+		if !contains(hr.GetFinalizers(), finalizer) {
+			logFor(hr).Info("HelmRelease is terminated, skipping reconciliation")
+
+			return reconcile.Result{}, nil
+		}
+
+		// the work agents delete the applied resources with the ManifestWorks
+		if err := r.deleteManifestWorks(hr, nil); err != nil {
+			return r.manifestWorkFailed(hr, err)
+		}
+
+		controllerutil.RemoveFinalizer(hr, finalizer)
+
+		if err := r.updateResource(hr); err != nil {
+			logFor(hr).Error(err, "Failed to strip HelmRelease uninstall finalizer")
+
+			return reconcile.Result{}, err
+		}
+
+		logFor(hr).Info("Deleted the ManifestWorks")
+
+		return reconcile.Result{}, nil
+	}
+
+	if !contains(hr.GetFinalizers(), finalizer) {
+		logFor(hr).V(1).Info("Adding finalizer", "finalizer", finalizer)
+		controllerutil.AddFinalizer(hr, finalizer)
+
+		if err := r.updateResource(hr); err != nil {
+			logFor(hr).Error(err, "Failed to add the uninstall finalizer")
+			return reconcile.Result{}, err
+		}
+	}
+
+	if err := r.replaceStaleManifestWorks(hr); err != nil {
+		return r.manifestWorkFailed(hr, err)
+	}
+
+	clusters, err := r.selectedClusters(hr)
+	if err != nil {
+		return r.manifestWorkFailed(hr, err)
+	}
+
+	matching, err := r.matchingOverrides(hr, clusters)
+	if err != nil {
+		return r.manifestWorkFailed(hr, err)
+	}
+
+	names, err := r.resolveClusterNames(hr, clusters)
+	if err != nil {
+		return r.manifestWorkFailed(hr, err)
+	}
+
+	// the clusters matching the same overrides with the same names share
+	// their rendering
+	renders := make(map[string]clusterRender)
+	desired := make(map[string]clusterRender, len(clusters))
+	selected := make(map[string]bool, len(clusters))
+
+	for _, cluster := range clusters {
+		selected[cluster] = true
+
+		key := fmt.Sprint(matching[cluster], names[cluster])
+
+		rendered, ok := renders[key]
+		if !ok {
+			if rendered, err = renderClusterWork(hr, manager, matching[cluster], names[cluster]); err != nil {
+				return r.manifestWorkFailed(hr, err)
+			}
+
+			renders[key] = rendered
+		}
+
+		desired[cluster] = rendered
+	}
+
+	stages, err := r.rolloutStages(hr, clusters)
+	if err != nil {
+		return r.manifestWorkFailed(hr, err)
+	}
+
+	rollout := startRollout(hr, rolloutRevision(desired))
+	requeue := reconcileInterval(hr)
+	clusterStatuses := make(map[string]appv1.HelmAppClusterStatus, len(clusters))
+
+	// the clusters of the next stages keep their release until the previous
+	// stages are verified
+	proceed := true
+
+	for _, stage := range stages {
+		proceed = proceed && stageApproved(hr, rollout, stage)
+
+		stageStatuses, err := r.upgradeStage(hr, stage, desired, proceed)
+		if err != nil {
+			return r.manifestWorkFailed(hr, err)
+		}
+
+		if proceed && rollout != nil {
+			var delay time.Duration
+
+			delay, proceed = verifyStage(hr, rollout, stage, stageStatuses, desired)
+			if delay > 0 && delay < requeue {
+				requeue = delay
+			}
+		}
+
+		for _, s := range stageStatuses {
+			clusterStatuses[s.Cluster] = s
+		}
+	}
+
+	if proceed && rollout != nil {
+		completeRollout(hr, rollout, len(clusters))
+	}
+
+	statuses := make([]appv1.HelmAppClusterStatus, 0, len(clusters))
+	for _, cluster := range clusters {
+		s := clusterStatuses[cluster]
+		s.OutOfDate = s.Digest != desired[cluster].digest
+		statuses = append(statuses, s)
+	}
+
+	if err := r.deleteManifestWorks(hr, selected); err != nil {
+		return r.manifestWorkFailed(hr, err)
+	}
+
+	if requested != "" {
+		hr.Status.LastHandledReconcileAt = requested
+	}
+
+	hr.Status.RemoveCondition(appv1.ConditionIrreconcilable)
+	hr.Status.RemoveCondition(appv1.ConditionReleaseFailed)
+	hr.Status.SetCondition(appv1.HelmAppCondition{
+		Type:    appv1.ConditionDeployed,
+		Status:  appv1.StatusTrue,
+		Reason:  appv1.ReasonManifestWorksApplied,
+		Message: fmt.Sprintf("Deployed to %d ManagedClusters", len(clusters)),
+	})
+	hr.Status.SetCondition(clustersReadyCondition(statuses))
+	hr.Status.Clusters = statuses
+	setRolloutConditions(hr, rollout)
+
+	resetRetries(hr)
+	hr.Status.ObservedGeneration = hr.GetGeneration()
+	err = r.updateResourceStatus(hr)
+
+	// new clusters are selected at the next interval
+	return reconcile.Result{RequeueAfter: requeue}, err
+}
+
+// upgradeStage returns the state of the ManifestWorks of the clusters of
+// stage, after upgrading those whose release is not the desired one if
+// upgrade is set, as many at once as the maxUnavailable of its wave allows.
+func (r *ReconcileHelmRelease) upgradeStage(hr *appv1.HelmRelease, stage rolloutStage,
+	desired map[string]clusterRender, upgrade bool) ([]appv1.HelmAppClusterStatus, error) {
+	works := make([]*workv1.ManifestWork, 0, len(stage.clusters))
+	statuses := make([]appv1.HelmAppClusterStatus, 0, len(stage.clusters))
+
+	for _, cluster := range stage.clusters {
+		work, err := r.getManifestWork(hr, cluster)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get the ManifestWork of cluster %s: %w", cluster, err)
+		}
+
+		works = append(works, work)
+		statuses = append(statuses, manifestWorkStatus(cluster, work))
+	}
+
+	if !upgrade {
+		return statuses, nil
+	}
+
+	budget := upgradeBudget(stage, statuses, desired)
+
+	for i, cluster := range stage.clusters {
+		// the work of a replaced cluster is created again once deleted
+		if works[i] != nil && works[i].GetDeletionTimestamp() != nil {
+			continue
+		}
+
+		// the clusters with the desired release are kept in sync
+		if statuses[i].Digest != desired[cluster].digest {
+			if budget <= 0 {
+				continue
+			}
+
+			budget--
+		}
+
+		work, err := r.applyManifestWork(hr, cluster, works[i], desired[cluster].digest, desired[cluster].manifests)
+		if err != nil {
+			return nil, fmt.Errorf("failed to apply the ManifestWork of cluster %s: %w", cluster, err)
+		}
+
+		statuses[i] = manifestWorkStatus(cluster, work)
+	}
+
+	return statuses, nil
+}
+
+// manifestWorkFailed reports the failure to deploy hr with ManifestWorks and
+// retries it with the backoff.
+func (r *ReconcileHelmRelease) manifestWorkFailed(hr *appv1.HelmRelease, err error) (reconcile.Result, error) {
+	logFor(hr).Error(err, "Failed to reconcile the ManifestWorks")
+
+	hr.Status.SetCondition(appv1.HelmAppCondition{
+		Type:    appv1.ConditionReleaseFailed,
+		Status:  appv1.StatusTrue,
+		Reason:  appv1.ReasonManifestWorkError,
+		Message: err.Error(),
+	})
+	delay := retryAfter(hr)
+	_ = r.updateResourceStatus(hr)
+
+	return reconcile.Result{RequeueAfter: delay}, nil
+}
+
+// selectedClusters returns the sorted names of the ManagedClusters selected by
+// the clusterSelector of hr and decided by its PlacementRule, if set.
+func (r *ReconcileHelmRelease) selectedClusters(hr *appv1.HelmRelease) ([]string, error) {
+	if hr.Repo.PlacementRef == nil {
+		return r.labelSelectedClusters(hr)
+	}
+
+	decided, err := r.placementDecisions(hr)
+	if err != nil || hr.Repo.ClusterSelector == nil {
+		return decided, err
+	}
+
+	matching, err := r.labelSelectedClusters(hr)
+	if err != nil {
+		return nil, err
+	}
+
+	matches := make(map[string]bool, len(matching))
+	for _, name := range matching {
+		matches[name] = true
+	}
+
+	names := make([]string, 0, len(decided))
+	for _, name := range decided {
+		if matches[name] {
+			names = append(names, name)
+		}
+	}
+
+	return names, nil
+}
+
+// labelSelectedClusters returns the sorted names of the ManagedClusters
+// selected by the clusterSelector of hr.
+func (r *ReconcileHelmRelease) labelSelectedClusters(hr *appv1.HelmRelease) ([]string, error) {
+	selector, err := metav1.LabelSelectorAsSelector(hr.Repo.ClusterSelector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cluster selector: %w", err)
+	}
+
+	clusters := &clusterv1.ManagedClusterList{}
+	if err := r.GetAPIReader().List(context.TODO(), clusters, client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return nil, fmt.Errorf("failed to list ManagedClusters: %w", err)
+	}
+
+	names := make([]string, 0, len(clusters.Items))
+	for _, c := range clusters.Items {
+		names = append(names, c.GetName())
+	}
+
+	sort.Strings(names)
+
+	return names, nil
+}
+
+// workManifests converts the rendered resources of hr to the manifests of its
+// ManifestWorks, preceded by the target namespace if hr creates it.
+func workManifests(hr *appv1.HelmRelease, namespace string, objects []*unstructured.Unstructured) ([]workv1.Manifest, error) {
+	if hr.Repo.CreateNamespace {
+		ns := &corev1.Namespace{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "Namespace"},
+			ObjectMeta: metav1.ObjectMeta{Name: namespace},
+		}
+
+		if md := hr.Repo.NamespaceMetadata; md != nil {
+			ns.SetLabels(md.Labels)
+			ns.SetAnnotations(md.Annotations)
+		}
+
+		content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(ns)
+		if err != nil {
+			return nil, err
+		}
+
+		objects = append([]*unstructured.Unstructured{{Object: content}}, objects...)
+	}
+
+	manifests := make([]workv1.Manifest, 0, len(objects))
+
+	for _, u := range objects {
+		raw, err := json.Marshal(u.Object)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal %s %s: %w", u.GetKind(), u.GetName(), err)
+		}
+
+		manifests = append(manifests, workv1.Manifest{RawExtension: runtime.RawExtension{Raw: raw}})
+	}
+
+	return manifests, nil
+}
+
+// applyManifestWork creates the ManifestWork of hr in the namespace of
+// cluster, or updates the existing work, and returns it.
+func (r *ReconcileHelmRelease) applyManifestWork(hr *appv1.HelmRelease, cluster string, work *workv1.ManifestWork,
+	digest string, manifests []workv1.Manifest) (*workv1.ManifestWork, error) {
+	if work == nil {
+		work = &workv1.ManifestWork{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        manifestWorkName(hr),
+				Namespace:   cluster,
+				Labels:      manifestWorkLabels(hr),
+				Annotations: map[string]string{releaseDigestAnnotation: digest},
+			},
+			Spec: workv1.ManifestWorkSpec{
+				Workload: workv1.ManifestsTemplate{Manifests: manifests},
+			},
+		}
+
+		logFor(hr).Info("Creating ManifestWork", "manifestwork", cluster+"/"+work.GetName())
+
+		return work, r.GetClient().Create(context.TODO(), work)
+	}
+
+	if reflect.DeepEqual(work.Spec.Workload.Manifests, manifests) && work.GetAnnotations()[releaseDigestAnnotation] == digest {
+		return work, nil
+	}
+
+	logFor(hr).Info("Updating ManifestWork", "manifestwork", cluster+"/"+work.GetName())
+
+	work.Spec.Workload.Manifests = manifests
+
+	annotations := work.GetAnnotations()
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+
+	annotations[releaseDigestAnnotation] = digest
+	work.SetAnnotations(annotations)
+
+	return work, r.GetClient().Update(context.TODO(), work)
+}
+
+// getManifestWork returns the ManifestWork of hr in the namespace of cluster,
+// nil if it does not exist.
+func (r *ReconcileHelmRelease) getManifestWork(hr *appv1.HelmRelease, cluster string) (*workv1.ManifestWork, error) {
+	work := &workv1.ManifestWork{}
+
+	err := r.GetAPIReader().Get(context.TODO(), types.NamespacedName{Namespace: cluster, Name: manifestWorkName(hr)}, work)
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return work, nil
+}
+
+// deleteManifestWorks deletes the ManifestWorks of hr in the namespaces of the
+// clusters not kept.
+func (r *ReconcileHelmRelease) deleteManifestWorks(hr *appv1.HelmRelease, keep map[string]bool) error {
+	works := &workv1.ManifestWorkList{}
+	if err := r.GetAPIReader().List(context.TODO(), works, manifestWorkLabels(hr)); err != nil {
+		return fmt.Errorf("failed to list ManifestWorks: %w", err)
+	}
+
+	for i := range works.Items {
+		work := &works.Items[i]
+		if keep[work.GetNamespace()] {
+			continue
+		}
+
+		logFor(hr).Info("Deleting ManifestWork", "manifestwork", work.GetNamespace()+"/"+work.GetName())
+
+		if err := r.GetClient().Delete(context.TODO(), work); err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("failed to delete ManifestWork %s/%s: %w", work.GetNamespace(), work.GetName(), err)
+		}
+	}
+
+	return nil
+}
+
+// manifestWorkStatus returns the state of the ManifestWork of cluster reported
+// by its work agent, nothing if it is not created yet.
+func manifestWorkStatus(cluster string, work *workv1.ManifestWork) appv1.HelmAppClusterStatus {
+	if work == nil {
+		return appv1.HelmAppClusterStatus{Cluster: cluster}
+	}
+
+	status := appv1.HelmAppClusterStatus{
+		Cluster: cluster,
+		Digest:  work.GetAnnotations()[releaseDigestAnnotation],
+	}
+
+	for _, c := range work.Status.Conditions {
+		switch c.Type {
+		case workv1.WorkApplied:
+			status.Applied = c.Status == metav1.ConditionTrue
+		case workv1.WorkAvailable:
+			status.Available = c.Status == metav1.ConditionTrue
+		}
+
+		status.Conditions = append(status.Conditions, appv1.HelmAppCondition{
+			Type:               appv1.HelmAppConditionType(c.Type),
+			Status:             appv1.ConditionStatus(c.Status),
+			Reason:             appv1.HelmAppConditionReason(c.Reason),
+			Message:            c.Message,
+			LastTransitionTime: c.LastTransitionTime,
+		})
+	}
+
+	return status
+}
+
+// clustersReadyCondition aggregates the state of the ManifestWorks of the
+// selected clusters into the ResourcesReady condition, with the failure
+// messages of the clusters whose work agent failed to apply the resources.
+func clustersReadyCondition(statuses []appv1.HelmAppClusterStatus) appv1.HelmAppCondition {
+	var failed, pending []string
+
+	for _, s := range statuses {
+		switch {
+		case s.Available:
+		case workFailure(s) != "":
+			failed = append(failed, fmt.Sprintf("%s: %s", s.Cluster, workFailure(s)))
+		default:
+			pending = append(pending, s.Cluster)
+		}
+	}
+
+	switch {
+	case len(failed) != 0:
+		return appv1.HelmAppCondition{
+			Type:    appv1.ConditionResourcesReady,
+			Status:  appv1.StatusFalse,
+			Reason:  appv1.ReasonResourcesFailed,
+			Message: strings.Join(failed, "; "),
+		}
+	case len(pending) != 0:
+		return appv1.HelmAppCondition{
+			Type:    appv1.ConditionResourcesReady,
+			Status:  appv1.StatusFalse,
+			Reason:  appv1.ReasonResourcesInProgress,
+			Message: fmt.Sprintf("%d/%d ManagedClusters available, waiting for %s", len(statuses)-len(pending), len(statuses), strings.Join(pending, ", ")),
+		}
+	default:
+		return appv1.HelmAppCondition{
+			Type:    appv1.ConditionResourcesReady,
+			Status:  appv1.StatusTrue,
+			Reason:  appv1.ReasonResourcesCurrent,
+			Message: fmt.Sprintf("%d/%d ManagedClusters available", len(statuses), len(statuses)),
+		}
+	}
+}
+
+// workFailure returns the message of the Applied or Available condition of
+// the ManifestWork of a cluster set to False by its work agent, if any.
+func workFailure(s appv1.HelmAppClusterStatus) string {
+	for _, c := range s.Conditions {
+		t := string(c.Type)
+		if (t == workv1.WorkApplied || t == workv1.WorkAvailable) && c.Status == appv1.StatusFalse {
+			return c.Message
+		}
+	}
+
+	return ""
+}
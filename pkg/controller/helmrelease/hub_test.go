@@ -0,0 +1,124 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helmrelease
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/onsi/gomega"
+	workv1 "github.com/open-cluster-management/api/work/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+func TestWorkManifests(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	hr := &appv1.HelmRelease{ObjectMeta: metav1.ObjectMeta{Name: "webapp", Namespace: "default"}}
+	hr.Repo.TargetNamespace = "team-a"
+
+	cm := &unstructured.Unstructured{}
+	cm.SetAPIVersion("v1")
+	cm.SetKind("ConfigMap")
+	cm.SetName("webapp")
+
+	manifests, err := workManifests(hr, "team-a", []*unstructured.Unstructured{cm})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(manifests).To(gomega.HaveLen(1))
+	g.Expect(manifests[0].Raw).To(gomega.MatchJSON(`{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"webapp"}}`))
+
+	// the created target namespace comes first
+	hr.Repo.CreateNamespace = true
+	hr.Repo.NamespaceMetadata = &appv1.NamespaceMetadata{Labels: map[string]string{"team": "a"}}
+
+	manifests, err = workManifests(hr, "team-a", []*unstructured.Unstructured{cm})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(manifests).To(gomega.HaveLen(2))
+
+	ns := &unstructured.Unstructured{}
+	g.Expect(json.Unmarshal(manifests[0].Raw, &ns.Object)).To(gomega.Succeed())
+	g.Expect(ns.GetKind()).To(gomega.Equal("Namespace"))
+	g.Expect(ns.GetName()).To(gomega.Equal("team-a"))
+	g.Expect(ns.GetLabels()).To(gomega.Equal(map[string]string{"team": "a"}))
+}
+
+func TestManifestWorkStatus(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	work := &workv1.ManifestWork{}
+	work.SetAnnotations(map[string]string{releaseDigestAnnotation: "digest"})
+	g.Expect(manifestWorkStatus("cluster1", work)).To(gomega.Equal(appv1.HelmAppClusterStatus{
+		Cluster: "cluster1",
+		Digest:  "digest",
+	}))
+
+	work.Status.Conditions = []metav1.Condition{
+		{Type: workv1.WorkApplied, Status: metav1.ConditionTrue},
+		{Type: workv1.WorkAvailable, Status: metav1.ConditionFalse, Message: "0/1 available"},
+	}
+
+	status := manifestWorkStatus("cluster1", work)
+	g.Expect(status.Applied).To(gomega.BeTrue())
+	g.Expect(status.Available).To(gomega.BeFalse())
+	g.Expect(status.Conditions).To(gomega.HaveLen(2))
+	g.Expect(workFailure(status)).To(gomega.Equal("0/1 available"))
+}
+
+func TestClustersReadyCondition(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	available := appv1.HelmAppClusterStatus{Cluster: "cluster1", Applied: true, Available: true}
+	pending := appv1.HelmAppClusterStatus{Cluster: "cluster2"}
+	failed := appv1.HelmAppClusterStatus{Cluster: "cluster3", Conditions: []appv1.HelmAppCondition{{
+		Type:    appv1.HelmAppConditionType(workv1.WorkApplied),
+		Status:  appv1.StatusFalse,
+		Message: "forbidden",
+	}}}
+
+	ready := clustersReadyCondition([]appv1.HelmAppClusterStatus{available})
+	g.Expect(ready.Status).To(gomega.Equal(appv1.StatusTrue))
+	g.Expect(ready.Message).To(gomega.Equal("1/1 ManagedClusters available"))
+
+	ready = clustersReadyCondition([]appv1.HelmAppClusterStatus{available, pending})
+	g.Expect(ready.Reason).To(gomega.Equal(appv1.ReasonResourcesInProgress))
+	g.Expect(ready.Message).To(gomega.Equal("1/2 ManagedClusters available, waiting for cluster2"))
+
+	// the failures are reported first
+	ready = clustersReadyCondition([]appv1.HelmAppClusterStatus{available, pending, failed})
+	g.Expect(ready.Reason).To(gomega.Equal(appv1.ReasonResourcesFailed))
+	g.Expect(ready.Message).To(gomega.Equal("cluster3: forbidden"))
+}
+
+func TestHelmReleaseOfWork(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	hr := &appv1.HelmRelease{ObjectMeta: metav1.ObjectMeta{Name: "webapp", Namespace: "default"}}
+
+	work := &workv1.ManifestWork{ObjectMeta: metav1.ObjectMeta{Name: manifestWorkName(hr), Namespace: "cluster1"}}
+	g.Expect(helmReleaseOfWork(handler.MapObject{Meta: work, Object: work})).To(gomega.BeEmpty())
+
+	work.SetLabels(manifestWorkLabels(hr))
+	g.Expect(helmReleaseOfWork(handler.MapObject{Meta: work, Object: work})).To(gomega.Equal([]reconcile.Request{{
+		NamespacedName: types.NamespacedName{Namespace: "default", Name: "webapp"},
+	}}))
+}
@@ -0,0 +1,167 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helmrelease
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	v1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+	"github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/release"
+)
+
+// Modes of the garbage collection of the release records left behind by
+// HelmReleases deleted without uninstalling their release, e.g. deleted while
+// the operator was down or with their finalizer stripped.
+const (
+	RecordGCDisabled = "disabled"
+	RecordGCDryRun   = "dry-run"
+	RecordGCEnabled  = "enabled"
+)
+
+// recordGCInterval is how often the orphaned release records are collected
+const recordGCInterval = time.Hour
+
+// recordOwner identifies the HelmRelease of a set of release records
+type recordOwner struct {
+	storageNamespace string
+	types.NamespacedName
+}
+
+// newRecordJanitor returns a runnable collecting the orphaned release records
+// every recordGCInterval until the manager stops.
+func newRecordJanitor(mgr manager.Manager, mode string) manager.Runnable {
+	return manager.RunnableFunc(func(stop <-chan struct{}) error {
+		wait.Until(func() {
+			if err := collectReleaseRecords(mgr, mode); err != nil {
+				log.Error(err, "Failed to collect orphaned release records")
+			}
+		}, recordGCInterval, stop)
+
+		return nil
+	})
+}
+
+// collectReleaseRecords deletes, or only reports in dry-run mode, the release
+// records labeled with a HelmRelease that no longer exists.
+func collectReleaseRecords(mgr manager.Manager, mode string) error {
+	if Options.Storage.Driver == release.SQLStorageDriver {
+		log.V(1).Info("Release records garbage collection is not supported by the sql storage driver")
+		return nil
+	}
+
+	owners, err := listRecordOwners(mgr)
+	if err != nil {
+		return err
+	}
+
+	for owner := range owners {
+		// the HelmReleases of the namespaces not watched are another operator's
+		if !watchesNamespace(owner.Namespace) {
+			continue
+		}
+
+		err := mgr.GetAPIReader().Get(context.TODO(), owner.NamespacedName, &appv1.HelmRelease{})
+		if err == nil || !apierrors.IsNotFound(err) {
+			continue
+		}
+
+		if mode == RecordGCDryRun {
+			log.Info("[dry-run] Release records of deleted HelmRelease would be deleted",
+				"helmrelease", owner.Namespace+"/"+owner.Name, "storageNamespace", owner.storageNamespace)
+
+			continue
+		}
+
+		deleted, err := release.DeleteReleaseRecords(mgr.GetConfig(), Options.Storage, owner.storageNamespace,
+			owner.Name, owner.Namespace)
+		if err != nil {
+			log.Error(err, "Failed to delete release records of deleted HelmRelease", "helmrelease", owner.Namespace+"/"+owner.Name)
+			continue
+		}
+
+		log.Info("Deleted release records of deleted HelmRelease", "records", deleted,
+			"helmrelease", owner.Namespace+"/"+owner.Name, "storageNamespace", owner.storageNamespace)
+	}
+
+	return nil
+}
+
+// listRecordOwners returns the HelmReleases having release records in the cluster.
+func listRecordOwners(mgr manager.Manager) (map[recordOwner]bool, error) {
+	clientv1, err := v1.NewForConfig(mgr.GetConfig())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get core/v1 client: %w", err)
+	}
+
+	listOptions := metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("owner=helm,%s,%s", release.HelmReleaseNameLabel, release.HelmReleaseNamespaceLabel),
+	}
+
+	// the records are only listed in the watched namespaces, the operator may
+	// not be allowed to list them anywhere else
+	namespaces := Options.WatchNamespaces
+	if len(namespaces) == 0 {
+		namespaces = []string{metav1.NamespaceAll}
+	}
+
+	var objects []metav1.ObjectMeta
+
+	for _, namespace := range namespaces {
+		if Options.Storage.Driver == release.ConfigMapsStorageDriver {
+			configMaps, err := clientv1.ConfigMaps(namespace).List(context.TODO(), listOptions)
+			if err != nil {
+				return nil, err
+			}
+
+			for _, cm := range configMaps.Items {
+				objects = append(objects, cm.ObjectMeta)
+			}
+		} else {
+			secrets, err := clientv1.Secrets(namespace).List(context.TODO(), listOptions)
+			if err != nil {
+				return nil, err
+			}
+
+			for _, s := range secrets.Items {
+				objects = append(objects, s.ObjectMeta)
+			}
+		}
+	}
+
+	owners := make(map[recordOwner]bool)
+
+	for _, o := range objects {
+		owners[recordOwner{
+			storageNamespace: o.Namespace,
+			NamespacedName: types.NamespacedName{
+				Namespace: o.Labels[release.HelmReleaseNamespaceLabel],
+				Name:      o.Labels[release.HelmReleaseNameLabel],
+			},
+		}] = true
+	}
+
+	return owners, nil
+}
@@ -0,0 +1,87 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helmrelease
+
+import (
+	"context"
+	"testing"
+
+	"github.com/onsi/gomega"
+	rpb "helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/storage/driver"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	v1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+
+	"github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/release"
+)
+
+func TestCollectReleaseRecords(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	mgr, err := manager.New(cfg, manager.Options{MetricsBindAddress: "0"})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	clientv1, err := v1.NewForConfig(cfg)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	// the release record of a HelmRelease deleted without uninstalling its release
+	records := driver.NewSecrets(clientv1.Secrets(helmReleaseNS))
+	g.Expect(records.Create("sh.helm.release.v1.records-gc.v1", &rpb.Release{
+		Name:      "records-gc",
+		Namespace: helmReleaseNS,
+		Version:   1,
+		Info:      &rpb.Info{Status: rpb.StatusDeployed},
+	})).To(gomega.Succeed())
+
+	// labeled with its HelmRelease like the installs do
+	secret, err := clientv1.Secrets(helmReleaseNS).Get(context.TODO(), "sh.helm.release.v1.records-gc.v1",
+		metav1.GetOptions{})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	secret.Labels[release.HelmReleaseNameLabel] = "records-gc"
+	secret.Labels[release.HelmReleaseNamespaceLabel] = helmReleaseNS
+	_, err = clientv1.Secrets(helmReleaseNS).Update(context.TODO(), secret, metav1.UpdateOptions{})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	recordsLeft := func() int {
+		secrets, err := clientv1.Secrets(helmReleaseNS).List(context.TODO(), metav1.ListOptions{
+			LabelSelector: release.HelmReleaseNameLabel + "=records-gc",
+		})
+		g.Expect(err).NotTo(gomega.HaveOccurred())
+
+		return len(secrets.Items)
+	}
+
+	g.Expect(recordsLeft()).To(gomega.Equal(1))
+
+	g.Expect(collectReleaseRecords(mgr, RecordGCDryRun)).To(gomega.Succeed())
+	g.Expect(recordsLeft()).To(gomega.Equal(1))
+
+	// the records of the namespaces not watched are another operator's
+	watched := Options.WatchNamespaces
+	Options.WatchNamespaces = []string{"records-gc-other"}
+
+	err = collectReleaseRecords(mgr, RecordGCEnabled)
+	Options.WatchNamespaces = watched
+
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(recordsLeft()).To(gomega.Equal(1))
+
+	g.Expect(collectReleaseRecords(mgr, RecordGCEnabled)).To(gomega.Succeed())
+	g.Expect(recordsLeft()).To(gomega.Equal(0))
+}
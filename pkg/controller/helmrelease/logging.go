@@ -0,0 +1,118 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helmrelease
+
+import (
+	"sync"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/uuid"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+	"github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/release"
+)
+
+// debugLogsAnnotation logs the debug entries of a HelmRelease whatever the log
+// level of the operator when set to "true"
+const debugLogsAnnotation = "apps.open-cluster-management.io/debug-logs"
+
+// log is the structured logger of the controller, delegating to the logger
+// set with ctrl.SetLogger
+var log = logf.Log.WithName("helmrelease")
+
+// reconcileIDs are the ids of the running reconciles of the HelmReleases. A
+// HelmRelease is never reconciled by two workers at once.
+var reconcileIDs sync.Map
+
+// beginReconcileLog assigns a new reconcile id to the HelmRelease name, logged
+// with each entry of the reconcile. The returned function forgets it.
+func beginReconcileLog(name types.NamespacedName) func() {
+	reconcileIDs.Store(name, string(uuid.NewUUID()))
+
+	return func() {
+		reconcileIDs.Delete(name)
+	}
+}
+
+// logFor returns the logger of the entries about hr, carrying the fields
+// identifying it so that they can be filtered per release: the HelmRelease,
+// its release, its chart and the id of the running reconcile.
+func logFor(hr *appv1.HelmRelease) logr.Logger {
+	name := types.NamespacedName{Namespace: hr.GetNamespace(), Name: hr.GetName()}
+
+	releaseName := release.ReleaseNameFor(hr.GetName())
+	if hr.Repo.ReleaseName != "" {
+		releaseName = hr.Repo.ReleaseName
+	}
+
+	if hr.Status.DeployedRelease != nil && hr.Status.DeployedRelease.Name != "" {
+		releaseName = hr.Status.DeployedRelease.Name
+	}
+
+	values := []interface{}{"helmrelease", name.String(), "release", releaseName}
+
+	if hr.Repo.ChartName != "" {
+		values = append(values, "chart", hr.Repo.ChartName)
+	}
+
+	if hr.Repo.Version != "" {
+		values = append(values, "version", hr.Repo.Version)
+	}
+
+	if id, ok := reconcileIDs.Load(name); ok {
+		values = append(values, "reconcileID", id)
+	}
+
+	logger := log.WithValues(values...)
+
+	if hr.GetAnnotations()[debugLogsAnnotation] == "true" {
+		return debugLogger{logger}
+	}
+
+	return logger
+}
+
+// debugLogger logs the V(n) entries at the info level, with their verbosity in
+// a debug field, so that they are logged whatever the log level.
+type debugLogger struct {
+	logr.Logger
+}
+
+func (l debugLogger) V(level int) logr.Logger {
+	return debugLogger{l.Logger.WithValues("debug", level)}
+}
+
+func (l debugLogger) WithValues(keysAndValues ...interface{}) logr.Logger {
+	return debugLogger{l.Logger.WithValues(keysAndValues...)}
+}
+
+func (l debugLogger) WithName(name string) logr.Logger {
+	return debugLogger{l.Logger.WithName(name)}
+}
+
+// resourceName identifies u in the log entries, e.g. apps/v1, Kind=Deployment web/nginx.
+func resourceName(u *unstructured.Unstructured) string {
+	name := u.GetName()
+	if u.GetNamespace() != "" {
+		name = u.GetNamespace() + "/" + name
+	}
+
+	return u.GroupVersionKind().String() + " " + name
+}
@@ -0,0 +1,106 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helmrelease
+
+import (
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/types"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+// valuesLogger is a logger keeping the fields set with WithValues.
+type valuesLogger struct {
+	logr.Logger
+	fields map[string]interface{}
+}
+
+func (l valuesLogger) WithValues(keysAndValues ...interface{}) logr.Logger {
+	fields := map[string]interface{}{}
+	for k, v := range l.fields {
+		fields[k] = v
+	}
+
+	for i := 0; i+1 < len(keysAndValues); i += 2 {
+		fields[keysAndValues[i].(string)] = keysAndValues[i+1]
+	}
+
+	return valuesLogger{Logger: l.Logger, fields: fields}
+}
+
+func TestLogFor(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	defer func(l logr.Logger) { log = l }(log)
+	log = valuesLogger{Logger: log}
+
+	hr := &appv1.HelmRelease{}
+	hr.SetNamespace("default")
+	hr.SetName("webapp")
+
+	fields := logFor(hr).(valuesLogger).fields
+	g.Expect(fields).To(gomega.Equal(map[string]interface{}{"helmrelease": "default/webapp", "release": "webapp"}))
+
+	// the chart, the deployed release and the running reconcile are logged
+	hr.Repo.ChartName = "nginx"
+	hr.Repo.Version = "1.0.0"
+	hr.Status.DeployedRelease = &appv1.HelmAppRelease{Name: "webapp-abcde"}
+
+	end := beginReconcileLog(types.NamespacedName{Namespace: "default", Name: "webapp"})
+
+	fields = logFor(hr).(valuesLogger).fields
+	g.Expect(fields).To(gomega.HaveKeyWithValue("release", "webapp-abcde"))
+	g.Expect(fields).To(gomega.HaveKeyWithValue("chart", "nginx"))
+	g.Expect(fields).To(gomega.HaveKeyWithValue("version", "1.0.0"))
+	g.Expect(fields).To(gomega.HaveKey("reconcileID"))
+
+	reconcileID := fields["reconcileID"]
+
+	end()
+	g.Expect(logFor(hr).(valuesLogger).fields).NotTo(gomega.HaveKey("reconcileID"))
+
+	// the next reconcile has its own id
+	end = beginReconcileLog(types.NamespacedName{Namespace: "default", Name: "webapp"})
+	defer end()
+
+	g.Expect(logFor(hr).(valuesLogger).fields["reconcileID"]).NotTo(gomega.Equal(reconcileID))
+}
+
+func TestLogForDebug(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	defer func(l logr.Logger) { log = l }(log)
+	log = valuesLogger{Logger: log}
+
+	hr := &appv1.HelmRelease{}
+	hr.SetNamespace("default")
+	hr.SetName("webapp")
+
+	_, ok := logFor(hr).(debugLogger)
+	g.Expect(ok).To(gomega.BeFalse())
+
+	// the V(n) entries of the debugged HelmReleases are logged with their verbosity
+	hr.SetAnnotations(map[string]string{debugLogsAnnotation: "true"})
+
+	logger, ok := logFor(hr).V(1).(debugLogger)
+	g.Expect(ok).To(gomega.BeTrue())
+	g.Expect(logger.Logger.(valuesLogger).fields).To(gomega.HaveKeyWithValue("debug", 1))
+	g.Expect(logger.Logger.(valuesLogger).fields).To(gomega.HaveKeyWithValue("helmrelease", "default/webapp"))
+}
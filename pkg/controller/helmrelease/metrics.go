@@ -0,0 +1,168 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helmrelease
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+// states of the HelmReleases reported by the helmrelease_state metric
+const (
+	stateReady       = "Ready"
+	stateFailed      = "Failed"
+	stateProgressing = "Progressing"
+	stateSuspended   = "Suspended"
+)
+
+var (
+	releaseState = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "helmrelease_state",
+		Help: "State of the HelmReleases, 1 for the current state and deployed chart version of each.",
+	}, []string{"namespace", "name", "state", "chart", "chart_version"})
+
+	releaseReadyTransition = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "helmrelease_ready_last_transition_timestamp_seconds",
+		Help: "Time the Ready condition of the HelmReleases last changed, in seconds since the epoch.",
+	}, []string{"namespace", "name"})
+
+	reconcileDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "helmrelease_reconcile_duration_seconds",
+		Help: "Duration of the reconciles of the HelmReleases, from their dequeue to their completion.",
+		// the installs and upgrades waiting for their resources take minutes
+		Buckets: []float64{0.1, 0.5, 1, 2.5, 5, 10, 30, 60, 120, 300, 600},
+	}, []string{"result"})
+
+	reconcileWorkers = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "helmrelease_reconcile_workers",
+		Help: "Number of HelmReleases reconciled in parallel at most.",
+	})
+
+	reconcileWorkersBusy = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "helmrelease_reconcile_workers_busy",
+		Help: "Number of HelmReleases being reconciled.",
+	})
+
+	statusUpdates = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "helmrelease_status_updates_total",
+		Help: "Status updates of the HelmReleases, written, coalesced with a later one or unchanged.",
+	}, []string{"result"})
+
+	// releaseStateLabels are the labels of the current helmrelease_state
+	// series of each HelmRelease, so that the previous one is deleted
+	releaseStateLabels   = map[types.NamespacedName][]string{}
+	releaseStateLabelsMu sync.Mutex
+)
+
+func init() {
+	metrics.Registry.MustRegister(releaseState, releaseReadyTransition, summaryCollector{},
+		reconcileDuration, reconcileWorkers, reconcileWorkersBusy, statusUpdates)
+}
+
+// stateOf returns the state of hr reported by the helmrelease_state metric,
+// derived from its standard conditions.
+func stateOf(hr *appv1.HelmRelease) string {
+	conditionTrue := func(t appv1.HelmAppConditionType) bool {
+		c := hr.Status.GetCondition(t)
+		return c != nil && c.Status == appv1.StatusTrue
+	}
+
+	switch {
+	case conditionTrue(appv1.ConditionSuspended):
+		return stateSuspended
+	case conditionTrue(appv1.ConditionReady):
+		return stateReady
+	case conditionTrue(appv1.ConditionStalled), conditionTrue(appv1.ConditionReleaseFailed):
+		return stateFailed
+	default:
+		return stateProgressing
+	}
+}
+
+// recordReleaseState publishes the state of hr and the version of its
+// deployed chart.
+func recordReleaseState(hr *appv1.HelmRelease) {
+	version := ""
+	if hr.Status.DeployedRelease != nil {
+		version = hr.Status.DeployedRelease.ChartVersion
+	}
+
+	state := stateOf(hr)
+	labels := []string{hr.GetNamespace(), hr.GetName(), state, hr.Repo.ChartName, version}
+	name := types.NamespacedName{Namespace: hr.GetNamespace(), Name: hr.GetName()}
+
+	recordSummary(hr, state)
+
+	releaseStateLabelsMu.Lock()
+	defer releaseStateLabelsMu.Unlock()
+
+	if previous, ok := releaseStateLabels[name]; ok {
+		releaseState.DeleteLabelValues(previous...)
+	}
+
+	releaseState.WithLabelValues(labels...).Set(1)
+	releaseStateLabels[name] = labels
+
+	if ready := hr.Status.GetCondition(appv1.ConditionReady); ready != nil {
+		releaseReadyTransition.WithLabelValues(name.Namespace, name.Name).Set(float64(ready.LastTransitionTime.Unix()))
+	}
+}
+
+// forgetReleaseState deletes the metrics of the HelmRelease name, e.g. once
+// it is deleted.
+func forgetReleaseState(name types.NamespacedName) {
+	releaseStateLabelsMu.Lock()
+	defer releaseStateLabelsMu.Unlock()
+
+	if previous, ok := releaseStateLabels[name]; ok {
+		releaseState.DeleteLabelValues(previous...)
+		delete(releaseStateLabels, name)
+	}
+
+	releaseReadyTransition.DeleteLabelValues(name.Namespace, name.Name)
+	forgetSummary(name)
+}
+
+// measuredReconciler measures the reconciles of the HelmReleases and the
+// workers busy running them, so that too few workers show up.
+type measuredReconciler struct {
+	reconcile.Reconciler
+}
+
+func (r measuredReconciler) Reconcile(request reconcile.Request) (reconcile.Result, error) {
+	reconcileWorkersBusy.Inc()
+	defer reconcileWorkersBusy.Dec()
+
+	start := time.Now()
+	result, err := r.Reconciler.Reconcile(request)
+
+	outcome := "success"
+	if err != nil {
+		outcome = "error"
+	}
+
+	reconcileDuration.WithLabelValues(outcome).Observe(time.Since(start).Seconds())
+
+	return result, err
+}
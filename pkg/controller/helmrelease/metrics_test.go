@@ -0,0 +1,140 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helmrelease
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+func TestStateOf(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	withCondition := func(t appv1.HelmAppConditionType) *appv1.HelmRelease {
+		hr := &appv1.HelmRelease{}
+		hr.Status.SetCondition(appv1.HelmAppCondition{Type: t, Status: appv1.StatusTrue})
+
+		return hr
+	}
+
+	g.Expect(stateOf(&appv1.HelmRelease{})).To(gomega.Equal(stateProgressing))
+	g.Expect(stateOf(withCondition(appv1.ConditionReady))).To(gomega.Equal(stateReady))
+	g.Expect(stateOf(withCondition(appv1.ConditionStalled))).To(gomega.Equal(stateFailed))
+	g.Expect(stateOf(withCondition(appv1.ConditionReleaseFailed))).To(gomega.Equal(stateFailed))
+	g.Expect(stateOf(withCondition(appv1.ConditionSuspended))).To(gomega.Equal(stateSuspended))
+}
+
+func TestRecordReleaseState(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	hr := &appv1.HelmRelease{ObjectMeta: metav1.ObjectMeta{Name: "metrics", Namespace: "default"}}
+	hr.Repo.ChartName = "webapp"
+	name := types.NamespacedName{Namespace: "default", Name: "metrics"}
+
+	defer forgetReleaseState(name)
+
+	recordReleaseState(hr)
+	g.Expect(testutil.ToFloat64(releaseState.WithLabelValues("default", "metrics", stateProgressing, "webapp", ""))).
+		To(gomega.Equal(1.0))
+
+	// the series of the previous state is replaced
+	transition := metav1.NewTime(time.Unix(1600000000, 0))
+	hr.Status.DeployedRelease = &appv1.HelmAppRelease{ChartVersion: "1.0.0"}
+	hr.Status.SetCondition(appv1.HelmAppCondition{Type: appv1.ConditionReady, Status: appv1.StatusTrue})
+	hr.Status.GetCondition(appv1.ConditionReady).LastTransitionTime = transition
+
+	recordReleaseState(hr)
+	g.Expect(releaseStateSeries(name)).To(gomega.Equal([]string{"default", "metrics", stateReady, "webapp", "1.0.0"}))
+	g.Expect(testutil.ToFloat64(releaseReadyTransition.WithLabelValues("default", "metrics"))).To(gomega.Equal(1600000000.0))
+
+	before := testutil.CollectAndCount(releaseState)
+	forgetReleaseState(name)
+	g.Expect(testutil.CollectAndCount(releaseState)).To(gomega.Equal(before - 1))
+	g.Expect(releaseStateSeries(name)).To(gomega.BeNil())
+}
+
+// releaseStateSeries returns the labels of the helmrelease_state series of
+// the HelmRelease name.
+func releaseStateSeries(name types.NamespacedName) []string {
+	releaseStateLabelsMu.Lock()
+	defer releaseStateLabelsMu.Unlock()
+
+	return releaseStateLabels[name]
+}
+
+// reconcileDurations returns the number of reconciles measured with result.
+func reconcileDurations(g *gomega.WithT, result string) uint64 {
+	families, err := metrics.Registry.Gather()
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	for _, f := range families {
+		if f.GetName() != "helmrelease_reconcile_duration_seconds" {
+			continue
+		}
+
+		for _, m := range f.GetMetric() {
+			for _, l := range m.GetLabel() {
+				if l.GetName() == "result" && l.GetValue() == result {
+					return m.GetHistogram().GetSampleCount()
+				}
+			}
+		}
+	}
+
+	return 0
+}
+
+func TestMeasuredReconciler(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	hold := make(chan struct{})
+	reconcileErr := errors.New("install failed")
+
+	r := measuredReconciler{reconcile.Func(func(reconcile.Request) (reconcile.Result, error) {
+		<-hold
+		return reconcile.Result{}, reconcileErr
+	})}
+
+	failed := reconcileDurations(g, "error")
+	reconciled := make(chan error, 1)
+
+	go func() {
+		_, err := r.Reconcile(reconcile.Request{})
+		reconciled <- err
+	}()
+
+	// the worker is busy until the reconcile completes
+	g.Eventually(func() float64 {
+		return testutil.ToFloat64(reconcileWorkersBusy)
+	}, 5*time.Second, 10*time.Millisecond).Should(gomega.Equal(1.0))
+
+	close(hold)
+	g.Eventually(reconciled, 5*time.Second).Should(gomega.Receive(gomega.Equal(reconcileErr)))
+
+	g.Expect(testutil.ToFloat64(reconcileWorkersBusy)).To(gomega.BeZero())
+	g.Expect(reconcileDurations(g, "error")).To(gomega.Equal(failed + 1))
+}
@@ -0,0 +1,58 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helmrelease
+
+import (
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+	"github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/release"
+)
+
+// nameConflict refuses hr, whose release name is already used in its
+// storage namespace, with the NameConflict condition instead of letting both
+// upgrade the same release in turn. A deleted HelmRelease never owned the
+// release, its finalizer is removed without uninstalling anything.
+func (r *ReconcileHelmRelease) nameConflict(hr *appv1.HelmRelease, conflict *release.ErrNameConflict) (reconcile.Result, error) {
+	logFor(hr).Error(conflict, "Release name conflict")
+
+	if hr.GetDeletionTimestamp() != nil {
+		if !contains(hr.GetFinalizers(), finalizer) {
+			return reconcile.Result{}, nil
+		}
+
+		controllerutil.RemoveFinalizer(hr, finalizer)
+
+		return reconcile.Result{}, r.updateResource(hr)
+	}
+
+	if c := hr.Status.GetCondition(appv1.ConditionNameConflict); c == nil || c.Status != appv1.StatusTrue {
+		r.recordWarning(hr, eventNameConflict, conflict)
+	}
+
+	hr.Status.SetCondition(appv1.HelmAppCondition{
+		Type:    appv1.ConditionNameConflict,
+		Status:  appv1.StatusTrue,
+		Reason:  appv1.ReasonReleaseNameInUse,
+		Message: conflict.Error(),
+	})
+	delay := retryAfter(hr)
+	_ = r.updateResourceStatus(hr)
+
+	return reconcile.Result{RequeueAfter: delay}, nil
+}
@@ -0,0 +1,211 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helmrelease
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/ghodss/yaml"
+	corev1 "k8s.io/api/core/v1"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+// notificationsAnnotation opts a HelmRelease out of the notifications when set
+// to "disabled"
+const notificationsAnnotation = "apps.open-cluster-management.io/notifications"
+
+// types of the notification sinks
+const (
+	SinkWebhook = "webhook"
+	SinkSlack   = "slack"
+	SinkTeams   = "teams"
+)
+
+// notifiedEvents are the events posted to the notification sinks by default:
+// the outcomes of the installs, upgrades and rollbacks and the failures
+var notifiedEvents = []string{
+	eventInstallSucceeded, eventInstallFailed,
+	eventUpgradeSucceeded, eventUpgradeFailed,
+	eventRollbackSucceeded, eventRollbackFailed,
+	eventUninstallFailed,
+}
+
+// notificationClient posts the notifications, a hung sink must not pile up
+// the notifications
+var notificationClient = &http.Client{Timeout: 10 * time.Second}
+
+// NotificationSink is a chat channel or a webhook the events of the
+// HelmReleases are posted to
+type NotificationSink struct {
+	// Name identifies the sink in the logs
+	Name string `json:"name"`
+	// Type is webhook, slack or teams
+	Type string `json:"type"`
+	// URL is the URL of the webhook, e.g. the incoming webhook of a Slack channel
+	URL string `json:"url"`
+	// Events are the reasons of the events posted, the outcomes of the installs,
+	// upgrades and rollbacks and the failures if empty
+	Events []string `json:"events,omitempty"`
+	// Namespaces restricts the sink to the HelmReleases of these namespaces, all
+	// the namespaces if empty
+	Namespaces []string `json:"namespaces,omitempty"`
+}
+
+// notificationConfig is the notification configuration file
+type notificationConfig struct {
+	Sinks []NotificationSink `json:"sinks"`
+}
+
+// notification is the payload of the webhook sinks
+type notification struct {
+	// HelmRelease is the namespace/name of the HelmRelease
+	HelmRelease  string    `json:"helmRelease"`
+	Release      string    `json:"release,omitempty"`
+	Chart        string    `json:"chart,omitempty"`
+	ChartVersion string    `json:"chartVersion,omitempty"`
+	Type         string    `json:"type"`
+	Reason       string    `json:"reason"`
+	Message      string    `json:"message"`
+	Time         time.Time `json:"time"`
+}
+
+// LoadNotificationSinks reads the notification sinks of the configuration file
+// at path.
+func LoadNotificationSinks(path string) ([]NotificationSink, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	config := notificationConfig{}
+	if err := yaml.Unmarshal(b, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse the notification config %s: %w", path, err)
+	}
+
+	for _, sink := range config.Sinks {
+		switch sink.Type {
+		case SinkWebhook, SinkSlack, SinkTeams:
+		default:
+			return nil, fmt.Errorf("unknown type %q of notification sink %s, webhook, slack or teams", sink.Type, sink.Name)
+		}
+
+		if sink.URL == "" {
+			return nil, fmt.Errorf("notification sink %s has no url", sink.Name)
+		}
+	}
+
+	return config.Sinks, nil
+}
+
+// notify posts the event of hr to the notification sinks it matches, in the
+// background: a notification is best effort and never fails the reconcile.
+func notify(hr *appv1.HelmRelease, eventType, reason, message string) {
+	if len(Options.NotificationSinks) == 0 || hr.GetAnnotations()[notificationsAnnotation] == "disabled" {
+		return
+	}
+
+	n := notification{
+		HelmRelease:  hr.GetNamespace() + "/" + hr.GetName(),
+		Chart:        hr.Repo.ChartName,
+		ChartVersion: hr.Repo.Version,
+		Type:         eventType,
+		Reason:       reason,
+		Message:      message,
+		Time:         time.Now().UTC(),
+	}
+
+	if hr.Status.DeployedRelease != nil {
+		n.Release = hr.Status.DeployedRelease.Name
+		n.ChartVersion = hr.Status.DeployedRelease.ChartVersion
+	}
+
+	for _, sink := range Options.NotificationSinks {
+		if !sink.matches(hr.GetNamespace(), reason) {
+			continue
+		}
+
+		go func(sink NotificationSink) {
+			if err := sink.post(n); err != nil {
+				logFor(hr).Error(err, "Failed to post the notification", "sink", sink.Name, "reason", reason)
+			}
+		}(sink)
+	}
+}
+
+func (s NotificationSink) matches(namespace, reason string) bool {
+	events := s.Events
+	if len(events) == 0 {
+		events = notifiedEvents
+	}
+
+	return contains(events, reason) && (len(s.Namespaces) == 0 || contains(s.Namespaces, namespace))
+}
+
+func (s NotificationSink) post(n notification) error {
+	var payload interface{} = n
+
+	text := fmt.Sprintf("%s %s: %s", n.HelmRelease, n.Reason, n.Message)
+
+	switch s.Type {
+	case SinkSlack:
+		icon := ":white_check_mark:"
+		if n.Type == corev1.EventTypeWarning {
+			icon = ":x:"
+		}
+
+		payload = map[string]string{"text": icon + " " + text}
+	case SinkTeams:
+		color := "2EB886"
+		if n.Type == corev1.EventTypeWarning {
+			color = "D00000"
+		}
+
+		payload = map[string]string{
+			"@type":      "MessageCard",
+			"@context":   "https://schema.org/extensions",
+			"summary":    n.HelmRelease + " " + n.Reason,
+			"themeColor": color,
+			"title":      n.HelmRelease + " " + n.Reason,
+			"text":       n.Message,
+		}
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	resp, err := notificationClient.Post(s.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		// the error has the url, e.g. the token of a Slack webhook
+		return fmt.Errorf("failed to post to the %s sink", s.Type)
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("the %s sink returned %s", s.Type, resp.Status)
+	}
+
+	return nil
+}
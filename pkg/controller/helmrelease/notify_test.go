@@ -0,0 +1,118 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helmrelease
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+func TestNotify(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	received := make(chan map[string]interface{}, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		payload := map[string]interface{}{}
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		received <- payload
+	}))
+	defer server.Close()
+
+	defer func(sinks []NotificationSink) { Options.NotificationSinks = sinks }(Options.NotificationSinks)
+	Options.NotificationSinks = []NotificationSink{
+		{Name: "releases", Type: SinkWebhook, URL: server.URL},
+		{Name: "other-team", Type: SinkSlack, URL: server.URL, Namespaces: []string{"other-team"}},
+	}
+
+	hr := &appv1.HelmRelease{}
+	hr.SetNamespace("default")
+	hr.SetName("webapp")
+	hr.Repo.ChartName = "nginx"
+	hr.Status.DeployedRelease = &appv1.HelmAppRelease{Name: "webapp", ChartVersion: "1.0.0"}
+
+	// the install is posted once, to the sink of the namespace
+	notify(hr, corev1.EventTypeNormal, eventInstallSucceeded, "installed")
+
+	payload := map[string]interface{}{}
+	g.Eventually(received, 5*time.Second).Should(gomega.Receive(&payload))
+	g.Expect(payload).To(gomega.HaveKeyWithValue("helmRelease", "default/webapp"))
+	g.Expect(payload).To(gomega.HaveKeyWithValue("chart", "nginx"))
+	g.Expect(payload).To(gomega.HaveKeyWithValue("chartVersion", "1.0.0"))
+	g.Expect(payload).To(gomega.HaveKeyWithValue("reason", eventInstallSucceeded))
+	g.Consistently(received, 200*time.Millisecond).ShouldNot(gomega.Receive())
+
+	// the Slack sinks are posted a text
+	hr.SetNamespace("other-team")
+	notify(hr, corev1.EventTypeWarning, eventUpgradeFailed, "timed out")
+
+	for i := 0; i < 2; i++ {
+		g.Eventually(received, 5*time.Second).Should(gomega.Receive(&payload))
+
+		if text, ok := payload["text"]; ok {
+			g.Expect(text).To(gomega.Equal(":x: other-team/webapp UpgradeFailed: timed out"))
+		}
+	}
+
+	// the events not notified and the HelmReleases opted out are not posted
+	notify(hr, corev1.EventTypeNormal, eventUninstallSucceeded, "uninstalled")
+
+	hr.SetAnnotations(map[string]string{notificationsAnnotation: "disabled"})
+	notify(hr, corev1.EventTypeNormal, eventInstallSucceeded, "installed")
+
+	g.Consistently(received, 200*time.Millisecond).ShouldNot(gomega.Receive())
+}
+
+func TestLoadNotificationSinks(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	dir, err := ioutil.TempDir("", "notifications")
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "sinks.yaml")
+
+	g.Expect(ioutil.WriteFile(path, []byte("sinks:\n- name: releases\n  type: teams\n  url: https://example.com/hook\n"+
+		"  events: [InstallFailed]\n"), 0600)).To(gomega.Succeed())
+
+	sinks, err := LoadNotificationSinks(path)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(sinks).To(gomega.Equal([]NotificationSink{
+		{Name: "releases", Type: SinkTeams, URL: "https://example.com/hook", Events: []string{eventInstallFailed}},
+	}))
+
+	g.Expect(ioutil.WriteFile(path, []byte("sinks:\n- name: releases\n  type: email\n  url: https://example.com/hook\n"),
+		0600)).To(gomega.Succeed())
+
+	_, err = LoadNotificationSinks(path)
+	g.Expect(err).To(gomega.MatchError(gomega.ContainSubstring(`unknown type "email"`)))
+}
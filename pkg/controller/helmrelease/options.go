@@ -0,0 +1,156 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helmrelease
+
+import (
+	"time"
+
+	"k8s.io/apimachinery/pkg/labels"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+	"github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/release"
+)
+
+// DefaultMaxConcurrentReconciles is the number of HelmReleases reconciled in
+// parallel by default
+const DefaultMaxConcurrentReconciles = 10
+
+// DefaultCosignPath is the cosign binary verifying the image signatures by default, looked up
+// in the PATH
+const DefaultCosignPath = "cosign"
+
+// ControllerOptions holds the operator level settings of the helmrelease controller
+type ControllerOptions struct {
+	// Storage configures the helm storage backend of the releases
+	Storage release.StorageOptions
+	// RecordGC is the garbage collection mode of the release records of deleted HelmReleases
+	RecordGC string
+	// MaxConcurrentReconciles is the number of HelmReleases reconciled in parallel. A HelmRelease
+	// is never reconciled by two workers at once, and the release lock keeps two HelmReleases
+	// sharing a release from running helm actions on it at once.
+	MaxConcurrentReconciles int
+	// ShardSelector selects the HelmReleases reconciled by this operator instance, by their
+	// labels. All of them are reconciled if it is nil.
+	ShardSelector labels.Selector
+	// WatchNamespaces restricts the operator to the HelmReleases of these namespaces. All the
+	// namespaces are watched if it is empty.
+	WatchNamespaces []string
+	// FinalizerTimeout is how long a deleted HelmRelease keeps its finalizer while its release
+	// fails to uninstall. The finalizer is then removed without uninstalling the release. It is
+	// never removed if the timeout is 0.
+	FinalizerTimeout time.Duration
+	// WatchReleaseResources watches the kinds of the resources deployed by the releases so that
+	// a HelmRelease is reconciled as soon as one of its resources is modified or deleted. The
+	// resources of every watched kind are cached.
+	WatchReleaseResources bool
+	// ResyncJitter spreads the reconciles of the healthy HelmReleases listed when the operator
+	// starts over this duration. The failing ones are reconciled first. They are all reconciled
+	// at once if it is 0.
+	ResyncJitter time.Duration
+	// ChartBundleNamespace is the namespace of the ConfigMaps bundling pre-staged chart archives. A
+	// matching bundle is used instead of downloading the chart from its repo. The bundles are not
+	// looked up if it is empty.
+	ChartBundleNamespace string
+	// NamespaceScoped runs the operator with namespaced permissions only. The HelmReleases may only
+	// deploy namespaced resources to their own namespace or to the AllowedTargetNamespaces of their
+	// namespace.
+	NamespaceScoped bool
+	// AllowedTargetNamespaces lists, by HelmRelease namespace, the other namespaces its HelmReleases
+	// may deploy to in namespace-scoped mode.
+	AllowedTargetNamespaces map[string][]string
+	// PolicyURL is the OPA data API URL of the decision listing the policy violations of a rendered
+	// resource. The releases violating the policies are not installed or upgraded. The policies
+	// are not evaluated if it is empty.
+	PolicyURL string
+	// PolicyRules are the CEL rules evaluated for each rendered resource. The releases violating
+	// the rules of error severity are not installed or upgraded, the violations of the rules of
+	// warning severity are recorded as warning events.
+	PolicyRules []PolicyRule
+	// AuditLogPath is the file the install, upgrade, rollback and uninstall of the releases are
+	// appended to, one JSON object per line. The actions are only recorded in the status history
+	// of the HelmReleases if it is empty.
+	AuditLogPath string
+	// ImageSignatureKey is the cosign public key, a file or a KMS URI, verifying the signatures of
+	// the images of the rendered workloads. The releases with unverified images are not installed
+	// or upgraded. The signatures are not verified if it is empty.
+	ImageSignatureKey string
+	// CosignPath is the cosign binary running the verifications
+	CosignPath string
+	// SignedImages are the prefixes of the images that must be signed. All the images must be
+	// signed if it is empty.
+	SignedImages []string
+	// PodSecurityPreflight checks the rendered workloads against the Pod Security Admission levels
+	// of their namespaces before each install and upgrade, so that the releases rejected by the
+	// enforced level fail before being applied.
+	PodSecurityPreflight bool
+	// AllowClusterScopedResources is the default of repo.allowClusterScopedResources, allowing the
+	// charts to deploy cluster-scoped resources
+	AllowClusterScopedResources bool
+	// PermissionsPreflight reviews the access of the identity deploying a release to its rendered
+	// resources before each install and upgrade, so that the missing permissions are reported
+	// before anything is applied.
+	PermissionsPreflight bool
+	// NotificationSinks are the chat channels and webhooks the outcomes of the helm actions of the
+	// HelmReleases are posted to
+	NotificationSinks []NotificationSink
+	// ManifestSinks are the ConfigMaps, S3 buckets and git repositories the manifests of the
+	// installed and upgraded releases are exported to
+	ManifestSinks []ManifestSink
+	// KubeClient are the rate limits and the request timeout of the clients of the controller to
+	// the remote clusters. Those of the operator's cluster are set on the config of the manager.
+	KubeClient release.ClientOptions
+	// HelmClient are the rate limits and the request timeout of the helm clients applying the
+	// releases
+	HelmClient release.ClientOptions
+	// PatchStrategy updates the resources of the kinds without a patch strategy of their own in
+	// repo.patchStrategies, unless the HelmRelease is applied with server-side apply. The resources
+	// are updated as helm does if empty.
+	PatchStrategy appv1.PatchStrategyEnum
+	// StatusUpdateInterval is the minimum interval between two status writes of a HelmRelease.
+	// A status changed sooner is written once the interval elapsed, with the later changes.
+	StatusUpdateInterval time.Duration
+}
+
+// Options is set from the command line flags before the controller is added to the manager
+var Options = ControllerOptions{
+	Storage: release.StorageOptions{
+		Driver: release.SecretsStorageDriver,
+	},
+	RecordGC:                    RecordGCDryRun,
+	MaxConcurrentReconciles:     DefaultMaxConcurrentReconciles,
+	CosignPath:                  DefaultCosignPath,
+	PodSecurityPreflight:        true,
+	AllowClusterScopedResources: true,
+	PermissionsPreflight:        true,
+	StatusUpdateInterval:        DefaultStatusUpdateInterval,
+}
+
+// watchesNamespace returns true if the HelmReleases of namespace are watched,
+// and so in the cache of the manager.
+func watchesNamespace(namespace string) bool {
+	if len(Options.WatchNamespaces) == 0 {
+		return true
+	}
+
+	for _, ns := range Options.WatchNamespaces {
+		if ns == namespace {
+			return true
+		}
+	}
+
+	return false
+}
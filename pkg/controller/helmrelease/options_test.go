@@ -0,0 +1,36 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helmrelease
+
+import (
+	"testing"
+
+	"github.com/onsi/gomega"
+)
+
+func TestWatchesNamespace(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	defer func(namespaces []string) { Options.WatchNamespaces = namespaces }(Options.WatchNamespaces)
+
+	Options.WatchNamespaces = nil
+	g.Expect(watchesNamespace("default")).To(gomega.BeTrue())
+
+	Options.WatchNamespaces = []string{"team-a", "team-b"}
+	g.Expect(watchesNamespace("team-b")).To(gomega.BeTrue())
+	g.Expect(watchesNamespace("default")).To(gomega.BeFalse())
+}
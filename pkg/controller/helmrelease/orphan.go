@@ -0,0 +1,159 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helmrelease
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/discovery"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+	helmclient "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/client"
+	"github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/release"
+)
+
+const (
+	// labels and annotations set by helm on the resources of a release
+	helmManagedByLabel             = "app.kubernetes.io/managed-by"
+	helmManagedByValue             = "Helm"
+	helmReleaseNameAnnotation      = "meta.helm.sh/release-name"
+	helmReleaseNamespaceAnnotation = "meta.helm.sh/release-namespace"
+
+	// orphanCheckInterval is how often a deployed release is checked for
+	// orphaned resources, the check lists every namespaced kind of the cluster
+	orphanCheckInterval = 10 * time.Minute
+)
+
+var (
+	orphanChecksMu sync.Mutex
+	// orphanChecks are the last successful orphan checks of the HelmReleases
+	orphanChecks = map[types.NamespacedName]orphanCheck{}
+)
+
+// orphanCheck is the last successful orphan check of a HelmRelease.
+type orphanCheck struct {
+	uid      types.UID
+	revision int
+	at       time.Time
+}
+
+// orphanCheckDue returns true if the deployed revision of hr was not checked
+// for orphaned resources within orphanCheckInterval. A new revision or a
+// recreated HelmRelease is checked right away.
+func orphanCheckDue(hr *appv1.HelmRelease, revision int, now time.Time) bool {
+	orphanChecksMu.Lock()
+	defer orphanChecksMu.Unlock()
+
+	last, ok := orphanChecks[types.NamespacedName{Namespace: hr.GetNamespace(), Name: hr.GetName()}]
+
+	return !ok || last.uid != hr.GetUID() || last.revision != revision ||
+		now.Sub(last.at) >= orphanCheckInterval
+}
+
+// recordOrphanCheck records that the deployed revision of hr was checked for
+// orphaned resources at now.
+func recordOrphanCheck(hr *appv1.HelmRelease, revision int, now time.Time) {
+	orphanChecksMu.Lock()
+	defer orphanChecksMu.Unlock()
+
+	orphanChecks[types.NamespacedName{Namespace: hr.GetNamespace(), Name: hr.GetName()}] = orphanCheck{
+		uid:      hr.GetUID(),
+		revision: revision,
+		at:       now,
+	}
+}
+
+// forgetOrphanCheck deletes the last orphan check of the HelmRelease name,
+// e.g. once it is deleted.
+func forgetOrphanCheck(name types.NamespacedName) {
+	orphanChecksMu.Lock()
+	defer orphanChecksMu.Unlock()
+
+	delete(orphanChecks, name)
+}
+
+// orphanedResources returns the resources of the target namespace that helm
+// labeled as part of the release but that are no longer in its deployed
+// manifest. Nothing is deleted. Cluster scoped resources are not checked.
+func (r *ReconcileHelmRelease) orphanedResources(hr *appv1.HelmRelease, releaseName,
+	manifest string) ([]appv1.HelmAppResource, error) {
+	cfg, err := r.clusterConfig(hr)
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := r.clusterClient(hr)
+	if err != nil {
+		return nil, err
+	}
+
+	dc, err := helmclient.SharedDiscoveryClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	// partial results are still worth checking when an aggregated API is down
+	resourceLists, err := dc.ServerPreferredNamespacedResources()
+	if err != nil && !discovery.IsGroupDiscoveryFailedError(err) {
+		return nil, err
+	}
+
+	namespace := targetNamespace(hr)
+
+	var live []unstructured.Unstructured
+
+	for _, resourceList := range resourceLists {
+		gv, err := schema.ParseGroupVersion(resourceList.GroupVersion)
+		if err != nil {
+			continue
+		}
+
+		for _, resource := range resourceList.APIResources {
+			if strings.Contains(resource.Name, "/") || !sets.NewString(resource.Verbs...).Has("list") {
+				continue
+			}
+
+			// read from the API server directly, the cache would start an informer per kind
+			list := &unstructured.UnstructuredList{}
+			list.SetGroupVersionKind(gv.WithKind(resource.Kind + "List"))
+
+			if err := c.List(context.TODO(), list, client.InNamespace(namespace),
+				client.MatchingLabels{helmManagedByLabel: helmManagedByValue}); err != nil {
+				log.V(3).Info("Failed to list resources for orphan check", "kind", gv.WithKind(resource.Kind).String(), "error", err.Error())
+				continue
+			}
+
+			for _, item := range list.Items {
+				annotations := item.GetAnnotations()
+				if annotations[helmReleaseNameAnnotation] == releaseName &&
+					annotations[helmReleaseNamespaceAnnotation] == namespace {
+					live = append(live, item)
+				}
+			}
+		}
+	}
+
+	return release.OrphanedResources(manifest, namespace, live)
+}
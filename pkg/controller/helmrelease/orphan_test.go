@@ -0,0 +1,95 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helmrelease
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+func TestOrphanedResources(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	mgr, err := manager.New(cfg, manager.Options{MetricsBindAddress: "0"})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	r := &ReconcileHelmRelease{mgr}
+	hr := &appv1.HelmRelease{ObjectMeta: metav1.ObjectMeta{Name: "orphan-check", Namespace: helmReleaseNS}}
+
+	// the resources labeled by helm as part of the release, one of them no
+	// longer in its manifest, e.g. left by a failed upgrade
+	for _, name := range []string{"orphan-check-kept", "orphan-check-left"} {
+		cm := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: helmReleaseNS,
+				Labels:    map[string]string{helmManagedByLabel: helmManagedByValue},
+				Annotations: map[string]string{
+					helmReleaseNameAnnotation:      hr.GetName(),
+					helmReleaseNamespaceAnnotation: helmReleaseNS,
+				},
+			},
+		}
+		g.Expect(mgr.GetClient().Create(context.TODO(), cm)).To(gomega.Succeed())
+
+		defer mgr.GetClient().Delete(context.TODO(), cm)
+	}
+
+	orphaned, err := r.orphanedResources(hr, hr.GetName(), configMapManifest("orphan-check-kept"))
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(orphaned).To(gomega.Equal([]appv1.HelmAppResource{
+		{APIVersion: "v1", Kind: "ConfigMap", Namespace: helmReleaseNS, Name: "orphan-check-left"},
+	}))
+}
+
+func TestOrphanCheckDue(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	hr := &appv1.HelmRelease{ObjectMeta: metav1.ObjectMeta{Namespace: "orphan-ns", Name: "orphan-check", UID: "uid-1"}}
+	name := types.NamespacedName{Namespace: hr.GetNamespace(), Name: hr.GetName()}
+
+	defer forgetOrphanCheck(name)
+
+	now := time.Now()
+
+	g.Expect(orphanCheckDue(hr, 1, now)).To(gomega.BeTrue())
+
+	recordOrphanCheck(hr, 1, now)
+
+	g.Expect(orphanCheckDue(hr, 1, now.Add(time.Minute))).To(gomega.BeFalse())
+	g.Expect(orphanCheckDue(hr, 1, now.Add(orphanCheckInterval))).To(gomega.BeTrue())
+
+	// an upgrade is checked right away
+	g.Expect(orphanCheckDue(hr, 2, now.Add(time.Minute))).To(gomega.BeTrue())
+
+	// so is a recreated HelmRelease
+	recreated := hr.DeepCopy()
+	recreated.SetUID("uid-2")
+	g.Expect(orphanCheckDue(recreated, 1, now.Add(time.Minute))).To(gomega.BeTrue())
+
+	forgetOrphanCheck(name)
+	g.Expect(orphanCheckDue(hr, 1, now.Add(time.Minute))).To(gomega.BeTrue())
+}
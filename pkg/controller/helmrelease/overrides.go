@@ -0,0 +1,160 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helmrelease
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	workv1 "github.com/open-cluster-management/api/work/v1"
+	"helm.sh/helm/v3/pkg/chartutil"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+	"github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/release"
+)
+
+// clusterRender is the release rendered with the values of the overrides
+// matching a cluster, shared by the clusters matching the same overrides
+type clusterRender struct {
+	digest    string
+	manifests []workv1.Manifest
+}
+
+// matchingOverrides returns, for each of the clusters, the indexes of the
+// clusterOverrides of hr matching its labels, in order.
+func (r *ReconcileHelmRelease) matchingOverrides(hr *appv1.HelmRelease, clusters []string) (map[string][]int, error) {
+	if len(hr.Repo.ClusterOverrides) == 0 {
+		return nil, nil
+	}
+
+	selectors := make([]labels.Selector, 0, len(hr.Repo.ClusterOverrides))
+
+	for i := range hr.Repo.ClusterOverrides {
+		selector, err := metav1.LabelSelectorAsSelector(&hr.Repo.ClusterOverrides[i].ClusterSelector)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cluster selector of cluster override %d: %w", i, err)
+		}
+
+		selectors = append(selectors, selector)
+	}
+
+	clusterLabels, err := r.managedClusterLabels()
+	if err != nil {
+		return nil, err
+	}
+
+	matching := make(map[string][]int, len(clusters))
+
+	for _, cluster := range clusters {
+		for i, selector := range selectors {
+			if selector.Matches(clusterLabels[cluster]) {
+				matching[cluster] = append(matching[cluster], i)
+			}
+		}
+	}
+
+	return matching, nil
+}
+
+// managedClusterLabels returns the labels of the ManagedClusters by name.
+func (r *ReconcileHelmRelease) managedClusterLabels() (map[string]labels.Set, error) {
+	list := &clusterv1.ManagedClusterList{}
+	if err := r.GetAPIReader().List(context.TODO(), list); err != nil {
+		return nil, fmt.Errorf("failed to list ManagedClusters: %w", err)
+	}
+
+	clusterLabels := make(map[string]labels.Set, len(list.Items))
+	for _, c := range list.Items {
+		clusterLabels[c.GetName()] = c.GetLabels()
+	}
+
+	return clusterLabels, nil
+}
+
+// renderClusterWork renders the release of hr with the values of the given
+// clusterOverrides, and the release name and target namespace of the
+// cluster, into the manifests of a ManifestWork. The digest of the release
+// covers the override values and the names.
+func renderClusterWork(hr *appv1.HelmRelease, manager release.Manager, overrides []int,
+	names clusterNames) (clusterRender, error) {
+	values, err := overrideValues(hr, overrides)
+	if err != nil {
+		return clusterRender{}, err
+	}
+
+	digest := manager.ReleaseDigest()
+
+	if len(values) != 0 || names.releaseName != hr.GetName() || names.namespace != targetNamespace(hr) {
+		// json sorts the map keys, the encoding is stable
+		raw, err := json.Marshal([]interface{}{values, names.releaseName, names.namespace})
+		if err != nil {
+			return clusterRender{}, err
+		}
+
+		sum := sha256.Sum256(append([]byte(digest), raw...))
+		digest = hex.EncodeToString(sum[:])
+	}
+
+	objects, err := manager.Render(context.TODO(), release.RenderOptions{
+		Overrides:   values,
+		ReleaseName: names.releaseName,
+		Namespace:   names.namespace,
+	})
+	if err != nil {
+		return clusterRender{}, err
+	}
+
+	manifests, err := workManifests(hr, names.namespace, objects)
+	if err != nil {
+		return clusterRender{}, err
+	}
+
+	return clusterRender{digest: digest, manifests: manifests}, nil
+}
+
+// overrideValues merges the values of the given clusterOverrides of hr, in
+// order, the last one wins.
+func overrideValues(hr *appv1.HelmRelease, overrides []int) (map[string]interface{}, error) {
+	var merged map[string]interface{}
+
+	for _, i := range overrides {
+		raw := hr.Repo.ClusterOverrides[i].Values.Raw
+		if len(raw) == 0 {
+			continue
+		}
+
+		var values map[string]interface{}
+		if err := json.Unmarshal(raw, &values); err != nil {
+			return nil, fmt.Errorf("failed to parse the values of cluster override %d: %w", i, err)
+		}
+
+		if merged == nil {
+			merged = values
+			continue
+		}
+
+		merged = chartutil.CoalesceTables(values, merged)
+	}
+
+	return merged, nil
+}
@@ -0,0 +1,95 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helmrelease
+
+import (
+	"testing"
+
+	"github.com/onsi/gomega"
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+func newClusterOverride(labels map[string]string, values string) appv1.ClusterValuesOverride {
+	return appv1.ClusterValuesOverride{
+		ClusterSelector: metav1.LabelSelector{MatchLabels: labels},
+		Values:          runtime.RawExtension{Raw: []byte(values)},
+	}
+}
+
+func TestMatchingOverrides(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	s := runtime.NewScheme()
+	g.Expect(clusterv1.Install(s)).To(gomega.Succeed())
+
+	newCluster := func(name, region string) runtime.Object {
+		return &clusterv1.ManagedCluster{ObjectMeta: metav1.ObjectMeta{
+			Name:   name,
+			Labels: map[string]string{"region": region, "env": "prod"},
+		}}
+	}
+
+	r := &ReconcileHelmRelease{readerManager{reader: fake.NewFakeClientWithScheme(s,
+		newCluster("cluster1", "eu"), newCluster("cluster2", "us"))}}
+
+	hr := &appv1.HelmRelease{}
+
+	matching, err := r.matchingOverrides(hr, []string{"cluster1", "cluster2"})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(matching).To(gomega.BeEmpty())
+
+	hr.Repo.ClusterOverrides = []appv1.ClusterValuesOverride{
+		newClusterOverride(map[string]string{"env": "prod"}, `{"replicas":3}`),
+		newClusterOverride(map[string]string{"region": "eu"}, `{"region":"eu"}`),
+	}
+
+	matching, err = r.matchingOverrides(hr, []string{"cluster1", "cluster2"})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(matching).To(gomega.Equal(map[string][]int{"cluster1": {0, 1}, "cluster2": {0}}))
+}
+
+func TestOverrideValues(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	hr := &appv1.HelmRelease{}
+	hr.Repo.ClusterOverrides = []appv1.ClusterValuesOverride{
+		newClusterOverride(nil, `{"image":{"tag":"1.0","pullPolicy":"Always"},"replicas":3}`),
+		newClusterOverride(nil, ""),
+		newClusterOverride(nil, `{"image":{"tag":"1.1"}}`),
+	}
+
+	values, err := overrideValues(hr, nil)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(values).To(gomega.BeNil())
+
+	// the last one wins
+	values, err = overrideValues(hr, []int{0, 1, 2})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(values).To(gomega.Equal(map[string]interface{}{
+		"image":    map[string]interface{}{"tag": "1.1", "pullPolicy": "Always"},
+		"replicas": float64(3),
+	}))
+
+	hr.Repo.ClusterOverrides[1].Values.Raw = []byte("[")
+	_, err = overrideValues(hr, []int{1})
+	g.Expect(err).To(gomega.HaveOccurred())
+}
@@ -0,0 +1,52 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helmrelease
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+	"github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/release"
+)
+
+// checkPermissions returns the permissions missing to deploy the release of
+// hr, reviewed as the identity its requests are sent as: the operator, the
+// kubeconfig of a remote cluster or the impersonated ServiceAccount.
+func checkPermissions(hr *appv1.HelmRelease, manager release.Manager) ([]string, error) {
+	if !Options.PermissionsPreflight {
+		return nil, nil
+	}
+
+	return manager.MissingPermissions(context.TODO())
+}
+
+// permissionsMessage is the condition message of the permissions missing to
+// deploy the release of hr.
+func permissionsMessage(hr *appv1.HelmRelease, missing []string) string {
+	identity := "the operator"
+
+	switch {
+	case hr.Repo.ServiceAccountName != "":
+		identity = fmt.Sprintf("ServiceAccount %s/%s", hr.GetNamespace(), hr.Repo.ServiceAccountName)
+	case hr.Repo.KubeConfig != nil:
+		identity = "the kubeconfig of the remote cluster"
+	}
+
+	return fmt.Sprintf("%s is missing permissions to deploy the chart: %s", identity, strings.Join(missing, ", "))
+}
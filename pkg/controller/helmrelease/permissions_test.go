@@ -0,0 +1,66 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helmrelease
+
+import (
+	"context"
+	"testing"
+
+	"github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+	"github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/release"
+)
+
+// permissionsManager is a release manager missing permissions to deploy its
+// release.
+type permissionsManager struct {
+	release.Manager
+
+	missing []string
+}
+
+func (m permissionsManager) MissingPermissions(ctx context.Context) ([]string, error) {
+	return m.missing, nil
+}
+
+func TestCheckPermissions(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	defer func(preflight bool) { Options.PermissionsPreflight = preflight }(Options.PermissionsPreflight)
+
+	hr := &appv1.HelmRelease{ObjectMeta: metav1.ObjectMeta{Name: "webapp", Namespace: "team-a"}}
+	m := permissionsManager{missing: []string{"create apps/deployments in namespace team-a"}}
+
+	Options.PermissionsPreflight = false
+	g.Expect(checkPermissions(hr, m)).To(gomega.BeEmpty())
+
+	Options.PermissionsPreflight = true
+	missing, err := checkPermissions(hr, m)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(missing).To(gomega.Equal(m.missing))
+
+	g.Expect(permissionsMessage(hr, missing)).To(gomega.Equal(
+		"the operator is missing permissions to deploy the chart: create apps/deployments in namespace team-a"))
+
+	hr.Repo.KubeConfig = &appv1.KubeConfig{}
+	g.Expect(permissionsMessage(hr, missing)).To(gomega.HavePrefix("the kubeconfig of the remote cluster is missing"))
+
+	hr.Repo.ServiceAccountName = "deployer"
+	g.Expect(permissionsMessage(hr, missing)).To(gomega.HavePrefix("ServiceAccount team-a/deployer is missing"))
+}
@@ -0,0 +1,134 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helmrelease
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+
+	plrv1 "github.com/open-cluster-management/multicloud-operators-placementrule/pkg/apis/apps/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+// placementRuleKind is the kind of the PlacementRules deciding the clusters
+var placementRuleKind = schema.GroupKind{Group: plrv1.SchemeGroupVersion.Group, Kind: "PlacementRule"}
+
+// watchPlacementRules reconciles the HelmReleases referencing a PlacementRule
+// as soon as its decisions change. The PlacementRules are only watched if
+// their CRD is installed, e.g. on an Open Cluster Management hub.
+func watchPlacementRules(mgr manager.Manager, c controller.Controller) error {
+	_, err := mgr.GetRESTMapper().RESTMapping(placementRuleKind, plrv1.SchemeGroupVersion.Version)
+	if meta.IsNoMatchError(err) {
+		log.Info("PlacementRules are not installed, the HelmReleases follow their decisions at each interval")
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	log.Info("Watching the decisions of the PlacementRules")
+
+	return c.Watch(&source.Kind{Type: &plrv1.PlacementRule{}}, &handler.EnqueueRequestsFromMapFunc{
+		ToRequests: placementMapper{mgr.GetClient()},
+	}, decisionsChangedPredicate{})
+}
+
+// placementMapper maps a PlacementRule to the HelmReleases of its namespace
+// referencing it.
+type placementMapper struct {
+	client client.Client
+}
+
+func (m placementMapper) Map(obj handler.MapObject) []reconcile.Request {
+	// the HelmReleases of the namespaces not watched are not cached
+	if !watchesNamespace(obj.Meta.GetNamespace()) {
+		return nil
+	}
+
+	hrs := &appv1.HelmReleaseList{}
+	if err := m.client.List(context.TODO(), hrs, client.InNamespace(obj.Meta.GetNamespace())); err != nil {
+		log.Error(err, "Failed to list the HelmReleases of PlacementRule", "placementrule", obj.Meta.GetNamespace()+"/"+obj.Meta.GetName())
+		return nil
+	}
+
+	var requests []reconcile.Request
+
+	for _, hr := range hrs.Items {
+		if hr.Repo.PlacementRef != nil && hr.Repo.PlacementRef.Name == obj.Meta.GetName() {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{Namespace: hr.GetNamespace(), Name: hr.GetName()},
+			})
+		}
+	}
+
+	return requests
+}
+
+// decisionsChangedPredicate only passes the PlacementRules whose decisions
+// changed, the deleted ones fail the reconcile of their HelmReleases.
+type decisionsChangedPredicate struct {
+	predicate.Funcs
+}
+
+func (decisionsChangedPredicate) Update(e event.UpdateEvent) bool {
+	oldRule, ok := e.ObjectOld.(*plrv1.PlacementRule)
+	if !ok {
+		return false
+	}
+
+	newRule, ok := e.ObjectNew.(*plrv1.PlacementRule)
+	if !ok {
+		return false
+	}
+
+	return !reflect.DeepEqual(oldRule.Status.Decisions, newRule.Status.Decisions)
+}
+
+// placementDecisions returns the sorted names of the ManagedClusters decided
+// by the PlacementRule referenced by hr, in its namespace.
+func (r *ReconcileHelmRelease) placementDecisions(hr *appv1.HelmRelease) ([]string, error) {
+	rule := &plrv1.PlacementRule{}
+	key := types.NamespacedName{Namespace: hr.GetNamespace(), Name: hr.Repo.PlacementRef.Name}
+
+	if err := r.GetAPIReader().Get(context.TODO(), key, rule); err != nil {
+		return nil, fmt.Errorf("failed to get PlacementRule %s: %w", key.Name, err)
+	}
+
+	names := make([]string, 0, len(rule.Status.Decisions))
+	for _, d := range rule.Status.Decisions {
+		names = append(names, d.ClusterName)
+	}
+
+	sort.Strings(names)
+
+	return names, nil
+}
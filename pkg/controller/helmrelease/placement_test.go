@@ -0,0 +1,94 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helmrelease
+
+import (
+	"testing"
+
+	"github.com/onsi/gomega"
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	plrv1 "github.com/open-cluster-management/multicloud-operators-placementrule/pkg/apis/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+// readerManager serves the API reads of the reconciles from a fake client.
+type readerManager struct {
+	manager.Manager
+	reader client.Reader
+}
+
+func (m readerManager) GetAPIReader() client.Reader {
+	return m.reader
+}
+
+func TestSelectedClusters(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	s := runtime.NewScheme()
+	g.Expect(clusterv1.Install(s)).To(gomega.Succeed())
+	g.Expect(plrv1.SchemeBuilder.AddToScheme(s)).To(gomega.Succeed())
+
+	newCluster := func(name, env string) runtime.Object {
+		return &clusterv1.ManagedCluster{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{"env": env}}}
+	}
+
+	rule := &plrv1.PlacementRule{ObjectMeta: metav1.ObjectMeta{Name: "prod", Namespace: "default"}}
+	rule.Status.Decisions = []plrv1.PlacementDecision{{ClusterName: "cluster3"}, {ClusterName: "cluster1"}}
+
+	r := &ReconcileHelmRelease{readerManager{reader: fake.NewFakeClientWithScheme(s,
+		newCluster("cluster1", "prod"), newCluster("cluster2", "prod"), newCluster("cluster3", "dev"), rule)}}
+
+	hr := &appv1.HelmRelease{ObjectMeta: metav1.ObjectMeta{Name: "webapp", Namespace: "default"}}
+	hr.Repo.ClusterSelector = &metav1.LabelSelector{MatchLabels: map[string]string{"env": "prod"}}
+	g.Expect(r.selectedClusters(hr)).To(gomega.Equal([]string{"cluster1", "cluster2"}))
+
+	// the decided clusters matching the selector
+	hr.Repo.PlacementRef = &corev1.LocalObjectReference{Name: "prod"}
+	g.Expect(r.selectedClusters(hr)).To(gomega.Equal([]string{"cluster1"}))
+
+	hr.Repo.ClusterSelector = nil
+	g.Expect(r.selectedClusters(hr)).To(gomega.Equal([]string{"cluster1", "cluster3"}))
+
+	hr.Repo.PlacementRef.Name = "missing"
+	_, err := r.selectedClusters(hr)
+	g.Expect(err).To(gomega.HaveOccurred())
+}
+
+func TestDecisionsChangedPredicate(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	old := &plrv1.PlacementRule{ObjectMeta: metav1.ObjectMeta{Name: "prod", Namespace: "default"}}
+	old.Status.Decisions = []plrv1.PlacementDecision{{ClusterName: "cluster1"}}
+
+	relabeled := old.DeepCopy()
+	relabeled.SetLabels(map[string]string{"team": "a"})
+	g.Expect(decisionsChangedPredicate{}.Update(event.UpdateEvent{ObjectOld: old, ObjectNew: relabeled})).
+		To(gomega.BeFalse())
+
+	decided := old.DeepCopy()
+	decided.Status.Decisions = append(decided.Status.Decisions, plrv1.PlacementDecision{ClusterName: "cluster2"})
+	g.Expect(decisionsChangedPredicate{}.Update(event.UpdateEvent{ObjectOld: old, ObjectNew: decided})).
+		To(gomega.BeTrue())
+}
@@ -0,0 +1,133 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helmrelease
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+	"github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/release"
+)
+
+// labels of the Pod Security Admission levels of a namespace
+const (
+	podSecurityEnforceLabel = "pod-security.kubernetes.io/enforce"
+	podSecurityWarnLabel    = "pod-security.kubernetes.io/warn"
+)
+
+// checkPodSecurity renders the release of hr and returns the violations of
+// the Pod Security Admission levels enforced on the namespaces of its
+// workloads, which would be rejected once applied, and the violations of
+// their warn levels. The hooks are not rendered and so not checked.
+func (r *ReconcileHelmRelease) checkPodSecurity(hr *appv1.HelmRelease,
+	manager release.Manager) (enforced []string, warned []string, err error) {
+	if !Options.PodSecurityPreflight {
+		return nil, nil, nil
+	}
+
+	objects, err := manager.Render(context.TODO(), release.RenderOptions{})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	levels := map[string]map[string]string{}
+
+	for _, u := range objects {
+		if !release.IsWorkload(u.GetKind()) {
+			continue
+		}
+
+		labels, ok := levels[u.GetNamespace()]
+		if !ok {
+			if labels, err = r.podSecurityLabels(hr, u.GetNamespace()); err != nil {
+				return nil, nil, err
+			}
+
+			levels[u.GetNamespace()] = labels
+		}
+
+		subject := fmt.Sprintf("%s %s/%s", u.GetKind(), u.GetNamespace(), u.GetName())
+
+		enforce := labels[podSecurityEnforceLabel]
+
+		violations, err := release.PodSecurityViolations(u, enforce)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		for _, v := range violations {
+			enforced = append(enforced, fmt.Sprintf("%s violates %s: %s", subject, enforce, v))
+		}
+
+		if warn := labels[podSecurityWarnLabel]; warn != enforce {
+			if violations, err = release.PodSecurityViolations(u, warn); err != nil {
+				return nil, nil, err
+			}
+
+			for _, v := range violations {
+				warned = append(warned, fmt.Sprintf("%s violates %s: %s", subject, warn, v))
+			}
+		}
+	}
+
+	return enforced, warned, nil
+}
+
+// podSecurityLabels returns the labels of namespace the workloads of hr are
+// deployed to, with the labels set on the target namespace before the
+// install. The namespaces that cannot be read, e.g. in namespace-scoped mode,
+// are not checked.
+func (r *ReconcileHelmRelease) podSecurityLabels(hr *appv1.HelmRelease, namespace string) (map[string]string, error) {
+	c, err := r.clusterClient(hr)
+	if err != nil {
+		return nil, err
+	}
+
+	ns := &corev1.Namespace{}
+
+	err = c.Get(context.TODO(), types.NamespacedName{Name: namespace}, ns)
+
+	switch {
+	case apierrors.IsForbidden(err):
+		logFor(hr).Info("Skipping the pod security preflight of a namespace that cannot be read", "namespace", namespace)
+
+		return nil, nil
+	case apierrors.IsNotFound(err):
+		ns = &corev1.Namespace{}
+	case err != nil:
+		return nil, err
+	}
+
+	if namespace == targetNamespace(hr) && hr.Repo.CreateNamespace {
+		setNamespaceMetadata(ns, hr.Repo.NamespaceMetadata)
+	}
+
+	return ns.GetLabels(), nil
+}
+
+// podSecurityMessage is the message of the workloads violating the pod
+// security levels of their namespaces.
+func podSecurityMessage(violations []string) string {
+	return "the chart deploys workloads violating the pod security levels of their namespaces: " +
+		strings.Join(violations, ", ")
+}
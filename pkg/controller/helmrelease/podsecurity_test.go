@@ -0,0 +1,90 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helmrelease
+
+import (
+	"testing"
+
+	"github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+// newPrivilegedPod returns a privileged Pod of namespace.
+func newPrivilegedPod(namespace string) *unstructured.Unstructured {
+	u := newPodObject("webapp:1.0")
+	u.SetNamespace(namespace)
+
+	containers, _, _ := unstructured.NestedSlice(u.Object, "spec", "containers")
+	containers[0].(map[string]interface{})["securityContext"] = map[string]interface{}{"privileged": true}
+	_ = unstructured.SetNestedSlice(u.Object, containers, "spec", "containers")
+
+	return u
+}
+
+func TestCheckPodSecurity(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	newNamespace := func(name string, labels map[string]string) *corev1.Namespace {
+		return &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels}}
+	}
+
+	r := &ReconcileHelmRelease{clientManager{client: fake.NewFakeClientWithScheme(scheme.Scheme,
+		newNamespace("enforced", map[string]string{podSecurityEnforceLabel: "baseline"}),
+		newNamespace("warned", map[string]string{podSecurityWarnLabel: "restricted"}),
+		newNamespace("unlabeled", nil),
+	)}}
+
+	hr := &appv1.HelmRelease{ObjectMeta: metav1.ObjectMeta{Name: "webapp", Namespace: "default"}}
+	m := &renderManager{objects: []*unstructured.Unstructured{
+		newPrivilegedPod("enforced"), newPrivilegedPod("warned"), newPrivilegedPod("unlabeled"),
+	}}
+
+	enforced, warned, err := r.checkPodSecurity(hr, m)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(enforced).To(gomega.Equal([]string{"Pod enforced/webapp violates baseline: container app: privileged=true"}))
+	g.Expect(warned).NotTo(gomega.BeEmpty())
+
+	for _, w := range warned {
+		g.Expect(w).To(gomega.HavePrefix("Pod warned/webapp violates restricted: "))
+	}
+
+	g.Expect(podSecurityMessage(enforced)).To(gomega.HaveSuffix(": " + enforced[0]))
+
+	// the labels set on the created target namespace apply before the install
+	hr.Repo.TargetNamespace = "created"
+	hr.Repo.CreateNamespace = true
+	hr.Repo.NamespaceMetadata = &appv1.NamespaceMetadata{Labels: map[string]string{podSecurityEnforceLabel: "baseline"}}
+	m.objects = []*unstructured.Unstructured{newPrivilegedPod("created")}
+
+	enforced, _, err = r.checkPodSecurity(hr, m)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(enforced).To(gomega.HaveLen(1))
+
+	defer func(preflight bool) { Options.PodSecurityPreflight = preflight }(Options.PodSecurityPreflight)
+	Options.PodSecurityPreflight = false
+
+	enforced, warned, err = r.checkPodSecurity(hr, m)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(enforced).To(gomega.BeEmpty())
+	g.Expect(warned).To(gomega.BeEmpty())
+}
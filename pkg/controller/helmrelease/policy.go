@@ -0,0 +1,240 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helmrelease
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+	"github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/release"
+)
+
+// opaPolicy is the policy of the violations reported by OPA
+const opaPolicy = "opa"
+
+// policyClient queries the policy server, a hung server must not block the
+// reconciles
+var policyClient = &http.Client{Timeout: 30 * time.Second}
+
+// opaInput is the input of the policy query of a rendered resource. The
+// resource is reviewed like in an admission request so that the policies
+// written for Gatekeeper, against input.review.object, apply.
+type opaInput struct {
+	Review  opaReview  `json:"review"`
+	Release opaRelease `json:"release"`
+}
+
+type opaReview struct {
+	Kind      metav1.GroupVersionKind `json:"kind"`
+	Name      string                  `json:"name"`
+	Namespace string                  `json:"namespace,omitempty"`
+	Object    map[string]interface{}  `json:"object"`
+}
+
+type opaRelease struct {
+	// HelmRelease is the namespace/name of the HelmRelease
+	HelmRelease string `json:"helmRelease"`
+	Name        string `json:"name"`
+	Namespace   string `json:"namespace"`
+}
+
+// checkPolicies renders the release of hr and returns the violations of the
+// policies by its resources, before they are installed or upgraded: the
+// violations of the OPA policies and of the policy rules of error severity,
+// then the violations of the policy rules of warning severity. The hooks are
+// not rendered and so not checked.
+func checkPolicies(hr *appv1.HelmRelease,
+	manager release.Manager) ([]appv1.HelmAppPolicyViolation, []appv1.HelmAppPolicyViolation, error) {
+	if Options.PolicyURL == "" && len(Options.PolicyRules) == 0 {
+		return nil, nil, nil
+	}
+
+	objects, err := manager.Render(context.TODO(), release.RenderOptions{})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rel := opaRelease{
+		HelmRelease: hr.GetNamespace() + "/" + hr.GetName(),
+		Name:        manager.ReleaseName(),
+		Namespace:   targetNamespace(hr),
+	}
+
+	var violations, warnings []appv1.HelmAppPolicyViolation
+
+	for _, u := range objects {
+		for _, rule := range Options.PolicyRules {
+			if !rule.appliesTo(u.GetKind()) {
+				continue
+			}
+
+			violation := rule.evaluate(u, rel)
+			if violation == nil {
+				continue
+			}
+
+			if rule.Severity == PolicySeverityWarning {
+				warnings = append(warnings, *violation)
+			} else {
+				violations = append(violations, *violation)
+			}
+		}
+
+		if Options.PolicyURL == "" {
+			continue
+		}
+
+		messages, err := queryOPA(Options.PolicyURL, opaInput{
+			Review: opaReview{
+				Kind: metav1.GroupVersionKind{
+					Group:   u.GroupVersionKind().Group,
+					Version: u.GroupVersionKind().Version,
+					Kind:    u.GetKind(),
+				},
+				Name:      u.GetName(),
+				Namespace: u.GetNamespace(),
+				Object:    u.Object,
+			},
+			Release: rel,
+		})
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to evaluate the policies of %s %s: %w", u.GetKind(), u.GetName(), err)
+		}
+
+		for _, message := range messages {
+			violations = append(violations, appv1.HelmAppPolicyViolation{
+				Resource: policyResource(u),
+				Policy:   opaPolicy,
+				Message:  message,
+			})
+		}
+	}
+
+	return violations, warnings, nil
+}
+
+// queryOPA queries the decision of the OPA data API at url for input and
+// returns its violations. The decision is a set of messages, or of objects
+// with a msg like the Gatekeeper violations, or a boolean allowing the
+// resource. An undefined decision has no violations.
+func queryOPA(url string, input opaInput) ([]string, error) {
+	body, err := json.Marshal(map[string]interface{}{"input": input})
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := policyClient.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("policy server returned %s", resp.Status)
+	}
+
+	decision := struct {
+		Result interface{} `json:"result"`
+	}{}
+
+	if err := json.NewDecoder(resp.Body).Decode(&decision); err != nil {
+		return nil, fmt.Errorf("failed to decode the policy decision: %w", err)
+	}
+
+	switch result := decision.Result.(type) {
+	case nil:
+		return nil, nil
+	case bool:
+		if result {
+			return nil, nil
+		}
+
+		return []string{"the resource is not allowed by the policies"}, nil
+	case []interface{}:
+		messages := make([]string, 0, len(result))
+
+		for _, v := range result {
+			if violation, ok := v.(map[string]interface{}); ok {
+				if msg, ok := violation["msg"].(string); ok {
+					messages = append(messages, msg)
+					continue
+				}
+			}
+
+			if msg, ok := v.(string); ok {
+				messages = append(messages, msg)
+				continue
+			}
+
+			// an unexpected violation is still a violation
+			raw, _ := json.Marshal(v)
+			messages = append(messages, string(raw))
+		}
+
+		return messages, nil
+	default:
+		return nil, fmt.Errorf("unexpected policy decision %v, expected a set of violations or a boolean", result)
+	}
+}
+
+func policyResource(u *unstructured.Unstructured) appv1.HelmAppResource {
+	return appv1.HelmAppResource{
+		APIVersion: u.GetAPIVersion(),
+		Kind:       u.GetKind(),
+		Namespace:  u.GetNamespace(),
+		Name:       u.GetName(),
+	}
+}
+
+// policyViolationsMessage is the condition message of the violations of the
+// policies, the first ones, all of them are listed in the status.
+func policyViolationsMessage(violations []appv1.HelmAppPolicyViolation) string {
+	return fmt.Sprintf("the release has %d policy violations: %s", len(violations), listPolicyViolations(violations))
+}
+
+// policyWarningsMessage is the event message of the violations of the policy
+// rules of warning severity, the first ones.
+func policyWarningsMessage(warnings []appv1.HelmAppPolicyViolation) string {
+	return fmt.Sprintf("the release has %d policy warnings: %s", len(warnings), listPolicyViolations(warnings))
+}
+
+func listPolicyViolations(violations []appv1.HelmAppPolicyViolation) string {
+	const listed = 3
+
+	messages := make([]string, 0, listed)
+
+	for i, v := range violations {
+		if i == listed {
+			messages = append(messages, fmt.Sprintf("and %d more", len(violations)-listed))
+			break
+		}
+
+		messages = append(messages, fmt.Sprintf("%s: %s", v.Resource, v.Message))
+	}
+
+	return strings.Join(messages, "; ")
+}
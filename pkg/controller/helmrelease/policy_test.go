@@ -0,0 +1,152 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helmrelease
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+// policyManager is a release manager rendering the objects of a release.
+type policyManager struct {
+	renderManager
+}
+
+func (m *policyManager) ReleaseName() string {
+	return "webapp"
+}
+
+// newOPAServer returns a policy server deciding with decide on the input of
+// each query.
+func newOPAServer(decide func(input opaInput) string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := struct {
+			Input opaInput `json:"input"`
+		}{}
+
+		if err := json.NewDecoder(r.Body).Decode(&query); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		fmt.Fprintf(w, `{"result": %s}`, decide(query.Input))
+	}))
+}
+
+func TestQueryOPA(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	for decision, violations := range map[string][]string{
+		`null`:                             nil,
+		`true`:                             nil,
+		`false`:                            {"the resource is not allowed by the policies"},
+		`[]`:                               {},
+		`["no latest tag"]`:                {"no latest tag"},
+		`[{"msg": "no latest tag"}]`:       {"no latest tag"},
+		`[{"details": {"tag": "latest"}}]`: {`{"details":{"tag":"latest"}}`},
+	} {
+		decision := decision
+		server := newOPAServer(func(opaInput) string { return decision })
+
+		messages, err := queryOPA(server.URL, opaInput{})
+		g.Expect(err).NotTo(gomega.HaveOccurred(), decision)
+		g.Expect(messages).To(gomega.Equal(violations), decision)
+
+		server.Close()
+	}
+
+	server := newOPAServer(func(opaInput) string { return `"allowed"` })
+	defer server.Close()
+
+	_, err := queryOPA(server.URL, opaInput{})
+	g.Expect(err).To(gomega.MatchError(gomega.ContainSubstring("unexpected policy decision")))
+}
+
+func TestCheckPolicies(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	defer func(url string) { Options.PolicyURL = url }(Options.PolicyURL)
+
+	var inputs []opaInput
+
+	server := newOPAServer(func(input opaInput) string {
+		inputs = append(inputs, input)
+
+		if input.Review.Kind.Kind == "Secret" {
+			return `[{"msg": "secrets are not allowed"}]`
+		}
+
+		return `[]`
+	})
+	defer server.Close()
+
+	hr := &appv1.HelmRelease{ObjectMeta: metav1.ObjectMeta{Name: "webapp", Namespace: "default"}}
+	hr.Repo.TargetNamespace = "team-a"
+
+	secret := newScopedObject("Secret", "team-a", "token")
+	secret.SetAPIVersion("v1")
+	m := &policyManager{renderManager{objects: []*unstructured.Unstructured{
+		newScopedObject("ConfigMap", "team-a", "config"), secret,
+	}}}
+
+	// the policies are not checked without a policy server
+	violations, _, err := checkPolicies(hr, m)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(violations).To(gomega.BeEmpty())
+	g.Expect(inputs).To(gomega.BeEmpty())
+
+	Options.PolicyURL = server.URL
+
+	violations, _, err = checkPolicies(hr, m)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(violations).To(gomega.Equal([]appv1.HelmAppPolicyViolation{{
+		Resource: appv1.HelmAppResource{APIVersion: "v1", Kind: "Secret", Namespace: "team-a", Name: "token"},
+		Policy:   opaPolicy,
+		Message:  "secrets are not allowed",
+	}}))
+
+	g.Expect(inputs).To(gomega.HaveLen(2))
+	g.Expect(inputs[1].Review.Name).To(gomega.Equal("token"))
+	g.Expect(inputs[1].Review.Object).To(gomega.HaveKeyWithValue("kind", "Secret"))
+	g.Expect(inputs[1].Release).To(gomega.Equal(opaRelease{HelmRelease: "default/webapp", Name: "webapp", Namespace: "team-a"}))
+}
+
+func TestPolicyViolationsMessage(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	var violations []appv1.HelmAppPolicyViolation
+
+	for i := 0; i < 5; i++ {
+		violations = append(violations, appv1.HelmAppPolicyViolation{
+			Resource: appv1.HelmAppResource{Kind: "ConfigMap", Name: fmt.Sprintf("config%d", i)},
+			Message:  "denied",
+		})
+	}
+
+	message := policyViolationsMessage(violations)
+	g.Expect(message).To(gomega.HavePrefix("the release has 5 policy violations: "))
+	g.Expect(message).To(gomega.HaveSuffix("; and 2 more"))
+}
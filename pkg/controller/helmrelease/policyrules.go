@@ -0,0 +1,162 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helmrelease
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	"github.com/ghodss/yaml"
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/checker/decls"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+// celPolicy is the policy of the violations of the CEL policy rules
+const celPolicy = "cel"
+
+// severities of the policy rules
+const (
+	// PolicySeverityError blocks the installs and upgrades of the violating releases
+	PolicySeverityError = "error"
+	// PolicySeverityWarning records a warning event on the violating releases
+	PolicySeverityWarning = "warning"
+)
+
+// PolicyRule is a CEL expression every rendered resource of its kinds must
+// satisfy, e.g. object.spec.template.spec.containers.all(c, has(c.resources.requests)).
+// The expression is given the rendered resource as object and the release as
+// release, like the input of the OPA policies.
+type PolicyRule struct {
+	// Name identifies the rule in the violations
+	Name string `json:"name"`
+	// Kinds are the kinds of the resources the rule applies to, all of them if
+	// empty
+	Kinds []string `json:"kinds,omitempty"`
+	// Expression is the CEL expression, true if the resource satisfies the rule
+	Expression string `json:"expression"`
+	// Message is the message of the violations, the rule name if empty
+	Message string `json:"message,omitempty"`
+	// Severity is error, the default, or warning
+	Severity string `json:"severity,omitempty"`
+
+	program cel.Program
+}
+
+// policyRulesConfig is the policy rules configuration file
+type policyRulesConfig struct {
+	Rules []PolicyRule `json:"rules"`
+}
+
+// LoadPolicyRules reads and compiles the policy rules of the configuration
+// file at path.
+func LoadPolicyRules(path string) ([]PolicyRule, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	config := policyRulesConfig{}
+	if err := yaml.Unmarshal(b, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse the policy rules %s: %w", path, err)
+	}
+
+	env, err := cel.NewEnv(cel.Declarations(
+		decls.NewVar("object", decls.NewMapType(decls.String, decls.Dyn)),
+		decls.NewVar("release", decls.NewMapType(decls.String, decls.String)),
+	))
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range config.Rules {
+		rule := &config.Rules[i]
+
+		switch rule.Severity {
+		case "":
+			rule.Severity = PolicySeverityError
+		case PolicySeverityError, PolicySeverityWarning:
+		default:
+			return nil, fmt.Errorf("unknown severity %q of policy rule %s, error or warning", rule.Severity, rule.Name)
+		}
+
+		ast, issues := env.Compile(rule.Expression)
+		if issues != nil && issues.Err() != nil {
+			return nil, fmt.Errorf("failed to compile policy rule %s: %w", rule.Name, issues.Err())
+		}
+
+		if t := ast.ResultType(); t.GetPrimitive() != decls.Bool.GetPrimitive() && t.GetDyn() == nil {
+			return nil, fmt.Errorf("policy rule %s is not a boolean expression", rule.Name)
+		}
+
+		if rule.program, err = env.Program(ast); err != nil {
+			return nil, fmt.Errorf("failed to compile policy rule %s: %w", rule.Name, err)
+		}
+	}
+
+	return config.Rules, nil
+}
+
+// appliesTo returns whether the rule applies to the resources of kind.
+func (rule PolicyRule) appliesTo(kind string) bool {
+	if len(rule.Kinds) == 0 {
+		return true
+	}
+
+	for _, k := range rule.Kinds {
+		if k == kind {
+			return true
+		}
+	}
+
+	return false
+}
+
+// evaluate returns the violation of the rule by u, nil if u satisfies it. A
+// rule that fails to evaluate, e.g. on a missing field, is violated.
+func (rule PolicyRule) evaluate(u *unstructured.Unstructured, release opaRelease) *appv1.HelmAppPolicyViolation {
+	message := rule.Message
+	if message == "" {
+		message = "the resource violates policy rule " + rule.Name
+	}
+
+	out, _, err := rule.program.Eval(map[string]interface{}{
+		"object": u.Object,
+		"release": map[string]string{
+			"helmRelease": release.HelmRelease,
+			"name":        release.Name,
+			"namespace":   release.Namespace,
+		},
+	})
+
+	switch {
+	case err != nil:
+		message = fmt.Sprintf("%s: %v", message, err)
+	case out.Value() == true:
+		return nil
+	case out.Value() != false:
+		message = fmt.Sprintf("%s: the rule returned %v, not a boolean", message, out.Value())
+	}
+
+	return &appv1.HelmAppPolicyViolation{
+		Resource: policyResource(u),
+		Policy:   celPolicy,
+		Message:  message,
+	}
+}
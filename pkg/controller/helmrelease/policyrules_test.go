@@ -0,0 +1,127 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helmrelease
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+const testPolicyRules = `rules:
+- name: requests
+  kinds: [Deployment]
+  expression: object.spec.template.spec.containers.all(c, has(c.resources) && has(c.resources.requests))
+  message: all Deployments must set resources.requests
+- name: team-label
+  expression: has(object.metadata.labels) && "team" in object.metadata.labels
+  severity: warning
+- name: release-namespace
+  kinds: [ConfigMap]
+  expression: object.metadata.namespace == release.namespace
+`
+
+func loadTestPolicyRules(g *gomega.WithT, rules string) ([]PolicyRule, error) {
+	dir, err := ioutil.TempDir("", "policyrules")
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "rules.yaml")
+	g.Expect(ioutil.WriteFile(path, []byte(rules), 0600)).To(gomega.Succeed())
+
+	return LoadPolicyRules(path)
+}
+
+func TestLoadPolicyRules(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	rules, err := loadTestPolicyRules(g, testPolicyRules)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(rules).To(gomega.HaveLen(3))
+	g.Expect(rules[0].Severity).To(gomega.Equal(PolicySeverityError))
+	g.Expect(rules[1].Severity).To(gomega.Equal(PolicySeverityWarning))
+
+	_, err = loadTestPolicyRules(g, "rules:\n- name: broken\n  expression: object.spec ==\n")
+	g.Expect(err).To(gomega.MatchError(gomega.ContainSubstring("failed to compile policy rule broken")))
+
+	_, err = loadTestPolicyRules(g, "rules:\n- name: name\n  expression: release.name\n")
+	g.Expect(err).To(gomega.MatchError(gomega.ContainSubstring("policy rule name is not a boolean expression")))
+
+	_, err = loadTestPolicyRules(g, "rules:\n- name: audit\n  expression: 'true'\n  severity: info\n")
+	g.Expect(err).To(gomega.MatchError(gomega.ContainSubstring(`unknown severity "info"`)))
+}
+
+func TestCheckPolicyRules(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	defer func(rules []PolicyRule) { Options.PolicyRules = rules }(Options.PolicyRules)
+
+	rules, err := loadTestPolicyRules(g, testPolicyRules)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	Options.PolicyRules = rules
+
+	hr := &appv1.HelmRelease{ObjectMeta: metav1.ObjectMeta{Name: "webapp", Namespace: "default"}}
+	hr.Repo.TargetNamespace = "team-a"
+
+	deployment := newScopedObject("Deployment", "team-a", "web")
+	deployment.SetLabels(map[string]string{"team": "a"})
+	g.Expect(unstructured.SetNestedSlice(deployment.Object, []interface{}{
+		map[string]interface{}{"name": "web", "resources": map[string]interface{}{
+			"requests": map[string]interface{}{"cpu": "100m"},
+		}},
+		map[string]interface{}{"name": "sidecar"},
+	}, "spec", "template", "spec", "containers")).To(gomega.Succeed())
+
+	config := newScopedObject("ConfigMap", "team-b", "config")
+	config.SetLabels(map[string]string{"team": "a"})
+
+	m := &policyManager{renderManager{objects: []*unstructured.Unstructured{
+		deployment, config, newScopedObject("Service", "team-a", "web"),
+	}}}
+
+	violations, warnings, err := checkPolicies(hr, m)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(violations).To(gomega.Equal([]appv1.HelmAppPolicyViolation{
+		{
+			Resource: appv1.HelmAppResource{Kind: "Deployment", Namespace: "team-a", Name: "web"},
+			Policy:   celPolicy,
+			Message:  "all Deployments must set resources.requests",
+		},
+		{
+			Resource: appv1.HelmAppResource{Kind: "ConfigMap", Namespace: "team-b", Name: "config"},
+			Policy:   celPolicy,
+			Message:  "the resource violates policy rule release-namespace",
+		},
+	}))
+
+	// the rules of warning severity do not block the release
+	g.Expect(warnings).To(gomega.Equal([]appv1.HelmAppPolicyViolation{{
+		Resource: appv1.HelmAppResource{Kind: "Service", Namespace: "team-a", Name: "web"},
+		Policy:   celPolicy,
+		Message:  "the resource violates policy rule team-label",
+	}}))
+	g.Expect(policyWarningsMessage(warnings)).To(gomega.HavePrefix("the release has 1 policy warnings: "))
+}
@@ -0,0 +1,186 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helmrelease
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/semver/v3"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/discovery"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+	helmclient "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/client"
+)
+
+// preconditionRetryInterval is how often a HelmRelease whose preconditions are not met is checked again
+const preconditionRetryInterval = time.Minute
+
+var crdGVK = schema.GroupVersionKind{Group: "apiextensions.k8s.io", Version: "v1", Kind: "CustomResourceDefinition"}
+
+// checkPreconditions returns true if the cluster the release of hr is deployed
+// to meets its preconditions. Otherwise the PreconditionsNotMet condition of
+// hr lists the unmet ones.
+func (r *ReconcileHelmRelease) checkPreconditions(hr *appv1.HelmRelease) (bool, error) {
+	p := hr.Repo.Preconditions
+	if p == nil {
+		hr.Status.RemoveCondition(appv1.ConditionPreconditionsNotMet)
+		return true, nil
+	}
+
+	cfg, err := r.clusterConfig(hr)
+	if err != nil {
+		return false, err
+	}
+
+	dc, err := helmclient.SharedDiscoveryClient(cfg)
+	if err != nil {
+		return false, err
+	}
+
+	var unmet []string
+
+	if p.KubeVersion != "" {
+		msg, err := unmetKubeVersion(dc, p.KubeVersion)
+		if err != nil {
+			return false, err
+		}
+
+		if msg != "" {
+			unmet = append(unmet, msg)
+		}
+	}
+
+	if len(p.APIGroups) != 0 {
+		// the shared cache is refreshed at most once per MinDiscoveryAge, so
+		// that a group served since the last check is seen on the next retry
+		dc.Invalidate()
+
+		groups, err := dc.ServerGroups()
+		if err != nil {
+			return false, fmt.Errorf("failed to discover the API groups: %w", err)
+		}
+
+		for _, required := range p.APIGroups {
+			if !servesAPIGroup(groups, required) {
+				unmet = append(unmet, fmt.Sprintf("API %s is not served", required))
+			}
+		}
+	}
+
+	if len(p.CRDs) != 0 {
+		c, err := r.clusterClient(hr)
+		if err != nil {
+			return false, err
+		}
+
+		for _, name := range p.CRDs {
+			crd := &unstructured.Unstructured{}
+			crd.SetGroupVersionKind(crdGVK)
+
+			err := c.Get(context.TODO(), types.NamespacedName{Name: name}, crd)
+			if apierrors.IsNotFound(err) {
+				unmet = append(unmet, fmt.Sprintf("CustomResourceDefinition %s is not installed", name))
+				continue
+			}
+
+			if err != nil {
+				return false, fmt.Errorf("failed to get CustomResourceDefinition %s: %w", name, err)
+			}
+		}
+	}
+
+	if len(unmet) == 0 {
+		hr.Status.RemoveCondition(appv1.ConditionPreconditionsNotMet)
+		return true, nil
+	}
+
+	message := strings.Join(unmet, "; ")
+
+	logFor(hr).Info("Preconditions not met", "unmet", message)
+
+	if c := hr.Status.GetCondition(appv1.ConditionPreconditionsNotMet); c == nil || c.Status != appv1.StatusTrue ||
+		c.Message != message {
+		r.recordWarning(hr, eventPreconditionsNotMet, errors.New(message))
+	}
+
+	hr.Status.SetCondition(appv1.HelmAppCondition{
+		Type:    appv1.ConditionPreconditionsNotMet,
+		Status:  appv1.StatusTrue,
+		Reason:  appv1.ReasonClusterCapabilityMissing,
+		Message: message,
+	})
+
+	return false, nil
+}
+
+// unmetKubeVersion returns why the Kubernetes version of the cluster does not
+// satisfy constraint, empty if it does. The pre-release and the build of the
+// version, e.g. -gke.100, are ignored, the constraints would not match them.
+func unmetKubeVersion(dc discovery.ServerVersionInterface, constraint string) (string, error) {
+	c, err := semver.NewConstraint(constraint)
+	if err != nil {
+		return "", fmt.Errorf("invalid kubeVersion precondition %q: %w", constraint, err)
+	}
+
+	info, err := dc.ServerVersion()
+	if err != nil {
+		return "", fmt.Errorf("failed to get the Kubernetes version: %w", err)
+	}
+
+	v, err := semver.NewVersion(info.GitVersion)
+	if err != nil {
+		return "", fmt.Errorf("invalid Kubernetes version %q: %w", info.GitVersion, err)
+	}
+
+	release := semver.MustParse(fmt.Sprintf("%d.%d.%d", v.Major(), v.Minor(), v.Patch()))
+	if c.Check(release) {
+		return "", nil
+	}
+
+	return fmt.Sprintf("Kubernetes version %s does not satisfy %s", info.GitVersion, constraint), nil
+}
+
+// servesAPIGroup returns true if the API group, or group version, required is
+// served.
+func servesAPIGroup(groups *metav1.APIGroupList, required string) bool {
+	for _, group := range groups.Groups {
+		if !strings.Contains(required, "/") {
+			if group.Name == required {
+				return true
+			}
+
+			continue
+		}
+
+		for _, version := range group.Versions {
+			if version.GroupVersion == required {
+				return true
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,58 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helmrelease
+
+import (
+	"testing"
+
+	"github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/version"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+func TestUnmetKubeVersion(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	dc := &fakediscovery.FakeDiscovery{
+		Fake:               &clienttesting.Fake{},
+		FakedServerVersion: &version.Info{GitVersion: "v1.18.12-gke.1201"},
+	}
+
+	// the pre-release of the provider is ignored
+	g.Expect(unmetKubeVersion(dc, ">= 1.18")).To(gomega.BeEmpty())
+	g.Expect(unmetKubeVersion(dc, ">= 1.19")).
+		To(gomega.Equal("Kubernetes version v1.18.12-gke.1201 does not satisfy >= 1.19"))
+
+	_, err := unmetKubeVersion(dc, "recent")
+	g.Expect(err).To(gomega.HaveOccurred())
+}
+
+func TestServesAPIGroup(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	groups := &metav1.APIGroupList{Groups: []metav1.APIGroup{{
+		Name:     "cert-manager.io",
+		Versions: []metav1.GroupVersionForDiscovery{{GroupVersion: "cert-manager.io/v1", Version: "v1"}},
+	}}}
+
+	g.Expect(servesAPIGroup(groups, "cert-manager.io")).To(gomega.BeTrue())
+	g.Expect(servesAPIGroup(groups, "cert-manager.io/v1")).To(gomega.BeTrue())
+	g.Expect(servesAPIGroup(groups, "cert-manager.io/v1alpha2")).To(gomega.BeFalse())
+	g.Expect(servesAPIGroup(groups, "monitoring.coreos.com")).To(gomega.BeFalse())
+}
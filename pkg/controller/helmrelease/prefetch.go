@@ -0,0 +1,109 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helmrelease
+
+import (
+	"context"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+	"github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/utils"
+)
+
+// chartPrefetcher downloads the charts of the HelmReleases queued right away
+// when they are listed, e.g. when the operator starts, ahead of their
+// reconciles. The downloads run in parallel in the download pool instead of
+// one per reconcile worker, and the reconciles then find the charts in the
+// chart cache. Only the helm repo archives are cached, the git sources are not
+// prefetched.
+type chartPrefetcher struct {
+	mgr   manager.Manager
+	queue workqueue.Interface
+}
+
+// prefetcher is set once the controller is added to the manager
+var prefetcher *chartPrefetcher
+
+func newChartPrefetcher(mgr manager.Manager) *chartPrefetcher {
+	return &chartPrefetcher{mgr: mgr, queue: workqueue.NewNamed("chart-prefetch")}
+}
+
+// prefetch queues the download of the chart of hr. It does nothing if the
+// prefetcher is not started.
+func (p *chartPrefetcher) prefetch(hr *appv1.HelmRelease) {
+	if p == nil || hr.GetDeletionTimestamp() != nil || hr.Repo.Suspend || hr.Repo.Source == nil ||
+		!strings.EqualFold(string(hr.Repo.Source.SourceType), string(appv1.HelmRepoSourceType)) {
+		return
+	}
+
+	p.queue.Add(types.NamespacedName{Namespace: hr.GetNamespace(), Name: hr.GetName()})
+}
+
+// Start runs one download worker per slot of the download pool until stop is
+// closed.
+func (p *chartPrefetcher) Start(stop <-chan struct{}) error {
+	for i := 0; i < utils.DownloadConcurrency(); i++ {
+		go p.work()
+	}
+
+	<-stop
+	p.queue.ShutDown()
+
+	return nil
+}
+
+func (p *chartPrefetcher) work() {
+	for {
+		item, shutdown := p.queue.Get()
+		if shutdown {
+			return
+		}
+
+		p.download(item.(types.NamespacedName))
+		p.queue.Done(item)
+	}
+}
+
+// download downloads the chart of the HelmRelease key to the chart cache. A
+// failed download is left to the reconcile, which reports it.
+func (p *chartPrefetcher) download(key types.NamespacedName) {
+	hr := &appv1.HelmRelease{}
+
+	if err := p.mgr.GetClient().Get(context.TODO(), key, hr); err != nil {
+		if !apierrors.IsNotFound(err) {
+			log.Error(err, "Failed to get the HelmRelease of a chart prefetch", "helmrelease", key.String())
+		}
+
+		return
+	}
+
+	if err := appv1.CheckChartSource(hr.GetNamespace(), hr.Repo.Source); err != nil {
+		return
+	}
+
+	if _, err := downloadChart(p.mgr, hr); err != nil {
+		logFor(hr).V(1).Info("Failed to prefetch the chart", "error", err.Error())
+		return
+	}
+
+	logFor(hr).V(3).Info("Prefetched the chart")
+}
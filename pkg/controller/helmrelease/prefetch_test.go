@@ -0,0 +1,68 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helmrelease
+
+import (
+	"testing"
+
+	"github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+func TestChartPrefetch(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	newHelmRelease := func(name string, sourceType appv1.SourceTypeEnum) *appv1.HelmRelease {
+		hr := &appv1.HelmRelease{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"}}
+		hr.Repo.Source = &appv1.Source{SourceType: sourceType}
+
+		return hr
+	}
+
+	suspended := newHelmRelease("suspended", appv1.HelmRepoSourceType)
+	suspended.Repo.Suspend = true
+
+	deleted := newHelmRelease("deleted", appv1.HelmRepoSourceType)
+	now := metav1.Now()
+	deleted.SetDeletionTimestamp(&now)
+
+	// the prefetches are ignored until the prefetcher is set
+	var p *chartPrefetcher
+	p.prefetch(newHelmRelease("webapp", appv1.HelmRepoSourceType))
+
+	p = newChartPrefetcher(nil)
+	defer p.queue.ShutDown()
+
+	// only the charts of the helm repos are cached
+	for _, hr := range []*appv1.HelmRelease{
+		newHelmRelease("git", appv1.GitSourceType),
+		{ObjectMeta: metav1.ObjectMeta{Name: "nosource", Namespace: "default"}},
+		suspended,
+		deleted,
+		newHelmRelease("webapp", appv1.HelmRepoSourceType),
+	} {
+		p.prefetch(hr)
+	}
+
+	g.Expect(p.queue.Len()).To(gomega.Equal(1))
+
+	item, _ := p.queue.Get()
+	g.Expect(item).To(gomega.Equal(types.NamespacedName{Namespace: "default", Name: "webapp"}))
+}
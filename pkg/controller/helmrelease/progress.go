@@ -0,0 +1,94 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helmrelease
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+	"github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/release"
+)
+
+// progressInterval is how often the rollout progress is published while a
+// release action waits for the resources.
+const progressInterval = 10 * time.Second
+
+// reportProgress publishes the rollout progress of the release of hr to its
+// status while an install or upgrade waits for the resources, so that long
+// rollouts do not look hung. The returned function stops it and must be
+// called before hr is updated again.
+func (r ReconcileHelmRelease) reportProgress(hr *appv1.HelmRelease, manager release.Manager) func() {
+	if !hr.Repo.Wait {
+		return func() {}
+	}
+
+	base := hr.DeepCopy()
+	patched := base.DeepCopy()
+	done := make(chan struct{})
+
+	var wg sync.WaitGroup
+
+	wg.Add(1)
+
+	go func() {
+		defer wg.Done()
+
+		ticker := time.NewTicker(progressInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+
+			progress := manager.RolloutProgress()
+			if progress == "" || progress == patched.Status.Progress {
+				continue
+			}
+
+			// only the progress is patched, without the resource version
+			update := patched.DeepCopy()
+			update.Status.Progress = progress
+
+			if err := r.GetClient().Status().Patch(context.TODO(), update, client.MergeFrom(patched)); err != nil {
+				logFor(base).Error(err, "Failed to report the rollout progress")
+				continue
+			}
+
+			logFor(base).V(1).Info("Rollout progress", "progress", progress)
+
+			patched = update
+		}
+	}()
+
+	return func() {
+		close(done)
+		wg.Wait()
+
+		// the status update following the action must not conflict with the patches
+		if patched.GetResourceVersion() != base.GetResourceVersion() {
+			hr.SetResourceVersion(patched.GetResourceVersion())
+			statusWrites.observeProgress(hr, patched.Status.Progress)
+		}
+	}
+}
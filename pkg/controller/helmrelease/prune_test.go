@@ -0,0 +1,55 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helmrelease
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/onsi/gomega"
+	rpb "helm.sh/helm/v3/pkg/release"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+// configMapManifest returns the manifest of a release of the ConfigMaps names.
+func configMapManifest(names ...string) string {
+	var b strings.Builder
+
+	for _, name := range names {
+		fmt.Fprintf(&b, "---\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: %s\ndata:\n  key: value\n", name)
+	}
+
+	return b.String()
+}
+
+func TestPrunedResources(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	hr := &appv1.HelmRelease{}
+	previous := &rpb.Release{Manifest: configMapManifest("kept", "removed")}
+	upgraded := &rpb.Release{Manifest: configMapManifest("kept")}
+
+	g.Expect(prunedResources(hr, previous, upgraded)).To(gomega.Equal([]appv1.HelmAppResource{
+		{APIVersion: "v1", Kind: "ConfigMap", Name: "removed"},
+	}))
+	g.Expect(prunedResources(hr, nil, upgraded)).To(gomega.BeEmpty())
+
+	// nothing is reported as pruned when the pruning is disabled
+	prune := false
+	hr.Repo.Prune = &prune
+	g.Expect(prunedResources(hr, previous, upgraded)).To(gomega.BeEmpty())
+}
@@ -0,0 +1,42 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helmrelease
+
+import (
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+	"github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/release"
+)
+
+// redactStatus redacts the data of the Secrets of the deployed manifest and
+// the secret-shaped data of the messages of the status of hr, e.g. the
+// credentials of a repo URL echoed by a download error. The status is
+// readable by everyone allowed to read the HelmReleases.
+func redactStatus(hr *appv1.HelmRelease) {
+	status := &hr.Status
+
+	if status.DeployedRelease != nil {
+		status.DeployedRelease.Manifest = release.RedactManifest(status.DeployedRelease.Manifest)
+	}
+
+	for i := range status.Conditions {
+		status.Conditions[i].Message = release.RedactMessage(status.Conditions[i].Message)
+	}
+
+	for i := range status.PolicyViolations {
+		status.PolicyViolations[i].Message = release.RedactMessage(status.PolicyViolations[i].Message)
+	}
+}
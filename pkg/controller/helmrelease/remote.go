@@ -0,0 +1,66 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helmrelease
+
+import (
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+	helmclient "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/client"
+	"github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/release"
+)
+
+// clusterConfig returns the config of the cluster the release of hr is
+// deployed to, the remote cluster of its kubeconfig or the operator's.
+func (r *ReconcileHelmRelease) clusterConfig(hr *appv1.HelmRelease) (*rest.Config, error) {
+	remote, err := release.RemoteConfig(r.GetAPIReader(), hr.GetNamespace(), hr.Repo.KubeConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	if remote != nil {
+		return Options.KubeClient.Apply(remote), nil
+	}
+
+	return r.GetConfig(), nil
+}
+
+// clusterClient returns an uncached client of the cluster the release of hr is
+// deployed to. The resources of the charts are not cached, they can be of any
+// kind in any namespace.
+func (r *ReconcileHelmRelease) clusterClient(hr *appv1.HelmRelease) (client.Client, error) {
+	if hr.Repo.KubeConfig == nil {
+		return client.DelegatingClient{
+			Reader:       r.GetAPIReader(),
+			Writer:       r.GetClient(),
+			StatusClient: r.GetClient(),
+		}, nil
+	}
+
+	cfg, err := r.clusterConfig(hr)
+	if err != nil {
+		return nil, err
+	}
+
+	mapper, err := helmclient.SharedRESTMapper(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return client.New(cfg, client.Options{Scheme: r.GetScheme(), Mapper: mapper})
+}
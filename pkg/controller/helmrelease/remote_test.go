@@ -0,0 +1,97 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helmrelease
+
+import (
+	"testing"
+	"time"
+
+	"github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+	"github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/release"
+)
+
+// remoteManager reads the kubeconfig Secrets of the remote clusters from a
+// fake client.
+type remoteManager struct {
+	clientManager
+	cfg *rest.Config
+}
+
+func (m remoteManager) GetConfig() *rest.Config {
+	return m.cfg
+}
+
+const remoteKubeConfig = `apiVersion: v1
+kind: Config
+clusters:
+- name: remote
+  cluster:
+    server: https://remote.example.com:6443
+contexts:
+- name: remote
+  context:
+    cluster: remote
+    user: remote
+current-context: remote
+users:
+- name: remote
+  user:
+    token: remote-token
+`
+
+func TestClusterConfig(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	defer func(o release.ClientOptions) { Options.KubeClient = o }(Options.KubeClient)
+	Options.KubeClient = release.ClientOptions{QPS: 50, Burst: 100, Timeout: time.Minute}
+
+	local := &rest.Config{Host: "https://local.example.com:6443", QPS: 20}
+	c := fake.NewFakeClientWithScheme(scheme.Scheme, &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "remote", Namespace: "default"},
+		Data:       map[string][]byte{release.DefaultKubeConfigKey: []byte(remoteKubeConfig)},
+	})
+	r := &ReconcileHelmRelease{remoteManager{clientManager: clientManager{client: c}, cfg: local}}
+
+	// the config of the operator's cluster is the one of the manager
+	hr := &appv1.HelmRelease{ObjectMeta: metav1.ObjectMeta{Name: "webapp", Namespace: "default"}}
+
+	cfg, err := r.clusterConfig(hr)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(cfg).To(gomega.BeIdenticalTo(local))
+
+	// the remote clusters are sent the requests with the client options
+	hr.Repo.KubeConfig = &appv1.KubeConfig{SecretRef: corev1.LocalObjectReference{Name: "remote"}}
+
+	cfg, err = r.clusterConfig(hr)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(cfg.Host).To(gomega.Equal("https://remote.example.com:6443"))
+	g.Expect(cfg.QPS).To(gomega.Equal(float32(50)))
+	g.Expect(cfg.Burst).To(gomega.Equal(100))
+	g.Expect(cfg.Timeout).To(gomega.Equal(time.Minute))
+
+	hr.Repo.KubeConfig.SecretRef.Name = "missing"
+
+	_, err = r.clusterConfig(hr)
+	g.Expect(err).To(gomega.HaveOccurred())
+}
@@ -0,0 +1,143 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helmrelease
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	workv1 "github.com/open-cluster-management/api/work/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+	"github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/release"
+)
+
+// clusterIDNamespace is the namespace whose UID identifies a cluster, it
+// exists as long as the cluster
+const clusterIDNamespace = "kube-system"
+
+// checkClusterReplaced compares the cluster the release of hr is deployed to
+// with the one it was deployed to before. If the cluster was replaced, e.g.
+// deleted and created again behind the same kubeconfig, the release records
+// left in the storage and the status of the release are stale: they are
+// deleted so that the release is installed again from scratch.
+func (r *ReconcileHelmRelease) checkClusterReplaced(ctx context.Context, hr *appv1.HelmRelease,
+	manager release.Manager) error {
+	// the namespaces of the cluster cannot be read in namespace-scoped mode
+	if Options.NamespaceScoped && hr.Repo.KubeConfig == nil {
+		return nil
+	}
+
+	c, err := r.clusterClient(hr)
+	if err != nil {
+		return err
+	}
+
+	ns := &corev1.Namespace{}
+	if err := c.Get(context.TODO(), types.NamespacedName{Name: clusterIDNamespace}, ns); err != nil {
+		return fmt.Errorf("failed to get the %s namespace identifying the cluster: %w", clusterIDNamespace, err)
+	}
+
+	id := string(ns.GetUID())
+
+	previous := hr.Status.ClusterID
+	if previous == "" || previous == id {
+		hr.Status.ClusterID = id
+		return nil
+	}
+
+	logFor(hr).Info("The cluster was replaced, installing the release again", "previousCluster", previous, "cluster", id)
+
+	// the records of a storage outside of the cluster, e.g. sql, survive it
+	if _, err := manager.OrphanRelease(ctx); err != nil && !errors.Is(err, release.ErrReleaseNotFound) {
+		return fmt.Errorf("failed to delete the release records of the replaced cluster: %w", err)
+	}
+
+	hr.Status.ClusterID = id
+	hr.Status.DeployedRelease = nil
+	hr.Status.PrunedResources = nil
+	hr.Status.OrphanedResources = nil
+	hr.Status.FieldConflicts = nil
+	hr.Status.Resources = nil
+	hr.Status.Progress = ""
+
+	for _, t := range []appv1.HelmAppConditionType{
+		appv1.ConditionDeployed,
+		appv1.ConditionReleased,
+		appv1.ConditionDrifted,
+		appv1.ConditionResourcesReady,
+		appv1.ConditionReleaseFailed,
+	} {
+		hr.Status.RemoveCondition(t)
+	}
+
+	r.recordEvent(hr, eventClusterReplaced, "The cluster was replaced, cluster ID %s instead of %s, the release is installed again",
+		id, previous)
+
+	return nil
+}
+
+// replaceStaleManifestWorks deletes the ManifestWorks of hr created before
+// their ManagedCluster: the cluster was deleted and registered again with the
+// same name since, their status is the one of the former cluster. They are
+// created again for the new cluster.
+func (r *ReconcileHelmRelease) replaceStaleManifestWorks(hr *appv1.HelmRelease) error {
+	works := &workv1.ManifestWorkList{}
+	if err := r.GetAPIReader().List(context.TODO(), works, manifestWorkLabels(hr)); err != nil {
+		return fmt.Errorf("failed to list ManifestWorks: %w", err)
+	}
+
+	for i := range works.Items {
+		work := &works.Items[i]
+		if work.GetDeletionTimestamp() != nil {
+			continue
+		}
+
+		cluster := &clusterv1.ManagedCluster{}
+
+		err := r.GetAPIReader().Get(context.TODO(), types.NamespacedName{Name: work.GetNamespace()}, cluster)
+		if apierrors.IsNotFound(err) {
+			continue
+		}
+
+		if err != nil {
+			return fmt.Errorf("failed to get ManagedCluster %s: %w", work.GetNamespace(), err)
+		}
+
+		created := work.GetCreationTimestamp()
+		if !created.Before(&cluster.ObjectMeta.CreationTimestamp) {
+			continue
+		}
+
+		logFor(hr).Info("ManagedCluster was registered again, re-creating its ManifestWork",
+			"managedcluster", cluster.GetName(), "manifestwork", work.GetNamespace()+"/"+work.GetName())
+
+		if err := r.GetClient().Delete(context.TODO(), work); err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("failed to delete the ManifestWork of replaced cluster %s: %w", cluster.GetName(), err)
+		}
+
+		r.recordEvent(hr, eventClusterReplaced, "ManagedCluster %s was registered again, its ManifestWork is created again",
+			cluster.GetName())
+	}
+
+	return nil
+}
@@ -0,0 +1,108 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helmrelease
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/onsi/gomega"
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	workv1 "github.com/open-cluster-management/api/work/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+// clientManager serves the API reads and writes of the reconciles from a
+// fake client and records their events.
+type clientManager struct {
+	manager.Manager
+	client   client.Client
+	recorder *record.FakeRecorder
+}
+
+func (m clientManager) GetClient() client.Client {
+	return m.client
+}
+
+func (m clientManager) GetAPIReader() client.Reader {
+	return m.client
+}
+
+func (m clientManager) GetEventRecorderFor(string) record.EventRecorder {
+	return m.recorder
+}
+
+// GetScheme returns the scheme the suite registers the APIs of the operator to.
+func (m clientManager) GetScheme() *runtime.Scheme {
+	return scheme.Scheme
+}
+
+func TestReplaceStaleManifestWorks(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	s := runtime.NewScheme()
+	g.Expect(clusterv1.Install(s)).To(gomega.Succeed())
+	g.Expect(workv1.Install(s)).To(gomega.Succeed())
+
+	hr := &appv1.HelmRelease{ObjectMeta: metav1.ObjectMeta{Name: "webapp", Namespace: "default"}}
+	registered := metav1.NewTime(time.Now().Add(-time.Hour).Truncate(time.Second))
+
+	newWork := func(cluster string, created metav1.Time) *workv1.ManifestWork {
+		return &workv1.ManifestWork{ObjectMeta: metav1.ObjectMeta{
+			Name:              manifestWorkName(hr),
+			Namespace:         cluster,
+			Labels:            manifestWorkLabels(hr),
+			CreationTimestamp: created,
+		}}
+	}
+
+	newCluster := func(name string) *clusterv1.ManagedCluster {
+		return &clusterv1.ManagedCluster{ObjectMeta: metav1.ObjectMeta{Name: name, CreationTimestamp: registered}}
+	}
+
+	c := fake.NewFakeClientWithScheme(s,
+		newCluster("current"), newWork("current", metav1.NewTime(registered.Add(time.Minute))),
+		newCluster("replaced"), newWork("replaced", metav1.NewTime(registered.Add(-time.Minute))),
+		// the works of deleted clusters are left to the work agent
+		newWork("deleted", metav1.NewTime(registered.Add(-time.Minute))))
+
+	recorder := record.NewFakeRecorder(10)
+	r := &ReconcileHelmRelease{clientManager{client: c, recorder: recorder}}
+
+	g.Expect(r.replaceStaleManifestWorks(hr)).To(gomega.Succeed())
+
+	works := &workv1.ManifestWorkList{}
+	g.Expect(c.List(context.TODO(), works)).To(gomega.Succeed())
+
+	var clusters []string
+	for _, work := range works.Items {
+		clusters = append(clusters, work.GetNamespace())
+	}
+
+	g.Expect(clusters).To(gomega.ConsistOf("current", "deleted"))
+	g.Expect(recorder.Events).To(gomega.Receive(gomega.ContainSubstring("ManagedCluster replaced was registered again")))
+	g.Expect(recorder.Events).NotTo(gomega.Receive())
+}
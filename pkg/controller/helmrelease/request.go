@@ -0,0 +1,133 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helmrelease
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+	"github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/release"
+	"github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/utils"
+)
+
+// forceUpgradeAnnotation set to true upgrades the installed release on the
+// next reconcile even if it is up to date, e.g. to run its hooks again. It is
+// removed once the upgrade succeeds.
+const forceUpgradeAnnotation = "apps.open-cluster-management.io/force-upgrade"
+
+// reconcileRequested returns the value of the reconcile-at annotation of hr
+// if it was not handled yet, an empty string otherwise.
+func reconcileRequested(hr *appv1.HelmRelease) string {
+	requested := hr.GetAnnotations()[release.ReconcileRequestAnnotation]
+	if requested == hr.Status.LastHandledReconcileAt {
+		return ""
+	}
+
+	return requested
+}
+
+// requestAnnotationChanged returns true if an annotation changing the outcome
+// of a reconcile changed between old and new. The other metadata changes,
+// e.g. of the labels, do not need a reconcile, nor does the removal of a
+// request, e.g. of the force-upgrade annotation once the upgrade succeeded.
+func requestAnnotationChanged(old, new metav1.Object) bool {
+	for _, key := range []string{release.ReconcileRequestAnnotation, forceUpgradeAnnotation, forceDeleteAnnotation, debugDumpAnnotation} {
+		if value := new.GetAnnotations()[key]; value != "" && value != old.GetAnnotations()[key] {
+			return true
+		}
+	}
+
+	// the changes held back are applied once the hold is removed
+	if old.GetAnnotations()[holdAnnotation] != new.GetAnnotations()[holdAnnotation] {
+		return true
+	}
+
+	// unpinned revisions may be compacted
+	return old.GetAnnotations()[release.PinnedRevisionsAnnotation] != new.GetAnnotations()[release.PinnedRevisionsAnnotation]
+}
+
+// holdAnnotation set to true holds the installed release at its deployed
+// revision: the changes of the spec and of the chart are reported as held
+// back and only applied once the annotation is removed.
+const holdAnnotation = "apps.open-cluster-management.io/hold"
+
+// holdRequested returns true if hr is annotated to keep its deployed
+// revision.
+func holdRequested(hr *appv1.HelmRelease) bool {
+	hold, err := strconv.ParseBool(hr.GetAnnotations()[holdAnnotation])
+	return err == nil && hold
+}
+
+// holdBack reports the upgrade of the release of hr held back by the hold
+// annotation. It is reconciled again once the annotation changes.
+func (r *ReconcileHelmRelease) holdBack(hr *appv1.HelmRelease) (reconcile.Result, error) {
+	message := "The changes are held back by the " + holdAnnotation + " annotation"
+	if deployed := hr.Status.DeployedRelease; deployed != nil {
+		message = fmt.Sprintf("The release is held at revision %d by the %s annotation", deployed.Revision, holdAnnotation)
+	}
+
+	logFor(hr).Info("Upgrade is held back")
+
+	hr.Status.SetCondition(appv1.HelmAppCondition{
+		Type:               appv1.ConditionUpgradePending,
+		Status:             appv1.StatusTrue,
+		Reason:             appv1.ReasonHeldBack,
+		Message:            message + ", the changes of the spec or of the chart are applied once it is removed",
+		ObservedGeneration: hr.GetGeneration(),
+	})
+
+	// the spec was seen, its changes are held on purpose
+	hr.Status.ObservedGeneration = hr.GetGeneration()
+
+	// the held release is checked again like a deployed one, e.g. for a
+	// changed chart
+	return reconcile.Result{RequeueAfter: reconcileInterval(hr)}, r.updateResourceStatus(hr)
+}
+
+// forceUpgradeRequested returns true if hr is annotated to be upgraded even
+// if its release is up to date.
+func forceUpgradeRequested(hr *appv1.HelmRelease) bool {
+	force, err := strconv.ParseBool(hr.GetAnnotations()[forceUpgradeAnnotation])
+	return err == nil && force
+}
+
+// consumeForceUpgrade removes the force-upgrade annotation of hr once its
+// release is upgraded.
+func (r *ReconcileHelmRelease) consumeForceUpgrade(hr *appv1.HelmRelease) error {
+	patch := client.MergeFrom(hr.DeepCopy())
+
+	annotations := hr.GetAnnotations()
+	delete(annotations, forceUpgradeAnnotation)
+	hr.SetAnnotations(annotations)
+
+	return r.GetClient().Patch(context.TODO(), hr, patch)
+}
+
+// discardChart removes the downloaded chart of hr so that it is downloaded
+// again, e.g. after the repository it failed to download from is fixed.
+func discardChart(hr *appv1.HelmRelease) {
+	if err := utils.RemoveChartCache(os.Getenv(appv1.ChartsDir), hr); err != nil {
+		logFor(hr).Error(err, "Failed to remove the downloaded chart")
+	}
+}
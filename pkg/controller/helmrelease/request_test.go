@@ -0,0 +1,123 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helmrelease
+
+import (
+	"testing"
+
+	"github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+	"github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/release"
+)
+
+func TestReconcileRequested(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	hr := &appv1.HelmRelease{}
+	g.Expect(reconcileRequested(hr)).To(gomega.BeEmpty())
+
+	hr.SetAnnotations(map[string]string{release.ReconcileRequestAnnotation: "2020-11-20T10:00:00Z"})
+	g.Expect(reconcileRequested(hr)).To(gomega.Equal("2020-11-20T10:00:00Z"))
+
+	// handled once
+	hr.Status.LastHandledReconcileAt = "2020-11-20T10:00:00Z"
+	g.Expect(reconcileRequested(hr)).To(gomega.BeEmpty())
+}
+
+func TestForceUpgradeRequested(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	hr := &appv1.HelmRelease{}
+	g.Expect(forceUpgradeRequested(hr)).To(gomega.BeFalse())
+
+	hr.SetAnnotations(map[string]string{forceUpgradeAnnotation: "yes"})
+	g.Expect(forceUpgradeRequested(hr)).To(gomega.BeFalse())
+
+	hr.SetAnnotations(map[string]string{forceUpgradeAnnotation: "true"})
+	g.Expect(forceUpgradeRequested(hr)).To(gomega.BeTrue())
+
+	// setting it triggers a reconcile
+	g.Expect(requestAnnotationChanged(&appv1.HelmRelease{}, hr)).To(gomega.BeTrue())
+	g.Expect(requestAnnotationChanged(hr, hr.DeepCopy())).To(gomega.BeFalse())
+}
+
+func TestRequestAnnotationChanged(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	old := &appv1.HelmRelease{}
+	old.SetAnnotations(map[string]string{forceUpgradeAnnotation: "true"})
+
+	// the other metadata changes do not trigger a reconcile
+	labeled := old.DeepCopy()
+	labeled.SetLabels(map[string]string{"team": "a"})
+	g.Expect(requestAnnotationChanged(old, labeled)).To(gomega.BeFalse())
+
+	// nor does removing a handled request
+	g.Expect(requestAnnotationChanged(old, &appv1.HelmRelease{})).To(gomega.BeFalse())
+
+	for _, key := range []string{release.ReconcileRequestAnnotation, forceDeleteAnnotation, release.PinnedRevisionsAnnotation} {
+		requested := old.DeepCopy()
+		requested.GetAnnotations()[key] = "true"
+		g.Expect(requestAnnotationChanged(old, requested)).To(gomega.BeTrue(), key)
+	}
+
+	// unpinning revisions lets them be compacted
+	pinned := old.DeepCopy()
+	pinned.GetAnnotations()[release.PinnedRevisionsAnnotation] = "3"
+	g.Expect(requestAnnotationChanged(pinned, old)).To(gomega.BeTrue())
+}
+
+func TestHoldBack(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	hr := &appv1.HelmRelease{ObjectMeta: metav1.ObjectMeta{Name: "webapp", Namespace: "default", Generation: 4}}
+	hr.Status.DeployedRelease = &appv1.HelmAppRelease{Revision: 3}
+	hr.Status.ObservedGeneration = 3
+	g.Expect(holdRequested(hr)).To(gomega.BeFalse())
+
+	held := hr.DeepCopy()
+	held.SetAnnotations(map[string]string{holdAnnotation: "true"})
+	g.Expect(holdRequested(held)).To(gomega.BeTrue())
+
+	// the changes held back are applied once the hold is removed
+	g.Expect(requestAnnotationChanged(hr, held)).To(gomega.BeTrue())
+	g.Expect(requestAnnotationChanged(held, hr)).To(gomega.BeTrue())
+
+	c := fake.NewFakeClientWithScheme(scheme.Scheme, held.DeepCopy())
+	r := &ReconcileHelmRelease{clientManager{client: c, recorder: record.NewFakeRecorder(10)}}
+
+	defer forgetReleaseState(types.NamespacedName{Namespace: "default", Name: "webapp"})
+
+	res, err := r.holdBack(held)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(res.RequeueAfter).To(gomega.Equal(reconcileInterval(held)))
+
+	pending := held.Status.GetCondition(appv1.ConditionUpgradePending)
+	g.Expect(pending.Status).To(gomega.Equal(appv1.StatusTrue))
+	g.Expect(pending.Reason).To(gomega.Equal(appv1.ReasonHeldBack))
+	g.Expect(pending.Message).To(gomega.HavePrefix("The release is held at revision 3"))
+	g.Expect(pending.ObservedGeneration).To(gomega.Equal(int64(4)))
+
+	// the held spec is observed
+	g.Expect(held.Status.ObservedGeneration).To(gomega.Equal(int64(4)))
+}
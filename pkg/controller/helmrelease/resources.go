@@ -0,0 +1,91 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helmrelease
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+	"github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/release"
+)
+
+// resourcesCheckInterval is how often the resources of a release are checked
+// while they are rolling out.
+const resourcesCheckInterval = 30 * time.Second
+
+// checkResources sets the readiness of the resources of manifest in the
+// status of hr and rolls it up into the ResourcesReady condition: False if
+// any resource failed or is still in progress.
+func checkResources(hr *appv1.HelmRelease, manager release.Manager, manifest string) {
+	statuses, err := manager.ResourceStatus(context.TODO(), manifest)
+	if err != nil {
+		logFor(hr).Error(err, "Failed to check the resources")
+
+		hr.Status.SetCondition(appv1.HelmAppCondition{
+			Type:    appv1.ConditionResourcesReady,
+			Status:  appv1.StatusUnknown,
+			Reason:  appv1.ReasonReconcileError,
+			Message: err.Error(),
+		})
+
+		return
+	}
+
+	hr.Status.Resources = statuses
+
+	hr.Status.SetCondition(resourcesReadyCondition(statuses))
+}
+
+func resourcesReadyCondition(statuses []appv1.HelmAppResourceStatus) appv1.HelmAppCondition {
+	var failed, inProgress []string
+
+	for _, s := range statuses {
+		switch s.Status {
+		case appv1.ResourceFailed:
+			failed = append(failed, s.Resource.String())
+		case appv1.ResourceInProgress, appv1.ResourceNotFound, appv1.ResourceUnknown:
+			inProgress = append(inProgress, s.Resource.String())
+		}
+	}
+
+	switch {
+	case len(failed) > 0:
+		return appv1.HelmAppCondition{
+			Type:    appv1.ConditionResourcesReady,
+			Status:  appv1.StatusFalse,
+			Reason:  appv1.ReasonResourcesFailed,
+			Message: "Failed resources: " + strings.Join(failed, ", "),
+		}
+	case len(inProgress) > 0:
+		return appv1.HelmAppCondition{
+			Type:    appv1.ConditionResourcesReady,
+			Status:  appv1.StatusFalse,
+			Reason:  appv1.ReasonResourcesInProgress,
+			Message: "Resources not ready yet: " + strings.Join(inProgress, ", "),
+		}
+	}
+
+	return appv1.HelmAppCondition{
+		Type:    appv1.ConditionResourcesReady,
+		Status:  appv1.StatusTrue,
+		Reason:  appv1.ReasonResourcesCurrent,
+		Message: fmt.Sprintf("%d resources are ready", len(statuses)),
+	}
+}
@@ -0,0 +1,55 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helmrelease
+
+import (
+	"testing"
+
+	"github.com/onsi/gomega"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+func TestResourcesReadyCondition(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	deployment := appv1.HelmAppResource{APIVersion: "apps/v1", Kind: "Deployment", Namespace: "default", Name: "web"}
+	service := appv1.HelmAppResource{APIVersion: "v1", Kind: "Service", Namespace: "default", Name: "web"}
+
+	c := resourcesReadyCondition([]appv1.HelmAppResourceStatus{
+		{Resource: deployment, Status: appv1.ResourceCurrent},
+		{Resource: service, Status: appv1.ResourceCurrent},
+	})
+	g.Expect(c.Status).To(gomega.Equal(appv1.StatusTrue))
+	g.Expect(c.Message).To(gomega.Equal("2 resources are ready"))
+
+	c = resourcesReadyCondition([]appv1.HelmAppResourceStatus{
+		{Resource: deployment, Status: appv1.ResourceInProgress},
+		{Resource: service, Status: appv1.ResourceCurrent},
+	})
+	g.Expect(c.Status).To(gomega.Equal(appv1.StatusFalse))
+	g.Expect(c.Reason).To(gomega.Equal(appv1.ReasonResourcesInProgress))
+	g.Expect(c.Message).To(gomega.Equal("Resources not ready yet: apps/v1/Deployment default/web"))
+
+	// a failed resource takes precedence over the ones in progress
+	c = resourcesReadyCondition([]appv1.HelmAppResourceStatus{
+		{Resource: deployment, Status: appv1.ResourceInProgress},
+		{Resource: service, Status: appv1.ResourceFailed},
+	})
+	g.Expect(c.Reason).To(gomega.Equal(appv1.ReasonResourcesFailed))
+	g.Expect(c.Message).To(gomega.Equal("Failed resources: v1/Service default/web"))
+}
@@ -0,0 +1,124 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helmrelease
+
+import (
+	"math/rand"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+// priorityStep separates the reconciles of the priorities when the operator
+// starts. The HelmReleases are all listed at once, so that the high priority
+// ones are queued before the others even with a short step.
+const priorityStep = 5 * time.Second
+
+// jitteredResyncHandler enqueues the HelmReleases like
+// EnqueueRequestForObject, except for their creates, e.g. all the
+// HelmReleases listed when the operator starts. The failing ones are delayed
+// until the retry recorded in their status, so that a restart does not retry
+// them right away. The others are queued by priority, and the healthy ones of
+// the normal and low priorities are delayed by up to Options.ResyncJitter so
+// that their chart downloads and dry-run upgrades are spread over time. The
+// new HelmReleases are reconciled right away. The charts of the HelmReleases
+// that are not delayed by a retry or the jitter are prefetched.
+type jitteredResyncHandler struct {
+	handler.EnqueueRequestForObject
+}
+
+func (h jitteredResyncHandler) Create(e event.CreateEvent, q workqueue.RateLimitingInterface) {
+	hr, ok := e.Object.(*appv1.HelmRelease)
+	if !ok {
+		h.EnqueueRequestForObject.Create(e, q)
+		return
+	}
+
+	request := reconcile.Request{NamespacedName: types.NamespacedName{Namespace: hr.GetNamespace(), Name: hr.GetName()}}
+
+	delay := resyncDelay(hr)
+
+	switch {
+	case pendingRetry(hr) > 0:
+		q.AddAfter(request, pendingRetry(hr))
+	case Options.ResyncJitter > 0 && healthy(hr) && hr.Repo.Priority != appv1.HighPriority:
+		q.AddAfter(request, delay+time.Duration(rand.Int63n(int64(Options.ResyncJitter))))
+	case delay > 0:
+		prefetcher.prefetch(hr)
+		q.AddAfter(request, delay)
+	default:
+		prefetcher.prefetch(hr)
+		q.Add(request)
+	}
+}
+
+// resyncDelay returns the delay before the first reconcile of hr after the
+// operator starts, after the HelmReleases of a higher priority. The new
+// HelmReleases, without a status yet, are not delayed.
+func resyncDelay(hr *appv1.HelmRelease) time.Duration {
+	if len(hr.Status.Conditions) == 0 {
+		return 0
+	}
+
+	switch hr.Repo.Priority {
+	case appv1.HighPriority:
+		return 0
+	case appv1.LowPriority:
+		// after the jittered reconciles of the normal priority
+		return 2*priorityStep + Options.ResyncJitter
+	default:
+		return priorityStep
+	}
+}
+
+// pendingRetry returns the delay until the retry of the failed reconcile of
+// hr recorded in its status, or 0 if the retry is due, if its spec changed
+// since or if a reconcile is requested.
+func pendingRetry(hr *appv1.HelmRelease) time.Duration {
+	if hr.Status.NextRetryTime == nil || reconcileRequested(hr) != "" {
+		return 0
+	}
+
+	ready := hr.Status.GetCondition(appv1.ConditionReady)
+	if ready == nil || ready.ObservedGeneration != hr.GetGeneration() {
+		return 0
+	}
+
+	if delay := time.Until(hr.Status.NextRetryTime.Time); delay > 0 {
+		return delay
+	}
+
+	return 0
+}
+
+// healthy returns true if the status of hr reports a successful reconcile of
+// its current generation.
+func healthy(hr *appv1.HelmRelease) bool {
+	if hr.Status.ObservedGeneration != hr.GetGeneration() || hr.Status.Failures > 0 {
+		return false
+	}
+
+	ready := hr.Status.GetCondition(appv1.ConditionReady)
+
+	return ready != nil && ready.Status == appv1.StatusTrue
+}
@@ -0,0 +1,86 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helmrelease
+
+import (
+	"testing"
+	"time"
+
+	"github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+	"github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/release"
+)
+
+func TestJitteredResyncHandler(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	defer func(jitter time.Duration) { Options.ResyncJitter = jitter }(Options.ResyncJitter)
+
+	Options.ResyncJitter = time.Hour
+
+	newHelmRelease := func(ready appv1.ConditionStatus, priority appv1.PriorityEnum) *appv1.HelmRelease {
+		hr := &appv1.HelmRelease{ObjectMeta: metav1.ObjectMeta{Name: "resync", Namespace: "default", Generation: 2}}
+		hr.Repo.Priority = priority
+		hr.Status.ObservedGeneration = 2
+		hr.Status.SetCondition(appv1.HelmAppCondition{Type: appv1.ConditionReady, Status: ready, ObservedGeneration: 2})
+
+		return hr
+	}
+
+	enqueued := func(hr *appv1.HelmRelease) int {
+		q := workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter())
+		defer q.ShutDown()
+
+		(&jitteredResyncHandler{}).Create(event.CreateEvent{Meta: hr, Object: hr}, q)
+
+		return q.Len()
+	}
+
+	// the healthy HelmReleases are delayed
+	g.Expect(enqueued(newHelmRelease(appv1.StatusTrue, appv1.NormalPriority))).To(gomega.Equal(0))
+
+	// the high priority, new, failing and changed ones are not
+	g.Expect(enqueued(newHelmRelease(appv1.StatusTrue, appv1.HighPriority))).To(gomega.Equal(1))
+	g.Expect(enqueued(&appv1.HelmRelease{ObjectMeta: metav1.ObjectMeta{Name: "resync", Namespace: "default"}})).
+		To(gomega.Equal(1))
+	g.Expect(enqueued(newHelmRelease(appv1.StatusFalse, appv1.HighPriority))).To(gomega.Equal(1))
+
+	changed := newHelmRelease(appv1.StatusTrue, appv1.HighPriority)
+	changed.SetGeneration(3)
+	g.Expect(enqueued(changed)).To(gomega.Equal(1))
+
+	// the other priorities are queued after the high priority ones
+	Options.ResyncJitter = 0
+	g.Expect(enqueued(newHelmRelease(appv1.StatusFalse, appv1.NormalPriority))).To(gomega.Equal(0))
+	g.Expect(resyncDelay(newHelmRelease(appv1.StatusFalse, appv1.NormalPriority))).To(gomega.Equal(priorityStep))
+	g.Expect(resyncDelay(newHelmRelease(appv1.StatusTrue, appv1.LowPriority))).To(gomega.Equal(2 * priorityStep))
+
+	// the failing ones wait for their pending retry
+	failing := newHelmRelease(appv1.StatusFalse, appv1.HighPriority)
+	next := metav1.NewTime(time.Now().Add(time.Hour))
+	failing.Status.NextRetryTime = &next
+	g.Expect(enqueued(failing)).To(gomega.Equal(0))
+	g.Expect(pendingRetry(failing)).To(gomega.BeNumerically(">", 59*time.Minute))
+
+	// unless a reconcile is requested
+	failing.SetAnnotations(map[string]string{release.ReconcileRequestAnnotation: "2020-11-20T10:00:00Z"})
+	g.Expect(enqueued(failing)).To(gomega.Equal(1))
+}
@@ -0,0 +1,398 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helmrelease
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	workv1 "github.com/open-cluster-management/api/work/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+// rolloutCheckInterval is how often the clusters being upgraded are checked
+// while the rollout waits for them to be available
+const rolloutCheckInterval = 30 * time.Second
+
+// rolloutStage is a group of clusters upgraded together: the canary clusters,
+// a wave, or the clusters of no wave.
+type rolloutStage struct {
+	name     string
+	canary   bool
+	wave     *appv1.RolloutWave
+	clusters []string
+}
+
+// hasRollout returns true if hr has a rollout strategy.
+func hasRollout(hr *appv1.HelmRelease) bool {
+	return hr.Repo.Rollout != nil && (hr.Repo.Rollout.Canary != nil || len(hr.Repo.Rollout.Waves) != 0)
+}
+
+// rolloutStages splits the clusters of hr into the stages rolled out in
+// order: the canary clusters, the clusters of each wave, then the others. The
+// clusters are a single stage without a rollout strategy.
+func (r *ReconcileHelmRelease) rolloutStages(hr *appv1.HelmRelease, clusters []string) ([]rolloutStage, error) {
+	if !hasRollout(hr) {
+		return []rolloutStage{{clusters: clusters}}, nil
+	}
+
+	clusterLabels, err := r.managedClusterLabels()
+	if err != nil {
+		return nil, err
+	}
+
+	assigned := make(map[string]bool, len(clusters))
+
+	// take assigns the clusters not in a previous stage matching selector to
+	// the stage
+	take := func(stage rolloutStage, selector *metav1.LabelSelector) (rolloutStage, error) {
+		s, err := metav1.LabelSelectorAsSelector(selector)
+		if err != nil {
+			return stage, fmt.Errorf("invalid cluster selector of the %s wave: %w", stage.name, err)
+		}
+
+		for _, cluster := range clusters {
+			if !assigned[cluster] && s.Matches(clusterLabels[cluster]) {
+				assigned[cluster] = true
+				stage.clusters = append(stage.clusters, cluster)
+			}
+		}
+
+		return stage, nil
+	}
+
+	var stages []rolloutStage
+
+	if canary := hr.Repo.Rollout.Canary; canary != nil {
+		stage, err := take(rolloutStage{name: appv1.CanaryWave, canary: true}, &canary.ClusterSelector)
+		if err != nil {
+			return nil, err
+		}
+
+		stages = append(stages, stage)
+	}
+
+	for i := range hr.Repo.Rollout.Waves {
+		wave := &hr.Repo.Rollout.Waves[i]
+
+		stage, err := take(rolloutStage{name: wave.Name, wave: wave}, &wave.ClusterSelector)
+		if err != nil {
+			return nil, err
+		}
+
+		stages = append(stages, stage)
+	}
+
+	others := rolloutStage{}
+
+	for _, cluster := range clusters {
+		if !assigned[cluster] {
+			others.clusters = append(others.clusters, cluster)
+		}
+	}
+
+	return append(stages, others), nil
+}
+
+// rolloutRevision identifies the renders of the release for the clusters, a
+// new revision starts a new rollout.
+func rolloutRevision(desired map[string]clusterRender) string {
+	seen := make(map[string]bool)
+	digests := make([]string, 0, len(desired))
+
+	for _, rendered := range desired {
+		if !seen[rendered.digest] {
+			seen[rendered.digest] = true
+			digests = append(digests, rendered.digest)
+		}
+	}
+
+	sort.Strings(digests)
+
+	sum := sha256.Sum256([]byte(strings.Join(digests, ",")))
+
+	return hex.EncodeToString(sum[:8])
+}
+
+// startRollout returns the rollout of revision in the status of hr, a new one
+// if the revision changed. It is nil without a rollout strategy.
+func startRollout(hr *appv1.HelmRelease, revision string) *appv1.HelmAppRolloutStatus {
+	if !hasRollout(hr) {
+		hr.Status.Rollout = nil
+		return nil
+	}
+
+	if hr.Status.Rollout == nil || hr.Status.Rollout.Revision != revision {
+		logFor(hr).Info("Starting the rollout", "revision", revision)
+
+		hr.Status.Rollout = &appv1.HelmAppRolloutStatus{
+			Revision:  revision,
+			Phase:     appv1.RollingOutRolloutPhase,
+			StartTime: metav1.Now(),
+		}
+	}
+
+	return hr.Status.Rollout
+}
+
+// stageApproved returns true if the stage can be upgraded: it does not require
+// an approval, it is empty, or the ApproveWaveAnnotation of hr approves it for the
+// revision being rolled out. The approvals are recorded in the rollout with
+// the approver stamped by the approval webhook.
+func stageApproved(hr *appv1.HelmRelease, rollout *appv1.HelmAppRolloutStatus, stage rolloutStage) bool {
+	if rollout == nil || stage.wave == nil || !stage.wave.RequireApproval || len(stage.clusters) == 0 ||
+		rollout.Phase == appv1.CompletedRolloutPhase {
+		return true
+	}
+
+	for _, approval := range rollout.Approvals {
+		if approval.Wave == stage.name {
+			return true
+		}
+	}
+
+	if hr.GetAnnotations()[appv1.ApproveWaveAnnotation] != rollout.Revision+"/"+stage.name {
+		rollout.Phase = appv1.PendingApprovalRolloutPhase
+		rollout.Wave = stage.name
+		rollout.Message = fmt.Sprintf("Waiting for the approval of the %s wave, annotate the HelmRelease with %s=%s/%s",
+			stage.name, appv1.ApproveWaveAnnotation, rollout.Revision, stage.name)
+
+		return false
+	}
+
+	approver := hr.GetAnnotations()[appv1.WaveApprovedByAnnotation]
+
+	logFor(hr).Info("Wave approved", "wave", stage.name, "approver", approver)
+
+	rollout.Approvals = append(rollout.Approvals, appv1.HelmAppWaveApproval{
+		Wave:         stage.name,
+		ApprovedBy:   approver,
+		ApprovedTime: metav1.Now(),
+	})
+
+	return true
+}
+
+// upgradeBudget returns how many more clusters of the stage can be upgraded
+// now, from the maxUnavailable of its wave and the clusters already upgraded
+// but not available.
+func upgradeBudget(stage rolloutStage, statuses []appv1.HelmAppClusterStatus, desired map[string]clusterRender) int {
+	if stage.wave == nil || stage.wave.MaxUnavailable == nil {
+		return len(stage.clusters)
+	}
+
+	budget, err := intstr.GetValueFromIntOrPercent(stage.wave.MaxUnavailable, len(stage.clusters), false)
+	if err != nil || budget < 1 {
+		// the rollout would never progress
+		budget = 1
+	}
+
+	for _, s := range statuses {
+		if s.Digest == desired[s.Cluster].digest && !s.Available {
+			budget--
+		}
+	}
+
+	return budget
+}
+
+// verifyStage advances the rollout from the state of the clusters of the
+// stage. It returns true once the stage is complete and the next one can be
+// upgraded, or else how long to wait before verifying it again.
+func verifyStage(hr *appv1.HelmRelease, rollout *appv1.HelmAppRolloutStatus, stage rolloutStage,
+	statuses []appv1.HelmAppClusterStatus, desired map[string]clusterRender) (time.Duration, bool) {
+	switch rollout.Phase {
+	case appv1.HaltedRolloutPhase:
+		return 0, false
+	case appv1.CompletedRolloutPhase:
+		return 0, true
+	}
+
+	if stage.name == "" || contains(rollout.CompletedWaves, stage.name) {
+		return 0, true
+	}
+
+	rollout.Wave = stage.name
+
+	var delay time.Duration
+	if stage.canary {
+		delay = verifyCanary(hr, rollout, statuses, desired)
+	} else {
+		delay = verifyWave(hr, rollout, stage, statuses, desired)
+	}
+
+	if delay > 0 || rollout.Phase == appv1.HaltedRolloutPhase {
+		return delay, false
+	}
+
+	rollout.CompletedWaves = append(rollout.CompletedWaves, stage.name)
+
+	return 0, true
+}
+
+// completeRollout records the rollout as completed once every stage is.
+func completeRollout(hr *appv1.HelmRelease, rollout *appv1.HelmAppRolloutStatus, clusters int) {
+	if rollout.Phase == appv1.CompletedRolloutPhase {
+		return
+	}
+
+	logFor(hr).Info("Completed the rollout", "revision", rollout.Revision)
+
+	rollout.Phase = appv1.CompletedRolloutPhase
+	rollout.Wave = ""
+	rollout.Message = fmt.Sprintf("%d clusters upgraded", clusters)
+}
+
+// verifyWave waits for all the clusters of the wave to be available with
+// their desired release.
+func verifyWave(hr *appv1.HelmRelease, rollout *appv1.HelmAppRolloutStatus, stage rolloutStage,
+	statuses []appv1.HelmAppClusterStatus, desired map[string]clusterRender) time.Duration {
+	upgraded := 0
+
+	for _, s := range statuses {
+		if s.Available && s.Digest == desired[s.Cluster].digest {
+			upgraded++
+		}
+	}
+
+	if upgraded == len(statuses) {
+		logFor(hr).Info("Wave upgraded", "wave", stage.name)
+		return 0
+	}
+
+	rollout.Phase = appv1.RollingOutRolloutPhase
+	rollout.Message = fmt.Sprintf("%d/%d clusters of the %s wave upgraded and available", upgraded, len(statuses), stage.name)
+
+	return rolloutCheckInterval
+}
+
+// verifyCanary advances the rollout from the state of the canary clusters: it
+// halts once one of them fails with its desired release, and is done once
+// all of them have been available with it for the soak duration. It returns
+// how long to wait before verifying them again, 0 once done.
+func verifyCanary(hr *appv1.HelmRelease, rollout *appv1.HelmAppRolloutStatus, statuses []appv1.HelmAppClusterStatus,
+	desired map[string]clusterRender) time.Duration {
+	if len(statuses) == 0 {
+		return 0
+	}
+
+	for _, s := range statuses {
+		// the conditions set before the rollout are about the previous release
+		if failure := canaryFailure(s, rollout.StartTime); failure != "" && s.Digest == desired[s.Cluster].digest {
+			logFor(hr).Info("Halting the rollout after the failure of a canary cluster", "cluster", s.Cluster)
+
+			rollout.Phase = appv1.HaltedRolloutPhase
+			rollout.CanaryAvailableTime = nil
+			rollout.Message = fmt.Sprintf("canary cluster %s failed: %s", s.Cluster, failure)
+
+			return 0
+		}
+	}
+
+	for _, s := range statuses {
+		if !s.Available || s.Digest != desired[s.Cluster].digest {
+			rollout.Phase = appv1.CanaryRolloutPhase
+			rollout.CanaryAvailableTime = nil
+			rollout.Message = fmt.Sprintf("Waiting for canary cluster %s to be available", s.Cluster)
+
+			return rolloutCheckInterval
+		}
+	}
+
+	soak := appv1.DefaultCanarySoak
+	if d := hr.Repo.Rollout.Canary.SoakDuration; d != nil {
+		soak = d.Duration
+	}
+
+	now := metav1.Now()
+	if rollout.CanaryAvailableTime == nil {
+		rollout.CanaryAvailableTime = &now
+	}
+
+	if remaining := rollout.CanaryAvailableTime.Add(soak).Sub(now.Time); remaining > 0 {
+		rollout.Phase = appv1.SoakingRolloutPhase
+		rollout.Message = fmt.Sprintf("%d canary clusters available, soaking until %s",
+			len(statuses), rollout.CanaryAvailableTime.Add(soak).UTC().Format(time.RFC3339))
+
+		return remaining
+	}
+
+	logFor(hr).Info("Canary clusters verified, upgrading the other clusters")
+
+	return 0
+}
+
+// canaryFailure returns the message of the Applied or Available condition of
+// the ManifestWork of a canary cluster set to False since the rollout
+// started, if any.
+func canaryFailure(s appv1.HelmAppClusterStatus, since metav1.Time) string {
+	for _, c := range s.Conditions {
+		t := string(c.Type)
+		if (t == workv1.WorkApplied || t == workv1.WorkAvailable) && c.Status == appv1.StatusFalse &&
+			!c.LastTransitionTime.Before(&since) {
+			return c.Message
+		}
+	}
+
+	return ""
+}
+
+// setRolloutConditions reports the rollout in progress with UpgradePending and
+// the halted rollout with ReleaseFailed.
+func setRolloutConditions(hr *appv1.HelmRelease, rollout *appv1.HelmAppRolloutStatus) {
+	if rollout == nil || rollout.Phase == appv1.CompletedRolloutPhase {
+		hr.Status.RemoveCondition(appv1.ConditionUpgradePending)
+		return
+	}
+
+	if rollout.Phase == appv1.PendingApprovalRolloutPhase {
+		hr.Status.SetCondition(appv1.HelmAppCondition{
+			Type:    appv1.ConditionUpgradePending,
+			Status:  appv1.StatusTrue,
+			Reason:  appv1.ReasonApprovalPending,
+			Message: rollout.Message,
+		})
+
+		return
+	}
+
+	if rollout.Phase == appv1.HaltedRolloutPhase {
+		hr.Status.RemoveCondition(appv1.ConditionUpgradePending)
+		hr.Status.SetCondition(appv1.HelmAppCondition{
+			Type:    appv1.ConditionReleaseFailed,
+			Status:  appv1.StatusTrue,
+			Reason:  appv1.ReasonCanaryFailed,
+			Message: rollout.Message,
+		})
+
+		return
+	}
+
+	hr.Status.SetCondition(appv1.HelmAppCondition{
+		Type:    appv1.ConditionUpgradePending,
+		Status:  appv1.StatusTrue,
+		Reason:  appv1.ReasonRolloutInProgress,
+		Message: rollout.Message,
+	})
+}
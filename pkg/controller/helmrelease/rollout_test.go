@@ -0,0 +1,241 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helmrelease
+
+import (
+	"testing"
+	"time"
+
+	"github.com/onsi/gomega"
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	workv1 "github.com/open-cluster-management/api/work/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+func newCanaryHelmRelease() *appv1.HelmRelease {
+	hr := &appv1.HelmRelease{ObjectMeta: metav1.ObjectMeta{Name: "webapp", Namespace: "default"}}
+	hr.Repo.Rollout = &appv1.RolloutStrategy{Canary: &appv1.CanaryRollout{
+		ClusterSelector: metav1.LabelSelector{MatchLabels: map[string]string{"canary": "true"}},
+		SoakDuration:    &metav1.Duration{Duration: time.Hour},
+	}}
+
+	return hr
+}
+
+func TestRolloutStages(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	s := runtime.NewScheme()
+	g.Expect(clusterv1.Install(s)).To(gomega.Succeed())
+
+	r := &ReconcileHelmRelease{readerManager{reader: fake.NewFakeClientWithScheme(s,
+		&clusterv1.ManagedCluster{ObjectMeta: metav1.ObjectMeta{Name: "cluster1"}},
+		&clusterv1.ManagedCluster{ObjectMeta: metav1.ObjectMeta{Name: "cluster2", Labels: map[string]string{"canary": "true"}}},
+	)}}
+
+	clusters := []string{"cluster1", "cluster2"}
+
+	stageClusters := func(hr *appv1.HelmRelease) [][]string {
+		stages, err := r.rolloutStages(hr, clusters)
+		g.Expect(err).NotTo(gomega.HaveOccurred())
+
+		var names [][]string
+		for _, stage := range stages {
+			names = append(names, stage.clusters)
+		}
+
+		return names
+	}
+
+	// a single stage without a rollout strategy
+	g.Expect(stageClusters(&appv1.HelmRelease{})).To(gomega.Equal([][]string{clusters}))
+
+	hr := newCanaryHelmRelease()
+	g.Expect(stageClusters(hr)).To(gomega.Equal([][]string{{"cluster2"}, {"cluster1"}}))
+
+	// the canary clusters are not in the waves, the clusters of no wave come last
+	hr.Repo.Rollout.Waves = []appv1.RolloutWave{{Name: "all"}}
+	g.Expect(stageClusters(hr)).To(gomega.Equal([][]string{{"cluster2"}, {"cluster1"}, nil}))
+}
+
+func TestStartRollout(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	hr := newCanaryHelmRelease()
+
+	rollout := startRollout(hr, "rev1")
+	g.Expect(rollout.Phase).To(gomega.Equal(appv1.RollingOutRolloutPhase))
+	g.Expect(hr.Status.Rollout).To(gomega.Equal(rollout))
+
+	// the rollout of the same revision goes on
+	rollout.Phase = appv1.SoakingRolloutPhase
+	g.Expect(startRollout(hr, "rev1").Phase).To(gomega.Equal(appv1.SoakingRolloutPhase))
+	g.Expect(startRollout(hr, "rev2").Phase).To(gomega.Equal(appv1.RollingOutRolloutPhase))
+
+	hr.Repo.Rollout = nil
+	g.Expect(startRollout(hr, "rev2")).To(gomega.BeNil())
+	g.Expect(hr.Status.Rollout).To(gomega.BeNil())
+
+	// the revision changes with the renders
+	g.Expect(rolloutRevision(map[string]clusterRender{"cluster1": {digest: "a"}, "cluster2": {digest: "a"}})).
+		To(gomega.Equal(rolloutRevision(map[string]clusterRender{"cluster1": {digest: "a"}})))
+	g.Expect(rolloutRevision(map[string]clusterRender{"cluster1": {digest: "a"}})).
+		NotTo(gomega.Equal(rolloutRevision(map[string]clusterRender{"cluster1": {digest: "b"}})))
+}
+
+func TestVerifyCanary(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	hr := newCanaryHelmRelease()
+	desired := map[string]clusterRender{"cluster1": {digest: "new"}}
+	rollout := startRollout(hr, "rev1")
+
+	// waiting for the canary cluster to run the desired release
+	statuses := []appv1.HelmAppClusterStatus{{Cluster: "cluster1", Available: true, Digest: "old"}}
+	g.Expect(verifyCanary(hr, rollout, statuses, desired)).To(gomega.Equal(rolloutCheckInterval))
+	g.Expect(rollout.Phase).To(gomega.Equal(appv1.CanaryRolloutPhase))
+
+	// then soaking it
+	statuses[0].Digest = "new"
+	g.Expect(verifyCanary(hr, rollout, statuses, desired)).To(gomega.BeNumerically("~", time.Hour, time.Minute))
+	g.Expect(rollout.Phase).To(gomega.Equal(appv1.SoakingRolloutPhase))
+
+	setRolloutConditions(hr, rollout)
+	g.Expect(hr.Status.GetCondition(appv1.ConditionUpgradePending).Reason).To(gomega.Equal(appv1.ReasonRolloutInProgress))
+
+	// a failure since the rollout started halts it
+	statuses[0].Available = false
+	statuses[0].Conditions = []appv1.HelmAppCondition{{
+		Type:               appv1.HelmAppConditionType(workv1.WorkAvailable),
+		Status:             appv1.StatusFalse,
+		Message:            "crash loop",
+		LastTransitionTime: metav1.Now(),
+	}}
+	g.Expect(verifyCanary(hr, rollout, statuses, desired)).To(gomega.BeZero())
+	g.Expect(rollout.Phase).To(gomega.Equal(appv1.HaltedRolloutPhase))
+	g.Expect(rollout.Message).To(gomega.Equal("canary cluster cluster1 failed: crash loop"))
+
+	setRolloutConditions(hr, rollout)
+	g.Expect(hr.Status.GetCondition(appv1.ConditionUpgradePending)).To(gomega.BeNil())
+	g.Expect(hr.Status.GetCondition(appv1.ConditionReleaseFailed).Reason).To(gomega.Equal(appv1.ReasonCanaryFailed))
+
+	// the soaked canary clusters complete their stage
+	rollout = startRollout(hr, "rev2")
+	soaked := metav1.NewTime(time.Now().Add(-2 * time.Hour))
+	rollout.CanaryAvailableTime = &soaked
+	statuses[0] = appv1.HelmAppClusterStatus{Cluster: "cluster1", Available: true, Digest: "new"}
+
+	stage := rolloutStage{name: appv1.CanaryWave, canary: true, clusters: []string{"cluster1"}}
+	delay, done := verifyStage(hr, rollout, stage, statuses, desired)
+	g.Expect(delay).To(gomega.BeZero())
+	g.Expect(done).To(gomega.BeTrue())
+	g.Expect(rollout.CompletedWaves).To(gomega.Equal([]string{appv1.CanaryWave}))
+}
+
+func TestVerifyWave(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	hr := &appv1.HelmRelease{}
+	hr.Repo.Rollout = &appv1.RolloutStrategy{Waves: []appv1.RolloutWave{{Name: "prod"}}}
+
+	desired := map[string]clusterRender{"cluster1": {digest: "new"}, "cluster2": {digest: "new"}}
+	stage := rolloutStage{name: "prod", wave: &hr.Repo.Rollout.Waves[0], clusters: []string{"cluster1", "cluster2"}}
+	statuses := []appv1.HelmAppClusterStatus{
+		{Cluster: "cluster1", Available: true, Digest: "new"},
+		{Cluster: "cluster2", Digest: "new"},
+	}
+
+	rollout := startRollout(hr, "rev1")
+
+	delay, done := verifyStage(hr, rollout, stage, statuses, desired)
+	g.Expect(delay).To(gomega.Equal(rolloutCheckInterval))
+	g.Expect(done).To(gomega.BeFalse())
+	g.Expect(rollout.Wave).To(gomega.Equal("prod"))
+	g.Expect(rollout.Message).To(gomega.Equal("1/2 clusters of the prod wave upgraded and available"))
+
+	statuses[1].Available = true
+	_, done = verifyStage(hr, rollout, stage, statuses, desired)
+	g.Expect(done).To(gomega.BeTrue())
+
+	completeRollout(hr, rollout, 2)
+	g.Expect(rollout.Phase).To(gomega.Equal(appv1.CompletedRolloutPhase))
+	g.Expect(rollout.Wave).To(gomega.BeEmpty())
+}
+
+func TestStageApproved(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	hr := &appv1.HelmRelease{}
+	hr.Repo.Rollout = &appv1.RolloutStrategy{Waves: []appv1.RolloutWave{{Name: "prod", RequireApproval: true}}}
+
+	stage := rolloutStage{name: "prod", wave: &hr.Repo.Rollout.Waves[0], clusters: []string{"cluster1"}}
+	rollout := startRollout(hr, "rev1")
+
+	g.Expect(stageApproved(hr, rollout, stage)).To(gomega.BeFalse())
+	g.Expect(rollout.Phase).To(gomega.Equal(appv1.PendingApprovalRolloutPhase))
+
+	setRolloutConditions(hr, rollout)
+	g.Expect(hr.Status.GetCondition(appv1.ConditionUpgradePending).Reason).To(gomega.Equal(appv1.ReasonApprovalPending))
+
+	// the approval of another revision does not carry over
+	hr.SetAnnotations(map[string]string{appv1.ApproveWaveAnnotation: "rev0/prod"})
+	g.Expect(stageApproved(hr, rollout, stage)).To(gomega.BeFalse())
+
+	hr.SetAnnotations(map[string]string{
+		appv1.ApproveWaveAnnotation:    "rev1/prod",
+		appv1.WaveApprovedByAnnotation: "alice",
+	})
+	g.Expect(stageApproved(hr, rollout, stage)).To(gomega.BeTrue())
+	g.Expect(rollout.Approvals).To(gomega.HaveLen(1))
+	g.Expect(rollout.Approvals[0].ApprovedBy).To(gomega.Equal("alice"))
+
+	// and is recorded once
+	g.Expect(stageApproved(hr, rollout, stage)).To(gomega.BeTrue())
+	g.Expect(rollout.Approvals).To(gomega.HaveLen(1))
+}
+
+func TestUpgradeBudget(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	maxUnavailable := intstr.FromString("50%")
+	stage := rolloutStage{
+		name:     "prod",
+		wave:     &appv1.RolloutWave{Name: "prod", MaxUnavailable: &maxUnavailable},
+		clusters: []string{"cluster1", "cluster2", "cluster3", "cluster4"},
+	}
+	desired := map[string]clusterRender{"cluster1": {digest: "new"}, "cluster2": {digest: "new"}}
+
+	g.Expect(upgradeBudget(stage, nil, desired)).To(gomega.Equal(2))
+
+	// the clusters upgraded and not available yet count against it
+	g.Expect(upgradeBudget(stage, []appv1.HelmAppClusterStatus{
+		{Cluster: "cluster1", Digest: "new"},
+		{Cluster: "cluster2", Digest: "old"},
+	}, desired)).To(gomega.Equal(1))
+
+	// at least one cluster is upgraded at once
+	maxUnavailable = intstr.FromString("10%")
+	g.Expect(upgradeBudget(stage, nil, desired)).To(gomega.Equal(1))
+
+	stage.wave.MaxUnavailable = nil
+	g.Expect(upgradeBudget(stage, nil, desired)).To(gomega.Equal(4))
+}
@@ -0,0 +1,147 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helmrelease
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+	"github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/release"
+)
+
+// namespaceScopeAllows returns true if the HelmReleases of namespace may
+// deploy to target in namespace-scoped mode: their own namespace, or one
+// allowed for their namespace by the operator.
+func namespaceScopeAllows(namespace, target string) bool {
+	if target == namespace {
+		return true
+	}
+
+	for _, allowed := range Options.AllowedTargetNamespaces[namespace] {
+		if allowed == target {
+			return true
+		}
+	}
+
+	return false
+}
+
+// checkNamespaceScope returns an error if hr needs more than the namespaced
+// permissions of the operator in namespace-scoped mode. The resources of the
+// chart are checked once it is rendered, by checkRenderedScope.
+func checkNamespaceScope(hr *appv1.HelmRelease) error {
+	// the permissions on a remote cluster are the ones of its kubeconfig
+	if !Options.NamespaceScoped || hr.Repo.KubeConfig != nil {
+		return nil
+	}
+
+	if hubMode(hr) {
+		return fmt.Errorf("clusterSelector and placementRef are not supported in namespace-scoped mode, the ManagedClusters are cluster-scoped")
+	}
+
+	if hr.Repo.CreateNamespace {
+		return fmt.Errorf("createNamespace is not supported in namespace-scoped mode, the namespaces are cluster-scoped")
+	}
+
+	if target := targetNamespace(hr); !namespaceScopeAllows(hr.GetNamespace(), target) {
+		return fmt.Errorf("target namespace %s is not allowed for the HelmReleases of namespace %s in namespace-scoped mode",
+			target, hr.GetNamespace())
+	}
+
+	if storage := hr.Repo.StorageNamespace; storage != "" && !namespaceScopeAllows(hr.GetNamespace(), storage) {
+		return fmt.Errorf("storage namespace %s is not allowed for the HelmReleases of namespace %s in namespace-scoped mode",
+			storage, hr.GetNamespace())
+	}
+
+	return nil
+}
+
+// checkRenderedScope renders the release of hr and returns the resources it
+// is not allowed to deploy in namespace-scoped mode: the cluster-scoped ones,
+// including the CRDs of the chart, and the ones of the namespaces not allowed
+// for hr. The hooks are not rendered, they fail on the missing permissions.
+func checkRenderedScope(hr *appv1.HelmRelease, manager release.Manager) ([]string, error) {
+	if !Options.NamespaceScoped || hr.Repo.KubeConfig != nil {
+		return nil, nil
+	}
+
+	objects, err := manager.Render(context.TODO(), release.RenderOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	var forbidden []string
+
+	for _, u := range objects {
+		switch ns := u.GetNamespace(); {
+		case ns == "":
+			forbidden = append(forbidden, fmt.Sprintf("%s %s (cluster-scoped)", u.GetKind(), u.GetName()))
+		case !namespaceScopeAllows(hr.GetNamespace(), ns):
+			forbidden = append(forbidden, fmt.Sprintf("%s %s/%s", u.GetKind(), ns, u.GetName()))
+		}
+	}
+
+	return forbidden, nil
+}
+
+// clusterScopedAllowed returns true if the chart of hr may deploy
+// cluster-scoped resources.
+func clusterScopedAllowed(hr *appv1.HelmRelease) bool {
+	if hr.Repo.AllowClusterScopedResources != nil {
+		return *hr.Repo.AllowClusterScopedResources
+	}
+
+	return Options.AllowClusterScopedResources
+}
+
+// checkClusterScoped renders the release of hr and returns its cluster-scoped
+// resources, including the CRDs of the chart, if hr may not deploy any. The
+// hooks are not rendered and so not checked.
+func checkClusterScoped(hr *appv1.HelmRelease, manager release.Manager) ([]string, error) {
+	if clusterScopedAllowed(hr) {
+		return nil, nil
+	}
+
+	objects, err := manager.Render(context.TODO(), release.RenderOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	var denied []string
+
+	for _, u := range objects {
+		if u.GetNamespace() == "" {
+			denied = append(denied, u.GetKind()+" "+u.GetName())
+		}
+	}
+
+	return denied, nil
+}
+
+// clusterScopedMessage is the condition message of the cluster-scoped
+// resources denied to hr.
+func clusterScopedMessage(denied []string) string {
+	return "the chart deploys cluster-scoped resources, allowClusterScopedResources is false: " + strings.Join(denied, ", ")
+}
+
+// namespaceScopeMessage is the condition message of the resources forbidden
+// in namespace-scoped mode.
+func namespaceScopeMessage(forbidden []string) string {
+	return "the chart deploys resources not allowed in namespace-scoped mode: " + strings.Join(forbidden, ", ")
+}
@@ -0,0 +1,153 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helmrelease
+
+import (
+	"context"
+	"testing"
+
+	"github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+	"github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/release"
+)
+
+// renderManager is a release manager rendering objects.
+type renderManager struct {
+	release.Manager
+
+	objects []*unstructured.Unstructured
+}
+
+func (m *renderManager) Render(ctx context.Context, opts release.RenderOptions) ([]*unstructured.Unstructured, error) {
+	return m.objects, nil
+}
+
+func newScopedObject(kind, namespace, name string) *unstructured.Unstructured {
+	u := &unstructured.Unstructured{}
+	u.SetKind(kind)
+	u.SetNamespace(namespace)
+	u.SetName(name)
+
+	return u
+}
+
+func TestCheckNamespaceScope(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	defer func(scoped bool, allowed map[string][]string) {
+		Options.NamespaceScoped, Options.AllowedTargetNamespaces = scoped, allowed
+	}(Options.NamespaceScoped, Options.AllowedTargetNamespaces)
+
+	newHelmRelease := func() *appv1.HelmRelease {
+		return &appv1.HelmRelease{ObjectMeta: metav1.ObjectMeta{Name: "webapp", Namespace: "team-a"}}
+	}
+
+	hr := newHelmRelease()
+	hr.Repo.TargetNamespace = "team-b"
+	g.Expect(checkNamespaceScope(hr)).To(gomega.Succeed())
+
+	Options.NamespaceScoped = true
+	Options.AllowedTargetNamespaces = map[string][]string{"team-a": {"team-a-web"}}
+
+	g.Expect(checkNamespaceScope(hr)).To(gomega.MatchError(gomega.ContainSubstring("target namespace team-b is not allowed")))
+
+	// a remote cluster is not restricted
+	hr.Repo.KubeConfig = &appv1.KubeConfig{}
+	g.Expect(checkNamespaceScope(hr)).To(gomega.Succeed())
+
+	for _, allowed := range []string{"", "team-a", "team-a-web"} {
+		hr := newHelmRelease()
+		hr.Repo.TargetNamespace = allowed
+		g.Expect(checkNamespaceScope(hr)).To(gomega.Succeed(), "target namespace %q", allowed)
+	}
+
+	for _, mutate := range []func(hr *appv1.HelmRelease){
+		func(hr *appv1.HelmRelease) { hr.Repo.ClusterSelector = &metav1.LabelSelector{} },
+		func(hr *appv1.HelmRelease) { hr.Repo.PlacementRef = &corev1.LocalObjectReference{Name: "prod"} },
+		func(hr *appv1.HelmRelease) { hr.Repo.CreateNamespace = true },
+		func(hr *appv1.HelmRelease) { hr.Repo.StorageNamespace = "releases" },
+	} {
+		hr := newHelmRelease()
+		mutate(hr)
+		g.Expect(checkNamespaceScope(hr)).NotTo(gomega.Succeed())
+	}
+}
+
+func TestCheckRenderedScope(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	defer func(scoped bool, allowed map[string][]string) {
+		Options.NamespaceScoped, Options.AllowedTargetNamespaces = scoped, allowed
+	}(Options.NamespaceScoped, Options.AllowedTargetNamespaces)
+
+	hr := &appv1.HelmRelease{ObjectMeta: metav1.ObjectMeta{Name: "webapp", Namespace: "team-a"}}
+	m := &renderManager{objects: []*unstructured.Unstructured{
+		newScopedObject("ConfigMap", "team-a", "config"),
+		newScopedObject("ConfigMap", "team-a-web", "config"),
+		newScopedObject("ClusterRole", "", "webapp"),
+		newScopedObject("Secret", "kube-system", "token"),
+	}}
+
+	forbidden, err := checkRenderedScope(hr, m)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(forbidden).To(gomega.BeEmpty())
+
+	Options.NamespaceScoped = true
+	Options.AllowedTargetNamespaces = map[string][]string{"team-a": {"team-a-web"}}
+
+	forbidden, err = checkRenderedScope(hr, m)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(forbidden).To(gomega.Equal([]string{"ClusterRole webapp (cluster-scoped)", "Secret kube-system/token"}))
+	g.Expect(namespaceScopeMessage(forbidden)).To(gomega.HaveSuffix(": ClusterRole webapp (cluster-scoped), Secret kube-system/token"))
+}
+
+func TestCheckClusterScoped(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	defer func(allowed bool) { Options.AllowClusterScopedResources = allowed }(Options.AllowClusterScopedResources)
+
+	hr := &appv1.HelmRelease{ObjectMeta: metav1.ObjectMeta{Name: "webapp", Namespace: "team-a"}}
+	m := &renderManager{objects: []*unstructured.Unstructured{
+		newScopedObject("ConfigMap", "team-a", "config"),
+		newScopedObject("ClusterRole", "", "webapp"),
+		newScopedObject("CustomResourceDefinition", "", "webapps.example.com"),
+	}}
+
+	denied, err := checkClusterScoped(hr, m)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(denied).To(gomega.BeEmpty())
+
+	// the operator default applies unless the HelmRelease sets it
+	Options.AllowClusterScopedResources = false
+
+	denied, err = checkClusterScoped(hr, m)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(denied).To(gomega.Equal([]string{"ClusterRole webapp", "CustomResourceDefinition webapps.example.com"}))
+	g.Expect(clusterScopedMessage(denied)).To(gomega.HaveSuffix(": ClusterRole webapp, CustomResourceDefinition webapps.example.com"))
+
+	allowed := true
+	hr.Repo.AllowClusterScopedResources = &allowed
+	g.Expect(checkClusterScoped(hr, m)).To(gomega.BeEmpty())
+
+	Options.AllowClusterScopedResources = true
+	allowed = false
+	g.Expect(checkClusterScoped(hr, m)).To(gomega.HaveLen(2))
+}
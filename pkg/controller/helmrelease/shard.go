@@ -0,0 +1,63 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helmrelease
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+// inShard returns true if the HelmRelease meta belongs to the shard of this
+// operator instance, i.e. its labels match the shard selector, if any.
+func inShard(meta metav1.Object) bool {
+	if meta == nil {
+		return false
+	}
+
+	return Options.ShardSelector == nil || Options.ShardSelector.Matches(labels.Set(meta.GetLabels()))
+}
+
+// shardPredicate only passes the events of the HelmReleases of the shard,
+// and their updates only if their generation or their request annotations
+// changed, so that status updates do not trigger reconciles.
+type shardPredicate struct {
+	predicate.GenerationChangedPredicate
+}
+
+func (p shardPredicate) Create(e event.CreateEvent) bool {
+	return inShard(e.Meta)
+}
+
+func (p shardPredicate) Delete(e event.DeleteEvent) bool {
+	return inShard(e.Meta)
+}
+
+func (p shardPredicate) Generic(e event.GenericEvent) bool {
+	return inShard(e.Meta)
+}
+
+func (p shardPredicate) Update(e event.UpdateEvent) bool {
+	if !inShard(e.MetaNew) {
+		return false
+	}
+
+	// a HelmRelease relabeled into the shard is reconciled right away
+	return !inShard(e.MetaOld) || p.GenerationChangedPredicate.Update(e) ||
+		requestAnnotationChanged(e.MetaOld, e.MetaNew)
+}
@@ -0,0 +1,71 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helmrelease
+
+import (
+	"testing"
+
+	"github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+	"github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/release"
+)
+
+func TestShardPredicate(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	defer func(selector labels.Selector) { Options.ShardSelector = selector }(Options.ShardSelector)
+
+	newHelmRelease := func(shard string, generation int64) *appv1.HelmRelease {
+		hr := &appv1.HelmRelease{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"shard": shard}}}
+		hr.SetGeneration(generation)
+
+		return hr
+	}
+
+	p := shardPredicate{}
+
+	// without a selector, every HelmRelease is in the shard
+	Options.ShardSelector = nil
+	g.Expect(p.Create(event.CreateEvent{Meta: newHelmRelease("b", 1)})).To(gomega.BeTrue())
+
+	Options.ShardSelector = labels.SelectorFromSet(labels.Set{"shard": "a"})
+
+	g.Expect(p.Create(event.CreateEvent{Meta: newHelmRelease("a", 1)})).To(gomega.BeTrue())
+	g.Expect(p.Create(event.CreateEvent{Meta: newHelmRelease("b", 1)})).To(gomega.BeFalse())
+	g.Expect(p.Delete(event.DeleteEvent{Meta: newHelmRelease("b", 1)})).To(gomega.BeFalse())
+
+	update := func(old, new *appv1.HelmRelease) bool {
+		return p.Update(event.UpdateEvent{MetaOld: old, ObjectOld: old, MetaNew: new, ObjectNew: new})
+	}
+
+	// status updates are filtered out
+	g.Expect(update(newHelmRelease("a", 1), newHelmRelease("a", 1))).To(gomega.BeFalse())
+	g.Expect(update(newHelmRelease("a", 1), newHelmRelease("a", 2))).To(gomega.BeTrue())
+
+	// unless a full reconcile is requested
+	requested := newHelmRelease("a", 1)
+	requested.SetAnnotations(map[string]string{release.ReconcileRequestAnnotation: "2020-11-20T10:00:00Z"})
+	g.Expect(update(newHelmRelease("a", 1), requested)).To(gomega.BeTrue())
+
+	// relabeled into the shard, it is reconciled right away, out of it never
+	g.Expect(update(newHelmRelease("b", 1), newHelmRelease("a", 1))).To(gomega.BeTrue())
+	g.Expect(update(newHelmRelease("a", 1), newHelmRelease("b", 2))).To(gomega.BeFalse())
+}
@@ -0,0 +1,139 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helmrelease
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+	"github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/release"
+)
+
+// cosignTimeout bounds the verification of an image, which fetches its
+// signatures from the registry
+const cosignTimeout = 2 * time.Minute
+
+// verifiedImages caches the digest-pinned images whose signature was
+// verified, their content cannot change. The tagged images are verified again
+// before each install and upgrade since the tag can be moved.
+var verifiedImages sync.Map
+
+// checkImageSignatures renders the release of hr and returns the images of
+// its workloads whose signature cannot be verified with the cosign public key,
+// with the reason. The hooks are not rendered and so not checked.
+func checkImageSignatures(hr *appv1.HelmRelease, manager release.Manager) ([]string, error) {
+	if Options.ImageSignatureKey == "" {
+		return nil, nil
+	}
+
+	objects, err := manager.Render(context.TODO(), release.RenderOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	var unverified []string
+
+	for _, image := range release.Images(objects) {
+		if !signatureRequired(image) {
+			continue
+		}
+
+		if err := verifyImageSignature(image); err != nil {
+			var exitErr *exec.ExitError
+			if !errors.As(err, &exitErr) {
+				return nil, fmt.Errorf("failed to verify the signature of image %s: %w", image, err)
+			}
+
+			unverified = append(unverified, fmt.Sprintf("%s (%s)", image, cosignMessage(exitErr)))
+		}
+	}
+
+	return unverified, nil
+}
+
+// signatureRequired returns true if image must be signed, i.e. it matches one
+// of the SignedImages prefixes, or any image if there is none.
+func signatureRequired(image string) bool {
+	if len(Options.SignedImages) == 0 {
+		return true
+	}
+
+	for _, prefix := range Options.SignedImages {
+		if strings.HasPrefix(image, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// verifyImageSignature runs cosign verify for image. The error is an
+// exec.ExitError if the signature is missing or invalid.
+func verifyImageSignature(image string) error {
+	pinned := strings.Contains(image, "@sha256:")
+	if _, ok := verifiedImages.Load(image); ok && pinned {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.TODO(), cosignTimeout)
+	defer cancel()
+
+	var stderr bytes.Buffer
+
+	// #nosec G204 the binary and the key are set by the operator flags
+	cmd := exec.CommandContext(ctx, Options.CosignPath, "verify", "--key", Options.ImageSignatureKey, image)
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			exitErr.Stderr = stderr.Bytes()
+		}
+
+		return err
+	}
+
+	if pinned {
+		verifiedImages.Store(image, true)
+	}
+
+	return nil
+}
+
+// cosignMessage returns the last line written by cosign before failing, which
+// holds the reason.
+func cosignMessage(err *exec.ExitError) string {
+	lines := strings.Split(strings.TrimSpace(string(err.Stderr)), "\n")
+	if msg := strings.TrimSpace(lines[len(lines)-1]); msg != "" {
+		return msg
+	}
+
+	return err.Error()
+}
+
+// unverifiedImagesMessage is the condition message of the images whose
+// signature cannot be verified.
+func unverifiedImagesMessage(unverified []string) string {
+	return "the chart deploys images whose signature cannot be verified: " + strings.Join(unverified, ", ")
+}
@@ -0,0 +1,109 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helmrelease
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+// fakeCosign is a cosign verifying the images not named unsigned, and
+// logging the verified images to its log.
+const fakeCosign = `#!/bin/sh
+echo "$4" >> "$(dirname "$0")/verified.log"
+case "$4" in
+*unsigned*) echo "Error: no matching signatures:" >&2; echo "main.go:62: error during command execution: no matching signatures" >&2; exit 1;;
+esac
+`
+
+func newPodObject(images ...string) *unstructured.Unstructured {
+	var containers []interface{}
+
+	for _, image := range images {
+		containers = append(containers, map[string]interface{}{"name": "app", "image": image})
+	}
+
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Pod",
+		"metadata":   map[string]interface{}{"name": "webapp"},
+		"spec":       map[string]interface{}{"containers": containers},
+	}}
+}
+
+func TestCheckImageSignatures(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	dir, err := ioutil.TempDir("", "cosign")
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	defer os.RemoveAll(dir)
+
+	cosign := filepath.Join(dir, "cosign")
+	g.Expect(ioutil.WriteFile(cosign, []byte(fakeCosign), 0700)).To(gomega.Succeed()) // #nosec G306
+
+	defer func(key, path string, signed []string) {
+		Options.ImageSignatureKey, Options.CosignPath, Options.SignedImages = key, path, signed
+	}(Options.ImageSignatureKey, Options.CosignPath, Options.SignedImages)
+
+	Options.CosignPath = cosign
+	Options.SignedImages = []string{"registry.example.com/"}
+
+	pinned := "registry.example.com/webapp@sha256:" + strings.Repeat("a", 64)
+	m := &renderManager{objects: []*unstructured.Unstructured{newPodObject(
+		pinned, "registry.example.com/unsigned:1.0", "docker.io/library/busybox:latest",
+	)}}
+
+	// the signatures are not verified without a key
+	unverified, err := checkImageSignatures(&appv1.HelmRelease{}, m)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(unverified).To(gomega.BeEmpty())
+
+	Options.ImageSignatureKey = "cosign.pub"
+
+	unverified, err = checkImageSignatures(&appv1.HelmRelease{}, m)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(unverified).To(gomega.Equal([]string{
+		"registry.example.com/unsigned:1.0 (main.go:62: error during command execution: no matching signatures)",
+	}))
+	g.Expect(unverifiedImagesMessage(unverified)).To(gomega.HaveSuffix(": " + unverified[0]))
+
+	// the verified pinned image is not verified again
+	_, err = checkImageSignatures(&appv1.HelmRelease{}, m)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	verified, err := ioutil.ReadFile(filepath.Join(dir, "verified.log"))
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(strings.Fields(string(verified))).To(gomega.Equal([]string{
+		"registry.example.com/unsigned:1.0", pinned, "registry.example.com/unsigned:1.0",
+	}))
+
+	// a cosign that cannot run fails the check
+	Options.CosignPath = filepath.Join(dir, "missing")
+	verifiedImages.Delete(pinned)
+
+	_, err = checkImageSignatures(&appv1.HelmRelease{}, m)
+	g.Expect(err).To(gomega.HaveOccurred())
+}
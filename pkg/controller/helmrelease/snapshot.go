@@ -0,0 +1,415 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helmrelease
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	rpb "helm.sh/helm/v3/pkg/release"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+	"github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/release"
+)
+
+// snapshotsInstalled is set if the HelmReleaseSnapshot CRD is installed
+var snapshotsInstalled bool
+
+// addSnapshots adds the controller capturing and restoring the
+// HelmReleaseSnapshots to mgr, if they are installed.
+func addSnapshots(mgr manager.Manager) error {
+	gvk := appv1.SchemeGroupVersion.WithKind("HelmReleaseSnapshot")
+
+	_, err := mgr.GetRESTMapper().RESTMapping(gvk.GroupKind(), gvk.Version)
+	if meta.IsNoMatchError(err) {
+		log.Info("HelmReleaseSnapshots are not installed, no snapshot is captured")
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	c, err := controller.New("helmreleasesnapshot-controller", mgr, controller.Options{Reconciler: &snapshotter{mgr}})
+	if err != nil {
+		return err
+	}
+
+	snapshotsInstalled = true
+
+	// the snapshots are sharded by their labels like the HelmReleases, the
+	// automatic ones carry the labels of their HelmRelease
+	return c.Watch(&source.Kind{Type: &appv1.HelmReleaseSnapshot{}}, &handler.EnqueueRequestForObject{},
+		shardPredicate{})
+}
+
+// snapshotter captures the HelmReleaseSnapshots once and restores them when
+// requested
+type snapshotter struct {
+	manager.Manager
+}
+
+// snapshotFailure is an error capturing a snapshot that is not retried, e.g.
+// a revision missing from the release history
+type snapshotFailure struct {
+	message string
+}
+
+func (e *snapshotFailure) Error() string {
+	return e.message
+}
+
+func (r *snapshotter) Reconcile(request reconcile.Request) (reconcile.Result, error) {
+	snapshot := &appv1.HelmReleaseSnapshot{}
+
+	if err := r.GetClient().Get(context.TODO(), request.NamespacedName, snapshot); err != nil {
+		if apierrors.IsNotFound(err) {
+			return reconcile.Result{}, nil
+		}
+
+		return reconcile.Result{}, err
+	}
+
+	changed := false
+
+	if snapshot.Status.CaptureTime == nil {
+		err := r.capture(snapshot)
+
+		var failure *snapshotFailure
+		if err != nil && !errors.As(err, &failure) {
+			log.Error(err, "Failed to capture snapshot", "snapshot", request.NamespacedName.String())
+			return reconcile.Result{}, err
+		}
+
+		now := metav1.Now()
+		snapshot.Status.CaptureTime = &now
+		snapshot.Status.Phase = appv1.SnapshotCaptured
+
+		if failure != nil {
+			snapshot.Status.Phase = appv1.SnapshotFailed
+			snapshot.Status.Error = failure.Error()
+		}
+
+		log.Info("Captured snapshot", "snapshot", request.NamespacedName.String(),
+			"phase", snapshot.Status.Phase, "revision", snapshot.Status.Revision)
+
+		changed = true
+	}
+
+	if requested := snapshot.Spec.RestoreRequest; requested != "" &&
+		(snapshot.Status.LastRestore == nil || snapshot.Status.LastRestore.Request != requested) {
+		snapshot.Status.LastRestore = r.restore(snapshot)
+		changed = true
+	}
+
+	if !changed {
+		return reconcile.Result{}, nil
+	}
+
+	return reconcile.Result{}, r.GetClient().Status().Update(context.TODO(), snapshot)
+}
+
+// capture captures the revision of the release selected by snapshot in its
+// status, and its secret values in its values Secret.
+func (r *snapshotter) capture(snapshot *appv1.HelmReleaseSnapshot) error {
+	hr, manager, err := r.releaseManager(snapshot)
+	if err != nil {
+		return err
+	}
+
+	rel, err := snapshotRevision(manager, snapshot.Spec.Revision)
+	if err != nil {
+		return err
+	}
+
+	secretName := snapshot.GetName() + "-values"
+
+	captured, err := release.SnapshotRelease(rel, secretName)
+	if err != nil {
+		return &snapshotFailure{err.Error()}
+	}
+
+	if len(captured.SecretValues) != 0 {
+		if err := r.writeValuesSecret(snapshot, secretName, captured.SecretValues); err != nil {
+			return err
+		}
+
+		snapshot.Status.ValuesSecret = secretName
+	}
+
+	snapshot.Status.Release = rel.Name
+	snapshot.Status.Revision = rel.Version
+	snapshot.Status.Chart = appv1.HelmReleaseSnapshotChart{
+		Name:    captured.Chart,
+		Version: captured.ChartVersion,
+		Digest:  captured.ChartDigest,
+		Source:  hr.Repo.Source.DeepCopy(),
+	}
+	snapshot.Status.Values = captured.Values
+	snapshot.Status.ValuesDigest = captured.ValuesDigest
+	snapshot.Status.Manifest = captured.Manifest
+
+	return nil
+}
+
+// releaseManager returns the HelmRelease of snapshot and the manager of its
+// release.
+func (r *snapshotter) releaseManager(snapshot *appv1.HelmReleaseSnapshot) (*appv1.HelmRelease, release.Manager, error) {
+	hr := &appv1.HelmRelease{}
+	key := types.NamespacedName{Namespace: snapshot.GetNamespace(), Name: snapshot.Spec.HelmRelease}
+
+	if err := r.GetClient().Get(context.TODO(), key, hr); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, nil, &snapshotFailure{fmt.Sprintf("HelmRelease %s not found", key.Name)}
+		}
+
+		return nil, nil, err
+	}
+
+	if hubMode(hr) {
+		return nil, nil, &snapshotFailure{"the releases deployed to managed clusters can not be snapshotted"}
+	}
+
+	manager, err := ReconcileHelmRelease{r.Manager}.getHelmOperatorManager(context.TODO(), hr,
+		reconcile.Request{NamespacedName: key})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return hr, manager, nil
+}
+
+// snapshotRevision returns the revision of the release of manager, the
+// deployed one if it is 0.
+func snapshotRevision(manager release.Manager, revision int) (*rpb.Release, error) {
+	history, err := manager.History(context.TODO())
+	if err != nil {
+		return nil, err
+	}
+
+	for i := len(history) - 1; i >= 0; i-- {
+		rel := history[i]
+
+		if (revision == 0 && rel.Info != nil && rel.Info.Status == rpb.StatusDeployed) || rel.Version == revision {
+			return rel, nil
+		}
+	}
+
+	if revision == 0 {
+		return nil, &snapshotFailure{fmt.Sprintf("release %s has no deployed revision", manager.ReleaseName())}
+	}
+
+	return nil, &snapshotFailure{fmt.Sprintf("revision %d is not in the history of release %s", revision, manager.ReleaseName())}
+}
+
+// writeValuesSecret writes the secret values of snapshot to the Secret name,
+// owned by the snapshot.
+func (r *snapshotter) writeValuesSecret(snapshot *appv1.HelmReleaseSnapshot, name string, data map[string][]byte) error {
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: snapshot.GetNamespace(),
+			Labels:    map[string]string{release.HelmReleaseNameLabel: snapshot.Spec.HelmRelease},
+		},
+		Type: corev1.SecretTypeOpaque,
+		Data: data,
+	}
+
+	if err := controllerutil.SetControllerReference(snapshot, secret, r.GetScheme()); err != nil {
+		return err
+	}
+
+	err := r.GetClient().Create(context.TODO(), secret)
+	if apierrors.IsAlreadyExists(err) {
+		// left by a capture whose status update failed
+		return r.GetClient().Update(context.TODO(), secret)
+	}
+
+	return err
+}
+
+// restore rolls the release of snapshot back to its revision and returns the
+// outcome.
+func (r *snapshotter) restore(snapshot *appv1.HelmReleaseSnapshot) *appv1.HelmReleaseSnapshotRestore {
+	restore := &appv1.HelmReleaseSnapshotRestore{Request: snapshot.Spec.RestoreRequest}
+
+	rel, err := r.rollback(snapshot)
+	if err != nil {
+		log.Error(err, "Failed to restore snapshot", "snapshot", snapshot.GetNamespace()+"/"+snapshot.GetName())
+		restore.Error = release.RedactMessage(err.Error())
+
+		return restore
+	}
+
+	now := metav1.Now()
+	restore.Time = &now
+	restore.Revision = rel.Version
+
+	log.Info("Restored snapshot", "snapshot", snapshot.GetNamespace()+"/"+snapshot.GetName(),
+		"revision", snapshot.Status.Revision, "restoredRevision", rel.Version)
+
+	return restore
+}
+
+// rollback rolls the release of snapshot back to its revision, if it is still
+// the revision captured.
+func (r *snapshotter) rollback(snapshot *appv1.HelmReleaseSnapshot) (*rpb.Release, error) {
+	if snapshot.Status.Phase != appv1.SnapshotCaptured {
+		return nil, errors.New("the snapshot was not captured")
+	}
+
+	hr, manager, err := r.releaseManager(snapshot)
+	if err != nil {
+		return nil, err
+	}
+
+	if !hr.Repo.Suspend {
+		return nil, fmt.Errorf("HelmRelease %s must be suspended, its next reconcile would upgrade the release again",
+			hr.GetName())
+	}
+
+	rel, err := snapshotRevision(manager, snapshot.Status.Revision)
+	if err != nil {
+		return nil, fmt.Errorf("%w, pin it with the %s annotation of the HelmRelease to keep it",
+			err, release.PinnedRevisionsAnnotation)
+	}
+
+	// a release installed again reuses the revision numbers
+	if release.RedactManifest(rel.Manifest) != snapshot.Status.Manifest {
+		return nil, fmt.Errorf("revision %d of release %s is not the revision captured", rel.Version, rel.Name)
+	}
+
+	lockCtx, unlock, err := manager.Lock(context.TODO())
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	restored, err := manager.RollbackRelease(lockCtx, rel.Version)
+
+	recordAudit(hr, manager, appv1.AuditRollback, triggerSnapshotRestore, restored, err)
+
+	if err != nil {
+		ReconcileHelmRelease{r.Manager}.recordWarning(hr, eventRollbackFailed, err)
+	} else {
+		ReconcileHelmRelease{r.Manager}.recordEvent(hr, eventSnapshotRestored,
+			"Restored release %s to revision %d of snapshot %s as revision %d",
+			restored.Name, rel.Version, snapshot.GetName(), restored.Version)
+	}
+
+	if err := (ReconcileHelmRelease{r.Manager}).updateResourceStatus(hr); err != nil {
+		logFor(hr).Error(err, "Failed to record the snapshot restore")
+	}
+
+	return restored, err
+}
+
+// snapshotRelease creates the HelmReleaseSnapshot of the revision rel
+// installed or upgraded by hr, if it snapshots its upgrades, and deletes its
+// snapshots beyond its history. The snapshot is captured by the snapshot
+// controller.
+func (r ReconcileHelmRelease) snapshotRelease(hr *appv1.HelmRelease, rel *rpb.Release) {
+	if !hr.Repo.SnapshotUpgrades || rel == nil {
+		return
+	}
+
+	if !snapshotsInstalled {
+		logFor(hr).Info("HelmReleaseSnapshots are not installed, the revision is not snapshotted", "revision", rel.Version)
+		return
+	}
+
+	labels := map[string]string{release.HelmReleaseNameLabel: hr.GetName()}
+	for k, v := range hr.GetLabels() {
+		labels[k] = v
+	}
+
+	snapshot := &appv1.HelmReleaseSnapshot{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%s.v%d", hr.GetName(), rel.Version),
+			Namespace: hr.GetNamespace(),
+			Labels:    labels,
+		},
+		Spec: appv1.HelmReleaseSnapshotSpec{HelmRelease: hr.GetName(), Revision: rel.Version},
+	}
+
+	if err := controllerutil.SetControllerReference(hr, snapshot, r.GetScheme()); err != nil {
+		logFor(hr).Error(err, "Failed to snapshot the revision", "revision", rel.Version)
+		return
+	}
+
+	if err := r.GetClient().Create(context.TODO(), snapshot); err != nil && !apierrors.IsAlreadyExists(err) {
+		logFor(hr).Error(err, "Failed to snapshot the revision", "revision", rel.Version)
+		return
+	}
+
+	if err := r.pruneSnapshots(hr); err != nil {
+		logFor(hr).Error(err, "Failed to delete the snapshots beyond the history")
+	}
+}
+
+// pruneSnapshots deletes the snapshots created for hr beyond the number of
+// revisions it keeps, their revision could not be restored.
+func (r ReconcileHelmRelease) pruneSnapshots(hr *appv1.HelmRelease) error {
+	maxHistory := release.DefaultMaxHistory
+	if hr.Repo.MaxHistory != nil {
+		maxHistory = *hr.Repo.MaxHistory
+	}
+
+	if maxHistory <= 0 {
+		return nil
+	}
+
+	snapshots := &appv1.HelmReleaseSnapshotList{}
+	if err := r.GetClient().List(context.TODO(), snapshots, client.InNamespace(hr.GetNamespace()),
+		client.MatchingLabels{release.HelmReleaseNameLabel: hr.GetName()}); err != nil {
+		return err
+	}
+
+	var owned []appv1.HelmReleaseSnapshot
+
+	for _, snapshot := range snapshots.Items {
+		if metav1.IsControlledBy(&snapshot, hr) {
+			owned = append(owned, snapshot)
+		}
+	}
+
+	sort.Slice(owned, func(i, j int) bool {
+		return owned[i].Spec.Revision > owned[j].Spec.Revision
+	})
+
+	for i := maxHistory; i < len(owned); i++ {
+		if err := r.GetClient().Delete(context.TODO(), &owned[i]); err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+	}
+
+	return nil
+}
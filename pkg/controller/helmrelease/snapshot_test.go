@@ -0,0 +1,107 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helmrelease
+
+import (
+	"context"
+	"testing"
+
+	"github.com/onsi/gomega"
+	rpb "helm.sh/helm/v3/pkg/release"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+	"github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/release"
+)
+
+// historyManager is a release manager with a fixed history.
+type historyManager struct {
+	release.Manager
+	history []*rpb.Release
+}
+
+func (m historyManager) History(context.Context) ([]*rpb.Release, error) {
+	return m.history, nil
+}
+
+func (m historyManager) ReleaseName() string {
+	return "webapp"
+}
+
+func TestSnapshotRevision(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	m := historyManager{history: []*rpb.Release{
+		{Name: "webapp", Version: 1, Info: &rpb.Info{Status: rpb.StatusSuperseded}},
+		{Name: "webapp", Version: 2, Info: &rpb.Info{Status: rpb.StatusDeployed}},
+		{Name: "webapp", Version: 3, Info: &rpb.Info{Status: rpb.StatusFailed}},
+	}}
+
+	// the deployed revision is snapshotted by default
+	rel, err := snapshotRevision(m, 0)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(rel.Version).To(gomega.Equal(2))
+
+	rel, err = snapshotRevision(m, 1)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(rel.Version).To(gomega.Equal(1))
+
+	// the missing revisions are not retried
+	var failure *snapshotFailure
+
+	_, err = snapshotRevision(m, 4)
+	g.Expect(err).To(gomega.BeAssignableToTypeOf(failure))
+	g.Expect(err).To(gomega.MatchError("revision 4 is not in the history of release webapp"))
+
+	_, err = snapshotRevision(historyManager{}, 0)
+	g.Expect(err).To(gomega.MatchError("release webapp has no deployed revision"))
+}
+
+func TestSnapshotRelease(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	defer func(installed bool) { snapshotsInstalled = installed }(snapshotsInstalled)
+	snapshotsInstalled = true
+
+	maxHistory := 2
+	hr := &appv1.HelmRelease{ObjectMeta: metav1.ObjectMeta{Name: "webapp", Namespace: "default", UID: "webapp-uid"}}
+	hr.Repo.SnapshotUpgrades = true
+	hr.Repo.MaxHistory = &maxHistory
+
+	c := fake.NewFakeClientWithScheme(scheme.Scheme)
+	r := ReconcileHelmRelease{clientManager{client: c}}
+
+	for revision := 1; revision <= 3; revision++ {
+		r.snapshotRelease(hr, &rpb.Release{Name: "webapp", Version: revision})
+	}
+
+	// the snapshots are owned by the HelmRelease, the ones beyond its history are deleted
+	snapshots := &appv1.HelmReleaseSnapshotList{}
+	g.Expect(c.List(context.TODO(), snapshots)).To(gomega.Succeed())
+
+	var revisions []int
+
+	for i := range snapshots.Items {
+		g.Expect(metav1.IsControlledBy(&snapshots.Items[i], hr)).To(gomega.BeTrue())
+		g.Expect(snapshots.Items[i].Spec.HelmRelease).To(gomega.Equal("webapp"))
+		revisions = append(revisions, snapshots.Items[i].Spec.Revision)
+	}
+
+	g.Expect(revisions).To(gomega.ConsistOf(2, 3))
+}
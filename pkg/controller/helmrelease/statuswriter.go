@@ -0,0 +1,249 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helmrelease
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+// DefaultStatusUpdateInterval is the minimum interval between two status
+// writes of a HelmRelease by default
+const DefaultStatusUpdateInterval = 5 * time.Second
+
+// statusRequeueBuffer is the number of HelmReleases whose failed delayed
+// status write waits to be requeued
+const statusRequeueBuffer = 100
+
+// results of the status writes reported by the helmrelease_status_updates_total metric
+const (
+	statusWritten   = "written"
+	statusCoalesced = "coalesced"
+	statusUnchanged = "unchanged"
+)
+
+// statusWriter patches the status of the HelmReleases from the status last
+// read or written, so that the writes neither conflict nor send the unchanged
+// fields. The unchanged statuses are not written, and a status changed less
+// than Options.StatusUpdateInterval after the previous write is written once
+// the interval elapsed, coalesced with the later changes. A HelmRelease whose
+// delayed write failed is sent to requeue, and its reconcile carries on from
+// the status that was not written.
+type statusWriter struct {
+	mu      sync.Mutex
+	records map[types.NamespacedName]*statusRecord
+	requeue chan event.GenericEvent
+}
+
+type statusRecord struct {
+	// writing serializes the writes of the reconciles and of the delayed flush
+	writing sync.Mutex
+
+	uid      types.UID
+	observed appv1.HelmAppStatus
+	written  time.Time
+	pending  *appv1.HelmRelease
+	timer    *time.Timer
+}
+
+var statusWrites = newStatusWriter()
+
+func newStatusWriter() *statusWriter {
+	return &statusWriter{
+		records: map[types.NamespacedName]*statusRecord{},
+		requeue: make(chan event.GenericEvent, statusRequeueBuffer),
+	}
+}
+
+// record returns the record of hr, created with the status returned by
+// observed if hr has none.
+func (w *statusWriter) record(hr *appv1.HelmRelease, observed func() appv1.HelmAppStatus) *statusRecord {
+	name := types.NamespacedName{Namespace: hr.GetNamespace(), Name: hr.GetName()}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	rec, ok := w.records[name]
+	if !ok || rec.uid != hr.GetUID() {
+		rec = &statusRecord{uid: hr.GetUID(), observed: observed()}
+		w.records[name] = rec
+	}
+
+	return rec
+}
+
+// observe records the status of hr as read at the start of its reconcile, the
+// base of its next patch. A status still waiting for the interval is set on hr
+// so that the reconcile carries on from it.
+func (w *statusWriter) observe(hr *appv1.HelmRelease) {
+	rec := w.record(hr, func() appv1.HelmAppStatus { return appv1.HelmAppStatus{} })
+
+	rec.writing.Lock()
+	defer rec.writing.Unlock()
+
+	rec.observed = *hr.Status.DeepCopy()
+
+	if rec.pending != nil {
+		hr.Status = *rec.pending.Status.DeepCopy()
+	}
+}
+
+// observeProgress records the rollout progress patched in the status of hr
+// while its release action waited, so that the next patch clears it.
+func (w *statusWriter) observeProgress(hr *appv1.HelmRelease, progress string) {
+	rec := w.record(hr, func() appv1.HelmAppStatus { return *hr.Status.DeepCopy() })
+
+	rec.writing.Lock()
+	defer rec.writing.Unlock()
+
+	rec.observed.Progress = progress
+}
+
+// forget drops the record of the HelmRelease name, e.g. once it is deleted.
+func (w *statusWriter) forget(name types.NamespacedName) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	delete(w.records, name)
+}
+
+// write writes the status of hr, right away or once the interval since the
+// previous write elapsed.
+func (w *statusWriter) write(c client.Client, hr *appv1.HelmRelease) error {
+	// the HelmReleases written without being reconciled, e.g. by the
+	// snapshots, are patched from their cached status
+	rec := w.record(hr, func() appv1.HelmAppStatus {
+		cached := &appv1.HelmRelease{}
+		if err := c.Get(context.TODO(), types.NamespacedName{Namespace: hr.GetNamespace(), Name: hr.GetName()},
+			cached); err != nil || cached.GetUID() != hr.GetUID() {
+			return appv1.HelmAppStatus{}
+		}
+
+		return cached.Status
+	})
+
+	rec.writing.Lock()
+	defer rec.writing.Unlock()
+
+	if equality.Semantic.DeepEqual(rec.observed, hr.Status) {
+		rec.clearPending()
+
+		statusUpdates.WithLabelValues(statusUnchanged).Inc()
+
+		return nil
+	}
+
+	if wait := Options.StatusUpdateInterval - time.Since(rec.written); wait > 0 {
+		if rec.timer == nil {
+			rec.timer = time.AfterFunc(wait, func() { w.flush(c, rec) })
+		}
+
+		rec.pending = hr.DeepCopy()
+
+		statusUpdates.WithLabelValues(statusCoalesced).Inc()
+
+		return nil
+	}
+
+	rec.clearPending()
+
+	return rec.patch(c, hr)
+}
+
+// clearPending drops the pending status of rec, superseded by a later one.
+// rec.writing must be held.
+func (rec *statusRecord) clearPending() {
+	if rec.timer != nil {
+		rec.timer.Stop()
+		rec.timer = nil
+	}
+
+	rec.pending = nil
+}
+
+// flush writes the pending status of rec, if any. A status that fails to be
+// written stays pending and its HelmRelease is requeued.
+func (w *statusWriter) flush(c client.Client, rec *statusRecord) {
+	rec.writing.Lock()
+	defer rec.writing.Unlock()
+
+	if rec.pending == nil {
+		return
+	}
+
+	hr := rec.pending
+	rec.clearPending()
+
+	err := rec.patch(c, hr)
+	if err == nil || apierrors.IsNotFound(err) {
+		return
+	}
+
+	logFor(hr).Error(err, "Failed to write the coalesced status")
+
+	rec.pending = hr
+
+	select {
+	case w.requeue <- event.GenericEvent{Meta: hr, Object: hr}:
+	default:
+		// the HelmRelease is still written by its next resync
+		logFor(hr).Info("Too many failed status writes to requeue the HelmRelease")
+	}
+}
+
+// flushAll writes the pending statuses right away, e.g. when the operator
+// stops.
+func (w *statusWriter) flushAll(c client.Client) {
+	w.mu.Lock()
+	records := make([]*statusRecord, 0, len(w.records))
+
+	for _, rec := range w.records {
+		records = append(records, rec)
+	}
+	w.mu.Unlock()
+
+	for _, rec := range records {
+		w.flush(c, rec)
+	}
+}
+
+// patch merge patches the status of hr from the observed one, without its
+// resource version, and records it as observed. rec.writing must be held.
+func (rec *statusRecord) patch(c client.Client, hr *appv1.HelmRelease) error {
+	base := hr.DeepCopy()
+	base.Status = rec.observed
+
+	if err := c.Status().Patch(context.TODO(), hr, client.MergeFrom(base)); err != nil {
+		return err
+	}
+
+	rec.observed = *hr.Status.DeepCopy()
+	rec.written = time.Now()
+
+	statusUpdates.WithLabelValues(statusWritten).Inc()
+
+	return nil
+}
@@ -0,0 +1,303 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helmrelease
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis"
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+// statusPatchClient counts the status patches of a fake client and fails
+// them with err if set.
+type statusPatchClient struct {
+	client.Client
+
+	mu      sync.Mutex
+	patches int
+	err     error
+}
+
+// Update updates hr like the API server does with a status subresource: the
+// status is not updated, and the stored one is returned.
+func (c *statusPatchClient) Update(ctx context.Context, obj runtime.Object, opts ...client.UpdateOption) error {
+	hr, ok := obj.(*appv1.HelmRelease)
+	if !ok {
+		return c.Client.Update(ctx, obj, opts...)
+	}
+
+	stored := &appv1.HelmRelease{}
+	if err := c.Client.Get(ctx, types.NamespacedName{Namespace: hr.GetNamespace(), Name: hr.GetName()},
+		stored); err != nil {
+		return err
+	}
+
+	hr.Status = stored.Status
+
+	return c.Client.Update(ctx, hr, opts...)
+}
+
+func (c *statusPatchClient) Status() client.StatusWriter {
+	return statusPatchWriter{c}
+}
+
+func (c *statusPatchClient) patchCount() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.patches
+}
+
+func (c *statusPatchClient) failPatches(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.err = err
+}
+
+type statusPatchWriter struct {
+	c *statusPatchClient
+}
+
+func (w statusPatchWriter) Update(ctx context.Context, obj runtime.Object, opts ...client.UpdateOption) error {
+	return w.c.Client.Status().Update(ctx, obj, opts...)
+}
+
+func (w statusPatchWriter) Patch(ctx context.Context, obj runtime.Object, patch client.Patch,
+	opts ...client.PatchOption) error {
+	w.c.mu.Lock()
+	w.c.patches++
+	err := w.c.err
+	w.c.mu.Unlock()
+
+	if err != nil {
+		return err
+	}
+
+	return w.c.Client.Status().Patch(ctx, obj, patch, opts...)
+}
+
+func newStatusTestClient(t *testing.T, hrs ...*appv1.HelmRelease) *statusPatchClient {
+	scheme := runtime.NewScheme()
+	if err := apis.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	c := fake.NewFakeClientWithScheme(scheme)
+
+	for _, hr := range hrs {
+		if err := c.Create(context.TODO(), hr.DeepCopy()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return &statusPatchClient{Client: c}
+}
+
+func newStatusTestHelmRelease(uid types.UID) *appv1.HelmRelease {
+	return &appv1.HelmRelease{
+		TypeMeta:   metav1.TypeMeta{APIVersion: appv1.SchemeGroupVersion.String(), Kind: "HelmRelease"},
+		ObjectMeta: metav1.ObjectMeta{Namespace: "status-ns", Name: "status-writer", UID: uid},
+	}
+}
+
+func withStatusInterval(interval time.Duration) func() {
+	previous := Options.StatusUpdateInterval
+	Options.StatusUpdateInterval = interval
+
+	return func() { Options.StatusUpdateInterval = previous }
+}
+
+func writtenStatus(g *gomega.WithT, c client.Client, hr *appv1.HelmRelease) appv1.HelmAppStatus {
+	got := &appv1.HelmRelease{}
+	g.Expect(c.Get(context.TODO(), types.NamespacedName{Namespace: hr.GetNamespace(), Name: hr.GetName()},
+		got)).To(gomega.Succeed())
+
+	return got.Status
+}
+
+func TestStatusWriterUnchanged(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	defer withStatusInterval(0)()
+
+	hr := newStatusTestHelmRelease("uid-1")
+	c := newStatusTestClient(t, hr)
+	w := newStatusWriter()
+
+	w.observe(hr)
+	g.Expect(w.write(c, hr)).To(gomega.Succeed())
+	g.Expect(c.patchCount()).To(gomega.Equal(0))
+}
+
+func TestStatusWriterWritten(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	defer withStatusInterval(0)()
+
+	hr := newStatusTestHelmRelease("uid-1")
+	c := newStatusTestClient(t, hr)
+	w := newStatusWriter()
+
+	w.observe(hr)
+	hr.Status.Progress = "1/2 replicas ready"
+	g.Expect(w.write(c, hr)).To(gomega.Succeed())
+	g.Expect(c.patchCount()).To(gomega.Equal(1))
+	g.Expect(writtenStatus(g, c, hr).Progress).To(gomega.Equal("1/2 replicas ready"))
+
+	// the written status is the base of the next write
+	g.Expect(w.write(c, hr)).To(gomega.Succeed())
+	g.Expect(c.patchCount()).To(gomega.Equal(1))
+}
+
+func TestStatusWriterCoalescedAndFlushed(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	defer withStatusInterval(200 * time.Millisecond)()
+
+	hr := newStatusTestHelmRelease("uid-1")
+	c := newStatusTestClient(t, hr)
+	w := newStatusWriter()
+
+	w.observe(hr)
+	hr.Status.Progress = "0/2 replicas ready"
+	g.Expect(w.write(c, hr)).To(gomega.Succeed())
+	g.Expect(c.patchCount()).To(gomega.Equal(1))
+
+	hr.Status.Progress = "1/2 replicas ready"
+	g.Expect(w.write(c, hr)).To(gomega.Succeed())
+	hr.Status.Progress = "2/2 replicas ready"
+	g.Expect(w.write(c, hr)).To(gomega.Succeed())
+	g.Expect(c.patchCount()).To(gomega.Equal(1))
+
+	// a reconcile carries on from the pending status
+	observed := newStatusTestHelmRelease("uid-1")
+	observed.Status = writtenStatus(g, c, hr)
+	w.observe(observed)
+	g.Expect(observed.Status.Progress).To(gomega.Equal("2/2 replicas ready"))
+
+	g.Eventually(c.patchCount, time.Second, 10*time.Millisecond).Should(gomega.Equal(2))
+	g.Expect(writtenStatus(g, c, hr).Progress).To(gomega.Equal("2/2 replicas ready"))
+	g.Consistently(c.patchCount, 300*time.Millisecond, 50*time.Millisecond).Should(gomega.Equal(2))
+}
+
+func TestStatusWriterRecreated(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	defer withStatusInterval(0)()
+
+	hr := newStatusTestHelmRelease("uid-1")
+	hr.Status.Progress = "2/2 replicas ready"
+
+	w := newStatusWriter()
+	w.observe(hr)
+
+	// the recreated HelmRelease has the status of the deleted one, but its
+	// record is read again and not taken from the deleted one
+	recreated := newStatusTestHelmRelease("uid-2")
+	c := newStatusTestClient(t, recreated)
+
+	recreated.Status.Progress = "2/2 replicas ready"
+	g.Expect(w.write(c, recreated)).To(gomega.Succeed())
+	g.Expect(c.patchCount()).To(gomega.Equal(1))
+	g.Expect(writtenStatus(g, c, recreated).Progress).To(gomega.Equal("2/2 replicas ready"))
+}
+
+func TestStatusWriterFailedFlushRequeued(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	defer withStatusInterval(100 * time.Millisecond)()
+
+	hr := newStatusTestHelmRelease("uid-1")
+	c := newStatusTestClient(t, hr)
+	w := newStatusWriter()
+
+	w.observe(hr)
+	hr.Status.Progress = "1/2 replicas ready"
+	g.Expect(w.write(c, hr)).To(gomega.Succeed())
+
+	c.failPatches(errors.New("connection refused"))
+
+	hr.Status.Progress = "2/2 replicas ready"
+	g.Expect(w.write(c, hr)).To(gomega.Succeed())
+
+	select {
+	case e := <-w.requeue:
+		g.Expect(e.Meta.GetNamespace()).To(gomega.Equal(hr.GetNamespace()))
+		g.Expect(e.Meta.GetName()).To(gomega.Equal(hr.GetName()))
+	case <-time.After(time.Second):
+		t.Fatal("the HelmRelease of the failed status write was not requeued")
+	}
+
+	// the requeued reconcile carries on from the status that was not written
+	c.failPatches(nil)
+
+	requeued := newStatusTestHelmRelease("uid-1")
+	requeued.Status = writtenStatus(g, c, hr)
+	w.observe(requeued)
+	g.Expect(requeued.Status.Progress).To(gomega.Equal("2/2 replicas ready"))
+
+	g.Expect(w.write(c, requeued)).To(gomega.Succeed())
+	g.Eventually(func() string { return writtenStatus(g, c, hr).Progress }, time.Second,
+		10*time.Millisecond).Should(gomega.Equal("2/2 replicas ready"))
+}
+
+func TestStatusWriterCoalescedWithUpdate(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	defer withStatusInterval(200 * time.Millisecond)()
+
+	hr := newStatusTestHelmRelease("uid-1")
+	c := newStatusTestClient(t, hr)
+	w := newStatusWriter()
+	r := ReconcileHelmRelease{Manager: clientManager{client: c}}
+
+	w.observe(hr)
+	hr.Status.Progress = "1/2 replicas ready"
+	g.Expect(w.write(c, hr)).To(gomega.Succeed())
+
+	hr.Status.Progress = "2/2 replicas ready"
+	g.Expect(w.write(c, hr)).To(gomega.Succeed())
+	g.Expect(c.patchCount()).To(gomega.Equal(1))
+
+	// the next reconcile adds the finalizer while the status is pending
+	reconciled := &appv1.HelmRelease{}
+	g.Expect(c.Get(context.TODO(), types.NamespacedName{Namespace: hr.GetNamespace(), Name: hr.GetName()},
+		reconciled)).To(gomega.Succeed())
+	w.observe(reconciled)
+
+	reconciled.SetFinalizers([]string{finalizer})
+	g.Expect(r.updateResource(reconciled)).To(gomega.Succeed())
+	g.Expect(reconciled.Status.Progress).To(gomega.Equal("2/2 replicas ready"))
+
+	// the pending status is still written
+	g.Expect(w.write(c, reconciled)).To(gomega.Succeed())
+	g.Eventually(func() string { return writtenStatus(g, c, hr).Progress }, time.Second,
+		10*time.Millisecond).Should(gomega.Equal("2/2 replicas ready"))
+}
@@ -0,0 +1,188 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helmrelease
+
+import (
+	"strings"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+const (
+	// hostingSubscriptionAnnotation is the namespace/name of the Subscription
+	// a HelmRelease was created by
+	hostingSubscriptionAnnotation = "apps.open-cluster-management.io/hosting-subscription"
+
+	// the Subscription lists its HelmReleases by these labels
+	subscriptionNameLabel      = "apps.open-cluster-management.io/subscription-name"
+	subscriptionNamespaceLabel = "apps.open-cluster-management.io/subscription-namespace"
+)
+
+// originSubscription returns the Subscription hr was created by: its owner,
+// or the Subscription of its hosting-subscription annotation.
+func originSubscription(hr *appv1.HelmRelease) (types.NamespacedName, bool) {
+	for _, ref := range hr.GetOwnerReferences() {
+		gv, err := schema.ParseGroupVersion(ref.APIVersion)
+		if err == nil && gv.Group == appv1.SchemeGroupVersion.Group && ref.Kind == "Subscription" {
+			return types.NamespacedName{Namespace: hr.GetNamespace(), Name: ref.Name}, true
+		}
+	}
+
+	parts := strings.SplitN(hr.GetAnnotations()[hostingSubscriptionAnnotation], "/", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return types.NamespacedName{}, false
+	}
+
+	return types.NamespacedName{Namespace: parts[0], Name: parts[1]}, true
+}
+
+// linkSubscription sets the back-references of hr to the Subscription it was
+// created by, and returns true if they changed.
+func linkSubscription(hr *appv1.HelmRelease) bool {
+	origin, ok := originSubscription(hr)
+	if !ok {
+		return false
+	}
+
+	annotations := hr.GetAnnotations()
+	labels := hr.GetLabels()
+
+	if annotations[hostingSubscriptionAnnotation] == origin.String() &&
+		labels[subscriptionNameLabel] == origin.Name && labels[subscriptionNamespaceLabel] == origin.Namespace {
+		return false
+	}
+
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+
+	if labels == nil {
+		labels = make(map[string]string)
+	}
+
+	annotations[hostingSubscriptionAnnotation] = origin.String()
+	labels[subscriptionNameLabel] = origin.Name
+	labels[subscriptionNamespaceLabel] = origin.Namespace
+
+	hr.SetAnnotations(annotations)
+	hr.SetLabels(labels)
+
+	return true
+}
+
+// subscriptionStatus rolls the conditions of hr up into the per-cluster form
+// of the status of the Subscription it was created by, nil if there is none.
+// In hub mode each selected ManagedCluster has its state, with the failure of
+// its work agent.
+func subscriptionStatus(hr *appv1.HelmRelease) *appv1.HelmAppSubscriptionStatus {
+	origin, ok := originSubscription(hr)
+	if !ok {
+		return nil
+	}
+
+	status := &appv1.HelmAppSubscriptionStatus{Subscription: origin.String()}
+
+	local := releaseUnitStatus(hr)
+
+	// the failures of the hub, e.g. a chart failing to render, fail every cluster
+	if !hubMode(hr) || local.Phase == appv1.FailedPhase {
+		status.Clusters = append(status.Clusters, local)
+	}
+
+	if hubMode(hr) {
+		for _, s := range hr.Status.Clusters {
+			status.Clusters = append(status.Clusters, clusterUnitStatus(s))
+		}
+	}
+
+	status.Phase = appv1.SubscribedPhase
+
+	for _, unit := range status.Clusters {
+		switch {
+		case unit.Phase == appv1.FailedPhase:
+			status.Phase = appv1.FailedPhase
+		case unit.Phase == appv1.InProgressPhase && status.Phase != appv1.FailedPhase:
+			status.Phase = appv1.InProgressPhase
+		}
+	}
+
+	return status
+}
+
+// releaseUnitStatus returns the state of the release of hr from its Ready
+// condition: failed if it is kept from being ready by a failure.
+func releaseUnitStatus(hr *appv1.HelmRelease) appv1.HelmAppSubscriptionUnitStatus {
+	ready := hr.Status.GetCondition(appv1.ConditionReady)
+	if ready == nil {
+		return appv1.HelmAppSubscriptionUnitStatus{Phase: appv1.InProgressPhase}
+	}
+
+	unit := appv1.HelmAppSubscriptionUnitStatus{
+		Phase:          appv1.InProgressPhase,
+		Reason:         ready.Reason,
+		Message:        ready.Message,
+		LastUpdateTime: ready.LastTransitionTime,
+	}
+
+	if ready.Status == appv1.StatusTrue {
+		unit.Phase = appv1.SubscribedPhase
+		return unit
+	}
+
+	for _, t := range []appv1.HelmAppConditionType{
+		appv1.ConditionStalled,
+		appv1.ConditionNameConflict,
+		appv1.ConditionReleaseFailed,
+		appv1.ConditionPreconditionsNotMet,
+	} {
+		if c := hr.Status.GetCondition(t); c != nil && c.Status == appv1.StatusTrue {
+			unit.Phase = appv1.FailedPhase
+			break
+		}
+	}
+
+	return unit
+}
+
+// clusterUnitStatus returns the state of the release on a ManagedCluster from
+// its ManifestWork.
+func clusterUnitStatus(s appv1.HelmAppClusterStatus) appv1.HelmAppSubscriptionUnitStatus {
+	unit := appv1.HelmAppSubscriptionUnitStatus{
+		Cluster: s.Cluster,
+		Phase:   appv1.InProgressPhase,
+		Reason:  appv1.ReasonResourcesInProgress,
+		Message: "Waiting for the work agent to apply the resources",
+	}
+
+	for _, c := range s.Conditions {
+		if c.LastTransitionTime.After(unit.LastUpdateTime.Time) {
+			unit.LastUpdateTime = c.LastTransitionTime
+		}
+	}
+
+	switch failure := workFailure(s); {
+	case s.Available:
+		unit.Phase, unit.Reason, unit.Message = appv1.SubscribedPhase, appv1.ReasonResourcesCurrent, ""
+	case failure != "":
+		unit.Phase, unit.Reason, unit.Message = appv1.FailedPhase, appv1.ReasonResourcesFailed, failure
+	}
+
+	return unit
+}
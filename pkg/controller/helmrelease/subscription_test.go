@@ -0,0 +1,101 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helmrelease
+
+import (
+	"testing"
+
+	"github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+func TestLinkSubscription(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	hr := &appv1.HelmRelease{ObjectMeta: metav1.ObjectMeta{Name: "webapp", Namespace: "default"}}
+	g.Expect(linkSubscription(hr)).To(gomega.BeFalse())
+
+	// the owner Subscription is linked
+	hr.SetOwnerReferences([]metav1.OwnerReference{{
+		APIVersion: appv1.SchemeGroupVersion.String(),
+		Kind:       "Subscription",
+		Name:       "webapp-sub",
+	}})
+	g.Expect(linkSubscription(hr)).To(gomega.BeTrue())
+	g.Expect(hr.GetAnnotations()[hostingSubscriptionAnnotation]).To(gomega.Equal("default/webapp-sub"))
+	g.Expect(hr.GetLabels()).To(gomega.Equal(map[string]string{
+		subscriptionNameLabel:      "webapp-sub",
+		subscriptionNamespaceLabel: "default",
+	}))
+
+	// once
+	g.Expect(linkSubscription(hr)).To(gomega.BeFalse())
+
+	// the hosting Subscription of another namespace is linked too
+	hosted := &appv1.HelmRelease{ObjectMeta: metav1.ObjectMeta{
+		Name:        "webapp",
+		Namespace:   "default",
+		Annotations: map[string]string{hostingSubscriptionAnnotation: "apps/webapp-sub"},
+	}}
+	origin, ok := originSubscription(hosted)
+	g.Expect(ok).To(gomega.BeTrue())
+	g.Expect(origin).To(gomega.Equal(types.NamespacedName{Namespace: "apps", Name: "webapp-sub"}))
+	g.Expect(linkSubscription(hosted)).To(gomega.BeTrue())
+	g.Expect(hosted.GetLabels()[subscriptionNamespaceLabel]).To(gomega.Equal("apps"))
+}
+
+func TestSubscriptionStatus(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	hr := &appv1.HelmRelease{ObjectMeta: metav1.ObjectMeta{Name: "webapp", Namespace: "default"}}
+	g.Expect(subscriptionStatus(hr)).To(gomega.BeNil())
+
+	hr.SetAnnotations(map[string]string{hostingSubscriptionAnnotation: "apps/webapp-sub"})
+
+	status := subscriptionStatus(hr)
+	g.Expect(status.Subscription).To(gomega.Equal("apps/webapp-sub"))
+	g.Expect(status.Phase).To(gomega.Equal(appv1.InProgressPhase))
+
+	hr.Status.SetCondition(appv1.HelmAppCondition{Type: appv1.ConditionReady, Status: appv1.StatusTrue})
+	g.Expect(subscriptionStatus(hr).Phase).To(gomega.Equal(appv1.SubscribedPhase))
+
+	hr.Status.SetCondition(appv1.HelmAppCondition{Type: appv1.ConditionReady, Status: appv1.StatusFalse})
+	hr.Status.SetCondition(appv1.HelmAppCondition{Type: appv1.ConditionReleaseFailed, Status: appv1.StatusTrue})
+	g.Expect(subscriptionStatus(hr).Phase).To(gomega.Equal(appv1.FailedPhase))
+
+	// in hub mode, each cluster has its state
+	hr.Status.RemoveCondition(appv1.ConditionReleaseFailed)
+	hr.Repo.ClusterSelector = &metav1.LabelSelector{}
+	hr.Status.Clusters = []appv1.HelmAppClusterStatus{
+		{Cluster: "cluster1", Applied: true, Available: true},
+		{Cluster: "cluster2", Conditions: []appv1.HelmAppCondition{{
+			Type:    appv1.HelmAppConditionType("Applied"),
+			Status:  appv1.StatusFalse,
+			Message: "forbidden",
+		}}},
+	}
+
+	status = subscriptionStatus(hr)
+	g.Expect(status.Phase).To(gomega.Equal(appv1.FailedPhase))
+	g.Expect(status.Clusters).To(gomega.HaveLen(2))
+	g.Expect(status.Clusters[0].Phase).To(gomega.Equal(appv1.SubscribedPhase))
+	g.Expect(status.Clusters[1].Cluster).To(gomega.Equal("cluster2"))
+	g.Expect(status.Clusters[1].Message).To(gomega.Equal("forbidden"))
+}
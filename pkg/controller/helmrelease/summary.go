@@ -0,0 +1,207 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helmrelease
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/types"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+// SummaryPath is the path of the fleet summary served with the metrics
+const SummaryPath = "/helmreleases/summary"
+
+// summaryTopN is the number of HelmReleases listed as failing and unready
+const summaryTopN = 10
+
+var (
+	namespaceReleasesDesc = prometheus.NewDesc("helmrelease_namespace_releases",
+		"Number of HelmReleases of each namespace by state.", []string{"namespace", "state"}, nil)
+
+	// releaseSummaries are the last recorded summaries of the HelmReleases
+	releaseSummaries   = map[types.NamespacedName]releaseSummary{}
+	releaseSummariesMu sync.Mutex
+)
+
+// releaseSummary is the state of a HelmRelease in the fleet summary
+type releaseSummary struct {
+	// HelmRelease is the namespace/name of the HelmRelease
+	HelmRelease string `json:"helmRelease"`
+	State       string `json:"state"`
+	Failures    int    `json:"failures,omitempty"`
+	Reason      string `json:"reason,omitempty"`
+	Message     string `json:"message,omitempty"`
+	// NotReadySince is when the HelmRelease last stopped being ready
+	NotReadySince *time.Time `json:"notReadySince,omitempty"`
+
+	namespace string
+}
+
+// fleetSummary is the health view of the HelmReleases reconciled by the
+// operator
+type fleetSummary struct {
+	Time          time.Time                 `json:"time"`
+	Total         int                       `json:"total"`
+	Namespaces    map[string]map[string]int `json:"namespaces"`
+	TopFailing    []releaseSummary          `json:"topFailing"`
+	OldestUnready []releaseSummary          `json:"oldestUnready"`
+}
+
+// recordSummary records the state of hr in the fleet summary.
+func recordSummary(hr *appv1.HelmRelease, state string) {
+	s := releaseSummary{
+		HelmRelease: hr.GetNamespace() + "/" + hr.GetName(),
+		State:       state,
+		Failures:    hr.Status.Failures,
+		namespace:   hr.GetNamespace(),
+	}
+
+	if state != stateReady {
+		if ready := hr.Status.GetCondition(appv1.ConditionReady); ready != nil {
+			since := ready.LastTransitionTime.Time
+			s.NotReadySince = &since
+			s.Reason = string(ready.Reason)
+			s.Message = ready.Message
+		}
+	}
+
+	releaseSummariesMu.Lock()
+	defer releaseSummariesMu.Unlock()
+
+	releaseSummaries[types.NamespacedName{Namespace: hr.GetNamespace(), Name: hr.GetName()}] = s
+}
+
+// forgetSummary removes the HelmRelease name from the fleet summary.
+func forgetSummary(name types.NamespacedName) {
+	releaseSummariesMu.Lock()
+	defer releaseSummariesMu.Unlock()
+
+	delete(releaseSummaries, name)
+}
+
+// summarize returns the fleet summary of the recorded HelmReleases.
+func summarize(now time.Time) fleetSummary {
+	releaseSummariesMu.Lock()
+	defer releaseSummariesMu.Unlock()
+
+	summary := fleetSummary{
+		Time:          now,
+		Total:         len(releaseSummaries),
+		Namespaces:    map[string]map[string]int{},
+		TopFailing:    []releaseSummary{},
+		OldestUnready: []releaseSummary{},
+	}
+
+	for _, s := range releaseSummaries {
+		if summary.Namespaces[s.namespace] == nil {
+			summary.Namespaces[s.namespace] = map[string]int{}
+		}
+
+		summary.Namespaces[s.namespace][s.State]++
+
+		if s.Failures > 0 {
+			summary.TopFailing = append(summary.TopFailing, s)
+		}
+
+		if s.NotReadySince != nil && s.State != stateSuspended {
+			summary.OldestUnready = append(summary.OldestUnready, s)
+		}
+	}
+
+	// the names break the ties so that the summary is stable
+	sort.Slice(summary.TopFailing, func(i, j int) bool {
+		a, b := summary.TopFailing[i], summary.TopFailing[j]
+		if a.Failures != b.Failures {
+			return a.Failures > b.Failures
+		}
+
+		return a.HelmRelease < b.HelmRelease
+	})
+
+	sort.Slice(summary.OldestUnready, func(i, j int) bool {
+		a, b := summary.OldestUnready[i], summary.OldestUnready[j]
+		if !a.NotReadySince.Equal(*b.NotReadySince) {
+			return a.NotReadySince.Before(*b.NotReadySince)
+		}
+
+		return a.HelmRelease < b.HelmRelease
+	})
+
+	if len(summary.TopFailing) > summaryTopN {
+		summary.TopFailing = summary.TopFailing[:summaryTopN]
+	}
+
+	if len(summary.OldestUnready) > summaryTopN {
+		summary.OldestUnready = summary.OldestUnready[:summaryTopN]
+	}
+
+	return summary
+}
+
+// ReleaseCounts returns the number of HelmReleases reconciled by the operator
+// by state: Ready, Failed, Progressing and Suspended.
+func ReleaseCounts() map[string]int {
+	counts := map[string]int{stateReady: 0, stateFailed: 0, stateProgressing: 0, stateSuspended: 0}
+
+	for _, states := range summarize(time.Now()).Namespaces {
+		for state, count := range states {
+			counts[state] += count
+		}
+	}
+
+	return counts
+}
+
+// summaryCollector publishes the helmrelease_namespace_releases metric,
+// computed from the fleet summary when scraped.
+type summaryCollector struct{}
+
+func (summaryCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- namespaceReleasesDesc
+}
+
+func (summaryCollector) Collect(ch chan<- prometheus.Metric) {
+	for namespace, states := range summarize(time.Now()).Namespaces {
+		for state, count := range states {
+			ch <- prometheus.MustNewConstMetric(namespaceReleasesDesc, prometheus.GaugeValue, float64(count), namespace, state)
+		}
+	}
+}
+
+// SummaryHandler serves the fleet summary of the HelmReleases reconciled by the
+// operator as JSON: their number by namespace and state, the ones failing the
+// most and the ones unready for the longest.
+func SummaryHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", "GET")
+			http.Error(w, "only GET is supported", http.StatusMethodNotAllowed)
+
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(summarize(time.Now().UTC()))
+	})
+}
@@ -0,0 +1,98 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helmrelease
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+func TestSummarize(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	notReady := func(name string, failures int, since time.Time) *appv1.HelmRelease {
+		hr := &appv1.HelmRelease{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "summary"}}
+		hr.Status.Failures = failures
+		hr.Status.SetCondition(appv1.HelmAppCondition{Type: appv1.ConditionReady, Status: appv1.StatusFalse,
+			Reason: appv1.ReasonInstallError, Message: "install failed"})
+		hr.Status.GetCondition(appv1.ConditionReady).LastTransitionTime = metav1.NewTime(since)
+
+		return hr
+	}
+
+	now := time.Now()
+	ready := &appv1.HelmRelease{ObjectMeta: metav1.ObjectMeta{Name: "ready", Namespace: "summary"}}
+
+	for hr, state := range map[*appv1.HelmRelease]string{
+		ready: stateReady,
+		notReady("failing", 3, now.Add(-time.Minute)):    stateFailed,
+		notReady("flaky", 1, now.Add(-time.Hour)):        stateFailed,
+		notReady("suspended", 0, now.Add(-48*time.Hour)): stateSuspended,
+	} {
+		recordSummary(hr, state)
+	}
+
+	defer func() {
+		for _, name := range []string{"ready", "failing", "flaky", "suspended"} {
+			forgetSummary(types.NamespacedName{Namespace: "summary", Name: name})
+		}
+	}()
+
+	summary := summarize(now)
+	counts := ReleaseCounts()
+	g.Expect(counts[stateFailed]).To(gomega.BeNumerically(">=", 2))
+	g.Expect(counts).To(gomega.HaveKey(stateProgressing))
+
+	g.Expect(summary.Namespaces["summary"]).To(gomega.Equal(map[string]int{stateReady: 1, stateFailed: 2, stateSuspended: 1}))
+
+	// the ones failing the most come first, the suspended ones are not unready
+	var failing, unready []string
+
+	for _, s := range summary.TopFailing {
+		failing = append(failing, s.HelmRelease)
+	}
+
+	for _, s := range summary.OldestUnready {
+		unready = append(unready, s.HelmRelease)
+	}
+
+	g.Expect(failing).To(gomega.Equal([]string{"summary/failing", "summary/flaky"}))
+	g.Expect(unready).To(gomega.Equal([]string{"summary/flaky", "summary/failing"}))
+	g.Expect(summary.TopFailing[0].Reason).To(gomega.Equal(string(appv1.ReasonInstallError)))
+
+	// the summary is served as JSON
+	w := httptest.NewRecorder()
+	SummaryHandler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, SummaryPath, nil))
+	g.Expect(w.Code).To(gomega.Equal(http.StatusOK))
+
+	served := fleetSummary{}
+	g.Expect(json.Unmarshal(w.Body.Bytes(), &served)).To(gomega.Succeed())
+	g.Expect(served.Namespaces["summary"]).To(gomega.Equal(summary.Namespaces["summary"]))
+
+	w = httptest.NewRecorder()
+	SummaryHandler().ServeHTTP(w, httptest.NewRequest(http.MethodPost, SummaryPath, nil))
+	g.Expect(w.Code).To(gomega.Equal(http.StatusMethodNotAllowed))
+}
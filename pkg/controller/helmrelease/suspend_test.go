@@ -0,0 +1,85 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helmrelease
+
+import (
+	"context"
+	"testing"
+
+	"github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+func TestReconcileSuspended(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	mgr, err := manager.New(cfg, manager.Options{MetricsBindAddress: "0"})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	stopMgr, mgrStopped := StartTestManager(mgr, g)
+
+	defer func() {
+		close(stopMgr)
+		mgrStopped.Wait()
+	}()
+
+	c := mgr.GetClient()
+	key := types.NamespacedName{Name: "suspended", Namespace: helmReleaseNS}
+	hr := &appv1.HelmRelease{
+		ObjectMeta: metav1.ObjectMeta{Name: key.Name, Namespace: key.Namespace},
+		Repo: appv1.HelmReleaseRepo{
+			Source: &appv1.Source{
+				SourceType: appv1.HelmRepoSourceType,
+				HelmRepo:   &appv1.HelmRepo{Urls: []string{"https://charts.example.com/suspended-1.0.0.tgz"}},
+			},
+			ChartName: "suspended",
+			Suspend:   true,
+		},
+	}
+	g.Expect(c.Create(context.TODO(), hr)).To(gomega.Succeed())
+
+	defer c.Delete(context.TODO(), hr)
+
+	g.Eventually(func() error { return c.Get(context.TODO(), key, &appv1.HelmRelease{}) }).Should(gomega.Succeed())
+
+	r := &ReconcileHelmRelease{mgr}
+
+	// nothing is downloaded or installed
+	res, err := r.Reconcile(reconcile.Request{NamespacedName: key})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(res).To(gomega.Equal(reconcile.Result{}))
+
+	g.Eventually(func() *appv1.HelmAppCondition {
+		got := &appv1.HelmRelease{}
+		g.Expect(c.Get(context.TODO(), key, got)).To(gomega.Succeed())
+		g.Expect(got.Status.DeployedRelease).To(gomega.BeNil())
+		g.Expect(got.GetFinalizers()).To(gomega.BeEmpty())
+
+		for i := range got.Status.Conditions {
+			if got.Status.Conditions[i].Type == appv1.ConditionSuspended {
+				return &got.Status.Conditions[i]
+			}
+		}
+
+		return nil
+	}).ShouldNot(gomega.BeNil())
+}
@@ -0,0 +1,163 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helmrelease
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+// allowedNamespacesAnnotation lists the comma separated namespaces whose
+// HelmReleases may deploy to the annotated namespace, * allows all of them.
+const allowedNamespacesAnnotation = "apps.open-cluster-management.io/allowed-helmrelease-namespaces"
+
+// targetNamespace returns the namespace the chart of hr is deployed to.
+func targetNamespace(hr *appv1.HelmRelease) string {
+	if hr.Repo.TargetNamespace != "" {
+		return hr.Repo.TargetNamespace
+	}
+
+	return hr.GetNamespace()
+}
+
+// checkTargetNamespace returns an error if hr deploys to another namespace
+// that does not allow the HelmReleases of its namespace. The operator can
+// deploy anywhere, so without this check any tenant able to create a
+// HelmRelease could deploy into any namespace.
+func (r *ReconcileHelmRelease) checkTargetNamespace(hr *appv1.HelmRelease) error {
+	// the namespaces of a remote cluster are bounded by its kubeconfig, the
+	// ManifestWorks are applied to the managed clusters
+	target := targetNamespace(hr)
+	if target == hr.GetNamespace() || hr.Repo.KubeConfig != nil || hubMode(hr) {
+		return nil
+	}
+
+	// the namespaces cannot be read in namespace-scoped mode, the allowed
+	// target namespaces are checked by checkNamespaceScope
+	if Options.NamespaceScoped {
+		return nil
+	}
+
+	ns := &corev1.Namespace{}
+
+	err := r.GetAPIReader().Get(context.TODO(), types.NamespacedName{Name: target}, ns)
+	if apierrors.IsNotFound(err) && hr.Repo.CreateNamespace {
+		// the namespace is created for the HelmRelease
+		return nil
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to get target namespace %s: %w", target, err)
+	}
+
+	for _, allowed := range strings.Split(ns.GetAnnotations()[allowedNamespacesAnnotation], ",") {
+		if allowed = strings.TrimSpace(allowed); allowed == "*" || allowed == hr.GetNamespace() {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("target namespace %s does not allow the HelmReleases of namespace %s in its %s annotation",
+		target, hr.GetNamespace(), allowedNamespacesAnnotation)
+}
+
+// ensureTargetNamespace creates the target namespace of hr if it does not
+// exist, and sets its labels and annotations, when CreateNamespace is set.
+func (r *ReconcileHelmRelease) ensureTargetNamespace(hr *appv1.HelmRelease) error {
+	if !hr.Repo.CreateNamespace {
+		return nil
+	}
+
+	c, err := r.clusterClient(hr)
+	if err != nil {
+		return err
+	}
+
+	target := targetNamespace(hr)
+	ns := &corev1.Namespace{}
+
+	err = c.Get(context.TODO(), types.NamespacedName{Name: target}, ns)
+	if apierrors.IsNotFound(err) {
+		ns = &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: target}}
+		if target != hr.GetNamespace() {
+			ns.SetAnnotations(map[string]string{allowedNamespacesAnnotation: hr.GetNamespace()})
+		}
+
+		setNamespaceMetadata(ns, hr.Repo.NamespaceMetadata)
+
+		logFor(hr).Info("Creating target namespace", "namespace", target)
+
+		return c.Create(context.TODO(), ns)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if !setNamespaceMetadata(ns, hr.Repo.NamespaceMetadata) {
+		return nil
+	}
+
+	logFor(hr).Info("Updating the metadata of target namespace", "namespace", target)
+
+	return c.Update(context.TODO(), ns)
+}
+
+// setNamespaceMetadata adds the labels and annotations of metadata to ns and
+// returns true if any changed. The allowed namespaces are not set, a
+// HelmRelease must not let other namespaces deploy to its target namespace.
+func setNamespaceMetadata(ns *corev1.Namespace, metadata *appv1.NamespaceMetadata) bool {
+	if metadata == nil {
+		return false
+	}
+
+	changed := false
+
+	labels := ns.GetLabels()
+	if labels == nil {
+		labels = make(map[string]string)
+	}
+
+	for k, v := range metadata.Labels {
+		if labels[k] != v {
+			labels[k], changed = v, true
+		}
+	}
+
+	annotations := ns.GetAnnotations()
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+
+	for k, v := range metadata.Annotations {
+		if k != allowedNamespacesAnnotation && annotations[k] != v {
+			annotations[k], changed = v, true
+		}
+	}
+
+	ns.SetLabels(labels)
+	ns.SetAnnotations(annotations)
+
+	return changed
+}
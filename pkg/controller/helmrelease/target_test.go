@@ -0,0 +1,89 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helmrelease
+
+import (
+	"context"
+	"testing"
+
+	"github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+func TestCheckTargetNamespace(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	mgr, err := manager.New(cfg, manager.Options{MetricsBindAddress: "0"})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	r := &ReconcileHelmRelease{mgr}
+
+	target := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+		Name:        "target-check",
+		Annotations: map[string]string{allowedNamespacesAnnotation: "team-a, " + helmReleaseNS},
+	}}
+	g.Expect(mgr.GetClient().Create(context.TODO(), target)).To(gomega.Succeed())
+
+	defer mgr.GetClient().Delete(context.TODO(), target)
+
+	hr := &appv1.HelmRelease{ObjectMeta: metav1.ObjectMeta{Name: "target-check", Namespace: helmReleaseNS}}
+
+	// the namespace of the HelmRelease is always allowed
+	g.Expect(targetNamespace(hr)).To(gomega.Equal(helmReleaseNS))
+	g.Expect(r.checkTargetNamespace(hr)).To(gomega.Succeed())
+
+	hr.Repo.TargetNamespace = target.GetName()
+	g.Expect(targetNamespace(hr)).To(gomega.Equal(target.GetName()))
+	g.Expect(r.checkTargetNamespace(hr)).To(gomega.Succeed())
+
+	// the HelmReleases of other namespaces are not
+	hr.SetNamespace("team-b")
+	g.Expect(r.checkTargetNamespace(hr)).To(gomega.MatchError(gomega.ContainSubstring("does not allow")))
+
+	hr.Repo.TargetNamespace = "target-check-missing"
+	g.Expect(r.checkTargetNamespace(hr)).To(gomega.MatchError(gomega.ContainSubstring("failed to get")))
+
+	// unless it is created for the HelmRelease
+	hr.Repo.CreateNamespace = true
+	g.Expect(r.checkTargetNamespace(hr)).To(gomega.Succeed())
+}
+
+func TestSetNamespaceMetadata(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+		Labels:      map[string]string{"team": "a"},
+		Annotations: map[string]string{allowedNamespacesAnnotation: "team-a"},
+	}}
+	g.Expect(setNamespaceMetadata(ns, nil)).To(gomega.BeFalse())
+
+	metadata := &appv1.NamespaceMetadata{
+		Labels:      map[string]string{"istio-injection": "enabled"},
+		Annotations: map[string]string{allowedNamespacesAnnotation: "*", "owner": "team-a"},
+	}
+	g.Expect(setNamespaceMetadata(ns, metadata)).To(gomega.BeTrue())
+	g.Expect(ns.GetLabels()).To(gomega.Equal(map[string]string{"team": "a", "istio-injection": "enabled"}))
+
+	// the allowed namespaces are never widened by the HelmRelease
+	g.Expect(ns.GetAnnotations()).To(gomega.Equal(map[string]string{allowedNamespacesAnnotation: "team-a", "owner": "team-a"}))
+
+	g.Expect(setNamespaceMetadata(ns, metadata)).To(gomega.BeFalse())
+}
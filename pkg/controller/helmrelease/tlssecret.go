@@ -0,0 +1,169 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helmrelease
+
+import (
+	"bytes"
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/types"
+	coreinformers "k8s.io/client-go/informers/core/v1"
+	"k8s.io/client-go/kubernetes"
+	toolscache "k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+// tlsSecretSelector selects the Secrets of type kubernetes.io/tls, those
+// issued by cert-manager among others
+var tlsSecretSelector = fields.OneTermEqualSelector("type", string(corev1.SecretTypeTLS)).String()
+
+// watchTLSSecrets reconciles the HelmReleases referencing a TLS Secret as
+// soon as its certificate changes, e.g. rotated by cert-manager, so that a
+// download failing with the expired certificate is retried right away. The
+// Secrets are read at each download, the rotated certificates are used
+// without the watch too. Only the Secrets of type kubernetes.io/tls of the
+// watched namespaces are watched, by their own informers instead of the cache
+// of the manager, which would hold every Secret of the cluster.
+func watchTLSSecrets(mgr manager.Manager, c controller.Controller) error {
+	cs, err := kubernetes.NewForConfig(mgr.GetConfig())
+	if err != nil {
+		return err
+	}
+
+	namespaces := Options.WatchNamespaces
+	if len(namespaces) == 0 {
+		namespaces = []string{metav1.NamespaceAll}
+	}
+
+	informers := make(informerRunner, 0, len(namespaces))
+
+	for _, namespace := range namespaces {
+		informer := coreinformers.NewFilteredSecretInformer(cs, namespace, 0, toolscache.Indexers{},
+			func(options *metav1.ListOptions) {
+				options.FieldSelector = tlsSecretSelector
+			})
+
+		err := c.Watch(&source.Informer{Informer: informer}, &handler.EnqueueRequestsFromMapFunc{
+			ToRequests: tlsSecretMapper{mgr.GetClient()},
+		}, certificateChangedPredicate{})
+		if err != nil {
+			return err
+		}
+
+		informers = append(informers, informer)
+	}
+
+	return mgr.Add(informers)
+}
+
+// informerRunner runs informers until the manager stops
+type informerRunner []toolscache.SharedIndexInformer
+
+func (r informerRunner) Start(stop <-chan struct{}) error {
+	for _, informer := range r {
+		go informer.Run(stop)
+	}
+
+	<-stop
+
+	return nil
+}
+
+// tlsSecretMapper maps a Secret to the HelmReleases referencing it as their
+// TLS Secret.
+type tlsSecretMapper struct {
+	client client.Client
+}
+
+func (m tlsSecretMapper) Map(obj handler.MapObject) []reconcile.Request {
+	hrs := &appv1.HelmReleaseList{}
+	if err := m.client.List(context.TODO(), hrs); err != nil {
+		log.Error(err, "Failed to list the HelmReleases of TLS Secret", "secret", obj.Meta.GetNamespace()+"/"+obj.Meta.GetName())
+		return nil
+	}
+
+	var requests []reconcile.Request
+
+	for i := range hrs.Items {
+		hr := &hrs.Items[i]
+
+		ref := hr.Repo.TLSSecretRef
+		if ref == nil || ref.Name != obj.Meta.GetName() || !inShard(hr) {
+			continue
+		}
+
+		namespace := ref.Namespace
+		if namespace == "" {
+			namespace = hr.GetNamespace()
+		}
+
+		if namespace == obj.Meta.GetNamespace() {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{Namespace: hr.GetNamespace(), Name: hr.GetName()},
+			})
+		}
+	}
+
+	return requests
+}
+
+// certificateChangedPredicate only passes the updates of the Secrets holding
+// a certificate whose certificate changed, the other updates, e.g. of the
+// annotations, do not fix a download.
+type certificateChangedPredicate struct {
+	predicate.Funcs
+}
+
+func (certificateChangedPredicate) Create(e event.CreateEvent) bool {
+	return false
+}
+
+func (certificateChangedPredicate) Delete(e event.DeleteEvent) bool {
+	return false
+}
+
+func (certificateChangedPredicate) Generic(e event.GenericEvent) bool {
+	return false
+}
+
+func (certificateChangedPredicate) Update(e event.UpdateEvent) bool {
+	oldSecret, ok := e.ObjectOld.(*corev1.Secret)
+	if !ok {
+		return false
+	}
+
+	newSecret, ok := e.ObjectNew.(*corev1.Secret)
+	if !ok {
+		return false
+	}
+
+	cert := newSecret.Data[corev1.TLSCertKey]
+
+	return len(cert) != 0 && !bytes.Equal(oldSecret.Data[corev1.TLSCertKey], cert)
+}
@@ -0,0 +1,76 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helmrelease
+
+import (
+	"testing"
+
+	"github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+func TestCertificateChangedPredicate(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	newSecret := func(cert string) *corev1.Secret {
+		return &corev1.Secret{Data: map[string][]byte{corev1.TLSCertKey: []byte(cert)}}
+	}
+
+	p := certificateChangedPredicate{}
+
+	g.Expect(p.Update(event.UpdateEvent{ObjectOld: newSecret("old"), ObjectNew: newSecret("new")})).To(gomega.BeTrue())
+	g.Expect(p.Update(event.UpdateEvent{ObjectOld: newSecret("old"), ObjectNew: newSecret("old")})).To(gomega.BeFalse())
+	g.Expect(p.Update(event.UpdateEvent{ObjectOld: &corev1.Secret{}, ObjectNew: &corev1.Secret{}})).To(gomega.BeFalse())
+	g.Expect(p.Create(event.CreateEvent{Object: newSecret("new")})).To(gomega.BeFalse())
+}
+
+func TestTLSSecretMapper(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	newHelmRelease := func(name, namespace string, ref *corev1.ObjectReference) *appv1.HelmRelease {
+		hr := &appv1.HelmRelease{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace}}
+		hr.Repo.TLSSecretRef = ref
+
+		return hr
+	}
+
+	s := scheme.Scheme
+	g.Expect(appv1.SchemeBuilder.AddToScheme(s)).To(gomega.Succeed())
+
+	m := tlsSecretMapper{fake.NewFakeClientWithScheme(s,
+		newHelmRelease("same-namespace", "default", &corev1.ObjectReference{Name: "repo-tls"}),
+		newHelmRelease("other-namespace", "team-a", &corev1.ObjectReference{Name: "repo-tls", Namespace: "default"}),
+		newHelmRelease("other-secret", "default", &corev1.ObjectReference{Name: "other-tls"}),
+		newHelmRelease("no-secret", "default", nil),
+		newHelmRelease("local-secret", "team-b", &corev1.ObjectReference{Name: "repo-tls"}))}
+
+	secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "repo-tls", Namespace: "default"}}
+
+	g.Expect(m.Map(handler.MapObject{Meta: secret, Object: secret})).To(gomega.ConsistOf(
+		reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: "same-namespace"}},
+		reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "team-a", Name: "other-namespace"}},
+	))
+}
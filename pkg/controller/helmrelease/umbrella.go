@@ -0,0 +1,436 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helmrelease
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"helm.sh/helm/v3/pkg/chartutil"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+// umbrellaLabel labels the HelmReleases of the charts of an umbrella
+// HelmRelease with its name
+const umbrellaLabel = "apps.open-cluster-management.io/umbrella-helmrelease"
+
+// umbrellaUninstallInterval is how often an umbrella HelmRelease being
+// deleted checks that the HelmRelease of its last chart is gone
+const umbrellaUninstallInterval = 10 * time.Second
+
+// isUmbrella returns true if hr deploys several charts, each with its own
+// HelmRelease, instead of a release of its own.
+func isUmbrella(hr *appv1.HelmRelease) bool {
+	return len(hr.Repo.Charts) != 0
+}
+
+// watchUmbrellaCharts reconciles the umbrella HelmReleases as soon as the
+// HelmRelease of one of their charts changes, so that the next chart is
+// deployed once the previous one is ready.
+func watchUmbrellaCharts(c controller.Controller) error {
+	return c.Watch(&source.Kind{Type: &appv1.HelmRelease{}}, &handler.EnqueueRequestForOwner{
+		OwnerType:    &appv1.HelmRelease{},
+		IsController: true,
+	}, shardPredicate{})
+}
+
+// reconcileUmbrella creates or updates the HelmReleases of the charts of hr,
+// deletes the ones of the removed charts, and reports their state. The
+// HelmRelease of each chart depends on the one of the previous chart, so that
+// they are installed and upgraded in order.
+func (r *ReconcileHelmRelease) reconcileUmbrella(hr *appv1.HelmRelease) (reconcile.Result, error) {
+	if hr.GetDeletionTimestamp() != nil {
+		return r.uninstallUmbrella(hr)
+	}
+
+	if !contains(hr.GetFinalizers(), finalizer) {
+		logFor(hr).V(1).Info("Adding finalizer", "finalizer", finalizer)
+		controllerutil.AddFinalizer(hr, finalizer)
+
+		if err := r.updateResource(hr); err != nil {
+			logFor(hr).Error(err, "Failed to add the uninstall finalizer")
+			return reconcile.Result{}, err
+		}
+	}
+
+	// the HelmReleases of the charts are suspended with it
+	if hr.Repo.Suspend {
+		hr.Status.SetCondition(appv1.HelmAppCondition{
+			Type:    appv1.ConditionSuspended,
+			Status:  appv1.StatusTrue,
+			Reason:  appv1.ReasonSuspended,
+			Message: "Reconciliation of the charts is suspended",
+		})
+	} else {
+		hr.Status.RemoveCondition(appv1.ConditionSuspended)
+	}
+
+	children, err := r.umbrellaChildren(hr)
+	if err == nil {
+		hr.Status.Charts, err = r.applyUmbrellaCharts(hr, children)
+	}
+
+	if err != nil {
+		logFor(hr).Error(err, "Failed to apply the HelmReleases of the charts")
+
+		hr.Status.SetCondition(appv1.HelmAppCondition{
+			Type:    appv1.ConditionIrreconcilable,
+			Status:  appv1.StatusTrue,
+			Reason:  appv1.ReasonReconcileError,
+			Message: err.Error(),
+		})
+		delay := retryAfter(hr)
+		_ = r.updateResourceStatus(hr)
+
+		return reconcile.Result{RequeueAfter: delay}, nil
+	}
+
+	hr.Status.RemoveCondition(appv1.ConditionIrreconcilable)
+	hr.Status.SetCondition(umbrellaDeployedCondition(hr.Status.Charts))
+	resetRetries(hr)
+	hr.Status.ObservedGeneration = hr.GetGeneration()
+
+	return reconcile.Result{RequeueAfter: reconcileInterval(hr)}, r.updateResourceStatus(hr)
+}
+
+// applyUmbrellaCharts applies the HelmRelease of each chart of hr, deletes
+// the children of the removed charts and returns the state of the charts.
+func (r *ReconcileHelmRelease) applyUmbrellaCharts(hr *appv1.HelmRelease,
+	children map[string]*appv1.HelmRelease) ([]appv1.HelmAppChartStatus, error) {
+	statuses := make([]appv1.HelmAppChartStatus, 0, len(hr.Repo.Charts))
+	previous := ""
+
+	for _, chart := range hr.Repo.Charts {
+		desired, err := umbrellaChartRelease(hr, chart, previous)
+		if err != nil {
+			return nil, err
+		}
+
+		child, err := r.applyChartRelease(hr, desired, children[desired.GetName()])
+		if err != nil {
+			return nil, err
+		}
+
+		delete(children, desired.GetName())
+
+		statuses = append(statuses, chartStatus(chart.Name, child))
+		previous = desired.GetName()
+	}
+
+	// the releases of the removed charts are uninstalled
+	for _, child := range children {
+		if child.GetDeletionTimestamp() != nil {
+			continue
+		}
+
+		logFor(hr).Info("Deleting the HelmRelease of a removed chart", "chart", child.GetName())
+
+		if err := r.GetClient().Delete(context.TODO(), child); err != nil && !apierrors.IsNotFound(err) {
+			return nil, fmt.Errorf("failed to delete HelmRelease %s: %w", child.GetName(), err)
+		}
+	}
+
+	return statuses, nil
+}
+
+// uninstallUmbrella deletes the HelmReleases of the charts of hr in the
+// reverse order of the charts, each one once the next one is gone, then
+// removes the finalizer of hr.
+func (r *ReconcileHelmRelease) uninstallUmbrella(hr *appv1.HelmRelease) (reconcile.Result, error) {
+	if !contains(hr.GetFinalizers(), finalizer) {
+		return reconcile.Result{}, nil
+	}
+
+	children, err := r.umbrellaChildren(hr)
+	if err != nil {
+		logFor(hr).Error(err, "Failed to list the HelmReleases of the charts")
+		return reconcile.Result{}, err
+	}
+
+	// the children of the removed charts go first
+	order := make([]*appv1.HelmRelease, 0, len(children))
+	for i := len(hr.Repo.Charts) - 1; i >= 0; i-- {
+		name := chartReleaseName(hr, hr.Repo.Charts[i])
+		if child, ok := children[name]; ok {
+			order = append(order, child)
+			delete(children, name)
+		}
+	}
+
+	for _, child := range children {
+		order = append([]*appv1.HelmRelease{child}, order...)
+	}
+
+	if len(order) != 0 {
+		child := order[0]
+
+		if child.GetDeletionTimestamp() == nil {
+			logFor(hr).Info("Deleting the HelmRelease of a chart", "chart", child.GetName())
+
+			if err := r.GetClient().Delete(context.TODO(), child); err != nil && !apierrors.IsNotFound(err) {
+				logFor(hr).Error(err, "Failed to delete the HelmRelease of a chart", "chart", child.GetName())
+				return reconcile.Result{}, err
+			}
+		}
+
+		hr.Status.SetCondition(appv1.HelmAppCondition{
+			Type:    appv1.ConditionDeployed,
+			Status:  appv1.StatusFalse,
+			Reason:  appv1.ReasonChartNotReady,
+			Message: fmt.Sprintf("Uninstalling HelmRelease %s", child.GetName()),
+		})
+		_ = r.updateResourceStatus(hr)
+
+		return reconcile.Result{RequeueAfter: umbrellaUninstallInterval}, nil
+	}
+
+	logFor(hr).Info("Uninstalled the charts")
+
+	controllerutil.RemoveFinalizer(hr, finalizer)
+
+	if err := r.updateResource(hr); err != nil {
+		logFor(hr).Error(err, "Failed to strip HelmRelease uninstall finalizer")
+		return reconcile.Result{}, err
+	}
+
+	return reconcile.Result{}, nil
+}
+
+// umbrellaChildren returns the HelmReleases of the charts of hr by name. They
+// are read from the API server, the ones just created may not be cached yet.
+func (r *ReconcileHelmRelease) umbrellaChildren(hr *appv1.HelmRelease) (map[string]*appv1.HelmRelease, error) {
+	list := &appv1.HelmReleaseList{}
+	if err := r.GetAPIReader().List(context.TODO(), list, client.InNamespace(hr.GetNamespace()),
+		client.MatchingLabels{umbrellaLabel: hr.GetName()}); err != nil {
+		return nil, fmt.Errorf("failed to list the HelmReleases of the charts: %w", err)
+	}
+
+	children := make(map[string]*appv1.HelmRelease, len(list.Items))
+
+	for i := range list.Items {
+		child := &list.Items[i]
+		if metav1.IsControlledBy(child, hr) {
+			children[child.GetName()] = child
+		}
+	}
+
+	return children, nil
+}
+
+// applyChartRelease creates the HelmRelease of a chart of hr, or updates the
+// existing one if it changed, and returns it.
+func (r *ReconcileHelmRelease) applyChartRelease(hr, desired, existing *appv1.HelmRelease) (*appv1.HelmRelease, error) {
+	if existing == nil {
+		if err := controllerutil.SetControllerReference(hr, desired, r.GetScheme()); err != nil {
+			return nil, err
+		}
+
+		logFor(hr).Info("Creating the HelmRelease of a chart", "chart", desired.GetName())
+
+		err := r.GetClient().Create(context.TODO(), desired)
+		if apierrors.IsAlreadyExists(err) {
+			return nil, fmt.Errorf("HelmRelease %s already exists and does not belong to this HelmRelease", desired.GetName())
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to create HelmRelease %s: %w", desired.GetName(), err)
+		}
+
+		return desired, nil
+	}
+
+	if sameJSON(existing.Repo, desired.Repo) && sameJSON(existing.Spec, desired.Spec) &&
+		sameJSON(existing.GetLabels(), desired.GetLabels()) {
+		return existing, nil
+	}
+
+	logFor(hr).Info("Updating the HelmRelease of a chart", "chart", existing.GetName())
+
+	existing.Repo = desired.Repo
+	existing.Spec = desired.Spec
+	existing.SetLabels(desired.GetLabels())
+
+	if err := r.GetClient().Update(context.TODO(), existing); err != nil {
+		return nil, fmt.Errorf("failed to update HelmRelease %s: %w", existing.GetName(), err)
+	}
+
+	return existing, nil
+}
+
+// chartReleaseName is the name of the HelmRelease of a chart of hr.
+func chartReleaseName(hr *appv1.HelmRelease, chart appv1.UmbrellaChart) string {
+	return hr.GetName() + "-" + chart.Name
+}
+
+// umbrellaChartRelease returns the HelmRelease of chart, a chart of hr: the
+// repo settings of hr with the chart, and the shared values of hr with the
+// values of the chart merged. It depends on previous, the HelmRelease of the
+// previous chart.
+func umbrellaChartRelease(hr *appv1.HelmRelease, chart appv1.UmbrellaChart, previous string) (*appv1.HelmRelease, error) {
+	repo := hr.Repo.DeepCopy()
+
+	// the names are the ones of the HelmRelease of the chart
+	repo.Charts = nil
+	repo.ReleaseName = ""
+	repo.ClusterReleaseName = ""
+
+	repo.ChartName = chart.ChartName
+	repo.Version = chart.Version
+
+	if chart.Source != nil {
+		repo.Source = chart.Source.DeepCopy()
+	}
+
+	if chart.TargetNamespace != "" {
+		repo.TargetNamespace = chart.TargetNamespace
+	}
+
+	if previous != "" {
+		repo.DependsOn = append(repo.DependsOn, appv1.DependencyReference{Name: previous})
+	}
+
+	values, err := chartValues(hr, chart)
+	if err != nil {
+		return nil, err
+	}
+
+	labels := make(map[string]string, len(hr.GetLabels())+1)
+	for k, v := range hr.GetLabels() {
+		labels[k] = v
+	}
+
+	// the Subscription of hr lists it, not the HelmReleases of its charts
+	delete(labels, subscriptionNameLabel)
+	delete(labels, subscriptionNamespaceLabel)
+
+	labels[umbrellaLabel] = hr.GetName()
+
+	return &appv1.HelmRelease{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      chartReleaseName(hr, chart),
+			Namespace: hr.GetNamespace(),
+			Labels:    labels,
+		},
+		Repo: *repo,
+		Spec: values,
+	}, nil
+}
+
+// chartValues returns the shared values of hr with the values of chart
+// merged, overriding them.
+func chartValues(hr *appv1.HelmRelease, chart appv1.UmbrellaChart) (map[string]interface{}, error) {
+	// a copy, the values of hr are shared by all its charts
+	raw, err := json.Marshal(hr.Spec)
+	if err != nil {
+		return nil, err
+	}
+
+	var shared map[string]interface{}
+	if err := json.Unmarshal(raw, &shared); err != nil {
+		return nil, fmt.Errorf("failed to parse the values: %w", err)
+	}
+
+	if len(chart.Values.Raw) == 0 {
+		return shared, nil
+	}
+
+	var values map[string]interface{}
+	if err := json.Unmarshal(chart.Values.Raw, &values); err != nil {
+		return nil, fmt.Errorf("failed to parse the values of chart %s: %w", chart.Name, err)
+	}
+
+	if shared == nil {
+		return values, nil
+	}
+
+	return chartutil.CoalesceTables(values, shared), nil
+}
+
+// chartStatus returns the state of child, the HelmRelease of chart name.
+func chartStatus(name string, child *appv1.HelmRelease) appv1.HelmAppChartStatus {
+	status := appv1.HelmAppChartStatus{
+		Name:        name,
+		HelmRelease: child.GetName(),
+		Ready:       appv1.StatusUnknown,
+	}
+
+	if child.Status.DeployedRelease != nil {
+		status.Revision = child.Status.DeployedRelease.Revision
+	}
+
+	// the state of a previous generation is not the one of the chart
+	ready := child.Status.GetCondition(appv1.ConditionReady)
+	if ready != nil && ready.ObservedGeneration == child.GetGeneration() {
+		status.Ready = ready.Status
+
+		if ready.Status != appv1.StatusTrue {
+			status.Reason, status.Message = ready.Reason, ready.Message
+		}
+	}
+
+	return status
+}
+
+// umbrellaDeployedCondition returns the Deployed condition of an umbrella
+// HelmRelease: true once every chart is ready, explained by the first chart
+// that is not otherwise.
+func umbrellaDeployedCondition(charts []appv1.HelmAppChartStatus) appv1.HelmAppCondition {
+	for _, chart := range charts {
+		if chart.Ready == appv1.StatusTrue {
+			continue
+		}
+
+		message := fmt.Sprintf("Chart %s (HelmRelease %s) is not ready", chart.Name, chart.HelmRelease)
+		if chart.Message != "" {
+			message += ": " + chart.Message
+		}
+
+		return appv1.HelmAppCondition{
+			Type:    appv1.ConditionDeployed,
+			Status:  appv1.StatusFalse,
+			Reason:  appv1.ReasonChartNotReady,
+			Message: message,
+		}
+	}
+
+	return appv1.HelmAppCondition{
+		Type:   appv1.ConditionDeployed,
+		Status: appv1.StatusTrue,
+		Reason: appv1.ReasonChartsDeployed,
+	}
+}
+
+// sameJSON returns true if a and b have the same JSON encoding, e.g. values
+// decoded with numbers of different types.
+func sameJSON(a, b interface{}) bool {
+	rawA, errA := json.Marshal(a)
+	rawB, errB := json.Marshal(b)
+
+	return errA == nil && errB == nil && bytes.Equal(rawA, rawB)
+}
@@ -0,0 +1,184 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helmrelease
+
+import (
+	"context"
+	"testing"
+
+	"github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+func newUmbrella() *appv1.HelmRelease {
+	hr := &appv1.HelmRelease{ObjectMeta: metav1.ObjectMeta{
+		Name:      "stack",
+		Namespace: "default",
+		UID:       "stack-uid",
+		Labels: map[string]string{
+			"team":                     "web",
+			subscriptionNameLabel:      "sub",
+			subscriptionNamespaceLabel: "default",
+		},
+	}}
+	hr.SetGroupVersionKind(appv1.SchemeGroupVersion.WithKind("HelmRelease"))
+	hr.Repo.ChartName = "ignored"
+	hr.Repo.TargetNamespace = "apps"
+	hr.Repo.Charts = []appv1.UmbrellaChart{
+		{Name: "db", ChartName: "postgresql", Version: "10.0.0", TargetNamespace: "data",
+			Values: runtime.RawExtension{Raw: []byte(`{"replicas":2,"image":{"tag":"13"}}`)}},
+		{Name: "web", ChartName: "nginx"},
+	}
+	hr.Spec = map[string]interface{}{"replicas": 1, "image": map[string]interface{}{"pullPolicy": "Always"}}
+
+	return hr
+}
+
+func TestUmbrellaChartRelease(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	hr := newUmbrella()
+
+	db, err := umbrellaChartRelease(hr, hr.Repo.Charts[0], "")
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(db.GetName()).To(gomega.Equal("stack-db"))
+	g.Expect(db.GetLabels()).To(gomega.Equal(map[string]string{"team": "web", umbrellaLabel: "stack"}))
+	g.Expect(db.Repo.ChartName).To(gomega.Equal("postgresql"))
+	g.Expect(db.Repo.Version).To(gomega.Equal("10.0.0"))
+	g.Expect(db.Repo.TargetNamespace).To(gomega.Equal("data"))
+	g.Expect(db.Repo.Charts).To(gomega.BeEmpty())
+	g.Expect(db.Repo.DependsOn).To(gomega.BeEmpty())
+
+	// the values of the chart override the shared ones
+	g.Expect(sameJSON(db.Spec, map[string]interface{}{
+		"replicas": 2,
+		"image":    map[string]interface{}{"tag": "13", "pullPolicy": "Always"},
+	})).To(gomega.BeTrue())
+
+	web, err := umbrellaChartRelease(hr, hr.Repo.Charts[1], db.GetName())
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(web.Repo.TargetNamespace).To(gomega.Equal("apps"))
+	g.Expect(web.Repo.DependsOn).To(gomega.Equal([]appv1.DependencyReference{{Name: "stack-db"}}))
+	g.Expect(sameJSON(web.Spec, hr.Spec)).To(gomega.BeTrue())
+
+	// the shared values are not modified
+	g.Expect(hr.Spec.(map[string]interface{})["replicas"]).To(gomega.Equal(1))
+
+	hr.Repo.Charts[1].Values.Raw = []byte(`[`)
+	_, err = umbrellaChartRelease(hr, hr.Repo.Charts[1], db.GetName())
+	g.Expect(err).To(gomega.HaveOccurred())
+}
+
+func TestUmbrellaDeployedCondition(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	child := &appv1.HelmRelease{ObjectMeta: metav1.ObjectMeta{Name: "stack-db", Generation: 2}}
+	child.Status.DeployedRelease = &appv1.HelmAppRelease{Revision: 3}
+	child.Status.SetCondition(appv1.HelmAppCondition{
+		Type:               appv1.ConditionReady,
+		Status:             appv1.StatusTrue,
+		ObservedGeneration: 1,
+	})
+
+	// the state of a previous generation is unknown
+	status := chartStatus("db", child)
+	g.Expect(status.Ready).To(gomega.Equal(appv1.StatusUnknown))
+	g.Expect(status.Revision).To(gomega.Equal(3))
+
+	cond := umbrellaDeployedCondition([]appv1.HelmAppChartStatus{status})
+	g.Expect(cond.Status).To(gomega.Equal(appv1.StatusFalse))
+	g.Expect(cond.Reason).To(gomega.Equal(appv1.ReasonChartNotReady))
+	g.Expect(cond.Message).To(gomega.Equal("Chart db (HelmRelease stack-db) is not ready"))
+
+	child.Status.SetCondition(appv1.HelmAppCondition{
+		Type:               appv1.ConditionReady,
+		Status:             appv1.StatusFalse,
+		Reason:             appv1.ReasonInstallError,
+		Message:            "timed out",
+		ObservedGeneration: 2,
+	})
+
+	status = chartStatus("db", child)
+	g.Expect(status.Ready).To(gomega.Equal(appv1.StatusFalse))
+	g.Expect(umbrellaDeployedCondition([]appv1.HelmAppChartStatus{status}).Message).
+		To(gomega.HaveSuffix(": timed out"))
+
+	status.Ready = appv1.StatusTrue
+	cond = umbrellaDeployedCondition([]appv1.HelmAppChartStatus{status})
+	g.Expect(cond.Status).To(gomega.Equal(appv1.StatusTrue))
+	g.Expect(cond.Reason).To(gomega.Equal(appv1.ReasonChartsDeployed))
+}
+
+func TestReconcileUmbrella(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	hr := newUmbrella()
+
+	// the HelmRelease of a removed chart
+	removed := &appv1.HelmRelease{ObjectMeta: metav1.ObjectMeta{
+		Name:      "stack-cache",
+		Namespace: "default",
+		Labels:    map[string]string{umbrellaLabel: "stack"},
+	}}
+	g.Expect(controllerutil.SetControllerReference(hr, removed, scheme.Scheme)).To(gomega.Succeed())
+
+	c := fake.NewFakeClientWithScheme(scheme.Scheme, hr.DeepCopy(), removed)
+	r := &ReconcileHelmRelease{clientManager{client: c, recorder: record.NewFakeRecorder(10)}}
+
+	defer forgetReleaseState(types.NamespacedName{Namespace: "default", Name: "stack"})
+
+	_, err := r.reconcileUmbrella(hr)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	g.Expect(hr.GetFinalizers()).To(gomega.ContainElement(finalizer))
+	g.Expect(hr.Status.Charts).To(gomega.HaveLen(2))
+	g.Expect(hr.Status.Charts[0].HelmRelease).To(gomega.Equal("stack-db"))
+	g.Expect(hr.Status.Charts[1].HelmRelease).To(gomega.Equal("stack-web"))
+	g.Expect(hr.Status.GetCondition(appv1.ConditionDeployed).Status).To(gomega.Equal(appv1.StatusFalse))
+
+	children, err := r.umbrellaChildren(hr)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(children).To(gomega.HaveLen(2))
+	g.Expect(children).To(gomega.HaveKey("stack-db"))
+	g.Expect(children).To(gomega.HaveKey("stack-web"))
+
+	// the charts are uninstalled in the reverse order
+	now := metav1.Now()
+	hr.SetDeletionTimestamp(&now)
+
+	for _, gone := range []string{"stack-web", "stack-db"} {
+		res, err := r.reconcileUmbrella(hr)
+		g.Expect(err).NotTo(gomega.HaveOccurred())
+		g.Expect(res.RequeueAfter).To(gomega.Equal(umbrellaUninstallInterval))
+
+		err = c.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: gone}, &appv1.HelmRelease{})
+		g.Expect(err).To(gomega.HaveOccurred())
+	}
+
+	res, err := r.reconcileUmbrella(hr)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(res.RequeueAfter).To(gomega.BeZero())
+	g.Expect(hr.GetFinalizers()).NotTo(gomega.ContainElement(finalizer))
+}
@@ -0,0 +1,80 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helmrelease
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// NewClient returns the client of the manager. It reads the Secrets and the
+// ConfigMaps from the API server, so that the cache of the manager never
+// lists and watches every Secret and ConfigMap of the cluster, e.g. the helm
+// release records, while only a few are read: the values, credentials and
+// chart bundles referenced by the HelmReleases. The other kinds are read from
+// the cache, but for the unstructured objects.
+func NewClient(c cache.Cache, config *rest.Config, options client.Options) (client.Client, error) {
+	apiClient, err := client.New(config, options)
+	if err != nil {
+		return nil, err
+	}
+
+	return &client.DelegatingClient{
+		Reader:       uncachedReader{cache: c, client: apiClient},
+		Writer:       apiClient,
+		StatusClient: apiClient,
+	}, nil
+}
+
+// uncachedReader reads the Secrets, the ConfigMaps and the unstructured
+// objects from client, the other objects from cache.
+type uncachedReader struct {
+	cache  client.Reader
+	client client.Reader
+}
+
+func (r uncachedReader) Get(ctx context.Context, key client.ObjectKey, obj runtime.Object) error {
+	if uncached(obj) {
+		return r.client.Get(ctx, key, obj)
+	}
+
+	return r.cache.Get(ctx, key, obj)
+}
+
+func (r uncachedReader) List(ctx context.Context, list runtime.Object, opts ...client.ListOption) error {
+	if uncached(list) {
+		return r.client.List(ctx, list, opts...)
+	}
+
+	return r.cache.List(ctx, list, opts...)
+}
+
+func uncached(obj runtime.Object) bool {
+	switch obj.(type) {
+	case *corev1.Secret, *corev1.SecretList, *corev1.ConfigMap, *corev1.ConfigMapList,
+		*unstructured.Unstructured, *unstructured.UnstructuredList:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,65 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helmrelease
+
+import (
+	"context"
+	"testing"
+
+	"github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+func TestUncachedReader(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	meta := metav1.ObjectMeta{Name: "webapp", Namespace: "default"}
+
+	cache := fake.NewFakeClientWithScheme(scheme.Scheme, &appv1.HelmRelease{ObjectMeta: meta})
+	apiServer := fake.NewFakeClientWithScheme(scheme.Scheme, &corev1.Secret{ObjectMeta: meta},
+		&corev1.ConfigMap{ObjectMeta: meta})
+
+	r := uncachedReader{cache: cache, client: apiServer}
+	key := types.NamespacedName{Namespace: "default", Name: "webapp"}
+
+	// the Secrets and ConfigMaps are read from the API server
+	g.Expect(r.Get(context.TODO(), key, &corev1.Secret{})).To(gomega.Succeed())
+	g.Expect(r.Get(context.TODO(), key, &corev1.ConfigMap{})).To(gomega.Succeed())
+
+	secrets := &corev1.SecretList{}
+	g.Expect(r.List(context.TODO(), secrets)).To(gomega.Succeed())
+	g.Expect(secrets.Items).To(gomega.HaveLen(1))
+
+	cm := &unstructured.Unstructured{}
+	cm.SetAPIVersion("v1")
+	cm.SetKind("ConfigMap")
+	g.Expect(r.Get(context.TODO(), key, cm)).To(gomega.Succeed())
+
+	// the other kinds from the cache
+	g.Expect(r.Get(context.TODO(), key, &appv1.HelmRelease{})).To(gomega.Succeed())
+
+	hrs := &appv1.HelmReleaseList{}
+	g.Expect(r.List(context.TODO(), hrs)).To(gomega.Succeed())
+	g.Expect(hrs.Items).To(gomega.HaveLen(1))
+}
@@ -0,0 +1,172 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helmrelease
+
+import (
+	"sync"
+
+	"github.com/ghodss/yaml"
+	"helm.sh/helm/v3/pkg/releaseutil"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+// resourceWatcher watches the kinds of the resources deployed by the
+// releases so that a HelmRelease is reconciled as soon as one of its
+// resources is modified or deleted, e.g. to remediate the drift, instead of
+// at its next interval. The watches are added as new kinds get deployed.
+type resourceWatcher struct {
+	mu       sync.Mutex
+	ctrl     controller.Controller
+	watched  map[schema.GroupVersionKind]bool
+	releases map[types.NamespacedName]types.NamespacedName
+}
+
+// watcher is set when the resources of the releases are watched
+var watcher *resourceWatcher
+
+func newResourceWatcher(c controller.Controller) *resourceWatcher {
+	return &resourceWatcher{
+		ctrl:     c,
+		watched:  make(map[schema.GroupVersionKind]bool),
+		releases: make(map[types.NamespacedName]types.NamespacedName),
+	}
+}
+
+// watchResources watches the kinds of the resources of the manifest deployed
+// by hr, if the resources of the releases are watched. The resources of the
+// remote clusters are not watched.
+func watchResources(hr *appv1.HelmRelease, releaseName, manifest string) {
+	if watcher == nil || hr.Repo.KubeConfig != nil {
+		return
+	}
+
+	watcher.watch(hr, releaseName, manifest)
+}
+
+// forgetResources stops mapping the resources of the deleted HelmRelease name
+// to it. The watches of their kinds are kept for the other releases.
+func forgetResources(name types.NamespacedName) {
+	if watcher == nil {
+		return
+	}
+
+	watcher.mu.Lock()
+	defer watcher.mu.Unlock()
+
+	for rel, hr := range watcher.releases {
+		if hr == name {
+			delete(watcher.releases, rel)
+		}
+	}
+}
+
+func (w *resourceWatcher) watch(hr *appv1.HelmRelease, releaseName, manifest string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.releases[types.NamespacedName{Namespace: targetNamespace(hr), Name: releaseName}] =
+		types.NamespacedName{Namespace: hr.GetNamespace(), Name: hr.GetName()}
+
+	for _, resource := range releaseutil.SplitManifests(manifest) {
+		var u unstructured.Unstructured
+		if err := yaml.Unmarshal([]byte(resource), &u); err != nil {
+			continue
+		}
+
+		gvk := u.GroupVersionKind()
+		if gvk.Empty() || w.watched[gvk] {
+			continue
+		}
+
+		obj := &unstructured.Unstructured{}
+		obj.SetGroupVersionKind(gvk)
+
+		err := w.ctrl.Watch(&source.Kind{Type: obj}, &handler.EnqueueRequestsFromMapFunc{
+			ToRequests: handler.ToRequestsFunc(w.helmReleaseOf),
+		}, releaseResourcePredicate{})
+		if err != nil {
+			log.Error(err, "Failed to watch the resources of the releases", "kind", gvk.String())
+			continue
+		}
+
+		log.Info("Watching the resources of the releases", "kind", gvk.String())
+
+		w.watched[gvk] = true
+	}
+}
+
+// helmReleaseOf maps a resource to the HelmRelease of its release, from the
+// annotations set by helm on the resources it deploys.
+func (w *resourceWatcher) helmReleaseOf(obj handler.MapObject) []reconcile.Request {
+	annotations := obj.Meta.GetAnnotations()
+
+	rel := types.NamespacedName{
+		Namespace: annotations[helmReleaseNamespaceAnnotation],
+		Name:      annotations[helmReleaseNameAnnotation],
+	}
+	if rel.Name == "" {
+		return nil
+	}
+
+	w.mu.Lock()
+	hr, ok := w.releases[rel]
+	w.mu.Unlock()
+
+	if !ok {
+		return nil
+	}
+
+	return []reconcile.Request{{NamespacedName: hr}}
+}
+
+// releaseResourcePredicate passes the deletes and the changes of the
+// resources of the releases. The status updates of the resources with a
+// generation are skipped, and so are the creates, e.g. all the existing
+// resources listed when a kind starts being watched.
+type releaseResourcePredicate struct {
+	predicate.Funcs
+}
+
+func (releaseResourcePredicate) Create(e event.CreateEvent) bool {
+	return false
+}
+
+func (releaseResourcePredicate) Update(e event.UpdateEvent) bool {
+	if e.MetaOld == nil || e.MetaNew == nil {
+		return false
+	}
+
+	if e.MetaNew.GetGeneration() != 0 {
+		return e.MetaNew.GetGeneration() != e.MetaOld.GetGeneration()
+	}
+
+	return e.MetaNew.GetResourceVersion() != e.MetaOld.GetResourceVersion()
+}
+
+func (releaseResourcePredicate) Generic(e event.GenericEvent) bool {
+	return false
+}
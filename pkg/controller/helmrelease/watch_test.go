@@ -0,0 +1,98 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helmrelease
+
+import (
+	"testing"
+
+	"github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+// watchController counts the watches added to the controller.
+type watchController struct {
+	controller.Controller
+	watches int
+}
+
+func (c *watchController) Watch(source.Source, handler.EventHandler, ...predicate.Predicate) error {
+	c.watches++
+	return nil
+}
+
+func TestResourceWatcher(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	defer func(w *resourceWatcher) { watcher = w }(watcher)
+
+	c := &watchController{}
+	watcher = newResourceWatcher(c)
+
+	hr := &appv1.HelmRelease{ObjectMeta: metav1.ObjectMeta{Name: "watched", Namespace: "default"}}
+	watchResources(hr, "watched-release", configMapManifest("first", "second"))
+
+	// a watch per kind
+	g.Expect(c.watches).To(gomega.Equal(1))
+
+	mapped := func(releaseNamespace, releaseName string) []reconcile.Request {
+		cm := &metav1.ObjectMeta{Annotations: map[string]string{
+			helmReleaseNamespaceAnnotation: releaseNamespace,
+			helmReleaseNameAnnotation:      releaseName,
+		}}
+
+		return watcher.helmReleaseOf(handler.MapObject{Meta: cm})
+	}
+
+	g.Expect(mapped("default", "watched-release")).To(gomega.Equal([]reconcile.Request{
+		{NamespacedName: types.NamespacedName{Namespace: "default", Name: "watched"}},
+	}))
+	g.Expect(mapped("default", "other-release")).To(gomega.BeEmpty())
+
+	forgetResources(types.NamespacedName{Namespace: "default", Name: "watched"})
+	g.Expect(mapped("default", "watched-release")).To(gomega.BeEmpty())
+
+	// the resources of the remote clusters are not watched
+	hr.Repo.KubeConfig = &appv1.KubeConfig{}
+	watchResources(hr, "watched-release", configMapManifest("first"))
+	g.Expect(mapped("default", "watched-release")).To(gomega.BeEmpty())
+}
+
+func TestReleaseResourcePredicate(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	p := releaseResourcePredicate{}
+	meta := func(generation int64, resourceVersion string) *metav1.ObjectMeta {
+		return &metav1.ObjectMeta{Generation: generation, ResourceVersion: resourceVersion}
+	}
+
+	g.Expect(p.Create(event.CreateEvent{Meta: meta(1, "1")})).To(gomega.BeFalse())
+	g.Expect(p.Delete(event.DeleteEvent{Meta: meta(1, "1")})).To(gomega.BeTrue())
+
+	// the status updates of the resources with a generation are skipped
+	g.Expect(p.Update(event.UpdateEvent{MetaOld: meta(1, "1"), MetaNew: meta(1, "2")})).To(gomega.BeFalse())
+	g.Expect(p.Update(event.UpdateEvent{MetaOld: meta(1, "1"), MetaNew: meta(2, "2")})).To(gomega.BeTrue())
+	g.Expect(p.Update(event.UpdateEvent{MetaOld: meta(0, "1"), MetaNew: meta(0, "2")})).To(gomega.BeTrue())
+}
@@ -0,0 +1,122 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helmrelease
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+// upgradePendingUntil returns when the upgrade of hr may run and the reason
+// it is pending, or the zero time if it may run now, i.e. after its
+// upgradeAfter time and in one of its upgrade windows.
+func upgradePendingUntil(hr *appv1.HelmRelease, now time.Time) (time.Time, appv1.HelmAppConditionReason, error) {
+	eligible, reason := now, appv1.ReasonPendingSchedule
+	if after := hr.Repo.UpgradeAfter; after != nil && after.After(now) {
+		eligible = after.Time
+	}
+
+	next := eligible
+
+	for i, w := range hr.Repo.UpgradeWindows {
+		opens, err := windowOpensAt(w, eligible)
+		if err != nil {
+			return time.Time{}, "", err
+		}
+
+		if i == 0 || opens.Before(next) {
+			next = opens
+		}
+	}
+
+	if !next.After(now) {
+		return time.Time{}, "", nil
+	}
+
+	if next.After(eligible) {
+		reason = appv1.ReasonPendingWindow
+	}
+
+	return next, reason, nil
+}
+
+// windowOpensAt returns when w next opens, or now if it is open.
+func windowOpensAt(w appv1.UpgradeWindow, now time.Time) (time.Time, error) {
+	loc := time.UTC
+
+	if w.TimeZone != "" {
+		var err error
+		if loc, err = time.LoadLocation(w.TimeZone); err != nil {
+			return time.Time{}, fmt.Errorf("invalid upgrade window time zone %s: %w", w.TimeZone, err)
+		}
+	}
+
+	start, err := time.Parse("15:04", w.Start)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid upgrade window start %s, expected HH:MM", w.Start)
+	}
+
+	if w.Duration.Duration <= 0 {
+		return time.Time{}, fmt.Errorf("invalid upgrade window duration %s", w.Duration.Duration)
+	}
+
+	days := make(map[time.Weekday]bool, len(w.Days))
+
+	for _, day := range w.Days {
+		weekday, err := parseWeekday(day)
+		if err != nil {
+			return time.Time{}, err
+		}
+
+		days[weekday] = true
+	}
+
+	local := now.In(loc)
+
+	// a window opened up to a week ago may still be open
+	for d := -7; d <= 7; d++ {
+		opens := time.Date(local.Year(), local.Month(), local.Day()+d, start.Hour(), start.Minute(), 0, 0, loc)
+		if len(days) > 0 && !days[opens.Weekday()] {
+			continue
+		}
+
+		if opens.After(now) {
+			return opens, nil
+		}
+
+		if now.Before(opens.Add(w.Duration.Duration)) {
+			return now, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("upgrade window never opens")
+}
+
+// parseWeekday parses the English name of a day of the week, full or
+// abbreviated to three letters, e.g. Sat or Saturday.
+func parseWeekday(day string) (time.Weekday, error) {
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		if strings.EqualFold(day, d.String()) || strings.EqualFold(day, d.String()[:3]) {
+			return d, nil
+		}
+	}
+
+	return 0, fmt.Errorf("invalid upgrade window day %s", day)
+}
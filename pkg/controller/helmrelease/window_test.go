@@ -0,0 +1,87 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helmrelease
+
+import (
+	"testing"
+	"time"
+
+	"github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+func TestUpgradePendingUntil(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	hr := &appv1.HelmRelease{}
+
+	// Friday, November 20 2020
+	now := time.Date(2020, time.November, 20, 12, 0, 0, 0, time.UTC)
+
+	// without windows, upgrades run right away
+	pending, reason, err := upgradePendingUntil(hr, now)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(pending.IsZero()).To(gomega.BeTrue())
+
+	hr.Repo.UpgradeWindows = []appv1.UpgradeWindow{
+		{Days: []string{"Sat", "sunday"}, Start: "02:00", Duration: metav1.Duration{Duration: 4 * time.Hour}},
+	}
+
+	pending, reason, err = upgradePendingUntil(hr, now)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(pending).To(gomega.Equal(time.Date(2020, time.November, 21, 2, 0, 0, 0, time.UTC)))
+	g.Expect(reason).To(gomega.Equal(appv1.ReasonPendingWindow))
+
+	// the first window after the upgradeAfter time
+	after := metav1.NewTime(time.Date(2020, time.November, 21, 3, 0, 0, 0, time.UTC))
+	hr.Repo.UpgradeAfter = &after
+
+	pending, reason, err = upgradePendingUntil(hr, now)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(pending).To(gomega.Equal(after.Time))
+	g.Expect(reason).To(gomega.Equal(appv1.ReasonPendingSchedule))
+
+	hr.Repo.UpgradeAfter = nil
+
+	// open on Sunday morning
+	pending, reason, err = upgradePendingUntil(hr, time.Date(2020, time.November, 22, 5, 59, 0, 0, time.UTC))
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(pending.IsZero()).To(gomega.BeTrue())
+
+	// the window opening first is waited for
+	hr.Repo.UpgradeWindows = append(hr.Repo.UpgradeWindows, appv1.UpgradeWindow{
+		Start: "23:00", Duration: metav1.Duration{Duration: time.Hour}, TimeZone: "Europe/Paris",
+	})
+
+	pending, reason, err = upgradePendingUntil(hr, now)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(pending.Equal(time.Date(2020, time.November, 20, 22, 0, 0, 0, time.UTC))).To(gomega.BeTrue(), pending.String())
+
+	for _, w := range []appv1.UpgradeWindow{
+		{Start: "2am", Duration: metav1.Duration{Duration: time.Hour}},
+		{Start: "02:00"},
+		{Days: []string{"Someday"}, Start: "02:00", Duration: metav1.Duration{Duration: time.Hour}},
+		{Start: "02:00", Duration: metav1.Duration{Duration: time.Hour}, TimeZone: "Mars/Olympus"},
+	} {
+		hr.Repo.UpgradeWindows = []appv1.UpgradeWindow{w}
+
+		_, _, err = upgradePendingUntil(hr, now)
+		g.Expect(err).To(gomega.HaveOccurred(), w.Start)
+	}
+}
@@ -0,0 +1,166 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package faults injects failures into the operator to test that it recovers
+// from them, e.g. a crash in the middle of an upgrade leaving its release
+// pending. The faults are only injected by the builds with the faults tag:
+//
+//	go build -tags faults ./cmd/manager
+//	HELMRELEASE_FAULTS="chart-download@default/nginx=error*2,release-apply=crash" ./manager
+//
+// Inject is a no-op in the other builds.
+package faults
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EnvVar lists the faults injected by a build with the faults tag, in the
+// format of Parse.
+const EnvVar = "HELMRELEASE_FAULTS"
+
+// Point is where a fault is injected.
+type Point string
+
+const (
+	// ChartDownload is before the chart of a HelmRelease is downloaded.
+	ChartDownload Point = "chart-download"
+	// ReleaseApply is before the resources of a release are created or
+	// updated, once helm recorded the pending revision.
+	ReleaseApply Point = "release-apply"
+	// StatusWrite is after a release is installed or upgraded, before the
+	// status of its HelmRelease is written.
+	StatusWrite Point = "status-write"
+)
+
+// Action is what an injected fault does.
+type Action string
+
+const (
+	// Error fails with ErrInjected.
+	Error Action = "error"
+	// Delay waits for the delay of the fault.
+	Delay Action = "delay"
+	// Crash exits the process with Exit.
+	Crash Action = "crash"
+)
+
+// ErrInjected is the error of the faults failing with Error.
+var ErrInjected = errors.New("injected fault")
+
+// Exit exits the process on a Crash. The tests running the operator in
+// process replace it, e.g. with a panic.
+var Exit = func() {
+	os.Exit(3)
+}
+
+// Fault is a failure injected at a point.
+type Fault struct {
+	Point  Point
+	Action Action
+	// Key is the namespace/name of the HelmRelease the fault is injected
+	// into, every HelmRelease if it is empty.
+	Key string
+	// Delay is the delay of the Delay action.
+	Delay time.Duration
+	// Times is how many times the fault is injected, always if it is 0.
+	Times int
+}
+
+// Parse parses a comma-separated list of faults, each formatted as
+// point[@namespace/name]=action[:delay][*times], e.g.
+// "chart-download@default/nginx=error*2,release-apply=delay:30s".
+func Parse(spec string) ([]Fault, error) {
+	var faults []Fault
+
+	for _, s := range strings.Split(spec, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+
+		fault, err := parseFault(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid fault %q: %w", s, err)
+		}
+
+		faults = append(faults, fault)
+	}
+
+	return faults, nil
+}
+
+func parseFault(s string) (Fault, error) {
+	var fault Fault
+
+	target, action := s, ""
+	if i := strings.Index(s, "="); i >= 0 {
+		target, action = s[:i], s[i+1:]
+	}
+
+	if i := strings.Index(target, "@"); i >= 0 {
+		target, fault.Key = target[:i], target[i+1:]
+	}
+
+	switch point := Point(target); point {
+	case ChartDownload, ReleaseApply, StatusWrite:
+		fault.Point = point
+	default:
+		return fault, fmt.Errorf("unknown point %q", target)
+	}
+
+	if i := strings.LastIndex(action, "*"); i >= 0 {
+		times, err := strconv.Atoi(action[i+1:])
+		if err != nil || times < 1 {
+			return fault, fmt.Errorf("invalid times %q", action[i+1:])
+		}
+
+		action, fault.Times = action[:i], times
+	}
+
+	if i := strings.Index(action, ":"); i >= 0 {
+		delay, err := time.ParseDuration(action[i+1:])
+		if err != nil {
+			return fault, err
+		}
+
+		action, fault.Delay = action[:i], delay
+	}
+
+	switch a := Action(action); a {
+	case Error, Crash:
+		fault.Action = a
+	case Delay:
+		if fault.Delay <= 0 {
+			return fault, errors.New("delay requires a positive duration")
+		}
+
+		fault.Action = a
+	default:
+		return fault, fmt.Errorf("unknown action %q", action)
+	}
+
+	return fault, nil
+}
+
+// matches returns true if the fault is injected at point into the
+// HelmRelease key.
+func (f Fault) matches(point Point, key string) bool {
+	return f.Point == point && (f.Key == "" || f.Key == key)
+}
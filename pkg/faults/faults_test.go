@@ -0,0 +1,57 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package faults
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	faults, err := Parse("chart-download@default/nginx=error*2, release-apply=delay:30s,status-write=crash")
+	assert.NoError(t, err)
+	assert.Equal(t, []Fault{
+		{Point: ChartDownload, Action: Error, Key: "default/nginx", Times: 2},
+		{Point: ReleaseApply, Action: Delay, Delay: 30 * time.Second},
+		{Point: StatusWrite, Action: Crash},
+	}, faults)
+
+	faults, err = Parse("")
+	assert.NoError(t, err)
+	assert.Empty(t, faults)
+
+	for _, spec := range []string{
+		"chart-upload=error",
+		"chart-download=explode",
+		"release-apply=delay",
+		"release-apply=delay:soon",
+		"status-write=crash*0",
+	} {
+		_, err := Parse(spec)
+		assert.Error(t, err, spec)
+	}
+}
+
+func TestFaultMatches(t *testing.T) {
+	f := Fault{Point: ChartDownload, Key: "default/nginx"}
+	assert.True(t, f.matches(ChartDownload, "default/nginx"))
+	assert.False(t, f.matches(ChartDownload, "default/redis"))
+	assert.False(t, f.matches(ReleaseApply, "default/nginx"))
+
+	f.Key = ""
+	assert.True(t, f.matches(ChartDownload, "default/redis"))
+}
@@ -0,0 +1,101 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build faults
+// +build faults
+
+package faults
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"k8s.io/klog"
+)
+
+// Enabled is true in the builds with the faults tag.
+const Enabled = true
+
+var injected = struct {
+	sync.Mutex
+	faults []Fault
+}{}
+
+func init() {
+	faults, err := Parse(os.Getenv(EnvVar))
+	if err != nil {
+		klog.Error(err, " - Ignoring the faults of ", EnvVar)
+		return
+	}
+
+	Set(faults...)
+}
+
+// Set replaces the faults injected.
+func Set(faults ...Fault) {
+	injected.Lock()
+	defer injected.Unlock()
+
+	injected.faults = faults
+}
+
+// Inject injects the first fault matching point and the HelmRelease key, if
+// any, and returns its error.
+func Inject(point Point, key string) error {
+	fault, ok := next(point, key)
+	if !ok {
+		return nil
+	}
+
+	klog.Warning("Injecting fault ", fault.Action, " at ", point, " into ", key)
+
+	if fault.Action == Delay {
+		time.Sleep(fault.Delay)
+		return nil
+	}
+
+	// an Exit replaced by the tests fails the operation if it returns
+	if fault.Action == Crash {
+		Exit()
+	}
+
+	return fmt.Errorf("%w at %s of %s", ErrInjected, point, key)
+}
+
+// next consumes one injection of the first fault matching point and key.
+func next(point Point, key string) (Fault, bool) {
+	injected.Lock()
+	defer injected.Unlock()
+
+	for i := range injected.faults {
+		fault := &injected.faults[i]
+		if !fault.matches(point, key) {
+			continue
+		}
+
+		if fault.Times > 0 {
+			fault.Times--
+
+			if fault.Times == 0 {
+				injected.faults = append(injected.faults[:i:i], injected.faults[i+1:]...)
+			}
+		}
+
+		return *fault, true
+	}
+
+	return Fault{}, false
+}
@@ -0,0 +1,46 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build faults
+// +build faults
+
+package faults
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInject(t *testing.T) {
+	defer Set()
+
+	exit := Exit
+	defer func() { Exit = exit }()
+
+	crashed := 0
+	Exit = func() { crashed++ }
+
+	Set(Fault{Point: ChartDownload, Action: Error, Key: "default/nginx", Times: 2},
+		Fault{Point: StatusWrite, Action: Crash})
+
+	assert.NoError(t, Inject(ChartDownload, "default/redis"))
+	assert.True(t, errors.Is(Inject(ChartDownload, "default/nginx"), ErrInjected))
+	assert.True(t, errors.Is(Inject(ChartDownload, "default/nginx"), ErrInjected))
+	assert.NoError(t, Inject(ChartDownload, "default/nginx"))
+
+	assert.Error(t, Inject(StatusWrite, "default/nginx"))
+	assert.Equal(t, 1, crashed)
+}
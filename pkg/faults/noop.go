@@ -0,0 +1,29 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !faults
+// +build !faults
+
+package faults
+
+// Enabled is true in the builds with the faults tag.
+const Enabled = false
+
+// Set replaces the faults injected, none without the faults tag.
+func Set(faults ...Fault) {}
+
+// Inject injects no fault without the faults tag.
+func Inject(point Point, key string) error {
+	return nil
+}
@@ -0,0 +1,451 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package flux converts the Flux HelmReleases, helm.toolkit.fluxcd.io, to
+// HelmReleases, so that their releases can be moved from Flux to the operator.
+package flux
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ghodss/yaml"
+	"helm.sh/helm/v3/pkg/strvals"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+var (
+	// HelmReleaseGVK is the version of the Flux HelmReleases that are converted
+	HelmReleaseGVK = schema.GroupVersionKind{Group: "helm.toolkit.fluxcd.io", Version: "v2beta1", Kind: "HelmRelease"}
+	// SourceGroupVersion is the version of the Flux sources of the charts
+	SourceGroupVersion = schema.GroupVersion{Group: "source.toolkit.fluxcd.io", Version: "v1beta1"}
+)
+
+const (
+	helmRepositoryKind = "HelmRepository"
+	gitRepositoryKind  = "GitRepository"
+
+	defaultValuesKey = "values.yaml"
+)
+
+// convertedFields are the fields of the Flux HelmRelease spec that are
+// converted, the others are reported.
+var convertedFields = map[string]bool{
+	"chart":              true,
+	"interval":           true,
+	"releaseName":        true,
+	"targetNamespace":    true,
+	"storageNamespace":   true,
+	"serviceAccountName": true,
+	"dependsOn":          true,
+	"suspend":            true,
+	"timeout":            true,
+	"maxHistory":         true,
+	"install":            true,
+	"upgrade":            true,
+	"values":             true,
+	"valuesFrom":         true,
+}
+
+// Convert returns the HelmRelease deploying the release of the Flux
+// HelmRelease fhr, and warnings about the settings it could not convert. The
+// source of its chart and its valuesFrom are read with c, the values are
+// resolved once: their later changes are not picked up.
+func Convert(ctx context.Context, c client.Reader, fhr *unstructured.Unstructured) (*appv1.HelmRelease, []string, error) {
+	kind, _, _ := unstructured.NestedString(fhr.Object, "spec", "chart", "spec", "sourceRef", "kind")
+	name, _, _ := unstructured.NestedString(fhr.Object, "spec", "chart", "spec", "sourceRef", "name")
+	namespace, _, _ := unstructured.NestedString(fhr.Object, "spec", "chart", "spec", "sourceRef", "namespace")
+
+	if namespace == "" {
+		namespace = fhr.GetNamespace()
+	}
+
+	source := &unstructured.Unstructured{}
+	source.SetGroupVersionKind(SourceGroupVersion.WithKind(kind))
+
+	if err := c.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, source); err != nil {
+		return nil, nil, fmt.Errorf("failed to get %s %s/%s: %w", kind, namespace, name, err)
+	}
+
+	valuesFrom, warnings, err := readValuesFrom(ctx, c, fhr)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	hr, convertWarnings, err := convert(fhr, source, valuesFrom)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return hr, append(warnings, convertWarnings...), nil
+}
+
+// readValuesFrom returns the values of the valuesFrom references of fhr, in
+// order.
+func readValuesFrom(ctx context.Context, c client.Reader, fhr *unstructured.Unstructured) ([]map[string]interface{}, []string, error) {
+	refs, _, err := unstructured.NestedSlice(fhr.Object, "spec", "valuesFrom")
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid spec.valuesFrom: %w", err)
+	}
+
+	var (
+		valuesFrom []map[string]interface{}
+		warnings   []string
+	)
+
+	for i := range refs {
+		ref, ok := refs[i].(map[string]interface{})
+		if !ok {
+			return nil, nil, fmt.Errorf("invalid spec.valuesFrom[%d]", i)
+		}
+
+		kind, _, _ := unstructured.NestedString(ref, "kind")
+		name, _, _ := unstructured.NestedString(ref, "name")
+		valuesKey, _, _ := unstructured.NestedString(ref, "valuesKey")
+		targetPath, _, _ := unstructured.NestedString(ref, "targetPath")
+		optional, _, _ := unstructured.NestedBool(ref, "optional")
+
+		if valuesKey == "" {
+			valuesKey = defaultValuesKey
+		}
+
+		key := types.NamespacedName{Namespace: fhr.GetNamespace(), Name: name}
+
+		var (
+			data  string
+			found bool
+		)
+
+		switch kind {
+		case "ConfigMap":
+			cm := &corev1.ConfigMap{}
+			if err = c.Get(ctx, key, cm); err == nil {
+				data, found = cm.Data[valuesKey]
+			}
+		case "Secret":
+			secret := &corev1.Secret{}
+			if err = c.Get(ctx, key, secret); err == nil {
+				var b []byte
+				b, found = secret.Data[valuesKey]
+				data = string(b)
+			}
+		default:
+			return nil, nil, fmt.Errorf("unsupported kind %q of spec.valuesFrom[%d]", kind, i)
+		}
+
+		if err != nil && !(optional && client.IgnoreNotFound(err) == nil) {
+			return nil, nil, fmt.Errorf("failed to get %s %s of spec.valuesFrom[%d]: %w", kind, key, i, err)
+		}
+
+		if !found {
+			if optional {
+				continue
+			}
+
+			return nil, nil, fmt.Errorf("no key %q in %s %s of spec.valuesFrom[%d]", valuesKey, kind, key, i)
+		}
+
+		values, err := parseValues(data, targetPath)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid values of %s %s of spec.valuesFrom[%d]: %w", kind, key, i, err)
+		}
+
+		valuesFrom = append(valuesFrom, values)
+		warnings = append(warnings, fmt.Sprintf("the values of %s %s are copied to the spec, their later changes are not picked up", kind, key))
+	}
+
+	return valuesFrom, warnings, nil
+}
+
+// parseValues returns the values of data, a YAML document, or a single
+// value set at targetPath if it is set, as Flux does.
+func parseValues(data, targetPath string) (map[string]interface{}, error) {
+	values := map[string]interface{}{}
+
+	if targetPath != "" {
+		err := strvals.ParseInto(fmt.Sprintf("%s=%s", targetPath, data), values)
+		return values, err
+	}
+
+	err := yaml.Unmarshal([]byte(data), &values)
+
+	return values, err
+}
+
+// convert returns the HelmRelease of fhr, whose chart is served by source and
+// whose valuesFrom resolved to valuesFrom.
+func convert(fhr, source *unstructured.Unstructured, valuesFrom []map[string]interface{}) (*appv1.HelmRelease, []string, error) {
+	spec, _, err := unstructured.NestedMap(fhr.Object, "spec")
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid spec: %w", err)
+	}
+
+	var warnings []string
+
+	for _, field := range sortedKeys(spec) {
+		if !convertedFields[field] {
+			warnings = append(warnings, fmt.Sprintf("spec.%s is not converted", field))
+		}
+	}
+
+	// the remediations and the helm flags of the actions are not converted
+	for _, action := range []string{"install", "upgrade"} {
+		fields, _, _ := unstructured.NestedMap(spec, action)
+
+		for _, field := range sortedKeys(fields) {
+			if field != "createNamespace" && field != "disableWait" {
+				warnings = append(warnings, fmt.Sprintf("spec.%s.%s is not converted", action, field))
+			}
+		}
+	}
+
+	targetNamespace, _, _ := unstructured.NestedString(spec, "targetNamespace")
+
+	// the release name of Flux, so that the release is adopted
+	releaseName, _, _ := unstructured.NestedString(spec, "releaseName")
+	if releaseName == "" {
+		releaseName = fhr.GetName()
+		if targetNamespace != "" {
+			releaseName = targetNamespace + "-" + releaseName
+		}
+	}
+
+	repo, err := convertChart(fhr, source)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	repo.TargetNamespace = targetNamespace
+
+	// Flux keeps the release records in the target namespace by default
+	repo.StorageNamespace, _, _ = unstructured.NestedString(spec, "storageNamespace")
+	if repo.StorageNamespace == "" {
+		repo.StorageNamespace = targetNamespace
+	}
+
+	if repo.StorageNamespace == fhr.GetNamespace() {
+		repo.StorageNamespace = ""
+	}
+
+	repo.ServiceAccountName, _, _ = unstructured.NestedString(spec, "serviceAccountName")
+	repo.Suspend, _, _ = unstructured.NestedBool(spec, "suspend")
+	repo.CreateNamespace, _, _ = unstructured.NestedBool(spec, "install", "createNamespace")
+
+	// Flux waits for the resources unless disabled
+	disableWait, _, _ := unstructured.NestedBool(spec, "install", "disableWait")
+	repo.Wait = !disableWait
+
+	if maxHistory, found, _ := unstructured.NestedInt64(spec, "maxHistory"); found {
+		n := int(maxHistory)
+		repo.MaxHistory = &n
+	}
+
+	for _, field := range []struct {
+		name string
+		to   **metav1.Duration
+	}{
+		{"interval", &repo.Interval},
+		{"timeout", &repo.Timeout},
+	} {
+		value, found, _ := unstructured.NestedString(spec, field.name)
+		if !found {
+			continue
+		}
+
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid spec.%s: %w", field.name, err)
+		}
+
+		*field.to = &metav1.Duration{Duration: d}
+	}
+
+	dependsOn, _, _ := unstructured.NestedSlice(spec, "dependsOn")
+	for _, d := range dependsOn {
+		if d, ok := d.(map[string]interface{}); ok {
+			name, _, _ := unstructured.NestedString(d, "name")
+			namespace, _, _ := unstructured.NestedString(d, "namespace")
+			repo.DependsOn = append(repo.DependsOn, appv1.DependencyReference{Namespace: namespace, Name: name})
+		}
+	}
+
+	// the values override the valuesFrom, the later valuesFrom the earlier ones
+	values := map[string]interface{}{}
+	for _, v := range valuesFrom {
+		values = mergeValues(values, v)
+	}
+
+	if v, _, _ := unstructured.NestedMap(spec, "values"); v != nil {
+		values = mergeValues(values, v)
+	}
+
+	hr := &appv1.HelmRelease{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: appv1.SchemeGroupVersion.String(),
+			Kind:       "HelmRelease",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        fhr.GetName(),
+			Namespace:   fhr.GetNamespace(),
+			Labels:      withoutFluxKeys(fhr.GetLabels()),
+			Annotations: withoutFluxKeys(fhr.GetAnnotations()),
+		},
+		Repo: *repo,
+		Spec: values,
+	}
+
+	if releaseName != fhr.GetName() {
+		hr.Repo.ReleaseName = releaseName
+	}
+
+	return hr, warnings, nil
+}
+
+// convertChart returns the repo of the chart of fhr served by source, a
+// HelmRepository or a GitRepository.
+func convertChart(fhr, source *unstructured.Unstructured) (*appv1.HelmReleaseRepo, error) {
+	chart, _, _ := unstructured.NestedString(fhr.Object, "spec", "chart", "spec", "chart")
+	version, _, _ := unstructured.NestedString(fhr.Object, "spec", "chart", "spec", "version")
+	url, _, _ := unstructured.NestedString(source.Object, "spec", "url")
+
+	if chart == "" || url == "" {
+		return nil, fmt.Errorf("no chart or source URL for Flux HelmRelease %s/%s", fhr.GetNamespace(), fhr.GetName())
+	}
+
+	repo := &appv1.HelmReleaseRepo{}
+
+	if secret, _, _ := unstructured.NestedString(source.Object, "spec", "secretRef", "name"); secret != "" {
+		repo.SecretRef = &corev1.ObjectReference{Namespace: source.GetNamespace(), Name: secret}
+	}
+
+	switch source.GetKind() {
+	case helmRepositoryKind:
+		// the chart archives are downloaded by URL, the index is not read
+		if !exactVersion(version) {
+			return nil, fmt.Errorf("the chart version %q of Flux HelmRelease %s/%s is not an exact version",
+				version, fhr.GetNamespace(), fhr.GetName())
+		}
+
+		repo.Source = &appv1.Source{
+			SourceType: appv1.HelmRepoSourceType,
+			HelmRepo: &appv1.HelmRepo{
+				Urls: []string{fmt.Sprintf("%s/%s-%s.tgz", strings.TrimSuffix(url, "/"), chart, version)},
+			},
+		}
+		repo.ChartName = chart
+		repo.Version = version
+	case gitRepositoryKind:
+		branch, _, _ := unstructured.NestedString(source.Object, "spec", "ref", "branch")
+		if branch == "" {
+			branch = "master"
+		}
+
+		repo.Source = &appv1.Source{
+			SourceType: appv1.GitSourceType,
+			Git: &appv1.Git{
+				Urls:      []string{url},
+				ChartPath: strings.TrimPrefix(path.Clean(chart), "/"),
+				Branch:    branch,
+			},
+		}
+		repo.ChartName = path.Base(chart)
+	default:
+		return nil, fmt.Errorf("unsupported chart source kind %q of Flux HelmRelease %s/%s",
+			source.GetKind(), fhr.GetNamespace(), fhr.GetName())
+	}
+
+	return repo, nil
+}
+
+// mergeValues returns a merged with b, the values of b overriding the ones
+// of a.
+func mergeValues(a, b map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(a))
+	for k, v := range a {
+		out[k] = v
+	}
+
+	for k, v := range b {
+		if v, ok := v.(map[string]interface{}); ok {
+			if av, ok := out[k].(map[string]interface{}); ok {
+				out[k] = mergeValues(av, v)
+				continue
+			}
+		}
+
+		out[k] = v
+	}
+
+	return out
+}
+
+// withoutFluxKeys returns the labels or annotations m without the ones of
+// Flux, e.g. of the Kustomization applying the Flux HelmRelease which would
+// prune the HelmRelease, nor the last applied configuration of kubectl.
+func withoutFluxKeys(m map[string]string) map[string]string {
+	var out map[string]string
+
+	for k, v := range m {
+		if strings.Contains(k, "fluxcd.io/") || k == corev1.LastAppliedConfigAnnotation {
+			continue
+		}
+
+		if out == nil {
+			out = make(map[string]string, len(m))
+		}
+
+		out[k] = v
+	}
+
+	return out
+}
+
+// exactVersion returns true if version is a version, not a range.
+func exactVersion(version string) bool {
+	if version == "" || strings.ContainsAny(version, "*^~<>=|, ") {
+		return false
+	}
+
+	for _, part := range strings.Split(version, ".") {
+		if part == "x" || part == "X" {
+			return false
+		}
+	}
+
+	return true
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
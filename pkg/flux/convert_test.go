@@ -0,0 +1,170 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package flux
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ghodss/yaml"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+const testFluxHelmRelease = `
+apiVersion: helm.toolkit.fluxcd.io/v2beta1
+kind: HelmRelease
+metadata:
+  name: nginx
+  namespace: flux-system
+  labels:
+    app: nginx
+    kustomize.toolkit.fluxcd.io/name: apps
+spec:
+  interval: 5m
+  targetNamespace: web
+  chart:
+    spec:
+      chart: nginx-ingress
+      version: 1.41.0
+      sourceRef:
+        kind: HelmRepository
+        name: stable
+  install:
+    createNamespace: true
+    remediation:
+      retries: 3
+  postRenderers:
+  - kustomize: {}
+  values:
+    controller:
+      replicaCount: 2
+`
+
+const testHelmRepository = `
+apiVersion: source.toolkit.fluxcd.io/v1beta1
+kind: HelmRepository
+metadata:
+  name: stable
+  namespace: flux-system
+spec:
+  url: https://charts.example.com/stable/
+  secretRef:
+    name: stable-auth
+`
+
+const testGitRepository = `
+apiVersion: source.toolkit.fluxcd.io/v1beta1
+kind: GitRepository
+metadata:
+  name: charts
+  namespace: flux-system
+spec:
+  url: https://github.com/example/charts
+  ref:
+    branch: main
+`
+
+func newTestObject(t *testing.T, manifest string) *unstructured.Unstructured {
+	u := &unstructured.Unstructured{}
+	assert.NoError(t, yaml.Unmarshal([]byte(manifest), &u.Object))
+
+	return u
+}
+
+func TestConvert(t *testing.T) {
+	fhr := newTestObject(t, testFluxHelmRelease)
+
+	valuesFrom := []map[string]interface{}{
+		{"controller": map[string]interface{}{"replicaCount": 1, "image": "nginx"}},
+	}
+
+	hr, warnings, err := convert(fhr, newTestObject(t, testHelmRepository), valuesFrom)
+	assert.NoError(t, err)
+
+	// the release of Flux is adopted
+	assert.Equal(t, "nginx", hr.Name)
+	assert.Equal(t, "web-nginx", hr.Repo.ReleaseName)
+	assert.Equal(t, "flux-system", hr.Namespace)
+	assert.Equal(t, map[string]string{"app": "nginx"}, hr.Labels)
+	assert.Nil(t, hr.Annotations)
+	assert.Equal(t, appv1.HelmRepoSourceType, hr.Repo.Source.SourceType)
+	assert.Equal(t, []string{"https://charts.example.com/stable/nginx-ingress-1.41.0.tgz"}, hr.Repo.Source.HelmRepo.Urls)
+	assert.Equal(t, "nginx-ingress", hr.Repo.ChartName)
+	assert.Equal(t, "1.41.0", hr.Repo.Version)
+	assert.Equal(t, "stable-auth", hr.Repo.SecretRef.Name)
+	assert.Equal(t, "flux-system", hr.Repo.SecretRef.Namespace)
+	assert.Equal(t, "web", hr.Repo.TargetNamespace)
+	assert.Equal(t, "web", hr.Repo.StorageNamespace)
+	assert.True(t, hr.Repo.CreateNamespace)
+	assert.True(t, hr.Repo.Wait)
+	assert.Equal(t, 5*time.Minute, hr.Repo.Interval.Duration)
+	assert.Nil(t, hr.Repo.Timeout)
+
+	// the values override the valuesFrom
+	assert.Equal(t, map[string]interface{}{
+		"controller": map[string]interface{}{"replicaCount": float64(2), "image": "nginx"},
+	}, hr.Spec)
+
+	assert.Equal(t, []string{
+		"spec.postRenderers is not converted",
+		"spec.install.remediation is not converted",
+	}, warnings)
+}
+
+func TestConvertGitRepository(t *testing.T) {
+	fhr := newTestObject(t, testFluxHelmRelease)
+	assert.NoError(t, unstructured.SetNestedField(fhr.Object, "./charts/nginx-ingress", "spec", "chart", "spec", "chart"))
+	assert.NoError(t, unstructured.SetNestedField(fhr.Object, "nginx", "spec", "releaseName"))
+
+	hr, _, err := convert(fhr, newTestObject(t, testGitRepository), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "nginx", hr.Name)
+	assert.Empty(t, hr.Repo.ReleaseName)
+	assert.Equal(t, appv1.GitSourceType, hr.Repo.Source.SourceType)
+	assert.Equal(t, []string{"https://github.com/example/charts"}, hr.Repo.Source.Git.Urls)
+	assert.Equal(t, "charts/nginx-ingress", hr.Repo.Source.Git.ChartPath)
+	assert.Equal(t, "main", hr.Repo.Source.Git.Branch)
+	assert.Equal(t, "nginx-ingress", hr.Repo.ChartName)
+	assert.Nil(t, hr.Repo.SecretRef)
+}
+
+func TestConvertVersionRange(t *testing.T) {
+	fhr := newTestObject(t, testFluxHelmRelease)
+
+	for _, version := range []string{"", "*", "1.41.x", ">=1.0.0", "^1.41.0"} {
+		assert.NoError(t, unstructured.SetNestedField(fhr.Object, version, "spec", "chart", "spec", "version"))
+
+		_, _, err := convert(fhr, newTestObject(t, testHelmRepository), nil)
+		assert.Error(t, err, version)
+	}
+}
+
+func TestParseValues(t *testing.T) {
+	values, err := parseValues("replicaCount: 2\nimage:\n  tag: v1\n", "")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"replicaCount": float64(2),
+		"image":        map[string]interface{}{"tag": "v1"},
+	}, values)
+
+	values, err = parseValues("v2", "image.tag")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"image": map[string]interface{}{"tag": "v2"}}, values)
+}
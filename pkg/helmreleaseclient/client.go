@@ -0,0 +1,200 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package helmreleaseclient creates and monitors HelmReleases from other
+// operators and from CI jobs, with the typed HelmRelease API instead of
+// unstructured objects, e.g.
+//
+//	c, err := helmreleaseclient.New(cfg)
+//	hr := helmreleaseclient.NewHelmRelease("default", "nginx",
+//		helmreleaseclient.WithHelmRepo("https://charts.example.com/nginx-ingress-1.41.0.tgz", "nginx-ingress"))
+//	err = c.Create(ctx, hr)
+//	hr, err = c.WaitForReady(ctx, helmreleaseclient.Key(hr), 5*time.Second)
+package helmreleaseclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	apitypes "k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis"
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+	"github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/release"
+)
+
+// DefaultPollInterval is how often WaitForReady reads the HelmRelease when
+// no interval is given
+const DefaultPollInterval = 5 * time.Second
+
+// ErrStalled is returned by WaitForReady when the HelmRelease stalled for
+// its current generation, e.g. after exhausting its retries, it would not get
+// ready without a change.
+var ErrStalled = errors.New("HelmRelease stalled")
+
+// Client reads and writes the HelmReleases.
+type Client struct {
+	client.Client
+}
+
+// New returns a Client sending the requests with cfg.
+func New(cfg *rest.Config) (*Client, error) {
+	scheme, err := NewScheme()
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := client.New(cfg, client.Options{Scheme: scheme})
+	if err != nil {
+		return nil, err
+	}
+
+	return &Client{Client: c}, nil
+}
+
+// NewForClient returns a Client using c, e.g. the client of a
+// controller-runtime manager whose scheme has the HelmRelease types.
+func NewForClient(c client.Client) *Client {
+	return &Client{Client: c}
+}
+
+// NewScheme returns a scheme with the Kubernetes types and the HelmRelease
+// types.
+func NewScheme() (*runtime.Scheme, error) {
+	scheme := runtime.NewScheme()
+
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		return nil, err
+	}
+
+	if err := apis.AddToScheme(scheme); err != nil {
+		return nil, err
+	}
+
+	return scheme, nil
+}
+
+// Key returns the namespaced name of hr.
+func Key(hr *appv1.HelmRelease) apitypes.NamespacedName {
+	return apitypes.NamespacedName{Namespace: hr.GetNamespace(), Name: hr.GetName()}
+}
+
+// GetHelmRelease returns the HelmRelease key.
+func (c *Client) GetHelmRelease(ctx context.Context, key apitypes.NamespacedName) (*appv1.HelmRelease, error) {
+	hr := &appv1.HelmRelease{}
+	if err := c.Get(ctx, key, hr); err != nil {
+		return nil, err
+	}
+
+	return hr, nil
+}
+
+// ListHelmReleases returns the HelmReleases of namespace, of all the
+// namespaces if empty.
+func (c *Client) ListHelmReleases(ctx context.Context, namespace string, opts ...client.ListOption) ([]appv1.HelmRelease, error) {
+	if namespace != "" {
+		opts = append(opts, client.InNamespace(namespace))
+	}
+
+	list := &appv1.HelmReleaseList{}
+	if err := c.List(ctx, list, opts...); err != nil {
+		return nil, err
+	}
+
+	return list.Items, nil
+}
+
+// Suspend suspends the reconciles of the HelmRelease key, nothing is
+// installed, upgraded or uninstalled until it is resumed.
+func (c *Client) Suspend(ctx context.Context, key apitypes.NamespacedName) error {
+	return c.patch(ctx, key, func(hr *appv1.HelmRelease) {
+		hr.Repo.Suspend = true
+	})
+}
+
+// Resume resumes the reconciles of the suspended HelmRelease key.
+func (c *Client) Resume(ctx context.Context, key apitypes.NamespacedName) error {
+	return c.patch(ctx, key, func(hr *appv1.HelmRelease) {
+		hr.Repo.Suspend = false
+	})
+}
+
+// RequestReconcile requests a full reconcile of the HelmRelease key: its
+// chart is downloaded again and its values resolved again. It returns the
+// request, reported in status.lastHandledReconcileAt once it is handled.
+func (c *Client) RequestReconcile(ctx context.Context, key apitypes.NamespacedName) (string, error) {
+	requested := time.Now().UTC().Format(time.RFC3339Nano)
+
+	return requested, c.patch(ctx, key, func(hr *appv1.HelmRelease) {
+		annotations := hr.GetAnnotations()
+		if annotations == nil {
+			annotations = make(map[string]string)
+		}
+
+		annotations[release.ReconcileRequestAnnotation] = requested
+		hr.SetAnnotations(annotations)
+	})
+}
+
+// WaitForReady waits until the HelmRelease key is Ready for its current
+// generation and returns it. It reads the HelmRelease every interval,
+// DefaultPollInterval if 0, until ctx is done. It fails with ErrStalled if
+// the HelmRelease stalled.
+func (c *Client) WaitForReady(ctx context.Context, key apitypes.NamespacedName,
+	interval time.Duration) (*appv1.HelmRelease, error) {
+	if interval == 0 {
+		interval = DefaultPollInterval
+	}
+
+	var hr *appv1.HelmRelease
+
+	err := wait.PollImmediateUntil(interval, func() (bool, error) {
+		var err error
+		if hr, err = c.GetHelmRelease(ctx, key); err != nil {
+			return false, err
+		}
+
+		if stalled := StalledCondition(hr); stalled != nil {
+			return false, fmt.Errorf("%w: %s: %s", ErrStalled, stalled.Reason, stalled.Message)
+		}
+
+		return IsReady(hr), nil
+	}, ctx.Done())
+
+	if errors.Is(err, wait.ErrWaitTimeout) {
+		return hr, fmt.Errorf("HelmRelease %s is not ready: %w", key, ctx.Err())
+	}
+
+	return hr, err
+}
+
+// patch merge patches the HelmRelease key with the change of mutate.
+func (c *Client) patch(ctx context.Context, key apitypes.NamespacedName, mutate func(*appv1.HelmRelease)) error {
+	hr, err := c.GetHelmRelease(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	original := hr.DeepCopy()
+	mutate(hr)
+
+	return c.Patch(ctx, hr, client.MergeFrom(original))
+}
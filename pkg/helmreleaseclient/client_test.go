@@ -0,0 +1,125 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helmreleaseclient
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+	"github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/release"
+)
+
+func newTestClient(t *testing.T, hrs ...*appv1.HelmRelease) *Client {
+	scheme, err := NewScheme()
+	assert.NoError(t, err)
+
+	c := fake.NewFakeClientWithScheme(scheme)
+	for _, hr := range hrs {
+		assert.NoError(t, c.Create(context.TODO(), hr))
+	}
+
+	return NewForClient(c)
+}
+
+func withCondition(hr *appv1.HelmRelease, t appv1.HelmAppConditionType, status appv1.ConditionStatus,
+	reason appv1.HelmAppConditionReason) *appv1.HelmRelease {
+	hr.Status.SetCondition(appv1.HelmAppCondition{
+		Type:               t,
+		Status:             status,
+		Reason:             reason,
+		ObservedGeneration: hr.GetGeneration(),
+	})
+
+	return hr
+}
+
+func TestSuspendResume(t *testing.T) {
+	hr := NewHelmRelease("default", "nginx")
+	c := newTestClient(t, hr)
+
+	assert.NoError(t, c.Suspend(context.TODO(), Key(hr)))
+
+	got, err := c.GetHelmRelease(context.TODO(), Key(hr))
+	assert.NoError(t, err)
+	assert.True(t, got.Repo.Suspend)
+
+	assert.NoError(t, c.Resume(context.TODO(), Key(hr)))
+
+	got, err = c.GetHelmRelease(context.TODO(), Key(hr))
+	assert.NoError(t, err)
+	assert.False(t, got.Repo.Suspend)
+}
+
+func TestRequestReconcile(t *testing.T) {
+	hr := NewHelmRelease("default", "nginx", WithLabels(map[string]string{"app": "nginx"}))
+	c := newTestClient(t, hr)
+
+	requested, err := c.RequestReconcile(context.TODO(), Key(hr))
+	assert.NoError(t, err)
+	assert.NotEmpty(t, requested)
+
+	got, err := c.GetHelmRelease(context.TODO(), Key(hr))
+	assert.NoError(t, err)
+	assert.Equal(t, requested, got.GetAnnotations()[release.ReconcileRequestAnnotation])
+	assert.Equal(t, "nginx", got.GetLabels()["app"])
+}
+
+func TestListHelmReleases(t *testing.T) {
+	c := newTestClient(t, NewHelmRelease("default", "nginx"), NewHelmRelease("other", "nginx"))
+
+	hrs, err := c.ListHelmReleases(context.TODO(), "default")
+	assert.NoError(t, err)
+	assert.Len(t, hrs, 1)
+
+	hrs, err = c.ListHelmReleases(context.TODO(), "")
+	assert.NoError(t, err)
+	assert.Len(t, hrs, 2)
+}
+
+func TestWaitForReady(t *testing.T) {
+	hr := NewHelmRelease("default", "nginx")
+	hr.Generation = 2
+	c := newTestClient(t, withCondition(hr, appv1.ConditionReady, appv1.StatusTrue, appv1.ReasonReconcileSuccessful))
+
+	got, err := c.WaitForReady(context.TODO(), Key(hr), time.Millisecond)
+	assert.NoError(t, err)
+	assert.Equal(t, "nginx", got.GetName())
+}
+
+func TestWaitForReadyStalled(t *testing.T) {
+	hr := NewHelmRelease("default", "nginx")
+	c := newTestClient(t, withCondition(hr, appv1.ConditionStalled, appv1.StatusTrue, appv1.ReasonRetriesExhausted))
+
+	_, err := c.WaitForReady(context.TODO(), Key(hr), time.Millisecond)
+	assert.True(t, errors.Is(err, ErrStalled))
+	assert.Contains(t, err.Error(), "RetriesExhausted")
+}
+
+func TestWaitForReadyTimeout(t *testing.T) {
+	hr := NewHelmRelease("default", "nginx")
+	c := newTestClient(t, hr)
+
+	ctx, cancel := context.WithTimeout(context.TODO(), 20*time.Millisecond)
+	defer cancel()
+
+	_, err := c.WaitForReady(ctx, Key(hr), time.Millisecond)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+}
@@ -0,0 +1,84 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helmreleaseclient
+
+import (
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+// CurrentCondition returns the condition t of hr if it was set for the
+// current generation of hr, nil otherwise: the conditions of a previous
+// generation do not describe the current spec.
+func CurrentCondition(hr *appv1.HelmRelease, t appv1.HelmAppConditionType) *appv1.HelmAppCondition {
+	c := hr.Status.GetCondition(t)
+	if c == nil || c.ObservedGeneration != hr.GetGeneration() {
+		return nil
+	}
+
+	return c
+}
+
+// IsReady returns true if hr is Ready for its current generation: its
+// release is deployed and nothing keeps it from being up to date.
+func IsReady(hr *appv1.HelmRelease) bool {
+	if hr.GetDeletionTimestamp() != nil {
+		return false
+	}
+
+	ready := CurrentCondition(hr, appv1.ConditionReady)
+
+	return ready != nil && ready.Status == appv1.StatusTrue
+}
+
+// IsReleased returns true if the release of hr is deployed for its current
+// generation.
+func IsReleased(hr *appv1.HelmRelease) bool {
+	released := CurrentCondition(hr, appv1.ConditionReleased)
+
+	return released != nil && released.Status == appv1.StatusTrue
+}
+
+// StalledCondition returns the Stalled condition of hr if it stalled for its
+// current generation, e.g. after exhausting its retries, nil otherwise.
+func StalledCondition(hr *appv1.HelmRelease) *appv1.HelmAppCondition {
+	stalled := CurrentCondition(hr, appv1.ConditionStalled)
+	if stalled == nil || stalled.Status != appv1.StatusTrue {
+		return nil
+	}
+
+	return stalled
+}
+
+// NotReadyReason returns why hr is not ready, the reason and the message of
+// its Ready condition, empty if it is ready or not reconciled yet for its
+// current generation.
+func NotReadyReason(hr *appv1.HelmRelease) (appv1.HelmAppConditionReason, string) {
+	ready := CurrentCondition(hr, appv1.ConditionReady)
+	if ready == nil || ready.Status == appv1.StatusTrue {
+		return "", ""
+	}
+
+	return ready.Reason, ready.Message
+}
+
+// DeployedRevision returns the revision of the deployed release of hr, 0 if
+// none is deployed.
+func DeployedRevision(hr *appv1.HelmRelease) int {
+	if hr.Status.DeployedRelease == nil {
+		return 0
+	}
+
+	return hr.Status.DeployedRelease.Revision
+}
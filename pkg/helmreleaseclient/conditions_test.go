@@ -0,0 +1,79 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helmreleaseclient
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+func TestIsReady(t *testing.T) {
+	hr := NewHelmRelease("default", "nginx")
+	hr.Generation = 2
+	assert.False(t, IsReady(hr))
+
+	withCondition(hr, appv1.ConditionReady, appv1.StatusTrue, appv1.ReasonReconcileSuccessful)
+	assert.True(t, IsReady(hr))
+
+	// the condition of the previous generation
+	hr.Generation = 3
+	assert.False(t, IsReady(hr))
+	assert.Nil(t, CurrentCondition(hr, appv1.ConditionReady))
+
+	hr.Generation = 2
+	now := metav1.Now()
+	hr.DeletionTimestamp = &now
+	assert.False(t, IsReady(hr))
+}
+
+func TestNotReadyReason(t *testing.T) {
+	hr := NewHelmRelease("default", "nginx")
+
+	reason, message := NotReadyReason(hr)
+	assert.Empty(t, reason)
+	assert.Empty(t, message)
+
+	hr.Status.SetCondition(appv1.HelmAppCondition{
+		Type:    appv1.ConditionReady,
+		Status:  appv1.StatusFalse,
+		Reason:  appv1.ReasonDependencyNotReady,
+		Message: "HelmRelease default/cert-manager is not ready",
+	})
+
+	reason, message = NotReadyReason(hr)
+	assert.Equal(t, appv1.ReasonDependencyNotReady, reason)
+	assert.Equal(t, "HelmRelease default/cert-manager is not ready", message)
+	assert.False(t, IsReleased(hr))
+}
+
+func TestStalledCondition(t *testing.T) {
+	hr := NewHelmRelease("default", "nginx")
+	assert.Nil(t, StalledCondition(hr))
+
+	withCondition(hr, appv1.ConditionStalled, appv1.StatusTrue, appv1.ReasonRetriesExhausted)
+	assert.Equal(t, appv1.ReasonRetriesExhausted, StalledCondition(hr).Reason)
+}
+
+func TestDeployedRevision(t *testing.T) {
+	hr := NewHelmRelease("default", "nginx")
+	assert.Equal(t, 0, DeployedRevision(hr))
+
+	hr.Status.DeployedRelease = &appv1.HelmAppRelease{Revision: 3}
+	assert.Equal(t, 3, DeployedRevision(hr))
+}
@@ -0,0 +1,125 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helmreleaseclient
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+// Option sets a setting of the HelmRelease built by NewHelmRelease.
+type Option func(hr *appv1.HelmRelease)
+
+// NewHelmRelease returns the HelmRelease name of namespace with the settings
+// of opts, ready to be created.
+func NewHelmRelease(namespace, name string, opts ...Option) *appv1.HelmRelease {
+	hr := &appv1.HelmRelease{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: appv1.SchemeGroupVersion.String(),
+			Kind:       "HelmRelease",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+	}
+
+	for _, opt := range opts {
+		opt(hr)
+	}
+
+	return hr
+}
+
+// WithHelmRepo downloads the chart chartName from the urls of its archive,
+// the first one that serves it.
+func WithHelmRepo(url, chartName string, alternates ...string) Option {
+	return func(hr *appv1.HelmRelease) {
+		hr.Repo.Source = &appv1.Source{
+			SourceType: appv1.HelmRepoSourceType,
+			HelmRepo:   &appv1.HelmRepo{Urls: append([]string{url}, alternates...)},
+		}
+		hr.Repo.ChartName = chartName
+	}
+}
+
+// WithGit downloads the chart chartName from the chartPath directory of the
+// branch of the git repo url.
+func WithGit(url, branch, chartPath, chartName string) Option {
+	return func(hr *appv1.HelmRelease) {
+		hr.Repo.Source = &appv1.Source{
+			SourceType: appv1.GitSourceType,
+			Git:        &appv1.Git{Urls: []string{url}, Branch: branch, ChartPath: chartPath},
+		}
+		hr.Repo.ChartName = chartName
+	}
+}
+
+// WithVersion sets the version of the chart, or a semver constraint.
+func WithVersion(version string) Option {
+	return func(hr *appv1.HelmRelease) {
+		hr.Repo.Version = version
+	}
+}
+
+// WithValues sets the values of the chart.
+func WithValues(values map[string]interface{}) Option {
+	return func(hr *appv1.HelmRelease) {
+		hr.Spec = values
+	}
+}
+
+// WithTargetNamespace deploys the chart to namespace instead of the namespace
+// of the HelmRelease.
+func WithTargetNamespace(namespace string) Option {
+	return func(hr *appv1.HelmRelease) {
+		hr.Repo.TargetNamespace = namespace
+	}
+}
+
+// WithWait waits for the resources of the release to be ready before the
+// install or the upgrade succeeds.
+func WithWait() Option {
+	return func(hr *appv1.HelmRelease) {
+		hr.Repo.Wait = true
+	}
+}
+
+// WithDependsOn deploys the chart once the HelmReleases name of the same
+// namespace are ready.
+func WithDependsOn(names ...string) Option {
+	return func(hr *appv1.HelmRelease) {
+		for _, name := range names {
+			hr.Repo.DependsOn = append(hr.Repo.DependsOn, appv1.DependencyReference{Name: name})
+		}
+	}
+}
+
+// WithLabels adds labels to the HelmRelease.
+func WithLabels(labels map[string]string) Option {
+	return func(hr *appv1.HelmRelease) {
+		merged := hr.GetLabels()
+		if merged == nil {
+			merged = make(map[string]string, len(labels))
+		}
+
+		for k, v := range labels {
+			merged[k] = v
+		}
+
+		hr.SetLabels(merged)
+	}
+}
@@ -0,0 +1,64 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helmreleaseclient
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+func TestNewHelmRelease(t *testing.T) {
+	hr := NewHelmRelease("default", "nginx",
+		WithHelmRepo("https://charts.example.com/nginx-ingress-1.41.0.tgz", "nginx-ingress",
+			"https://mirror.example.com/nginx-ingress-1.41.0.tgz"),
+		WithVersion("1.41.0"),
+		WithValues(map[string]interface{}{"replicaCount": 2}),
+		WithTargetNamespace("ingress"),
+		WithWait(),
+		WithDependsOn("cert-manager"),
+		WithLabels(map[string]string{"app": "nginx"}))
+
+	assert.Equal(t, "HelmRelease", hr.Kind)
+	assert.Equal(t, appv1.SchemeGroupVersion.String(), hr.APIVersion)
+	assert.Equal(t, "default", hr.Namespace)
+	assert.Equal(t, "nginx", hr.Name)
+	assert.Equal(t, appv1.HelmRepoSourceType, hr.Repo.Source.SourceType)
+	assert.Equal(t, []string{
+		"https://charts.example.com/nginx-ingress-1.41.0.tgz",
+		"https://mirror.example.com/nginx-ingress-1.41.0.tgz",
+	}, hr.Repo.Source.HelmRepo.Urls)
+	assert.Equal(t, "nginx-ingress", hr.Repo.ChartName)
+	assert.Equal(t, "1.41.0", hr.Repo.Version)
+	assert.Equal(t, map[string]interface{}{"replicaCount": 2}, hr.Spec)
+	assert.Equal(t, "ingress", hr.Repo.TargetNamespace)
+	assert.True(t, hr.Repo.Wait)
+	assert.Equal(t, []appv1.DependencyReference{{Name: "cert-manager"}}, hr.Repo.DependsOn)
+	assert.Equal(t, map[string]string{"app": "nginx"}, hr.Labels)
+}
+
+func TestWithGit(t *testing.T) {
+	hr := NewHelmRelease("default", "nginx", WithGit("https://github.com/example/charts", "main", "charts/nginx", "nginx"))
+
+	assert.Equal(t, appv1.GitSourceType, hr.Repo.Source.SourceType)
+	assert.Equal(t, &appv1.Git{
+		Urls:      []string{"https://github.com/example/charts"},
+		Branch:    "main",
+		ChartPath: "charts/nginx",
+	}, hr.Repo.Source.Git)
+	assert.Equal(t, "nginx", hr.Repo.ChartName)
+}
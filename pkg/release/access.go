@@ -0,0 +1,153 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/kubernetes"
+)
+
+// accessVerbs are the verbs an install or upgrade needs on each rendered
+// resource: get to compare it, create if it does not exist and patch if it
+// does.
+var accessVerbs = []string{"get", "create", "patch"}
+
+// MissingPermissions renders the release and returns the permissions the
+// identity deploying it, the operator or the impersonated ServiceAccount,
+// lacks on its resources, e.g. "create apps/deployments in namespace web".
+// They are checked with SelfSubjectAccessReviews. The kinds defined by the
+// CRDs of the chart are not known before the install and are not checked,
+// neither are the hooks, the pruned resources and the release records.
+func (m manager) MissingPermissions(ctx context.Context) ([]string, error) {
+	objects, err := m.Render(ctx, RenderOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	restMapper, err := m.actionConfig.RESTClientGetter.ToRESTMapper()
+	if err != nil {
+		return nil, err
+	}
+
+	attributes, err := accessAttributes(objects, restMapper)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg, err := m.actionConfig.RESTClientGetter.ToRESTConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	clientset, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	var missing []string
+
+	for _, attrs := range attributes {
+		review := &authorizationv1.SelfSubjectAccessReview{
+			Spec: authorizationv1.SelfSubjectAccessReviewSpec{ResourceAttributes: attrs.DeepCopy()},
+		}
+
+		result, err := clientset.AuthorizationV1().SelfSubjectAccessReviews().Create(ctx, review, metav1.CreateOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to review access to %s: %w", permission(attrs), err)
+		}
+
+		if !result.Status.Allowed {
+			missing = append(missing, permission(attrs))
+		}
+	}
+
+	return missing, nil
+}
+
+// accessAttributes returns the attributes to review for objects, once per
+// verb, resource and namespace, sorted. The names are not reviewed: the
+// permissions of a resource are rarely granted by name and the create
+// permission cannot be.
+func accessAttributes(objects []*unstructured.Unstructured, restMapper meta.RESTMapper) ([]*authorizationv1.ResourceAttributes, error) {
+	seen := make(map[authorizationv1.ResourceAttributes]bool)
+
+	var attributes []*authorizationv1.ResourceAttributes
+
+	for _, u := range objects {
+		gvk := u.GroupVersionKind()
+
+		mapping, err := restMapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+		if meta.IsNoMatchError(err) {
+			// defined by the CRDs of the chart
+			continue
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to get the resource of %s: %w", gvk.Kind, err)
+		}
+
+		for _, verb := range accessVerbs {
+			attrs := authorizationv1.ResourceAttributes{
+				Namespace: u.GetNamespace(),
+				Verb:      verb,
+				Group:     mapping.Resource.Group,
+				Resource:  mapping.Resource.Resource,
+			}
+
+			if seen[attrs] {
+				continue
+			}
+
+			seen[attrs] = true
+			attributes = append(attributes, &attrs)
+		}
+	}
+
+	sort.SliceStable(attributes, func(i, j int) bool {
+		a, b := attributes[i], attributes[j]
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+
+		if a.Group != b.Group {
+			return a.Group < b.Group
+		}
+
+		return a.Resource < b.Resource
+	})
+
+	return attributes, nil
+}
+
+// permission describes attrs, e.g. "create apps/deployments in namespace web".
+func permission(attrs *authorizationv1.ResourceAttributes) string {
+	resource := attrs.Resource
+	if attrs.Group != "" {
+		resource = attrs.Group + "/" + resource
+	}
+
+	if attrs.Namespace == "" {
+		return fmt.Sprintf("%s %s (cluster-scoped)", attrs.Verb, resource)
+	}
+
+	return fmt.Sprintf("%s %s in namespace %s", attrs.Verb, resource, attrs.Namespace)
+}
@@ -0,0 +1,78 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestAccessAttributes(t *testing.T) {
+	restMapper := meta.NewDefaultRESTMapper(nil)
+	restMapper.Add(schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}, meta.RESTScopeNamespace)
+	restMapper.Add(schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}, meta.RESTScopeNamespace)
+	restMapper.Add(schema.GroupVersionKind{Group: "rbac.authorization.k8s.io", Version: "v1", Kind: "ClusterRole"},
+		meta.RESTScopeRoot)
+
+	object := func(apiVersion, kind, namespace, name string) *unstructured.Unstructured {
+		u := &unstructured.Unstructured{}
+		u.SetAPIVersion(apiVersion)
+		u.SetKind(kind)
+		u.SetNamespace(namespace)
+		u.SetName(name)
+
+		return u
+	}
+
+	attributes, err := accessAttributes([]*unstructured.Unstructured{
+		object("apps/v1", "Deployment", "web", "frontend"),
+		object("apps/v1", "Deployment", "web", "backend"),
+		object("v1", "ConfigMap", "web", "settings"),
+		object("rbac.authorization.k8s.io/v1", "ClusterRole", "", "reader"),
+		object("example.com/v1", "Widget", "web", "defined-by-the-chart"),
+	}, restMapper)
+	assert.NoError(t, err)
+
+	var permissions []string
+	for _, attrs := range attributes {
+		permissions = append(permissions, permission(attrs))
+	}
+
+	assert.Equal(t, []string{
+		"get rbac.authorization.k8s.io/clusterroles (cluster-scoped)",
+		"create rbac.authorization.k8s.io/clusterroles (cluster-scoped)",
+		"patch rbac.authorization.k8s.io/clusterroles (cluster-scoped)",
+		"get configmaps in namespace web",
+		"create configmaps in namespace web",
+		"patch configmaps in namespace web",
+		"get apps/deployments in namespace web",
+		"create apps/deployments in namespace web",
+		"patch apps/deployments in namespace web",
+	}, permissions)
+}
+
+func TestPermission(t *testing.T) {
+	assert.Equal(t, "create apps/deployments in namespace web", permission(&authorizationv1.ResourceAttributes{
+		Namespace: "web", Verb: "create", Group: "apps", Resource: "deployments",
+	}))
+	assert.Equal(t, "patch namespaces (cluster-scoped)", permission(&authorizationv1.ResourceAttributes{
+		Verb: "patch", Resource: "namespaces",
+	}))
+}
@@ -0,0 +1,176 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"helm.sh/helm/v3/pkg/kube"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	apitypes "k8s.io/apimachinery/pkg/types"
+	"k8s.io/cli-runtime/pkg/resource"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+// DefaultFieldManager is the field manager recorded in managedFields for the
+// resources applied by the operator.
+const DefaultFieldManager = appv1.DefaultFieldManager
+
+var _ kube.Interface = &serverSideApplyClient{}
+
+// serverSideApplyClient applies the resources of a release with server-side
+// apply instead of the client-side strategic-merge/JSON-merge patches computed
+// by the helm kube client. Everything other than Create and Update (Build,
+// Wait, Delete, ...) is delegated to the wrapped client.
+type serverSideApplyClient struct {
+	kube.Interface
+	fieldManager string
+	force        bool
+}
+
+func newServerSideApplyClient(base kube.Interface, fieldManager string, policy appv1.ConflictPolicyEnum) kube.Interface {
+	if fieldManager == "" {
+		fieldManager = DefaultFieldManager
+	}
+
+	return &serverSideApplyClient{
+		Interface:    base,
+		fieldManager: fieldManager,
+		force:        policy == appv1.ForceConflictPolicy,
+	}
+}
+
+// Create applies every resource of the list.
+func (c *serverSideApplyClient) Create(resources kube.ResourceList) (*kube.Result, error) {
+	if err := resources.Visit(func(info *resource.Info, err error) error {
+		if err != nil {
+			return err
+		}
+		return c.apply(info)
+	}); err != nil {
+		return nil, err
+	}
+
+	return &kube.Result{Created: resources}, nil
+}
+
+// Update applies every resource of target and deletes the resources of
+// original that are no longer part of target, the same way the helm kube
+// client does.
+func (c *serverSideApplyClient) Update(original, target kube.ResourceList, force bool) (*kube.Result, error) {
+	res := &kube.Result{}
+
+	if err := target.Visit(func(info *resource.Info, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if err := c.apply(info); err != nil {
+			return err
+		}
+
+		if original.Get(info) == nil {
+			res.Created = append(res.Created, info)
+		} else {
+			res.Updated = append(res.Updated, info)
+		}
+
+		return nil
+	}); err != nil {
+		return res, err
+	}
+
+	for _, info := range original.Difference(target) {
+		if err := info.Get(); err != nil {
+			continue
+		}
+
+		annotations, err := meta.NewAccessor().Annotations(info.Object)
+		if err == nil && annotations != nil && annotations[kube.ResourcePolicyAnno] == kube.KeepPolicy {
+			continue
+		}
+
+		if err := deleteResource(info); err != nil && !apierrors.IsNotFound(err) {
+			return res, fmt.Errorf("failed to delete %s %q: %w", info.Mapping.GroupVersionKind.Kind, info.Name, err)
+		}
+
+		res.Deleted = append(res.Deleted, info)
+	}
+
+	return res, nil
+}
+
+// apply sends the object of info to the API server as an apply patch and
+// refreshes info with the object returned by the server. Conflicts with other
+// field managers are returned as an ErrApplyConflict unless forced.
+func (c *serverSideApplyClient) apply(info *resource.Info) error {
+	data, err := json.Marshal(info.Object)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s %q: %w", info.Mapping.GroupVersionKind.Kind, info.Name, err)
+	}
+
+	force := c.force
+	helper := resource.NewHelper(info.Client, info.Mapping)
+
+	obj, err := helper.Patch(info.Namespace, info.Name, apitypes.ApplyPatchType, data, &metav1.PatchOptions{
+		FieldManager: c.fieldManager,
+		Force:        &force,
+	})
+	if apierrors.IsConflict(err) {
+		if conflicts := applyConflicts(err); len(conflicts) > 0 {
+			return &ErrApplyConflict{Resource: resourceRefs(kube.ResourceList{info})[0], Conflicts: conflicts}
+		}
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to apply %s %q: %w", info.Mapping.GroupVersionKind.Kind, info.Name, err)
+	}
+
+	return info.Refresh(obj, true)
+}
+
+func deleteResource(info *resource.Info) error {
+	policy := metav1.DeletePropagationBackground
+
+	_, err := resource.NewHelper(info.Client, info.Mapping).DeleteWithOptions(info.Namespace, info.Name,
+		&metav1.DeleteOptions{PropagationPolicy: &policy})
+
+	return err
+}
+
+// applyConflicts returns the field manager conflicts reported by a failed
+// apply, e.g. `conflict with "kube-controller-manager" using apps/v1: .spec.replicas`.
+func applyConflicts(err error) []string {
+	status, ok := err.(apierrors.APIStatus)
+	if !ok || status.Status().Details == nil {
+		return nil
+	}
+
+	var conflicts []string
+
+	for _, cause := range status.Status().Details.Causes {
+		if cause.Type != metav1.CauseTypeFieldManagerConflict {
+			continue
+		}
+
+		conflicts = append(conflicts, fmt.Sprintf("%s: %s", cause.Message, cause.Field))
+	}
+
+	return conflicts
+}
@@ -0,0 +1,262 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"helm.sh/helm/v3/pkg/kube"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	apitypes "k8s.io/apimachinery/pkg/types"
+	"k8s.io/cli-runtime/pkg/resource"
+	fakerest "k8s.io/client-go/rest/fake"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+// applyRequest is a request received by the fake API server of the apply
+// tests.
+type applyRequest struct {
+	method      string
+	path        string
+	query       url.Values
+	contentType string
+	body        []byte
+}
+
+// applyServer is a fake API server of ConfigMaps, which stores the apply
+// patches as the whole object and records the requests.
+type applyServer struct {
+	objects  map[string][]byte
+	requests []applyRequest
+	// conflicts are returned by the apply patches when not forced
+	conflicts []metav1.StatusCause
+}
+
+func newApplyServer() *applyServer {
+	return &applyServer{objects: map[string][]byte{}}
+}
+
+func (s *applyServer) handle(req *http.Request) (*http.Response, error) {
+	var body []byte
+
+	if req.Body != nil {
+		var err error
+		if body, err = ioutil.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+	}
+
+	s.requests = append(s.requests, applyRequest{
+		method:      req.Method,
+		path:        req.URL.Path,
+		query:       req.URL.Query(),
+		contentType: req.Header.Get("Content-Type"),
+		body:        body,
+	})
+
+	switch req.Method {
+	case http.MethodGet:
+		if obj, ok := s.objects[req.URL.Path]; ok {
+			return applyResponse(http.StatusOK, obj), nil
+		}
+	case http.MethodPatch:
+		if len(s.conflicts) > 0 && req.URL.Query().Get("force") != "true" {
+			return applyStatus(http.StatusConflict, metav1.StatusReasonConflict, s.conflicts), nil
+		}
+
+		s.objects[req.URL.Path] = body
+		return applyResponse(http.StatusOK, body), nil
+	case http.MethodDelete:
+		if _, ok := s.objects[req.URL.Path]; ok {
+			delete(s.objects, req.URL.Path)
+			return applyStatus(http.StatusOK, "", nil), nil
+		}
+	}
+
+	return applyStatus(http.StatusNotFound, metav1.StatusReasonNotFound, nil), nil
+}
+
+func (s *applyServer) requestsOf(method string) []applyRequest {
+	var requests []applyRequest
+
+	for _, req := range s.requests {
+		if req.method == method {
+			requests = append(requests, req)
+		}
+	}
+
+	return requests
+}
+
+func applyResponse(code int, body []byte) *http.Response {
+	return &http.Response{
+		StatusCode: code,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       ioutil.NopCloser(bytes.NewReader(body)),
+	}
+}
+
+func applyStatus(code int, reason metav1.StatusReason, causes []metav1.StatusCause) *http.Response {
+	status := &metav1.Status{
+		TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "Status"},
+		Status:   metav1.StatusSuccess,
+		Code:     int32(code),
+		Reason:   reason,
+	}
+
+	if code >= http.StatusBadRequest {
+		status.Status = metav1.StatusFailure
+		status.Details = &metav1.StatusDetails{Causes: causes}
+	}
+
+	body, _ := json.Marshal(status)
+
+	return applyResponse(code, body)
+}
+
+// newApplyTestInfo returns the ConfigMap name served by s.
+func newApplyTestInfo(s *applyServer, name string, annotations map[string]string) *resource.Info {
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion("v1")
+	obj.SetKind("ConfigMap")
+	obj.SetNamespace("default")
+	obj.SetName(name)
+	obj.SetAnnotations(annotations)
+
+	return &resource.Info{
+		Client: &fakerest.RESTClient{
+			NegotiatedSerializer: resource.UnstructuredPlusDefaultContentConfig().NegotiatedSerializer,
+			Client:               fakerest.CreateHTTPClient(s.handle),
+		},
+		Mapping: &meta.RESTMapping{
+			Resource:         schema.GroupVersionResource{Version: "v1", Resource: "configmaps"},
+			GroupVersionKind: schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"},
+			Scope:            meta.RESTScopeNamespace,
+		},
+		Namespace: "default",
+		Name:      name,
+		Object:    obj,
+	}
+}
+
+func configMapPath(name string) string {
+	return "/namespaces/default/configmaps/" + name
+}
+
+func infoNames(resources kube.ResourceList) []string {
+	var names []string
+
+	for _, info := range resources {
+		names = append(names, info.Name)
+	}
+
+	return names
+}
+
+func TestServerSideApplyCreate(t *testing.T) {
+	s := newApplyServer()
+
+	res, err := newServerSideApplyClient(nil, "", appv1.FailConflictPolicy).
+		Create(kube.ResourceList{newApplyTestInfo(s, "first", nil), newApplyTestInfo(s, "second", nil)})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"first", "second"}, infoNames(res.Created))
+
+	patches := s.requestsOf(http.MethodPatch)
+	require.Len(t, patches, 2)
+
+	for i, name := range []string{"first", "second"} {
+		assert.Equal(t, configMapPath(name), patches[i].path)
+		assert.Equal(t, string(apitypes.ApplyPatchType), patches[i].contentType)
+		assert.Equal(t, DefaultFieldManager, patches[i].query.Get("fieldManager"))
+		assert.Equal(t, "false", patches[i].query.Get("force"))
+	}
+
+	// the field manager can be overridden
+	s.requests = nil
+
+	_, err = newServerSideApplyClient(nil, "gitops", appv1.FailConflictPolicy).Create(kube.ResourceList{newApplyTestInfo(s, "first", nil)})
+	require.NoError(t, err)
+	assert.Equal(t, "gitops", s.requestsOf(http.MethodPatch)[0].query.Get("fieldManager"))
+}
+
+func TestServerSideApplyConflict(t *testing.T) {
+	s := newApplyServer()
+	s.conflicts = []metav1.StatusCause{{
+		Type:    metav1.CauseTypeFieldManagerConflict,
+		Message: `conflict with "kubectl"`,
+		Field:   ".data.key",
+	}}
+
+	_, err := newServerSideApplyClient(nil, "", appv1.FailConflictPolicy).
+		Create(kube.ResourceList{newApplyTestInfo(s, "first", nil)})
+
+	var conflict *ErrApplyConflict
+
+	require.True(t, errors.As(err, &conflict), "unexpected error %v", err)
+	assert.Equal(t, "first", conflict.Resource.Name)
+	assert.Equal(t, []string{`conflict with "kubectl": .data.key`}, conflict.Conflicts)
+
+	// forced, the fields are taken over
+	s.requests = nil
+
+	_, err = newServerSideApplyClient(nil, "", appv1.ForceConflictPolicy).
+		Create(kube.ResourceList{newApplyTestInfo(s, "first", nil)})
+	require.NoError(t, err)
+	assert.Equal(t, "true", s.requestsOf(http.MethodPatch)[0].query.Get("force"))
+}
+
+func TestServerSideApplyUpdate(t *testing.T) {
+	s := newApplyServer()
+	c := newServerSideApplyClient(nil, "", appv1.FailConflictPolicy)
+
+	original := kube.ResourceList{
+		newApplyTestInfo(s, "kept", nil),
+		newApplyTestInfo(s, "removed", nil),
+		newApplyTestInfo(s, "policy-kept", map[string]string{kube.ResourcePolicyAnno: kube.KeepPolicy}),
+		newApplyTestInfo(s, "already-deleted", nil),
+	}
+
+	_, err := c.Create(original[:3])
+	require.NoError(t, err)
+
+	s.requests = nil
+
+	res, err := c.Update(original, kube.ResourceList{newApplyTestInfo(s, "kept", nil), newApplyTestInfo(s, "added", nil)}, false)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"added"}, infoNames(res.Created))
+	assert.Equal(t, []string{"kept"}, infoNames(res.Updated))
+	assert.Equal(t, []string{"removed"}, infoNames(res.Deleted))
+
+	// only the resource removed from the release without the keep policy is deleted
+	deletes := s.requestsOf(http.MethodDelete)
+	require.Len(t, deletes, 1)
+	assert.Equal(t, configMapPath("removed"), deletes[0].path)
+	assert.Contains(t, string(deletes[0].body), `"propagationPolicy":"Background"`)
+
+	_, kept := s.objects[configMapPath("policy-kept")]
+	assert.True(t, kept)
+}
@@ -0,0 +1,163 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	rpb "helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/storage/driver"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+// BackupVersion is the version of the format of the backups.
+const BackupVersion = "v1"
+
+// Backup is a disaster recovery archive of HelmReleases, their status
+// included, and of the records of their releases, so that a rebuilt cluster
+// upgrades the existing releases instead of installing them again.
+type Backup struct {
+	Version      string              `json:"version"`
+	Time         metav1.Time         `json:"time"`
+	HelmReleases []BackupHelmRelease `json:"helmReleases"`
+}
+
+// BackupHelmRelease is a HelmRelease and the records of its release.
+type BackupHelmRelease struct {
+	HelmRelease appv1.HelmRelease `json:"helmRelease"`
+	// StorageNamespace is the namespace of the records
+	StorageNamespace string         `json:"storageNamespace,omitempty"`
+	Records          []*rpb.Release `json:"records,omitempty"`
+}
+
+// WriteBackup writes backup to w, gzipped JSON.
+func WriteBackup(w io.Writer, backup *Backup) error {
+	zw := gzip.NewWriter(w)
+
+	if err := json.NewEncoder(zw).Encode(backup); err != nil {
+		return fmt.Errorf("failed to encode the backup: %w", err)
+	}
+
+	return zw.Close()
+}
+
+// ReadBackup reads a backup written by WriteBackup from r.
+func ReadBackup(r io.Reader) (*Backup, error) {
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the backup: %w", err)
+	}
+	defer zr.Close()
+
+	backup := &Backup{}
+	if err := json.NewDecoder(zr).Decode(backup); err != nil {
+		return nil, fmt.Errorf("failed to decode the backup: %w", err)
+	}
+
+	if backup.Version != BackupVersion {
+		return nil, fmt.Errorf("unsupported backup version %q, expected %s", backup.Version, BackupVersion)
+	}
+
+	return backup, nil
+}
+
+// ReleaseRecords returns the records of the release name of the storage
+// namespace, with their chart, the oldest first. It is empty if the release
+// does not exist.
+func ReleaseRecords(cfg *rest.Config, opts StorageOptions, storageNamespace, name string) ([]*rpb.Release, error) {
+	storageBackend, err := newStorage(cfg, opts, storageNamespace)
+	if err != nil {
+		return nil, err
+	}
+
+	history, err := storageBackend.History(name)
+	if notFoundErr(err) {
+		return nil, nil
+	}
+
+	if err == nil {
+		history, err = fullReleases(storageBackend, history)
+	}
+
+	if err != nil {
+		return nil, storageFailed(fmt.Errorf("failed to get release history: %w", err))
+	}
+
+	sortByRevision(history)
+
+	return history, nil
+}
+
+// ImportReleaseRecords creates the records missing from the storage
+// namespace, labeled with the HelmRelease name of namespace, and returns the
+// number of created records. The existing records are left as is.
+func ImportReleaseRecords(cfg *rest.Config, opts StorageOptions, storageNamespace, name, namespace string,
+	records []*rpb.Release) (int, error) {
+	storageBackend, err := newStorage(cfg, opts, storageNamespace)
+	if err != nil {
+		return 0, err
+	}
+
+	labelRecord, err := newRecordLabeler(cfg, opts, storageNamespace, name, namespace)
+	if err != nil {
+		return 0, err
+	}
+
+	created := 0
+
+	for _, rel := range records {
+		if err := storageBackend.Create(rel); err != nil {
+			if errors.Is(err, driver.ErrReleaseExists) {
+				continue
+			}
+
+			return created, storageFailed(fmt.Errorf("failed to create release record %s: %w", recordKey(rel), err))
+		}
+
+		if labelRecord != nil {
+			if err := labelRecord(rel); err != nil {
+				return created, fmt.Errorf("failed to label release record %s: %w", recordKey(rel), err)
+			}
+		}
+
+		created++
+	}
+
+	return created, nil
+}
+
+// RestoredHelmRelease returns the HelmRelease of a backup to create in a
+// rebuilt cluster: the metadata set by the API server, the owner references
+// and the finalizers are dropped. Its status is kept but not observed: the
+// generations start over in the rebuilt cluster.
+func RestoredHelmRelease(hr *appv1.HelmRelease) *appv1.HelmRelease {
+	restored := hr.DeepCopy()
+	restored.ObjectMeta = metav1.ObjectMeta{
+		Name:        hr.Name,
+		Namespace:   hr.Namespace,
+		Labels:      restored.Labels,
+		Annotations: restored.Annotations,
+	}
+	restored.Status.ObservedGeneration = 0
+
+	return restored
+}
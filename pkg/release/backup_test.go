@@ -0,0 +1,84 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	rpb "helm.sh/helm/v3/pkg/release"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+func TestBackup(t *testing.T) {
+	backup := &Backup{
+		Version: BackupVersion,
+		HelmReleases: []BackupHelmRelease{{
+			HelmRelease: appv1.HelmRelease{
+				ObjectMeta: metav1.ObjectMeta{Name: "nginx", Namespace: "apps"},
+				Repo:       appv1.HelmReleaseRepo{ChartName: "nginx", Version: "1.2.0"},
+			},
+			StorageNamespace: "apps",
+			Records:          []*rpb.Release{newTestRelease(1, rpb.StatusSuperseded), newTestRelease(2, rpb.StatusDeployed)},
+		}},
+	}
+
+	buf := &bytes.Buffer{}
+	assert.NoError(t, WriteBackup(buf, backup))
+
+	read, err := ReadBackup(buf)
+	assert.NoError(t, err)
+	assert.Len(t, read.HelmReleases, 1)
+	assert.Equal(t, "nginx", read.HelmReleases[0].HelmRelease.Repo.ChartName)
+	assert.Len(t, read.HelmReleases[0].Records, 2)
+	assert.Equal(t, rpb.StatusDeployed, read.HelmReleases[0].Records[1].Info.Status)
+
+	backup.Version = "v0"
+	buf.Reset()
+	assert.NoError(t, WriteBackup(buf, backup))
+
+	_, err = ReadBackup(buf)
+	assert.Error(t, err)
+}
+
+func TestRestoredHelmRelease(t *testing.T) {
+	hr := &appv1.HelmRelease{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "nginx",
+			Namespace:       "apps",
+			Labels:          map[string]string{"team": "web"},
+			UID:             "1234",
+			ResourceVersion: "42",
+			Generation:      7,
+			Finalizers:      []string{"uninstall-helm-release"},
+			OwnerReferences: []metav1.OwnerReference{{Kind: "Subscription", Name: "nginx", UID: "5678"}},
+		},
+		Status: appv1.HelmAppStatus{
+			ObservedGeneration: 7,
+			DeployedRelease:    &appv1.HelmAppRelease{Name: "nginx", Revision: 3},
+		},
+	}
+
+	restored := RestoredHelmRelease(hr)
+
+	assert.Equal(t, metav1.ObjectMeta{Name: "nginx", Namespace: "apps", Labels: map[string]string{"team": "web"}},
+		restored.ObjectMeta)
+	assert.Equal(t, int64(0), restored.Status.ObservedGeneration)
+	assert.Equal(t, 3, restored.Status.DeployedRelease.Revision)
+	assert.Equal(t, int64(7), hr.Status.ObservedGeneration)
+}
@@ -0,0 +1,218 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	cpb "helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chart/loader"
+)
+
+// DefaultLoadedChartsCacheSize is the number of distinct loaded charts kept in
+// memory by default
+const DefaultLoadedChartsCacheSize = 100
+
+// loadedCharts holds a single loaded chart per chart content, shared by the
+// releases of the same chart version, e.g. the HelmReleases of a hub deploying
+// the same chart to many namespaces. The least recently loaded charts are
+// dropped first, the releases keep their copies.
+var loadedCharts = newChartCache(DefaultLoadedChartsCacheSize)
+
+type chartCache struct {
+	mu      sync.Mutex
+	size    int
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+type chartCacheEntry struct {
+	digest string
+	chart  *cpb.Chart
+}
+
+func newChartCache(size int) *chartCache {
+	return &chartCache{size: size, order: list.New(), entries: map[string]*list.Element{}}
+}
+
+// get returns the chart of digest, if cached.
+func (c *chartCache) get(digest string) (*cpb.Chart, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[digest]
+	if !ok {
+		return nil, false
+	}
+
+	c.order.MoveToFront(e)
+
+	return e.Value.(*chartCacheEntry).chart, true
+}
+
+// add caches chrt as the chart of digest and returns the chart cached for it,
+// chrt unless another one was cached first.
+func (c *chartCache) add(digest string, chrt *cpb.Chart) *cpb.Chart {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e, ok := c.entries[digest]; ok {
+		c.order.MoveToFront(e)
+		return e.Value.(*chartCacheEntry).chart
+	}
+
+	c.entries[digest] = c.order.PushFront(&chartCacheEntry{digest: digest, chart: chrt})
+
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*chartCacheEntry).digest)
+	}
+
+	return chrt
+}
+
+// LoadChart loads the chart archive or directory at path. The identical
+// charts are only held once in memory: the templates and files of the returned
+// chart are shared with the other releases of the same chart content, and must
+// not be modified. Its metadata, values and dependencies are copies, which helm
+// modifies when processing the dependencies of a release.
+func LoadChart(path string) (*cpb.Chart, error) {
+	chrt, _, err := loadChart(path)
+
+	return chrt, err
+}
+
+// loadChart loads the chart at path like LoadChart and returns the digest of
+// its content as well.
+func loadChart(path string) (*cpb.Chart, string, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// an archive is hashed instead of being loaded when its chart is cached
+	if !fi.IsDir() {
+		digest, err := fileDigest(path)
+		if err != nil {
+			return nil, "", err
+		}
+
+		if chrt, ok := loadedCharts.get(digest); ok {
+			return copyChart(chrt), digest, nil
+		}
+
+		chrt, err := loader.Load(path)
+		if err != nil {
+			return nil, "", err
+		}
+
+		return copyChart(loadedCharts.add(digest, chrt)), digest, nil
+	}
+
+	chrt, err := loader.Load(path)
+	if err != nil {
+		return nil, "", err
+	}
+
+	digest := ChartDigest(chrt)
+	if digest == "" {
+		return chrt, "", nil
+	}
+
+	return copyChart(loadedCharts.add(digest, chrt)), digest, nil
+}
+
+func fileDigest(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("failed to read chart archive %s: %w", path, err)
+	}
+
+	return "sha256:" + hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// copyChart returns a copy of chrt and of its dependencies sharing their
+// templates and files.
+func copyChart(chrt *cpb.Chart) *cpb.Chart {
+	out := *chrt
+
+	if chrt.Metadata != nil {
+		metadata := *chrt.Metadata
+		metadata.Dependencies = nil
+
+		for _, d := range chrt.Metadata.Dependencies {
+			dependency := *d
+			metadata.Dependencies = append(metadata.Dependencies, &dependency)
+		}
+
+		out.Metadata = &metadata
+	}
+
+	if values, ok := copyValue(chrt.Values).(map[string]interface{}); ok {
+		out.Values = values
+	}
+
+	dependencies := make([]*cpb.Chart, 0, len(chrt.Dependencies()))
+	for _, d := range chrt.Dependencies() {
+		dependencies = append(dependencies, copyChart(d))
+	}
+
+	out.SetDependencies(dependencies...)
+
+	return &out
+}
+
+// copyValue returns a deep copy of the maps and lists of a values tree.
+func copyValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		if v == nil {
+			return v
+		}
+
+		out := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			out[k] = copyValue(e)
+		}
+
+		return out
+	case []interface{}:
+		if v == nil {
+			return v
+		}
+
+		out := make([]interface{}, len(v))
+		for i, e := range v {
+			out[i] = copyValue(e)
+		}
+
+		return out
+	default:
+		return v
+	}
+}
@@ -0,0 +1,112 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	cpb "helm.sh/helm/v3/pkg/chart"
+)
+
+func TestLoadChart(t *testing.T) {
+	archive, err := ioutil.ReadFile("../../test/helmrepo/subscription-release-test-1-0.1.0.tgz")
+	assert.NoError(t, err)
+
+	dir, err := ioutil.TempDir("", "chartcache")
+	assert.NoError(t, err)
+
+	defer os.RemoveAll(dir)
+
+	// the same chart downloaded for two HelmReleases
+	first := filepath.Join(dir, "first.tgz")
+	second := filepath.Join(dir, "second.tgz")
+	assert.NoError(t, ioutil.WriteFile(first, archive, 0600))
+	assert.NoError(t, ioutil.WriteFile(second, archive, 0600))
+
+	a, err := LoadChart(first)
+	assert.NoError(t, err)
+
+	b, err := LoadChart(second)
+	assert.NoError(t, err)
+
+	assert.NotSame(t, a, b)
+	assert.Equal(t, "subscription-release-test-1", b.Name())
+	assert.Same(t, a.Templates[0], b.Templates[0])
+
+	// the values are not shared
+	a.Values["enabled"] = false
+	a.Values["subscriptionrelease"].(map[string]interface{})["enabled"] = false
+	assert.Equal(t, true, b.Values["enabled"])
+	assert.Equal(t, true, b.Values["subscriptionrelease"].(map[string]interface{})["enabled"])
+
+	dirChart, err := LoadChart("../../test/github/subscription-release-test-1")
+	assert.NoError(t, err)
+	assert.Equal(t, "subscription-release-test-1", dirChart.Name())
+
+	_, err = LoadChart(filepath.Join(dir, "missing.tgz"))
+	assert.Error(t, err)
+}
+
+func TestChartCacheEviction(t *testing.T) {
+	c := newChartCache(2)
+
+	nginx := &cpb.Chart{Metadata: &cpb.Metadata{Name: "nginx"}}
+	redis := &cpb.Chart{Metadata: &cpb.Metadata{Name: "redis"}}
+	mysql := &cpb.Chart{Metadata: &cpb.Metadata{Name: "mysql"}}
+
+	assert.Same(t, nginx, c.add("nginx", nginx))
+	assert.Same(t, redis, c.add("redis", redis))
+
+	// the chart cached first is kept
+	assert.Same(t, nginx, c.add("nginx", &cpb.Chart{Metadata: &cpb.Metadata{Name: "nginx"}}))
+
+	// redis is the least recently used
+	c.add("mysql", mysql)
+
+	_, ok := c.get("redis")
+	assert.False(t, ok)
+
+	cached, ok := c.get("nginx")
+	assert.True(t, ok)
+	assert.Same(t, nginx, cached)
+}
+
+func TestCopyChart(t *testing.T) {
+	dependency := &cpb.Chart{Metadata: &cpb.Metadata{Name: "redis"}}
+	chrt := &cpb.Chart{
+		Metadata: &cpb.Metadata{
+			Name:         "app",
+			Dependencies: []*cpb.Dependency{{Name: "redis", Condition: "redis.enabled"}},
+		},
+		Values:    map[string]interface{}{"redis": map[string]interface{}{"enabled": true}},
+		Templates: []*cpb.File{{Name: "templates/deployment.yaml"}},
+	}
+	chrt.SetDependencies(dependency)
+
+	out := copyChart(chrt)
+
+	// as done by helm when processing the dependencies
+	out.Metadata.Dependencies[0].Enabled = true
+	out.SetDependencies()
+
+	assert.False(t, chrt.Metadata.Dependencies[0].Enabled)
+	assert.Len(t, chrt.Dependencies(), 1)
+	assert.Same(t, chrt, chrt.Dependencies()[0].Parent())
+	assert.Same(t, chrt.Templates[0], out.Templates[0])
+}
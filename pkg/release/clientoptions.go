@@ -0,0 +1,68 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release
+
+import (
+	"time"
+
+	"k8s.io/client-go/rest"
+)
+
+// ClientOptions are the rate limits and the request timeout of the clients
+// of the Kubernetes API. The zero values keep those of the config.
+type ClientOptions struct {
+	// QPS is the number of requests per second sent by a client on average
+	QPS float32
+	// Burst is the number of requests a client may send at once above QPS
+	Burst int
+	// Timeout bounds each request, the watches included
+	Timeout time.Duration
+}
+
+// Apply returns a copy of cfg with the non-zero options set.
+func (o ClientOptions) Apply(cfg *rest.Config) *rest.Config {
+	cfg = rest.CopyConfig(cfg)
+
+	if o.QPS > 0 {
+		cfg.QPS = o.QPS
+	}
+
+	if o.Burst > 0 {
+		cfg.Burst = o.Burst
+	}
+
+	if o.Timeout > 0 {
+		cfg.Timeout = o.Timeout
+	}
+
+	return cfg
+}
+
+// Or returns o with its zero values set from defaults.
+func (o ClientOptions) Or(defaults ClientOptions) ClientOptions {
+	if o.QPS == 0 {
+		o.QPS = defaults.QPS
+	}
+
+	if o.Burst == 0 {
+		o.Burst = defaults.Burst
+	}
+
+	if o.Timeout == 0 {
+		o.Timeout = defaults.Timeout
+	}
+
+	return o
+}
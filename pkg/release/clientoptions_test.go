@@ -0,0 +1,45 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/client-go/rest"
+)
+
+func TestClientOptionsApply(t *testing.T) {
+	cfg := &rest.Config{Host: "https://cluster.example.com:6443", QPS: 20, Burst: 30}
+
+	applied := ClientOptions{QPS: 100, Timeout: time.Minute}.Apply(cfg)
+	assert.Equal(t, float32(100), applied.QPS)
+	assert.Equal(t, 30, applied.Burst)
+	assert.Equal(t, time.Minute, applied.Timeout)
+	assert.Equal(t, cfg.Host, applied.Host)
+
+	// the config is copied
+	assert.Equal(t, float32(20), cfg.QPS)
+	assert.Equal(t, time.Duration(0), cfg.Timeout)
+}
+
+func TestClientOptionsOr(t *testing.T) {
+	defaults := ClientOptions{QPS: 20, Burst: 30, Timeout: time.Minute}
+
+	assert.Equal(t, defaults, ClientOptions{}.Or(defaults))
+	assert.Equal(t, ClientOptions{QPS: 50, Burst: 30, Timeout: time.Minute},
+		ClientOptions{QPS: 50}.Or(defaults))
+}
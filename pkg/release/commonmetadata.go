@@ -0,0 +1,94 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release
+
+import (
+	"bytes"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// commonMetadataRenderer is a helm post-renderer adding labels and
+// annotations to the rendered resources and to the pod templates of the
+// rendered workloads.
+type commonMetadataRenderer struct {
+	labels      map[string]string
+	annotations map[string]string
+}
+
+func (r commonMetadataRenderer) Run(rendered *bytes.Buffer) (*bytes.Buffer, error) {
+	return bytes.NewBufferString(addCommonMetadata(rendered.String(), r.labels, r.annotations)), nil
+}
+
+// addCommonMetadata adds labels and annotations to the metadata of the
+// resources of manifest and of the pod templates of its workloads, except
+// those they already set.
+func addCommonMetadata(manifest string, labels, annotations map[string]string) string {
+	return transformDocuments(manifest, func(u map[string]interface{}) bool {
+		if _, ok := u["kind"].(string); !ok {
+			return false
+		}
+
+		added := addMetadata(u, []string{"metadata"}, labels, annotations)
+
+		kind, _ := u["kind"].(string)
+
+		// the metadata of a Pod is its own
+		if path := podSpecPath(kind); len(path) > 1 {
+			template := append(path[:len(path)-1:len(path)-1], "metadata")
+			added = addMetadata(u, template, labels, annotations) || added
+		}
+
+		return added
+	})
+}
+
+// addMetadata adds the labels and annotations missing from the metadata of u
+// at path and returns true if any was.
+func addMetadata(u map[string]interface{}, path []string, labels, annotations map[string]string) bool {
+	added := false
+
+	for field, values := range map[string]map[string]string{"labels": labels, "annotations": annotations} {
+		if len(values) == 0 {
+			continue
+		}
+
+		existing, _, err := unstructured.NestedStringMap(u, append(path, field)...)
+		if err != nil {
+			continue
+		}
+
+		if existing == nil {
+			existing = map[string]string{}
+		}
+
+		set := false
+
+		for k, v := range values {
+			if _, ok := existing[k]; ok {
+				continue
+			}
+
+			existing[k] = v
+			set = true
+		}
+
+		if set && unstructured.SetNestedStringMap(u, existing, append(path, field)...) == nil {
+			added = true
+		}
+	}
+
+	return added
+}
@@ -0,0 +1,53 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestAddCommonMetadata(t *testing.T) {
+	deployment := fmt.Sprintf(testDeploymentManifest, "1", 1, "nginx:1.19")
+	configMap := fmt.Sprintf(testConfigMapManifest, "a")
+	labels := map[string]string{"cost-center": "platform"}
+	annotations := map[string]string{"example.com/revision": "2", "example.com/owner": "platform-team"}
+
+	added := addCommonMetadata(deployment+configMap, labels, annotations)
+
+	objects, err := manifestObjects(added)
+	assert.NoError(t, err)
+	assert.Len(t, objects, 2)
+
+	for _, u := range objects {
+		assert.Equal(t, "platform", u.GetLabels()["cost-center"], u.GetKind())
+		assert.Equal(t, "platform-team", u.GetAnnotations()["example.com/owner"], u.GetKind())
+	}
+
+	// the annotations set by the chart are kept
+	assert.Equal(t, "1", objects[0].GetAnnotations()["example.com/revision"])
+
+	templateLabels, _, _ := unstructured.NestedStringMap(objects[0].Object, "spec", "template", "metadata", "labels")
+	assert.Equal(t, map[string]string{"cost-center": "platform"}, templateLabels)
+
+	assert.True(t, strings.HasPrefix(added, "---\n# Source: test/templates/deployment.yaml\n"))
+
+	// the metadata already added is left as is
+	assert.Equal(t, added, addCommonMetadata(added, labels, annotations))
+}
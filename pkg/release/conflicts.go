@@ -0,0 +1,256 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/cli-runtime/pkg/resource"
+	"k8s.io/client-go/rest"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+// FieldConflicts returns the fields of the deployed resources owned by other
+// field managers, according to their managedFields, whose live value differs
+// from the value set by the release. These are the fields another controller,
+// e.g. an autoscaler, is fighting the operator over.
+func (m manager) FieldConflicts(ctx context.Context) ([]appv1.HelmAppFieldConflict, error) {
+	if m.deployedRelease == nil {
+		return nil, nil
+	}
+
+	expected, err := m.kubeClient.Build(bytes.NewBufferString(m.deployedRelease.Manifest), false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build deployed release resources: %w", err)
+	}
+
+	own := ownFieldManagers(m.fieldManager)
+
+	var conflicts []appv1.HelmAppFieldConflict
+
+	err = expected.Visit(func(info *resource.Info, err error) error {
+		if err != nil {
+			return err
+		}
+
+		live, err := resource.NewHelper(info.Client, info.Mapping).Get(info.Namespace, info.Name)
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+
+		if err != nil {
+			return fmt.Errorf("could not get object: %w", err)
+		}
+
+		resourceConflicts, err := fieldConflicts(info.Object, live, own)
+		if err != nil {
+			return err
+		}
+
+		ref := resourceRefs([]*resource.Info{info})[0]
+		for i := range resourceConflicts {
+			resourceConflicts[i].Resource = ref
+		}
+
+		conflicts = append(conflicts, resourceConflicts...)
+
+		return nil
+	})
+
+	return conflicts, err
+}
+
+// ownFieldManagers returns the field managers the operator writes with: the
+// configured one and the default manager of the requests sent without a
+// field manager, e.g. the helm kube client patches.
+func ownFieldManagers(fieldManager string) map[string]bool {
+	if fieldManager == "" {
+		fieldManager = DefaultFieldManager
+	}
+
+	// the API server names the manager of these requests after the user agent
+	userAgent := strings.Split(rest.DefaultKubernetesUserAgent(), "/")[0]
+
+	return map[string]bool{
+		fieldManager:         true,
+		DefaultFieldManager:  true,
+		userAgent:            true,
+		"before-first-apply": true,
+	}
+}
+
+// fieldConflicts returns, per field manager not in own, the fields of live it
+// owns that rendered sets to a different value.
+func fieldConflicts(rendered, live runtime.Object, own map[string]bool) ([]appv1.HelmAppFieldConflict, error) {
+	accessor, err := meta.Accessor(live)
+	if err != nil {
+		return nil, err
+	}
+
+	renderedContent, err := runtime.DefaultUnstructuredConverter.ToUnstructured(rendered)
+	if err != nil {
+		return nil, err
+	}
+
+	liveContent, err := runtime.DefaultUnstructuredConverter.ToUnstructured(live)
+	if err != nil {
+		return nil, err
+	}
+
+	var conflicts []appv1.HelmAppFieldConflict
+
+	for _, entry := range accessor.GetManagedFields() {
+		if own[entry.Manager] || entry.FieldsV1 == nil {
+			continue
+		}
+
+		var owned map[string]interface{}
+		if err := json.Unmarshal(entry.FieldsV1.Raw, &owned); err != nil {
+			return nil, fmt.Errorf("failed to decode managed fields of %s: %w", entry.Manager, err)
+		}
+
+		var fields []string
+
+		conflictingFields(owned, renderedContent, liveContent, "", &fields)
+
+		if len(fields) == 0 {
+			continue
+		}
+
+		sort.Strings(fields)
+
+		conflicts = append(conflicts, appv1.HelmAppFieldConflict{Manager: entry.Manager, Fields: fields})
+	}
+
+	return conflicts, nil
+}
+
+// conflictingFields walks the owned fieldsV1 tree along the rendered and the
+// live object and appends the path of each owned leaf set to a different value
+// in rendered than in live. The fields rendered does not set are skipped.
+func conflictingFields(owned map[string]interface{}, rendered, live interface{}, path string, fields *[]string) {
+	for key, child := range owned {
+		if key == "." {
+			continue
+		}
+
+		renderedChild, segment, ok := fieldChild(rendered, key)
+		if !ok {
+			continue
+		}
+
+		liveChild, _, _ := fieldChild(live, key)
+
+		ownedChild, _ := child.(map[string]interface{})
+		if isLeaf(ownedChild) {
+			if fmt.Sprint(renderedChild) != fmt.Sprint(liveChild) {
+				*fields = append(*fields, path+segment)
+			}
+
+			continue
+		}
+
+		conflictingFields(ownedChild, renderedChild, liveChild, path+segment, fields)
+	}
+}
+
+func isLeaf(owned map[string]interface{}) bool {
+	for key := range owned {
+		if key != "." {
+			return false
+		}
+	}
+
+	return true
+}
+
+// fieldChild returns the child of obj selected by a fieldsV1 key and its path
+// segment: f:<name> selects a field, k:<keys> the list item with these keys
+// and i:<index> a list item. Set values, v:<value>, are never conflicting.
+func fieldChild(obj interface{}, key string) (interface{}, string, bool) {
+	switch {
+	case strings.HasPrefix(key, "f:"):
+		m, ok := obj.(map[string]interface{})
+		if !ok {
+			return nil, "", false
+		}
+
+		name := strings.TrimPrefix(key, "f:")
+		child, ok := m[name]
+
+		return child, "." + name, ok
+	case strings.HasPrefix(key, "k:"):
+		var keys map[string]interface{}
+		if err := json.Unmarshal([]byte(strings.TrimPrefix(key, "k:")), &keys); err != nil {
+			return nil, "", false
+		}
+
+		items, _ := obj.([]interface{})
+		for _, item := range items {
+			if itemMatches(item, keys) {
+				return item, "[" + keySegment(keys) + "]", true
+			}
+		}
+	case strings.HasPrefix(key, "i:"):
+		var index int
+		if _, err := fmt.Sscanf(key, "i:%d", &index); err != nil {
+			return nil, "", false
+		}
+
+		items, _ := obj.([]interface{})
+		if index >= 0 && index < len(items) {
+			return items[index], fmt.Sprintf("[%d]", index), true
+		}
+	}
+
+	return nil, "", false
+}
+
+// itemMatches returns true if the list item has the given key values. The
+// values are compared printed since the JSON numbers of the keys are floats.
+func itemMatches(item interface{}, keys map[string]interface{}) bool {
+	m, ok := item.(map[string]interface{})
+	if !ok {
+		return false
+	}
+
+	for k, v := range keys {
+		if fmt.Sprint(m[k]) != fmt.Sprint(v) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func keySegment(keys map[string]interface{}) string {
+	pairs := make([]string, 0, len(keys))
+	for k, v := range keys {
+		pairs = append(pairs, fmt.Sprintf("%s=%v", k, v))
+	}
+
+	sort.Strings(pairs)
+
+	return strings.Join(pairs, ",")
+}
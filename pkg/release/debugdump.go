@@ -0,0 +1,79 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	cpb "helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chartutil"
+)
+
+// DebugDump holds the inputs and the output of the render of a release, so
+// that the render of the operator can be reproduced, e.g. with helm template.
+type DebugDump struct {
+	// ReleaseName and Namespace are the name and the target namespace of the
+	// release.
+	ReleaseName string
+	Namespace   string
+	// Chart is the metadata of the chart.
+	Chart *cpb.Metadata
+	// Values are the values of the release merged into the default values of
+	// the chart, their secret values redacted.
+	Values map[string]interface{}
+	// Manifest is the manifest of the release rendered as a dry-run install,
+	// the hooks last, the Secret data and the secret values redacted. It is
+	// empty if the render failed.
+	Manifest string
+	// RenderError is the redacted error of the render, if it failed.
+	RenderError string
+}
+
+// DebugDump renders the release as a dry-run install and returns the chart,
+// the values and the manifest of the render. A failed render is reported in
+// the dump, the error is only returned if the values cannot be merged.
+func (m manager) DebugDump(ctx context.Context) (*DebugDump, error) {
+	values, err := chartutil.CoalesceValues(m.chart, m.values)
+	if err != nil {
+		return nil, m.redactError(fmt.Errorf("failed to merge the values: %w", err))
+	}
+
+	dump := &DebugDump{
+		ReleaseName: m.releaseName,
+		Namespace:   m.namespace,
+		Chart:       m.chart.Metadata,
+		Values:      redactValues(values, m.secrets),
+	}
+
+	rel, err := m.getCandidateInstall()
+	if err != nil {
+		dump.RenderError = redact(err.Error(), m.secrets)
+		return dump, nil
+	}
+
+	var manifest strings.Builder
+
+	manifest.WriteString(rel.Manifest)
+
+	for _, hook := range rel.Hooks {
+		fmt.Fprintf(&manifest, "\n---\n# Source: %s\n%s", hook.Path, hook.Manifest)
+	}
+
+	dump.Manifest = redact(RedactManifest(manifest.String()), m.secrets)
+
+	return dump, nil
+}
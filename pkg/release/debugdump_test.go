@@ -0,0 +1,98 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release
+
+import (
+	"context"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chartutil"
+	kubefake "helm.sh/helm/v3/pkg/kube/fake"
+	"helm.sh/helm/v3/pkg/storage"
+	"helm.sh/helm/v3/pkg/storage/driver"
+)
+
+func debugDumpManager(c *chart.Chart, values map[string]interface{}) manager {
+	return manager{
+		actionConfig: &action.Configuration{
+			KubeClient:   &kubefake.PrintingKubeClient{Out: ioutil.Discard},
+			Capabilities: chartutil.DefaultCapabilities,
+			Log:          func(_ string, _ ...interface{}) {},
+		},
+		storageBackend: storage.Init(driver.NewMemory()),
+		releaseName:    "web",
+		namespace:      "apps",
+		chart:          c,
+		values:         values,
+		secrets:        secretValues(values),
+	}
+}
+
+func TestDebugDump(t *testing.T) {
+	c := offlineChart()
+	c.Values["auth"] = map[string]interface{}{"password": "default-password"}
+	c.Templates = append(c.Templates, &chart.File{
+		Name: "templates/secret.yaml",
+		Data: []byte("apiVersion: v1\nkind: Secret\nmetadata:\n  name: web\nstringData:\n  password: {{ .Values.auth.password }}\n"),
+	}, &chart.File{
+		Name: "templates/test.yaml",
+		Data: []byte("apiVersion: v1\nkind: Pod\nmetadata:\n  name: web-test\n  annotations:\n    helm.sh/hook: test\n"),
+	})
+
+	values := map[string]interface{}{
+		"greeting": "hi",
+		"auth":     map[string]interface{}{"password": "correct-horse"},
+	}
+
+	dump, err := debugDumpManager(c, values).DebugDump(context.TODO())
+	require.NoError(t, err)
+
+	assert.Equal(t, "web", dump.ReleaseName)
+	assert.Equal(t, "apps", dump.Namespace)
+	assert.Equal(t, "offline", dump.Chart.Name)
+	assert.Empty(t, dump.RenderError)
+
+	// the values of the release override the defaults of the chart
+	assert.Equal(t, "hi", dump.Values["greeting"])
+	assert.Equal(t, map[string]interface{}{"password": Redacted}, dump.Values["auth"])
+
+	assert.Contains(t, dump.Manifest, "greeting: hi")
+	assert.Contains(t, dump.Manifest, "# Source: offline/templates/test.yaml")
+	assert.NotContains(t, dump.Manifest, "correct-horse")
+
+	// the values of m are not modified
+	assert.Equal(t, "correct-horse", values["auth"].(map[string]interface{})["password"])
+}
+
+func TestDebugDumpRenderError(t *testing.T) {
+	c := offlineChart()
+	c.Templates = append(c.Templates, &chart.File{
+		Name: "templates/broken.yaml",
+		Data: []byte("{{ required \"token correct-horse is required\" .Values.missing }}"),
+	})
+
+	dump, err := debugDumpManager(c, map[string]interface{}{"token": "correct-horse"}).DebugDump(context.TODO())
+	require.NoError(t, err)
+
+	assert.Empty(t, dump.Manifest)
+	assert.Contains(t, dump.RenderError, "is required")
+	assert.NotContains(t, dump.RenderError, "correct-horse")
+	assert.Equal(t, Redacted, dump.Values["token"])
+}
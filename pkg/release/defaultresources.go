@@ -0,0 +1,134 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release
+
+import (
+	"bytes"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// defaultResourcesRenderer is a helm post-renderer setting the requests and
+// limits the containers of the rendered workloads leave unset.
+type defaultResourcesRenderer struct {
+	defaults *corev1.ResourceRequirements
+}
+
+func (r defaultResourcesRenderer) Run(rendered *bytes.Buffer) (*bytes.Buffer, error) {
+	return bytes.NewBufferString(setDefaultResources(rendered.String(), r.defaults)), nil
+}
+
+// setDefaultResources sets the default requests and limits the containers of
+// the workloads of manifest, init containers included, leave unset. A default
+// request is not set if the container has a limit for the resource, which the
+// request defaults to, and a default limit is not set below the request of
+// the container.
+func setDefaultResources(manifest string, defaults *corev1.ResourceRequirements) string {
+	return transformPodSpecs(manifest, func(spec map[string]interface{}) bool {
+		set := false
+
+		// the ephemeral containers have no resources
+		for _, field := range []string{"containers", "initContainers"} {
+			containers, _ := spec[field].([]interface{})
+
+			for _, c := range containers {
+				container, ok := c.(map[string]interface{})
+				if !ok {
+					continue
+				}
+
+				resources, _ := container["resources"].(map[string]interface{})
+				if resources == nil {
+					resources = map[string]interface{}{}
+				}
+
+				requests, _ := resources["requests"].(map[string]interface{})
+				limits, _ := resources["limits"].(map[string]interface{})
+
+				defaulted := false
+
+				for name, q := range defaults.Requests {
+					if _, ok := requests[string(name)]; ok {
+						continue
+					}
+
+					if _, ok := limits[string(name)]; ok {
+						continue
+					}
+
+					requests = setQuantity(requests, name, q)
+					defaulted = true
+				}
+
+				for name, q := range defaults.Limits {
+					if _, ok := limits[string(name)]; ok {
+						continue
+					}
+
+					// also the default request set above
+					if request, ok := quantity(requests[string(name)]); ok && request.Cmp(q) > 0 {
+						continue
+					}
+
+					limits = setQuantity(limits, name, q)
+					defaulted = true
+				}
+
+				if !defaulted {
+					continue
+				}
+
+				if requests != nil {
+					resources["requests"] = requests
+				}
+
+				if limits != nil {
+					resources["limits"] = limits
+				}
+
+				container["resources"] = resources
+				set = true
+			}
+		}
+
+		return set
+	})
+}
+
+func setQuantity(quantities map[string]interface{}, name corev1.ResourceName, q resource.Quantity) map[string]interface{} {
+	if quantities == nil {
+		quantities = map[string]interface{}{}
+	}
+
+	quantities[string(name)] = q.String()
+
+	return quantities
+}
+
+// quantity parses a quantity of a manifest, a string or a number.
+func quantity(v interface{}) (resource.Quantity, bool) {
+	switch v := v.(type) {
+	case string:
+		q, err := resource.ParseQuantity(v)
+		return q, err == nil
+	case float64:
+		return *resource.NewMilliQuantity(int64(v*1000), resource.DecimalSI), true
+	case int64:
+		return *resource.NewQuantity(v, resource.DecimalSI), true
+	default:
+		return resource.Quantity{}, false
+	}
+}
@@ -0,0 +1,94 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const testResourcesManifest = `---
+# Source: test/templates/deployment.yaml
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: test
+spec:
+  template:
+    spec:
+      containers:
+      - name: unset
+        image: nginx:1.19
+      - name: explicit
+        image: nginx:1.19
+        resources:
+          requests:
+            memory: 1Gi
+      - name: limited
+        image: nginx:1.19
+        resources:
+          limits:
+            cpu: 50m
+`
+
+func TestSetDefaultResources(t *testing.T) {
+	defaults := &corev1.ResourceRequirements{
+		Requests: corev1.ResourceList{
+			corev1.ResourceCPU:    resource.MustParse("100m"),
+			corev1.ResourceMemory: resource.MustParse("128Mi"),
+		},
+		Limits: corev1.ResourceList{
+			corev1.ResourceMemory: resource.MustParse("512Mi"),
+		},
+	}
+
+	set := setDefaultResources(testResourcesManifest, defaults)
+
+	objects, err := manifestObjects(set)
+	assert.NoError(t, err)
+	assert.Len(t, objects, 1)
+
+	containers, _, _ := unstructured.NestedSlice(objects[0].Object, "spec", "template", "spec", "containers")
+	resources := func(i int) map[string]interface{} {
+		r, _, _ := unstructured.NestedMap(containers[i].(map[string]interface{}), "resources")
+		return r
+	}
+
+	assert.Equal(t, map[string]interface{}{
+		"requests": map[string]interface{}{"cpu": "100m", "memory": "128Mi"},
+		"limits":   map[string]interface{}{"memory": "512Mi"},
+	}, resources(0))
+
+	// the explicit request is kept, and no limit is set below it
+	assert.Equal(t, map[string]interface{}{
+		"requests": map[string]interface{}{"cpu": "100m", "memory": "1Gi"},
+	}, resources(1))
+
+	// the request of a limited resource defaults to its limit
+	assert.Equal(t, map[string]interface{}{
+		"requests": map[string]interface{}{"memory": "128Mi"},
+		"limits":   map[string]interface{}{"cpu": "50m", "memory": "512Mi"},
+	}, resources(2))
+
+	assert.True(t, strings.HasPrefix(set, "---\n# Source: test/templates/deployment.yaml\n"))
+
+	// the defaulted resources are left as is
+	assert.Equal(t, set, setDefaultResources(set, defaults))
+}
@@ -0,0 +1,115 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release
+
+import (
+	"fmt"
+	"sort"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+// apiDeprecation is when an API version of a kind was deprecated and removed,
+// as minor versions of Kubernetes 1.x
+type apiDeprecation struct {
+	deprecatedIn int
+	removedIn    int
+	replacement  string
+}
+
+// deprecatedAPIs are the deprecated API versions of the built-in kinds, by
+// API version and kind, from the Kubernetes deprecated API migration guide
+var deprecatedAPIs = map[string]apiDeprecation{
+	"extensions/v1beta1 Deployment":        {9, 16, "apps/v1"},
+	"extensions/v1beta1 DaemonSet":         {9, 16, "apps/v1"},
+	"extensions/v1beta1 ReplicaSet":        {9, 16, "apps/v1"},
+	"extensions/v1beta1 NetworkPolicy":     {9, 16, "networking.k8s.io/v1"},
+	"extensions/v1beta1 PodSecurityPolicy": {10, 16, "policy/v1beta1"},
+	"apps/v1beta1 Deployment":              {9, 16, "apps/v1"},
+	"apps/v1beta1 StatefulSet":             {9, 16, "apps/v1"},
+	"apps/v1beta2 Deployment":              {9, 16, "apps/v1"},
+	"apps/v1beta2 DaemonSet":               {9, 16, "apps/v1"},
+	"apps/v1beta2 ReplicaSet":              {9, 16, "apps/v1"},
+	"apps/v1beta2 StatefulSet":             {9, 16, "apps/v1"},
+
+	"extensions/v1beta1 Ingress":                                          {14, 22, "networking.k8s.io/v1"},
+	"networking.k8s.io/v1beta1 Ingress":                                   {19, 22, "networking.k8s.io/v1"},
+	"networking.k8s.io/v1beta1 IngressClass":                              {19, 22, "networking.k8s.io/v1"},
+	"apiextensions.k8s.io/v1beta1 CustomResourceDefinition":               {16, 22, "apiextensions.k8s.io/v1"},
+	"admissionregistration.k8s.io/v1beta1 MutatingWebhookConfiguration":   {16, 22, "admissionregistration.k8s.io/v1"},
+	"admissionregistration.k8s.io/v1beta1 ValidatingWebhookConfiguration": {16, 22, "admissionregistration.k8s.io/v1"},
+	"apiregistration.k8s.io/v1beta1 APIService":                           {19, 22, "apiregistration.k8s.io/v1"},
+	"rbac.authorization.k8s.io/v1beta1 ClusterRole":                       {17, 22, "rbac.authorization.k8s.io/v1"},
+	"rbac.authorization.k8s.io/v1beta1 ClusterRoleBinding":                {17, 22, "rbac.authorization.k8s.io/v1"},
+	"rbac.authorization.k8s.io/v1beta1 Role":                              {17, 22, "rbac.authorization.k8s.io/v1"},
+	"rbac.authorization.k8s.io/v1beta1 RoleBinding":                       {17, 22, "rbac.authorization.k8s.io/v1"},
+	"scheduling.k8s.io/v1beta1 PriorityClass":                             {14, 22, "scheduling.k8s.io/v1"},
+	"storage.k8s.io/v1beta1 CSIDriver":                                    {19, 22, "storage.k8s.io/v1"},
+	"storage.k8s.io/v1beta1 CSINode":                                      {17, 22, "storage.k8s.io/v1"},
+	"storage.k8s.io/v1beta1 StorageClass":                                 {19, 22, "storage.k8s.io/v1"},
+	"storage.k8s.io/v1beta1 VolumeAttachment":                             {19, 22, "storage.k8s.io/v1"},
+	"certificates.k8s.io/v1beta1 CertificateSigningRequest":               {19, 22, "certificates.k8s.io/v1"},
+	"coordination.k8s.io/v1beta1 Lease":                                   {19, 22, "coordination.k8s.io/v1"},
+
+	"batch/v1beta1 CronJob":                       {21, 25, "batch/v1"},
+	"policy/v1beta1 PodDisruptionBudget":          {21, 25, "policy/v1"},
+	"policy/v1beta1 PodSecurityPolicy":            {21, 25, ""},
+	"autoscaling/v2beta1 HorizontalPodAutoscaler": {22, 25, "autoscaling/v2"},
+	"discovery.k8s.io/v1beta1 EndpointSlice":      {21, 25, "discovery.k8s.io/v1"},
+	"events.k8s.io/v1beta1 Event":                 {21, 25, "events.k8s.io/v1"},
+	"node.k8s.io/v1beta1 RuntimeClass":            {20, 25, "node.k8s.io/v1"},
+
+	"autoscaling/v2beta2 HorizontalPodAutoscaler":                     {23, 26, "autoscaling/v2"},
+	"flowcontrol.apiserver.k8s.io/v1beta1 FlowSchema":                 {23, 26, "flowcontrol.apiserver.k8s.io/v1beta3"},
+	"flowcontrol.apiserver.k8s.io/v1beta1 PriorityLevelConfiguration": {23, 26, "flowcontrol.apiserver.k8s.io/v1beta3"},
+
+	"storage.k8s.io/v1beta1 CSIStorageCapacity": {24, 27, "storage.k8s.io/v1"},
+}
+
+// DeprecatedAPIs returns the objects using API versions deprecated in
+// Kubernetes 1.minor or in the next minor version, the version the cluster is
+// likely to be upgraded to, sorted by resource. Removed is set on the API
+// versions removed in either.
+func DeprecatedAPIs(objects []*unstructured.Unstructured, minor int) []appv1.HelmAppDeprecatedAPI {
+	var found []appv1.HelmAppDeprecatedAPI
+
+	for _, u := range objects {
+		d, ok := deprecatedAPIs[u.GetAPIVersion()+" "+u.GetKind()]
+		if !ok || d.deprecatedIn > minor+1 {
+			continue
+		}
+
+		found = append(found, appv1.HelmAppDeprecatedAPI{
+			Resource: appv1.HelmAppResource{
+				APIVersion: u.GetAPIVersion(),
+				Kind:       u.GetKind(),
+				Namespace:  u.GetNamespace(),
+				Name:       u.GetName(),
+			},
+			DeprecatedIn: fmt.Sprintf("1.%d", d.deprecatedIn),
+			RemovedIn:    fmt.Sprintf("1.%d", d.removedIn),
+			Replacement:  d.replacement,
+			Removed:      d.removedIn <= minor+1,
+		})
+	}
+
+	sort.Slice(found, func(i, j int) bool {
+		return found[i].Resource.String() < found[j].Resource.String()
+	})
+
+	return found
+}
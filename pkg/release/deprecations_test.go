@@ -0,0 +1,69 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+func TestDeprecatedAPIs(t *testing.T) {
+	object := func(apiVersion, kind, name string) *unstructured.Unstructured {
+		u := &unstructured.Unstructured{}
+		u.SetAPIVersion(apiVersion)
+		u.SetKind(kind)
+		u.SetNamespace("default")
+		u.SetName(name)
+
+		return u
+	}
+	objects := []*unstructured.Unstructured{
+		object("networking.k8s.io/v1beta1", "Ingress", "web"),
+		object("batch/v1beta1", "CronJob", "backup"),
+		object("autoscaling/v2beta2", "HorizontalPodAutoscaler", "web"),
+		object("apps/v1", "Deployment", "web"),
+	}
+
+	assert.Equal(t, []appv1.HelmAppDeprecatedAPI{
+		{
+			Resource: appv1.HelmAppResource{
+				APIVersion: "networking.k8s.io/v1beta1", Kind: "Ingress", Namespace: "default", Name: "web",
+			},
+			DeprecatedIn: "1.19",
+			RemovedIn:    "1.22",
+			Replacement:  "networking.k8s.io/v1",
+		},
+	}, DeprecatedAPIs(objects, 19))
+
+	found := DeprecatedAPIs(objects, 21)
+	if assert.Len(t, found, 2) {
+		assert.Equal(t, "CronJob", found[0].Resource.Kind)
+		assert.False(t, found[0].Removed)
+		assert.Equal(t, "Ingress", found[1].Resource.Kind)
+		assert.True(t, found[1].Removed)
+	}
+
+	found = DeprecatedAPIs(objects, 25)
+	if assert.Len(t, found, 3) {
+		assert.Equal(t, "HorizontalPodAutoscaler", found[0].Resource.Kind)
+		assert.True(t, found[0].Removed)
+	}
+
+	assert.Empty(t, DeprecatedAPIs(objects, 15))
+}
@@ -0,0 +1,289 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"helm.sh/helm/v3/pkg/action"
+	rpb "helm.sh/helm/v3/pkg/release"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+// ReleaseDiff is the difference between the deployed release and the
+// candidate release rendered from the current chart and values.
+type ReleaseDiff struct {
+	// Added lists the resources only rendered by the candidate release
+	Added []ResourceDiff
+	// Modified lists the resources rendered differently by the candidate release
+	Modified []ResourceDiff
+	// Removed lists the resources no longer rendered by the candidate release
+	Removed []ResourceDiff
+}
+
+// Empty returns true if the candidate release renders the deployed resources.
+func (d *ReleaseDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Modified) == 0 && len(d.Removed) == 0
+}
+
+// Summary counts the resources of the diff by kind and change, e.g.
+// "2 Deployments changed, 1 Secret added, 1 ConfigMap removed".
+func (d *ReleaseDiff) Summary() string {
+	var parts []string
+
+	for _, change := range []struct {
+		resources []ResourceDiff
+		verb      string
+	}{{d.Modified, "changed"}, {d.Added, "added"}, {d.Removed, "removed"}} {
+		counts := map[string]int{}
+		for _, r := range change.resources {
+			counts[r.Resource.Kind]++
+		}
+
+		kinds := make([]string, 0, len(counts))
+		for kind := range counts {
+			kinds = append(kinds, kind)
+		}
+
+		sort.Strings(kinds)
+
+		for _, kind := range kinds {
+			parts = append(parts, fmt.Sprintf("%d %s %s", counts[kind], pluralKind(kind, counts[kind]), change.verb))
+		}
+	}
+
+	if len(parts) == 0 {
+		return "no resources changed"
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+// pluralKind returns the plural of kind if n is not 1, e.g. NetworkPolicies.
+func pluralKind(kind string, n int) string {
+	switch {
+	case n == 1 || kind == "":
+		return kind
+	case strings.HasSuffix(kind, "s") || strings.HasSuffix(kind, "x"):
+		return kind + "es"
+	case strings.HasSuffix(kind, "y") && len(kind) > 1 && !strings.ContainsAny(kind[len(kind)-2:len(kind)-1], "aeiou"):
+		return kind[:len(kind)-1] + "ies"
+	}
+
+	return kind + "s"
+}
+
+// DiffSummary returns the summary of the resources changed from the previous
+// manifest of a release to the upgraded one. The fields matched by the
+// ignoreDifferences rules are not compared.
+func DiffSummary(previous, upgraded string, rules []appv1.ResourceIgnoreDifferences) (string, error) {
+	diff, err := diffManifests(previous, upgraded, rules)
+	if err != nil {
+		return "", err
+	}
+
+	return diff.Summary(), nil
+}
+
+// ResourceDiff is the difference of a resource between the two releases.
+type ResourceDiff struct {
+	Resource appv1.HelmAppResource
+	// Changes lists the changed fields of a modified resource
+	Changes []FieldChange
+}
+
+// FieldChange is a field set to a different value by the candidate release.
+// Old is nil for an added field and New is nil for a removed field.
+type FieldChange struct {
+	// Path is the JSON pointer to the field, e.g. /spec/replicas
+	Path string
+	Old  interface{}
+	New  interface{}
+}
+
+// Diff returns the difference between the deployed release and the candidate
+// release, or all the candidate resources as added if the release is not
+// installed. The fields matched by the ignoreDifferences rules are not
+// compared, the Secret data and the secret values are redacted. Without a
+// Sync first, the deployed release is read from the storage as is, e.g. by the
+// kubectl plugin that must not change the release.
+func (m manager) Diff(ctx context.Context) (*ReleaseDiff, error) {
+	var (
+		deployed  string
+		candidate *rpb.Release
+		err       error
+	)
+
+	deployedRelease := m.deployedRelease
+	if deployedRelease == nil {
+		if deployedRelease, err = m.GetDeployedRelease(); err != nil && !notFoundErr(err) {
+			return nil, storageFailed(fmt.Errorf("failed to get deployed release: %w", err))
+		}
+	}
+
+	if deployedRelease != nil {
+		deployed = deployedRelease.Manifest
+		candidate, err = m.getCandidateRelease(m.namespace, m.releaseName, m.chart, m.values)
+	} else {
+		candidate, err = m.getCandidateInstall()
+	}
+
+	if err != nil {
+		return nil, renderFailed(m.redactError(fmt.Errorf("failed to get candidate release: %w", err)))
+	}
+
+	diff, err := diffManifests(deployed, candidate.Manifest, m.ignoreDifferences)
+	if err != nil {
+		return nil, err
+	}
+
+	// the diffs are reported, the Secret data and the secret values are not
+	redactDiff(diff, m.secrets)
+
+	return diff, nil
+}
+
+// getCandidateInstall renders the release as a dry-run install.
+func (m manager) getCandidateInstall() (*rpb.Release, error) {
+	actionConfig, err := m.candidateActionConfig(m.releaseName)
+	if err != nil {
+		return nil, err
+	}
+
+	install := action.NewInstall(actionConfig)
+	install.ReleaseName = m.releaseName
+	install.Namespace = m.namespace
+	install.DryRun = true
+	install.PostRenderer = m.postRenderer
+
+	return install.Run(m.chart, m.values)
+}
+
+// diffManifests returns the difference between the deployed and the candidate
+// manifests once the fields matched by rules are removed from both of them.
+func diffManifests(deployed, candidate string, rules []appv1.ResourceIgnoreDifferences) (*ReleaseDiff, error) {
+	deployedResources, err := manifestResources(deployed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse deployed manifest: %w", err)
+	}
+
+	candidateResources, err := manifestResources(candidate)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse candidate manifest: %w", err)
+	}
+
+	diff := &ReleaseDiff{}
+
+	for key, c := range candidateResources {
+		d, ok := deployedResources[key]
+		if !ok {
+			diff.Added = append(diff.Added, ResourceDiff{Resource: resourceFor(c)})
+			continue
+		}
+
+		if err := ignoreDifferences(d, rules); err != nil {
+			return nil, err
+		}
+
+		if err := ignoreDifferences(c, rules); err != nil {
+			return nil, err
+		}
+
+		var changes []FieldChange
+
+		diffFields(d.Object, c.Object, "", &changes)
+
+		if len(changes) > 0 {
+			sort.Slice(changes, func(i, j int) bool {
+				return changes[i].Path < changes[j].Path
+			})
+
+			diff.Modified = append(diff.Modified, ResourceDiff{Resource: resourceFor(c), Changes: changes})
+		}
+	}
+
+	for key, d := range deployedResources {
+		if _, ok := candidateResources[key]; !ok {
+			diff.Removed = append(diff.Removed, ResourceDiff{Resource: resourceFor(d)})
+		}
+	}
+
+	for _, resources := range [][]ResourceDiff{diff.Added, diff.Modified, diff.Removed} {
+		sortResourceDiffs(resources)
+	}
+
+	return diff, nil
+}
+
+// diffFields appends the changes between from and to below path. Maps are compared
+// field by field and lists item by item, any other value as a whole.
+func diffFields(from, to interface{}, path string, changes *[]FieldChange) {
+	switch o := from.(type) {
+	case map[string]interface{}:
+		n, ok := to.(map[string]interface{})
+		if !ok {
+			break
+		}
+
+		for k, ov := range o {
+			nv, ok := n[k]
+			if !ok {
+				*changes = append(*changes, FieldChange{Path: path + "/" + escapePointer(k), Old: ov})
+				continue
+			}
+
+			diffFields(ov, nv, path+"/"+escapePointer(k), changes)
+		}
+
+		for k, nv := range n {
+			if _, ok := o[k]; !ok {
+				*changes = append(*changes, FieldChange{Path: path + "/" + escapePointer(k), New: nv})
+			}
+		}
+
+		return
+	case []interface{}:
+		n, ok := to.([]interface{})
+		if !ok || len(n) != len(o) {
+			break
+		}
+
+		for i := range o {
+			diffFields(o[i], n[i], fmt.Sprintf("%s/%d", path, i), changes)
+		}
+
+		return
+	}
+
+	if !reflect.DeepEqual(from, to) {
+		*changes = append(*changes, FieldChange{Path: path, Old: from, New: to})
+	}
+}
+
+// escapePointer escapes a field name for a JSON pointer.
+func escapePointer(name string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(name)
+}
+
+func sortResourceDiffs(resources []ResourceDiff) {
+	sort.Slice(resources, func(i, j int) bool {
+		return resources[i].Resource.String() < resources[j].Resource.String()
+	})
+}
@@ -0,0 +1,149 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+func TestDiffManifests(t *testing.T) {
+	deployed := `---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  replicas: 1
+  template:
+    spec:
+      containers:
+      - name: web
+        image: web:1
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: old
+`
+	candidate := `---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  labels:
+    app: web
+spec:
+  replicas: 3
+  template:
+    spec:
+      containers:
+      - name: web
+        image: web:2
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: new
+`
+	diff, err := diffManifests(deployed, candidate, []appv1.ResourceIgnoreDifferences{
+		{Kind: "Deployment", JSONPointers: []string{"/spec/replicas"}},
+	})
+	assert.NoError(t, err)
+
+	assert.Equal(t, []ResourceDiff{{Resource: appv1.HelmAppResource{APIVersion: "v1", Kind: "ConfigMap", Name: "new"}}}, diff.Added)
+	assert.Equal(t, []ResourceDiff{{Resource: appv1.HelmAppResource{APIVersion: "v1", Kind: "ConfigMap", Name: "old"}}}, diff.Removed)
+	assert.Equal(t, []ResourceDiff{{
+		Resource: appv1.HelmAppResource{APIVersion: "apps/v1", Kind: "Deployment", Name: "web"},
+		Changes: []FieldChange{
+			{Path: "/metadata/labels", New: map[string]interface{}{"app": "web"}},
+			{Path: "/spec/template/spec/containers/0/image", Old: "web:1", New: "web:2"},
+		},
+	}}, diff.Modified)
+
+	diff, err = diffManifests(deployed, deployed, nil)
+	assert.NoError(t, err)
+	assert.True(t, diff.Empty())
+}
+
+func TestDiffSummary(t *testing.T) {
+	previous := `---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  replicas: 1
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: api
+spec:
+  replicas: 1
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: old
+`
+	upgraded := `---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  replicas: 2
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: api
+spec:
+  replicas: 2
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: creds
+---
+apiVersion: networking.k8s.io/v1
+kind: NetworkPolicy
+metadata:
+  name: deny
+---
+apiVersion: networking.k8s.io/v1
+kind: NetworkPolicy
+metadata:
+  name: allow
+`
+
+	summary, err := DiffSummary(previous, upgraded, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "2 Deployments changed, 2 NetworkPolicies added, 1 Secret added, 1 ConfigMap removed", summary)
+
+	// the ignored fields are not changes
+	summary, err = DiffSummary(previous, strings.ReplaceAll(previous, "replicas: 1", "replicas: 3"),
+		[]appv1.ResourceIgnoreDifferences{{Kind: "Deployment", JSONPointers: []string{"/spec/replicas"}}})
+	assert.NoError(t, err)
+	assert.Equal(t, "no resources changed", summary)
+
+	assert.Equal(t, "Ingresses", pluralKind("Ingress", 2))
+	assert.Equal(t, "Gateways", pluralKind("Gateway", 2))
+}
@@ -0,0 +1,109 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"hash"
+
+	cpb "helm.sh/helm/v3/pkg/chart"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+// releaseDigest returns a digest of everything deciding the upgrade of a
+// release: the chart, the merged values, the target namespace, the
+// ignoreDifferences rules and the settings injected by the post-renderer. Two
+// renders with the same digest only differ if the chart depends on the cluster
+// state, e.g. with the lookup function.
+func releaseDigest(chart *cpb.Chart, values map[string]interface{}, namespace string,
+	rules []appv1.ResourceIgnoreDifferences, postRender []interface{}) (string, error) {
+	h := sha256.New()
+
+	if err := writeChartDigest(h, chart); err != nil {
+		return "", err
+	}
+
+	// json sorts the map keys, the encoding is stable
+	for _, v := range []interface{}{values, namespace, rules} {
+		if err := json.NewEncoder(h).Encode(v); err != nil {
+			return "", err
+		}
+	}
+
+	// only the settings that are set, the digests of the other releases are unchanged
+	for _, setting := range postRender {
+		if err := json.NewEncoder(h).Encode(setting); err != nil {
+			return "", err
+		}
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// ValuesDigest returns the digest of the merged values of the release, so
+// that two revisions can be compared without recording the values.
+func (m manager) ValuesDigest() string {
+	return valuesDigest(m.values)
+}
+
+// ManifestDigest returns the digest of the rendered manifest of a release, so
+// that it can be compared without recording the manifest.
+func ManifestDigest(manifest string) string {
+	sum := sha256.Sum256([]byte(manifest))
+
+	return hex.EncodeToString(sum[:])
+}
+
+func valuesDigest(values map[string]interface{}) string {
+	// json sorts the map keys, the encoding is stable
+	b, err := json.Marshal(values)
+	if err != nil {
+		return ""
+	}
+
+	sum := sha256.Sum256(b)
+
+	return hex.EncodeToString(sum[:])
+}
+
+// ChartDigest returns the sha256 digest of the content of chart and of its
+// dependencies, the same for the same chart whatever its archive.
+func ChartDigest(chart *cpb.Chart) string {
+	h := sha256.New()
+
+	if err := writeChartDigest(h, chart); err != nil {
+		return ""
+	}
+
+	return "sha256:" + hex.EncodeToString(h.Sum(nil))
+}
+
+func writeChartDigest(h hash.Hash, chart *cpb.Chart) error {
+	// the dependencies are not part of the chart encoding
+	if err := json.NewEncoder(h).Encode(chart); err != nil {
+		return err
+	}
+
+	for _, dependency := range chart.Dependencies() {
+		if err := writeChartDigest(h, dependency); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,139 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	cpb "helm.sh/helm/v3/pkg/chart"
+	rpb "helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/storage"
+	"helm.sh/helm/v3/pkg/storage/driver"
+	corev1 "k8s.io/api/core/v1"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+func TestReleaseDigest(t *testing.T) {
+	chart := &cpb.Chart{Metadata: &cpb.Metadata{Name: "webapp", Version: "1.0.0"}}
+	values := map[string]interface{}{"replicaCount": 1}
+
+	digest, err := releaseDigest(chart, values, "default", nil, nil)
+	require.NoError(t, err)
+
+	same, err := releaseDigest(chart, map[string]interface{}{"replicaCount": 1}, "default", nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, digest, same)
+
+	// every input of the render changes the digest
+	for name, changed := range map[string]func() (string, error){
+		"values": func() (string, error) {
+			return releaseDigest(chart, map[string]interface{}{"replicaCount": 2}, "default", nil, nil)
+		},
+		"namespace": func() (string, error) {
+			return releaseDigest(chart, values, "other", nil, nil)
+		},
+		"rules": func() (string, error) {
+			return releaseDigest(chart, values, "default",
+				[]appv1.ResourceIgnoreDifferences{{Kind: "Deployment", JSONPointers: []string{"/spec/replicas"}}}, nil)
+		},
+		"pullSecrets": func() (string, error) {
+			return releaseDigest(chart, values, "default", nil, postRenderSettings(&appv1.HelmReleaseRepo{
+				ImagePullSecrets: []corev1.LocalObjectReference{{Name: "registry"}}}))
+		},
+		"mirrors": func() (string, error) {
+			return releaseDigest(chart, values, "default", nil, postRenderSettings(&appv1.HelmReleaseRepo{
+				ImageMirrors: []appv1.ImageMirror{{Registry: "docker.io", Mirror: "mirror.example.com"}}}))
+		},
+		"chart": func() (string, error) {
+			return releaseDigest(&cpb.Chart{Metadata: &cpb.Metadata{Name: "webapp", Version: "1.0.1"}},
+				values, "default", nil, nil)
+		},
+		"dependency": func() (string, error) {
+			parent := &cpb.Chart{Metadata: &cpb.Metadata{Name: "webapp", Version: "1.0.0"}}
+			parent.AddDependency(&cpb.Chart{Metadata: &cpb.Metadata{Name: "redis", Version: "10.0.0"}})
+
+			return releaseDigest(parent, values, "default", nil, nil)
+		},
+	} {
+		other, err := changed()
+		require.NoError(t, err)
+		assert.NotEqual(t, digest, other, name)
+	}
+}
+
+func TestSyncUnchangedRelease(t *testing.T) {
+	deployed := &rpb.Release{
+		Name:     "webapp",
+		Version:  1,
+		Manifest: "kind: ConfigMap",
+		Info:     &rpb.Info{Status: rpb.StatusDeployed},
+	}
+
+	storageBackend := storage.Init(driver.NewMemory())
+	require.NoError(t, storageBackend.Create(deployed))
+
+	// the deployed release was rendered from the same inputs, the candidate
+	// is not rendered, which would fail without an action configuration
+	m := &manager{
+		storageBackend: storageBackend,
+		releaseName:    "webapp",
+		digest:         "digest",
+		status: &appv1.HelmAppStatus{
+			DeployedRelease: &appv1.HelmAppRelease{Manifest: deployed.Manifest, Digest: "digest"},
+		},
+	}
+
+	require.NoError(t, m.Sync(context.TODO()))
+	assert.True(t, m.IsInstalled())
+	assert.False(t, m.IsUpgradeRequired())
+}
+
+func TestValuesDigest(t *testing.T) {
+	digest := valuesDigest(map[string]interface{}{"image": map[string]interface{}{"tag": "1.0", "repository": "webapp"}})
+
+	assert.Len(t, digest, 64)
+	assert.Equal(t, digest, valuesDigest(map[string]interface{}{"image": map[string]interface{}{"repository": "webapp", "tag": "1.0"}}))
+	assert.NotEqual(t, digest, valuesDigest(map[string]interface{}{"image": map[string]interface{}{"tag": "1.1", "repository": "webapp"}}))
+}
+
+func TestChartDigest(t *testing.T) {
+	chart := &cpb.Chart{Metadata: &cpb.Metadata{Name: "nginx", Version: "1.0.0"}}
+
+	digest := ChartDigest(chart)
+	assert.True(t, strings.HasPrefix(digest, "sha256:"))
+	assert.Equal(t, digest, ChartDigest(&cpb.Chart{Metadata: &cpb.Metadata{Name: "nginx", Version: "1.0.0"}}))
+
+	chart.Metadata.Version = "1.0.1"
+	assert.NotEqual(t, digest, ChartDigest(chart))
+
+	// the dependencies are part of the digest
+	parent := &cpb.Chart{Metadata: &cpb.Metadata{Name: "app", Version: "1.0.0"}}
+	withDependency := &cpb.Chart{Metadata: &cpb.Metadata{Name: "app", Version: "1.0.0"}}
+	withDependency.AddDependency(chart)
+	assert.NotEqual(t, ChartDigest(parent), ChartDigest(withDependency))
+}
+
+func TestManifestDigest(t *testing.T) {
+	manifest := "---\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: nginx\n"
+
+	assert.Len(t, ManifestDigest(manifest), 64)
+	assert.Equal(t, ManifestDigest(manifest), ManifestDigest(manifest))
+	assert.NotEqual(t, ManifestDigest(manifest), ManifestDigest(manifest+"data:\n  a: b\n"))
+}
@@ -0,0 +1,153 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"helm.sh/helm/v3/pkg/kube"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/cli-runtime/pkg/resource"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+// DetectDrift returns the resources of the deployed release whose live state
+// no longer matches the deployed manifest, e.g. after a manual kubectl edit.
+// Missing resources are drifted too. The fields matched by the
+// ignoreDifferences rules are not compared.
+func (m manager) DetectDrift(ctx context.Context) ([]appv1.HelmAppResource, error) {
+	drifted, err := m.driftedResources()
+	if err != nil {
+		return nil, err
+	}
+
+	return resourceRefs(drifted), nil
+}
+
+// RemediateDrift re-applies the drifted resources of the deployed release
+// and returns them.
+func (m manager) RemediateDrift(ctx context.Context) ([]appv1.HelmAppResource, error) {
+	drifted, err := m.driftedResources()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(drifted) == 0 {
+		return nil, nil
+	}
+
+	// original and target are the same so that nothing gets deleted
+	if _, err := m.kubeClient.Update(drifted, drifted, false); err != nil {
+		return nil, fmt.Errorf("failed to re-apply drifted resources: %w", err)
+	}
+
+	return resourceRefs(drifted), nil
+}
+
+func (m manager) driftedResources() (kube.ResourceList, error) {
+	if m.deployedRelease == nil {
+		return nil, nil
+	}
+
+	expected, err := m.kubeClient.Build(bytes.NewBufferString(m.deployedRelease.Manifest), false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build deployed release resources: %w", err)
+	}
+
+	var drifted kube.ResourceList
+
+	err = expected.Visit(func(info *resource.Info, err error) error {
+		if err != nil {
+			return err
+		}
+
+		// the ignored fields are not re-applied either
+		if info.Object, err = m.withoutIgnoredFields(info.Object); err != nil {
+			return err
+		}
+
+		helper := resource.NewHelper(info.Client, info.Mapping)
+
+		existing, err := helper.Get(info.Namespace, info.Name)
+		if apierrors.IsNotFound(err) {
+			drifted = append(drifted, info)
+			return nil
+		}
+
+		if err != nil {
+			return fmt.Errorf("could not get object: %w", err)
+		}
+
+		if existing, err = m.withoutIgnoredFields(existing); err != nil {
+			return err
+		}
+
+		// the same patch as the upgrades, empty if the chart fields are unchanged
+		patch, _, err := createPatch(existing, info)
+		if err != nil {
+			return fmt.Errorf("error creating patch: %w", err)
+		}
+
+		if patch != nil && string(patch) != "{}" {
+			drifted = append(drifted, info)
+		}
+
+		return nil
+	})
+
+	return drifted, err
+}
+
+func (m manager) withoutIgnoredFields(obj runtime.Object) (runtime.Object, error) {
+	if len(m.ignoreDifferences) == 0 {
+		return obj, nil
+	}
+
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	u := &unstructured.Unstructured{Object: runtime.DeepCopyJSON(content)}
+	if err := ignoreDifferences(u, m.ignoreDifferences); err != nil {
+		return nil, err
+	}
+
+	return u, nil
+}
+
+func resourceRefs(resources kube.ResourceList) []appv1.HelmAppResource {
+	refs := make([]appv1.HelmAppResource, 0, len(resources))
+
+	for _, info := range resources {
+		gvk := info.Mapping.GroupVersionKind
+
+		refs = append(refs, appv1.HelmAppResource{
+			APIVersion: gvk.GroupVersion().String(),
+			Kind:       gvk.Kind,
+			Namespace:  info.Namespace,
+			Name:       info.Name,
+		})
+	}
+
+	sortResources(refs)
+
+	return refs
+}
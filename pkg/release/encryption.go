@@ -0,0 +1,471 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	cpb "helm.sh/helm/v3/pkg/chart"
+	rpb "helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/storage/driver"
+)
+
+// encryptedManifestPrefix marks the manifest of a release record holding the
+// encrypted payload of the release instead.
+const encryptedManifestPrefix = "encrypted:v1:"
+
+// keyWrapper encrypts and decrypts the data keys of the release payloads with
+// a key managed outside of the cluster.
+type keyWrapper interface {
+	wrap(key []byte) (string, error)
+	unwrap(wrapped string) ([]byte, error)
+}
+
+// releasePayload is the part of a release that is encrypted: everything that
+// may hold secrets, the rendered manifests, the values and the chart.
+type releasePayload struct {
+	Manifest string                 `json:"manifest,omitempty"`
+	Config   map[string]interface{} `json:"config,omitempty"`
+	Chart    *cpb.Chart             `json:"chart,omitempty"`
+	Hooks    []*rpb.Hook            `json:"hooks,omitempty"`
+	Notes    string                 `json:"notes,omitempty"`
+}
+
+// encryptedPayload is the encrypted payload of a release and its data key,
+// encrypted with the key wrapper.
+type encryptedPayload struct {
+	Key   string `json:"key"`
+	Nonce []byte `json:"nonce"`
+	Data  []byte `json:"data"`
+}
+
+// encryptedDriver envelope encrypts the payload of the releases stored by
+// the helm storage driver it wraps, each record with its own data key. The
+// name, version, status and labels of the releases are left in clear so that
+// the records can still be listed and queried. The records stored before the
+// encryption was enabled are read as is.
+type encryptedDriver struct {
+	driver.Driver
+	keys keyWrapper
+}
+
+func (d *encryptedDriver) Create(key string, rls *rpb.Release) error {
+	encrypted, err := d.encrypt(rls)
+	if err != nil {
+		return err
+	}
+
+	return d.Driver.Create(key, encrypted)
+}
+
+func (d *encryptedDriver) Update(key string, rls *rpb.Release) error {
+	encrypted, err := d.encrypt(rls)
+	if err != nil {
+		return err
+	}
+
+	return d.Driver.Update(key, encrypted)
+}
+
+func (d *encryptedDriver) Get(key string) (*rpb.Release, error) {
+	rls, err := d.Driver.Get(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return d.decrypt(rls)
+}
+
+// Delete returns the deleted release encrypted if it cannot be decrypted, it
+// is deleted anyway.
+func (d *encryptedDriver) Delete(key string) (*rpb.Release, error) {
+	rls, err := d.Driver.Delete(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if decrypted, err := d.decrypt(rls); err == nil {
+		return decrypted, nil
+	}
+
+	return rls, nil
+}
+
+// List filters the decrypted releases, the filters may look at their payload.
+func (d *encryptedDriver) List(filter func(*rpb.Release) bool) ([]*rpb.Release, error) {
+	releases, err := d.Driver.List(func(*rpb.Release) bool { return true })
+	if err != nil {
+		return nil, err
+	}
+
+	var filtered []*rpb.Release
+
+	for _, rls := range releases {
+		if rls, err = d.decrypt(rls); err != nil {
+			return nil, err
+		}
+
+		if filter(rls) {
+			filtered = append(filtered, rls)
+		}
+	}
+
+	return filtered, nil
+}
+
+func (d *encryptedDriver) Query(labels map[string]string) ([]*rpb.Release, error) {
+	releases, err := d.Driver.Query(labels)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range releases {
+		if releases[i], err = d.decrypt(releases[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	return releases, nil
+}
+
+// encrypt returns a copy of rls with its payload encrypted, rls is still
+// used by helm once stored.
+func (d *encryptedDriver) encrypt(rls *rpb.Release) (*rpb.Release, error) {
+	payload := releasePayload{Manifest: rls.Manifest, Config: rls.Config, Chart: rls.Chart, Hooks: rls.Hooks}
+	if rls.Info != nil {
+		payload.Notes = rls.Info.Notes
+	}
+
+	plaintext, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	dataKey := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, dataKey); err != nil {
+		return nil, err
+	}
+
+	wrapped, err := d.keys.wrap(dataKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt the data key of release %s: %w", rls.Name, err)
+	}
+
+	gcm, err := newGCM(dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	sealed, err := json.Marshal(encryptedPayload{
+		Key:   wrapped,
+		Nonce: nonce,
+		Data:  gcm.Seal(nil, nonce, plaintext, releaseAAD(rls)),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	encrypted := *rls
+	encrypted.Manifest = encryptedManifestPrefix + base64.StdEncoding.EncodeToString(sealed)
+	encrypted.Config = nil
+	encrypted.Hooks = nil
+
+	// helm lists the releases by their chart name and version
+	if rls.Chart != nil {
+		encrypted.Chart = &cpb.Chart{Metadata: rls.Chart.Metadata}
+	}
+
+	if rls.Info != nil {
+		info := *rls.Info
+		info.Notes = ""
+		encrypted.Info = &info
+	}
+
+	return &encrypted, nil
+}
+
+// decrypt returns a copy of rls with its payload decrypted, the releases
+// stored in clear are returned as is.
+func (d *encryptedDriver) decrypt(rls *rpb.Release) (*rpb.Release, error) {
+	if rls == nil || !strings.HasPrefix(rls.Manifest, encryptedManifestPrefix) {
+		return rls, nil
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(rls.Manifest, encryptedManifestPrefix))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode the encrypted release %s: %w", rls.Name, err)
+	}
+
+	var encrypted encryptedPayload
+	if err := json.Unmarshal(sealed, &encrypted); err != nil {
+		return nil, fmt.Errorf("failed to decode the encrypted release %s: %w", rls.Name, err)
+	}
+
+	dataKey, err := d.keys.unwrap(encrypted.Key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt the data key of release %s: %w", rls.Name, err)
+	}
+
+	gcm, err := newGCM(dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext, err := gcm.Open(nil, encrypted.Nonce, encrypted.Data, releaseAAD(rls))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt release %s: %w", rls.Name, err)
+	}
+
+	var payload releasePayload
+	if err := json.Unmarshal(plaintext, &payload); err != nil {
+		return nil, fmt.Errorf("failed to decode the decrypted release %s: %w", rls.Name, err)
+	}
+
+	decrypted := *rls
+	decrypted.Manifest, decrypted.Config, decrypted.Chart, decrypted.Hooks =
+		payload.Manifest, payload.Config, payload.Chart, payload.Hooks
+
+	if rls.Info != nil {
+		info := *rls.Info
+		info.Notes = payload.Notes
+		decrypted.Info = &info
+	}
+
+	return &decrypted, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// releaseAAD binds the encrypted payload to its release revision, so that it
+// cannot be swapped with the payload of another record.
+func releaseAAD(rls *rpb.Release) []byte {
+	return []byte(fmt.Sprintf("%s.v%d", rls.Name, rls.Version))
+}
+
+// vaultDataKeysCacheSize is the number of unwrapped data keys kept in memory
+// per transit key
+const vaultDataKeysCacheSize = 256
+
+// vaultTransit wraps the data keys with a key of the Vault transit secrets
+// engine. The unwrapped data keys are cached, a record is decrypted at each
+// reconcile of its HelmRelease. The least recently used keys are dropped
+// first and wiped, so that at most vaultDataKeysCacheSize keys are held.
+type vaultTransit struct {
+	address   string
+	mount     string
+	key       string
+	tokenFile string
+	client    *http.Client
+
+	mu       sync.Mutex
+	keyOrder *list.List
+	dataKeys map[string]*list.Element
+}
+
+type vaultDataKey struct {
+	wrapped string
+	key     []byte
+}
+
+var (
+	// vaultTransits reuses the cache of the data keys across reconciles,
+	// keyed by address and key
+	vaultTransits   = map[string]*vaultTransit{}
+	vaultTransitsMu sync.Mutex
+)
+
+// newVaultTransit returns the transit key wrapper of opts.
+func newVaultTransit(opts StorageOptions) *vaultTransit {
+	vaultTransitsMu.Lock()
+	defer vaultTransitsMu.Unlock()
+
+	id := opts.VaultAddress + "/" + opts.VaultTransitKey
+
+	t, ok := vaultTransits[id]
+	if !ok {
+		// the key is the last segment, the mount may be nested
+		i := strings.LastIndex(opts.VaultTransitKey, "/")
+
+		t = &vaultTransit{
+			address:   strings.TrimSuffix(opts.VaultAddress, "/"),
+			mount:     opts.VaultTransitKey[:i],
+			key:       opts.VaultTransitKey[i+1:],
+			tokenFile: opts.VaultTokenFile,
+			client:    &http.Client{Timeout: 30 * time.Second},
+			keyOrder:  list.New(),
+			dataKeys:  make(map[string]*list.Element),
+		}
+		vaultTransits[id] = t
+	}
+
+	return t
+}
+
+func (t *vaultTransit) wrap(key []byte) (string, error) {
+	var resp struct {
+		Data struct {
+			Ciphertext string `json:"ciphertext"`
+		} `json:"data"`
+	}
+
+	if err := t.call("encrypt", map[string]string{"plaintext": base64.StdEncoding.EncodeToString(key)}, &resp); err != nil {
+		return "", err
+	}
+
+	t.cacheKey(resp.Data.Ciphertext, key)
+
+	return resp.Data.Ciphertext, nil
+}
+
+func (t *vaultTransit) unwrap(wrapped string) ([]byte, error) {
+	if key, ok := t.cachedKey(wrapped); ok {
+		return key, nil
+	}
+
+	var resp struct {
+		Data struct {
+			Plaintext string `json:"plaintext"`
+		} `json:"data"`
+	}
+
+	if err := t.call("decrypt", map[string]string{"ciphertext": wrapped}, &resp); err != nil {
+		return nil, err
+	}
+
+	key, err := base64.StdEncoding.DecodeString(resp.Data.Plaintext)
+	if err != nil {
+		return nil, err
+	}
+
+	t.cacheKey(wrapped, key)
+
+	return key, nil
+}
+
+// cachedKey returns a copy of the data key unwrapped from wrapped, if cached.
+func (t *vaultTransit) cachedKey(wrapped string) ([]byte, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	e, ok := t.dataKeys[wrapped]
+	if !ok {
+		return nil, false
+	}
+
+	t.keyOrder.MoveToFront(e)
+
+	return append([]byte(nil), e.Value.(*vaultDataKey).key...), true
+}
+
+// cacheKey caches a copy of key as the data key unwrapped from wrapped,
+// wiping the least recently used keys beyond vaultDataKeysCacheSize.
+func (t *vaultTransit) cacheKey(wrapped string, key []byte) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if e, ok := t.dataKeys[wrapped]; ok {
+		t.keyOrder.MoveToFront(e)
+		return
+	}
+
+	t.dataKeys[wrapped] = t.keyOrder.PushFront(&vaultDataKey{wrapped: wrapped, key: append([]byte(nil), key...)})
+
+	for t.keyOrder.Len() > vaultDataKeysCacheSize {
+		oldest := t.keyOrder.Remove(t.keyOrder.Back()).(*vaultDataKey)
+		delete(t.dataKeys, oldest.wrapped)
+
+		for i := range oldest.key {
+			oldest.key[i] = 0
+		}
+	}
+}
+
+// call posts body to the encrypt or decrypt endpoint of the transit key and
+// decodes the response into out.
+func (t *vaultTransit) call(operation string, body map[string]string, out interface{}) error {
+	token, err := t.token()
+	if err != nil {
+		return err
+	}
+
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	url := fmt.Sprintf("%s/v1/%s/%s/%s", t.address, t.mount, operation, t.key)
+
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("X-Vault-Token", token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to %s with vault transit key %s/%s: %w", operation, t.mount, t.key, err)
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to %s with vault transit key %s/%s: %s", operation, t.mount, t.key, resp.Status)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// token returns the Vault token, read from the token file at each request so
+// that a renewed token, e.g. by the Vault agent, is used right away.
+func (t *vaultTransit) token() (string, error) {
+	if t.tokenFile == "" {
+		return os.Getenv("VAULT_TOKEN"), nil
+	}
+
+	token, err := ioutil.ReadFile(t.tokenFile)
+	if err != nil {
+		return "", fmt.Errorf("failed to read the vault token: %w", err)
+	}
+
+	return strings.TrimSpace(string(token)), nil
+}
@@ -0,0 +1,123 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release
+
+import (
+	"container/list"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	cpb "helm.sh/helm/v3/pkg/chart"
+	rpb "helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/storage/driver"
+)
+
+// fakeKeys wraps the data keys by encoding them
+type fakeKeys struct{}
+
+func (fakeKeys) wrap(key []byte) (string, error) {
+	return base64.StdEncoding.EncodeToString(key), nil
+}
+
+func (fakeKeys) unwrap(wrapped string) ([]byte, error) {
+	return base64.StdEncoding.DecodeString(wrapped)
+}
+
+func TestEncryptedDriver(t *testing.T) {
+	memory := driver.NewMemory()
+	d := &encryptedDriver{Driver: memory, keys: fakeKeys{}}
+
+	rls := &rpb.Release{
+		Name:     "app",
+		Version:  1,
+		Manifest: "kind: Secret\ndata:\n  password: aHVudGVyMg==",
+		Config:   map[string]interface{}{"password": "hunter2"},
+		Chart:    &cpb.Chart{Metadata: &cpb.Metadata{Name: "app", Version: "1.0.0"}},
+		Info:     &rpb.Info{Status: rpb.StatusDeployed, Notes: "login with hunter2"},
+	}
+
+	assert.NoError(t, d.Create("sh.helm.release.v1.app.v1", rls))
+
+	// the caller keeps its release as is
+	assert.Equal(t, "login with hunter2", rls.Info.Notes)
+
+	stored, err := memory.Get("sh.helm.release.v1.app.v1")
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(stored.Manifest, encryptedManifestPrefix))
+	assert.NotContains(t, stored.Manifest, "aHVudGVyMg==")
+	assert.Nil(t, stored.Config)
+	assert.Empty(t, stored.Info.Notes)
+	assert.Equal(t, "app", stored.Chart.Metadata.Name)
+
+	got, err := d.Get("sh.helm.release.v1.app.v1")
+	assert.NoError(t, err)
+	assert.Equal(t, rls.Manifest, got.Manifest)
+	assert.Equal(t, "hunter2", got.Config["password"])
+	assert.Equal(t, "login with hunter2", got.Info.Notes)
+
+	deployed, err := d.List(func(r *rpb.Release) bool { return strings.Contains(r.Manifest, "password") })
+	assert.NoError(t, err)
+	assert.Len(t, deployed, 1)
+
+	// a payload swapped to another revision does not decrypt
+	swapped := *stored
+	swapped.Version = 2
+	_, err = d.decrypt(&swapped)
+	assert.Error(t, err)
+
+	// the records stored in clear are read as is
+	assert.NoError(t, memory.Create("sh.helm.release.v1.legacy.v1", &rpb.Release{Name: "legacy", Version: 1,
+		Manifest: "kind: ConfigMap", Info: &rpb.Info{Status: rpb.StatusDeployed}}))
+
+	legacy, err := d.Get("sh.helm.release.v1.legacy.v1")
+	assert.NoError(t, err)
+	assert.Equal(t, "kind: ConfigMap", legacy.Manifest)
+}
+
+func TestVaultTransitDataKeys(t *testing.T) {
+	tr := &vaultTransit{keyOrder: list.New(), dataKeys: map[string]*list.Element{}}
+
+	first := []byte("first data key")
+	tr.cacheKey("vault:v1:first", first)
+
+	// the cached keys are copies
+	key, ok := tr.cachedKey("vault:v1:first")
+	assert.True(t, ok)
+	assert.Equal(t, first, key)
+
+	key[0] = 'x'
+	key, _ = tr.cachedKey("vault:v1:first")
+	assert.Equal(t, first, key)
+
+	cached := tr.dataKeys["vault:v1:first"].Value.(*vaultDataKey).key
+
+	// the least recently used keys are dropped and wiped
+	for i := 0; i < vaultDataKeysCacheSize; i++ {
+		tr.cacheKey(fmt.Sprintf("vault:v1:%d", i), []byte("data key"))
+	}
+
+	assert.Equal(t, vaultDataKeysCacheSize, tr.keyOrder.Len())
+	assert.Len(t, tr.dataKeys, vaultDataKeysCacheSize)
+
+	_, ok = tr.cachedKey("vault:v1:first")
+	assert.False(t, ok)
+	assert.Equal(t, make([]byte, len(first)), cached)
+
+	_, ok = tr.cachedKey("vault:v1:0")
+	assert.True(t, ok)
+}
@@ -0,0 +1,150 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release
+
+import (
+	"bytes"
+	"reflect"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// envRenderer is a helm post-renderer appending environment variables to the
+// containers of the rendered workloads.
+type envRenderer struct {
+	env     []corev1.EnvVar
+	envFrom []corev1.EnvFromSource
+}
+
+func (r envRenderer) Run(rendered *bytes.Buffer) (*bytes.Buffer, error) {
+	injected, err := injectEnv(rendered.String(), r.env, r.envFrom)
+	if err != nil {
+		return nil, err
+	}
+
+	return bytes.NewBufferString(injected), nil
+}
+
+// injectEnv appends the variables of env to the containers and init
+// containers of the workloads of manifest, except those they already define,
+// and the sources of envFrom they do not already have.
+func injectEnv(manifest string, env []corev1.EnvVar, envFrom []corev1.EnvFromSource) (string, error) {
+	vars := make([]map[string]interface{}, 0, len(env))
+
+	for i := range env {
+		v, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&env[i])
+		if err != nil {
+			return "", err
+		}
+
+		vars = append(vars, v)
+	}
+
+	sources := make([]map[string]interface{}, 0, len(envFrom))
+
+	for i := range envFrom {
+		s, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&envFrom[i])
+		if err != nil {
+			return "", err
+		}
+
+		sources = append(sources, s)
+	}
+
+	return transformPodSpecs(manifest, func(spec map[string]interface{}) bool {
+		injected := false
+
+		// the ephemeral containers are not part of the templates
+		for _, field := range []string{"containers", "initContainers"} {
+			containers, _ := spec[field].([]interface{})
+
+			for _, c := range containers {
+				container, ok := c.(map[string]interface{})
+				if !ok {
+					continue
+				}
+
+				injected = appendEnv(container, vars) || injected
+				injected = appendEnvFrom(container, sources) || injected
+			}
+		}
+
+		return injected
+	}), nil
+}
+
+// appendEnv appends the variables the container does not define and returns
+// true if any was appended.
+func appendEnv(container map[string]interface{}, vars []map[string]interface{}) bool {
+	env, _ := container["env"].([]interface{})
+
+	defined := map[interface{}]bool{}
+
+	for _, v := range env {
+		if v, ok := v.(map[string]interface{}); ok {
+			defined[v["name"]] = true
+		}
+	}
+
+	appended := false
+
+	for _, v := range vars {
+		if defined[v["name"]] {
+			continue
+		}
+
+		env = append(env, runtime.DeepCopyJSON(v))
+		appended = true
+	}
+
+	if appended {
+		container["env"] = env
+	}
+
+	return appended
+}
+
+// appendEnvFrom appends the sources the container does not have and returns
+// true if any was appended.
+func appendEnvFrom(container map[string]interface{}, sources []map[string]interface{}) bool {
+	envFrom, _ := container["envFrom"].([]interface{})
+
+	appended := false
+
+	for _, s := range sources {
+		found := false
+
+		for _, existing := range envFrom {
+			if reflect.DeepEqual(existing, s) {
+				found = true
+				break
+			}
+		}
+
+		if found {
+			continue
+		}
+
+		envFrom = append(envFrom, runtime.DeepCopyJSON(s))
+		appended = true
+	}
+
+	if appended {
+		container["envFrom"] = envFrom
+	}
+
+	return appended
+}
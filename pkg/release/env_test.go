@@ -0,0 +1,97 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const testEnvDeploymentManifest = `---
+# Source: test/templates/env.yaml
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: env
+spec:
+  template:
+    spec:
+      containers:
+      - name: test
+        image: nginx:1.19
+        env:
+        - name: HTTP_PROXY
+          value: http://chart-proxy:3128
+`
+
+func TestInjectEnv(t *testing.T) {
+	deployment := fmt.Sprintf(testDeploymentManifest, "1", 1, "nginx:1.19")
+	configMap := fmt.Sprintf(testConfigMapManifest, "a")
+
+	env := []corev1.EnvVar{
+		{Name: "HTTP_PROXY", Value: "http://proxy:3128"},
+		{Name: "OTEL_ENDPOINT", ValueFrom: &corev1.EnvVarSource{
+			ConfigMapKeyRef: &corev1.ConfigMapKeySelector{
+				LocalObjectReference: corev1.LocalObjectReference{Name: "telemetry"},
+				Key:                  "endpoint",
+			},
+		}},
+	}
+	envFrom := []corev1.EnvFromSource{
+		{SecretRef: &corev1.SecretEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: "proxy"}}},
+	}
+
+	injected, err := injectEnv(deployment+testEnvDeploymentManifest+configMap, env, envFrom)
+	assert.NoError(t, err)
+
+	objects, err := manifestObjects(injected)
+	assert.NoError(t, err)
+	assert.Len(t, objects, 3)
+
+	for _, u := range objects {
+		if u.GetKind() != "Deployment" {
+			continue
+		}
+
+		containers, _, _ := unstructured.NestedSlice(u.Object, "spec", "template", "spec", "containers")
+		container := containers[0].(map[string]interface{})
+
+		vars := container["env"].([]interface{})
+		assert.Len(t, vars, 2)
+
+		// the variables of the chart are kept
+		proxy := vars[0].(map[string]interface{})
+		if u.GetName() == "env" {
+			assert.Equal(t, "http://chart-proxy:3128", proxy["value"])
+		} else {
+			assert.Equal(t, "http://proxy:3128", proxy["value"])
+		}
+
+		assert.Equal(t, "OTEL_ENDPOINT", vars[1].(map[string]interface{})["name"])
+		assert.Len(t, container["envFrom"], 1)
+	}
+
+	assert.True(t, strings.HasSuffix(injected, configMap))
+
+	// the variables already injected are kept as is
+	again, err := injectEnv(injected, env, envFrom)
+	assert.NoError(t, err)
+	assert.Equal(t, injected, again)
+}
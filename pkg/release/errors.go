@@ -0,0 +1,193 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	rpb "helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/storage/driver"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+var (
+	// ErrReleaseNotFound is returned when the release, or its deployed
+	// revision, does not exist. It is the helm storage driver error so that
+	// both can be matched with errors.Is.
+	ErrReleaseNotFound = driver.ErrReleaseNotFound
+
+	// ErrHooksFailed matches the install, upgrade and uninstall errors caused
+	// by a failed chart hook.
+	ErrHooksFailed = errors.New("release hooks failed")
+
+	// ErrRenderFailed matches the errors caused by a chart that fails to
+	// render, e.g. a template error or a manifest that is not valid YAML.
+	ErrRenderFailed = errors.New("release render failed")
+
+	// ErrStorageFailed matches the errors reading or writing the release
+	// records of the helm storage.
+	ErrStorageFailed = errors.New("release storage failed")
+)
+
+// ErrUpgradeFailed is returned when an upgrade fails. RolledBack is set if
+// the release was rolled back to the previous revision and RollbackErr if the
+// rollback failed too.
+type ErrUpgradeFailed struct {
+	Err         error
+	RolledBack  bool
+	RollbackErr error
+}
+
+func (e *ErrUpgradeFailed) Error() string {
+	if e.RollbackErr != nil {
+		return fmt.Sprintf("failed upgrade (%s) and failed rollback: %s", e.Err, e.RollbackErr)
+	}
+
+	return fmt.Sprintf("failed to upgrade release: %s", e.Err)
+}
+
+func (e *ErrUpgradeFailed) Unwrap() error {
+	return e.Err
+}
+
+// ErrApplyConflict is returned when server-side apply fails because fields of
+// the resource are owned by other field managers.
+type ErrApplyConflict struct {
+	Resource  appv1.HelmAppResource
+	Conflicts []string
+}
+
+func (e *ErrApplyConflict) Error() string {
+	return fmt.Sprintf("failed to apply %s, conflicts with other field managers: %s",
+		e.Resource, strings.Join(e.Conflicts, ", "))
+}
+
+// ErrNameConflict is returned when the release name of a custom resource is
+// already used in its storage namespace by another custom resource, or by a
+// release of another chart or target namespace.
+type ErrNameConflict struct {
+	ReleaseName string
+	Detail      string
+}
+
+func (e *ErrNameConflict) Error() string {
+	return fmt.Sprintf("duplicate release name %q: %s", e.ReleaseName, e.Detail)
+}
+
+// ErrReleasePending is returned by Sync when the latest revision of the
+// release was left pending by a helm action that never completed. Action is
+// how it was recovered, e.g. rolled back, empty if it was left as is.
+type ErrReleasePending struct {
+	Version int
+	Status  rpb.Status
+	Action  string
+}
+
+func (e *ErrReleasePending) Error() string {
+	if e.Action == "" {
+		return fmt.Sprintf("revision %d of the release was left %s, request a reconcile to retry it", e.Version, e.Status)
+	}
+
+	return fmt.Sprintf("revision %d of the release was left %s and was %s", e.Version, e.Status, e.Action)
+}
+
+// hooksFailedError keeps the helm error of a failed hook while matching
+// ErrHooksFailed.
+type hooksFailedError struct {
+	err error
+}
+
+func (e *hooksFailedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *hooksFailedError) Unwrap() error {
+	return e.err
+}
+
+func (e *hooksFailedError) Is(target error) bool {
+	return target == ErrHooksFailed
+}
+
+// withHooksFailed marks the helm action errors caused by a failed hook. Helm
+// only reports them as formatted strings.
+func withHooksFailed(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	msg := err.Error()
+	for _, s := range []string{"hooks failed", "failed pre-", "failed post-", "warning: Hook "} {
+		if strings.Contains(msg, s) {
+			return &hooksFailedError{err}
+		}
+	}
+
+	return err
+}
+
+// classifiedError keeps an error while matching its class, one of the
+// sentinel errors.
+type classifiedError struct {
+	err   error
+	class error
+}
+
+func (e *classifiedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *classifiedError) Unwrap() error {
+	return e.err
+}
+
+func (e *classifiedError) Is(target error) bool {
+	return target == e.class
+}
+
+// renderFailed marks err as a render failure.
+func renderFailed(err error) error {
+	return &classifiedError{err: err, class: ErrRenderFailed}
+}
+
+// storageFailed marks err as a storage failure.
+func storageFailed(err error) error {
+	return &classifiedError{err: err, class: ErrStorageFailed}
+}
+
+// withRenderFailed marks the helm action errors caused by a chart that fails
+// to render. Helm only reports them as formatted strings.
+func withRenderFailed(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	msg := err.Error()
+	for _, s := range []string{"template: ", "parse error", "unable to build kubernetes objects from release manifest"} {
+		if strings.Contains(msg, s) {
+			return renderFailed(err)
+		}
+	}
+
+	return err
+}
+
+// notFoundErr returns true if err is, or wraps, ErrReleaseNotFound.
+func notFoundErr(err error) bool {
+	return errors.Is(err, ErrReleaseNotFound)
+}
@@ -0,0 +1,49 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTypedErrors(t *testing.T) {
+	hookErr := withHooksFailed(errors.New("failed pre-install: timed out waiting for the condition"))
+	assert.True(t, errors.Is(hookErr, ErrHooksFailed))
+	assert.False(t, errors.Is(withHooksFailed(errors.New("connection refused")), ErrHooksFailed))
+
+	var err error = &ErrUpgradeFailed{Err: hookErr, RollbackErr: errors.New("rollback timed out")}
+
+	var upgradeErr *ErrUpgradeFailed
+	assert.True(t, errors.As(fmt.Errorf("reconcile: %w", err), &upgradeErr))
+	assert.NotNil(t, upgradeErr.RollbackErr)
+	assert.True(t, errors.Is(err, ErrHooksFailed))
+
+	renderErr := withRenderFailed(errors.New(`template: nginx/templates/deployment.yaml:12:20: executing "nginx/templates/deployment.yaml" at <.Values.image.tag>: nil pointer evaluating interface {}.tag`))
+	assert.True(t, errors.Is(fmt.Errorf("failed to install release: %w", renderErr), ErrRenderFailed))
+	assert.False(t, errors.Is(withRenderFailed(errors.New("connection refused")), ErrRenderFailed))
+	assert.False(t, errors.Is(hookErr, ErrRenderFailed))
+
+	storageErr := storageFailed(fmt.Errorf("failed to retrieve release history: %w", errors.New("etcdserver: request timed out")))
+	assert.True(t, errors.Is(storageErr, ErrStorageFailed))
+	assert.False(t, errors.Is(storageErr, ErrRenderFailed))
+	assert.Equal(t, "failed to retrieve release history: etcdserver: request timed out", storageErr.Error())
+
+	assert.True(t, notFoundErr(fmt.Errorf("uninstall: %w", ErrReleaseNotFound)))
+	assert.False(t, notFoundErr(errors.New("namespace not found")))
+}
@@ -0,0 +1,491 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package fake provides an in-memory release.Manager, so that the reconcile
+// logic of the operators built on the release package can be tested without
+// a cluster nor the helm storage, e.g.
+//
+//	m := fake.NewManager("nginx", "default", chrt)
+//	m.UpgradeErr = errors.New("timed out waiting for the condition")
+//	r := newReconciler(fake.ManagerFactory{Manager: m})
+//	...
+//	assert.Equal(t, []string{"Sync", "InstallRelease"}, m.Calls())
+package fake
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"helm.sh/helm/v3/pkg/action"
+	cpb "helm.sh/helm/v3/pkg/chart"
+	rpb "helm.sh/helm/v3/pkg/release"
+	helmtime "helm.sh/helm/v3/pkg/time"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+	"github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/release"
+)
+
+var _ release.Manager = &Manager{}
+
+// Manager is an in-memory release.Manager. Its revisions are recorded in
+// memory: the installs, upgrades and rollbacks record a deployed revision of
+// Manifest, superseding the previous one, and the uninstalls remove them. Its
+// methods fail with the errors set on it. The fields are set before the
+// manager is used, its methods are safe for concurrent use.
+type Manager struct {
+	// Name and Namespace are the name and the namespace of the release.
+	Name      string
+	Namespace string
+	// CandidateChart is the chart returned by Chart, installed and upgraded to.
+	CandidateChart *cpb.Chart
+	// Values are the values of the installed and upgraded revisions.
+	Values map[string]interface{}
+	// Manifest is the manifest of the installed and upgraded revisions. Sync
+	// reports an upgrade as required when the deployed manifest differs.
+	Manifest string
+	// Digest and ValuesHash are returned by ReleaseDigest and ValuesDigest.
+	Digest     string
+	ValuesHash string
+
+	// SyncErr, InstallErr, UpgradeErr, UninstallErr, RollbackErr and LockErr
+	// fail the matching methods, e.g. LockErr set to release.ErrReleaseLocked.
+	// A failed install records nothing, like the operator uninstalls it, a
+	// failed upgrade records a failed revision and keeps the deployed one.
+	SyncErr      error
+	InstallErr   error
+	UpgradeErr   error
+	UninstallErr error
+	RollbackErr  error
+	LockErr      error
+
+	// Drift, Conflicts, Statuses, Progress, ReleaseDiff, Rendered and Missing
+	// are returned by DetectDrift and RemediateDrift, FieldConflicts,
+	// ResourceStatus, RolloutProgress, Diff, Render and MissingPermissions.
+	Drift       []appv1.HelmAppResource
+	Conflicts   []appv1.HelmAppFieldConflict
+	Statuses    []appv1.HelmAppResourceStatus
+	Progress    string
+	ReleaseDiff *release.ReleaseDiff
+	Rendered    []*unstructured.Unstructured
+	Missing     []string
+
+	mu              sync.Mutex
+	calls           []string
+	releases        []*rpb.Release
+	installed       bool
+	upgradeRequired bool
+}
+
+// NewManager returns a fake manager of the release name in namespace,
+// rendered from chrt. It is not installed.
+func NewManager(name, namespace string, chrt *cpb.Chart) *Manager {
+	return &Manager{Name: name, Namespace: namespace, CandidateChart: chrt}
+}
+
+// ManagerFactory is a release.ManagerFactory returning Manager, or failing
+// with Err.
+type ManagerFactory struct {
+	Manager *Manager
+	Err     error
+}
+
+// NewManager returns f.Manager, the custom resource is ignored.
+func (f ManagerFactory) NewManager(*unstructured.Unstructured, map[string]string) (release.Manager, error) {
+	if f.Err != nil {
+		return nil, f.Err
+	}
+
+	return f.Manager, nil
+}
+
+// Calls returns the names of the methods called with a context, in order.
+func (m *Manager) Calls() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append([]string(nil), m.calls...)
+}
+
+// SetReleases replaces the recorded revisions of the release, e.g. with a
+// deployed release to upgrade.
+func (m *Manager) SetReleases(releases ...*rpb.Release) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.releases = releases
+}
+
+func (m *Manager) ReleaseName() string {
+	return m.Name
+}
+
+func (m *Manager) ReleaseDigest() string {
+	return m.Digest
+}
+
+func (m *Manager) ValuesDigest() string {
+	return m.ValuesHash
+}
+
+func (m *Manager) Chart() *cpb.Chart {
+	return m.CandidateChart
+}
+
+func (m *Manager) IsInstalled() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.installed
+}
+
+func (m *Manager) IsUpgradeRequired() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.upgradeRequired
+}
+
+// Sync looks up the deployed revision, the upgrade is required when its
+// manifest is not Manifest.
+func (m *Manager) Sync(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.record("Sync")
+
+	if m.SyncErr != nil {
+		return m.SyncErr
+	}
+
+	deployed := m.deployed()
+	m.installed = deployed != nil
+	m.upgradeRequired = deployed != nil && deployed.Manifest != m.Manifest
+
+	return nil
+}
+
+func (m *Manager) InstallRelease(ctx context.Context, opts ...release.InstallOption) (*rpb.Release, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.record("InstallRelease")
+
+	install := &action.Install{}
+	for _, o := range opts {
+		if err := o(install); err != nil {
+			return nil, fmt.Errorf("failed to apply install option: %w", err)
+		}
+	}
+
+	if m.InstallErr != nil {
+		return nil, m.InstallErr
+	}
+
+	installed := m.newRevision(rpb.StatusDeployed, m.CandidateChart, m.Values, m.Manifest)
+	m.releases = append(m.releases, installed)
+	m.installed = true
+	m.upgradeRequired = false
+
+	return installed, nil
+}
+
+func (m *Manager) UpgradeRelease(ctx context.Context, opts ...release.UpgradeOption) (*rpb.Release, *rpb.Release, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.record("UpgradeRelease")
+
+	upgrade := &action.Upgrade{}
+	for _, o := range opts {
+		if err := o(upgrade); err != nil {
+			return nil, nil, fmt.Errorf("failed to apply upgrade option: %w", err)
+		}
+	}
+
+	previous := m.deployed()
+	if previous == nil {
+		return nil, nil, &release.ErrUpgradeFailed{Err: fmt.Errorf("%q has no deployed releases", m.Name)}
+	}
+
+	if m.UpgradeErr != nil {
+		m.releases = append(m.releases, m.newRevision(rpb.StatusFailed, m.CandidateChart, m.Values, m.Manifest))
+		return nil, nil, m.UpgradeErr
+	}
+
+	upgraded := m.supersede(previous, m.newRevision(rpb.StatusDeployed, m.CandidateChart, m.Values, m.Manifest))
+	m.upgradeRequired = false
+
+	return previous, upgraded, nil
+}
+
+func (m *Manager) UninstallRelease(ctx context.Context, opts ...release.UninstallOption) (*rpb.Release, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.record("UninstallRelease")
+
+	uninstall := &action.Uninstall{}
+	for _, o := range opts {
+		if err := o(uninstall); err != nil {
+			return nil, fmt.Errorf("failed to apply uninstall option: %w", err)
+		}
+	}
+
+	if m.UninstallErr != nil {
+		return nil, m.UninstallErr
+	}
+
+	uninstalled, err := m.remove()
+	if uninstalled != nil {
+		uninstalled.Info.Status = rpb.StatusUninstalled
+	}
+
+	return uninstalled, err
+}
+
+func (m *Manager) OrphanRelease(ctx context.Context) (*rpb.Release, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.record("OrphanRelease")
+
+	return m.remove()
+}
+
+func (m *Manager) GetDeployedRelease() (*rpb.Release, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if deployed := m.deployed(); deployed != nil {
+		return deployed, nil
+	}
+
+	return nil, release.ErrReleaseNotFound
+}
+
+func (m *Manager) History(ctx context.Context) ([]*rpb.Release, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.record("History")
+
+	return append([]*rpb.Release(nil), m.releases...), nil
+}
+
+// RollbackRelease records a deployed revision with the chart, values and
+// manifest of revision, or of the revision before the deployed one if it is 0.
+func (m *Manager) RollbackRelease(ctx context.Context, revision int) (*rpb.Release, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.record("RollbackRelease")
+
+	if m.RollbackErr != nil {
+		return nil, m.RollbackErr
+	}
+
+	previous := m.deployed()
+	if revision == 0 && previous != nil {
+		revision = previous.Version - 1
+	}
+
+	var target *rpb.Release
+
+	for _, rel := range m.releases {
+		if rel.Version == revision {
+			target = rel
+		}
+	}
+
+	if target == nil {
+		return nil, fmt.Errorf("failed to roll back release: %w", release.ErrReleaseNotFound)
+	}
+
+	return m.supersede(previous, m.newRevision(rpb.StatusDeployed, target.Chart, target.Config, target.Manifest)), nil
+}
+
+func (m *Manager) DetectDrift(ctx context.Context) ([]appv1.HelmAppResource, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.record("DetectDrift")
+
+	return m.Drift, nil
+}
+
+func (m *Manager) RemediateDrift(ctx context.Context) ([]appv1.HelmAppResource, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.record("RemediateDrift")
+
+	return m.Drift, nil
+}
+
+func (m *Manager) FieldConflicts(ctx context.Context) ([]appv1.HelmAppFieldConflict, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.record("FieldConflicts")
+
+	return m.Conflicts, nil
+}
+
+func (m *Manager) ResourceStatus(ctx context.Context, manifest string) ([]appv1.HelmAppResourceStatus, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.record("ResourceStatus")
+
+	return m.Statuses, nil
+}
+
+func (m *Manager) RolloutProgress() string {
+	return m.Progress
+}
+
+// Diff returns ReleaseDiff, or an empty diff if it is nil.
+func (m *Manager) Diff(ctx context.Context) (*release.ReleaseDiff, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.record("Diff")
+
+	if m.ReleaseDiff == nil {
+		return &release.ReleaseDiff{}, nil
+	}
+
+	return m.ReleaseDiff, nil
+}
+
+func (m *Manager) Render(ctx context.Context, opts release.RenderOptions) ([]*unstructured.Unstructured, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.record("Render")
+
+	return m.Rendered, nil
+}
+
+// DebugDump returns the chart metadata, the values and Manifest.
+func (m *Manager) DebugDump(ctx context.Context) (*release.DebugDump, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.record("DebugDump")
+
+	dump := &release.DebugDump{
+		ReleaseName: m.Name,
+		Namespace:   m.Namespace,
+		Values:      m.Values,
+		Manifest:    m.Manifest,
+	}
+
+	if m.CandidateChart != nil {
+		dump.Chart = m.CandidateChart.Metadata
+	}
+
+	return dump, nil
+}
+
+func (m *Manager) MissingPermissions(ctx context.Context) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.record("MissingPermissions")
+
+	return m.Missing, nil
+}
+
+func (m *Manager) Lock(ctx context.Context) (context.Context, func(), error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.record("Lock")
+
+	if m.LockErr != nil {
+		return nil, nil, m.LockErr
+	}
+
+	return ctx, func() {}, nil
+}
+
+func (m *Manager) record(call string) {
+	m.calls = append(m.calls, call)
+}
+
+// deployed returns the last deployed revision, nil if there is none.
+func (m *Manager) deployed() *rpb.Release {
+	for i := len(m.releases) - 1; i >= 0; i-- {
+		if m.releases[i].Info != nil && m.releases[i].Info.Status == rpb.StatusDeployed {
+			return m.releases[i]
+		}
+	}
+
+	return nil
+}
+
+func (m *Manager) newRevision(status rpb.Status, chrt *cpb.Chart, values map[string]interface{},
+	manifest string) *rpb.Release {
+	version := 1
+	if len(m.releases) != 0 {
+		version = m.releases[len(m.releases)-1].Version + 1
+	}
+
+	now := helmtime.Time{Time: time.Now()}
+
+	return &rpb.Release{
+		Name:      m.Name,
+		Namespace: m.Namespace,
+		Version:   version,
+		Chart:     chrt,
+		Config:    values,
+		Manifest:  manifest,
+		Info: &rpb.Info{
+			FirstDeployed: now,
+			LastDeployed:  now,
+			Status:        status,
+		},
+	}
+}
+
+// supersede records deployed, superseding previous if it is not nil.
+func (m *Manager) supersede(previous, deployed *rpb.Release) *rpb.Release {
+	if previous != nil {
+		previous.Info.Status = rpb.StatusSuperseded
+	}
+
+	m.releases = append(m.releases, deployed)
+	m.installed = true
+
+	return deployed
+}
+
+// remove deletes the revisions of the release and returns the deployed one,
+// nil if none is deployed, or ErrReleaseNotFound if there are none.
+func (m *Manager) remove() (*rpb.Release, error) {
+	if len(m.releases) == 0 {
+		return nil, release.ErrReleaseNotFound
+	}
+
+	deployed := m.deployed()
+	m.releases = nil
+	m.installed = false
+	m.upgradeRequired = false
+
+	return deployed, nil
+}
@@ -0,0 +1,107 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fake
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	cpb "helm.sh/helm/v3/pkg/chart"
+	rpb "helm.sh/helm/v3/pkg/release"
+
+	"github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/release"
+)
+
+func TestManagerLifecycle(t *testing.T) {
+	ctx := context.TODO()
+	m := NewManager("nginx", "default", &cpb.Chart{Metadata: &cpb.Metadata{Name: "nginx", Version: "1.0.0"}})
+	m.Manifest = "kind: ConfigMap"
+
+	assert.NoError(t, m.Sync(ctx))
+	assert.False(t, m.IsInstalled())
+
+	installed, err := m.InstallRelease(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, installed.Version)
+	assert.Equal(t, rpb.StatusDeployed, installed.Info.Status)
+
+	m.Manifest = "kind: Secret"
+	assert.NoError(t, m.Sync(ctx))
+	assert.True(t, m.IsInstalled())
+	assert.True(t, m.IsUpgradeRequired())
+
+	previous, upgraded, err := m.UpgradeRelease(ctx)
+	assert.NoError(t, err)
+	assert.Same(t, installed, previous)
+	assert.Equal(t, rpb.StatusSuperseded, previous.Info.Status)
+	assert.Equal(t, 2, upgraded.Version)
+
+	rolledBack, err := m.RollbackRelease(ctx, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, rolledBack.Version)
+	assert.Equal(t, "kind: ConfigMap", rolledBack.Manifest)
+
+	uninstalled, err := m.UninstallRelease(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, rpb.StatusUninstalled, uninstalled.Info.Status)
+
+	_, err = m.GetDeployedRelease()
+	assert.True(t, errors.Is(err, release.ErrReleaseNotFound))
+
+	assert.Equal(t, []string{"Sync", "InstallRelease", "Sync", "UpgradeRelease", "RollbackRelease", "UninstallRelease"},
+		m.Calls())
+}
+
+func TestManagerFailures(t *testing.T) {
+	ctx := context.TODO()
+	m := NewManager("nginx", "default", nil)
+	m.InstallErr = errors.New("install failed")
+
+	_, err := m.InstallRelease(ctx)
+	assert.EqualError(t, err, "install failed")
+
+	history, err := m.History(ctx)
+	assert.NoError(t, err)
+	assert.Empty(t, history)
+
+	m.InstallErr = nil
+	m.UpgradeErr = &release.ErrUpgradeFailed{Err: errors.New("timed out"), RolledBack: true}
+
+	_, err = m.InstallRelease(ctx)
+	assert.NoError(t, err)
+
+	_, _, err = m.UpgradeRelease(ctx)
+	assert.Equal(t, m.UpgradeErr, err)
+
+	history, err = m.History(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, history, 2)
+	assert.Equal(t, rpb.StatusFailed, history[1].Info.Status)
+
+	deployed, err := m.GetDeployedRelease()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, deployed.Version)
+
+	m.LockErr = release.ErrReleaseLocked
+	_, _, err = m.Lock(ctx)
+	assert.True(t, errors.Is(err, release.ErrReleaseLocked))
+
+	f := ManagerFactory{Manager: m}
+	got, err := f.NewManager(nil, nil)
+	assert.NoError(t, err)
+	assert.Same(t, m, got)
+}
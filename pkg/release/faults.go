@@ -0,0 +1,51 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release
+
+import (
+	"helm.sh/helm/v3/pkg/kube"
+
+	"github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/faults"
+)
+
+var _ kube.Interface = &faultClient{}
+
+// faultClient injects the faults.ReleaseApply faults of the HelmRelease key
+// before the resources are created or updated. It is only used by the builds
+// with the faults tag.
+type faultClient struct {
+	kube.Interface
+	key string
+}
+
+func newFaultClient(base kube.Interface, key string) kube.Interface {
+	return &faultClient{Interface: base, key: key}
+}
+
+func (c *faultClient) Create(resources kube.ResourceList) (*kube.Result, error) {
+	if err := faults.Inject(faults.ReleaseApply, c.key); err != nil {
+		return nil, err
+	}
+
+	return c.Interface.Create(resources)
+}
+
+func (c *faultClient) Update(original, target kube.ResourceList, force bool) (*kube.Result, error) {
+	if err := faults.Inject(faults.ReleaseApply, c.key); err != nil {
+		return nil, err
+	}
+
+	return c.Interface.Update(original, target, force)
+}
@@ -0,0 +1,311 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/cli-runtime/pkg/resource"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+// ResourceStatus returns the readiness of each resource of manifest,
+// computed from its live state like kstatus does: a resource is Current once
+// its controller observed its latest generation and finished rolling it out.
+func (m manager) ResourceStatus(ctx context.Context, manifest string) ([]appv1.HelmAppResourceStatus, error) {
+	expected, err := m.kubeClient.Build(bytes.NewBufferString(manifest), false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build release resources: %w", err)
+	}
+
+	var statuses []appv1.HelmAppResourceStatus
+
+	err = expected.Visit(func(info *resource.Info, err error) error {
+		if err != nil {
+			return err
+		}
+
+		status, err := resourceStatus(info)
+		if err != nil {
+			return err
+		}
+
+		statuses = append(statuses, status)
+
+		return nil
+	})
+
+	return statuses, err
+}
+
+// resourceStatus returns the status of the live state of info.
+func resourceStatus(info *resource.Info) (appv1.HelmAppResourceStatus, error) {
+	status := appv1.HelmAppResourceStatus{Resource: resourceRefs([]*resource.Info{info})[0]}
+
+	live, err := resource.NewHelper(info.Client, info.Mapping).Get(info.Namespace, info.Name)
+
+	switch {
+	case apierrors.IsNotFound(err):
+		status.Status = appv1.ResourceNotFound
+	case err != nil:
+		status.Status, status.Message = appv1.ResourceUnknown, err.Error()
+	default:
+		content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(live)
+		if err != nil {
+			return status, err
+		}
+
+		status.Status, status.Message = computeStatus(&unstructured.Unstructured{Object: content})
+	}
+
+	return status, nil
+}
+
+// computeStatus returns the status of obj and a message explaining it.
+func computeStatus(obj *unstructured.Unstructured) (appv1.ResourceStatusEnum, string) {
+	if obj.GetDeletionTimestamp() != nil {
+		return appv1.ResourceInProgress, "Resource is being deleted"
+	}
+
+	observed, found, _ := unstructured.NestedInt64(obj.Object, "status", "observedGeneration")
+	if found && observed < obj.GetGeneration() {
+		return appv1.ResourceInProgress, fmt.Sprintf("Generation %d not observed yet", obj.GetGeneration())
+	}
+
+	switch obj.GroupVersionKind().GroupKind().String() {
+	case "Deployment.apps":
+		return deploymentStatus(obj)
+	case "StatefulSet.apps":
+		return statefulSetStatus(obj)
+	case "DaemonSet.apps":
+		return daemonSetStatus(obj)
+	case "ReplicaSet.apps":
+		return replicaSetStatus(obj)
+	case "Pod":
+		return podStatus(obj)
+	case "Job.batch":
+		return jobStatus(obj)
+	case "PersistentVolumeClaim":
+		return pvcStatus(obj)
+	case "Service":
+		return serviceStatus(obj)
+	case "CustomResourceDefinition.apiextensions.k8s.io":
+		return crdStatus(obj)
+	}
+
+	return genericStatus(obj)
+}
+
+// genericStatus follows the conditions of the resources with no dedicated
+// rules, which are Current unless they report otherwise.
+func genericStatus(obj *unstructured.Unstructured) (appv1.ResourceStatusEnum, string) {
+	if c := condition(obj, "Stalled"); c != nil && c["status"] == "True" {
+		return appv1.ResourceFailed, conditionMessage(c)
+	}
+
+	if c := condition(obj, "Reconciling"); c != nil && c["status"] == "True" {
+		return appv1.ResourceInProgress, conditionMessage(c)
+	}
+
+	if c := condition(obj, "Ready"); c != nil && c["status"] == "False" {
+		return appv1.ResourceInProgress, conditionMessage(c)
+	}
+
+	return appv1.ResourceCurrent, ""
+}
+
+func deploymentStatus(obj *unstructured.Unstructured) (appv1.ResourceStatusEnum, string) {
+	replicas := specReplicas(obj)
+	updated := statusInt(obj, "updatedReplicas")
+	available := statusInt(obj, "availableReplicas")
+	total := statusInt(obj, "replicas")
+
+	if c := condition(obj, "Progressing"); c != nil && c["reason"] == "ProgressDeadlineExceeded" {
+		return appv1.ResourceFailed, conditionMessage(c)
+	}
+
+	switch {
+	case updated < replicas:
+		return appv1.ResourceInProgress, fmt.Sprintf("Updated: %d/%d", updated, replicas)
+	case total > updated:
+		return appv1.ResourceInProgress, fmt.Sprintf("Pending termination: %d", total-updated)
+	case available < updated:
+		return appv1.ResourceInProgress, fmt.Sprintf("Available: %d/%d", available, updated)
+	}
+
+	return appv1.ResourceCurrent, fmt.Sprintf("Deployment is available. Replicas: %d", total)
+}
+
+func statefulSetStatus(obj *unstructured.Unstructured) (appv1.ResourceStatusEnum, string) {
+	replicas := specReplicas(obj)
+	ready := statusInt(obj, "readyReplicas")
+
+	if ready < replicas {
+		return appv1.ResourceInProgress, fmt.Sprintf("Ready: %d/%d", ready, replicas)
+	}
+
+	strategy, _, _ := unstructured.NestedString(obj.Object, "spec", "updateStrategy", "type")
+	if strategy == "OnDelete" {
+		return appv1.ResourceCurrent, fmt.Sprintf("StatefulSet is ready. Replicas: %d", ready)
+	}
+
+	current, _, _ := unstructured.NestedString(obj.Object, "status", "currentRevision")
+	update, _, _ := unstructured.NestedString(obj.Object, "status", "updateRevision")
+
+	if current != update {
+		updated := statusInt(obj, "updatedReplicas")
+		return appv1.ResourceInProgress, fmt.Sprintf("Updated: %d/%d", updated, replicas)
+	}
+
+	return appv1.ResourceCurrent, fmt.Sprintf("StatefulSet is ready. Replicas: %d", ready)
+}
+
+func daemonSetStatus(obj *unstructured.Unstructured) (appv1.ResourceStatusEnum, string) {
+	desired := statusInt(obj, "desiredNumberScheduled")
+	updated := statusInt(obj, "updatedNumberScheduled")
+	available := statusInt(obj, "numberAvailable")
+
+	switch {
+	case updated < desired:
+		return appv1.ResourceInProgress, fmt.Sprintf("Updated: %d/%d", updated, desired)
+	case available < desired:
+		return appv1.ResourceInProgress, fmt.Sprintf("Available: %d/%d", available, desired)
+	}
+
+	return appv1.ResourceCurrent, fmt.Sprintf("DaemonSet is available. Scheduled: %d", desired)
+}
+
+func replicaSetStatus(obj *unstructured.Unstructured) (appv1.ResourceStatusEnum, string) {
+	replicas := specReplicas(obj)
+	available := statusInt(obj, "availableReplicas")
+
+	if c := condition(obj, "ReplicaFailure"); c != nil && c["status"] == "True" {
+		return appv1.ResourceInProgress, conditionMessage(c)
+	}
+
+	if available < replicas {
+		return appv1.ResourceInProgress, fmt.Sprintf("Available: %d/%d", available, replicas)
+	}
+
+	return appv1.ResourceCurrent, fmt.Sprintf("ReplicaSet is available. Replicas: %d", available)
+}
+
+func podStatus(obj *unstructured.Unstructured) (appv1.ResourceStatusEnum, string) {
+	phase, _, _ := unstructured.NestedString(obj.Object, "status", "phase")
+
+	switch phase {
+	case "Succeeded":
+		return appv1.ResourceCurrent, "Pod has completed successfully"
+	case "Failed":
+		return appv1.ResourceFailed, "Pod has failed"
+	case "Running":
+		if c := condition(obj, "Ready"); c != nil && c["status"] == "True" {
+			return appv1.ResourceCurrent, "Pod is ready"
+		}
+	}
+
+	return appv1.ResourceInProgress, fmt.Sprintf("Pod phase is %s", phase)
+}
+
+func jobStatus(obj *unstructured.Unstructured) (appv1.ResourceStatusEnum, string) {
+	if c := condition(obj, "Failed"); c != nil && c["status"] == "True" {
+		return appv1.ResourceFailed, conditionMessage(c)
+	}
+
+	if c := condition(obj, "Complete"); c != nil && c["status"] == "True" {
+		return appv1.ResourceCurrent, "Job completed"
+	}
+
+	return appv1.ResourceInProgress, fmt.Sprintf("Job in progress. Succeeded: %d", statusInt(obj, "succeeded"))
+}
+
+func pvcStatus(obj *unstructured.Unstructured) (appv1.ResourceStatusEnum, string) {
+	phase, _, _ := unstructured.NestedString(obj.Object, "status", "phase")
+	if phase != "Bound" {
+		return appv1.ResourceInProgress, fmt.Sprintf("PersistentVolumeClaim phase is %s", phase)
+	}
+
+	return appv1.ResourceCurrent, "PersistentVolumeClaim is bound"
+}
+
+func serviceStatus(obj *unstructured.Unstructured) (appv1.ResourceStatusEnum, string) {
+	serviceType, _, _ := unstructured.NestedString(obj.Object, "spec", "type")
+	if serviceType != "LoadBalancer" {
+		return appv1.ResourceCurrent, ""
+	}
+
+	ingress, _, _ := unstructured.NestedSlice(obj.Object, "status", "loadBalancer", "ingress")
+	if len(ingress) == 0 {
+		return appv1.ResourceInProgress, "LoadBalancer ingress is not assigned yet"
+	}
+
+	return appv1.ResourceCurrent, "LoadBalancer ingress is assigned"
+}
+
+func crdStatus(obj *unstructured.Unstructured) (appv1.ResourceStatusEnum, string) {
+	if c := condition(obj, "NamesAccepted"); c != nil && c["status"] == "False" {
+		return appv1.ResourceFailed, conditionMessage(c)
+	}
+
+	if c := condition(obj, "Established"); c != nil && c["status"] == "True" {
+		return appv1.ResourceCurrent, "CustomResourceDefinition is established"
+	}
+
+	return appv1.ResourceInProgress, "CustomResourceDefinition is not established yet"
+}
+
+// condition returns the status condition of obj of type t, or nil.
+func condition(obj *unstructured.Unstructured, t string) map[string]interface{} {
+	conditions, _, _ := unstructured.NestedSlice(obj.Object, "status", "conditions")
+
+	for _, c := range conditions {
+		if c, ok := c.(map[string]interface{}); ok && c["type"] == t {
+			return c
+		}
+	}
+
+	return nil
+}
+
+func conditionMessage(c map[string]interface{}) string {
+	if message, ok := c["message"].(string); ok && message != "" {
+		return message
+	}
+
+	reason, _ := c["reason"].(string)
+
+	return reason
+}
+
+// specReplicas returns the desired replicas of obj, 1 if unset.
+func specReplicas(obj *unstructured.Unstructured) int64 {
+	replicas, found, _ := unstructured.NestedInt64(obj.Object, "spec", "replicas")
+	if !found {
+		return 1
+	}
+
+	return replicas
+}
+
+func statusInt(obj *unstructured.Unstructured, field string) int64 {
+	v, _, _ := unstructured.NestedInt64(obj.Object, "status", field)
+	return v
+}
@@ -0,0 +1,69 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+func newStatusTestDeployment(generation, observed, replicas, updated, available int64) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata":   map[string]interface{}{"name": "web", "generation": generation},
+		"spec":       map[string]interface{}{"replicas": replicas},
+		"status": map[string]interface{}{
+			"observedGeneration": observed,
+			"replicas":           updated,
+			"updatedReplicas":    updated,
+			"availableReplicas":  available,
+		},
+	}}
+}
+
+func TestComputeStatus(t *testing.T) {
+	status, _ := computeStatus(newStatusTestDeployment(2, 2, 3, 3, 3))
+	assert.Equal(t, appv1.ResourceCurrent, status)
+
+	status, message := computeStatus(newStatusTestDeployment(3, 2, 3, 3, 3))
+	assert.Equal(t, appv1.ResourceInProgress, status)
+	assert.Equal(t, "Generation 3 not observed yet", message)
+
+	status, message = computeStatus(newStatusTestDeployment(2, 2, 3, 3, 1))
+	assert.Equal(t, appv1.ResourceInProgress, status)
+	assert.Equal(t, "Available: 1/3", message)
+
+	job := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "batch/v1",
+		"kind":       "Job",
+		"status": map[string]interface{}{
+			"conditions": []interface{}{
+				map[string]interface{}{"type": "Failed", "status": "True", "reason": "BackoffLimitExceeded"},
+			},
+		},
+	}}
+	status, message = computeStatus(job)
+	assert.Equal(t, appv1.ResourceFailed, status)
+	assert.Equal(t, "BackoffLimitExceeded", message)
+
+	configMap := &unstructured.Unstructured{Object: map[string]interface{}{"apiVersion": "v1", "kind": "ConfigMap"}}
+	status, _ = computeStatus(configMap)
+	assert.Equal(t, appv1.ResourceCurrent, status)
+}
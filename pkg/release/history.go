@@ -0,0 +1,136 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"helm.sh/helm/v3/pkg/action"
+	rpb "helm.sh/helm/v3/pkg/release"
+)
+
+// PinnedRevisionsAnnotation lists the comma separated release revisions of a
+// HelmRelease that are never deleted by the history compaction.
+const PinnedRevisionsAnnotation = "apps.open-cluster-management.io/pinned-revisions"
+
+// parsePinnedRevisions parses the value of the PinnedRevisionsAnnotation.
+func parsePinnedRevisions(value string) (map[int]bool, error) {
+	pinned := make(map[int]bool)
+
+	for _, r := range strings.Split(value, ",") {
+		r = strings.TrimSpace(r)
+		if r == "" {
+			continue
+		}
+
+		revision, err := strconv.Atoi(r)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s annotation %q: %w", PinnedRevisionsAnnotation, value, err)
+		}
+
+		pinned[revision] = true
+	}
+
+	return pinned, nil
+}
+
+// compactedRevisions returns the revisions of history deleted by the history
+// compaction. The first deployed revision, the keepLast most recent
+// revisions, the deployed revision and the pinned revisions are kept.
+func compactedRevisions(history []*rpb.Release, keepLast int, pinned map[int]bool) []int {
+	releases := make([]*rpb.Release, len(history))
+	copy(releases, history)
+	sortByRevision(releases)
+
+	firstDeployed := 0
+
+	for _, rel := range releases {
+		if rel.Info != nil && (rel.Info.Status == rpb.StatusDeployed || rel.Info.Status == rpb.StatusSuperseded) {
+			firstDeployed = rel.Version
+			break
+		}
+	}
+
+	var compacted []int
+
+	for i, rel := range releases {
+		keep := i >= len(releases)-keepLast || rel.Version == firstDeployed || pinned[rel.Version] ||
+			(rel.Info != nil && rel.Info.Status == rpb.StatusDeployed)
+
+		if !keep {
+			compacted = append(compacted, rel.Version)
+		}
+	}
+
+	return compacted
+}
+
+// History returns the revisions of the release recorded in the helm storage,
+// with their chart, the oldest first. It is empty if the release is not
+// installed.
+func (m manager) History(ctx context.Context) ([]*rpb.Release, error) {
+	history, err := m.storageBackend.History(m.releaseName)
+	if notFoundErr(err) {
+		return nil, nil
+	}
+
+	if err == nil {
+		history, err = fullReleases(m.storageBackend, history)
+	}
+
+	if err != nil {
+		return nil, storageFailed(fmt.Errorf("failed to get release history: %w", err))
+	}
+
+	sortByRevision(history)
+
+	return history, nil
+}
+
+// RollbackRelease rolls the release back to revision, or to the previous
+// revision if it is 0, and returns the deployed release. The next reconcile
+// upgrades it again to the HelmRelease spec unless the HelmRelease is
+// suspended.
+func (m manager) RollbackRelease(ctx context.Context, revision int) (*rpb.Release, error) {
+	if err := lockHeld(ctx); err != nil {
+		return nil, err
+	}
+
+	rollback := action.NewRollback(m.actionConfig)
+	rollback.Version = revision
+	rollback.Wait = m.wait
+	rollback.Timeout = m.timeout
+
+	if err := rollback.Run(m.releaseName); err != nil {
+		return nil, m.redactError(withHooksFailed(fmt.Errorf("failed to roll back release: %w", err)))
+	}
+
+	deployed, err := m.storageBackend.Deployed(m.releaseName)
+	if err != nil {
+		return nil, storageFailed(fmt.Errorf("failed to get rolled back release: %w", err))
+	}
+
+	return deployed, nil
+}
+
+func sortByRevision(releases []*rpb.Release) {
+	sort.Slice(releases, func(i, j int) bool {
+		return releases[i].Version < releases[j].Version
+	})
+}
@@ -0,0 +1,146 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release
+
+import (
+	"context"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"helm.sh/helm/v3/pkg/action"
+	cpb "helm.sh/helm/v3/pkg/chart"
+	kubefake "helm.sh/helm/v3/pkg/kube/fake"
+	rpb "helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/storage"
+	"helm.sh/helm/v3/pkg/storage/driver"
+)
+
+func newTestRelease(version int, status rpb.Status) *rpb.Release {
+	return &rpb.Release{Name: "test", Version: version, Info: &rpb.Info{Status: status}}
+}
+
+func TestCompactedRevisions(t *testing.T) {
+	history := []*rpb.Release{
+		newTestRelease(6, rpb.StatusSuperseded),
+		newTestRelease(1, rpb.StatusFailed),
+		newTestRelease(2, rpb.StatusSuperseded),
+		newTestRelease(3, rpb.StatusSuperseded),
+		newTestRelease(4, rpb.StatusSuperseded),
+		newTestRelease(5, rpb.StatusSuperseded),
+		newTestRelease(7, rpb.StatusDeployed),
+		newTestRelease(8, rpb.StatusFailed),
+	}
+
+	assert.Equal(t, []int{1, 3, 5, 6}, compactedRevisions(history, 1, map[int]bool{4: true}))
+	assert.Equal(t, []int{1, 3, 4, 5}, compactedRevisions(history, 3, nil))
+	assert.Empty(t, compactedRevisions(history, 10, nil))
+
+	pinned, err := parsePinnedRevisions(" 3, 5,")
+	assert.NoError(t, err)
+	assert.Equal(t, map[int]bool{3: true, 5: true}, pinned)
+
+	_, err = parsePinnedRevisions("3,latest")
+	assert.Error(t, err)
+}
+
+func TestOrphanRelease(t *testing.T) {
+	storageBackend := storage.Init(driver.NewMemory())
+	m := manager{storageBackend: storageBackend, releaseName: "test"}
+
+	_, err := m.OrphanRelease(context.TODO())
+	assert.Equal(t, ErrReleaseNotFound, err)
+
+	for _, rel := range []*rpb.Release{
+		newTestRelease(1, rpb.StatusSuperseded),
+		newTestRelease(2, rpb.StatusDeployed),
+		newTestRelease(3, rpb.StatusFailed),
+	} {
+		require.NoError(t, storageBackend.Create(rel))
+	}
+
+	// nothing is deleted once the lock of the release is lost
+	lost, cancel := context.WithCancel(context.TODO())
+	cancel()
+
+	_, err = m.OrphanRelease(lost)
+	assert.Equal(t, ErrReleaseLockLost, err)
+
+	// every record is deleted, the deployed revision is returned
+	deployed, err := m.OrphanRelease(context.TODO())
+	require.NoError(t, err)
+	assert.Equal(t, 2, deployed.Version)
+
+	_, err = m.OrphanRelease(context.TODO())
+	assert.Equal(t, ErrReleaseNotFound, err)
+}
+
+func TestHistory(t *testing.T) {
+	m := manager{storageBackend: storage.Init(driver.NewMemory()), releaseName: "test"}
+
+	history, err := m.History(context.TODO())
+	assert.NoError(t, err)
+	assert.Empty(t, history)
+
+	for _, rel := range []*rpb.Release{
+		newTestRelease(2, rpb.StatusDeployed),
+		newTestRelease(1, rpb.StatusSuperseded),
+		newTestRelease(3, rpb.StatusFailed),
+	} {
+		assert.NoError(t, m.storageBackend.Create(rel))
+	}
+
+	history, err = m.History(context.TODO())
+	assert.NoError(t, err)
+
+	var revisions []int
+	for _, rel := range history {
+		revisions = append(revisions, rel.Version)
+	}
+
+	assert.Equal(t, []int{1, 2, 3}, revisions)
+}
+
+func TestRollbackRelease(t *testing.T) {
+	storageBackend := storage.Init(driver.NewMemory())
+	m := manager{
+		storageBackend: storageBackend,
+		releaseName:    "test",
+		actionConfig: &action.Configuration{
+			Releases:   storageBackend,
+			KubeClient: &kubefake.PrintingKubeClient{Out: ioutil.Discard},
+			Log:        func(string, ...interface{}) {},
+		},
+	}
+
+	for _, rel := range []*rpb.Release{
+		newTestRelease(1, rpb.StatusSuperseded),
+		newTestRelease(2, rpb.StatusDeployed),
+	} {
+		rel.Chart = &cpb.Chart{Metadata: &cpb.Metadata{Name: "webapp", Version: "1.0.0"}}
+		require.NoError(t, storageBackend.Create(rel))
+	}
+
+	// the rollback is a new revision of the release
+	deployed, err := m.RollbackRelease(context.TODO(), 1)
+	require.NoError(t, err)
+	assert.Equal(t, 3, deployed.Version)
+
+	history, err := m.History(context.TODO())
+	require.NoError(t, err)
+	assert.Len(t, history, 3)
+	assert.Equal(t, rpb.StatusSuperseded, history[1].Info.Status)
+}
@@ -0,0 +1,219 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+// manifestsDiffer reports whether the deployed and candidate manifests differ
+// once the fields matched by rules are removed from both of them.
+func manifestsDiffer(deployed, candidate string, rules []appv1.ResourceIgnoreDifferences) (bool, error) {
+	if deployed == candidate {
+		return false, nil
+	}
+
+	if len(rules) == 0 {
+		return true, nil
+	}
+
+	deployedResources, err := manifestResources(deployed)
+	if err != nil {
+		return false, fmt.Errorf("failed to parse deployed manifest: %w", err)
+	}
+
+	candidateResources, err := manifestResources(candidate)
+	if err != nil {
+		return false, fmt.Errorf("failed to parse candidate manifest: %w", err)
+	}
+
+	if len(deployedResources) != len(candidateResources) {
+		return true, nil
+	}
+
+	for key, d := range deployedResources {
+		c, ok := candidateResources[key]
+		if !ok {
+			return true, nil
+		}
+
+		if err := ignoreDifferences(d, rules); err != nil {
+			return false, err
+		}
+
+		if err := ignoreDifferences(c, rules); err != nil {
+			return false, err
+		}
+
+		if !reflect.DeepEqual(d.Object, c.Object) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// ignoreDifferences removes the fields of u matched by rules.
+func ignoreDifferences(u *unstructured.Unstructured, rules []appv1.ResourceIgnoreDifferences) error {
+	for _, rule := range rules {
+		if !ignoreRuleMatches(rule, u) {
+			continue
+		}
+
+		for _, pointer := range rule.JSONPointers {
+			path, err := parseJSONPointer(pointer)
+			if err != nil {
+				return err
+			}
+
+			u.Object = removePath(u.Object, path).(map[string]interface{})
+		}
+
+		for _, expression := range rule.JQPathExpressions {
+			path, err := parseJQPath(expression)
+			if err != nil {
+				return err
+			}
+
+			u.Object = removePath(u.Object, path).(map[string]interface{})
+		}
+	}
+
+	return nil
+}
+
+func ignoreRuleMatches(rule appv1.ResourceIgnoreDifferences, u *unstructured.Unstructured) bool {
+	return objectMatches(u, rule.APIVersion, rule.Kind, rule.Name)
+}
+
+// objectMatches returns true if u is of kind and, if they are set, of
+// apiVersion and named name.
+func objectMatches(u *unstructured.Unstructured, apiVersion, kind, name string) bool {
+	if !strings.EqualFold(kind, u.GetKind()) {
+		return false
+	}
+
+	if apiVersion != "" && apiVersion != u.GetAPIVersion() {
+		return false
+	}
+
+	return name == "" || name == u.GetName()
+}
+
+// parseJSONPointer splits a RFC 6901 JSON pointer into its reference tokens.
+func parseJSONPointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("invalid JSON pointer %q: must start with /", pointer)
+	}
+
+	tokens := strings.Split(pointer[1:], "/")
+	for i, t := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(t, "~1", "/"), "~0", "~")
+	}
+
+	return tokens, nil
+}
+
+// parseJQPath splits a jq style path such as .metadata.annotations["a/b"] or
+// .spec.containers[0].image into its tokens. Only field and index accesses
+// are supported.
+func parseJQPath(expression string) ([]string, error) {
+	var tokens []string
+
+	rest := expression
+
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			rest = rest[1:]
+			end := strings.IndexAny(rest, ".[")
+
+			if end == -1 {
+				end = len(rest)
+			}
+
+			if end > 0 {
+				tokens = append(tokens, rest[:end])
+			}
+
+			rest = rest[end:]
+		case '[':
+			end := strings.Index(rest, "]")
+			if end == -1 {
+				return nil, fmt.Errorf("invalid jq path %q: missing ]", expression)
+			}
+
+			token := rest[1:end]
+			if unquoted, err := strconv.Unquote(token); err == nil {
+				token = unquoted
+			}
+
+			tokens = append(tokens, token)
+			rest = rest[end+1:]
+		default:
+			return nil, fmt.Errorf("invalid jq path %q: unexpected %q", expression, rest[0])
+		}
+	}
+
+	return tokens, nil
+}
+
+// removePath returns obj without the value found at path. Missing paths are
+// ignored.
+func removePath(obj interface{}, path []string) interface{} {
+	if len(path) == 0 {
+		return obj
+	}
+
+	switch o := obj.(type) {
+	case map[string]interface{}:
+		if len(path) == 1 {
+			delete(o, path[0])
+			return o
+		}
+
+		if v, ok := o[path[0]]; ok {
+			o[path[0]] = removePath(v, path[1:])
+		}
+
+		return o
+	case []interface{}:
+		i, err := strconv.Atoi(path[0])
+		if err != nil || i < 0 || i >= len(o) {
+			return o
+		}
+
+		if len(path) == 1 {
+			return append(o[:i], o[i+1:]...)
+		}
+
+		o[i] = removePath(o[i], path[1:])
+
+		return o
+	default:
+		return obj
+	}
+}
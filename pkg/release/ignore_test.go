@@ -0,0 +1,112 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+const testDeploymentManifest = `---
+# Source: test/templates/deployment.yaml
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: test
+  annotations:
+    example.com/revision: "%s"
+spec:
+  replicas: %d
+  template:
+    spec:
+      containers:
+      - name: test
+        image: %s
+`
+
+func TestManifestsDiffer(t *testing.T) {
+	deployed := fmt.Sprintf(testDeploymentManifest, "1", 1, "nginx:1.19")
+
+	tests := []struct {
+		candidate string
+		rules     []appv1.ResourceIgnoreDifferences
+		differ    bool
+	}{
+		{
+			candidate: deployed,
+			differ:    false,
+		},
+		{
+			candidate: fmt.Sprintf(testDeploymentManifest, "1", 3, "nginx:1.19"),
+			differ:    true,
+		},
+		{
+			candidate: fmt.Sprintf(testDeploymentManifest, "1", 3, "nginx:1.19"),
+			rules: []appv1.ResourceIgnoreDifferences{
+				{Kind: "Deployment", JSONPointers: []string{"/spec/replicas"}},
+			},
+			differ: false,
+		},
+		{
+			candidate: fmt.Sprintf(testDeploymentManifest, "1", 3, "nginx:1.19"),
+			rules: []appv1.ResourceIgnoreDifferences{
+				{Kind: "Deployment", Name: "other", JSONPointers: []string{"/spec/replicas"}},
+			},
+			differ: true,
+		},
+		{
+			candidate: fmt.Sprintf(testDeploymentManifest, "2", 1, "nginx:1.20"),
+			rules: []appv1.ResourceIgnoreDifferences{
+				{
+					APIVersion:        "apps/v1",
+					Kind:              "Deployment",
+					JSONPointers:      []string{"/metadata/annotations/example.com~1revision"},
+					JQPathExpressions: []string{".spec.template.spec.containers[0].image"},
+				},
+			},
+			differ: false,
+		},
+		{
+			candidate: fmt.Sprintf(testDeploymentManifest, "2", 1, "nginx:1.20"),
+			rules: []appv1.ResourceIgnoreDifferences{
+				{Kind: "Deployment", JQPathExpressions: []string{`.metadata.annotations["example.com/revision"]`}},
+			},
+			differ: true,
+		},
+	}
+
+	for _, test := range tests {
+		differ, err := manifestsDiffer(deployed, test.candidate, test.rules)
+		assert.NoError(t, err)
+		assert.Equal(t, test.differ, differ)
+	}
+}
+
+func TestParseJQPath(t *testing.T) {
+	path, err := parseJQPath(`.metadata.annotations["a/b"]`)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"metadata", "annotations", "a/b"}, path)
+
+	path, err = parseJQPath(".spec.containers[1].image")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"spec", "containers", "1", "image"}, path)
+
+	_, err = parseJQPath(".spec.containers[1")
+	assert.Error(t, err)
+}
@@ -0,0 +1,78 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release
+
+import (
+	"sort"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// podSpecPath returns the path of the pod spec of the workloads of kind, nil
+// if kind is not a workload.
+func podSpecPath(kind string) []string {
+	switch kind {
+	case "Pod":
+		return []string{"spec"}
+	case "Deployment", "StatefulSet", "DaemonSet", "ReplicaSet", "ReplicationController", "Job":
+		return []string{"spec", "template", "spec"}
+	case "CronJob":
+		return []string{"spec", "jobTemplate", "spec", "template", "spec"}
+	default:
+		return nil
+	}
+}
+
+// IsWorkload returns true if the resources of kind have a pod spec.
+func IsWorkload(kind string) bool {
+	return podSpecPath(kind) != nil
+}
+
+// Images returns the sorted container images referenced by the pod specs of
+// the workloads of objects, init and ephemeral containers included.
+func Images(objects []*unstructured.Unstructured) []string {
+	found := map[string]bool{}
+
+	for _, u := range objects {
+		path := podSpecPath(u.GetKind())
+		if path == nil {
+			continue
+		}
+
+		for _, field := range []string{"containers", "initContainers", "ephemeralContainers"} {
+			containers, _, _ := unstructured.NestedSlice(u.Object, append(path, field)...)
+
+			for _, c := range containers {
+				container, ok := c.(map[string]interface{})
+				if !ok {
+					continue
+				}
+
+				if image, ok := container["image"].(string); ok && image != "" {
+					found[image] = true
+				}
+			}
+		}
+	}
+
+	images := make([]string, 0, len(found))
+	for image := range found {
+		images = append(images, image)
+	}
+
+	sort.Strings(images)
+
+	return images
+}
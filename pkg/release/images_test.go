@@ -0,0 +1,51 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestImages(t *testing.T) {
+	deployment := &unstructured.Unstructured{Object: map[string]interface{}{
+		"kind": "Deployment",
+		"spec": map[string]interface{}{"template": map[string]interface{}{"spec": map[string]interface{}{
+			"initContainers": []interface{}{map[string]interface{}{"name": "init", "image": "busybox:1.32"}},
+			"containers": []interface{}{
+				map[string]interface{}{"name": "web", "image": "nginx:1.19"},
+				map[string]interface{}{"name": "sidecar", "image": "envoy@sha256:abc"},
+			},
+		}}},
+	}}
+	cronJob := &unstructured.Unstructured{Object: map[string]interface{}{
+		"kind": "CronJob",
+		"spec": map[string]interface{}{"jobTemplate": map[string]interface{}{"spec": map[string]interface{}{
+			"template": map[string]interface{}{"spec": map[string]interface{}{
+				"containers": []interface{}{map[string]interface{}{"name": "backup", "image": "nginx:1.19"}},
+			}},
+		}}},
+	}}
+	configMap := &unstructured.Unstructured{Object: map[string]interface{}{
+		"kind": "ConfigMap",
+		"data": map[string]interface{}{"image": "ignored:1.0"},
+	}}
+
+	assert.Equal(t, []string{"busybox:1.32", "envoy@sha256:abc", "nginx:1.19"},
+		Images([]*unstructured.Unstructured{deployment, cronJob, configMap}))
+	assert.Empty(t, Images([]*unstructured.Unstructured{configMap}))
+}
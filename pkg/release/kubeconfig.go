@@ -0,0 +1,65 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	crclient "sigs.k8s.io/controller-runtime/pkg/client"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+// DefaultKubeConfigKey is the key of the kubeconfig in its Secret when the
+// HelmRelease does not set one.
+const DefaultKubeConfigKey = "value"
+
+// RemoteConfig returns the config of the remote cluster a release is deployed
+// to, read from the kubeconfig Secret of the namespace, or nil if kubeConfig
+// is nil and the release is deployed to the cluster of the operator.
+func RemoteConfig(reader crclient.Reader, namespace string, kubeConfig *appv1.KubeConfig) (*rest.Config, error) {
+	if kubeConfig == nil {
+		return nil, nil
+	}
+
+	secret := &corev1.Secret{}
+	key := types.NamespacedName{Namespace: namespace, Name: kubeConfig.SecretRef.Name}
+
+	if err := reader.Get(context.TODO(), key, secret); err != nil {
+		return nil, fmt.Errorf("failed to get kubeconfig secret %s: %w", key, err)
+	}
+
+	dataKey := kubeConfig.Key
+	if dataKey == "" {
+		dataKey = DefaultKubeConfigKey
+	}
+
+	data, ok := secret.Data[dataKey]
+	if !ok {
+		return nil, fmt.Errorf("kubeconfig secret %s has no %s key", key, dataKey)
+	}
+
+	cfg, err := clientcmd.RESTConfigFromKubeConfig(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load kubeconfig of secret %s: %w", key, err)
+	}
+
+	return cfg, nil
+}
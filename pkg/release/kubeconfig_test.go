@@ -0,0 +1,74 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+const testKubeConfig = `apiVersion: v1
+kind: Config
+clusters:
+- name: remote
+  cluster:
+    server: https://remote.example.com:6443
+contexts:
+- name: remote
+  context:
+    cluster: remote
+    user: remote
+current-context: remote
+users:
+- name: remote
+  user:
+    token: remote-token
+`
+
+func TestRemoteConfig(t *testing.T) {
+	reader := fake.NewFakeClient(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "remote", Namespace: "default"},
+		Data: map[string][]byte{
+			DefaultKubeConfigKey: []byte(testKubeConfig),
+			"invalid":            []byte("clusters: ["),
+		},
+	})
+
+	// deployed to the cluster of the operator
+	cfg, err := RemoteConfig(reader, "default", nil)
+	require.NoError(t, err)
+	assert.Nil(t, cfg)
+
+	cfg, err = RemoteConfig(reader, "default", &appv1.KubeConfig{SecretRef: corev1.LocalObjectReference{Name: "remote"}})
+	require.NoError(t, err)
+	assert.Equal(t, "https://remote.example.com:6443", cfg.Host)
+	assert.Equal(t, "remote-token", cfg.BearerToken)
+
+	for _, kubeConfig := range []*appv1.KubeConfig{
+		{SecretRef: corev1.LocalObjectReference{Name: "missing"}},
+		{SecretRef: corev1.LocalObjectReference{Name: "remote"}, Key: "missing"},
+		{SecretRef: corev1.LocalObjectReference{Name: "remote"}, Key: "invalid"},
+	} {
+		_, err = RemoteConfig(reader, "default", kubeConfig)
+		assert.Error(t, err, kubeConfig.Key)
+	}
+}
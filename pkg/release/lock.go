@@ -0,0 +1,243 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/go-logr/logr"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/uuid"
+	coordinationclientv1 "k8s.io/client-go/kubernetes/typed/coordination/v1"
+	"k8s.io/client-go/rest"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+var log = logf.Log.WithName("release")
+
+const (
+	// releaseLockDuration is how long a release lock is held without renewal,
+	// e.g. after the holder crashed.
+	releaseLockDuration = time.Minute
+	// releaseLockRenewInterval is how often a held release lock is renewed.
+	releaseLockRenewInterval = releaseLockDuration / 3
+)
+
+// ErrReleaseLocked is returned when another operator process holds the lock
+// of the release.
+var ErrReleaseLocked = errors.New("release is locked by another operator")
+
+// ErrReleaseLockLost is returned by the helm actions started after the lock
+// of the release was lost, e.g. taken over by another operator process.
+var ErrReleaseLockLost = errors.New("lock of the release was lost")
+
+// lockIdentity identifies this operator process as a release lock holder.
+var lockIdentity = newLockIdentity()
+
+func newLockIdentity() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+
+	return hostname + "_" + string(uuid.NewUUID())
+}
+
+// releaseLocker acquires the lease-based lock of a release so that two
+// operator processes, e.g. during a leader failover, never run helm actions
+// on the same release concurrently. It returns a context canceled once the
+// lock is lost or released, and the function releasing the lock.
+type releaseLocker func(ctx context.Context) (context.Context, func(), error)
+
+// newReleaseLocker returns the locker of the release name. The lease lives in
+// the storage namespace, next to the release records it protects.
+func newReleaseLocker(cfg *rest.Config, storageNamespace, name string) (releaseLocker, error) {
+	client, err := coordinationclientv1.NewForConfig(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get coordination/v1 client: %w", err)
+	}
+
+	leases := client.Leases(storageNamespace)
+	leaseName := "helmrelease-lock-" + name
+	logger := log.WithValues("release", storageNamespace+"/"+name)
+
+	return func(ctx context.Context) (context.Context, func(), error) {
+		return holdLease(ctx, leases, leaseName, releaseLockRenewInterval, logger)
+	}, nil
+}
+
+// holdLease acquires the lease and renews it every interval until the
+// returned function is called. The returned context is canceled when the
+// lease is taken over by another process, or when it could not be renewed
+// before it expires.
+func holdLease(ctx context.Context, leases coordinationclientv1.LeaseInterface, name string,
+	interval time.Duration, logger logr.Logger) (context.Context, func(), error) {
+	if err := acquireLease(ctx, leases, name); err != nil {
+		return nil, nil, err
+	}
+
+	lockCtx, cancel := context.WithCancel(ctx)
+	stop := make(chan struct{})
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		renewed := time.Now()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				err := acquireLease(context.TODO(), leases, name)
+				if err == nil {
+					renewed = time.Now()
+					continue
+				}
+
+				logger.Error(err, "Failed to renew the lock of the release")
+
+				// another process may take the lease once it expires, stop
+				// before then
+				if errors.Is(err, ErrReleaseLocked) || time.Since(renewed)+interval >= releaseLockDuration {
+					logger.Info("Lost the lock of the release")
+					cancel()
+
+					return
+				}
+			}
+		}
+	}()
+
+	return lockCtx, func() {
+		close(stop)
+		<-done
+		cancel()
+
+		if err := releaseLease(context.TODO(), leases, name); err != nil {
+			logger.Error(err, "Failed to release the lock of the release")
+		}
+	}, nil
+}
+
+// lockHeld returns ErrReleaseLockLost if the context returned by Lock was
+// canceled because the lock of the release was lost. Helm 3.4 actions do not
+// take a context, so an action already running is not interrupted, only the
+// next ones fail.
+func lockHeld(ctx context.Context) error {
+	if ctx.Err() != nil {
+		return ErrReleaseLockLost
+	}
+
+	return nil
+}
+
+// acquireLease creates, takes over or renews the lease for lockIdentity. It
+// returns ErrReleaseLocked if the lease is held by another process and has
+// not expired.
+func acquireLease(ctx context.Context, leases coordinationclientv1.LeaseInterface, name string) error {
+	now := metav1.NewMicroTime(time.Now())
+	duration := int32(releaseLockDuration / time.Second)
+	identity := lockIdentity
+
+	lease, err := leases.Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		_, err = leases.Create(ctx, &coordinationv1.Lease{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec: coordinationv1.LeaseSpec{
+				HolderIdentity:       &identity,
+				LeaseDurationSeconds: &duration,
+				AcquireTime:          &now,
+				RenewTime:            &now,
+			},
+		}, metav1.CreateOptions{})
+
+		if apierrors.IsAlreadyExists(err) {
+			return ErrReleaseLocked
+		}
+
+		return err
+	}
+
+	if err != nil {
+		return err
+	}
+
+	held := lease.Spec.HolderIdentity != nil && *lease.Spec.HolderIdentity != ""
+	if held && *lease.Spec.HolderIdentity != lockIdentity && !leaseExpired(lease) {
+		return ErrReleaseLocked
+	}
+
+	if !held || *lease.Spec.HolderIdentity != lockIdentity {
+		lease.Spec.AcquireTime = &now
+	}
+
+	lease.Spec.HolderIdentity = &identity
+	lease.Spec.LeaseDurationSeconds = &duration
+	lease.Spec.RenewTime = &now
+
+	// the resource version makes a concurrent take over fail with a conflict
+	_, err = leases.Update(ctx, lease, metav1.UpdateOptions{})
+	if apierrors.IsConflict(err) {
+		return ErrReleaseLocked
+	}
+
+	return err
+}
+
+// releaseLease clears the holder of the lease if it is still lockIdentity.
+func releaseLease(ctx context.Context, leases coordinationclientv1.LeaseInterface, name string) error {
+	lease, err := leases.Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if lease.Spec.HolderIdentity == nil || *lease.Spec.HolderIdentity != lockIdentity {
+		return nil
+	}
+
+	lease.Spec.HolderIdentity = nil
+
+	_, err = leases.Update(ctx, lease, metav1.UpdateOptions{})
+	if apierrors.IsConflict(err) {
+		return nil
+	}
+
+	return err
+}
+
+func leaseExpired(lease *coordinationv1.Lease) bool {
+	if lease.Spec.RenewTime == nil || lease.Spec.LeaseDurationSeconds == nil {
+		return true
+	}
+
+	expiry := lease.Spec.RenewTime.Add(time.Duration(*lease.Spec.LeaseDurationSeconds) * time.Second)
+
+	return time.Now().After(expiry)
+}
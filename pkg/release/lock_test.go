@@ -0,0 +1,109 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestReleaseLease(t *testing.T) {
+	leases := fake.NewSimpleClientset().CoordinationV1().Leases("default")
+	ctx := context.TODO()
+
+	// the lease is created for this process and renewed
+	require.NoError(t, acquireLease(ctx, leases, "lock"))
+	require.NoError(t, acquireLease(ctx, leases, "lock"))
+
+	lease, err := leases.Get(ctx, "lock", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, lockIdentity, *lease.Spec.HolderIdentity)
+
+	// released, it is free to take
+	require.NoError(t, releaseLease(ctx, leases, "lock"))
+
+	lease, err = leases.Get(ctx, "lock", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Nil(t, lease.Spec.HolderIdentity)
+
+	// held by another process, it is locked until it expires
+	holder := "other-operator"
+	duration := int32(60)
+	renewed := metav1.NewMicroTime(time.Now())
+	lease.Spec = coordinationv1.LeaseSpec{HolderIdentity: &holder, LeaseDurationSeconds: &duration, RenewTime: &renewed}
+
+	_, err = leases.Update(ctx, lease, metav1.UpdateOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, ErrReleaseLocked, acquireLease(ctx, leases, "lock"))
+
+	// the lease of another holder is not released
+	require.NoError(t, releaseLease(ctx, leases, "lock"))
+
+	lease, err = leases.Get(ctx, "lock", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, holder, *lease.Spec.HolderIdentity)
+
+	expired := metav1.NewMicroTime(time.Now().Add(-2 * releaseLockDuration))
+	lease.Spec.RenewTime = &expired
+
+	_, err = leases.Update(ctx, lease, metav1.UpdateOptions{})
+	require.NoError(t, err)
+	require.NoError(t, acquireLease(ctx, leases, "lock"))
+
+	lease, err = leases.Get(ctx, "lock", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, lockIdentity, *lease.Spec.HolderIdentity)
+}
+
+func TestHoldLease(t *testing.T) {
+	leases := fake.NewSimpleClientset().CoordinationV1().Leases("default")
+	ctx := context.TODO()
+
+	lockCtx, unlock, err := holdLease(ctx, leases, "lock", 10*time.Millisecond, log)
+	require.NoError(t, err)
+	require.NoError(t, lockHeld(lockCtx))
+
+	// taken over by another process, the lock is lost
+	lease, err := leases.Get(ctx, "lock", metav1.GetOptions{})
+	require.NoError(t, err)
+
+	holder := "other-operator"
+	lease.Spec.HolderIdentity = &holder
+
+	_, err = leases.Update(ctx, lease, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	select {
+	case <-lockCtx.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("the lock context was not canceled")
+	}
+
+	assert.Equal(t, ErrReleaseLockLost, lockHeld(lockCtx))
+
+	// the lease of the new holder is left alone
+	unlock()
+
+	lease, err = leases.Get(ctx, "lock", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, holder, *lease.Spec.HolderIdentity)
+}
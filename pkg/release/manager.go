@@ -22,18 +22,22 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"sort"
 	"strings"
+	"time"
 
 	jsonpatch "gomodules.xyz/jsonpatch/v3"
 	"helm.sh/helm/v3/pkg/action"
 	cpb "helm.sh/helm/v3/pkg/chart"
 	"helm.sh/helm/v3/pkg/kube"
 	helmkube "helm.sh/helm/v3/pkg/kube"
+	"helm.sh/helm/v3/pkg/postrender"
 	rpb "helm.sh/helm/v3/pkg/release"
 	"helm.sh/helm/v3/pkg/storage"
 	"helm.sh/helm/v3/pkg/storage/driver"
 	apiextv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	apiextv1beta1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	apitypes "k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/strategicpatch"
@@ -46,30 +50,66 @@ import (
 // and uninstall a release.
 type Manager interface {
 	ReleaseName() string
+	ReleaseDigest() string
+	ValuesDigest() string
+	Chart() *cpb.Chart
 	IsInstalled() bool
 	IsUpgradeRequired() bool
 	Sync(context.Context) error
 	InstallRelease(context.Context, ...InstallOption) (*rpb.Release, error)
 	UpgradeRelease(context.Context, ...UpgradeOption) (*rpb.Release, *rpb.Release, error)
 	UninstallRelease(context.Context, ...UninstallOption) (*rpb.Release, error)
+	OrphanRelease(context.Context) (*rpb.Release, error)
 	GetDeployedRelease() (*rpb.Release, error)
+	History(context.Context) ([]*rpb.Release, error)
+	RollbackRelease(context.Context, int) (*rpb.Release, error)
+	DetectDrift(context.Context) ([]appv1.HelmAppResource, error)
+	RemediateDrift(context.Context) ([]appv1.HelmAppResource, error)
+	FieldConflicts(context.Context) ([]appv1.HelmAppFieldConflict, error)
+	ResourceStatus(context.Context, string) ([]appv1.HelmAppResourceStatus, error)
+	RolloutProgress() string
+	Diff(context.Context) (*ReleaseDiff, error)
+	Render(context.Context, RenderOptions) ([]*unstructured.Unstructured, error)
+	DebugDump(context.Context) (*DebugDump, error)
+	MissingPermissions(context.Context) ([]string, error)
+	Lock(context.Context) (context.Context, func(), error)
 }
 
 type manager struct {
 	actionConfig   *action.Configuration
 	storageBackend *storage.Storage
 	kubeClient     kube.Interface
+	labelRecord    recordLabeler
+	lock           releaseLocker
+	progress       *rolloutProgress
 
 	releaseName string
 	namespace   string
 
-	values map[string]interface{}
-	status *appv1.HelmAppStatus
+	values            map[string]interface{}
+	secrets           []string
+	status            *appv1.HelmAppStatus
+	ignoreDifferences []appv1.ResourceIgnoreDifferences
+	fieldManager      string
+	historyCompaction *appv1.HistoryCompaction
+	pinnedRevisions   map[int]bool
+	maxHistory        int
+	digest            string
+	wait              bool
+	timeout           time.Duration
+	pendingPolicy     appv1.PendingReleasePolicyEnum
+	retryPending      bool
 
 	isInstalled       bool
 	isUpgradeRequired bool
 	deployedRelease   *rpb.Release
 	chart             *cpb.Chart
+	postRenderer      postrender.PostRenderer
+
+	// the chart is loaded again from chartPath when the manager is reused,
+	// if its content still has chartDigest
+	chartPath   string
+	chartDigest string
 }
 
 type InstallOption func(*action.Install) error
@@ -81,6 +121,30 @@ func (m manager) ReleaseName() string {
 	return m.releaseName
 }
 
+// ReleaseDigest returns the digest of the chart, values and settings the
+// release is rendered from.
+func (m manager) ReleaseDigest() string {
+	return m.digest
+}
+
+// Chart returns the chart the release is rendered from.
+func (m manager) Chart() *cpb.Chart {
+	return m.chart
+}
+
+// Lock acquires the lock of the release, shared by all the operator
+// processes, and returns the function releasing it. It returns
+// ErrReleaseLocked if another process holds it. The returned context is
+// canceled once the lock is lost; the helm actions given that context fail
+// with ErrReleaseLockLost from then on.
+func (m manager) Lock(ctx context.Context) (context.Context, func(), error) {
+	if m.lock == nil {
+		return ctx, func() {}, nil
+	}
+
+	return m.lock(ctx)
+}
+
 func (m manager) IsInstalled() bool {
 	return m.isInstalled
 }
@@ -92,75 +156,200 @@ func (m manager) IsUpgradeRequired() bool {
 // Sync ensures the Helm storage backend is in sync with the status of the
 // custom resource.
 func (m *manager) Sync(ctx context.Context) error {
+	if err := lockHeld(ctx); err != nil {
+		return err
+	}
+
 	// Get release history for this release name
 	releases, err := m.storageBackend.History(m.releaseName)
 	if err != nil && !notFoundErr(err) {
-		return fmt.Errorf("failed to retrieve release history: %w", err)
+		return storageFailed(fmt.Errorf("failed to retrieve release history: %w", err))
 	}
 
-	// Cleanup non-deployed release versions. If all release versions are
+	if pending := latestPending(releases); pending != nil {
+		if err := m.recoverPending(pending); err != nil {
+			return err
+		}
+	}
+
+	// Cleanup failed and pending release versions. If all release versions are
 	// non-deployed, this will ensure that failed installations are correctly
-	// retried.
+	// retried. Superseded versions are kept for rollbacks, up to maxHistory.
+	var superseded []*rpb.Release
+
 	for _, rel := range releases {
-		if rel.Info != nil && rel.Info.Status != rpb.StatusDeployed {
-			_, err := m.storageBackend.Delete(rel.Name, rel.Version)
-			if err != nil && !notFoundErr(err) {
-				return fmt.Errorf("failed to delete stale release version: %w", err)
-			}
+		if rel.Info == nil || rel.Info.Status == rpb.StatusDeployed {
+			continue
+		}
+
+		if rel.Info.Status == rpb.StatusSuperseded {
+			superseded = append(superseded, rel)
+			continue
+		}
+
+		_, err := m.storageBackend.Delete(rel.Name, rel.Version)
+		if err != nil && !notFoundErr(err) {
+			return storageFailed(fmt.Errorf("failed to delete stale release version: %w", err))
 		}
 	}
 
+	if err := m.trimSuperseded(superseded); err != nil {
+		return err
+	}
+
+	if err := m.compactHistory(); err != nil {
+		return err
+	}
+
 	// Load the most recently deployed release from the storage backend.
 	deployedRelease, err := m.GetDeployedRelease()
-	if errors.Is(err, driver.ErrReleaseNotFound) {
+	if errors.Is(err, ErrReleaseNotFound) {
 		return nil
 	}
 	if err != nil {
-		return fmt.Errorf("failed to get deployed release: %w", err)
+		return storageFailed(fmt.Errorf("failed to get deployed release: %w", err))
 	}
 	m.deployedRelease = deployedRelease
 	m.isInstalled = true
 
+	// Label the record so that it can be garbage collected if the custom
+	// resource goes away without uninstalling the release.
+	if m.labelRecord != nil {
+		if err := m.labelRecord(deployedRelease); err != nil {
+			return fmt.Errorf("failed to label deployed release record: %w", err)
+		}
+	}
+
+	// Skip the candidate render if the deployed release recorded in the status
+	// was rendered from the same inputs. The status has the Secret data redacted.
+	if deployed := m.status.DeployedRelease; deployed != nil && deployed.Digest != "" &&
+		deployed.Digest == m.digest && deployed.Manifest == RedactManifest(deployedRelease.Manifest) {
+		m.isUpgradeRequired = false
+		return nil
+	}
+
 	// Get the next candidate release to determine if an upgrade is necessary.
 	candidateRelease, err := m.getCandidateRelease(m.namespace, m.releaseName, m.chart, m.values)
 	if err != nil {
-		return fmt.Errorf("failed to get candidate release: %w", err)
+		return renderFailed(m.redactError(fmt.Errorf("failed to get candidate release: %w", err)))
 	}
-	if deployedRelease.Manifest != candidateRelease.Manifest {
-		m.isUpgradeRequired = true
+	upgradeRequired, err := manifestsDiffer(deployedRelease.Manifest, candidateRelease.Manifest, m.ignoreDifferences)
+	if err != nil {
+		return fmt.Errorf("failed to compare deployed and candidate manifests: %w", err)
 	}
+	m.isUpgradeRequired = upgradeRequired
 
 	return nil
 }
 
-func notFoundErr(err error) bool {
-	return err != nil && strings.Contains(err.Error(), "not found")
+// trimSuperseded deletes the oldest superseded release versions so that at
+// most maxHistory versions are kept, the deployed one included.
+func (m *manager) trimSuperseded(superseded []*rpb.Release) error {
+	if m.maxHistory <= 0 || len(superseded) < m.maxHistory {
+		return nil
+	}
+
+	sort.Slice(superseded, func(i, j int) bool {
+		return superseded[i].Version > superseded[j].Version
+	})
+
+	for _, rel := range superseded[m.maxHistory-1:] {
+		_, err := m.storageBackend.Delete(rel.Name, rel.Version)
+		if err != nil && !notFoundErr(err) {
+			return storageFailed(fmt.Errorf("failed to delete superseded release version: %w", err))
+		}
+	}
+
+	return nil
+}
+
+// compactHistory deletes the release revisions selected by the history
+// compaction policy, if any.
+func (m *manager) compactHistory() error {
+	if m.historyCompaction == nil {
+		return nil
+	}
+
+	releases, err := m.storageBackend.History(m.releaseName)
+	if err != nil {
+		if notFoundErr(err) {
+			return nil
+		}
+
+		return storageFailed(fmt.Errorf("failed to retrieve release history: %w", err))
+	}
+
+	for _, version := range compactedRevisions(releases, m.historyCompaction.KeepLast, m.pinnedRevisions) {
+		if _, err := m.storageBackend.Delete(m.releaseName, version); err != nil && !notFoundErr(err) {
+			return fmt.Errorf("failed to delete compacted release version: %w", err)
+		}
+	}
+
+	return nil
 }
 
+// GetDeployedRelease returns the deployed revision of the release, with its
+// chart, or ErrReleaseNotFound if there is none.
 func (m manager) GetDeployedRelease() (*rpb.Release, error) {
 	deployedRelease, err := m.storageBackend.Deployed(m.releaseName)
 	if err != nil {
+		// the helm storage only reports it as a formatted string
 		if strings.Contains(err.Error(), "has no deployed releases") {
-			return nil, driver.ErrReleaseNotFound
+			return nil, ErrReleaseNotFound
 		}
 		return nil, err
 	}
-	return deployedRelease, nil
+	return fullRelease(m.storageBackend, deployedRelease)
 }
 
 func (m manager) getCandidateRelease(namespace, name string, chart *cpb.Chart,
 	values map[string]interface{}) (*rpb.Release, error) {
-	upgrade := action.NewUpgrade(m.actionConfig)
+	actionConfig, err := m.candidateActionConfig(name)
+	if err != nil {
+		return nil, err
+	}
+
+	upgrade := action.NewUpgrade(actionConfig)
 	upgrade.Namespace = namespace
 	upgrade.DryRun = true
+	upgrade.PostRenderer = m.postRenderer
 	return upgrade.Run(name, chart, values)
 }
 
+// candidateActionConfig returns a copy of the action configuration backed by
+// an in-memory copy of the release history, so that candidate renders can
+// never interfere with the real release records and can run concurrently.
+func (m manager) candidateActionConfig(name string) (*action.Configuration, error) {
+	history, err := m.storageBackend.History(name)
+	if err != nil && !notFoundErr(err) {
+		return nil, fmt.Errorf("failed to retrieve release history: %w", err)
+	}
+
+	memory := storage.Init(driver.NewMemory())
+	for _, rel := range history {
+		if err := memory.Create(rel); err != nil {
+			return nil, fmt.Errorf("failed to copy release history: %w", err)
+		}
+	}
+
+	actionConfig := *m.actionConfig
+	actionConfig.Releases = memory
+
+	return &actionConfig, nil
+}
+
 // InstallRelease performs a Helm release install.
 func (m manager) InstallRelease(ctx context.Context, opts ...InstallOption) (*rpb.Release, error) {
+	if err := lockHeld(ctx); err != nil {
+		return nil, err
+	}
+
 	install := action.NewInstall(m.actionConfig)
 	install.ReleaseName = m.releaseName
 	install.Namespace = m.namespace
+	install.Wait = m.wait
+	install.Timeout = m.timeout
+	install.PostRenderer = m.postRenderer
 	for _, o := range opts {
 		if err := o(install); err != nil {
 			return nil, fmt.Errorf("failed to apply install option: %w", err)
@@ -183,11 +372,18 @@ func (m manager) InstallRelease(ctx context.Context, opts ...InstallOption) (*rp
 			// Only log a message about a rollback failure if the failure was caused
 			// by something other than the release not being found.
 			if uninstallErr != nil && !notFoundErr(uninstallErr) {
-				return nil, fmt.Errorf("failed installation (%s) and failed rollback: %w", err, uninstallErr)
+				return nil, m.redactError(fmt.Errorf("failed installation (%s) and failed rollback: %w", err, uninstallErr))
 			}
 		}
-		return nil, fmt.Errorf("failed to install release: %w", err)
+		return nil, m.redactError(fmt.Errorf("failed to install release: %w", withRenderFailed(withHooksFailed(err))))
+	}
+
+	// label the record right away so that another HelmRelease with the same
+	// release name does not take it over, the next Sync labels it otherwise
+	if m.labelRecord != nil {
+		_ = m.labelRecord(installedRelease)
 	}
+
 	return installedRelease, nil
 }
 
@@ -200,8 +396,15 @@ func ForceUpgrade(force bool) UpgradeOption {
 
 // UpgradeRelease performs a Helm release upgrade.
 func (m manager) UpgradeRelease(ctx context.Context, opts ...UpgradeOption) (*rpb.Release, *rpb.Release, error) {
+	if err := lockHeld(ctx); err != nil {
+		return nil, nil, err
+	}
+
 	upgrade := action.NewUpgrade(m.actionConfig)
 	upgrade.Namespace = m.namespace
+	upgrade.Wait = m.wait
+	upgrade.Timeout = m.timeout
+	upgrade.PostRenderer = m.postRenderer
 	for _, o := range opts {
 		if err := o(upgrade); err != nil {
 			return nil, nil, fmt.Errorf("failed to apply upgrade option: %w", err)
@@ -214,6 +417,8 @@ func (m manager) UpgradeRelease(ctx context.Context, opts ...UpgradeOption) (*rp
 		if upgradedRelease != nil {
 			rollback := action.NewRollback(m.actionConfig)
 			rollback.Force = true
+			rollback.Wait = m.wait
+			rollback.Timeout = m.timeout
 
 			// As of Helm 2.13, if UpgradeRelease returns a non-nil release, that
 			// means the release was also recorded in the release store.
@@ -222,10 +427,12 @@ func (m manager) UpgradeRelease(ctx context.Context, opts ...UpgradeOption) (*rp
 			// log both the upgrade and rollback errors.
 			rollbackErr := rollback.Run(m.releaseName)
 			if rollbackErr != nil {
-				return nil, nil, fmt.Errorf("failed upgrade (%s) and failed rollback: %w", err, rollbackErr)
+				return nil, nil, &ErrUpgradeFailed{Err: m.redactError(withRenderFailed(withHooksFailed(err))), RollbackErr: m.redactError(rollbackErr)}
 			}
+
+			return nil, nil, &ErrUpgradeFailed{Err: m.redactError(withRenderFailed(withHooksFailed(err))), RolledBack: true}
 		}
-		return nil, nil, fmt.Errorf("failed to upgrade release: %w", err)
+		return nil, nil, &ErrUpgradeFailed{Err: m.redactError(withRenderFailed(withHooksFailed(err)))}
 	}
 	return m.deployedRelease, upgradedRelease, err
 }
@@ -299,12 +506,17 @@ func createJSONMergePatch(existingJSON, expectedJSON []byte) ([]byte, error) {
 
 // UninstallRelease performs a Helm release uninstall.
 func (m manager) UninstallRelease(ctx context.Context, opts ...UninstallOption) (*rpb.Release, error) {
+	if err := lockHeld(ctx); err != nil {
+		return nil, err
+	}
+
 	// Get history of this release
 	if _, err := m.storageBackend.History(m.releaseName); err != nil {
 		return nil, fmt.Errorf("failed to get release history: %w", err)
 	}
 
 	uninstall := action.NewUninstall(m.actionConfig)
+	uninstall.Timeout = m.timeout
 	for _, o := range opts {
 		if err := o(uninstall); err != nil {
 			return nil, fmt.Errorf("failed to apply uninstall option: %w", err)
@@ -312,8 +524,40 @@ func (m manager) UninstallRelease(ctx context.Context, opts ...UninstallOption)
 	}
 	uninstallResponse, err := uninstall.Run(m.releaseName)
 	if err != nil {
-		return nil, err
+		return nil, m.redactError(withHooksFailed(err))
 	}
 
 	return uninstallResponse.Release, nil
 }
+
+// OrphanRelease deletes the records of the release without deleting its
+// resources, they are left running in the cluster. It returns the deployed
+// revision, if any.
+func (m manager) OrphanRelease(ctx context.Context) (*rpb.Release, error) {
+	if err := lockHeld(ctx); err != nil {
+		return nil, err
+	}
+
+	releases, err := m.storageBackend.History(m.releaseName)
+	if err != nil && !notFoundErr(err) {
+		return nil, fmt.Errorf("failed to get release history: %w", err)
+	}
+
+	if len(releases) == 0 {
+		return nil, ErrReleaseNotFound
+	}
+
+	var deployed *rpb.Release
+
+	for _, rel := range releases {
+		if rel.Info != nil && rel.Info.Status == rpb.StatusDeployed {
+			deployed = rel
+		}
+
+		if _, err := m.storageBackend.Delete(rel.Name, rel.Version); err != nil && !notFoundErr(err) {
+			return nil, fmt.Errorf("failed to delete release version: %w", err)
+		}
+	}
+
+	return deployed, nil
+}
@@ -0,0 +1,129 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release
+
+import (
+	"sync"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	apitypes "k8s.io/apimachinery/pkg/types"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+// ReconcileRequestAnnotation requests a full reconcile of a custom resource
+// when its value changes, e.g. set to the current time: the chart is
+// downloaded again and the values resolved again.
+const ReconcileRequestAnnotation = "apps.open-cluster-management.io/reconcile-at"
+
+// ManagerCache reuses the managers of the custom resources across reconciles
+// until their spec changes. The action configuration, the loaded chart and
+// the merged values are reused while the status is refreshed from the custom
+// resource on each Get. The cached managers do not hold their chart between
+// reconciles: it is loaded again from the downloaded chart on each Get, which
+// is a miss when the chart was removed or changed. The managers of remote or
+// impersonated releases are not cached: their rest config depends on a
+// kubeconfig Secret or on a ServiceAccount that may change behind the same
+// generation. It is safe for concurrent use.
+type ManagerCache struct {
+	mu       sync.Mutex
+	managers map[apitypes.NamespacedName]cachedManager
+}
+
+type cachedManager struct {
+	uid        apitypes.UID
+	generation int64
+	pinned     string
+	requested  string
+	manager    *manager
+}
+
+// NewManagerCache returns an empty manager cache.
+func NewManagerCache() *ManagerCache {
+	return &ManagerCache{managers: make(map[apitypes.NamespacedName]cachedManager)}
+}
+
+// Get returns the manager cached for the current generation of cr.
+func (c *ManagerCache) Get(cr *unstructured.Unstructured) (Manager, bool) {
+	c.mu.Lock()
+	entry, ok := c.managers[cacheKey(cr)]
+	c.mu.Unlock()
+
+	// removing the handled reconcile request does not change the manager
+	requested := cr.GetAnnotations()[ReconcileRequestAnnotation]
+
+	if !ok || entry.uid != cr.GetUID() || entry.generation != cr.GetGeneration() ||
+		entry.pinned != cr.GetAnnotations()[PinnedRevisionsAnnotation] ||
+		(requested != "" && entry.requested != requested) {
+		return nil, false
+	}
+
+	chrt, digest, err := loadChart(entry.manager.chartPath)
+	if err != nil || digest == "" || digest != entry.manager.chartDigest {
+		return nil, false
+	}
+
+	// only the state computed by Sync is reset, the rest is immutable
+	m := *entry.manager
+	m.chart = chrt
+	m.status = appv1.StatusFor(cr)
+	m.isInstalled = false
+	m.isUpgradeRequired = false
+	m.deployedRelease = nil
+	m.retryPending = retryPendingRequested(cr)
+
+	return &m, true
+}
+
+// Put caches the manager created for cr.
+func (c *ManagerCache) Put(cr *unstructured.Unstructured, m Manager) {
+	mgr, ok := m.(*manager)
+	if !ok || mgr.chartPath == "" || mgr.chartDigest == "" {
+		return
+	}
+
+	// the chart and the release of the reconcile are released once it is done
+	cached := *mgr
+	cached.chart = nil
+	cached.deployedRelease = nil
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if repo := appv1.RepoFor(cr); repo.KubeConfig != nil || repo.ServiceAccountName != "" {
+		delete(c.managers, cacheKey(cr))
+		return
+	}
+
+	c.managers[cacheKey(cr)] = cachedManager{
+		uid:        cr.GetUID(),
+		generation: cr.GetGeneration(),
+		pinned:     cr.GetAnnotations()[PinnedRevisionsAnnotation],
+		requested:  cr.GetAnnotations()[ReconcileRequestAnnotation],
+		manager:    &cached,
+	}
+}
+
+// Delete removes the manager of the custom resource name from the cache.
+func (c *ManagerCache) Delete(name apitypes.NamespacedName) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.managers, name)
+}
+
+func cacheKey(cr *unstructured.Unstructured) apitypes.NamespacedName {
+	return apitypes.NamespacedName{Namespace: cr.GetNamespace(), Name: cr.GetName()}
+}
@@ -0,0 +1,168 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	rpb "helm.sh/helm/v3/pkg/release"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	apitypes "k8s.io/apimachinery/pkg/types"
+)
+
+func newCacheTestCR(generation int64) *unstructured.Unstructured {
+	cr := &unstructured.Unstructured{Object: map[string]interface{}{}}
+	cr.SetNamespace("default")
+	cr.SetName("webapp")
+	cr.SetUID("uid-1")
+	cr.SetGeneration(generation)
+
+	return cr
+}
+
+// newCacheTestManager returns a manager of the test chart archive, copied to
+// a temporary directory.
+func newCacheTestManager(t *testing.T) *manager {
+	archive, err := ioutil.ReadFile("../../test/helmrepo/subscription-release-test-1-0.1.0.tgz")
+	require.NoError(t, err)
+
+	dir, err := ioutil.TempDir("", "managercache")
+	require.NoError(t, err)
+
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	path := filepath.Join(dir, "chart.tgz")
+	require.NoError(t, ioutil.WriteFile(path, archive, 0600))
+
+	chrt, digest, err := loadChart(path)
+	require.NoError(t, err)
+
+	return &manager{releaseName: "webapp", chart: chrt, chartPath: path, chartDigest: digest}
+}
+
+func TestManagerCache(t *testing.T) {
+	c := NewManagerCache()
+	cr := newCacheTestCR(1)
+	m := newCacheTestManager(t)
+
+	_, ok := c.Get(cr)
+	assert.False(t, ok)
+
+	m.isInstalled = true
+	m.isUpgradeRequired = true
+	m.deployedRelease = &rpb.Release{Name: "webapp"}
+	c.Put(cr, m)
+
+	// the state computed by Sync is not reused
+	cached, ok := c.Get(cr)
+	require.True(t, ok)
+	assert.Equal(t, "webapp", cached.ReleaseName())
+	assert.False(t, cached.IsInstalled())
+	assert.False(t, cached.IsUpgradeRequired())
+
+	// a new generation, a recreated custom resource, changed pinned
+	// revisions or a requested full reconcile need a new manager
+	_, ok = c.Get(newCacheTestCR(2))
+	assert.False(t, ok)
+
+	recreated := newCacheTestCR(1)
+	recreated.SetUID("uid-2")
+	_, ok = c.Get(recreated)
+	assert.False(t, ok)
+
+	pinned := newCacheTestCR(1)
+	pinned.SetAnnotations(map[string]string{PinnedRevisionsAnnotation: "1"})
+	_, ok = c.Get(pinned)
+	assert.False(t, ok)
+
+	requested := newCacheTestCR(1)
+	requested.SetAnnotations(map[string]string{ReconcileRequestAnnotation: "2020-11-20T10:00:00Z"})
+	_, ok = c.Get(requested)
+	assert.False(t, ok)
+
+	// removing the handled request keeps the manager
+	c.Put(requested, m)
+	_, ok = c.Get(cr)
+	assert.True(t, ok)
+
+	c.Delete(apitypes.NamespacedName{Namespace: "default", Name: "webapp"})
+	_, ok = c.Get(cr)
+	assert.False(t, ok)
+}
+
+func TestManagerCacheReloadsChart(t *testing.T) {
+	cr := newCacheTestCR(1)
+	m := newCacheTestManager(t)
+	chrt := m.chart
+
+	c := NewManagerCache()
+	c.Put(cr, m)
+
+	// the cached manager does not hold the chart
+	assert.Nil(t, c.managers[cacheKey(cr)].manager.chart)
+	assert.Same(t, chrt, m.chart)
+
+	cached, ok := c.Get(cr)
+	assert.True(t, ok)
+	assert.Equal(t, "subscription-release-test-1", cached.(*manager).chart.Name())
+
+	// a removed chart is downloaded again
+	assert.NoError(t, os.Remove(m.chartPath))
+
+	_, ok = c.Get(cr)
+	assert.False(t, ok)
+}
+
+func TestManagerCacheRetryPending(t *testing.T) {
+	c := NewManagerCache()
+	cr := newCacheTestCR(1)
+	cr.SetAnnotations(map[string]string{ReconcileRequestAnnotation: "2020-11-20T10:00:00Z"})
+
+	m := newCacheTestManager(t)
+	m.retryPending = true
+	c.Put(cr, m)
+
+	// the handled request does not retry the pending revision again
+	cr.Object["status"] = map[string]interface{}{"lastHandledReconcileAt": "2020-11-20T10:00:00Z"}
+
+	cached, ok := c.Get(cr)
+	require.True(t, ok)
+	assert.False(t, cached.(*manager).retryPending)
+}
+
+func TestManagerCacheSkipsRemoteReleases(t *testing.T) {
+	c := NewManagerCache()
+	m := newCacheTestManager(t)
+
+	for name, repo := range map[string]map[string]interface{}{
+		"remote":       {"kubeConfig": map[string]interface{}{"secretRef": map[string]interface{}{"name": "cluster"}}},
+		"impersonated": {"serviceAccountName": "deployer"},
+	} {
+		cr := newCacheTestCR(1)
+		c.Put(cr, m)
+
+		// the rest config may change behind the same generation
+		cr.Object["repo"] = repo
+		c.Put(cr, m)
+
+		_, ok := c.Get(cr)
+		assert.False(t, ok, name)
+	}
+}
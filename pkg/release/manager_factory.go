@@ -18,20 +18,20 @@ package release
 
 import (
 	"fmt"
+	"time"
 
 	"helm.sh/helm/v3/pkg/action"
-	"helm.sh/helm/v3/pkg/chart/loader"
 	"helm.sh/helm/v3/pkg/kube"
 	helmrelease "helm.sh/helm/v3/pkg/release"
 	"helm.sh/helm/v3/pkg/storage"
-	"helm.sh/helm/v3/pkg/storage/driver"
 	"helm.sh/helm/v3/pkg/strvals"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
-	v1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/rest"
 	crmanager "sigs.k8s.io/controller-runtime/pkg/manager"
 
 	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
 	"github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/client"
+	"github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/faults"
 )
 
 // ManagerFactory creates Managers that are specific to custom resources. It is
@@ -42,48 +42,167 @@ type ManagerFactory interface {
 	NewManager(r *unstructured.Unstructured, overrideValues map[string]string) (Manager, error)
 }
 
+// DefaultMaxHistory is the number of release revisions kept by default, like the helm CLI does.
+const DefaultMaxHistory = appv1.DefaultMaxHistory
+
 type managerFactory struct {
-	mgr      crmanager.Manager
-	chartDir string
+	mgr           crmanager.Manager
+	chartDir      string
+	storage       StorageOptions
+	client        ClientOptions
+	patchStrategy appv1.PatchStrategyEnum
 }
 
 // NewManagerFactory returns a new Helm manager factory capable of installing and uninstalling releases.
-func NewManagerFactory(mgr crmanager.Manager, chartDir string) ManagerFactory {
-	return &managerFactory{mgr, chartDir}
+// The requests of the releases are sent with the rate limits and timeout of clientOpts. The resources
+// of the kinds without a patch strategy of their own are updated with patchStrategy, or as helm does
+// if it is empty.
+func NewManagerFactory(mgr crmanager.Manager, chartDir string, storageOpts StorageOptions,
+	clientOpts ClientOptions, patchStrategy appv1.PatchStrategyEnum) ManagerFactory {
+	return &managerFactory{mgr, chartDir, storageOpts, clientOpts, patchStrategy}
 }
 
 func (f managerFactory) NewManager(cr *unstructured.Unstructured, overrideValues map[string]string) (Manager, error) {
-	// Get both v2 and v3 storage backends
-	clientv1, err := v1.NewForConfig(f.mgr.GetConfig())
+	repo := appv1.RepoFor(cr)
+
+	storageNamespace := cr.GetNamespace()
+	if repo.StorageNamespace != "" {
+		storageNamespace = repo.StorageNamespace
+	}
+
+	cfg := f.mgr.GetConfig()
+	restMapper := f.mgr.GetRESTMapper()
+
+	remote, err := RemoteConfig(f.mgr.GetAPIReader(), cr.GetNamespace(), repo.KubeConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	if remote != nil {
+		cfg = remote
+
+		if restMapper, err = client.SharedRESTMapper(cfg); err != nil {
+			return nil, fmt.Errorf("failed to get remote cluster REST mapper: %w", err)
+		}
+	}
+
+	cfg = f.client.Apply(cfg)
+
+	// the release lock is taken by the operator itself, not the ServiceAccount
+	// of the release
+	lockCfg := cfg
+
+	// every request of the release is sent as the ServiceAccount, so that its
+	// RBAC bounds what the release can do
+	if repo.ServiceAccountName != "" {
+		cfg = rest.CopyConfig(cfg)
+		cfg.Impersonate = rest.ImpersonationConfig{
+			UserName: fmt.Sprintf("system:serviceaccount:%s:%s", cr.GetNamespace(), repo.ServiceAccountName),
+		}
+	}
+
+	storageBackend, err := newStorage(cfg, f.storage, storageNamespace)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get core/v1 client: %w", err)
+		return nil, err
+	}
+
+	maxHistory := DefaultMaxHistory
+	if repo.MaxHistory != nil {
+		maxHistory = *repo.MaxHistory
+	}
+
+	storageBackend.MaxHistory = maxHistory
+
+	labelRecord, err := newRecordLabeler(cfg, f.storage, storageNamespace, cr.GetName(), cr.GetNamespace())
+	if err != nil {
+		return nil, err
+	}
+
+	targetNamespace := cr.GetNamespace()
+	if repo.TargetNamespace != "" {
+		targetNamespace = repo.TargetNamespace
 	}
-	storageBackend := storage.Init(driver.NewSecrets(clientv1.Secrets(cr.GetNamespace())))
 
 	// Get the necessary clients and client getters. Use a client that injects the CR
-	// as an owner reference into all resources templated by the chart.
-	rcg, err := client.NewRESTClientGetter(f.mgr, cr.GetNamespace())
+	// as an owner reference into all resources templated by the chart. The owner
+	// references are only injected in the resources of the CR namespace.
+	rcg, err := client.NewRESTClientGetterForConfig(cfg, restMapper, targetNamespace)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get REST client getter from manager: %w", err)
 	}
 
 	kubeClient := kube.New(rcg)
-	restMapper := f.mgr.GetRESTMapper()
-	ownerRefClient, err := client.NewOwnerRefInjectingClient(*kubeClient, restMapper, cr)
+
+	// the CR does not exist in a remote cluster, its garbage collector would
+	// delete the resources referencing it
+	var ownerRefClient kube.Interface = kubeClient
+
+	if remote == nil {
+		ownerRefClient, err = client.NewOwnerRefInjectingClient(*kubeClient, restMapper, cr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to inject owner references: %w", err)
+		}
+	}
+
+	if repo.ServerSideApply {
+		ownerRefClient = newServerSideApplyClient(ownerRefClient, repo.FieldManager, repo.ConflictPolicy)
+	} else if len(repo.PatchStrategies) > 0 || f.patchStrategy != "" {
+		ownerRefClient = newPatchStrategyClient(ownerRefClient, repo.PatchStrategies, f.patchStrategy, repo.FieldManager)
+	}
+
+	if !repo.PruneEnabled() {
+		ownerRefClient = newNoPruneClient(ownerRefClient)
+	}
+
+	if len(repo.ResourceTimeouts) > 0 || len(repo.WaitExclusions) > 0 {
+		ownerRefClient = newWaitClient(ownerRefClient, repo.ResourceTimeouts, repo.WaitExclusions)
+	}
+
+	var progress *rolloutProgress
+
+	if repo.Wait {
+		progress = &rolloutProgress{}
+		ownerRefClient = newProgressClient(ownerRefClient, progress)
+	}
+
+	var timeout time.Duration
+
+	switch {
+	case repo.Timeout != nil:
+		timeout = repo.Timeout.Duration
+	case repo.Wait:
+		timeout = DefaultWaitTimeout
+	}
+
+	// the waves are waited for with the progress and the timeouts of their kinds
+	ownerRefClient = newWaveClient(ownerRefClient, repo.SyncWaves, timeout)
+
+	if faults.Enabled {
+		ownerRefClient = newFaultClient(ownerRefClient, cr.GetNamespace()+"/"+cr.GetName())
+	}
+
+	// the archives of the helm repo charts are loaded as is, without being
+	// expanded, and the identical charts are only held once
+	crChart, chartDigest, err := loadChart(f.chartDir)
 	if err != nil {
-		return nil, fmt.Errorf("failed to inject owner references: %w", err)
+		return nil, fmt.Errorf("failed to load chart: %w", err)
 	}
 
-	crChart, err := loader.LoadDir(f.chartDir)
+	recordOwner, err := newRecordOwner(cfg, f.storage, storageNamespace)
 	if err != nil {
-		return nil, fmt.Errorf("failed to load chart dir: %w", err)
+		return nil, err
 	}
 
-	releaseName, err := getReleaseName(storageBackend, crChart.Name(), cr)
+	releaseName, err := getReleaseName(storageBackend, recordOwner, crChart.Name(), cr, repo.ReleaseName, targetNamespace)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get helm release name: %w", err)
 	}
 
+	lock, err := newReleaseLocker(lockCfg, storageNamespace, releaseName)
+	if err != nil {
+		return nil, err
+	}
+
 	crValues, ok := cr.Object["spec"].(map[string]interface{})
 	if !ok {
 		return nil, fmt.Errorf("failed to get spec: expected map[string]interface{}")
@@ -95,6 +214,18 @@ func (f managerFactory) NewManager(cr *unstructured.Unstructured, overrideValues
 	}
 	values := mergeMaps(crValues, expOverrides)
 
+	pinnedRevisions, err := parsePinnedRevisions(cr.GetAnnotations()[PinnedRevisionsAnnotation])
+	if err != nil {
+		return nil, err
+	}
+
+	digest, err := releaseDigest(crChart, values, targetNamespace, repo.IgnoreDifferences, postRenderSettings(repo))
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute release digest: %w", err)
+	}
+
+	postRenderer := newPostRenderer(repo, targetNamespace)
+
 	actionConfig := &action.Configuration{
 		RESTClientGetter: rcg,
 		Releases:         storageBackend,
@@ -106,13 +237,30 @@ func (f managerFactory) NewManager(cr *unstructured.Unstructured, overrideValues
 		actionConfig:   actionConfig,
 		storageBackend: storageBackend,
 		kubeClient:     ownerRefClient,
+		labelRecord:    labelRecord,
+		lock:           lock,
+		progress:       progress,
 
 		releaseName: releaseName,
-		namespace:   cr.GetNamespace(),
+		namespace:   targetNamespace,
 
-		chart:  crChart,
-		values: values,
-		status: appv1.StatusFor(cr),
+		chart:             crChart,
+		chartPath:         f.chartDir,
+		chartDigest:       chartDigest,
+		postRenderer:      postRenderer,
+		values:            values,
+		secrets:           secretValues(values),
+		status:            appv1.StatusFor(cr),
+		ignoreDifferences: repo.IgnoreDifferences,
+		fieldManager:      repo.FieldManager,
+		historyCompaction: repo.HistoryCompaction,
+		pinnedRevisions:   pinnedRevisions,
+		maxHistory:        maxHistory,
+		digest:            digest,
+		wait:              repo.Wait,
+		timeout:           timeout,
+		pendingPolicy:     repo.PendingReleasePolicy,
+		retryPending:      retryPendingRequested(cr),
 	}, nil
 }
 
@@ -127,15 +275,17 @@ func (f managerFactory) NewManager(cr *unstructured.Unstructured, overrideValues
 // because Kubernetes allows instances of different types to have the same name
 // in the same namespace.
 //
-// TODO(jlanford): As noted above, using the CR name as the release name raises
-//   the possibility of collision. We should move this logic to a validating
-//   admission webhook so that the CR owner receives immediate feedback of the
-//   collision. As is, the only indication of collision will be in the CR status
-//   and operator logs.
-func getReleaseName(storageBackend *storage.Storage, crChartName string,
-	cr *unstructured.Unstructured) (string, error) {
-	// If a release with the CR name does not exist, return the CR name.
-	releaseName := cr.GetName()
+// The release records are labeled with the namespace of their CR, a release
+// of a CR of another namespace sharing the storage namespace is a collision
+// too. The collisions are returned as ErrNameConflict.
+func getReleaseName(storageBackend *storage.Storage, owner recordOwner, crChartName string,
+	cr *unstructured.Unstructured, name, targetNamespace string) (string, error) {
+	// If a release with the CR name does not exist, return the CR name,
+	// shortened if helm would reject it, or the name set in the CR.
+	releaseName := name
+	if releaseName == "" {
+		releaseName = ReleaseNameFor(cr.GetName())
+	}
 	history, exists, err := releaseHistory(storageBackend, releaseName)
 	if err != nil {
 		return "", err
@@ -144,16 +294,50 @@ func getReleaseName(storageBackend *storage.Storage, crChartName string,
 		return releaseName, nil
 	}
 
+	latest := history[0]
+	for _, rel := range history {
+		if rel.Version > latest.Version {
+			latest = rel
+		}
+	}
+
+	// A storage namespace shared by several namespaces may hold the release
+	// of a CR with the same name in another namespace. Both would otherwise
+	// upgrade the same release in turn.
+	if owner != nil {
+		ownerNamespace, err := owner(latest)
+		if err != nil {
+			return "", err
+		}
+
+		if ownerNamespace != "" && ownerNamespace != cr.GetNamespace() {
+			return "", &ErrNameConflict{
+				ReleaseName: releaseName,
+				Detail:      fmt.Sprintf("the release belongs to HelmRelease %s/%s", ownerNamespace, releaseName),
+			}
+		}
+	}
+
 	// If a release name with the CR name exists, but the release's chart is
 	// different than the chart managed by this operator, return an error
 	// because something else created the existing release.
-	if history[0].Chart == nil {
+	if latest.Chart == nil {
 		return "", fmt.Errorf("could not find chart metadata in release with name %q", releaseName)
 	}
-	existingChartName := history[0].Chart.Name()
+	existingChartName := latest.Chart.Name()
 	if existingChartName != crChartName {
-		return "", fmt.Errorf("duplicate release name: found existing release with name %q for chart %q",
-			releaseName, existingChartName)
+		return "", &ErrNameConflict{
+			ReleaseName: releaseName,
+			Detail:      fmt.Sprintf("found existing release for chart %q", existingChartName),
+		}
+	}
+
+	// The target namespace of a release cannot be changed either.
+	if latest.Namespace != "" && latest.Namespace != targetNamespace {
+		return "", &ErrNameConflict{
+			ReleaseName: releaseName,
+			Detail:      fmt.Sprintf("found existing release in namespace %q", latest.Namespace),
+		}
 	}
 
 	return releaseName, nil
@@ -0,0 +1,82 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"helm.sh/helm/v3/pkg/chart"
+	rpb "helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/storage"
+	"helm.sh/helm/v3/pkg/storage/driver"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestGetReleaseName(t *testing.T) {
+	storageBackend := storage.Init(driver.NewMemory())
+
+	cr := &unstructured.Unstructured{}
+	cr.SetNamespace("team-a")
+	cr.SetName("webapp")
+
+	// no release yet
+	name, err := getReleaseName(storageBackend, nil, "webapp", cr, "", "team-a")
+	require.NoError(t, err)
+	assert.Equal(t, "webapp", name)
+
+	// the name set in the CR is used as is
+	name, err = getReleaseName(storageBackend, nil, "webapp", cr, "webapp-readable", "team-a")
+	require.NoError(t, err)
+	assert.Equal(t, "webapp-readable", name)
+
+	for _, version := range []int{1, 2} {
+		require.NoError(t, storageBackend.Create(&rpb.Release{
+			Name:      "webapp",
+			Namespace: "team-a",
+			Version:   version,
+			Info:      &rpb.Info{Status: rpb.StatusDeployed},
+			Chart:     &chart.Chart{Metadata: &chart.Metadata{Name: "webapp"}},
+		}))
+	}
+
+	owners := map[string]string{}
+	owner := func(rel *rpb.Release) (string, error) { return owners[recordKey(rel)], nil }
+
+	name, err = getReleaseName(storageBackend, owner, "webapp", cr, "", "team-a")
+	require.NoError(t, err)
+	assert.Equal(t, "webapp", name)
+
+	for desc, c := range map[string]struct {
+		chartName, targetNamespace, owner, detail string
+	}{
+		"other chart":     {"database", "team-a", "", `found existing release for chart "webapp"`},
+		"other target":    {"webapp", "team-b", "", `found existing release in namespace "team-a"`},
+		"other namespace": {"webapp", "team-a", "team-b", "the release belongs to HelmRelease team-b/webapp"},
+	} {
+		// the owner of the latest record is checked
+		owners[recordKey(&rpb.Release{Name: "webapp", Version: 2})] = c.owner
+
+		_, err = getReleaseName(storageBackend, owner, c.chartName, cr, "", c.targetNamespace)
+
+		var conflict *ErrNameConflict
+
+		require.True(t, errors.As(err, &conflict), "%s: unexpected error %v", desc, err)
+		assert.Equal(t, "webapp", conflict.ReleaseName)
+		assert.Equal(t, c.detail, conflict.Detail, desc)
+	}
+}
@@ -0,0 +1,163 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/ghodss/yaml"
+	"helm.sh/helm/v3/pkg/kube"
+	"helm.sh/helm/v3/pkg/releaseutil"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+// manifestResources parses a rendered release manifest into its resources
+// keyed by resourceKey. Empty documents are skipped.
+func manifestResources(manifest string) (map[string]*unstructured.Unstructured, error) {
+	resources := make(map[string]*unstructured.Unstructured)
+
+	for _, m := range releaseutil.SplitManifests(manifest) {
+		u := &unstructured.Unstructured{}
+		if err := yaml.Unmarshal([]byte(m), &u.Object); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal manifest: %w", err)
+		}
+
+		if len(u.Object) == 0 || u.GetKind() == "" {
+			continue
+		}
+
+		resources[resourceKey(u)] = u
+	}
+
+	return resources, nil
+}
+
+// resourceKey identifies a resource of a manifest. Like helm, the version of
+// the resource is not part of its identity.
+func resourceKey(u *unstructured.Unstructured) string {
+	return fmt.Sprintf("%s/%s/%s", u.GroupVersionKind().GroupKind(), u.GetNamespace(), u.GetName())
+}
+
+// RemovedResources returns the resources of the previous manifest that are no
+// longer part of the current manifest. Resources annotated with the helm keep
+// resource policy are not returned since helm does not delete them.
+func RemovedResources(previous, current string) ([]appv1.HelmAppResource, error) {
+	previousResources, err := manifestResources(previous)
+	if err != nil {
+		return nil, err
+	}
+
+	currentResources, err := manifestResources(current)
+	if err != nil {
+		return nil, err
+	}
+
+	var removed []appv1.HelmAppResource
+
+	for key, u := range previousResources {
+		if _, ok := currentResources[key]; ok {
+			continue
+		}
+
+		if u.GetAnnotations()[kube.ResourcePolicyAnno] == kube.KeepPolicy {
+			continue
+		}
+
+		removed = append(removed, resourceFor(u))
+	}
+
+	sortResources(removed)
+
+	return removed, nil
+}
+
+// OrphanedResources returns the live resources of the release that are not
+// part of its manifest. Resources without a namespace in the manifest are
+// expected in the release namespace.
+func OrphanedResources(manifest, namespace string, live []unstructured.Unstructured) ([]appv1.HelmAppResource, error) {
+	resources, err := manifestResources(manifest)
+	if err != nil {
+		return nil, err
+	}
+
+	expected := make(map[string]bool, len(resources))
+
+	for _, u := range resources {
+		if u.GetNamespace() == "" {
+			u.SetNamespace(namespace)
+		}
+
+		expected[resourceKey(u)] = true
+	}
+
+	// a resource served by several API groups is listed once per group, it is
+	// only orphaned if none of its representations is part of the manifest.
+	seen := make(map[string]bool)
+
+	var candidates []*unstructured.Unstructured
+
+	for i := range live {
+		id := string(live[i].GetUID())
+		if id == "" {
+			id = resourceKey(&live[i])
+		}
+
+		if expected[resourceKey(&live[i])] {
+			seen[id] = true
+			continue
+		}
+
+		candidates = append(candidates, &live[i])
+	}
+
+	var orphaned []appv1.HelmAppResource
+
+	for _, u := range candidates {
+		id := string(u.GetUID())
+		if id == "" {
+			id = resourceKey(u)
+		}
+
+		if seen[id] {
+			continue
+		}
+
+		seen[id] = true
+
+		orphaned = append(orphaned, resourceFor(u))
+	}
+
+	sortResources(orphaned)
+
+	return orphaned, nil
+}
+
+func resourceFor(u *unstructured.Unstructured) appv1.HelmAppResource {
+	return appv1.HelmAppResource{
+		APIVersion: u.GetAPIVersion(),
+		Kind:       u.GetKind(),
+		Namespace:  u.GetNamespace(),
+		Name:       u.GetName(),
+	}
+}
+
+func sortResources(resources []appv1.HelmAppResource) {
+	sort.Slice(resources, func(i, j int) bool {
+		return resources[i].String() < resources[j].String()
+	})
+}
@@ -0,0 +1,118 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+const testConfigMapManifest = `---
+# Source: test/templates/configmap.yaml
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: %s
+`
+
+const testKeptConfigMapManifest = `---
+# Source: test/templates/kept.yaml
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: kept
+  annotations:
+    helm.sh/resource-policy: keep
+`
+
+func TestRemovedResources(t *testing.T) {
+	deployment := fmt.Sprintf(testDeploymentManifest, "1", 1, "nginx:1.19")
+	previous := deployment + fmt.Sprintf(testConfigMapManifest, "a") + fmt.Sprintf(testConfigMapManifest, "b") +
+		testKeptConfigMapManifest
+
+	removed, err := RemovedResources(previous, previous)
+	assert.NoError(t, err)
+	assert.Empty(t, removed)
+
+	removed, err = RemovedResources(previous, deployment+fmt.Sprintf(testConfigMapManifest, "b"))
+	assert.NoError(t, err)
+	assert.Equal(t, []appv1.HelmAppResource{{APIVersion: "v1", Kind: "ConfigMap", Name: "a"}}, removed)
+
+	// a new apiVersion of the same kind is not a removal
+	current := fmt.Sprintf(testConfigMapManifest, "a") + fmt.Sprintf(testConfigMapManifest, "b") +
+		`---
+apiVersion: apps/v1beta2
+kind: Deployment
+metadata:
+  name: test
+`
+	removed, err = RemovedResources(previous, current)
+	assert.NoError(t, err)
+	assert.Empty(t, removed)
+}
+
+func TestOrphanedResources(t *testing.T) {
+	manifest := fmt.Sprintf(testDeploymentManifest, "1", 1, "nginx:1.19") + fmt.Sprintf(testConfigMapManifest, "a")
+
+	newLive := func(apiVersion, kind, name, uid string) unstructured.Unstructured {
+		u := unstructured.Unstructured{}
+		u.SetAPIVersion(apiVersion)
+		u.SetKind(kind)
+		u.SetNamespace("default")
+		u.SetName(name)
+		u.SetUID(types.UID(uid))
+
+		return u
+	}
+
+	live := []unstructured.Unstructured{
+		newLive("apps/v1", "Deployment", "test", "1"),
+		newLive("v1", "ConfigMap", "a", "2"),
+		newLive("v1", "ConfigMap", "b", "3"),
+		// the same deployment served by another API group
+		newLive("extensions/v1beta1", "Deployment", "test", "1"),
+	}
+
+	orphaned, err := OrphanedResources(manifest, "default", live)
+	assert.NoError(t, err)
+	assert.Equal(t, []appv1.HelmAppResource{{APIVersion: "v1", Kind: "ConfigMap", Namespace: "default", Name: "b"}}, orphaned)
+
+	orphaned, err = OrphanedResources(manifest, "other", live)
+	assert.NoError(t, err)
+	assert.Len(t, orphaned, 3)
+}
+
+func TestManifestObjects(t *testing.T) {
+	objects, err := manifestObjects(fmt.Sprintf(testConfigMapManifest, "first") + "---\n# empty\n" +
+		fmt.Sprintf(testConfigMapManifest, "second"))
+	assert.NoError(t, err)
+
+	// in the order of the manifest, without the empty documents
+	var names []string
+	for _, u := range objects {
+		names = append(names, u.GetName())
+	}
+
+	assert.Equal(t, []string{"first", "second"}, names)
+
+	_, err = manifestObjects("kind: [")
+	assert.Error(t, err)
+}
@@ -0,0 +1,176 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	rpb "helm.sh/helm/v3/pkg/release"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+// UnmanagedReleases returns the deployed releases of namespace, of all the
+// namespaces if it is empty, whose records do not belong to a HelmRelease,
+// e.g. the releases installed with the helm CLI. Their records are expected in
+// their namespace, as the helm CLI stores them. The records of the sql driver
+// are not labeled, all its deployed releases are returned.
+func UnmanagedReleases(cfg *rest.Config, opts StorageOptions, namespace string) ([]*rpb.Release, error) {
+	storageBackend, err := newStorage(cfg, opts, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	// selected by their labels instead of listing all the records
+	deployed, err := storageBackend.Query(map[string]string{"owner": "helm", "status": rpb.StatusDeployed.String()})
+	if err != nil && !notFoundErr(err) {
+		return nil, storageFailed(fmt.Errorf("failed to list deployed releases: %w", err))
+	}
+
+	owners := make(map[string]recordOwner)
+
+	var unmanaged []*rpb.Release
+
+	for _, rel := range deployed {
+		owner, ok := owners[rel.Namespace]
+		if !ok {
+			if owner, err = newRecordOwner(cfg, opts, rel.Namespace); err != nil {
+				return nil, err
+			}
+
+			owners[rel.Namespace] = owner
+		}
+
+		if owner != nil {
+			ownerNamespace, err := owner(rel)
+			if err != nil {
+				return nil, err
+			}
+
+			if ownerNamespace != "" {
+				continue
+			}
+		}
+
+		unmanaged = append(unmanaged, rel)
+	}
+
+	sort.Slice(unmanaged, func(i, j int) bool {
+		if unmanaged[i].Namespace != unmanaged[j].Namespace {
+			return unmanaged[i].Namespace < unmanaged[j].Namespace
+		}
+
+		return unmanaged[i].Name < unmanaged[j].Name
+	})
+
+	return unmanaged, nil
+}
+
+// MigratedHelmRelease returns the HelmRelease of namespace deploying the
+// release rel with the same chart and values, so that its first reconcile
+// renders the deployed manifest. The chart is downloaded from the helm repo
+// repoURL, where its archive is expected at <repoURL>/<chart>-<version>.tgz,
+// the layout of the helm repos. The release keeps its name and namespace, its
+// records are read from the release namespace.
+func MigratedHelmRelease(rel *rpb.Release, repoURL, namespace string) (*appv1.HelmRelease, error) {
+	if rel.Chart == nil || rel.Chart.Metadata == nil {
+		return nil, fmt.Errorf("could not find chart metadata in release %q", rel.Name)
+	}
+
+	if namespace == "" {
+		namespace = rel.Namespace
+	}
+
+	chart := rel.Chart.Metadata
+
+	hr := &appv1.HelmRelease{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: appv1.SchemeGroupVersion.String(),
+			Kind:       "HelmRelease",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      rel.Name,
+			Namespace: namespace,
+		},
+		Repo: appv1.HelmReleaseRepo{
+			Source: &appv1.Source{
+				SourceType: appv1.HelmRepoSourceType,
+				HelmRepo: &appv1.HelmRepo{
+					Urls: []string{fmt.Sprintf("%s/%s-%s.tgz", strings.TrimSuffix(repoURL, "/"), chart.Name, chart.Version)},
+				},
+			},
+			ChartName: chart.Name,
+			Version:   chart.Version,
+		},
+	}
+
+	if namespace != rel.Namespace {
+		hr.Repo.TargetNamespace = rel.Namespace
+		hr.Repo.StorageNamespace = rel.Namespace
+	}
+
+	// the values of the release are the ones set on install or upgrade, not
+	// the chart defaults
+	values := rel.Config
+	if values == nil {
+		values = map[string]interface{}{}
+	}
+
+	hr.Spec = values
+
+	return hr, nil
+}
+
+// AdoptReleaseRecords labels the records of the release name of the storage
+// namespace with the HelmRelease of namespace adopting it, e.g. a migrated
+// helm CLI release. It returns the number of labeled records, 0 with the sql
+// driver whose records have no labels.
+func AdoptReleaseRecords(cfg *rest.Config, opts StorageOptions, storageNamespace, name,
+	namespace string) (int, error) {
+	labelRecord, err := newRecordLabeler(cfg, opts, storageNamespace, name, namespace)
+	if err != nil || labelRecord == nil {
+		return 0, err
+	}
+
+	storageBackend, err := newStorage(cfg, opts, storageNamespace)
+	if err != nil {
+		return 0, err
+	}
+
+	history, err := storageBackend.History(name)
+	if err != nil {
+		if notFoundErr(err) {
+			return 0, nil
+		}
+
+		return 0, err
+	}
+
+	labeled := 0
+
+	for _, rel := range history {
+		if err := labelRecord(rel); err != nil {
+			return labeled, fmt.Errorf("failed to label release record %s: %w", recordKey(rel), err)
+		}
+
+		labeled++
+	}
+
+	return labeled, nil
+}
@@ -0,0 +1,57 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	cpb "helm.sh/helm/v3/pkg/chart"
+	rpb "helm.sh/helm/v3/pkg/release"
+)
+
+func TestMigratedHelmRelease(t *testing.T) {
+	rel := &rpb.Release{
+		Name:      "nginx",
+		Namespace: "web",
+		Chart:     &cpb.Chart{Metadata: &cpb.Metadata{Name: "nginx-ingress", Version: "1.41.0"}},
+		Config:    map[string]interface{}{"replicaCount": 2},
+	}
+
+	hr, err := MigratedHelmRelease(rel, "https://charts.example.com/stable/", "")
+	assert.NoError(t, err)
+	assert.Equal(t, "HelmRelease", hr.Kind)
+	assert.Equal(t, "nginx", hr.Name)
+	assert.Equal(t, "web", hr.Namespace)
+	assert.Equal(t, "nginx-ingress", hr.Repo.ChartName)
+	assert.Equal(t, "1.41.0", hr.Repo.Version)
+	assert.Equal(t, []string{"https://charts.example.com/stable/nginx-ingress-1.41.0.tgz"}, hr.Repo.Source.HelmRepo.Urls)
+	assert.Empty(t, hr.Repo.TargetNamespace)
+	assert.Empty(t, hr.Repo.StorageNamespace)
+	assert.Equal(t, rel.Config, hr.Spec)
+
+	// the release stays in its namespace
+	rel.Config = nil
+	hr, err = MigratedHelmRelease(rel, "https://charts.example.com/stable", "helmreleases")
+	assert.NoError(t, err)
+	assert.Equal(t, "helmreleases", hr.Namespace)
+	assert.Equal(t, "web", hr.Repo.TargetNamespace)
+	assert.Equal(t, "web", hr.Repo.StorageNamespace)
+	assert.Equal(t, map[string]interface{}{}, hr.Spec)
+
+	rel.Chart = nil
+	_, err = MigratedHelmRelease(rel, "https://charts.example.com/stable", "")
+	assert.Error(t, err)
+}
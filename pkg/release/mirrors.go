@@ -0,0 +1,118 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release
+
+import (
+	"bytes"
+	"strings"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+// dockerHub is the registry of the images without one, e.g. nginx:1.19
+const dockerHub = "docker.io"
+
+// mirrorRenderer is a helm post-renderer pulling the images of the rendered
+// workloads from the mirrors of their registries.
+type mirrorRenderer struct {
+	mirrors map[string]string
+}
+
+func newMirrorRenderer(mirrors []appv1.ImageMirror) mirrorRenderer {
+	r := mirrorRenderer{mirrors: make(map[string]string, len(mirrors))}
+
+	for _, m := range mirrors {
+		r.mirrors[normalizeRegistry(m.Registry)] = strings.TrimSuffix(m.Mirror, "/")
+	}
+
+	return r
+}
+
+func (r mirrorRenderer) Run(rendered *bytes.Buffer) (*bytes.Buffer, error) {
+	return bytes.NewBufferString(mirrorImages(rendered.String(), r.mirrors)), nil
+}
+
+// mirrorImages replaces the registries of the images of the containers of the
+// workloads of manifest, init and ephemeral containers included, with their
+// mirrors.
+func mirrorImages(manifest string, mirrors map[string]string) string {
+	return transformPodSpecs(manifest, func(spec map[string]interface{}) bool {
+		mirrored := false
+
+		for _, field := range []string{"containers", "initContainers", "ephemeralContainers"} {
+			containers, _ := spec[field].([]interface{})
+
+			for _, c := range containers {
+				container, ok := c.(map[string]interface{})
+				if !ok {
+					continue
+				}
+
+				image, ok := container["image"].(string)
+				if !ok || image == "" {
+					continue
+				}
+
+				if m := mirrorImage(image, mirrors); m != image {
+					container["image"] = m
+					mirrored = true
+				}
+			}
+		}
+
+		return mirrored
+	})
+}
+
+// mirrorImage returns image pulled from the mirror of its registry, image if
+// its registry has no mirror.
+func mirrorImage(image string, mirrors map[string]string) string {
+	registry, repository := splitImage(image)
+
+	mirror, ok := mirrors[registry]
+	if !ok {
+		return image
+	}
+
+	return mirror + "/" + repository
+}
+
+// splitImage splits image into its registry and its repository with its tag
+// or digest. The docker hub images are in docker.io, and their official
+// images in its library.
+func splitImage(image string) (registry, repository string) {
+	i := strings.Index(image, "/")
+	if i < 0 {
+		return dockerHub, "library/" + image
+	}
+
+	// the first component is a registry if it is a host
+	first := image[:i]
+	if !strings.ContainsAny(first, ".:") && first != "localhost" {
+		return dockerHub, image
+	}
+
+	return normalizeRegistry(first), image[i+1:]
+}
+
+// normalizeRegistry returns docker.io for the other hosts of the docker hub.
+func normalizeRegistry(registry string) string {
+	switch registry {
+	case "index.docker.io", "registry-1.docker.io":
+		return dockerHub
+	default:
+		return registry
+	}
+}
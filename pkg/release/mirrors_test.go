@@ -0,0 +1,70 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestMirrorImage(t *testing.T) {
+	mirrors := map[string]string{
+		"docker.io": "mirror.example.com/dockerhub",
+		"quay.io":   "mirror.example.com/quay",
+	}
+
+	tests := []struct {
+		image    string
+		mirrored string
+	}{
+		{"nginx:1.19", "mirror.example.com/dockerhub/library/nginx:1.19"},
+		{"bitnami/redis:6.0", "mirror.example.com/dockerhub/bitnami/redis:6.0"},
+		{"docker.io/bitnami/redis", "mirror.example.com/dockerhub/bitnami/redis"},
+		{"index.docker.io/library/nginx@sha256:abcd", "mirror.example.com/dockerhub/library/nginx@sha256:abcd"},
+		{"quay.io/prometheus/node-exporter:v1.0.1", "mirror.example.com/quay/prometheus/node-exporter:v1.0.1"},
+		{"gcr.io/google-containers/pause:3.2", "gcr.io/google-containers/pause:3.2"},
+		{"localhost:5000/nginx", "localhost:5000/nginx"},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.mirrored, mirrorImage(test.image, mirrors), test.image)
+	}
+}
+
+func TestMirrorImages(t *testing.T) {
+	deployment := fmt.Sprintf(testDeploymentManifest, "1", 1, "nginx:1.19")
+	configMap := fmt.Sprintf(testConfigMapManifest, "a")
+	mirrors := map[string]string{"docker.io": "mirror.example.com"}
+
+	mirrored := mirrorImages(deployment+configMap, mirrors)
+
+	objects, err := manifestObjects(mirrored)
+	assert.NoError(t, err)
+	assert.Len(t, objects, 2)
+
+	containers, _, _ := unstructured.NestedSlice(objects[0].Object, "spec", "template", "spec", "containers")
+	assert.Equal(t, "mirror.example.com/library/nginx:1.19", containers[0].(map[string]interface{})["image"])
+
+	// the comments and the other documents are kept
+	assert.True(t, strings.HasPrefix(mirrored, "---\n# Source: test/templates/deployment.yaml\n"))
+	assert.True(t, strings.HasSuffix(mirrored, configMap))
+
+	// the mirrored images are left as is
+	assert.Equal(t, mirrored, mirrorImages(mirrored, mirrors))
+}
@@ -0,0 +1,43 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// MaxReleaseNameLength is the longest release name helm accepts.
+const MaxReleaseNameLength = 53
+
+// releaseNameHashLength is the length of the hash suffix of a shortened
+// release name
+const releaseNameHashLength = 8
+
+// ReleaseNameFor returns the release name of a HelmRelease named name: name
+// itself, or name truncated and suffixed with a hash of the whole name if it
+// is longer than helm accepts, e.g. the first 44 characters of name, a dash
+// and 8 hex characters. The same name always gets the same release name.
+func ReleaseNameFor(name string) string {
+	if len(name) <= MaxReleaseNameLength {
+		return name
+	}
+
+	sum := sha256.Sum256([]byte(name))
+	prefix := strings.TrimRight(name[:MaxReleaseNameLength-releaseNameHashLength-1], "-.")
+
+	return prefix + "-" + hex.EncodeToString(sum[:])[:releaseNameHashLength]
+}
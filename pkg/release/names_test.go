@@ -0,0 +1,44 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+func TestReleaseNameFor(t *testing.T) {
+	assert.Equal(t, "nginx-ingress", ReleaseNameFor("nginx-ingress"))
+
+	exact := strings.Repeat("a", MaxReleaseNameLength)
+	assert.Equal(t, exact, ReleaseNameFor(exact))
+
+	long := "payments-platform-observability-stack-prometheus-operator-east"
+	name := ReleaseNameFor(long)
+
+	assert.Len(t, name, MaxReleaseNameLength)
+	assert.True(t, strings.HasPrefix(name, long[:44]+"-"))
+	assert.Equal(t, name, ReleaseNameFor(long))
+	assert.NotEqual(t, name, ReleaseNameFor(long+"-2"))
+	assert.Empty(t, validation.IsDNS1123Subdomain(name))
+
+	// the truncated name does not end with a separator
+	dashed := strings.Repeat("a", 43) + "-" + strings.Repeat("b", 20)
+	assert.True(t, strings.HasPrefix(ReleaseNameFor(dashed), strings.Repeat("a", 43)+"-"))
+	assert.Len(t, ReleaseNameFor(dashed), 52)
+}
@@ -0,0 +1,128 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release
+
+import (
+	"bytes"
+	"strings"
+
+	"helm.sh/helm/v3/pkg/postrender"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// namespaceRenderer is a helm post-renderer setting the namespace of the
+// rendered resources that set another one to the target namespace.
+type namespaceRenderer struct {
+	namespace string
+}
+
+func (r namespaceRenderer) Run(rendered *bytes.Buffer) (*bytes.Buffer, error) {
+	return bytes.NewBufferString(overrideNamespace(rendered.String(), r.namespace)), nil
+}
+
+// withNamespace returns postRenderer overriding the namespaces with namespace
+// instead, e.g. for a release rendered for another namespace.
+func withNamespace(postRenderer postrender.PostRenderer, namespace string) postrender.PostRenderer {
+	renderers, ok := postRenderer.(postRenderers)
+	if !ok {
+		return postRenderer
+	}
+
+	retargeted := make(postRenderers, len(renderers))
+
+	for i, r := range renderers {
+		if _, ok := r.(namespaceRenderer); ok {
+			r = namespaceRenderer{namespace}
+		}
+
+		retargeted[i] = r
+	}
+
+	return retargeted
+}
+
+// overrideNamespace sets the namespace of the resources of manifest that set
+// another one to namespace. The ServiceAccount subjects of the RoleBindings
+// and ClusterRoleBindings are only moved along with the ServiceAccounts of
+// manifest, the bindings of the other ServiceAccounts are kept.
+func overrideNamespace(manifest, namespace string) string {
+	// the ServiceAccounts of the chart, by their rendered namespace and name
+	accounts := map[string]bool{}
+
+	transformDocuments(manifest, func(u map[string]interface{}) bool {
+		obj := &unstructured.Unstructured{Object: u}
+		if obj.GetAPIVersion() == "v1" && obj.GetKind() == "ServiceAccount" {
+			accounts[obj.GetNamespace()+"/"+obj.GetName()] = true
+		}
+
+		return false
+	})
+
+	return transformDocuments(manifest, func(u map[string]interface{}) bool {
+		obj := &unstructured.Unstructured{Object: u}
+		overridden := false
+
+		if ns := obj.GetNamespace(); ns != "" && ns != namespace {
+			obj.SetNamespace(namespace)
+
+			overridden = true
+		}
+
+		if isRoleBinding(obj) {
+			overridden = overrideSubjects(obj, namespace, accounts) || overridden
+		}
+
+		return overridden
+	})
+}
+
+func isRoleBinding(obj *unstructured.Unstructured) bool {
+	return strings.HasPrefix(obj.GetAPIVersion(), "rbac.authorization.k8s.io/") &&
+		(obj.GetKind() == "RoleBinding" || obj.GetKind() == "ClusterRoleBinding")
+}
+
+// overrideSubjects sets the namespace of the subjects of the binding that are
+// ServiceAccounts of accounts and returns true if any changed.
+func overrideSubjects(binding *unstructured.Unstructured, namespace string, accounts map[string]bool) bool {
+	subjects, found, err := unstructured.NestedSlice(binding.Object, "subjects")
+	if !found || err != nil {
+		return false
+	}
+
+	overridden := false
+
+	for _, s := range subjects {
+		subject, ok := s.(map[string]interface{})
+		if !ok || subject["kind"] != "ServiceAccount" {
+			continue
+		}
+
+		ns, _ := subject["namespace"].(string)
+		name, _ := subject["name"].(string)
+
+		if ns == "" || ns == namespace || !accounts[ns+"/"+name] {
+			continue
+		}
+
+		subject["namespace"] = namespace
+		overridden = true
+	}
+
+	if !overridden {
+		return false
+	}
+
+	return unstructured.SetNestedSlice(binding.Object, subjects, "subjects") == nil
+}
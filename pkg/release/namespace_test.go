@@ -0,0 +1,89 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const testNamespacedManifest = `---
+# Source: test/templates/serviceaccount.yaml
+apiVersion: v1
+kind: ServiceAccount
+metadata:
+  name: controller
+  namespace: hardcoded
+---
+# Source: test/templates/rolebinding.yaml
+apiVersion: rbac.authorization.k8s.io/v1
+kind: RoleBinding
+metadata:
+  name: controller
+  namespace: hardcoded
+roleRef:
+  apiGroup: rbac.authorization.k8s.io
+  kind: Role
+  name: controller
+subjects:
+- kind: ServiceAccount
+  name: controller
+  namespace: hardcoded
+- kind: ServiceAccount
+  name: monitoring
+  namespace: hardcoded
+`
+
+func TestOverrideNamespace(t *testing.T) {
+	configMap := fmt.Sprintf(testConfigMapManifest, "a")
+
+	overridden := overrideNamespace(testNamespacedManifest+configMap, "target")
+
+	objects, err := manifestObjects(overridden)
+	assert.NoError(t, err)
+	assert.Len(t, objects, 3)
+
+	for _, u := range objects {
+		switch u.GetKind() {
+		case "ServiceAccount", "RoleBinding":
+			assert.Equal(t, "target", u.GetNamespace())
+		default:
+			// the resources without a namespace are left to helm
+			assert.Empty(t, u.GetNamespace())
+		}
+	}
+
+	// only the subjects of the ServiceAccounts of the chart are moved
+	var binding *unstructured.Unstructured
+
+	for _, u := range objects {
+		if u.GetKind() == "RoleBinding" {
+			binding = u
+		}
+	}
+
+	subjects, _, _ := unstructured.NestedSlice(binding.Object, "subjects")
+	assert.Equal(t, "target", subjects[0].(map[string]interface{})["namespace"])
+	assert.Equal(t, "hardcoded", subjects[1].(map[string]interface{})["namespace"])
+
+	assert.True(t, strings.HasSuffix(overridden, configMap))
+
+	// the manifests already in the target namespace are kept as is
+	assert.Equal(t, overridden, overrideNamespace(overridden, "target"))
+}
@@ -0,0 +1,132 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chartutil"
+	kubefake "helm.sh/helm/v3/pkg/kube/fake"
+	rpb "helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/storage"
+	"helm.sh/helm/v3/pkg/storage/driver"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+// ChartRenderOptions customize the rendering of a chart without a cluster.
+type ChartRenderOptions struct {
+	// ReleaseName is the name of the release, ReleaseNameFor the name of the
+	// HelmRelease by default.
+	ReleaseName string
+	// Namespace is the target namespace of the release.
+	Namespace string
+	// Capabilities are the capabilities of the target cluster seen by the
+	// templates, the helm defaults if nil.
+	Capabilities *chartutil.Capabilities
+	// RESTMapper returns the scope of the kinds not defined by the chart. The
+	// built-in kinds are known without it, the others are assumed namespaced.
+	RESTMapper meta.RESTMapper
+}
+
+// clusterScopedKinds are the built-in cluster-scoped kinds, known without a
+// cluster.
+var clusterScopedKinds = map[schema.GroupKind]bool{
+	{Kind: "Namespace"}:        true,
+	{Kind: "Node"}:             true,
+	{Kind: "PersistentVolume"}: true,
+	{Group: "rbac.authorization.k8s.io", Kind: "ClusterRole"}:                       true,
+	{Group: "rbac.authorization.k8s.io", Kind: "ClusterRoleBinding"}:                true,
+	{Group: "apiextensions.k8s.io", Kind: "CustomResourceDefinition"}:               true,
+	{Group: "apiregistration.k8s.io", Kind: "APIService"}:                           true,
+	{Group: "admissionregistration.k8s.io", Kind: "MutatingWebhookConfiguration"}:   true,
+	{Group: "admissionregistration.k8s.io", Kind: "ValidatingWebhookConfiguration"}: true,
+	{Group: "storage.k8s.io", Kind: "StorageClass"}:                                 true,
+	{Group: "storage.k8s.io", Kind: "CSIDriver"}:                                    true,
+	{Group: "storage.k8s.io", Kind: "CSINode"}:                                      true,
+	{Group: "storage.k8s.io", Kind: "VolumeAttachment"}:                             true,
+	{Group: "scheduling.k8s.io", Kind: "PriorityClass"}:                             true,
+	{Group: "node.k8s.io", Kind: "RuntimeClass"}:                                    true,
+	{Group: "networking.k8s.io", Kind: "IngressClass"}:                              true,
+	{Group: "policy", Kind: "PodSecurityPolicy"}:                                    true,
+	{Group: "certificates.k8s.io", Kind: "CertificateSigningRequest"}:               true,
+	{Group: "flowcontrol.apiserver.k8s.io", Kind: "FlowSchema"}:                     true,
+	{Group: "flowcontrol.apiserver.k8s.io", Kind: "PriorityLevelConfiguration"}:     true,
+}
+
+// staticScope returns the scope of the built-in kinds, the other kinds are
+// assumed to be namespaced custom resources.
+func staticScope(gvk schema.GroupVersionKind) (bool, error) {
+	return !clusterScopedKinds[gvk.GroupKind()], nil
+}
+
+// RenderChart renders chrt with values as the release of the HelmRelease
+// named name with repo, through the same post-render chain, without a
+// cluster. It returns the rendered release and its resources in install
+// order, the CRDs of the chart first. The namespaced resources without a
+// namespace are set to the target namespace. The hooks are not rendered and
+// the lookup template function finds nothing.
+func RenderChart(chrt *chart.Chart, name string, repo *appv1.HelmReleaseRepo, values map[string]interface{},
+	opts ChartRenderOptions) (*rpb.Release, []*unstructured.Unstructured, error) {
+	releaseName := opts.ReleaseName
+	if releaseName == "" {
+		releaseName = ReleaseNameFor(name)
+	}
+
+	capabilities := opts.Capabilities
+	if capabilities == nil {
+		capabilities = chartutil.DefaultCapabilities
+	}
+
+	memory := driver.NewMemory()
+	memory.SetNamespace(opts.Namespace)
+
+	actionConfig := &action.Configuration{
+		Releases:     storage.Init(memory),
+		KubeClient:   &kubefake.PrintingKubeClient{Out: ioutil.Discard},
+		Capabilities: capabilities,
+		Log:          func(_ string, _ ...interface{}) {},
+	}
+
+	install := action.NewInstall(actionConfig)
+	install.ReleaseName = releaseName
+	install.Namespace = opts.Namespace
+	install.DryRun = true
+	install.PostRenderer = newPostRenderer(repo, opts.Namespace)
+
+	rel, err := install.Run(chrt, values)
+	if err != nil {
+		m := manager{secrets: secretValues(values)}
+		return nil, nil, renderFailed(m.redactError(fmt.Errorf("failed to render release: %w", err)))
+	}
+
+	scope := staticScope
+	if opts.RESTMapper != nil {
+		scope = mapperScope(opts.RESTMapper)
+	}
+
+	objects, err := renderedObjects(chrt, rel.Manifest, opts.Namespace, scope)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return rel, objects, nil
+}
@@ -0,0 +1,133 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chartutil"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+const offlineTemplate = `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: {{ .Release.Name }}
+data:
+  greeting: {{ .Values.greeting }}
+  kubeVersion: {{ .Capabilities.KubeVersion.Version }}
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata:
+  name: {{ .Release.Name }}-reader
+rules: []
+---
+apiVersion: example.com/v1
+kind: Widget
+metadata:
+  name: {{ .Release.Name }}
+`
+
+const offlineCRD = `apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: gadgets.example.com
+spec:
+  group: example.com
+  scope: Cluster
+  names:
+    kind: Gadget
+    plural: gadgets
+`
+
+func offlineChart() *chart.Chart {
+	return &chart.Chart{
+		Metadata: &chart.Metadata{APIVersion: "v2", Name: "offline", Version: "0.1.0"},
+		Templates: []*chart.File{
+			{Name: "templates/resources.yaml", Data: []byte(offlineTemplate)},
+			{Name: "templates/gadget.yaml", Data: []byte("apiVersion: example.com/v1\nkind: Gadget\nmetadata:\n  name: gadget\n")},
+		},
+		Files: []*chart.File{
+			{Name: "crds/gadgets.yaml", Data: []byte(offlineCRD)},
+		},
+		Values: map[string]interface{}{"greeting": "hello"},
+	}
+}
+
+func TestRenderChart(t *testing.T) {
+	repo := &appv1.HelmReleaseRepo{CommonLabels: map[string]string{"team": "payments"}}
+
+	rel, objects, err := RenderChart(offlineChart(), "web", repo, map[string]interface{}{"greeting": "hi"},
+		ChartRenderOptions{Namespace: "apps"})
+	require.NoError(t, err)
+
+	assert.Equal(t, "web", rel.Name)
+	assert.Equal(t, "apps", rel.Namespace)
+
+	kinds := []string{}
+	namespaces := map[string]string{}
+
+	for _, u := range objects {
+		kinds = append(kinds, u.GetKind())
+		namespaces[u.GetKind()] = u.GetNamespace()
+	}
+
+	// the CRDs first, then the install order
+	assert.Equal(t, "CustomResourceDefinition", kinds[0])
+	assert.ElementsMatch(t, []string{"CustomResourceDefinition", "ConfigMap", "ClusterRole", "Widget", "Gadget"}, kinds)
+
+	assert.Equal(t, "apps", namespaces["ConfigMap"])
+	assert.Equal(t, "apps", namespaces["Widget"])
+	assert.Empty(t, namespaces["ClusterRole"])
+	assert.Empty(t, namespaces["Gadget"], "the scope of the kinds of the chart is the one of their CRD")
+
+	for _, u := range objects {
+		if u.GetKind() == "ConfigMap" {
+			assert.Equal(t, "hi", u.Object["data"].(map[string]interface{})["greeting"])
+			assert.Equal(t, chartutil.DefaultCapabilities.KubeVersion.Version,
+				u.Object["data"].(map[string]interface{})["kubeVersion"])
+			assert.Equal(t, "payments", u.GetLabels()["team"])
+		}
+	}
+}
+
+func TestRenderChartCapabilities(t *testing.T) {
+	capabilities := *chartutil.DefaultCapabilities
+	capabilities.KubeVersion = chartutil.KubeVersion{Version: "v1.17.4", Major: "1", Minor: "17"}
+
+	_, objects, err := RenderChart(offlineChart(), "web", &appv1.HelmReleaseRepo{}, nil,
+		ChartRenderOptions{ReleaseName: "preview", Namespace: "apps", Capabilities: &capabilities})
+	require.NoError(t, err)
+
+	for _, u := range objects {
+		if u.GetKind() == "ConfigMap" {
+			assert.Equal(t, "preview", u.GetName())
+			assert.Equal(t, "v1.17.4", u.Object["data"].(map[string]interface{})["kubeVersion"])
+		}
+	}
+
+	c := offlineChart()
+	c.Metadata.KubeVersion = ">= 1.19"
+
+	_, _, err = RenderChart(c, "web", &appv1.HelmReleaseRepo{}, nil,
+		ChartRenderOptions{Namespace: "apps", Capabilities: &capabilities})
+	assert.True(t, errors.Is(err, ErrRenderFailed))
+}
@@ -0,0 +1,290 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	jsonpatch "gomodules.xyz/jsonpatch/v3"
+	"helm.sh/helm/v3/pkg/kube"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	apitypes "k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/jsonmergepatch"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+	"k8s.io/cli-runtime/pkg/resource"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+var _ kube.Interface = &patchStrategyClient{}
+
+// patchStrategyClient updates the resources whose kind has a configured patch
+// strategy, or all of them with a default strategy, itself and leaves all other
+// resources to the wrapped client.
+type patchStrategyClient struct {
+	kube.Interface
+	strategies      []appv1.PatchStrategy
+	defaultStrategy appv1.PatchStrategyEnum
+	fieldManager    string
+}
+
+func newPatchStrategyClient(base kube.Interface, strategies []appv1.PatchStrategy,
+	defaultStrategy appv1.PatchStrategyEnum, fieldManager string) kube.Interface {
+	if fieldManager == "" {
+		fieldManager = DefaultFieldManager
+	}
+
+	return &patchStrategyClient{
+		Interface:       base,
+		strategies:      strategies,
+		defaultStrategy: defaultStrategy,
+		fieldManager:    fieldManager,
+	}
+}
+
+// strategyFor returns the patch strategy configured for the kind of info, or
+// the default one.
+func (c *patchStrategyClient) strategyFor(info *resource.Info) (appv1.PatchStrategyEnum, bool) {
+	gvk := info.Object.GetObjectKind().GroupVersionKind()
+
+	for _, s := range c.strategies {
+		if !strings.EqualFold(s.Kind, gvk.Kind) {
+			continue
+		}
+
+		if s.APIVersion != "" && s.APIVersion != gvk.GroupVersion().String() {
+			continue
+		}
+
+		return s.Strategy, true
+	}
+
+	return c.defaultStrategy, c.defaultStrategy != ""
+}
+
+// Update updates the resources with a configured patch strategy and delegates
+// the remaining resources, including the deletion of resources no longer
+// part of target, to the wrapped client.
+func (c *patchStrategyClient) Update(original, target kube.ResourceList, force bool) (*kube.Result, error) {
+	overridden := target.Filter(func(info *resource.Info) bool {
+		_, ok := c.strategyFor(info)
+		return ok
+	})
+
+	if len(overridden) == 0 {
+		return c.Interface.Update(original, target, force)
+	}
+
+	res, err := c.Interface.Update(original.Difference(overridden), target.Difference(overridden), force)
+	if err != nil {
+		return res, err
+	}
+
+	for _, info := range overridden {
+		strategy, _ := c.strategyFor(info)
+
+		created, err := updateWithStrategy(original.Get(info), info, strategy, c.fieldManager)
+		if err != nil {
+			return res, err
+		}
+
+		if created {
+			res.Created = append(res.Created, info)
+		} else {
+			res.Updated = append(res.Updated, info)
+		}
+	}
+
+	return res, nil
+}
+
+// updateWithStrategy creates the resource of info if it does not exist yet,
+// otherwise it updates it using the given strategy. The fields of original,
+// the previously rendered resource if any, that are no longer rendered are
+// removed. It returns true if the resource was created. Patches are recorded
+// under fieldManager.
+func updateWithStrategy(original, info *resource.Info, strategy appv1.PatchStrategyEnum,
+	fieldManager string) (bool, error) {
+	kind := info.Object.GetObjectKind().GroupVersionKind().Kind
+	helper := resource.NewHelper(info.Client, info.Mapping)
+
+	live := *info
+	if err := live.Get(); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return false, fmt.Errorf("failed to get %s %q: %w", kind, info.Name, err)
+		}
+
+		obj, err := helper.Create(info.Namespace, true, info.Object)
+		if err != nil {
+			return false, fmt.Errorf("failed to create %s %q: %w", kind, info.Name, err)
+		}
+
+		return true, info.Refresh(obj, true)
+	}
+
+	if strategy == appv1.ReplacePatchStrategy {
+		if err := meta.NewAccessor().SetResourceVersion(info.Object, live.ResourceVersion); err != nil {
+			return false, err
+		}
+
+		obj, err := helper.Replace(info.Namespace, info.Name, true, info.Object)
+		if err != nil {
+			return false, fmt.Errorf("failed to replace %s %q: %w", kind, info.Name, err)
+		}
+
+		return false, info.Refresh(obj, true)
+	}
+
+	var originalObject runtime.Object
+	if original != nil {
+		originalObject = original.Object
+	}
+
+	patch, patchType, err := createPatchWithStrategy(originalObject, live.Object, info, strategy)
+	if err != nil {
+		return false, fmt.Errorf("failed to create patch for %s %q: %w", kind, info.Name, err)
+	}
+
+	// nothing to patch
+	if patch == nil || string(patch) == "{}" {
+		return false, nil
+	}
+
+	obj, err := helper.Patch(info.Namespace, info.Name, patchType, patch, &metav1.PatchOptions{FieldManager: fieldManager})
+	if err != nil {
+		return false, fmt.Errorf("failed to patch %s %q: %w", kind, info.Name, err)
+	}
+
+	return false, info.Refresh(obj, true)
+}
+
+// createPatchWithStrategy is createPatch with the patch type chosen by the
+// caller instead of being derived from the type of the object. The fields of
+// original missing from expected are removed, original is nil if the resource
+// was not rendered before.
+func createPatchWithStrategy(original, existing runtime.Object, expected *resource.Info,
+	strategy appv1.PatchStrategyEnum) ([]byte, apitypes.PatchType, error) {
+	existingJSON, err := json.Marshal(existing)
+	if err != nil {
+		return nil, "", err
+	}
+
+	expectedJSON, err := json.Marshal(expected.Object)
+	if err != nil {
+		return nil, "", err
+	}
+
+	originalJSON := expectedJSON
+	if original != nil {
+		if originalJSON, err = json.Marshal(original); err != nil {
+			return nil, "", err
+		}
+	}
+
+	switch strategy {
+	case appv1.StrategicMergePatchStrategy:
+		patchMeta, err := strategicpatch.NewPatchMetaFromStruct(kube.AsVersioned(expected))
+		if err != nil {
+			return nil, apitypes.StrategicMergePatchType, err
+		}
+
+		patch, err := strategicpatch.CreateThreeWayMergePatch(originalJSON, expectedJSON, existingJSON, patchMeta, true)
+
+		return patch, apitypes.StrategicMergePatchType, err
+	case appv1.MergePatchStrategy:
+		patch, err := jsonmergepatch.CreateThreeWayJSONMergePatch(originalJSON, expectedJSON, existingJSON)
+
+		return patch, apitypes.MergePatchType, err
+	case appv1.JSONPatchStrategy:
+		patch, err := createJSONPatch(originalJSON, existingJSON, expectedJSON)
+
+		return patch, apitypes.JSONPatchType, err
+	default:
+		return nil, "", fmt.Errorf("patch strategy %q unsupported", strategy)
+	}
+}
+
+// createJSONPatch returns the RFC 6902 patch updating existing to expected,
+// nil if there is nothing to update. The fields of existing missing from
+// expected are only removed if they are part of original: the others were
+// added by Kubernetes or by the users.
+func createJSONPatch(originalJSON, existingJSON, expectedJSON []byte) ([]byte, error) {
+	ops, err := jsonpatch.CreatePatch(existingJSON, expectedJSON)
+	if err != nil {
+		return nil, err
+	}
+
+	var original interface{}
+	if err := json.Unmarshal(originalJSON, &original); err != nil {
+		return nil, err
+	}
+
+	patchOps := make([]jsonpatch.JsonPatchOperation, 0, len(ops))
+
+	for _, op := range ops {
+		if op.Operation == "remove" && !pathExists(original, op.Path) {
+			continue
+		}
+
+		// the fields rendered as null are not set
+		if op.Operation == "add" && op.Value == nil {
+			continue
+		}
+
+		patchOps = append(patchOps, op)
+	}
+
+	if len(patchOps) == 0 {
+		return nil, nil
+	}
+
+	return json.Marshal(patchOps)
+}
+
+// pathExists returns true if the JSON pointer path resolves in doc.
+func pathExists(doc interface{}, path string) bool {
+	if path == "" {
+		return true
+	}
+
+	for _, token := range strings.Split(strings.TrimPrefix(path, "/"), "/") {
+		token = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+
+		switch v := doc.(type) {
+		case map[string]interface{}:
+			var ok bool
+			if doc, ok = v[token]; !ok {
+				return false
+			}
+		case []interface{}:
+			i, err := strconv.Atoi(token)
+			if err != nil || i < 0 || i >= len(v) {
+				return false
+			}
+
+			doc = v[i]
+		default:
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,219 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/runtime"
+	apitypes "k8s.io/apimachinery/pkg/types"
+	"k8s.io/cli-runtime/pkg/resource"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+	"github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/testutils"
+)
+
+func TestCreatePatchWithStrategy(t *testing.T) {
+	tests := []struct {
+		o1        runtime.Object
+		o2        runtime.Object
+		strategy  appv1.PatchStrategyEnum
+		patch     string
+		patchType apitypes.PatchType
+	}{
+		{
+			o1: newTestUnstructured([]interface{}{
+				map[string]interface{}{
+					"name": "test1",
+				},
+				map[string]interface{}{
+					"name": "test2",
+				},
+			}),
+			o2: newTestUnstructured([]interface{}{
+				map[string]interface{}{
+					"name": "test1",
+				},
+			}),
+			strategy:  appv1.MergePatchStrategy,
+			patch:     `{"spec":{"template":{"spec":{"containers":[{"name":"test1"}]}}}}`,
+			patchType: apitypes.MergePatchType,
+		},
+		{
+			o1: newTestUnstructured([]interface{}{
+				map[string]interface{}{
+					"name": "test1",
+				},
+			}),
+			o2: newTestUnstructured([]interface{}{
+				map[string]interface{}{
+					"name": "test1",
+				},
+			}),
+			strategy:  appv1.MergePatchStrategy,
+			patch:     `{}`,
+			patchType: apitypes.MergePatchType,
+		},
+		{
+			o1: newTestUnstructured([]interface{}{
+				map[string]interface{}{
+					"name": "test1",
+				},
+			}),
+			o2: newTestUnstructured([]interface{}{
+				map[string]interface{}{
+					"name": "test2",
+				},
+			}),
+			strategy:  appv1.JSONPatchStrategy,
+			patch:     `[{"op":"replace","path":"/spec/template/spec/containers/0/name","value":"test2"}]`,
+			patchType: apitypes.JSONPatchType,
+		},
+	}
+
+	for _, test := range tests {
+		diff, patchType, err := createPatchWithStrategy(nil, test.o1, &resource.Info{Object: test.o2}, test.strategy)
+		assert.NoError(t, err)
+		assert.Equal(t, test.patchType, patchType)
+		assert.Equal(t, test.patch, string(diff))
+	}
+
+	_, _, err := createPatchWithStrategy(nil, newTestUnstructured(nil), &resource.Info{Object: newTestUnstructured(nil)}, "unknown")
+	assert.Error(t, err)
+}
+
+func TestCreateJSONPatch(t *testing.T) {
+	original := `{"data":{"kept":"1","removed":"1"},"list":[1,2]}`
+	existing := `{"data":{"kept":"1","removed":"1","added":"1"},"list":[1,2,3]}`
+	expected := `{"data":{"kept":"2"},"list":[1]}`
+
+	// the fields removed from the chart are removed, not those added by others
+	patch, err := createJSONPatch([]byte(original), []byte(existing), []byte(expected))
+	require.NoError(t, err)
+
+	var ops []map[string]interface{}
+	require.NoError(t, json.Unmarshal(patch, &ops))
+	assert.ElementsMatch(t, []map[string]interface{}{
+		{"op": "replace", "path": "/data/kept", "value": "2"},
+		{"op": "remove", "path": "/data/removed"},
+		{"op": "remove", "path": "/list/1"},
+	}, ops)
+
+	// nothing is removed from the resources not rendered before
+	patch, err = createJSONPatch([]byte(expected), []byte(existing), []byte(expected))
+	require.NoError(t, err)
+	assert.Equal(t, `[{"op":"replace","path":"/data/kept","value":"2"}]`, string(patch))
+
+	patch, err = createJSONPatch([]byte(original), []byte(original), []byte(original))
+	require.NoError(t, err)
+	assert.Nil(t, patch)
+}
+
+const patchStrategyManifest = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  replicas: %d
+  selector:
+    matchLabels:
+      app: web
+  template:
+    metadata:
+      labels:
+        app: web
+    spec:
+      containers:
+      - name: web
+        image: nginx
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+data:
+  replicas: "%d"
+`
+
+func TestPatchStrategyClientUpdate(t *testing.T) {
+	kubeClient := testutils.NewKubeClient(t, "default")
+	c := newPatchStrategyClient(kubeClient, []appv1.PatchStrategy{
+		{APIVersion: "apps/v1", Kind: "Deployment", Strategy: appv1.MergePatchStrategy},
+	}, "", "")
+
+	original, err := c.Build(strings.NewReader(fmt.Sprintf(patchStrategyManifest, 1, 1)), false)
+	require.NoError(t, err)
+
+	_, err = c.Create(original)
+	require.NoError(t, err)
+
+	target, err := c.Build(strings.NewReader(fmt.Sprintf(patchStrategyManifest, 2, 2)), false)
+	require.NoError(t, err)
+
+	result, err := c.Update(original, target, false)
+	require.NoError(t, err)
+	assert.Len(t, result.Updated, 2)
+
+	patchTypes := make(map[string]apitypes.PatchType)
+
+	for _, req := range kubeClient.Requests() {
+		if req.Verb == "patch" {
+			patchTypes[req.Resource.Kind] = req.PatchType
+		}
+	}
+
+	assert.Equal(t, map[string]apitypes.PatchType{
+		"Deployment": apitypes.MergePatchType,
+		"ConfigMap":  apitypes.StrategicMergePatchType,
+	}, patchTypes)
+}
+
+func TestPatchStrategyClientDefaultStrategy(t *testing.T) {
+	kubeClient := testutils.NewKubeClient(t, "default")
+	c := newPatchStrategyClient(kubeClient, []appv1.PatchStrategy{
+		{APIVersion: "apps/v1", Kind: "Deployment", Strategy: appv1.MergePatchStrategy},
+	}, appv1.JSONPatchStrategy, "")
+
+	original, err := c.Build(strings.NewReader(fmt.Sprintf(patchStrategyManifest, 1, 1)), false)
+	require.NoError(t, err)
+
+	_, err = c.Create(original)
+	require.NoError(t, err)
+
+	target, err := c.Build(strings.NewReader(fmt.Sprintf(patchStrategyManifest, 2, 2)), false)
+	require.NoError(t, err)
+
+	_, err = c.Update(original, target, false)
+	require.NoError(t, err)
+
+	patchTypes := make(map[string]apitypes.PatchType)
+
+	for _, req := range kubeClient.Requests() {
+		if req.Verb == "patch" {
+			patchTypes[req.Resource.Kind] = req.PatchType
+		}
+	}
+
+	// the kinds without a strategy of their own are patched with the default one
+	assert.Equal(t, map[string]apitypes.PatchType{
+		"Deployment": apitypes.MergePatchType,
+		"ConfigMap":  apitypes.JSONPatchType,
+	}, patchTypes)
+}
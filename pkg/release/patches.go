@@ -0,0 +1,138 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	jsonpatch "github.com/evanphx/json-patch"
+	"github.com/ghodss/yaml"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+	"k8s.io/client-go/kubernetes/scheme"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+// patchRenderer is a helm post-renderer applying patches to the matching
+// rendered resources.
+type patchRenderer struct {
+	patches []appv1.ResourcePatch
+}
+
+func (r patchRenderer) Run(rendered *bytes.Buffer) (*bytes.Buffer, error) {
+	patched, err := applyPatches(rendered.String(), r.patches)
+	if err != nil {
+		return nil, err
+	}
+
+	return bytes.NewBufferString(patched), nil
+}
+
+// applyPatches applies the patches to the matching resources of manifest, in
+// turn.
+func applyPatches(manifest string, patches []appv1.ResourcePatch) (string, error) {
+	var err error
+
+	patched := transformDocuments(manifest, func(u map[string]interface{}) bool {
+		if err != nil {
+			return false
+		}
+
+		obj := &unstructured.Unstructured{Object: u}
+		result := u
+		applied := false
+
+		for i, p := range patches {
+			if !objectMatches(obj, p.Target.APIVersion, p.Target.Kind, p.Target.Name) {
+				continue
+			}
+
+			if result, err = applyPatch(result, p); err != nil {
+				err = fmt.Errorf("failed to apply patch %d to %s %s: %w", i, obj.GetKind(), obj.GetName(), err)
+				return false
+			}
+
+			applied = true
+		}
+
+		if !applied {
+			return false
+		}
+
+		// the document is re-encoded from u
+		for k := range u {
+			delete(u, k)
+		}
+
+		for k, v := range result {
+			u[k] = v
+		}
+
+		return true
+	})
+
+	return patched, err
+}
+
+func applyPatch(u map[string]interface{}, p appv1.ResourcePatch) (map[string]interface{}, error) {
+	original, err := json.Marshal(u)
+	if err != nil {
+		return nil, err
+	}
+
+	patch, err := yaml.YAMLToJSON([]byte(p.Patch))
+	if err != nil {
+		return nil, err
+	}
+
+	var patched []byte
+
+	switch p.Type {
+	case "", appv1.StrategicMergePatchStrategy:
+		gvk := (&unstructured.Unstructured{Object: u}).GroupVersionKind()
+
+		// the custom resources have no patch strategies, they are merged
+		typed, schemeErr := scheme.Scheme.New(gvk)
+		if schemeErr != nil {
+			patched, err = jsonpatch.MergePatch(original, patch)
+			break
+		}
+
+		patched, err = strategicpatch.StrategicMergePatch(original, patch, typed)
+	case appv1.MergePatchStrategy:
+		patched, err = jsonpatch.MergePatch(original, patch)
+	case appv1.JSONPatchStrategy:
+		var ops jsonpatch.Patch
+		if ops, err = jsonpatch.DecodePatch(patch); err == nil {
+			patched, err = ops.Apply(original)
+		}
+	default:
+		return nil, fmt.Errorf("patch type %q unsupported", p.Type)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	result := map[string]interface{}{}
+	if err := json.Unmarshal(patched, &result); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
@@ -0,0 +1,87 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+func TestApplyPatches(t *testing.T) {
+	deployment := fmt.Sprintf(testDeploymentManifest, "1", 1, "nginx:1.19")
+	configMap := fmt.Sprintf(testConfigMapManifest, "a")
+
+	patched, err := applyPatches(deployment+configMap, []appv1.ResourcePatch{
+		{
+			Target: appv1.ResourceSelector{APIVersion: "apps/v1", Kind: "Deployment", Name: "test"},
+			Patch: `
+spec:
+  template:
+    spec:
+      containers:
+      - name: test
+        env:
+        - name: LOG_LEVEL
+          value: debug
+`,
+		},
+		{
+			Target: appv1.ResourceSelector{Kind: "Deployment"},
+			Type:   appv1.JSONPatchStrategy,
+			Patch:  `[{"op": "replace", "path": "/spec/replicas", "value": 3}]`,
+		},
+		{
+			Target: appv1.ResourceSelector{Kind: "Deployment", Name: "other"},
+			Type:   appv1.MergePatchStrategy,
+			Patch:  `{"spec": {"replicas": 5}}`,
+		},
+	})
+	assert.NoError(t, err)
+
+	objects, err := manifestObjects(patched)
+	assert.NoError(t, err)
+	assert.Len(t, objects, 2)
+
+	// the containers are merged by name
+	containers, _, _ := unstructured.NestedSlice(objects[0].Object, "spec", "template", "spec", "containers")
+	assert.Len(t, containers, 1)
+	assert.Equal(t, "nginx:1.19", containers[0].(map[string]interface{})["image"])
+	assert.Equal(t, []interface{}{map[string]interface{}{"name": "LOG_LEVEL", "value": "debug"}},
+		containers[0].(map[string]interface{})["env"])
+
+	replicas, _, _ := unstructured.NestedFieldNoCopy(objects[0].Object, "spec", "replicas")
+	assert.EqualValues(t, 3, replicas)
+
+	// the comments and the other documents are kept
+	assert.True(t, strings.HasPrefix(patched, "---\n# Source: test/templates/deployment.yaml\n"))
+	assert.True(t, strings.HasSuffix(patched, configMap))
+}
+
+func TestApplyPatchesFailure(t *testing.T) {
+	deployment := fmt.Sprintf(testDeploymentManifest, "1", 1, "nginx:1.19")
+
+	_, err := applyPatches(deployment, []appv1.ResourcePatch{{
+		Target: appv1.ResourceSelector{Kind: "Deployment"},
+		Type:   appv1.JSONPatchStrategy,
+		Patch:  `[{"op": "remove", "path": "/spec/missing"}]`,
+	}})
+	assert.Error(t, err)
+}
@@ -0,0 +1,110 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release
+
+import (
+	"fmt"
+
+	"helm.sh/helm/v3/pkg/action"
+	rpb "helm.sh/helm/v3/pkg/release"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+// retryPendingRequested returns true if a reconcile of cr was requested and
+// not handled yet, the pending revision is then retried whatever the policy.
+func retryPendingRequested(cr *unstructured.Unstructured) bool {
+	requested := cr.GetAnnotations()[ReconcileRequestAnnotation]
+	return requested != "" && requested != appv1.StatusFor(cr).LastHandledReconcileAt
+}
+
+// latestPending returns the latest revision of releases if it is pending,
+// nil otherwise.
+func latestPending(releases []*rpb.Release) *rpb.Release {
+	var latest *rpb.Release
+
+	for _, rel := range releases {
+		if latest == nil || rel.Version > latest.Version {
+			latest = rel
+		}
+	}
+
+	if latest == nil || latest.Info == nil || !latest.Info.Status.IsPending() {
+		return nil
+	}
+
+	return latest
+}
+
+// recoverPending recovers the pending revision of the release, left by a
+// helm action that never completed, e.g. in an operator killed during an
+// upgrade. The release is locked, no helm action of the operator is running
+// on it. With the Retry policy, the revision is deleted by Sync like the
+// failed ones and the action is run again. With the Rollback policy, the
+// revision is marked failed and rolled back to the deployed revision, or
+// uninstalled if there is none. With the Fail policy, it is left as is until
+// a reconcile is requested.
+func (m *manager) recoverPending(pending *rpb.Release) error {
+	policy := m.pendingPolicy
+	if m.retryPending {
+		policy = appv1.RetryPendingReleasePolicy
+	}
+
+	switch policy {
+	case appv1.FailPendingReleasePolicy:
+		return &ErrReleasePending{Version: pending.Version, Status: pending.Info.Status}
+	case appv1.RollbackPendingReleasePolicy:
+	default:
+		return nil
+	}
+
+	err := &ErrReleasePending{Version: pending.Version, Status: pending.Info.Status}
+
+	// the manifest of the failed revision is the one rolled back from, so
+	// that the resources it created are deleted too
+	pending.SetStatus(rpb.StatusFailed, fmt.Sprintf("Release left %s", pending.Info.Status))
+
+	if updateErr := m.storageBackend.Update(pending); updateErr != nil {
+		return fmt.Errorf("failed to mark pending release version as failed: %w", updateErr)
+	}
+
+	deployed, deployedErr := m.GetDeployedRelease()
+	if deployedErr != nil {
+		uninstall := action.NewUninstall(m.actionConfig)
+		uninstall.Timeout = m.timeout
+
+		if _, uninstallErr := uninstall.Run(m.releaseName); uninstallErr != nil {
+			return fmt.Errorf("failed to uninstall pending release: %w", uninstallErr)
+		}
+
+		err.Action = "uninstalled"
+
+		return err
+	}
+
+	rollback := action.NewRollback(m.actionConfig)
+	rollback.Version = deployed.Version
+	rollback.Wait = m.wait
+	rollback.Timeout = m.timeout
+
+	if rollbackErr := rollback.Run(m.releaseName); rollbackErr != nil {
+		return fmt.Errorf("failed to roll back pending release: %w", rollbackErr)
+	}
+
+	err.Action = "rolled back"
+
+	return err
+}
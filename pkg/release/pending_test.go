@@ -0,0 +1,80 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	rpb "helm.sh/helm/v3/pkg/release"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+func TestLatestPending(t *testing.T) {
+	assert.Nil(t, latestPending(nil))
+
+	assert.Nil(t, latestPending([]*rpb.Release{
+		newTestRelease(2, rpb.StatusPendingUpgrade),
+		newTestRelease(3, rpb.StatusDeployed),
+	}))
+
+	pending := newTestRelease(4, rpb.StatusPendingUpgrade)
+	assert.Equal(t, pending, latestPending([]*rpb.Release{
+		newTestRelease(3, rpb.StatusDeployed),
+		pending,
+		newTestRelease(2, rpb.StatusSuperseded),
+	}))
+
+	assert.NotNil(t, latestPending([]*rpb.Release{newTestRelease(1, rpb.StatusPendingInstall)}))
+}
+
+func TestRecoverPending(t *testing.T) {
+	pending := newTestRelease(2, rpb.StatusPendingUpgrade)
+
+	// the pending revision is deleted by Sync and the action run again
+	m := manager{pendingPolicy: appv1.RetryPendingReleasePolicy}
+	assert.NoError(t, m.recoverPending(pending))
+
+	// or left as is
+	m.pendingPolicy = appv1.FailPendingReleasePolicy
+
+	var pendingErr *ErrReleasePending
+
+	require.True(t, errors.As(m.recoverPending(pending), &pendingErr))
+	assert.Equal(t, 2, pendingErr.Version)
+	assert.Equal(t, rpb.StatusPendingUpgrade, pendingErr.Status)
+	assert.Empty(t, pendingErr.Action)
+	assert.Equal(t, rpb.StatusPendingUpgrade, pending.Info.Status)
+
+	// until a reconcile is requested
+	m.retryPending = true
+	assert.NoError(t, m.recoverPending(pending))
+}
+
+func TestRetryPendingRequested(t *testing.T) {
+	cr := &unstructured.Unstructured{Object: map[string]interface{}{}}
+	assert.False(t, retryPendingRequested(cr))
+
+	cr.SetAnnotations(map[string]string{ReconcileRequestAnnotation: "2020-11-20T10:00:00Z"})
+	assert.True(t, retryPendingRequested(cr))
+
+	// once
+	cr.Object["status"] = map[string]interface{}{"lastHandledReconcileAt": "2020-11-20T10:00:00Z"}
+	assert.False(t, retryPendingRequested(cr))
+}
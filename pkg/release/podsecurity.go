@@ -0,0 +1,316 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// Pod Security Standards levels, from the least to the most restrictive
+const (
+	PodSecurityPrivileged = "privileged"
+	PodSecurityBaseline   = "baseline"
+	PodSecurityRestricted = "restricted"
+)
+
+const appArmorAnnotationPrefix = "container.apparmor.security.beta.kubernetes.io/"
+
+var (
+	// baselineCapabilities may be added by the containers of the baseline level
+	baselineCapabilities = map[corev1.Capability]bool{
+		"AUDIT_WRITE": true, "CHOWN": true, "DAC_OVERRIDE": true, "FOWNER": true, "FSETID": true,
+		"KILL": true, "MKNOD": true, "NET_BIND_SERVICE": true, "SETFCAP": true, "SETGID": true,
+		"SETPCAP": true, "SETUID": true, "SYS_CHROOT": true,
+	}
+
+	// baselineSELinuxTypes may be set by the pods of the baseline level
+	baselineSELinuxTypes = map[string]bool{
+		"": true, "container_t": true, "container_init_t": true, "container_kvm_t": true,
+	}
+
+	// safeSysctls may be set by the pods of the baseline level
+	safeSysctls = map[string]bool{
+		"kernel.shm_rmid_forced": true, "net.ipv4.ip_local_port_range": true,
+		"net.ipv4.ip_unprivileged_port_start": true, "net.ipv4.tcp_syncookies": true,
+		"net.ipv4.ping_group_range": true,
+	}
+)
+
+// podContainer is a container of any kind of a pod spec
+type podContainer struct {
+	name            string
+	securityContext *corev1.SecurityContext
+	ports           []corev1.ContainerPort
+}
+
+// PodSecurityViolations returns the controls of the Pod Security Standards
+// level violated by the pod spec of the workload u, e.g. "container web:
+// privileged=true". It returns nil if u is not a workload or the level is
+// privileged or unknown.
+func PodSecurityViolations(u *unstructured.Unstructured, level string) ([]string, error) {
+	if level != PodSecurityBaseline && level != PodSecurityRestricted {
+		return nil, nil
+	}
+
+	path := podSpecPath(u.GetKind())
+	if path == nil {
+		return nil, nil
+	}
+
+	content, found, err := unstructured.NestedMap(u.Object, path...)
+	if !found || err != nil {
+		return nil, err
+	}
+
+	spec := &corev1.PodSpec{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(content, spec); err != nil {
+		return nil, fmt.Errorf("failed to parse the pod spec of %s %s: %w", u.GetKind(), u.GetName(), err)
+	}
+
+	// the annotations of the pod, or of the pod template
+	annotations := u.GetAnnotations()
+	if len(path) > 1 {
+		annotations, _, _ = unstructured.NestedStringMap(u.Object, append(path[:len(path)-1], "metadata", "annotations")...)
+	}
+
+	violations := baselineViolations(annotations, spec)
+	if level == PodSecurityRestricted {
+		violations = append(violations, restrictedViolations(spec)...)
+	}
+
+	return violations, nil
+}
+
+func podContainers(spec *corev1.PodSpec) []podContainer {
+	var containers []podContainer
+
+	for _, c := range spec.InitContainers {
+		containers = append(containers, podContainer{c.Name, c.SecurityContext, c.Ports})
+	}
+
+	for _, c := range spec.Containers {
+		containers = append(containers, podContainer{c.Name, c.SecurityContext, c.Ports})
+	}
+
+	for _, c := range spec.EphemeralContainers {
+		containers = append(containers, podContainer{c.Name, c.SecurityContext, c.Ports})
+	}
+
+	return containers
+}
+
+func baselineViolations(annotations map[string]string, spec *corev1.PodSpec) []string {
+	var violations []string
+
+	for name, set := range map[string]bool{"hostNetwork": spec.HostNetwork, "hostPID": spec.HostPID, "hostIPC": spec.HostIPC} {
+		if set {
+			violations = append(violations, "pod: "+name+"=true")
+		}
+	}
+
+	sort.Strings(violations)
+
+	for _, v := range spec.Volumes {
+		if v.HostPath != nil {
+			violations = append(violations, fmt.Sprintf("volume %s: hostPath volumes are forbidden", v.Name))
+		}
+	}
+
+	if psc := spec.SecurityContext; psc != nil {
+		violations = append(violations, seLinuxViolations("pod", psc.SELinuxOptions)...)
+
+		if psc.SeccompProfile != nil && psc.SeccompProfile.Type == corev1.SeccompProfileTypeUnconfined {
+			violations = append(violations, "pod: seccompProfile.type=Unconfined")
+		}
+
+		for _, sysctl := range psc.Sysctls {
+			if !safeSysctls[sysctl.Name] {
+				violations = append(violations, fmt.Sprintf("pod: sysctl %s is forbidden", sysctl.Name))
+			}
+		}
+	}
+
+	keys := make([]string, 0, len(annotations))
+	for k := range annotations {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		if profile := annotations[k]; strings.HasPrefix(k, appArmorAnnotationPrefix) &&
+			profile != "runtime/default" && !strings.HasPrefix(profile, "localhost/") {
+			violations = append(violations, fmt.Sprintf("container %s: AppArmor profile %s",
+				strings.TrimPrefix(k, appArmorAnnotationPrefix), profile))
+		}
+	}
+
+	for _, c := range podContainers(spec) {
+		prefix := "container " + c.name + ": "
+
+		for _, p := range c.ports {
+			if p.HostPort != 0 {
+				violations = append(violations, fmt.Sprintf("%shostPort=%d", prefix, p.HostPort))
+			}
+		}
+
+		sc := c.securityContext
+		if sc == nil {
+			continue
+		}
+
+		if sc.Privileged != nil && *sc.Privileged {
+			violations = append(violations, prefix+"privileged=true")
+		}
+
+		if sc.Capabilities != nil {
+			var added []string
+
+			for _, capability := range sc.Capabilities.Add {
+				if !baselineCapabilities[capability] {
+					added = append(added, string(capability))
+				}
+			}
+
+			if len(added) != 0 {
+				violations = append(violations, fmt.Sprintf("%scapabilities.add=[%s]", prefix, strings.Join(added, ", ")))
+			}
+		}
+
+		violations = append(violations, seLinuxViolations("container "+c.name, sc.SELinuxOptions)...)
+
+		if sc.ProcMount != nil && *sc.ProcMount != corev1.DefaultProcMount {
+			violations = append(violations, fmt.Sprintf("%sprocMount=%s", prefix, *sc.ProcMount))
+		}
+
+		if sc.SeccompProfile != nil && sc.SeccompProfile.Type == corev1.SeccompProfileTypeUnconfined {
+			violations = append(violations, prefix+"seccompProfile.type=Unconfined")
+		}
+	}
+
+	return violations
+}
+
+func seLinuxViolations(subject string, options *corev1.SELinuxOptions) []string {
+	if options == nil {
+		return nil
+	}
+
+	var violations []string
+
+	if !baselineSELinuxTypes[options.Type] {
+		violations = append(violations, fmt.Sprintf("%s: seLinuxOptions.type=%s", subject, options.Type))
+	}
+
+	if options.User != "" || options.Role != "" {
+		violations = append(violations, subject+": seLinuxOptions.user and role must not be set")
+	}
+
+	return violations
+}
+
+func restrictedViolations(spec *corev1.PodSpec) []string {
+	var violations []string
+
+	for _, v := range spec.Volumes {
+		s := v.VolumeSource
+		if s.ConfigMap == nil && s.CSI == nil && s.DownwardAPI == nil && s.EmptyDir == nil && s.Ephemeral == nil &&
+			s.PersistentVolumeClaim == nil && s.Projected == nil && s.Secret == nil && s.HostPath == nil {
+			violations = append(violations, fmt.Sprintf("volume %s: only configMap, csi, downwardAPI, emptyDir, "+
+				"ephemeral, persistentVolumeClaim, projected and secret volumes are allowed", v.Name))
+		}
+	}
+
+	psc := spec.SecurityContext
+	if psc == nil {
+		psc = &corev1.PodSecurityContext{}
+	}
+
+	if psc.RunAsUser != nil && *psc.RunAsUser == 0 {
+		violations = append(violations, "pod: runAsUser=0")
+	}
+
+	for _, c := range podContainers(spec) {
+		prefix := "container " + c.name + ": "
+
+		sc := c.securityContext
+		if sc == nil {
+			sc = &corev1.SecurityContext{}
+		}
+
+		if sc.AllowPrivilegeEscalation == nil || *sc.AllowPrivilegeEscalation {
+			violations = append(violations, prefix+"allowPrivilegeEscalation must be false")
+		}
+
+		// the container settings override the pod ones
+		runAsNonRoot := psc.RunAsNonRoot
+		if sc.RunAsNonRoot != nil {
+			runAsNonRoot = sc.RunAsNonRoot
+		}
+
+		if runAsNonRoot == nil || !*runAsNonRoot {
+			violations = append(violations, prefix+"runAsNonRoot must be true")
+		}
+
+		if sc.RunAsUser != nil && *sc.RunAsUser == 0 {
+			violations = append(violations, prefix+"runAsUser=0")
+		}
+
+		seccomp := psc.SeccompProfile
+		if sc.SeccompProfile != nil {
+			seccomp = sc.SeccompProfile
+		}
+
+		if seccomp == nil || (seccomp.Type != corev1.SeccompProfileTypeRuntimeDefault &&
+			seccomp.Type != corev1.SeccompProfileTypeLocalhost) {
+			violations = append(violations, prefix+"seccompProfile.type must be RuntimeDefault or Localhost")
+		}
+
+		var (
+			dropsAll bool
+			added    []string
+		)
+
+		if sc.Capabilities != nil {
+			for _, capability := range sc.Capabilities.Drop {
+				dropsAll = dropsAll || capability == "ALL"
+			}
+
+			// the capabilities forbidden by the baseline level are already reported
+			for _, capability := range sc.Capabilities.Add {
+				if capability != "NET_BIND_SERVICE" && baselineCapabilities[capability] {
+					added = append(added, string(capability))
+				}
+			}
+		}
+
+		if !dropsAll {
+			violations = append(violations, prefix+"capabilities.drop must include ALL")
+		}
+
+		if len(added) != 0 {
+			violations = append(violations, fmt.Sprintf("%scapabilities.add=[%s], only NET_BIND_SERVICE is allowed",
+				prefix, strings.Join(added, ", ")))
+		}
+	}
+
+	return violations
+}
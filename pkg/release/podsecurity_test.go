@@ -0,0 +1,112 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func testWorkload(podSpec map[string]interface{}) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata":   map[string]interface{}{"name": "web"},
+		"spec": map[string]interface{}{"template": map[string]interface{}{
+			"metadata": map[string]interface{}{"annotations": map[string]interface{}{
+				"container.apparmor.security.beta.kubernetes.io/web": "runtime/default",
+			}},
+			"spec": podSpec,
+		}},
+	}}
+}
+
+func TestPodSecurityViolations(t *testing.T) {
+	privileged := testWorkload(map[string]interface{}{
+		"hostNetwork": true,
+		"containers": []interface{}{map[string]interface{}{
+			"name":  "web",
+			"image": "nginx:1.19",
+			"ports": []interface{}{map[string]interface{}{"containerPort": int64(80), "hostPort": int64(80)}},
+			"securityContext": map[string]interface{}{
+				"privileged":   true,
+				"capabilities": map[string]interface{}{"add": []interface{}{"NET_ADMIN", "CHOWN"}},
+			},
+		}},
+		"volumes": []interface{}{map[string]interface{}{"name": "docker", "hostPath": map[string]interface{}{"path": "/var/run"}}},
+	})
+
+	violations, err := PodSecurityViolations(privileged, PodSecurityBaseline)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"pod: hostNetwork=true",
+		"volume docker: hostPath volumes are forbidden",
+		"container web: hostPort=80",
+		"container web: privileged=true",
+		"container web: capabilities.add=[NET_ADMIN]",
+	}, violations)
+
+	violations, err = PodSecurityViolations(privileged, PodSecurityPrivileged)
+	assert.NoError(t, err)
+	assert.Empty(t, violations)
+
+	baseline := testWorkload(map[string]interface{}{
+		"containers": []interface{}{map[string]interface{}{"name": "web", "image": "nginx:1.19"}},
+	})
+
+	violations, err = PodSecurityViolations(baseline, PodSecurityBaseline)
+	assert.NoError(t, err)
+	assert.Empty(t, violations)
+
+	violations, err = PodSecurityViolations(baseline, PodSecurityRestricted)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"container web: allowPrivilegeEscalation must be false",
+		"container web: runAsNonRoot must be true",
+		"container web: seccompProfile.type must be RuntimeDefault or Localhost",
+		"container web: capabilities.drop must include ALL",
+	}, violations)
+
+	restricted := testWorkload(map[string]interface{}{
+		"securityContext": map[string]interface{}{
+			"runAsNonRoot":   true,
+			"seccompProfile": map[string]interface{}{"type": "RuntimeDefault"},
+		},
+		"containers": []interface{}{map[string]interface{}{
+			"name":  "web",
+			"image": "nginx:1.19",
+			"securityContext": map[string]interface{}{
+				"allowPrivilegeEscalation": false,
+				"capabilities": map[string]interface{}{
+					"drop": []interface{}{"ALL"},
+					"add":  []interface{}{"NET_BIND_SERVICE"},
+				},
+			},
+		}},
+	})
+
+	violations, err = PodSecurityViolations(restricted, PodSecurityRestricted)
+	assert.NoError(t, err)
+	assert.Empty(t, violations)
+
+	// not a workload
+	violations, err = PodSecurityViolations(&unstructured.Unstructured{Object: map[string]interface{}{
+		"kind": "ConfigMap",
+	}}, PodSecurityRestricted)
+	assert.NoError(t, err)
+	assert.Empty(t, violations)
+}
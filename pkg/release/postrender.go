@@ -0,0 +1,153 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"helm.sh/helm/v3/pkg/postrender"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+// postRenderers is a helm post-renderer running its post-renderers in turn,
+// each on the output of the previous one.
+type postRenderers []postrender.PostRenderer
+
+func (r postRenderers) Run(rendered *bytes.Buffer) (*bytes.Buffer, error) {
+	for _, p := range r {
+		var err error
+		if rendered, err = p.Run(rendered); err != nil {
+			return nil, err
+		}
+	}
+
+	return rendered, nil
+}
+
+// newPostRenderer returns the helm post-renderer running the transformers
+// that apply to the release of repo in turn, namespace being the target
+// namespace. The hooks are not post-rendered by helm.
+func newPostRenderer(repo *appv1.HelmReleaseRepo, namespace string) postrender.PostRenderer {
+	var renderers postRenderers
+
+	for _, t := range registeredTransformers() {
+		if r := t.PostRenderer(repo, namespace); r != nil {
+			renderers = append(renderers, r)
+		}
+	}
+
+	if len(renderers) == 0 {
+		return nil
+	}
+
+	return renderers
+}
+
+// postRenderSettings returns the settings of repo the transformers depend
+// on, in the same order, so that changing them changes the release digest.
+func postRenderSettings(repo *appv1.HelmReleaseRepo) []interface{} {
+	var settings []interface{}
+
+	for _, t := range registeredTransformers() {
+		if setting := t.Settings(repo); setting != nil {
+			settings = append(settings, setting)
+		}
+	}
+
+	return settings
+}
+
+// transformDocuments calls transform with each document of manifest and
+// re-encodes those it returns true for. The other documents, and the
+// comments of the transformed ones, are kept as is.
+func transformDocuments(manifest string, transform func(u map[string]interface{}) bool) string {
+	docs := strings.Split(manifest, "\n---")
+
+	for i, doc := range docs {
+		u := map[string]interface{}{}
+		if err := yaml.Unmarshal([]byte(doc), &u); err != nil || len(u) == 0 {
+			continue
+		}
+
+		if !transform(u) {
+			continue
+		}
+
+		out, err := yaml.Marshal(u)
+		if err != nil {
+			continue
+		}
+
+		docs[i] = leadingComments(doc) + strings.TrimSuffix(string(out), "\n")
+	}
+
+	return strings.Join(docs, "\n---")
+}
+
+// filterDocuments drops the documents of manifest that keep returns false
+// for, with their comments. The documents that cannot be parsed are kept.
+func filterDocuments(manifest string, keep func(u map[string]interface{}) bool) string {
+	docs := strings.Split(manifest, "\n---")
+	kept := make([]string, 0, len(docs))
+	prefix := ""
+
+	for _, doc := range docs {
+		u := map[string]interface{}{}
+		if err := yaml.Unmarshal([]byte(doc), &u); err == nil && len(u) > 0 && !keep(u) {
+			// the separator of the first document is not part of the split
+			if len(kept) == 0 && strings.HasPrefix(doc, "---") {
+				prefix = "---"
+			}
+
+			continue
+		}
+
+		if len(kept) == 0 {
+			doc = prefix + doc
+		}
+
+		kept = append(kept, doc)
+	}
+
+	return strings.Join(kept, "\n---")
+}
+
+// transformPodSpecs calls transform with the pod spec of each workload of
+// manifest and re-encodes those it returns true for.
+func transformPodSpecs(manifest string, transform func(spec map[string]interface{}) bool) string {
+	return transformDocuments(manifest, func(u map[string]interface{}) bool {
+		kind, _ := u["kind"].(string)
+
+		path := podSpecPath(kind)
+		if path == nil {
+			return false
+		}
+
+		spec, found, err := unstructured.NestedMap(u, path...)
+		if !found || err != nil {
+			return false
+		}
+
+		if !transform(spec) {
+			return false
+		}
+
+		return unstructured.SetNestedMap(u, spec, path...) == nil
+	})
+}
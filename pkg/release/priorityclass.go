@@ -0,0 +1,46 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release
+
+import "bytes"
+
+// priorityClassRenderer is a helm post-renderer setting the priorityClassName
+// of the pod specs of the rendered workloads.
+type priorityClassRenderer struct {
+	name string
+}
+
+func (r priorityClassRenderer) Run(rendered *bytes.Buffer) (*bytes.Buffer, error) {
+	return bytes.NewBufferString(setPriorityClass(rendered.String(), r.name)), nil
+}
+
+// setPriorityClass sets the priorityClassName of the pod specs of the
+// workloads of manifest that have none. The pod specs with a priority are
+// left as is, it must match the one of their priority class.
+func setPriorityClass(manifest string, name string) string {
+	return transformPodSpecs(manifest, func(spec map[string]interface{}) bool {
+		if class, _ := spec["priorityClassName"].(string); class != "" {
+			return false
+		}
+
+		if _, ok := spec["priority"]; ok {
+			return false
+		}
+
+		spec["priorityClassName"] = name
+
+		return true
+	})
+}
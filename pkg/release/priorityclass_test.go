@@ -0,0 +1,45 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestSetPriorityClass(t *testing.T) {
+	deployment := fmt.Sprintf(testDeploymentManifest, "1", 1, "nginx:1.19")
+	configMap := fmt.Sprintf(testConfigMapManifest, "a")
+
+	set := setPriorityClass(deployment+configMap, "platform-critical")
+
+	objects, err := manifestObjects(set)
+	assert.NoError(t, err)
+	assert.Len(t, objects, 2)
+
+	class, _, _ := unstructured.NestedString(objects[0].Object, "spec", "template", "spec", "priorityClassName")
+	assert.Equal(t, "platform-critical", class)
+
+	// the comments and the other documents are kept
+	assert.True(t, strings.HasPrefix(set, "---\n# Source: test/templates/deployment.yaml\n"))
+	assert.True(t, strings.HasSuffix(set, configMap))
+
+	// the priority class of the chart is kept
+	assert.Equal(t, set, setPriorityClass(set, "other"))
+}
@@ -0,0 +1,144 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"helm.sh/helm/v3/pkg/kube"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+var _ kube.Interface = &progressClient{}
+
+// rolloutProgress holds the resources a helm action is waiting for. It is
+// shared by the progress client, which sets them, and the manager, which
+// reports their progress.
+type rolloutProgress struct {
+	mu        sync.Mutex
+	resources kube.ResourceList
+}
+
+func (p *rolloutProgress) set(resources kube.ResourceList) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.resources = resources
+}
+
+func (p *rolloutProgress) get() kube.ResourceList {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.resources
+}
+
+// progressClient records the resources waited for by the wrapped client so
+// that the progress of long rollouts can be reported while helm waits.
+// Everything other than Wait is delegated to the wrapped client.
+type progressClient struct {
+	kube.Interface
+	progress *rolloutProgress
+}
+
+func newProgressClient(base kube.Interface, progress *rolloutProgress) kube.Interface {
+	return &progressClient{
+		Interface: base,
+		progress:  progress,
+	}
+}
+
+// Wait records resources until the wrapped client is done waiting for them.
+func (c *progressClient) Wait(resources kube.ResourceList, timeout time.Duration) error {
+	c.progress.set(resources)
+	defer c.progress.set(nil)
+
+	return c.Interface.Wait(resources, timeout)
+}
+
+// RolloutProgress returns a summary of the readiness of the resources the
+// running install, upgrade or rollback is waiting for, e.g.
+// "Deployment 3/5 ready; StatefulSet default/foo: Ready: 1/3", or an empty
+// string if it is not waiting.
+func (m manager) RolloutProgress() string {
+	if m.progress == nil {
+		return ""
+	}
+
+	var statuses []appv1.HelmAppResourceStatus
+
+	for _, info := range m.progress.get() {
+		status, err := resourceStatus(info)
+		if err != nil {
+			status.Status, status.Message = appv1.ResourceUnknown, err.Error()
+		}
+
+		statuses = append(statuses, status)
+	}
+
+	return progressSummary(statuses)
+}
+
+// progressSummary counts the ready resources of each kind and explains why
+// the others are not ready.
+func progressSummary(statuses []appv1.HelmAppResourceStatus) string {
+	var (
+		kinds        []string
+		total, ready = map[string]int{}, map[string]int{}
+		pending      []string
+	)
+
+	for _, s := range statuses {
+		kind := s.Resource.Kind
+		if _, ok := total[kind]; !ok {
+			kinds = append(kinds, kind)
+		}
+
+		total[kind]++
+
+		if s.Status == appv1.ResourceCurrent {
+			ready[kind]++
+			continue
+		}
+
+		name := s.Resource.Name
+		if s.Resource.Namespace != "" {
+			name = s.Resource.Namespace + "/" + name
+		}
+
+		message := s.Message
+		if message == "" {
+			message = string(s.Status)
+		}
+
+		pending = append(pending, fmt.Sprintf("%s %s: %s", kind, name, message))
+	}
+
+	counts := make([]string, 0, len(kinds))
+	for _, kind := range kinds {
+		counts = append(counts, fmt.Sprintf("%s %d/%d ready", kind, ready[kind], total[kind]))
+	}
+
+	summary := strings.Join(counts, ", ")
+	if len(pending) > 0 {
+		summary += "; " + strings.Join(pending, ", ")
+	}
+
+	return summary
+}
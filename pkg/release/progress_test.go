@@ -0,0 +1,41 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+func TestProgressSummary(t *testing.T) {
+	assert.Equal(t, "", progressSummary(nil))
+
+	statuses := []appv1.HelmAppResourceStatus{
+		{Resource: appv1.HelmAppResource{Kind: "Deployment", Namespace: "default", Name: "web"}, Status: appv1.ResourceCurrent},
+		{
+			Resource: appv1.HelmAppResource{Kind: "StatefulSet", Namespace: "default", Name: "db"},
+			Status:   appv1.ResourceInProgress,
+			Message:  "Ready: 1/3",
+		},
+		{Resource: appv1.HelmAppResource{Kind: "Deployment", Namespace: "default", Name: "api"}, Status: appv1.ResourceNotFound},
+	}
+
+	assert.Equal(t,
+		"Deployment 1/2 ready, StatefulSet 0/1 ready; StatefulSet default/db: Ready: 1/3, Deployment default/api: NotFound",
+		progressSummary(statuses))
+}
@@ -0,0 +1,38 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release
+
+import (
+	"helm.sh/helm/v3/pkg/kube"
+)
+
+var _ kube.Interface = &noPruneClient{}
+
+// noPruneClient leaves the resources no longer rendered by the chart in the
+// cluster on upgrade instead of deleting them. Everything other than Update is
+// delegated to the wrapped client.
+type noPruneClient struct {
+	kube.Interface
+}
+
+func newNoPruneClient(base kube.Interface) kube.Interface {
+	return &noPruneClient{Interface: base}
+}
+
+// Update hides the resources of original that are not part of target from the
+// wrapped client so that it has nothing to delete.
+func (c *noPruneClient) Update(original, target kube.ResourceList, force bool) (*kube.Result, error) {
+	return c.Interface.Update(original.Intersect(target), target, force)
+}
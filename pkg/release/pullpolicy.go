@@ -0,0 +1,57 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release
+
+import (
+	"bytes"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// pullPolicyRenderer is a helm post-renderer setting the imagePullPolicy of
+// the containers of the rendered workloads.
+type pullPolicyRenderer struct {
+	policy corev1.PullPolicy
+}
+
+func (r pullPolicyRenderer) Run(rendered *bytes.Buffer) (*bytes.Buffer, error) {
+	return bytes.NewBufferString(setPullPolicy(rendered.String(), r.policy)), nil
+}
+
+// setPullPolicy sets the imagePullPolicy of the containers of the workloads
+// of manifest, init and ephemeral containers included, to policy.
+func setPullPolicy(manifest string, policy corev1.PullPolicy) string {
+	return transformPodSpecs(manifest, func(spec map[string]interface{}) bool {
+		set := false
+
+		for _, field := range []string{"containers", "initContainers", "ephemeralContainers"} {
+			containers, _ := spec[field].([]interface{})
+
+			for _, c := range containers {
+				container, ok := c.(map[string]interface{})
+				if !ok {
+					continue
+				}
+
+				if current, _ := container["imagePullPolicy"].(string); current != string(policy) {
+					container["imagePullPolicy"] = string(policy)
+					set = true
+				}
+			}
+		}
+
+		return set
+	})
+}
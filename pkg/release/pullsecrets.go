@@ -0,0 +1,75 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release
+
+import (
+	"bytes"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// pullSecretsRenderer is a helm post-renderer appending imagePullSecrets to
+// the pod specs of the rendered workloads. The hooks are not post-rendered by
+// helm.
+type pullSecretsRenderer struct {
+	secrets []corev1.LocalObjectReference
+}
+
+func (r pullSecretsRenderer) Run(rendered *bytes.Buffer) (*bytes.Buffer, error) {
+	return bytes.NewBufferString(injectPullSecrets(rendered.String(), r.secrets)), nil
+}
+
+// injectPullSecrets appends secrets to the imagePullSecrets of the workloads
+// of manifest, except those they already reference. The other documents, and
+// the comments of the workloads, are kept as is.
+func injectPullSecrets(manifest string, secrets []corev1.LocalObjectReference) string {
+	return transformPodSpecs(manifest, func(spec map[string]interface{}) bool {
+		return appendPullSecrets(spec, secrets)
+	})
+}
+
+// appendPullSecrets appends the missing secrets to the imagePullSecrets of
+// the pod spec and returns true if any was.
+func appendPullSecrets(spec map[string]interface{}, secrets []corev1.LocalObjectReference) bool {
+	existing, _ := spec["imagePullSecrets"].([]interface{})
+
+	names := map[string]bool{}
+
+	for _, s := range existing {
+		if ref, ok := s.(map[string]interface{}); ok {
+			if name, ok := ref["name"].(string); ok {
+				names[name] = true
+			}
+		}
+	}
+
+	appended := false
+
+	for _, s := range secrets {
+		if names[s.Name] {
+			continue
+		}
+
+		existing = append(existing, map[string]interface{}{"name": s.Name})
+		names[s.Name] = true
+		appended = true
+	}
+
+	if appended {
+		spec["imagePullSecrets"] = existing
+	}
+
+	return appended
+}
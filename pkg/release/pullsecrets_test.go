@@ -0,0 +1,50 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestInjectPullSecrets(t *testing.T) {
+	deployment := fmt.Sprintf(testDeploymentManifest, "1", 1, "nginx:1.19")
+	configMap := fmt.Sprintf(testConfigMapManifest, "a")
+	secrets := []corev1.LocalObjectReference{{Name: "registry"}, {Name: "mirror"}}
+
+	injected := injectPullSecrets(deployment+configMap, secrets)
+
+	objects, err := manifestObjects(injected)
+	assert.NoError(t, err)
+	assert.Len(t, objects, 2)
+
+	pullSecrets, _, _ := unstructured.NestedSlice(objects[0].Object, "spec", "template", "spec", "imagePullSecrets")
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"name": "registry"},
+		map[string]interface{}{"name": "mirror"},
+	}, pullSecrets)
+
+	// the comments and the other documents are kept
+	assert.True(t, strings.HasPrefix(injected, "---\n# Source: test/templates/deployment.yaml\n"))
+	assert.True(t, strings.HasSuffix(injected, configMap))
+
+	// the secrets already referenced are not duplicated
+	assert.Equal(t, injected, injectPullSecrets(injected, secrets))
+}
@@ -0,0 +1,155 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	rpb "helm.sh/helm/v3/pkg/release"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	apitypes "k8s.io/apimachinery/pkg/types"
+	v1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/rest"
+)
+
+// Labels set on the Secrets and ConfigMaps holding the release records of a
+// HelmRelease. Helm CLI releases do not have them.
+const (
+	HelmReleaseNameLabel      = "apps.open-cluster-management.io/helmrelease-name"
+	HelmReleaseNamespaceLabel = "apps.open-cluster-management.io/helmrelease-namespace"
+)
+
+// recordLabeler labels the storage object of a release record with the
+// HelmRelease it belongs to.
+type recordLabeler func(rel *rpb.Release) error
+
+// newRecordLabeler returns nil for the storage drivers without labels.
+func newRecordLabeler(cfg *rest.Config, opts StorageOptions, storageNamespace, name,
+	namespace string) (recordLabeler, error) {
+	if opts.Driver == SQLStorageDriver {
+		return nil, nil
+	}
+
+	clientv1, err := v1.NewForConfig(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get core/v1 client: %w", err)
+	}
+
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"labels": map[string]string{
+				HelmReleaseNameLabel:      name,
+				HelmReleaseNamespaceLabel: namespace,
+			},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return func(rel *rpb.Release) error {
+		key := recordKey(rel)
+
+		if opts.Driver == ConfigMapsStorageDriver {
+			_, err := clientv1.ConfigMaps(storageNamespace).Patch(context.TODO(), key, apitypes.MergePatchType,
+				patch, metav1.PatchOptions{})
+
+			return err
+		}
+
+		_, err := clientv1.Secrets(storageNamespace).Patch(context.TODO(), key, apitypes.MergePatchType,
+			patch, metav1.PatchOptions{})
+
+		return err
+	}, nil
+}
+
+// recordOwner returns the namespace of the HelmRelease a release record
+// belongs to, empty if the record is not labeled, e.g. a helm CLI release.
+type recordOwner func(rel *rpb.Release) (string, error)
+
+// newRecordOwner returns nil for the storage drivers without labels.
+func newRecordOwner(cfg *rest.Config, opts StorageOptions, storageNamespace string) (recordOwner, error) {
+	if opts.Driver == SQLStorageDriver {
+		return nil, nil
+	}
+
+	clientv1, err := v1.NewForConfig(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get core/v1 client: %w", err)
+	}
+
+	return func(rel *rpb.Release) (string, error) {
+		var (
+			obj metav1.Object
+			err error
+		)
+
+		if opts.Driver == ConfigMapsStorageDriver {
+			obj, err = clientv1.ConfigMaps(storageNamespace).Get(context.TODO(), recordKey(rel), metav1.GetOptions{})
+		} else {
+			obj, err = clientv1.Secrets(storageNamespace).Get(context.TODO(), recordKey(rel), metav1.GetOptions{})
+		}
+
+		if err != nil {
+			return "", fmt.Errorf("failed to get release record %s: %w", recordKey(rel), err)
+		}
+
+		return obj.GetLabels()[HelmReleaseNamespaceLabel], nil
+	}, nil
+}
+
+// recordKey is the name of the storage object of a release record, the same
+// as the helm secrets and configmaps drivers.
+func recordKey(rel *rpb.Release) string {
+	return fmt.Sprintf("sh.helm.release.v1.%s.v%d", rel.Name, rel.Version)
+}
+
+// DeleteReleaseRecords deletes the records of the release name deployed to
+// namespace from the storage namespace. It returns the number of deleted records.
+func DeleteReleaseRecords(cfg *rest.Config, opts StorageOptions, storageNamespace, name,
+	namespace string) (int, error) {
+	storageBackend, err := newStorage(cfg, opts, storageNamespace)
+	if err != nil {
+		return 0, err
+	}
+
+	history, err := storageBackend.History(name)
+	if err != nil {
+		if notFoundErr(err) {
+			return 0, nil
+		}
+
+		return 0, err
+	}
+
+	deleted := 0
+
+	for _, rel := range history {
+		if rel.Namespace != namespace {
+			continue
+		}
+
+		if _, err := storageBackend.Delete(rel.Name, rel.Version); err != nil && !notFoundErr(err) {
+			return deleted, fmt.Errorf("failed to delete release record %s.v%d: %w", rel.Name, rel.Version, err)
+		}
+
+		deleted++
+	}
+
+	return deleted, nil
+}
@@ -0,0 +1,276 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/ghodss/yaml"
+)
+
+// Redacted replaces the secret data in the messages, diffs and manifests.
+const Redacted = "[redacted]"
+
+// secretValueMinLength is the length of the shortest values redacted, the
+// shorter ones would redact common words.
+const secretValueMinLength = 6
+
+var (
+	// secretKey matches the keys of the secret-shaped values
+	secretKey = regexp.MustCompile(`(?i)(password|passwd|secret|token|api[_-]?key|private[_-]?key|credential|access[_-]?key)`)
+	// secretKeyExclusion matches the keys naming or referencing a secret
+	// rather than holding it, e.g. existingSecret or secretName
+	secretKeyExclusion = regexp.MustCompile(`(?i)(name|ref|existing)`)
+
+	// secretPatterns match the secrets in the messages, the groups around
+	// the secret are kept
+	secretPatterns = []*regexp.Regexp{
+		// the password of the credentials of a URL
+		regexp.MustCompile(`(?i)(\b[a-z][a-z0-9+.-]*://[^/\s:@]+:)[^/\s@]+(@)`),
+		// the credentials of an authorization header
+		regexp.MustCompile(`(?i)(authorization:\s*(?:bearer|basic)\s+)[^\s"',]+`),
+		// the secret-shaped fields, e.g. password: xxx or "token":"xxx"
+		regexp.MustCompile(`(?i)((?:password|passwd|token|api[_-]?key|private[_-]?key|client[_-]?secret|secret[_-]?key|access[_-]?key)"?\s*[:=]\s*"?)[^\s"',}\]]+`),
+	}
+)
+
+// RedactMessage redacts the secret-shaped data of message, e.g. the password
+// of a URL or the value of a password field.
+func RedactMessage(message string) string {
+	for _, p := range secretPatterns {
+		message = p.ReplaceAllString(message, "${1}"+Redacted+"${2}")
+	}
+
+	return message
+}
+
+// redact redacts the secret-shaped data and the secret values of message.
+func redact(message string, secrets []string) string {
+	for _, s := range secrets {
+		message = strings.ReplaceAll(message, s, Redacted)
+	}
+
+	return RedactMessage(message)
+}
+
+// secretValues returns the values of values under secret-shaped keys, e.g.
+// a password, so that they can be redacted from the messages echoing them.
+func secretValues(values map[string]interface{}) []string {
+	var secrets []string
+
+	var walk func(v interface{}, secret bool)
+
+	walk = func(v interface{}, secret bool) {
+		switch v := v.(type) {
+		case map[string]interface{}:
+			for k, child := range v {
+				walk(child, secret || (secretKey.MatchString(k) && !secretKeyExclusion.MatchString(k)))
+			}
+		case []interface{}:
+			for _, child := range v {
+				walk(child, secret)
+			}
+		case string:
+			if secret && len(v) >= secretValueMinLength {
+				secrets = append(secrets, v)
+			}
+		}
+	}
+
+	walk(values, false)
+
+	return secrets
+}
+
+// redactValues returns a copy of values whose strings under secret-shaped
+// keys are replaced with Redacted and whose other strings have the secret
+// values redacted.
+func redactValues(values map[string]interface{}, secrets []string) map[string]interface{} {
+	var walk func(v interface{}, secret bool) interface{}
+
+	walk = func(v interface{}, secret bool) interface{} {
+		switch v := v.(type) {
+		case map[string]interface{}:
+			out := make(map[string]interface{}, len(v))
+			for k, child := range v {
+				out[k] = walk(child, secret || (secretKey.MatchString(k) && !secretKeyExclusion.MatchString(k)))
+			}
+
+			return out
+		case []interface{}:
+			out := make([]interface{}, len(v))
+			for i, child := range v {
+				out[i] = walk(child, secret)
+			}
+
+			return out
+		case string:
+			if secret {
+				return Redacted
+			}
+
+			return redact(v, secrets)
+		}
+
+		return v
+	}
+
+	out, _ := walk(values, false).(map[string]interface{})
+
+	return out
+}
+
+// redactedError is an error whose message is redacted, it still matches the
+// errors it wraps.
+type redactedError struct {
+	err     error
+	message string
+}
+
+func (e *redactedError) Error() string {
+	return e.message
+}
+
+func (e *redactedError) Unwrap() error {
+	return e.err
+}
+
+// redactError redacts the message of err with the secret values of the
+// release, helm errors often echo the merged values.
+func (m manager) redactError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	message := redact(err.Error(), m.secrets)
+	if message == err.Error() {
+		return err
+	}
+
+	return &redactedError{err: err, message: message}
+}
+
+// RedactManifest redacts the data of the Secrets of manifest, e.g. before
+// reporting it in a status. The other resources are left as is.
+func RedactManifest(manifest string) string {
+	docs := strings.Split(manifest, "\n---")
+
+	for i, doc := range docs {
+		u := map[string]interface{}{}
+		if err := yaml.Unmarshal([]byte(doc), &u); err != nil || u["kind"] != "Secret" {
+			continue
+		}
+
+		redactSecretData(u)
+
+		out, err := yaml.Marshal(u)
+		if err != nil {
+			continue
+		}
+
+		docs[i] = leadingComments(doc) + strings.TrimSuffix(string(out), "\n")
+	}
+
+	return strings.Join(docs, "\n---")
+}
+
+// redactSecretData replaces the values of the data and stringData of the
+// Secret u.
+func redactSecretData(u map[string]interface{}) {
+	for _, field := range []string{"data", "stringData"} {
+		data, ok := u[field].(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		for k := range data {
+			data[k] = Redacted
+		}
+	}
+}
+
+// leadingComments returns the document separator, the comments and the
+// blank lines before the content of doc, e.g. the # Source: of helm.
+func leadingComments(doc string) string {
+	end := 0
+
+	for _, line := range strings.SplitAfter(doc, "\n") {
+		if trimmed := strings.TrimSpace(line); trimmed != "" && trimmed != "---" && !strings.HasPrefix(trimmed, "#") {
+			break
+		}
+
+		end += len(line)
+	}
+
+	return doc[:end]
+}
+
+// redactDiff redacts the changes of the data of the Secrets and the secret
+// values of the release in diff.
+func redactDiff(diff *ReleaseDiff, secrets []string) {
+	for _, d := range diff.Modified {
+		secretData := d.Resource.Kind == "Secret"
+
+		for i := range d.Changes {
+			c := &d.Changes[i]
+
+			if secretData && (underPath(c.Path, "/data") || underPath(c.Path, "/stringData")) {
+				c.Old, c.New = redactedValue(c.Old), redactedValue(c.New)
+				continue
+			}
+
+			c.Old, c.New = redactValue(c.Old, secrets), redactValue(c.New, secrets)
+		}
+	}
+}
+
+// underPath returns true if the JSON pointer p is path or below it.
+func underPath(p, path string) bool {
+	return p == path || strings.HasPrefix(p, path+"/")
+}
+
+// redactedValue replaces a value with Redacted, a missing value stays nil.
+func redactedValue(v interface{}) interface{} {
+	if v == nil {
+		return nil
+	}
+
+	return Redacted
+}
+
+// redactValue redacts the secret values of the strings of v.
+func redactValue(v interface{}, secrets []string) interface{} {
+	switch v := v.(type) {
+	case string:
+		return redact(v, secrets)
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, child := range v {
+			out[k] = redactValue(child, secrets)
+		}
+
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, child := range v {
+			out[i] = redactValue(child, secrets)
+		}
+
+		return out
+	}
+
+	return v
+}
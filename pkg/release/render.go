@@ -0,0 +1,178 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/ghodss/yaml"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/releaseutil"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// RenderOptions customize the rendering of a release, e.g. for a managed
+// cluster.
+type RenderOptions struct {
+	// Overrides are merged into the values of the release, overriding them.
+	Overrides map[string]interface{}
+	// ReleaseName replaces the name of the release if set.
+	ReleaseName string
+	// Namespace replaces the target namespace if set.
+	Namespace string
+}
+
+// Render renders the release as a dry-run install and returns its resources
+// in install order, the CRDs of the chart first. The namespaced resources
+// without a namespace are set to the target namespace. The hooks are not
+// rendered. It is used to hand the release to another agent, e.g. the work
+// agent of a managed cluster, instead of installing it.
+func (m manager) Render(ctx context.Context, opts RenderOptions) ([]*unstructured.Unstructured, error) {
+	if len(opts.Overrides) != 0 {
+		// the overrides are authoritative and merged in place, the values of m
+		// are not modified
+		m.values = chartutil.CoalesceTables(opts.Overrides, m.values)
+		m.secrets = append(secretValues(opts.Overrides), m.secrets...)
+	}
+
+	if opts.ReleaseName != "" {
+		m.releaseName = opts.ReleaseName
+	}
+
+	if opts.Namespace != "" {
+		m.namespace = opts.Namespace
+		m.postRenderer = withNamespace(m.postRenderer, opts.Namespace)
+	}
+
+	rel, err := m.getCandidateInstall()
+	if err != nil {
+		return nil, renderFailed(m.redactError(fmt.Errorf("failed to render release: %w", err)))
+	}
+
+	restMapper, err := m.actionConfig.RESTClientGetter.ToRESTMapper()
+	if err != nil {
+		return nil, err
+	}
+
+	return renderedObjects(m.chart, rel.Manifest, m.namespace, mapperScope(restMapper))
+}
+
+// kindScope returns true if the resources of the kind are namespaced.
+type kindScope func(gvk schema.GroupVersionKind) (bool, error)
+
+// mapperScope returns the scope of the kinds known to restMapper. Most of the
+// kinds it does not know are namespaced custom resources.
+func mapperScope(restMapper meta.RESTMapper) kindScope {
+	return func(gvk schema.GroupVersionKind) (bool, error) {
+		mapping, err := restMapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+
+		switch {
+		case meta.IsNoMatchError(err):
+			return true, nil
+		case err != nil:
+			return false, fmt.Errorf("failed to get the scope of %s: %w", gvk.Kind, err)
+		default:
+			return mapping.Scope.Name() == meta.RESTScopeNameNamespace, nil
+		}
+	}
+}
+
+// renderedObjects returns the resources of the rendered manifest of chrt in
+// install order, the CRDs of the chart first. The namespaced resources without
+// a namespace are set to namespace, the scope of the kinds not defined by the
+// chart is returned by scope.
+func renderedObjects(chrt *chart.Chart, manifest, namespace string, scope kindScope) ([]*unstructured.Unstructured, error) {
+	var objects []*unstructured.Unstructured
+
+	// the kinds defined by the chart are not known to the cluster rendering it
+	namespacedKinds := make(map[schema.GroupKind]bool)
+
+	for _, crd := range chrt.CRDs() {
+		crds, err := manifestObjects(string(crd.Data))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse CRD %s: %w", crd.Name, err)
+		}
+
+		for _, u := range crds {
+			group, _, _ := unstructured.NestedString(u.Object, "spec", "group")
+			kind, _, _ := unstructured.NestedString(u.Object, "spec", "names", "kind")
+			scope, _, _ := unstructured.NestedString(u.Object, "spec", "scope")
+			namespacedKinds[schema.GroupKind{Group: group, Kind: kind}] = scope != "Cluster"
+		}
+
+		objects = append(objects, crds...)
+	}
+
+	resources, err := manifestObjects(manifest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse rendered manifest: %w", err)
+	}
+
+	for _, u := range resources {
+		if u.GetNamespace() != "" {
+			continue
+		}
+
+		gvk := u.GroupVersionKind()
+
+		namespaced, ok := namespacedKinds[gvk.GroupKind()]
+		if !ok {
+			if namespaced, err = scope(gvk); err != nil {
+				return nil, err
+			}
+		}
+
+		if namespaced {
+			u.SetNamespace(namespace)
+		}
+	}
+
+	return append(objects, resources...), nil
+}
+
+// manifestObjects parses a manifest into its resources, in the order of the
+// manifest. Empty documents are skipped.
+func manifestObjects(manifest string) ([]*unstructured.Unstructured, error) {
+	manifests := releaseutil.SplitManifests(manifest)
+
+	keys := make([]string, 0, len(manifests))
+	for k := range manifests {
+		keys = append(keys, k)
+	}
+
+	sort.Sort(releaseutil.BySplitManifestsOrder(keys))
+
+	objects := make([]*unstructured.Unstructured, 0, len(keys))
+
+	for _, k := range keys {
+		u := &unstructured.Unstructured{}
+		if err := yaml.Unmarshal([]byte(manifests[k]), &u.Object); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal manifest: %w", err)
+		}
+
+		if len(u.Object) == 0 || u.GetKind() == "" {
+			continue
+		}
+
+		objects = append(objects, u)
+	}
+
+	return objects, nil
+}
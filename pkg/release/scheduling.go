@@ -0,0 +1,179 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release
+
+import (
+	"bytes"
+
+	"k8s.io/apimachinery/pkg/runtime"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+// schedulingRenderer is a helm post-renderer injecting a nodeSelector,
+// tolerations and an affinity into the pod specs of the rendered workloads.
+type schedulingRenderer struct {
+	scheduling *appv1.PodScheduling
+}
+
+func (r schedulingRenderer) Run(rendered *bytes.Buffer) (*bytes.Buffer, error) {
+	injected, err := injectScheduling(rendered.String(), r.scheduling)
+	if err != nil {
+		return nil, err
+	}
+
+	return bytes.NewBufferString(injected), nil
+}
+
+// injectScheduling adds the nodeSelector labels of scheduling to the pod specs
+// of the workloads of manifest, appends its tolerations except those they
+// already have, and replaces the parts of their affinity it sets.
+func injectScheduling(manifest string, scheduling *appv1.PodScheduling) (string, error) {
+	var tolerations []interface{}
+
+	for i := range scheduling.Tolerations {
+		t, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&scheduling.Tolerations[i])
+		if err != nil {
+			return "", err
+		}
+
+		tolerations = append(tolerations, t)
+	}
+
+	var affinity map[string]interface{}
+
+	if scheduling.Affinity != nil {
+		var err error
+		if affinity, err = runtime.DefaultUnstructuredConverter.ToUnstructured(scheduling.Affinity); err != nil {
+			return "", err
+		}
+	}
+
+	return transformPodSpecs(manifest, func(spec map[string]interface{}) bool {
+		injected := setNodeSelector(spec, scheduling.NodeSelector)
+		injected = appendTolerations(spec, tolerations) || injected
+
+		return setAffinity(spec, affinity) || injected
+	}), nil
+}
+
+// setNodeSelector sets the labels in the nodeSelector of the pod spec and
+// returns true if any changed.
+func setNodeSelector(spec map[string]interface{}, labels map[string]string) bool {
+	if len(labels) == 0 {
+		return false
+	}
+
+	selector, _ := spec["nodeSelector"].(map[string]interface{})
+	if selector == nil {
+		selector = map[string]interface{}{}
+	}
+
+	set := false
+
+	for k, v := range labels {
+		if current, ok := selector[k].(string); ok && current == v {
+			continue
+		}
+
+		selector[k] = v
+		set = true
+	}
+
+	if set {
+		spec["nodeSelector"] = selector
+	}
+
+	return set
+}
+
+// appendTolerations appends the tolerations missing from the pod spec and
+// returns true if any was. Two tolerations are the same if they match the
+// same taints.
+func appendTolerations(spec map[string]interface{}, tolerations []interface{}) bool {
+	existing, _ := spec["tolerations"].([]interface{})
+
+	appended := false
+
+	for _, t := range tolerations {
+		found := false
+
+		for _, e := range existing {
+			if sameToleration(e, t) {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			existing = append(existing, t)
+			appended = true
+		}
+	}
+
+	if appended {
+		spec["tolerations"] = existing
+	}
+
+	return appended
+}
+
+func sameToleration(a, b interface{}) bool {
+	ta, _ := a.(map[string]interface{})
+	tb, _ := b.(map[string]interface{})
+
+	for _, field := range []string{"key", "operator", "value", "effect"} {
+		va, _ := ta[field].(string)
+		vb, _ := tb[field].(string)
+
+		// Equal is the default operator
+		if field == "operator" {
+			if va == "" {
+				va = "Equal"
+			}
+
+			if vb == "" {
+				vb = "Equal"
+			}
+		}
+
+		if va != vb {
+			return false
+		}
+	}
+
+	return true
+}
+
+// setAffinity replaces the nodeAffinity, podAffinity and podAntiAffinity of
+// the pod spec set in affinity.
+func setAffinity(spec map[string]interface{}, affinity map[string]interface{}) bool {
+	if len(affinity) == 0 {
+		return false
+	}
+
+	current, _ := spec["affinity"].(map[string]interface{})
+	if current == nil {
+		current = map[string]interface{}{}
+	}
+
+	for k, v := range affinity {
+		current[k] = v
+	}
+
+	spec["affinity"] = current
+
+	return true
+}
@@ -0,0 +1,85 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+func TestInjectScheduling(t *testing.T) {
+	deployment := fmt.Sprintf(testDeploymentManifest, "1", 1, "nginx:1.19")
+	configMap := fmt.Sprintf(testConfigMapManifest, "a")
+	scheduling := &appv1.PodScheduling{
+		NodeSelector: map[string]string{"node-role.kubernetes.io/infra": ""},
+		Tolerations: []corev1.Toleration{{
+			Key:      "node-role.kubernetes.io/infra",
+			Operator: corev1.TolerationOpExists,
+			Effect:   corev1.TaintEffectNoSchedule,
+		}},
+		Affinity: &corev1.Affinity{
+			NodeAffinity: &corev1.NodeAffinity{
+				RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{
+					NodeSelectorTerms: []corev1.NodeSelectorTerm{{
+						MatchExpressions: []corev1.NodeSelectorRequirement{{
+							Key:      "kubernetes.io/arch",
+							Operator: corev1.NodeSelectorOpIn,
+							Values:   []string{"amd64"},
+						}},
+					}},
+				},
+			},
+		},
+	}
+
+	injected, err := injectScheduling(deployment+configMap, scheduling)
+	assert.NoError(t, err)
+
+	objects, err := manifestObjects(injected)
+	assert.NoError(t, err)
+	assert.Len(t, objects, 2)
+
+	spec := objects[0].Object
+	path := []string{"spec", "template", "spec"}
+
+	nodeSelector, _, _ := unstructured.NestedStringMap(spec, append(path, "nodeSelector")...)
+	assert.Equal(t, map[string]string{"node-role.kubernetes.io/infra": ""}, nodeSelector)
+
+	tolerations, _, _ := unstructured.NestedSlice(spec, append(path, "tolerations")...)
+	assert.Equal(t, []interface{}{map[string]interface{}{
+		"key":      "node-role.kubernetes.io/infra",
+		"operator": "Exists",
+		"effect":   "NoSchedule",
+	}}, tolerations)
+
+	_, found, _ := unstructured.NestedMap(spec, append(path, "affinity", "nodeAffinity")...)
+	assert.True(t, found)
+
+	// the comments and the other documents are kept
+	assert.True(t, strings.HasPrefix(injected, "---\n# Source: test/templates/deployment.yaml\n"))
+	assert.True(t, strings.HasSuffix(injected, configMap))
+
+	// the tolerations already there are not duplicated
+	again, err := injectScheduling(injected, scheduling)
+	assert.NoError(t, err)
+	assert.Equal(t, injected, again)
+}
@@ -0,0 +1,139 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release
+
+import (
+	"bytes"
+
+	"k8s.io/apimachinery/pkg/runtime"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+// securityContextRenderer is a helm post-renderer setting the securityContext
+// fields the pod specs and containers of the rendered workloads leave unset.
+type securityContextRenderer struct {
+	defaults *appv1.SecurityContextDefaults
+}
+
+func (r securityContextRenderer) Run(rendered *bytes.Buffer) (*bytes.Buffer, error) {
+	set, err := setSecurityContexts(rendered.String(), r.defaults)
+	if err != nil {
+		return nil, err
+	}
+
+	return bytes.NewBufferString(set), nil
+}
+
+// setSecurityContexts sets the default securityContext fields the pod specs of
+// the workloads of manifest, and their containers and init containers, leave
+// unset. The container defaults of the fields the pod securityContext sets,
+// e.g. runAsNonRoot, are not set so that the pod setting applies.
+func setSecurityContexts(manifest string, defaults *appv1.SecurityContextDefaults) (string, error) {
+	var pod, container map[string]interface{}
+
+	if defaults.Pod != nil {
+		var err error
+		if pod, err = runtime.DefaultUnstructuredConverter.ToUnstructured(defaults.Pod); err != nil {
+			return "", err
+		}
+	}
+
+	if defaults.Container != nil {
+		var err error
+		if container, err = runtime.DefaultUnstructuredConverter.ToUnstructured(defaults.Container); err != nil {
+			return "", err
+		}
+	}
+
+	return transformPodSpecs(manifest, func(spec map[string]interface{}) bool {
+		set := false
+
+		podContext, _ := spec["securityContext"].(map[string]interface{})
+
+		if len(pod) > 0 {
+			if podContext == nil {
+				podContext = map[string]interface{}{}
+			}
+
+			if setUnset(podContext, pod) {
+				spec["securityContext"] = podContext
+				set = true
+			}
+		}
+
+		containerDefaults := map[string]interface{}{}
+
+		for k, v := range container {
+			if _, ok := podContext[k]; !ok {
+				containerDefaults[k] = v
+			}
+		}
+
+		if len(containerDefaults) == 0 {
+			return set
+		}
+
+		// the ephemeral containers are not part of the templates
+		for _, field := range []string{"containers", "initContainers"} {
+			containers, _ := spec[field].([]interface{})
+
+			for _, c := range containers {
+				c, ok := c.(map[string]interface{})
+				if !ok {
+					continue
+				}
+
+				containerContext, _ := c["securityContext"].(map[string]interface{})
+				if containerContext == nil {
+					containerContext = map[string]interface{}{}
+				}
+
+				if setUnset(containerContext, containerDefaults) {
+					c["securityContext"] = containerContext
+					set = true
+				}
+			}
+		}
+
+		return set
+	}), nil
+}
+
+// setUnset sets the fields of defaults that obj leaves unset, recursing into
+// the objects both set, e.g. to drop capabilities when the chart adds some,
+// and returns true if any was set.
+func setUnset(obj, defaults map[string]interface{}) bool {
+	set := false
+
+	for k, v := range defaults {
+		existing, ok := obj[k]
+		if !ok {
+			obj[k] = runtime.DeepCopyJSONValue(v)
+			set = true
+
+			continue
+		}
+
+		existingObj, ok := existing.(map[string]interface{})
+		defaultObj, isObj := v.(map[string]interface{})
+
+		if ok && isObj && setUnset(existingObj, defaultObj) {
+			set = true
+		}
+	}
+
+	return set
+}
@@ -0,0 +1,107 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+const testPrivilegedDeploymentManifest = `---
+# Source: test/templates/privileged.yaml
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: privileged
+spec:
+  template:
+    spec:
+      securityContext:
+        runAsNonRoot: false
+      containers:
+      - name: test
+        image: nginx:1.19
+        securityContext:
+          capabilities:
+            add:
+            - NET_BIND_SERVICE
+`
+
+func TestSetSecurityContexts(t *testing.T) {
+	deployment := fmt.Sprintf(testDeploymentManifest, "1", 1, "nginx:1.19")
+	configMap := fmt.Sprintf(testConfigMapManifest, "a")
+
+	runAsNonRoot := true
+	allowPrivilegeEscalation := false
+	defaults := &appv1.SecurityContextDefaults{
+		Pod: &corev1.PodSecurityContext{
+			RunAsNonRoot:   &runAsNonRoot,
+			SeccompProfile: &corev1.SeccompProfile{Type: corev1.SeccompProfileTypeRuntimeDefault},
+		},
+		Container: &corev1.SecurityContext{
+			RunAsNonRoot:             &runAsNonRoot,
+			AllowPrivilegeEscalation: &allowPrivilegeEscalation,
+			Capabilities:             &corev1.Capabilities{Drop: []corev1.Capability{"ALL"}},
+		},
+	}
+
+	set, err := setSecurityContexts(deployment+testPrivilegedDeploymentManifest+configMap, defaults)
+	assert.NoError(t, err)
+
+	objects, err := manifestObjects(set)
+	assert.NoError(t, err)
+	assert.Len(t, objects, 3)
+
+	for _, u := range objects {
+		switch u.GetName() {
+		case "test":
+			nonRoot, _, _ := unstructured.NestedBool(u.Object, "spec", "template", "spec", "securityContext", "runAsNonRoot")
+			assert.True(t, nonRoot)
+
+			seccomp, _, _ := unstructured.NestedString(u.Object, "spec", "template", "spec", "securityContext", "seccompProfile", "type")
+			assert.Equal(t, "RuntimeDefault", seccomp)
+
+			containers, _, _ := unstructured.NestedSlice(u.Object, "spec", "template", "spec", "containers")
+			context := containers[0].(map[string]interface{})["securityContext"].(map[string]interface{})
+
+			// set by the pod
+			assert.NotContains(t, context, "runAsNonRoot")
+			assert.Equal(t, false, context["allowPrivilegeEscalation"])
+		case "privileged":
+			// the fields set by the chart are kept
+			nonRoot, _, _ := unstructured.NestedBool(u.Object, "spec", "template", "spec", "securityContext", "runAsNonRoot")
+			assert.False(t, nonRoot)
+
+			containers, _, _ := unstructured.NestedSlice(u.Object, "spec", "template", "spec", "containers")
+			capabilities, _, _ := unstructured.NestedMap(containers[0].(map[string]interface{}), "securityContext", "capabilities")
+			assert.Equal(t, []interface{}{"NET_BIND_SERVICE"}, capabilities["add"])
+			assert.Equal(t, []interface{}{"ALL"}, capabilities["drop"])
+		}
+	}
+
+	assert.True(t, strings.HasSuffix(set, configMap))
+
+	// the defaults already set are kept as is
+	again, err := setSecurityContexts(set, defaults)
+	assert.NoError(t, err)
+	assert.Equal(t, set, again)
+}
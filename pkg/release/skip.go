@@ -0,0 +1,82 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release
+
+import (
+	"bytes"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+// SkipAnnotation set to "true" on a rendered resource drops it before it is
+// applied, e.g. in a template of the chart or with a value of the chart.
+const SkipAnnotation = "apps.open-cluster-management.io/skip"
+
+// skipRenderer is a helm post-renderer dropping the skipped rendered
+// resources.
+type skipRenderer struct {
+	selectors []appv1.ResourceSelector
+	include   []appv1.ResourceKind
+	exclude   []appv1.ResourceKind
+}
+
+func (r skipRenderer) Run(rendered *bytes.Buffer) (*bytes.Buffer, error) {
+	return bytes.NewBufferString(r.skipResources(rendered.String())), nil
+}
+
+// skipResources drops the resources of manifest annotated with
+// SkipAnnotation, matching the selectors or filtered out by their kind.
+func (r skipRenderer) skipResources(manifest string) string {
+	// most releases skip nothing, their manifest is not parsed
+	if len(r.selectors) == 0 && len(r.include) == 0 && len(r.exclude) == 0 &&
+		!strings.Contains(manifest, SkipAnnotation) {
+		return manifest
+	}
+
+	return filterDocuments(manifest, func(u map[string]interface{}) bool {
+		return !r.skipped(&unstructured.Unstructured{Object: u})
+	})
+}
+
+func (r skipRenderer) skipped(u *unstructured.Unstructured) bool {
+	if u.GetAnnotations()[SkipAnnotation] == "true" {
+		return true
+	}
+
+	for _, s := range r.selectors {
+		if objectMatches(u, s.APIVersion, s.Kind, s.Name) {
+			return true
+		}
+	}
+
+	if len(r.include) > 0 && !kindListed(u, r.include) {
+		return true
+	}
+
+	return kindListed(u, r.exclude)
+}
+
+func kindListed(u *unstructured.Unstructured, kinds []appv1.ResourceKind) bool {
+	for _, k := range kinds {
+		if objectMatches(u, k.APIVersion, k.Kind, "") {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,78 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+const testSkippedConfigMapManifest = `---
+# Source: test/templates/skipped.yaml
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: skipped
+  annotations:
+    apps.open-cluster-management.io/skip: "true"
+`
+
+func TestSkipResources(t *testing.T) {
+	deployment := fmt.Sprintf(testDeploymentManifest, "1", 1, "nginx:1.19")
+	a := fmt.Sprintf(testConfigMapManifest, "a")
+	b := fmt.Sprintf(testConfigMapManifest, "b")
+	manifest := deployment + a + testSkippedConfigMapManifest + b
+
+	// the annotated resources are always skipped
+	assert.Equal(t, deployment+a+b, skipRenderer{}.skipResources(manifest))
+
+	r := skipRenderer{selectors: []appv1.ResourceSelector{{APIVersion: "v1", Kind: "ConfigMap", Name: "a"}}}
+	assert.Equal(t, deployment+b, r.skipResources(manifest))
+
+	// the first document keeps its separator
+	r = skipRenderer{selectors: []appv1.ResourceSelector{{Kind: "deployment"}}}
+	assert.Equal(t, a+b, r.skipResources(manifest))
+
+	r = skipRenderer{selectors: []appv1.ResourceSelector{{Kind: "ConfigMap"}}}
+	assert.Equal(t, deployment, r.skipResources(a+testSkippedConfigMapManifest+deployment))
+
+	// the manifests with nothing to skip are kept as is
+	r = skipRenderer{selectors: []appv1.ResourceSelector{{APIVersion: "v2", Kind: "ConfigMap"}}}
+	assert.Equal(t, deployment+a, r.skipResources(deployment+a))
+}
+
+func TestSkipResourcesKinds(t *testing.T) {
+	deployment := fmt.Sprintf(testDeploymentManifest, "1", 1, "nginx:1.19")
+	a := fmt.Sprintf(testConfigMapManifest, "a")
+	manifest := deployment + a
+
+	r := skipRenderer{include: []appv1.ResourceKind{{APIVersion: "apps/v1", Kind: "Deployment"}}}
+	assert.Equal(t, strings.TrimSuffix(deployment, "\n"), r.skipResources(manifest))
+
+	r = skipRenderer{exclude: []appv1.ResourceKind{{Kind: "Deployment"}}}
+	assert.Equal(t, a, r.skipResources(manifest))
+
+	// the excluded kinds win over the included ones
+	r = skipRenderer{
+		include: []appv1.ResourceKind{{Kind: "Deployment"}, {Kind: "ConfigMap"}},
+		exclude: []appv1.ResourceKind{{APIVersion: "v1", Kind: "ConfigMap"}},
+	}
+	assert.Equal(t, strings.TrimSuffix(deployment, "\n"), r.skipResources(manifest))
+}
@@ -0,0 +1,120 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	rpb "helm.sh/helm/v3/pkg/release"
+)
+
+// invalidSecretKey matches the characters not allowed in the keys of a Secret
+var invalidSecretKey = regexp.MustCompile(`[^-._a-zA-Z0-9]`)
+
+// ReferenceSecretValues returns a copy of values whose secret-shaped values,
+// e.g. a password, are replaced by a reference to the key of the Secret
+// secretName holding them, and the data of that Secret. The key is the path of
+// the value, e.g. db.password.
+func ReferenceSecretValues(values map[string]interface{}, secretName string) (map[string]interface{}, map[string][]byte) {
+	data := make(map[string][]byte)
+
+	var walk func(v interface{}, path []string, secret bool) interface{}
+
+	walk = func(v interface{}, path []string, secret bool) interface{} {
+		switch v := v.(type) {
+		case map[string]interface{}:
+			out := make(map[string]interface{}, len(v))
+			for k, child := range v {
+				out[k] = walk(child, append(path[:len(path):len(path)], k),
+					secret || (secretKey.MatchString(k) && !secretKeyExclusion.MatchString(k)))
+			}
+
+			return out
+		case []interface{}:
+			out := make([]interface{}, len(v))
+			for i, child := range v {
+				out[i] = walk(child, append(path[:len(path):len(path)], strconv.Itoa(i)), secret)
+			}
+
+			return out
+		case string:
+			if !secret || v == "" {
+				return v
+			}
+
+			key := invalidSecretKey.ReplaceAllString(strings.Join(path, "."), "_")
+			data[key] = []byte(v)
+
+			return SecretValueReference(secretName, key)
+		default:
+			return v
+		}
+	}
+
+	return walk(values, nil, false).(map[string]interface{}), data
+}
+
+// SecretValueReference is the placeholder of a secret value moved to the key
+// of the Secret secretName.
+func SecretValueReference(secretName, key string) string {
+	return fmt.Sprintf("secret:%s/%s", secretName, key)
+}
+
+// ReleaseSnapshot is what a snapshot captures of a revision of a release.
+type ReleaseSnapshot struct {
+	Chart        string
+	ChartVersion string
+	ChartDigest  string
+	// Values are the values of the revision in YAML, the secret values
+	// replaced by references to the keys of SecretValues
+	Values       string
+	ValuesDigest string
+	SecretValues map[string][]byte
+	// Manifest is the manifest of the revision, the Secret data redacted
+	Manifest string
+}
+
+// SnapshotRelease captures the revision rel, its secret values moved to the
+// Secret secretName.
+func SnapshotRelease(rel *rpb.Release, secretName string) (*ReleaseSnapshot, error) {
+	values, secretValues := ReferenceSecretValues(rel.Config, secretName)
+
+	b, err := yaml.Marshal(values)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode the values: %w", err)
+	}
+
+	snapshot := &ReleaseSnapshot{
+		Values:       string(b),
+		ValuesDigest: valuesDigest(rel.Config),
+		SecretValues: secretValues,
+		Manifest:     RedactManifest(rel.Manifest),
+	}
+
+	if rel.Chart != nil {
+		snapshot.ChartDigest = ChartDigest(rel.Chart)
+
+		if rel.Chart.Metadata != nil {
+			snapshot.Chart = rel.Chart.Metadata.Name
+			snapshot.ChartVersion = rel.Chart.Metadata.Version
+		}
+	}
+
+	return snapshot, nil
+}
@@ -0,0 +1,85 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	cpb "helm.sh/helm/v3/pkg/chart"
+	rpb "helm.sh/helm/v3/pkg/release"
+)
+
+func TestReferenceSecretValues(t *testing.T) {
+	values := map[string]interface{}{
+		"replicas": 3,
+		"db": map[string]interface{}{
+			"user":           "app",
+			"password":       "hunter2",
+			"existingSecret": "db-credentials",
+		},
+		"auth": map[string]interface{}{
+			"tokens": []interface{}{"abc", ""},
+		},
+	}
+
+	referenced, data := ReferenceSecretValues(values, "app-v3-values")
+
+	assert.Equal(t, map[string]interface{}{
+		"replicas": 3,
+		"db": map[string]interface{}{
+			"user":           "app",
+			"password":       "secret:app-v3-values/db.password",
+			"existingSecret": "db-credentials",
+		},
+		"auth": map[string]interface{}{
+			"tokens": []interface{}{"secret:app-v3-values/auth.tokens.0", ""},
+		},
+	}, referenced)
+	assert.Equal(t, map[string][]byte{
+		"db.password":   []byte("hunter2"),
+		"auth.tokens.0": []byte("abc"),
+	}, data)
+
+	// the values are not modified
+	assert.Equal(t, "hunter2", values["db"].(map[string]interface{})["password"])
+}
+
+func TestReferenceSecretValuesKeys(t *testing.T) {
+	referenced, data := ReferenceSecretValues(map[string]interface{}{
+		"extraEnv": map[string]interface{}{"API_KEY/v2": "k3y"},
+	}, "values")
+
+	assert.Equal(t, map[string][]byte{"extraEnv.API_KEY_v2": []byte("k3y")}, data)
+	assert.Equal(t, "secret:values/extraEnv.API_KEY_v2", referenced["extraEnv"].(map[string]interface{})["API_KEY/v2"])
+}
+
+func TestSnapshotRelease(t *testing.T) {
+	rel := newTestRelease(3, rpb.StatusDeployed)
+	rel.Chart = &cpb.Chart{Metadata: &cpb.Metadata{Name: "app", Version: "1.2.0"}}
+	rel.Config = map[string]interface{}{"db": map[string]interface{}{"password": "hunter2"}}
+	rel.Manifest = "apiVersion: v1\nkind: Secret\nmetadata:\n  name: db\nstringData:\n  password: hunter2\n"
+
+	snapshot, err := SnapshotRelease(rel, "app.v3-values")
+	assert.NoError(t, err)
+
+	assert.Equal(t, "app", snapshot.Chart)
+	assert.Equal(t, "1.2.0", snapshot.ChartVersion)
+	assert.Equal(t, ChartDigest(rel.Chart), snapshot.ChartDigest)
+	assert.Equal(t, "db:\n  password: secret:app.v3-values/db.password\n", snapshot.Values)
+	assert.Equal(t, valuesDigest(rel.Config), snapshot.ValuesDigest)
+	assert.Equal(t, map[string][]byte{"db.password": []byte("hunter2")}, snapshot.SecretValues)
+	assert.NotContains(t, snapshot.Manifest, "hunter2")
+}
@@ -0,0 +1,157 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"helm.sh/helm/v3/pkg/storage"
+	"helm.sh/helm/v3/pkg/storage/driver"
+	v1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/rest"
+)
+
+// Helm storage drivers the release records can be stored with
+const (
+	SecretsStorageDriver    = "secret"
+	ConfigMapsStorageDriver = "configmap"
+	SQLStorageDriver        = "sql"
+)
+
+// StorageOptions configures the helm storage backend of the releases
+type StorageOptions struct {
+	// Driver is one of secret (default), configmap or sql
+	Driver string
+	// SQLConnectionString is the postgres connection string used by the sql driver
+	SQLConnectionString string
+	// VaultAddress is the address of the Vault server encrypting the release payloads
+	VaultAddress string
+	// VaultTransitKey is the mount/name of the Vault transit key encrypting the data keys of the
+	// release payloads, e.g. transit/helm-releases. The payloads are stored in clear if it is empty.
+	VaultTransitKey string
+	// VaultTokenFile is the file holding the Vault token, the VAULT_TOKEN environment variable is
+	// used if it is empty
+	VaultTokenFile string
+}
+
+// Validate returns an error if the options do not describe a usable storage backend
+func (o StorageOptions) Validate() error {
+	if o.VaultTransitKey != "" {
+		if o.VaultAddress == "" {
+			return fmt.Errorf("the encryption of the release payloads requires the vault address")
+		}
+
+		if i := strings.LastIndex(o.VaultTransitKey, "/"); i <= 0 || i == len(o.VaultTransitKey)-1 {
+			return fmt.Errorf("the vault transit key %q must be a mount/name", o.VaultTransitKey)
+		}
+	}
+
+	switch o.Driver {
+	case "", SecretsStorageDriver, ConfigMapsStorageDriver:
+		return nil
+	case SQLStorageDriver:
+		if o.SQLConnectionString == "" {
+			return fmt.Errorf("the %s storage driver requires a connection string", SQLStorageDriver)
+		}
+
+		return nil
+	default:
+		return fmt.Errorf("unknown helm storage driver %q, expected one of %s, %s or %s",
+			o.Driver, SecretsStorageDriver, ConfigMapsStorageDriver, SQLStorageDriver)
+	}
+}
+
+// storageProbeName is the release name queried to check that the storage
+// backend is reachable, no release is expected to have it
+const storageProbeName = "storage-probe"
+
+// PingStorage returns an error if the release records of namespace cannot be
+// read from the storage backend, all namespaces if it is empty.
+func PingStorage(cfg *rest.Config, opts StorageOptions, namespace string) error {
+	d, err := newStorageDriver(cfg, opts, namespace)
+	if err != nil {
+		return err
+	}
+
+	if _, err := d.Query(map[string]string{"owner": "helm", "name": storageProbeName}); err != nil && !notFoundErr(err) {
+		return storageFailed(fmt.Errorf("failed to query the release records: %w", err))
+	}
+
+	return nil
+}
+
+var (
+	// sqlDrivers reuses the database connection of the sql driver across
+	// reconciles, keyed by namespace
+	sqlDrivers   = map[string]*driver.SQL{}
+	sqlDriversMu sync.Mutex
+)
+
+// newStorage returns the storage backend holding the release records of
+// namespace, encrypting the release payloads if a transit key is set. The
+// queries of the records are listed in pages and their results briefly
+// reused.
+func newStorage(cfg *rest.Config, opts StorageOptions, namespace string) (*storage.Storage, error) {
+	d, err := newStorageDriver(cfg, opts, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	// the records are cached encrypted
+	d = newCachingDriver(d, cfg, namespace)
+
+	if opts.VaultTransitKey != "" {
+		d = &encryptedDriver{Driver: d, keys: newVaultTransit(opts)}
+	}
+
+	return storage.Init(d), nil
+}
+
+func newStorageDriver(cfg *rest.Config, opts StorageOptions, namespace string) (driver.Driver, error) {
+	switch opts.Driver {
+	case "", SecretsStorageDriver, ConfigMapsStorageDriver:
+		clientv1, err := v1.NewForConfig(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get core/v1 client: %w", err)
+		}
+
+		if opts.Driver == ConfigMapsStorageDriver {
+			return newPagedConfigMaps(clientv1.ConfigMaps(namespace)), nil
+		}
+
+		return newPagedSecrets(clientv1.Secrets(namespace)), nil
+	case SQLStorageDriver:
+		sqlDriversMu.Lock()
+		defer sqlDriversMu.Unlock()
+
+		d, ok := sqlDrivers[namespace]
+		if !ok {
+			var err error
+
+			d, err = driver.NewSQL(opts.SQLConnectionString, func(_ string, _ ...interface{}) {}, namespace)
+			if err != nil {
+				return nil, fmt.Errorf("failed to connect to the helm storage database: %w", err)
+			}
+
+			sqlDrivers[namespace] = d
+		}
+
+		return d, nil
+	default:
+		return nil, opts.Validate()
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"helm.sh/helm/v3/pkg/storage/driver"
+	"k8s.io/client-go/rest"
+)
+
+func TestStorageOptionsValidate(t *testing.T) {
+	assert.NoError(t, StorageOptions{}.Validate())
+	assert.NoError(t, StorageOptions{Driver: ConfigMapsStorageDriver}.Validate())
+	assert.NoError(t, StorageOptions{Driver: SQLStorageDriver, SQLConnectionString: "host=db"}.Validate())
+	assert.Error(t, StorageOptions{Driver: SQLStorageDriver}.Validate())
+	assert.Error(t, StorageOptions{Driver: "memory"}.Validate())
+}
+
+func TestNewStorage(t *testing.T) {
+	cfg := &rest.Config{Host: "https://localhost:6443"}
+
+	for opts, name := range map[StorageOptions]string{
+		{}:                                driver.SecretsDriverName,
+		{Driver: SecretsStorageDriver}:    driver.SecretsDriverName,
+		{Driver: ConfigMapsStorageDriver}: driver.ConfigMapsDriverName,
+	} {
+		s, err := newStorage(cfg, opts, "default")
+		require.NoError(t, err)
+		assert.Equal(t, name, s.Name(), opts.Driver)
+	}
+
+	_, err := newStorage(cfg, StorageOptions{Driver: "memory"}, "default")
+	assert.Error(t, err)
+}
+
+func TestPingStorage(t *testing.T) {
+	failing := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failing {
+			http.Error(w, "etcdserver: request timed out", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"kind":"SecretList","apiVersion":"v1","items":[]}`))
+	}))
+	defer server.Close()
+
+	cfg := &rest.Config{Host: server.URL}
+
+	// no release is found, the storage is reachable
+	require.NoError(t, PingStorage(cfg, StorageOptions{}, "default"))
+
+	failing = true
+	err := PingStorage(cfg, StorageOptions{}, "default")
+	assert.True(t, errors.Is(err, ErrStorageFailed), "unexpected error %v", err)
+}
@@ -0,0 +1,437 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	cpb "helm.sh/helm/v3/pkg/chart"
+	rpb "helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/storage"
+	"helm.sh/helm/v3/pkg/storage/driver"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kblabels "k8s.io/apimachinery/pkg/labels"
+	v1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/rest"
+	"k8s.io/klog"
+)
+
+// recordsPageSize is the number of release records listed per request, so
+// that the namespaces with thousands of records are listed in pages
+const recordsPageSize = 100
+
+// releaseQueryTTL is how long the release records queried from the storage
+// backend are reused, unless the operator writes the records of the release.
+// The records written by others, e.g. with the helm CLI, are seen after at
+// most this long.
+const releaseQueryTTL = 15 * time.Second
+
+// recordLister lists a page of encoded release records and returns the
+// continue token of the next page, if any.
+type recordLister func(opts metav1.ListOptions) (records []string, next string, err error)
+
+// pagedSecrets queries the release records stored in Secrets page by page.
+type pagedSecrets struct {
+	*driver.Secrets
+	impl v1.SecretInterface
+}
+
+func newPagedSecrets(impl v1.SecretInterface) *pagedSecrets {
+	return &pagedSecrets{Secrets: driver.NewSecrets(impl), impl: impl}
+}
+
+func (d *pagedSecrets) list(opts metav1.ListOptions) ([]string, string, error) {
+	l, err := d.impl.List(context.TODO(), opts)
+	if err != nil {
+		return nil, "", err
+	}
+
+	records := make([]string, 0, len(l.Items))
+	for _, item := range l.Items {
+		records = append(records, string(item.Data["release"]))
+	}
+
+	return records, l.Continue, nil
+}
+
+func (d *pagedSecrets) Query(labels map[string]string) ([]*rpb.Release, error) {
+	return queryRecords(d.list, labels)
+}
+
+func (d *pagedSecrets) List(filter func(*rpb.Release) bool) ([]*rpb.Release, error) {
+	return listRecords(d.list, filter)
+}
+
+// pagedConfigMaps queries the release records stored in ConfigMaps page by
+// page.
+type pagedConfigMaps struct {
+	*driver.ConfigMaps
+	impl v1.ConfigMapInterface
+}
+
+func newPagedConfigMaps(impl v1.ConfigMapInterface) *pagedConfigMaps {
+	return &pagedConfigMaps{ConfigMaps: driver.NewConfigMaps(impl), impl: impl}
+}
+
+func (d *pagedConfigMaps) list(opts metav1.ListOptions) ([]string, string, error) {
+	l, err := d.impl.List(context.TODO(), opts)
+	if err != nil {
+		return nil, "", err
+	}
+
+	records := make([]string, 0, len(l.Items))
+	for _, item := range l.Items {
+		records = append(records, item.Data["release"])
+	}
+
+	return records, l.Continue, nil
+}
+
+func (d *pagedConfigMaps) Query(labels map[string]string) ([]*rpb.Release, error) {
+	return queryRecords(d.list, labels)
+}
+
+func (d *pagedConfigMaps) List(filter func(*rpb.Release) bool) ([]*rpb.Release, error) {
+	return listRecords(d.list, filter)
+}
+
+// queryRecords returns the releases whose records have labels, selected by
+// the API server. Like the helm drivers, it returns ErrReleaseNotFound if
+// there is none.
+func queryRecords(list recordLister, labels map[string]string) ([]*rpb.Release, error) {
+	records, err := listPages(list, kblabels.Set(labels).AsSelector().String())
+	if err != nil {
+		return nil, fmt.Errorf("query: failed to query with labels: %w", err)
+	}
+
+	if len(records) == 0 {
+		return nil, driver.ErrReleaseNotFound
+	}
+
+	return decodeRecords(records, func(*rpb.Release) bool { return true }), nil
+}
+
+// listRecords returns the releases of the records of helm accepted by filter.
+func listRecords(list recordLister, filter func(*rpb.Release) bool) ([]*rpb.Release, error) {
+	records, err := listPages(list, kblabels.Set{"owner": "helm"}.AsSelector().String())
+	if err != nil {
+		return nil, fmt.Errorf("list: failed to list: %w", err)
+	}
+
+	return decodeRecords(records, filter), nil
+}
+
+func listPages(list recordLister, selector string) ([]string, error) {
+	var records []string
+
+	opts := metav1.ListOptions{LabelSelector: selector, Limit: recordsPageSize}
+
+	for {
+		page, next, err := list(opts)
+		if err != nil {
+			return nil, err
+		}
+
+		records = append(records, page...)
+
+		if next == "" {
+			return records, nil
+		}
+
+		opts.Continue = next
+	}
+}
+
+// decodeRecords decodes the records accepted by filter, skipping the corrupted
+// ones like the helm drivers do.
+func decodeRecords(records []string, filter func(*rpb.Release) bool) []*rpb.Release {
+	var releases []*rpb.Release
+
+	for _, record := range records {
+		rls, err := decodeRecord(record)
+		if err != nil {
+			klog.Warning("Skipping a corrupted release record: ", err)
+			continue
+		}
+
+		if filter(rls) {
+			releases = append(releases, rls)
+		}
+	}
+
+	return releases
+}
+
+var gzipMagic = []byte{0x1f, 0x8b, 0x08}
+
+// decodeRecord decodes a release record as encoded by helm, the base64 of its
+// gzipped JSON.
+func decodeRecord(data string) (*rpb.Release, error) {
+	b, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if bytes.HasPrefix(b, gzipMagic) {
+		r, err := gzip.NewReader(bytes.NewReader(b))
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+
+		if b, err = ioutil.ReadAll(r); err != nil {
+			return nil, err
+		}
+	}
+
+	var rls rpb.Release
+	if err := json.Unmarshal(b, &rls); err != nil {
+		return nil, err
+	}
+
+	return &rls, nil
+}
+
+// cachingDriver reuses the results of the queries of the release records for
+// releaseQueryTTL, e.g. the history and the deployed release queried several
+// times by each sync. The results of a release are dropped as soon as the
+// operator writes its records, through any cachingDriver of the same scope.
+// The queried releases only keep the metadata of their chart, the chart of a
+// revision is read again from its record when needed.
+type cachingDriver struct {
+	driver.Driver
+	// scope is the driver, cluster and namespace of the records
+	scope string
+	// user is the impersonated user reading them, if any
+	user string
+}
+
+type queryResult struct {
+	name     string
+	releases []*rpb.Release
+	expires  time.Time
+}
+
+var (
+	releaseQueries   = map[string]queryResult{}
+	releaseQueriesMu sync.Mutex
+	// releaseQueriesPruned is when the expired results were last dropped
+	releaseQueriesPruned time.Time
+)
+
+func newCachingDriver(d driver.Driver, cfg *rest.Config, namespace string) *cachingDriver {
+	return &cachingDriver{Driver: d, scope: d.Name() + "/" + cfg.Host + "/" + namespace, user: cfg.Impersonate.UserName}
+}
+
+func (d *cachingDriver) Query(labels map[string]string) ([]*rpb.Release, error) {
+	key := d.queryKey(labels)
+
+	releaseQueriesMu.Lock()
+	result, ok := releaseQueries[key]
+	releaseQueriesMu.Unlock()
+
+	if !ok || time.Now().After(result.expires) {
+		releases, err := d.Driver.Query(labels)
+		if err != nil && !notFoundErr(err) {
+			return nil, err
+		}
+
+		stripped := make([]*rpb.Release, 0, len(releases))
+		for _, rls := range releases {
+			stripped = append(stripped, stripChart(rls))
+		}
+
+		now := time.Now()
+		result = queryResult{name: labels["name"], releases: stripped, expires: now.Add(releaseQueryTTL)}
+
+		releaseQueriesMu.Lock()
+		pruneReleaseQueries(now)
+		releaseQueries[key] = result
+		releaseQueriesMu.Unlock()
+	}
+
+	if len(result.releases) == 0 {
+		return nil, driver.ErrReleaseNotFound
+	}
+
+	// helm modifies the releases it reads, e.g. their status
+	releases := make([]*rpb.Release, 0, len(result.releases))
+	for _, rls := range result.releases {
+		releases = append(releases, copyRelease(rls))
+	}
+
+	return releases, nil
+}
+
+func (d *cachingDriver) Create(key string, rls *rpb.Release) error {
+	defer d.forget(rls.Name)
+
+	return d.Driver.Create(key, rls)
+}
+
+func (d *cachingDriver) Update(key string, rls *rpb.Release) error {
+	defer d.forget(rls.Name)
+
+	// helm updates the releases it queried, e.g. to supersede them
+	if chartStripped(rls) {
+		stored, err := d.Driver.Get(key)
+		if err != nil {
+			return err
+		}
+
+		full := *rls
+		full.Chart = stored.Chart
+		rls = &full
+	}
+
+	return d.Driver.Update(key, rls)
+}
+
+func (d *cachingDriver) Delete(key string) (*rpb.Release, error) {
+	defer d.forget(recordName(key))
+
+	return d.Driver.Delete(key)
+}
+
+func (d *cachingDriver) queryKey(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for k, v := range labels {
+		keys = append(keys, k+"="+v)
+	}
+
+	sort.Strings(keys)
+
+	return d.scope + "?" + d.user + "?" + strings.Join(keys, ",")
+}
+
+// forget drops the cached results of the release name, and those of the
+// queries not selecting a release.
+func (d *cachingDriver) forget(name string) {
+	releaseQueriesMu.Lock()
+	defer releaseQueriesMu.Unlock()
+
+	for key, result := range releaseQueries {
+		if strings.HasPrefix(key, d.scope+"?") && (result.name == "" || result.name == name) {
+			delete(releaseQueries, key)
+		}
+	}
+}
+
+// pruneReleaseQueries drops the expired results, at most once per
+// releaseQueryTTL so that a result is kept at most twice as long. It must be
+// called with releaseQueriesMu held.
+func pruneReleaseQueries(now time.Time) {
+	if now.Sub(releaseQueriesPruned) < releaseQueryTTL {
+		return
+	}
+
+	for key, result := range releaseQueries {
+		if now.After(result.expires) {
+			delete(releaseQueries, key)
+		}
+	}
+
+	releaseQueriesPruned = now
+}
+
+// stripChart returns a copy of rls whose chart only keeps its metadata, by
+// which helm lists the releases.
+func stripChart(rls *rpb.Release) *rpb.Release {
+	if rls.Chart == nil {
+		return rls
+	}
+
+	out := *rls
+	out.Chart = &cpb.Chart{Metadata: rls.Chart.Metadata}
+
+	return &out
+}
+
+// chartStripped returns true if the chart of rls may have been stripped by
+// the query cache. The chart of the encrypted releases is in their payload.
+func chartStripped(rls *rpb.Release) bool {
+	chrt := rls.Chart
+
+	return chrt != nil && chrt.Templates == nil && chrt.Values == nil && chrt.Files == nil &&
+		chrt.Schema == nil && chrt.Lock == nil && len(chrt.Dependencies()) == 0 &&
+		!strings.HasPrefix(rls.Manifest, encryptedManifestPrefix)
+}
+
+// fullRelease returns rls with its chart, read again from its record if it
+// was stripped by the query cache.
+func fullRelease(s *storage.Storage, rls *rpb.Release) (*rpb.Release, error) {
+	if !chartStripped(rls) {
+		return rls, nil
+	}
+
+	return s.Get(rls.Name, rls.Version)
+}
+
+// fullReleases returns releases with their chart, see fullRelease.
+func fullReleases(s *storage.Storage, releases []*rpb.Release) ([]*rpb.Release, error) {
+	full := make([]*rpb.Release, 0, len(releases))
+
+	for _, rls := range releases {
+		rls, err := fullRelease(s, rls)
+		if err != nil {
+			return nil, err
+		}
+
+		full = append(full, rls)
+	}
+
+	return full, nil
+}
+
+// recordName returns the release name of the record key, e.g.
+// sh.helm.release.v1.nginx.v3.
+func recordName(key string) string {
+	name := strings.TrimPrefix(key, "sh.helm.release.v1.")
+	if i := strings.LastIndex(name, ".v"); i > 0 {
+		name = name[:i]
+	}
+
+	return name
+}
+
+// copyRelease returns a copy of rls whose info and hooks can be modified.
+func copyRelease(rls *rpb.Release) *rpb.Release {
+	out := *rls
+
+	if rls.Info != nil {
+		info := *rls.Info
+		out.Info = &info
+	}
+
+	if rls.Hooks != nil {
+		out.Hooks = make([]*rpb.Hook, 0, len(rls.Hooks))
+		for _, h := range rls.Hooks {
+			hook := *h
+			out.Hooks = append(out.Hooks, &hook)
+		}
+	}
+
+	return &out
+}
@@ -0,0 +1,199 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	cpb "helm.sh/helm/v3/pkg/chart"
+	rpb "helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/storage"
+	"helm.sh/helm/v3/pkg/storage/driver"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
+)
+
+// countingDriver counts the queries of the driver it wraps
+type countingDriver struct {
+	driver.Driver
+	queries int
+}
+
+func (d *countingDriver) Query(labels map[string]string) ([]*rpb.Release, error) {
+	d.queries++
+	return d.Driver.Query(labels)
+}
+
+// encodeRecord encodes rls as helm does
+func encodeRecord(t *testing.T, rls *rpb.Release) string {
+	b, err := json.Marshal(rls)
+	assert.NoError(t, err)
+
+	var buf bytes.Buffer
+
+	w := gzip.NewWriter(&buf)
+	_, err = w.Write(b)
+	assert.NoError(t, err)
+	assert.NoError(t, w.Close())
+
+	return base64.StdEncoding.EncodeToString(buf.Bytes())
+}
+
+func TestQueryRecords(t *testing.T) {
+	pages := [][]string{
+		{encodeRecord(t, &rpb.Release{Name: "app", Version: 1}), "corrupted"},
+		{encodeRecord(t, &rpb.Release{Name: "app", Version: 2})},
+	}
+
+	var requests []metav1.ListOptions
+
+	list := func(opts metav1.ListOptions) ([]string, string, error) {
+		requests = append(requests, opts)
+
+		if opts.Continue == "" {
+			return pages[0], "next", nil
+		}
+
+		return pages[1], "", nil
+	}
+
+	releases, err := queryRecords(list, map[string]string{"name": "app", "owner": "helm"})
+	assert.NoError(t, err)
+	assert.Len(t, releases, 2)
+	assert.Equal(t, 2, releases[1].Version)
+
+	assert.Len(t, requests, 2)
+	assert.Equal(t, "name=app,owner=helm", requests[0].LabelSelector)
+	assert.Equal(t, int64(recordsPageSize), requests[0].Limit)
+	assert.Equal(t, "next", requests[1].Continue)
+
+	_, err = queryRecords(func(metav1.ListOptions) ([]string, string, error) { return nil, "", nil },
+		map[string]string{"name": "missing"})
+	assert.True(t, notFoundErr(err))
+}
+
+func TestCachingDriver(t *testing.T) {
+	counting := &countingDriver{Driver: driver.NewMemory()}
+	d := newCachingDriver(counting, &rest.Config{Host: "https://cluster.example.com"}, "default")
+
+	labels := map[string]string{"name": "app", "owner": "helm"}
+
+	_, err := d.Query(labels)
+	assert.True(t, notFoundErr(err))
+
+	// the missing release is cached too
+	_, err = d.Query(labels)
+	assert.True(t, notFoundErr(err))
+	assert.Equal(t, 1, counting.queries)
+
+	rls := &rpb.Release{Name: "app", Version: 1, Info: &rpb.Info{Status: rpb.StatusDeployed}}
+	assert.NoError(t, d.Create("sh.helm.release.v1.app.v1", rls))
+
+	releases, err := d.Query(labels)
+	assert.NoError(t, err)
+	assert.Len(t, releases, 1)
+	assert.Equal(t, 2, counting.queries)
+
+	// the cached releases are copied
+	releases[0].Info.Status = rpb.StatusSuperseded
+
+	releases, err = d.Query(labels)
+	assert.NoError(t, err)
+	assert.Equal(t, rpb.StatusDeployed, releases[0].Info.Status)
+	assert.Equal(t, 2, counting.queries)
+
+	// the other releases are still cached
+	_, err = d.Query(map[string]string{"name": "other", "owner": "helm"})
+	assert.True(t, notFoundErr(err))
+	assert.Equal(t, 3, counting.queries)
+
+	_, err = d.Delete("sh.helm.release.v1.app.v1")
+	assert.NoError(t, err)
+
+	_, err = d.Query(labels)
+	assert.True(t, notFoundErr(err))
+	assert.Equal(t, 4, counting.queries)
+
+	_, err = d.Query(map[string]string{"name": "other", "owner": "helm"})
+	assert.True(t, notFoundErr(err))
+	assert.Equal(t, 4, counting.queries)
+}
+
+func TestCachingDriverStripsCharts(t *testing.T) {
+	d := newCachingDriver(driver.NewMemory(), &rest.Config{Host: "https://charts.example.com"}, "default")
+	s := storage.Init(d)
+
+	chrt := &cpb.Chart{
+		Metadata:  &cpb.Metadata{Name: "nginx", Version: "1.0.0"},
+		Templates: []*cpb.File{{Name: "templates/cm.yaml", Data: []byte("kind: ConfigMap")}},
+	}
+	assert.NoError(t, s.Create(&rpb.Release{Name: "web", Version: 1, Chart: chrt,
+		Info: &rpb.Info{Status: rpb.StatusDeployed}}))
+
+	// the cached releases only keep the metadata of their chart
+	deployed, err := s.Deployed("web")
+	require.NoError(t, err)
+	assert.Equal(t, "nginx", deployed.Chart.Name())
+	assert.Nil(t, deployed.Chart.Templates)
+	assert.True(t, chartStripped(deployed))
+
+	full, err := fullRelease(s, deployed)
+	require.NoError(t, err)
+	assert.Equal(t, chrt.Templates, full.Chart.Templates)
+
+	// the chart of an updated release is kept
+	deployed.Info.Status = rpb.StatusSuperseded
+	assert.NoError(t, s.Update(deployed))
+
+	stored, err := s.Get("web", 1)
+	require.NoError(t, err)
+	assert.Equal(t, rpb.StatusSuperseded, stored.Info.Status)
+	assert.Equal(t, chrt.Templates, stored.Chart.Templates)
+}
+
+func TestPruneReleaseQueries(t *testing.T) {
+	releaseQueriesMu.Lock()
+	defer releaseQueriesMu.Unlock()
+
+	now := time.Now()
+	releaseQueriesPruned = now.Add(-releaseQueryTTL)
+	releaseQueries["expired"] = queryResult{expires: now.Add(-time.Second)}
+	releaseQueries["live"] = queryResult{expires: now.Add(time.Second)}
+
+	defer delete(releaseQueries, "live")
+
+	pruneReleaseQueries(now)
+	assert.NotContains(t, releaseQueries, "expired")
+	assert.Contains(t, releaseQueries, "live")
+
+	// the results are pruned at most once per TTL
+	releaseQueries["expired"] = queryResult{expires: now.Add(-time.Second)}
+	defer delete(releaseQueries, "expired")
+
+	pruneReleaseQueries(now.Add(time.Second))
+	assert.Contains(t, releaseQueries, "expired")
+}
+
+func TestRecordName(t *testing.T) {
+	assert.Equal(t, "app", recordName("sh.helm.release.v1.app.v1"))
+	assert.Equal(t, "my.app", recordName("sh.helm.release.v1.my.app.v12"))
+}
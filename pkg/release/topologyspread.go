@@ -0,0 +1,80 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release
+
+import (
+	"bytes"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+// topologySpreadRenderer is a helm post-renderer adding topology spread
+// constraints to the rendered Deployments and StatefulSets.
+type topologySpreadRenderer struct {
+	spread *appv1.TopologySpread
+}
+
+func (r topologySpreadRenderer) Run(rendered *bytes.Buffer) (*bytes.Buffer, error) {
+	return bytes.NewBufferString(setTopologySpread(rendered.String(), r.spread)), nil
+}
+
+// setTopologySpread adds a topology spread constraint for each topology key
+// of spread to the Deployments and StatefulSets of manifest that have none.
+// The constraints select the pods of their workload with its selector, the
+// workloads without a selector are left as is.
+func setTopologySpread(manifest string, spread *appv1.TopologySpread) string {
+	maxSkew := int64(spread.MaxSkew)
+	if maxSkew == 0 {
+		maxSkew = 1
+	}
+
+	whenUnsatisfiable := spread.WhenUnsatisfiable
+	if whenUnsatisfiable == "" {
+		whenUnsatisfiable = corev1.ScheduleAnyway
+	}
+
+	return transformDocuments(manifest, func(u map[string]interface{}) bool {
+		if kind, _ := u["kind"].(string); kind != "Deployment" && kind != "StatefulSet" {
+			return false
+		}
+
+		selector, found, err := unstructured.NestedMap(u, "spec", "selector")
+		if !found || err != nil || len(selector) == 0 {
+			return false
+		}
+
+		path := []string{"spec", "template", "spec", "topologySpreadConstraints"}
+
+		if constraints, _, _ := unstructured.NestedSlice(u, path...); len(constraints) > 0 {
+			return false
+		}
+
+		constraints := make([]interface{}, 0, len(spread.TopologyKeys))
+
+		for _, key := range spread.TopologyKeys {
+			constraints = append(constraints, map[string]interface{}{
+				"maxSkew":           maxSkew,
+				"topologyKey":       key,
+				"whenUnsatisfiable": string(whenUnsatisfiable),
+				"labelSelector":     selector,
+			})
+		}
+
+		return unstructured.SetNestedSlice(u, constraints, path...) == nil
+	})
+}
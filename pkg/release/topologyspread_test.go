@@ -0,0 +1,86 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+const testSelectedDeploymentManifest = `---
+# Source: test/templates/web.yaml
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  selector:
+    matchLabels:
+      app: web
+  template:
+    metadata:
+      labels:
+        app: web
+    spec:
+      containers:
+      - name: web
+        image: nginx:1.19
+`
+
+func TestSetTopologySpread(t *testing.T) {
+	// without a selector
+	deployment := fmt.Sprintf(testDeploymentManifest, "1", 1, "nginx:1.19")
+	configMap := fmt.Sprintf(testConfigMapManifest, "a")
+
+	spread := &appv1.TopologySpread{
+		TopologyKeys:      []string{"topology.kubernetes.io/zone", "kubernetes.io/hostname"},
+		WhenUnsatisfiable: corev1.DoNotSchedule,
+	}
+
+	set := setTopologySpread(deployment+testSelectedDeploymentManifest+configMap, spread)
+
+	objects, err := manifestObjects(set)
+	assert.NoError(t, err)
+	assert.Len(t, objects, 3)
+
+	for _, u := range objects {
+		constraints, _, _ := unstructured.NestedSlice(u.Object, "spec", "template", "spec", "topologySpreadConstraints")
+
+		if u.GetName() != "web" {
+			assert.Empty(t, constraints)
+			continue
+		}
+
+		assert.Len(t, constraints, 2)
+
+		zone := constraints[0].(map[string]interface{})
+		assert.Equal(t, "topology.kubernetes.io/zone", zone["topologyKey"])
+		assert.Equal(t, "DoNotSchedule", zone["whenUnsatisfiable"])
+		assert.EqualValues(t, 1, zone["maxSkew"])
+		assert.Equal(t, map[string]interface{}{"matchLabels": map[string]interface{}{"app": "web"}}, zone["labelSelector"])
+	}
+
+	assert.True(t, strings.HasSuffix(set, configMap))
+
+	// the constraints of the chart are kept
+	assert.Equal(t, set, setTopologySpread(set, &appv1.TopologySpread{TopologyKeys: []string{"kubernetes.io/hostname"}}))
+}
@@ -0,0 +1,376 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release
+
+import (
+	"fmt"
+	"sync"
+
+	"helm.sh/helm/v3/pkg/postrender"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+// The names of the built-in transformers, in the order they run.
+const (
+	SkipTransformer             = "skip"
+	NamespaceTransformer        = "namespace"
+	PullSecretsTransformer      = "pullSecrets"
+	MirrorsTransformer          = "mirrors"
+	PullPolicyTransformer       = "pullPolicy"
+	SchedulingTransformer       = "scheduling"
+	TopologySpreadTransformer   = "topologySpread"
+	DefaultResourcesTransformer = "defaultResources"
+	SecurityContextTransformer  = "securityContext"
+	EnvTransformer              = "env"
+	PriorityClassTransformer    = "priorityClass"
+	CommonMetadataTransformer   = "commonMetadata"
+	PatchesTransformer          = "patches"
+)
+
+// Transformer transforms the rendered manifest of the releases before it is
+// applied, as a step of the post-render chain. A post-renderer returning an
+// error fails the install or the upgrade, e.g. to enforce a policy.
+type Transformer interface {
+	// PostRenderer returns the helm post-renderer transforming the rendered
+	// manifest of the release of repo, deployed to namespace, nil if the
+	// transformer does not apply to the release.
+	PostRenderer(repo *appv1.HelmReleaseRepo, namespace string) postrender.PostRenderer
+	// Settings returns the settings the post-renderer of the release of repo
+	// depends on, nil if there are none. They are part of the release digest
+	// so that changing them upgrades the release. They must be encodable to
+	// JSON.
+	Settings(repo *appv1.HelmReleaseRepo) interface{}
+}
+
+type namedTransformer struct {
+	name string
+	Transformer
+}
+
+var (
+	transformersMu sync.RWMutex
+	transformers   = []namedTransformer{
+		{SkipTransformer, skipTransformer{}},
+		{NamespaceTransformer, namespaceTransformer{}},
+		{PullSecretsTransformer, pullSecretsTransformer{}},
+		{MirrorsTransformer, mirrorsTransformer{}},
+		{PullPolicyTransformer, pullPolicyTransformer{}},
+		{SchedulingTransformer, schedulingTransformer{}},
+		{TopologySpreadTransformer, topologySpreadTransformer{}},
+		{DefaultResourcesTransformer, defaultResourcesTransformer{}},
+		{SecurityContextTransformer, securityContextTransformer{}},
+		{EnvTransformer, envTransformer{}},
+		{PriorityClassTransformer, priorityClassTransformer{}},
+		{CommonMetadataTransformer, commonMetadataTransformer{}},
+		// the patches see the output of the other transformers
+		{PatchesTransformer, patchesTransformer{}},
+	}
+)
+
+// RegisterTransformer adds t to the post-render chain of every release under
+// name, right before the transformer named before, e.g. PatchesTransformer so
+// that its output can be patched, or at the end of the chain if before is
+// empty. It is meant for the downstream builds of the operator, called from
+// an init function, and panics if name is already registered or before is
+// not.
+func RegisterTransformer(name string, t Transformer, before string) {
+	transformersMu.Lock()
+	defer transformersMu.Unlock()
+
+	at := len(transformers)
+
+	for i, registered := range transformers {
+		if registered.name == name {
+			panic(fmt.Sprintf("transformer %q is already registered", name))
+		}
+
+		if registered.name == before {
+			at = i
+		}
+	}
+
+	if before != "" && at == len(transformers) {
+		panic(fmt.Sprintf("transformer %q is not registered", before))
+	}
+
+	registered := make([]namedTransformer, 0, len(transformers)+1)
+	registered = append(registered, transformers[:at]...)
+	registered = append(registered, namedTransformer{name, t})
+	registered = append(registered, transformers[at:]...)
+
+	transformers = registered
+}
+
+// Transformers returns the names of the transformers of the post-render
+// chain, in the order they run.
+func Transformers() []string {
+	transformersMu.RLock()
+	defer transformersMu.RUnlock()
+
+	names := make([]string, 0, len(transformers))
+	for _, t := range transformers {
+		names = append(names, t.name)
+	}
+
+	return names
+}
+
+func registeredTransformers() []namedTransformer {
+	transformersMu.RLock()
+	defer transformersMu.RUnlock()
+
+	return transformers
+}
+
+// the built-in transformers, each injecting a setting of the repo
+
+type skipTransformer struct{}
+
+// PostRenderer always applies, the skip annotation is honored for every
+// release.
+func (skipTransformer) PostRenderer(repo *appv1.HelmReleaseRepo, _ string) postrender.PostRenderer {
+	return skipRenderer{
+		selectors: repo.SkipResources,
+		include:   repo.IncludeKinds,
+		exclude:   repo.ExcludeKinds,
+	}
+}
+
+func (skipTransformer) Settings(repo *appv1.HelmReleaseRepo) interface{} {
+	if len(repo.SkipResources) == 0 && len(repo.IncludeKinds) == 0 && len(repo.ExcludeKinds) == 0 {
+		return nil
+	}
+
+	return []interface{}{repo.SkipResources, repo.IncludeKinds, repo.ExcludeKinds}
+}
+
+type namespaceTransformer struct{}
+
+func (namespaceTransformer) PostRenderer(repo *appv1.HelmReleaseRepo, namespace string) postrender.PostRenderer {
+	if !repo.OverrideNamespace {
+		return nil
+	}
+
+	return namespaceRenderer{namespace}
+}
+
+// Settings is the flag only, the target namespace is part of the digest
+// already.
+func (namespaceTransformer) Settings(repo *appv1.HelmReleaseRepo) interface{} {
+	if !repo.OverrideNamespace {
+		return nil
+	}
+
+	return repo.OverrideNamespace
+}
+
+type pullSecretsTransformer struct{}
+
+func (pullSecretsTransformer) PostRenderer(repo *appv1.HelmReleaseRepo, _ string) postrender.PostRenderer {
+	if len(repo.ImagePullSecrets) == 0 {
+		return nil
+	}
+
+	return pullSecretsRenderer{repo.ImagePullSecrets}
+}
+
+func (pullSecretsTransformer) Settings(repo *appv1.HelmReleaseRepo) interface{} {
+	if len(repo.ImagePullSecrets) == 0 {
+		return nil
+	}
+
+	return repo.ImagePullSecrets
+}
+
+type mirrorsTransformer struct{}
+
+func (mirrorsTransformer) PostRenderer(repo *appv1.HelmReleaseRepo, _ string) postrender.PostRenderer {
+	if len(repo.ImageMirrors) == 0 {
+		return nil
+	}
+
+	return newMirrorRenderer(repo.ImageMirrors)
+}
+
+func (mirrorsTransformer) Settings(repo *appv1.HelmReleaseRepo) interface{} {
+	if len(repo.ImageMirrors) == 0 {
+		return nil
+	}
+
+	return repo.ImageMirrors
+}
+
+type pullPolicyTransformer struct{}
+
+func (pullPolicyTransformer) PostRenderer(repo *appv1.HelmReleaseRepo, _ string) postrender.PostRenderer {
+	if repo.ImagePullPolicy == "" {
+		return nil
+	}
+
+	return pullPolicyRenderer{repo.ImagePullPolicy}
+}
+
+func (pullPolicyTransformer) Settings(repo *appv1.HelmReleaseRepo) interface{} {
+	if repo.ImagePullPolicy == "" {
+		return nil
+	}
+
+	return repo.ImagePullPolicy
+}
+
+type schedulingTransformer struct{}
+
+func (schedulingTransformer) PostRenderer(repo *appv1.HelmReleaseRepo, _ string) postrender.PostRenderer {
+	if repo.Scheduling == nil {
+		return nil
+	}
+
+	return schedulingRenderer{repo.Scheduling}
+}
+
+func (schedulingTransformer) Settings(repo *appv1.HelmReleaseRepo) interface{} {
+	if repo.Scheduling == nil {
+		return nil
+	}
+
+	return repo.Scheduling
+}
+
+type topologySpreadTransformer struct{}
+
+func (topologySpreadTransformer) PostRenderer(repo *appv1.HelmReleaseRepo, _ string) postrender.PostRenderer {
+	if repo.TopologySpread == nil {
+		return nil
+	}
+
+	return topologySpreadRenderer{repo.TopologySpread}
+}
+
+func (topologySpreadTransformer) Settings(repo *appv1.HelmReleaseRepo) interface{} {
+	if repo.TopologySpread == nil {
+		return nil
+	}
+
+	return repo.TopologySpread
+}
+
+type defaultResourcesTransformer struct{}
+
+func (defaultResourcesTransformer) PostRenderer(repo *appv1.HelmReleaseRepo, _ string) postrender.PostRenderer {
+	if repo.DefaultResources == nil {
+		return nil
+	}
+
+	return defaultResourcesRenderer{repo.DefaultResources}
+}
+
+func (defaultResourcesTransformer) Settings(repo *appv1.HelmReleaseRepo) interface{} {
+	if repo.DefaultResources == nil {
+		return nil
+	}
+
+	return repo.DefaultResources
+}
+
+type securityContextTransformer struct{}
+
+func (securityContextTransformer) PostRenderer(repo *appv1.HelmReleaseRepo, _ string) postrender.PostRenderer {
+	if repo.SecurityContext == nil {
+		return nil
+	}
+
+	return securityContextRenderer{repo.SecurityContext}
+}
+
+func (securityContextTransformer) Settings(repo *appv1.HelmReleaseRepo) interface{} {
+	if repo.SecurityContext == nil {
+		return nil
+	}
+
+	return repo.SecurityContext
+}
+
+type envTransformer struct{}
+
+func (envTransformer) PostRenderer(repo *appv1.HelmReleaseRepo, _ string) postrender.PostRenderer {
+	if len(repo.Env) == 0 && len(repo.EnvFrom) == 0 {
+		return nil
+	}
+
+	return envRenderer{repo.Env, repo.EnvFrom}
+}
+
+func (envTransformer) Settings(repo *appv1.HelmReleaseRepo) interface{} {
+	if len(repo.Env) == 0 && len(repo.EnvFrom) == 0 {
+		return nil
+	}
+
+	return []interface{}{repo.Env, repo.EnvFrom}
+}
+
+type priorityClassTransformer struct{}
+
+func (priorityClassTransformer) PostRenderer(repo *appv1.HelmReleaseRepo, _ string) postrender.PostRenderer {
+	if repo.PriorityClassName == "" {
+		return nil
+	}
+
+	return priorityClassRenderer{repo.PriorityClassName}
+}
+
+func (priorityClassTransformer) Settings(repo *appv1.HelmReleaseRepo) interface{} {
+	if repo.PriorityClassName == "" {
+		return nil
+	}
+
+	return repo.PriorityClassName
+}
+
+type commonMetadataTransformer struct{}
+
+func (commonMetadataTransformer) PostRenderer(repo *appv1.HelmReleaseRepo, _ string) postrender.PostRenderer {
+	if len(repo.CommonLabels) == 0 && len(repo.CommonAnnotations) == 0 {
+		return nil
+	}
+
+	return commonMetadataRenderer{repo.CommonLabels, repo.CommonAnnotations}
+}
+
+func (commonMetadataTransformer) Settings(repo *appv1.HelmReleaseRepo) interface{} {
+	if len(repo.CommonLabels) == 0 && len(repo.CommonAnnotations) == 0 {
+		return nil
+	}
+
+	return []map[string]string{repo.CommonLabels, repo.CommonAnnotations}
+}
+
+type patchesTransformer struct{}
+
+func (patchesTransformer) PostRenderer(repo *appv1.HelmReleaseRepo, _ string) postrender.PostRenderer {
+	if len(repo.Patches) == 0 {
+		return nil
+	}
+
+	return patchRenderer{repo.Patches}
+}
+
+func (patchesTransformer) Settings(repo *appv1.HelmReleaseRepo) interface{} {
+	if len(repo.Patches) == 0 {
+		return nil
+	}
+
+	return repo.Patches
+}
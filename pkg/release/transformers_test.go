@@ -0,0 +1,66 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"helm.sh/helm/v3/pkg/postrender"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+// upgradeImageTransformer upgrades nginx:1.19 to nginx:1.20
+type upgradeImageTransformer struct{}
+
+func (upgradeImageTransformer) PostRenderer(repo *appv1.HelmReleaseRepo, _ string) postrender.PostRenderer {
+	return upgradeImageTransformer{}
+}
+
+func (upgradeImageTransformer) Settings(repo *appv1.HelmReleaseRepo) interface{} {
+	return "nginx:1.20"
+}
+
+func (upgradeImageTransformer) Run(rendered *bytes.Buffer) (*bytes.Buffer, error) {
+	return bytes.NewBufferString(strings.ReplaceAll(rendered.String(), "nginx:1.19", "nginx:1.20")), nil
+}
+
+func TestRegisterTransformer(t *testing.T) {
+	builtin := transformers
+	defer func() { transformers = builtin }()
+
+	RegisterTransformer("upgradeImage", upgradeImageTransformer{}, PatchesTransformer)
+
+	names := Transformers()
+	assert.Equal(t, "upgradeImage", names[len(names)-2])
+	assert.Equal(t, PatchesTransformer, names[len(names)-1])
+
+	assert.Panics(t, func() { RegisterTransformer("upgradeImage", upgradeImageTransformer{}, "") })
+	assert.Panics(t, func() { RegisterTransformer("other", upgradeImageTransformer{}, "missing") })
+
+	repo := &appv1.HelmReleaseRepo{ImagePullPolicy: "IfNotPresent"}
+	assert.Equal(t, []interface{}{repo.ImagePullPolicy, "nginx:1.20"}, postRenderSettings(repo))
+
+	deployment := fmt.Sprintf(testDeploymentManifest, "1", 1, "nginx:1.19")
+
+	rendered, err := newPostRenderer(repo, "default").Run(bytes.NewBufferString(deployment))
+	assert.NoError(t, err)
+	assert.Contains(t, rendered.String(), "image: nginx:1.20")
+	assert.Contains(t, rendered.String(), "imagePullPolicy: IfNotPresent")
+}
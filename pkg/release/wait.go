@@ -0,0 +1,144 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"helm.sh/helm/v3/pkg/kube"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/cli-runtime/pkg/resource"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+// DefaultWaitTimeout is the time waited for the resources of a release to be
+// ready when no timeout is configured, like the helm CLI does.
+const DefaultWaitTimeout = appv1.DefaultWaitTimeout
+
+var _ kube.Interface = &waitClient{}
+
+// waitClient waits for the resources whose kind has a configured timeout with
+// that timeout instead of the timeout of the helm action, and does not wait
+// for the resources of the excluded kinds at all. The resources of each
+// timeout are waited for concurrently. Everything other than Wait and
+// WatchUntilReady is delegated to the wrapped client.
+type waitClient struct {
+	kube.Interface
+	timeouts   []appv1.ResourceTimeout
+	exclusions []appv1.ResourceKind
+}
+
+func newWaitClient(base kube.Interface, timeouts []appv1.ResourceTimeout, exclusions []appv1.ResourceKind) kube.Interface {
+	return &waitClient{
+		Interface:  base,
+		timeouts:   timeouts,
+		exclusions: exclusions,
+	}
+}
+
+// kindMatches returns true if the kind of info is kind, in apiVersion if set.
+func kindMatches(info *resource.Info, apiVersion, kind string) bool {
+	gvk := info.Object.GetObjectKind().GroupVersionKind()
+
+	if !strings.EqualFold(kind, gvk.Kind) {
+		return false
+	}
+
+	return apiVersion == "" || apiVersion == gvk.GroupVersion().String()
+}
+
+// timeoutFor returns the timeout configured for the kind of info, or
+// timeout if there is none.
+func (c *waitClient) timeoutFor(info *resource.Info, timeout time.Duration) time.Duration {
+	for _, t := range c.timeouts {
+		if kindMatches(info, t.APIVersion, t.Kind) {
+			return t.Timeout.Duration
+		}
+	}
+
+	return timeout
+}
+
+// excluded returns true if the readiness of info is not waited for.
+func (c *waitClient) excluded(info *resource.Info) bool {
+	for _, e := range c.exclusions {
+		if kindMatches(info, e.APIVersion, e.Kind) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Wait waits for the resources not excluded to be ready, each with the
+// timeout of its kind.
+func (c *waitClient) Wait(resources kube.ResourceList, timeout time.Duration) error {
+	included := resources.Filter(func(info *resource.Info) bool {
+		return !c.excluded(info)
+	})
+
+	return c.waitGrouped(included, timeout, c.Interface.Wait)
+}
+
+// WatchUntilReady waits for the hook resources to complete, each with the
+// timeout of its kind.
+func (c *waitClient) WatchUntilReady(resources kube.ResourceList, timeout time.Duration) error {
+	return c.waitGrouped(resources, timeout, c.Interface.WatchUntilReady)
+}
+
+func (c *waitClient) waitGrouped(resources kube.ResourceList, timeout time.Duration,
+	wait func(kube.ResourceList, time.Duration) error) error {
+	groups := make(map[time.Duration]kube.ResourceList)
+
+	for _, info := range resources {
+		t := c.timeoutFor(info, timeout)
+		groups[t] = append(groups[t], info)
+	}
+
+	if len(groups) <= 1 {
+		for t, group := range groups {
+			return wait(group, t)
+		}
+
+		return nil
+	}
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+	)
+
+	for t, group := range groups {
+		wg.Add(1)
+
+		go func(group kube.ResourceList, t time.Duration) {
+			defer wg.Done()
+
+			if err := wait(group, t); err != nil {
+				mu.Lock()
+				errs = append(errs, err)
+				mu.Unlock()
+			}
+		}(group, t)
+	}
+
+	wg.Wait()
+
+	return utilerrors.NewAggregate(errs)
+}
@@ -0,0 +1,79 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"helm.sh/helm/v3/pkg/kube"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/cli-runtime/pkg/resource"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+type waitRecordingClient struct {
+	kube.Interface
+	mu     sync.Mutex
+	waited map[string]time.Duration
+}
+
+func (c *waitRecordingClient) Wait(resources kube.ResourceList, timeout time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, info := range resources {
+		c.waited[info.Name] = timeout
+	}
+
+	return nil
+}
+
+func newTestInfo(apiVersion, kind, name string) *resource.Info {
+	u := &unstructured.Unstructured{}
+	u.SetAPIVersion(apiVersion)
+	u.SetKind(kind)
+	u.SetName(name)
+
+	return &resource.Info{Name: name, Object: u}
+}
+
+func TestWaitClientWait(t *testing.T) {
+	base := &waitRecordingClient{waited: make(map[string]time.Duration)}
+	c := newWaitClient(base, []appv1.ResourceTimeout{
+		{Kind: "StatefulSet", Timeout: metav1.Duration{Duration: 30 * time.Minute}},
+		{APIVersion: "v1", Kind: "ConfigMap", Timeout: metav1.Duration{Duration: 2 * time.Minute}},
+	}, []appv1.ResourceKind{
+		{APIVersion: "example.com/v1", Kind: "Database"},
+	})
+
+	err := c.Wait(kube.ResourceList{
+		newTestInfo("apps/v1", "StatefulSet", "db"),
+		newTestInfo("v1", "ConfigMap", "config"),
+		newTestInfo("apps/v1", "Deployment", "web"),
+		newTestInfo("example.com/v1", "Database", "prod"),
+	}, DefaultWaitTimeout)
+	assert.NoError(t, err)
+
+	assert.Equal(t, map[string]time.Duration{
+		"db":     30 * time.Minute,
+		"config": 2 * time.Minute,
+		"web":    DefaultWaitTimeout,
+	}, base.waited)
+}
@@ -0,0 +1,201 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"helm.sh/helm/v3/pkg/kube"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/cli-runtime/pkg/resource"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+// SyncWaveAnnotation sets the sync wave of a rendered resource, e.g. "-1" to
+// apply a Deployment before the resources of the default wave 0.
+const SyncWaveAnnotation = "apps.open-cluster-management.io/sync-wave"
+
+var _ kube.Interface = &waveClient{}
+
+// waveClient creates and updates the resources in sync wave order, the
+// resources of a wave once those of the previous waves are ready. The order of
+// the resources within a wave is kept. Everything other than Create and
+// Update is delegated to the wrapped client.
+type waveClient struct {
+	kube.Interface
+	waves   []appv1.ResourceWave
+	timeout time.Duration
+}
+
+func newWaveClient(base kube.Interface, waves []appv1.ResourceWave, timeout time.Duration) kube.Interface {
+	if timeout == 0 {
+		timeout = DefaultWaitTimeout
+	}
+
+	return &waveClient{
+		Interface: base,
+		waves:     waves,
+		timeout:   timeout,
+	}
+}
+
+// waveOf returns the sync wave of info, from its annotation or its kind.
+func (c *waveClient) waveOf(info *resource.Info) (int, error) {
+	if accessor, err := meta.Accessor(info.Object); err == nil {
+		if v, ok := accessor.GetAnnotations()[SyncWaveAnnotation]; ok {
+			wave, err := strconv.Atoi(v)
+			if err != nil {
+				return 0, fmt.Errorf("invalid %s annotation %q of %s %s", SyncWaveAnnotation, v,
+					info.Object.GetObjectKind().GroupVersionKind().Kind, info.Name)
+			}
+
+			return wave, nil
+		}
+	}
+
+	for _, w := range c.waves {
+		if kindMatches(info, w.APIVersion, w.Kind) {
+			return w.Wave, nil
+		}
+	}
+
+	return 0, nil
+}
+
+// split returns the resources of each wave, in wave order.
+func (c *waveClient) split(resources kube.ResourceList) ([]kube.ResourceList, error) {
+	groups := make(map[int]kube.ResourceList)
+
+	for _, info := range resources {
+		wave, err := c.waveOf(info)
+		if err != nil {
+			return nil, err
+		}
+
+		groups[wave] = append(groups[wave], info)
+	}
+
+	waves := make([]int, 0, len(groups))
+	for wave := range groups {
+		waves = append(waves, wave)
+	}
+
+	sort.Ints(waves)
+
+	split := make([]kube.ResourceList, 0, len(waves))
+	for _, wave := range waves {
+		split = append(split, groups[wave])
+	}
+
+	return split, nil
+}
+
+// Create creates the resources of each wave and waits for them to be ready
+// before creating those of the next wave. The last wave is waited for by
+// helm, if the release waits.
+func (c *waveClient) Create(resources kube.ResourceList) (*kube.Result, error) {
+	waves, err := c.split(resources)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(waves) <= 1 {
+		return c.Interface.Create(resources)
+	}
+
+	result := &kube.Result{}
+
+	for i, wave := range waves {
+		r, err := c.Interface.Create(wave)
+		mergeResult(result, r)
+
+		if err != nil {
+			return result, err
+		}
+
+		if i < len(waves)-1 {
+			if err := c.waitWave(wave); err != nil {
+				return result, err
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// Update updates the resources of each wave and waits for them to be ready
+// before updating those of the next wave. The resources of original no longer
+// in target are deleted with the last wave.
+func (c *waveClient) Update(original, target kube.ResourceList, force bool) (*kube.Result, error) {
+	waves, err := c.split(target)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(waves) <= 1 {
+		return c.Interface.Update(original, target, force)
+	}
+
+	result := &kube.Result{}
+	remaining := original
+
+	for i, wave := range waves {
+		// the wrapped client deletes the resources of original not in target,
+		// only the last wave is given the ones to delete
+		waveOriginal := remaining.Intersect(wave)
+		if i == len(waves)-1 {
+			waveOriginal = remaining
+		}
+
+		r, err := c.Interface.Update(waveOriginal, wave, force)
+		mergeResult(result, r)
+
+		if err != nil {
+			return result, err
+		}
+
+		remaining = remaining.Difference(wave)
+
+		if i < len(waves)-1 {
+			if err := c.waitWave(wave); err != nil {
+				return result, err
+			}
+		}
+	}
+
+	return result, nil
+}
+
+func (c *waveClient) waitWave(wave kube.ResourceList) error {
+	if err := c.Interface.Wait(wave, c.timeout); err != nil {
+		return fmt.Errorf("sync wave not ready: %w", err)
+	}
+
+	return nil
+}
+
+func mergeResult(result, r *kube.Result) {
+	if r == nil {
+		return
+	}
+
+	result.Created = append(result.Created, r.Created...)
+	result.Updated = append(result.Updated, r.Updated...)
+	result.Deleted = append(result.Deleted, r.Deleted...)
+}
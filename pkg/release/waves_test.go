@@ -0,0 +1,79 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"helm.sh/helm/v3/pkg/kube"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+type waveRecordingClient struct {
+	kube.Interface
+	created [][]string
+	waited  [][]string
+}
+
+func (c *waveRecordingClient) Create(resources kube.ResourceList) (*kube.Result, error) {
+	c.created = append(c.created, infoNames(resources))
+
+	return &kube.Result{Created: resources}, nil
+}
+
+func (c *waveRecordingClient) Wait(resources kube.ResourceList, timeout time.Duration) error {
+	c.waited = append(c.waited, infoNames(resources))
+
+	return nil
+}
+
+func TestWaveClientCreate(t *testing.T) {
+	annotated := newTestInfo("v1", "ConfigMap", "first")
+	annotated.Object.(*unstructured.Unstructured).SetAnnotations(map[string]string{SyncWaveAnnotation: "-2"})
+
+	base := &waveRecordingClient{}
+	c := newWaveClient(base, []appv1.ResourceWave{
+		{APIVersion: "apps/v1", Kind: "Deployment", Wave: -1},
+		{Kind: "Database", Wave: 1},
+	}, 0)
+
+	result, err := c.Create(kube.ResourceList{
+		newTestInfo("example.com/v1", "Database", "db"),
+		newTestInfo("v1", "Service", "web"),
+		newTestInfo("apps/v1", "Deployment", "operator"),
+		annotated,
+		newTestInfo("v1", "ConfigMap", "config"),
+	})
+	assert.NoError(t, err)
+	assert.Len(t, result.Created, 5)
+
+	assert.Equal(t, [][]string{{"first"}, {"operator"}, {"web", "config"}, {"db"}}, base.created)
+	// the last wave is left to helm
+	assert.Equal(t, [][]string{{"first"}, {"operator"}, {"web", "config"}}, base.waited)
+}
+
+func TestWaveClientInvalidAnnotation(t *testing.T) {
+	info := newTestInfo("v1", "ConfigMap", "config")
+	info.Object.(*unstructured.Unstructured).SetAnnotations(map[string]string{SyncWaveAnnotation: "first"})
+
+	base := &waveRecordingClient{}
+	_, err := newWaveClient(base, nil, 0).Create(kube.ResourceList{info})
+	assert.Error(t, err)
+	assert.Empty(t, base.created)
+}
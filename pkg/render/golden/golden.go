@@ -0,0 +1,183 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package golden renders fixture releases through the render pipeline of the
+// operator, the values merge and the post-render chain included, and compares
+// them with their golden manifests, so that a change of the render path that
+// changes what is applied fails the tests, e.g.
+//
+//	func TestGolden(t *testing.T) {
+//		golden.Run(t, "testdata")
+//	}
+//
+// Each case is a subdirectory holding:
+//
+//	helmrelease.yaml       the HelmRelease rendered, its spec holding the values
+//	case.yaml              the chart directory, relative to the case, and the cluster seen by the templates
+//	manifest.golden.yaml   the manifest expected, as written by render.Manifest.YAML
+//
+// The golden manifests are written instead of compared when the tests run
+// with -update-golden, e.g. go test ./pkg/render/golden -update-golden.
+package golden
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ghodss/yaml"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+	"github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/render"
+)
+
+const (
+	// HelmReleaseFile is the HelmRelease of a case
+	HelmReleaseFile = "helmrelease.yaml"
+	// CaseFile is the settings of a case
+	CaseFile = "case.yaml"
+	// ManifestFile is the golden manifest of a case
+	ManifestFile = "manifest.golden.yaml"
+)
+
+var update = flag.Bool("update-golden", false, "write the golden manifests instead of comparing them")
+
+// Case is the settings of a case, besides its HelmRelease.
+type Case struct {
+	// Chart is the chart directory rendered, relative to the case directory.
+	Chart string `json:"chart"`
+	// KubeVersion and APIVersions are the cluster seen by the templates, see
+	// render.Spec.
+	KubeVersion string   `json:"kubeVersion,omitempty"`
+	APIVersions []string `json:"apiVersions,omitempty"`
+}
+
+// Run renders each case of dir, every subdirectory with a HelmReleaseFile,
+// as a subtest and compares its manifest with the golden one.
+func Run(t *testing.T, dir string) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, entry := range entries {
+		caseDir := filepath.Join(dir, entry.Name())
+
+		if _, err := os.Stat(filepath.Join(caseDir, HelmReleaseFile)); !entry.IsDir() || err != nil {
+			continue
+		}
+
+		t.Run(entry.Name(), func(t *testing.T) {
+			manifest, err := Render(caseDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			goldenFile := filepath.Join(caseDir, ManifestFile)
+
+			if *update {
+				if err := ioutil.WriteFile(goldenFile, manifest, 0644); err != nil {
+					t.Fatal(err)
+				}
+
+				return
+			}
+
+			golden, err := ioutil.ReadFile(goldenFile)
+			if err != nil {
+				t.Fatalf("%v, run the tests with -update-golden to write it", err)
+			}
+
+			if diff := Diff(golden, manifest); diff != "" {
+				t.Errorf("the manifest rendered differs from %s, run the tests with -update-golden "+
+					"if the change is expected:\n%s", goldenFile, diff)
+			}
+		})
+	}
+}
+
+// Render renders the case of dir and returns its manifest.
+func Render(dir string) ([]byte, error) {
+	hr := &appv1.HelmRelease{}
+	if err := readYAML(filepath.Join(dir, HelmReleaseFile), hr); err != nil {
+		return nil, err
+	}
+
+	c := &Case{}
+	if err := readYAML(filepath.Join(dir, CaseFile), c); err != nil {
+		return nil, err
+	}
+
+	if c.Chart == "" {
+		return nil, fmt.Errorf("%s has no chart", filepath.Join(dir, CaseFile))
+	}
+
+	manifest, err := render.Render(context.TODO(), render.Spec{
+		HelmRelease: hr,
+		ChartDir:    filepath.Join(dir, c.Chart),
+		KubeVersion: c.KubeVersion,
+		APIVersions: c.APIVersions,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return manifest.YAML()
+}
+
+// Diff returns the first line that differs between the golden and the
+// rendered manifests, with its line number, empty if they are the same.
+func Diff(golden, rendered []byte) string {
+	if bytes.Equal(golden, rendered) {
+		return ""
+	}
+
+	expected := strings.Split(string(golden), "\n")
+	actual := strings.Split(string(rendered), "\n")
+
+	for i := 0; ; i++ {
+		var e, a string
+
+		if i < len(expected) {
+			e = expected[i]
+		}
+
+		if i < len(actual) {
+			a = actual[i]
+		}
+
+		if e != a || i >= len(expected) || i >= len(actual) {
+			return fmt.Sprintf("line %d:\n- %s\n+ %s", i+1, e, a)
+		}
+	}
+}
+
+func readYAML(path string, obj interface{}) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	if err := yaml.Unmarshal(data, obj); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,31 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package golden
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGolden(t *testing.T) {
+	Run(t, "../../../test/golden")
+}
+
+func TestDiff(t *testing.T) {
+	assert.Empty(t, Diff([]byte("a\nb\n"), []byte("a\nb\n")))
+	assert.Equal(t, "line 2:\n- b\n+ c", Diff([]byte("a\nb\n"), []byte("a\nc\n")))
+	assert.Equal(t, "line 3:\n- \n+ c", Diff([]byte("a\nb"), []byte("a\nb\nc")))
+}
@@ -0,0 +1,279 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package render renders the release of a HelmRelease outside of the
+// operator, e.g. to preview a change in CI or from hub-side tooling. The
+// chart is fetched from the source of the HelmRelease, its values are merged
+// and it is rendered through the post-render chain of the operator, without a
+// cluster, e.g.
+//
+//	manifest, err := render.Render(ctx, render.Spec{HelmRelease: hr, KubeVersion: "v1.19.3"})
+//	out, err := manifest.YAML()
+package render
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/ghodss/yaml"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/chartutil"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+	"github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/release"
+	"github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/utils"
+)
+
+// ErrUmbrella is returned for the umbrella HelmReleases, their charts are
+// rendered as the HelmReleases of their sub-releases.
+var ErrUmbrella = errors.New("the charts of an umbrella HelmRelease are rendered one by one")
+
+// Spec is the release to render.
+type Spec struct {
+	// HelmRelease is the HelmRelease rendered, its repo selects the chart and
+	// its spec holds the values.
+	HelmRelease *appv1.HelmRelease
+	// ChartDir is a local chart directory rendered instead of the chart of the
+	// source of the HelmRelease, e.g. the chart changed by a pull request.
+	ChartDir string
+	// ChartsDir is the directory the chart is downloaded to. A temporary
+	// directory removed once the chart is rendered is used if empty.
+	ChartsDir string
+	// Values are merged into the values of the HelmRelease, overriding them.
+	Values map[string]interface{}
+	// ConfigMap, Secret and TLSSecret are the objects referenced by the
+	// configMapRef, the secretRef and the tlsSecretRef of the HelmRelease,
+	// read by the caller if the source needs them.
+	ConfigMap *corev1.ConfigMap
+	Secret    *corev1.Secret
+	TLSSecret *corev1.Secret
+	// KubeVersion is the Kubernetes version of the target cluster seen by the
+	// templates, e.g. v1.19.3, the helm default if empty.
+	KubeVersion string
+	// APIVersions are the API versions of the target cluster added to the
+	// built-in ones, e.g. monitoring.coreos.com/v1 or
+	// monitoring.coreos.com/v1/ServiceMonitor.
+	APIVersions []string
+	// RESTMapper returns the scope of the kinds that are neither built in nor
+	// defined by the chart, they are assumed namespaced if nil.
+	RESTMapper meta.RESTMapper
+}
+
+// Manifest is the rendered release.
+type Manifest struct {
+	// ReleaseName is the name of the release.
+	ReleaseName string
+	// Namespace is the target namespace of the release.
+	Namespace string
+	// Chart is the metadata of the chart rendered.
+	Chart *chart.Metadata
+	// Source is the source that served the chart, nil for a local chart.
+	Source *appv1.HelmAppChartSource
+	// Objects are the resources of the release in install order, the CRDs of
+	// the chart first. The hooks are not rendered.
+	Objects []*unstructured.Unstructured
+	// Notes are the rendered NOTES.txt of the chart.
+	Notes string
+}
+
+// YAML returns the objects of m as a multi-document YAML stream.
+func (m Manifest) YAML() ([]byte, error) {
+	var buf bytes.Buffer
+
+	for _, obj := range m.Objects {
+		b, err := yaml.Marshal(obj.Object)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal %s %s: %w", obj.GetKind(), obj.GetName(), err)
+		}
+
+		buf.WriteString("---\n")
+		buf.Write(b)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Render fetches the chart of the HelmRelease of spec and renders its release
+// as the operator would install it. Only the source of the chart is
+// contacted, the release is rendered without a cluster: the lookup template
+// function finds nothing and the capabilities are the ones of spec.
+func Render(ctx context.Context, spec Spec) (Manifest, error) {
+	hr := spec.HelmRelease
+	if hr == nil {
+		return Manifest{}, errors.New("no HelmRelease to render")
+	}
+
+	if len(hr.Repo.Charts) > 0 {
+		return Manifest{}, ErrUmbrella
+	}
+
+	capabilities, err := Capabilities(spec.KubeVersion, spec.APIVersions)
+	if err != nil {
+		return Manifest{}, err
+	}
+
+	values, err := Values(hr, spec.Values)
+	if err != nil {
+		return Manifest{}, err
+	}
+
+	chartsDir := spec.ChartsDir
+	if chartsDir == "" && spec.ChartDir == "" {
+		chartsDir, err = ioutil.TempDir("", "charts")
+		if err != nil {
+			return Manifest{}, err
+		}
+
+		defer os.RemoveAll(chartsDir)
+	}
+
+	chartDir, source, err := fetchChart(ctx, spec, chartsDir)
+	if err != nil {
+		return Manifest{}, err
+	}
+
+	chrt, err := loader.Load(chartDir)
+	if err != nil {
+		return Manifest{}, fmt.Errorf("failed to load chart %s: %w", chartDir, err)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return Manifest{}, err
+	}
+
+	namespace := hr.Namespace
+	if hr.Repo.TargetNamespace != "" {
+		namespace = hr.Repo.TargetNamespace
+	}
+
+	rel, objects, err := release.RenderChart(chrt, hr.Name, &hr.Repo, values, release.ChartRenderOptions{
+		ReleaseName:  hr.Repo.ReleaseName,
+		Namespace:    namespace,
+		Capabilities: capabilities,
+		RESTMapper:   spec.RESTMapper,
+	})
+	if err != nil {
+		return Manifest{}, err
+	}
+
+	return Manifest{
+		ReleaseName: rel.Name,
+		Namespace:   namespace,
+		Chart:       chrt.Metadata,
+		Source:      source,
+		Objects:     objects,
+		Notes:       rel.Info.Notes,
+	}, nil
+}
+
+// fetchChart returns the directory of the chart of spec, downloaded to
+// chartsDir from the source of its HelmRelease unless it is local.
+func fetchChart(ctx context.Context, spec Spec, chartsDir string) (string, *appv1.HelmAppChartSource, error) {
+	if spec.ChartDir != "" {
+		return spec.ChartDir, nil, nil
+	}
+
+	if err := ctx.Err(); err != nil {
+		return "", nil, err
+	}
+
+	chartDir, source, err := utils.DownloadChartSource(spec.ConfigMap, spec.Secret, spec.TLSSecret, chartsDir, spec.HelmRelease)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to download the chart: %w", err)
+	}
+
+	return chartDir, source, nil
+}
+
+// Values returns the values hr is rendered with, its spec with overrides
+// merged into it.
+func Values(hr *appv1.HelmRelease, overrides map[string]interface{}) (map[string]interface{}, error) {
+	values := map[string]interface{}{}
+
+	if hr.Spec != nil {
+		b, err := json.Marshal(hr.Spec)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal the spec: %w", err)
+		}
+
+		if err := json.Unmarshal(b, &values); err != nil {
+			return nil, fmt.Errorf("the spec of HelmRelease %s/%s is not an object: %w", hr.Namespace, hr.Name, err)
+		}
+	}
+
+	if len(overrides) == 0 {
+		return values, nil
+	}
+
+	// CoalesceTables merges into its first argument
+	merged, err := copyValues(overrides)
+	if err != nil {
+		return nil, err
+	}
+
+	return chartutil.CoalesceTables(merged, values), nil
+}
+
+func copyValues(values map[string]interface{}) (map[string]interface{}, error) {
+	b, err := json.Marshal(values)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal the values: %w", err)
+	}
+
+	out := map[string]interface{}{}
+	if err := json.Unmarshal(b, &out); err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// Capabilities returns the capabilities of a cluster of kubeVersion serving
+// the built-in API versions and apiVersions, the helm defaults if both are
+// empty.
+func Capabilities(kubeVersion string, apiVersions []string) (*chartutil.Capabilities, error) {
+	capabilities := *chartutil.DefaultCapabilities
+
+	if kubeVersion != "" {
+		v, err := semver.NewVersion(kubeVersion)
+		if err != nil {
+			return nil, fmt.Errorf("invalid Kubernetes version %q: %w", kubeVersion, err)
+		}
+
+		capabilities.KubeVersion = chartutil.KubeVersion{
+			Version: "v" + v.String(),
+			Major:   strconv.FormatUint(v.Major(), 10),
+			Minor:   strconv.FormatUint(v.Minor(), 10),
+		}
+	}
+
+	if len(apiVersions) > 0 {
+		versions := make(chartutil.VersionSet, 0, len(capabilities.APIVersions)+len(apiVersions))
+		versions = append(versions, capabilities.APIVersions...)
+		capabilities.APIVersions = append(versions, apiVersions...)
+	}
+
+	return &capabilities, nil
+}
@@ -0,0 +1,139 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package render
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+const testChartDir = "../../test/github/subscription-release-test-1"
+
+func testHelmRelease() *appv1.HelmRelease {
+	return &appv1.HelmRelease{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "apps"},
+		Repo: appv1.HelmReleaseRepo{
+			ChartName: "subscription-release-test-1",
+		},
+		Spec: map[string]interface{}{
+			"subscriptionrelease": map[string]interface{}{"hostname": "hub.example.com"},
+		},
+	}
+}
+
+func TestRender(t *testing.T) {
+	manifest, err := Render(context.TODO(), Spec{
+		HelmRelease: testHelmRelease(),
+		ChartDir:    testChartDir,
+		Values: map[string]interface{}{
+			"subscriptionrelease": map[string]interface{}{"image": map[string]interface{}{"tag": "2.0"}},
+		},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, "web", manifest.ReleaseName)
+	assert.Equal(t, "apps", manifest.Namespace)
+	assert.Equal(t, "subscription-release-test-1", manifest.Chart.Name)
+	assert.Nil(t, manifest.Source)
+	require.Len(t, manifest.Objects, 1)
+
+	deployment := manifest.Objects[0]
+	assert.Equal(t, "Deployment", deployment.GetKind())
+	assert.Equal(t, "web-subscription-release-test-1", deployment.GetName())
+	assert.Equal(t, "apps", deployment.GetNamespace())
+
+	aliases, _, _ := unstructured.NestedSlice(deployment.Object, "spec", "template", "spec", "hostAliases")
+	require.Len(t, aliases, 1)
+	assert.Equal(t, []interface{}{"hub.example.com"}, aliases[0].(map[string]interface{})["hostnames"])
+
+	containers, _, _ := unstructured.NestedSlice(deployment.Object, "spec", "template", "spec", "containers")
+	require.Len(t, containers, 1)
+	assert.True(t, strings.HasSuffix(containers[0].(map[string]interface{})["image"].(string), ":2.0"))
+
+	out, err := manifest.YAML()
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(out), "---\n"))
+	assert.Contains(t, string(out), "name: web-subscription-release-test-1")
+}
+
+func TestRenderRepo(t *testing.T) {
+	hr := testHelmRelease()
+	hr.Repo.ReleaseName = "preview"
+	hr.Repo.TargetNamespace = "web"
+	hr.Spec = map[string]interface{}{"subscriptionrelease": map[string]interface{}{"enabled": false}}
+
+	manifest, err := Render(context.TODO(), Spec{HelmRelease: hr, ChartDir: testChartDir})
+	require.NoError(t, err)
+
+	assert.Equal(t, "preview", manifest.ReleaseName)
+	assert.Equal(t, "web", manifest.Namespace)
+	assert.Empty(t, manifest.Objects)
+
+	hr.Repo.Charts = []appv1.UmbrellaChart{{Name: "web", ChartName: "nginx"}}
+
+	_, err = Render(context.TODO(), Spec{HelmRelease: hr, ChartDir: testChartDir})
+	assert.True(t, errors.Is(err, ErrUmbrella))
+
+	_, err = Render(context.TODO(), Spec{})
+	assert.Error(t, err)
+}
+
+func TestValues(t *testing.T) {
+	hr := testHelmRelease()
+	overrides := map[string]interface{}{
+		"subscriptionrelease": map[string]interface{}{"ip": "10.0.0.1"},
+	}
+
+	values, err := Values(hr, overrides)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"subscriptionrelease": map[string]interface{}{"hostname": "hub.example.com", "ip": "10.0.0.1"},
+	}, values)
+
+	// the overrides are not modified
+	assert.Equal(t, map[string]interface{}{"ip": "10.0.0.1"}, overrides["subscriptionrelease"])
+
+	hr.Spec = nil
+	values, err = Values(hr, nil)
+	require.NoError(t, err)
+	assert.Empty(t, values)
+
+	hr.Spec = "replicas"
+	_, err = Values(hr, nil)
+	assert.Error(t, err)
+}
+
+func TestCapabilities(t *testing.T) {
+	capabilities, err := Capabilities("1.17.4", []string{"monitoring.coreos.com/v1"})
+	require.NoError(t, err)
+
+	assert.Equal(t, "v1.17.4", capabilities.KubeVersion.Version)
+	assert.Equal(t, "1", capabilities.KubeVersion.Major)
+	assert.Equal(t, "17", capabilities.KubeVersion.Minor)
+	assert.True(t, capabilities.APIVersions.Has("monitoring.coreos.com/v1"))
+	assert.True(t, capabilities.APIVersions.Has("apps/v1"))
+
+	_, err = Capabilities("latest", nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,119 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testutils
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/repo"
+)
+
+// ChartRepo is an in-process helm chart repository serving the archives of
+// its charts and their index.yaml.
+type ChartRepo struct {
+	*server
+
+	// Dir holds the chart archives and the index.yaml served.
+	Dir string
+}
+
+// NewChartRepo serves the charts, chart directories packaged to archives or
+// chart archives, from a temporary directory. The test fails if a chart
+// cannot be loaded.
+func NewChartRepo(t testing.TB, charts []string, opts ...Option) *ChartRepo {
+	t.Helper()
+
+	dir, err := ioutil.TempDir("", "chartrepo")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := &ChartRepo{server: newServer(http.FileServer(http.Dir(dir)), opts), Dir: dir}
+
+	t.Cleanup(func() {
+		r.Close()
+		os.RemoveAll(dir)
+	})
+
+	for _, chart := range charts {
+		r.AddChart(t, chart)
+	}
+
+	if len(charts) == 0 {
+		r.index(t)
+	}
+
+	return r
+}
+
+// AddChart publishes the chart directory or archive path to the repository,
+// e.g. a new version of a chart.
+func (r *ChartRepo) AddChart(t testing.TB, path string) {
+	t.Helper()
+
+	if strings.HasSuffix(path, ".tgz") {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := ioutil.WriteFile(filepath.Join(r.Dir, filepath.Base(path)), data, 0600); err != nil {
+			t.Fatal(err)
+		}
+	} else {
+		chrt, err := loader.Load(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := chartutil.Save(chrt, r.Dir); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r.index(t)
+}
+
+// ChartURL returns the URL of the archive of the chart version.
+func (r *ChartRepo) ChartURL(name, version string) string {
+	return r.URL + "/" + name + "-" + version + ".tgz"
+}
+
+// IndexURL returns the URL of the index.yaml of the repository.
+func (r *ChartRepo) IndexURL() string {
+	return r.URL + "/index.yaml"
+}
+
+func (r *ChartRepo) index(t testing.TB) {
+	t.Helper()
+
+	index, err := repo.IndexDirectory(r.Dir, r.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	index.SortEntries()
+
+	if err := index.WriteFile(filepath.Join(r.Dir, "index.yaml"), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,69 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testutils
+
+import (
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"helm.sh/helm/v3/pkg/repo"
+	"sigs.k8s.io/yaml"
+)
+
+func TestChartRepo(t *testing.T) {
+	r := NewChartRepo(t, []string{
+		"../../test/helmrepo/subscription-release-test-1-0.1.0.tgz",
+		"../../test/github/subscription-release-test-1",
+	}, WithBasicAuth("user", "password"))
+
+	resp, err := http.Get(r.IndexURL())
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	req, err := http.NewRequest(http.MethodGet, r.IndexURL(), nil)
+	assert.NoError(t, err)
+	req.SetBasicAuth("user", "password")
+
+	resp, err = http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+
+	data, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.NoError(t, err)
+
+	index := &repo.IndexFile{}
+	assert.NoError(t, yaml.Unmarshal(data, index))
+
+	versions := index.Entries["subscription-release-test-1"]
+	if assert.NotEmpty(t, versions) {
+		assert.Equal(t, r.URL+"/"+"subscription-release-test-1-"+versions[0].Version+".tgz", versions[0].URLs[0])
+	}
+
+	r.Fail(http.StatusServiceUnavailable)
+
+	req, err = http.NewRequest(http.MethodGet, r.ChartURL("subscription-release-test-1", "0.1.0"), nil)
+	assert.NoError(t, err)
+	req.SetBasicAuth("user", "password")
+
+	resp, err = http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+
+	assert.Equal(t, []string{"/index.yaml", "/index.yaml", "/subscription-release-test-1-0.1.0.tgz"}, r.Requests())
+}
@@ -0,0 +1,159 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testutils
+
+import (
+	"io/ioutil"
+	"net/http/cgi"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+)
+
+// gitRepoName is the name of the repository served by a GitServer
+const gitRepoName = "charts"
+
+// GitServer is an in-process git server, serving a repository over the smart
+// HTTP protocol with git http-backend, shallow clones included. The test is
+// skipped if git is not in the PATH.
+type GitServer struct {
+	*server
+
+	// Repo is the repository served, with its worktree.
+	Repo *git.Repository
+
+	worktree string
+}
+
+// NewGitServer serves a repository whose master branch has one commit of the
+// content of dir, e.g. a directory of charts. The test fails if dir cannot be
+// committed.
+func NewGitServer(t testing.TB, dir string, opts ...Option) *GitServer {
+	t.Helper()
+
+	gitPath, err := exec.LookPath("git")
+	if err != nil {
+		t.Skip("git is not in the PATH: ", err)
+	}
+
+	root, err := ioutil.TempDir("", "gitserver")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	worktree := filepath.Join(root, gitRepoName)
+
+	repo, err := git.PlainInit(worktree, false)
+	if err != nil {
+		os.RemoveAll(root)
+		t.Fatal(err)
+	}
+
+	backend := &cgi.Handler{
+		Path: gitPath,
+		Args: []string{"http-backend"},
+		Env:  []string{"GIT_PROJECT_ROOT=" + root, "GIT_HTTP_EXPORT_ALL=1"},
+	}
+
+	s := &GitServer{server: newServer(backend, opts), Repo: repo, worktree: worktree}
+
+	t.Cleanup(func() {
+		s.Close()
+		os.RemoveAll(root)
+	})
+
+	s.Commit(t, dir)
+
+	return s
+}
+
+// RepoURL returns the URL to clone the repository from.
+func (s *GitServer) RepoURL() string {
+	return s.URL + "/" + gitRepoName
+}
+
+// Commit copies the content of dir to the worktree of the current branch and
+// commits it, e.g. a new version of a chart. It returns the commit ID.
+func (s *GitServer) Commit(t testing.TB, dir string) string {
+	t.Helper()
+
+	wt, err := s.Repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(s.worktree, rel)), 0700); err != nil {
+			return err
+		}
+
+		if err := ioutil.WriteFile(filepath.Join(s.worktree, rel), data, info.Mode()); err != nil {
+			return err
+		}
+
+		_, err = wt.Add(filepath.ToSlash(rel))
+
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hash, err := wt.Commit("Add "+filepath.Base(dir), &git.CommitOptions{
+		All:    true,
+		Author: &object.Signature{Name: "testutils", Email: "testutils@example.com", When: time.Now()},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return hash.String()
+}
+
+// Branch creates the branch name at the current commit and checks it out,
+// the next commits are made to it.
+func (s *GitServer) Branch(t testing.TB, name string) {
+	t.Helper()
+
+	wt, err := s.Repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = wt.Checkout(&git.CheckoutOptions{Branch: plumbing.NewBranchReferenceName(name), Create: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
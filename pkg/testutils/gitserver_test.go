@@ -0,0 +1,57 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testutils
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	githttp "gopkg.in/src-d/go-git.v4/plumbing/transport/http"
+)
+
+func TestGitServer(t *testing.T) {
+	s := NewGitServer(t, "../../test/github", WithBasicAuth("user", "token"))
+	s.Branch(t, "release")
+	commit := s.Commit(t, "../../test/helmrepo")
+
+	dir, err := ioutil.TempDir("", "gitserver")
+	assert.NoError(t, err)
+
+	defer os.RemoveAll(dir)
+
+	_, err = git.PlainClone(filepath.Join(dir, "denied"), false, &git.CloneOptions{URL: s.RepoURL()})
+	assert.Error(t, err)
+
+	r, err := git.PlainClone(filepath.Join(dir, "release"), false, &git.CloneOptions{
+		URL:           s.RepoURL(),
+		Auth:          &githttp.BasicAuth{Username: "user", Password: "token"},
+		ReferenceName: plumbing.NewBranchReferenceName("release"),
+		SingleBranch:  true,
+		Depth:         1,
+	})
+	assert.NoError(t, err)
+
+	head, err := r.Head()
+	assert.NoError(t, err)
+	assert.Equal(t, commit, head.Hash().String())
+
+	assert.FileExists(t, filepath.Join(dir, "release", "subscription-release-test-1", "Chart.yaml"))
+	assert.FileExists(t, filepath.Join(dir, "release", "index.yaml"))
+}
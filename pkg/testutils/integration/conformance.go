@@ -0,0 +1,88 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package integration
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+	"github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/helmreleaseclient"
+	"github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/testutils"
+)
+
+// conformanceChart is the chart deployed by Conformance
+const conformanceChart = "subscription-release-test-1"
+
+// Conformance installs, upgrades and uninstalls a release of a chart served
+// by an in-process chart repository, in the default namespace.
+func Conformance(t *testing.T, h *Harness) {
+	repo := testutils.NewChartRepo(t, []string{filepath.Join(ModuleDir(), "test", "github", conformanceChart)})
+	ctx := context.TODO()
+
+	hr := helmreleaseclient.NewHelmRelease("default", "conformance",
+		helmreleaseclient.WithHelmRepo(repo.ChartURL(conformanceChart, "0.1.0"), conformanceChart))
+	key := helmreleaseclient.Key(hr)
+
+	t.Run("Install", func(t *testing.T) {
+		if err := h.Create(ctx, hr); err != nil {
+			t.Fatal(err)
+		}
+
+		if revision := helmreleaseclient.DeployedRevision(h.EventuallyReady(t, key)); revision != 1 {
+			t.Fatalf("HelmRelease %s deployed revision %d, expected 1", key, revision)
+		}
+	})
+
+	t.Run("Upgrade", func(t *testing.T) {
+		current, err := h.GetHelmRelease(ctx, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		current.Spec = map[string]interface{}{"subscriptionrelease": map[string]interface{}{"enabled": false}}
+
+		if err := h.Update(ctx, current); err != nil {
+			t.Fatal(err)
+		}
+
+		if revision := helmreleaseclient.DeployedRevision(h.EventuallyReady(t, key)); revision != 2 {
+			t.Fatalf("HelmRelease %s deployed revision %d, expected 2", key, revision)
+		}
+	})
+
+	t.Run("Uninstall", func(t *testing.T) {
+		if err := h.Delete(ctx, &appv1.HelmRelease{ObjectMeta: hr.ObjectMeta}); err != nil {
+			t.Fatal(err)
+		}
+
+		// the finalizer is removed once the release is uninstalled
+		err := wait.PollImmediate(pollInterval, h.timeout(), func() (bool, error) {
+			_, err := h.GetHelmRelease(ctx, key)
+			if apierrors.IsNotFound(err) {
+				return true, nil
+			}
+
+			return false, err
+		})
+		if err != nil {
+			t.Fatalf("HelmRelease %s is not deleted: %v", key, err)
+		}
+	})
+}
@@ -0,0 +1,206 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package integration runs the HelmRelease controller against the API server
+// of envtest, so that the operators and the distributions embedding it run
+// the same conformance tests against their builds, e.g.
+//
+//	var h *integration.Harness
+//
+//	func TestMain(m *testing.M) {
+//		var err error
+//		if h, err = integration.Start(nil); err != nil {
+//			log.Fatal(err)
+//		}
+//		code := m.Run()
+//		h.Stop()
+//		os.Exit(code)
+//	}
+//
+//	func TestConformance(t *testing.T) {
+//		integration.Conformance(t, h)
+//	}
+//
+// The envtest binaries are looked up in KUBEBUILDER_ASSETS, see the envtest
+// package of controller-runtime.
+package integration
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	apitypes "k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/envtest"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+	"github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/controller/helmrelease"
+	"github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/helmreleaseclient"
+)
+
+// DefaultTimeout is how long EventuallyReady waits by default
+const DefaultTimeout = 2 * time.Minute
+
+// pollInterval is the interval the HelmReleases are read at while waiting
+const pollInterval = time.Second
+
+// Harness runs an API server and the HelmRelease controller. Its client
+// reads the HelmReleases from the API server, not from the cache of the
+// controller.
+type Harness struct {
+	*helmreleaseclient.Client
+
+	// Env is the envtest environment of the API server.
+	Env *envtest.Environment
+	// Config is the config of the API server.
+	Config *rest.Config
+	// Manager runs the controller.
+	Manager manager.Manager
+	// Timeout bounds the waits of the helpers, DefaultTimeout if 0.
+	Timeout time.Duration
+
+	stop chan struct{}
+	done chan error
+}
+
+// ModuleDir returns the directory of the source of this module, holding its
+// CRDs and test charts.
+func ModuleDir() string {
+	_, file, _, _ := runtime.Caller(0)
+
+	return filepath.Join(filepath.Dir(file), "..", "..", "..")
+}
+
+// Start starts the API server of env, an envtest environment of the local
+// binaries if it is nil, installs the HelmRelease CRD unless env lists the
+// CRDs to install, and starts the controller with helmrelease.Options.
+func Start(env *envtest.Environment) (*Harness, error) {
+	if env == nil {
+		env = &envtest.Environment{}
+	}
+
+	if len(env.CRDDirectoryPaths) == 0 && len(env.CRDs) == 0 {
+		env.CRDDirectoryPaths = []string{filepath.Join(ModuleDir(), "deploy", "crds")}
+	}
+
+	cfg, err := env.Start()
+	if err != nil {
+		return nil, err
+	}
+
+	h, err := start(env, cfg)
+	if err != nil {
+		_ = env.Stop()
+		return nil, err
+	}
+
+	return h, nil
+}
+
+func start(env *envtest.Environment, cfg *rest.Config) (*Harness, error) {
+	scheme, err := helmreleaseclient.NewScheme()
+	if err != nil {
+		return nil, err
+	}
+
+	mgr, err := manager.New(cfg, manager.Options{
+		Scheme:             scheme,
+		MetricsBindAddress: "0",
+		NewClient:          helmrelease.NewClient,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := helmrelease.Add(mgr); err != nil {
+		return nil, err
+	}
+
+	c, err := helmreleaseclient.New(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	h := &Harness{
+		Client:  c,
+		Env:     env,
+		Config:  cfg,
+		Manager: mgr,
+		stop:    make(chan struct{}),
+		done:    make(chan error, 1),
+	}
+
+	go func() {
+		h.done <- mgr.Start(h.stop)
+	}()
+
+	if !mgr.GetCache().WaitForCacheSync(h.stop) {
+		close(h.stop)
+
+		if err := <-h.done; err != nil {
+			return nil, err
+		}
+
+		return nil, errors.New("failed to sync the cache of the controller")
+	}
+
+	return h, nil
+}
+
+// Stop stops the controller, then the API server.
+func (h *Harness) Stop() error {
+	close(h.stop)
+
+	if err := <-h.done; err != nil {
+		_ = h.Env.Stop()
+		return err
+	}
+
+	return h.Env.Stop()
+}
+
+// EventuallyReady waits until the HelmRelease key is Ready for its current
+// generation and returns it. The test fails if it stalls or is not ready
+// within Timeout.
+func (h *Harness) EventuallyReady(t testing.TB, key apitypes.NamespacedName) *appv1.HelmRelease {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), h.timeout())
+	defer cancel()
+
+	hr, err := h.WaitForReady(ctx, key, pollInterval)
+	if err != nil {
+		if hr != nil {
+			reason, message := helmreleaseclient.NotReadyReason(hr)
+			t.Fatalf("%v, %s: %s", err, reason, message)
+		}
+
+		t.Fatal(err)
+	}
+
+	return hr
+}
+
+func (h *Harness) timeout() time.Duration {
+	if h.Timeout == 0 {
+		return DefaultTimeout
+	}
+
+	return h.Timeout
+}
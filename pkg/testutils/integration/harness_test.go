@@ -0,0 +1,42 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package integration
+
+import (
+	stdlog "log"
+	"os"
+	"testing"
+)
+
+var h *Harness
+
+func TestMain(m *testing.M) {
+	var err error
+	if h, err = Start(nil); err != nil {
+		stdlog.Fatal(err)
+	}
+
+	code := m.Run()
+
+	if err := h.Stop(); err != nil {
+		stdlog.Fatal(err)
+	}
+
+	os.Exit(code)
+}
+
+func TestConformance(t *testing.T) {
+	Conformance(t, h)
+}
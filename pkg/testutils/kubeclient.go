@@ -0,0 +1,583 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testutils
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	jsonpatch "github.com/evanphx/json-patch"
+	"helm.sh/helm/v3/pkg/kube"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/meta/testrestmapper"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	apitypes "k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/yaml"
+
+	"github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/client"
+)
+
+var _ kube.Interface = &KubeClient{}
+
+// KubeClient is a helm kube.Interface recording the calls of its methods and
+// the writes they send to an in-process API server, so that the tests assert
+// which resources a release creates, updates and deletes, and with which
+// patch types, without a cluster, e.g.
+//
+//	c := testutils.NewKubeClient(t, "default")
+//	c.AddObject(t, deployed)
+//	target, err := c.Build(strings.NewReader(manifest), true)
+//	_, err = c.Update(original, target, false)
+//	assert.Equal(t, apitypes.StrategicMergePatchType, c.Requests()[0].PatchType)
+//
+// The calls are served by the helm kube client, the resources it builds
+// writing to the in-process API server, so that the writes of the clients
+// wrapping it and writing the resources themselves, e.g. with server-side
+// apply, are recorded too. The API server only stores the objects: the
+// manifests are not validated, the resources are ready as soon as they are
+// written and the kinds missing from the client-go scheme are mapped with
+// AddKind.
+type KubeClient struct {
+	*kube.Client
+
+	api    *server
+	kinds  *meta.DefaultRESTMapper
+	mapper meta.RESTMapper
+
+	mu       sync.Mutex
+	version  int
+	objects  map[objectKey]map[string]interface{}
+	calls    []KubeCall
+	requests []APIRequest
+}
+
+// Resource identifies a resource of a KubeCall or an APIRequest.
+type Resource struct {
+	APIVersion string
+	Kind       string
+	Namespace  string
+	Name       string
+}
+
+// KubeCall is a call of a kube.Interface method of a KubeClient.
+type KubeCall struct {
+	// Method is the name of the method, e.g. Update.
+	Method string
+	// Resources are the resources the method was called with, the target
+	// resources of an Update and the resources built by a Build.
+	Resources []Resource
+	// Original and Force are the original resources and the force flag of an
+	// Update.
+	Original []Resource
+	Force    bool
+}
+
+// APIRequest is a write sent to the API server.
+type APIRequest struct {
+	// Verb is create, update, patch or delete.
+	Verb     string
+	Resource Resource
+	// PatchType is the type of a patch, e.g. application/apply-patch+yaml.
+	PatchType apitypes.PatchType
+	// Body is the object created or updated, the patch or the delete options.
+	Body []byte
+}
+
+type objectKey struct {
+	resource  schema.GroupVersionResource
+	namespace string
+	name      string
+}
+
+// NewKubeClient returns a KubeClient building the resources without a
+// namespace in namespace. Its API server is closed when the test completes.
+func NewKubeClient(t testing.TB, namespace string) *KubeClient {
+	t.Helper()
+
+	c := &KubeClient{
+		kinds:   meta.NewDefaultRESTMapper(nil),
+		objects: make(map[objectKey]map[string]interface{}),
+	}
+
+	// the CRDs of the charts
+	for _, version := range []string{"v1", "v1beta1"} {
+		c.kinds.Add(schema.GroupVersionKind{Group: "apiextensions.k8s.io", Version: version, Kind: "CustomResourceDefinition"},
+			meta.RESTScopeRoot)
+	}
+
+	c.mapper = meta.FirstHitRESTMapper{
+		MultiRESTMapper: meta.MultiRESTMapper{c.kinds, testrestmapper.TestOnlyStaticRESTMapper(scheme.Scheme)},
+	}
+
+	c.api = newServer(http.HandlerFunc(c.serve), nil)
+	t.Cleanup(c.api.Close)
+
+	getter, err := client.NewRESTClientGetterForConfig(&rest.Config{Host: c.api.URL}, c.mapper, namespace)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c.Client = kube.New(getter)
+
+	return c
+}
+
+// AddKind maps gvk, e.g. the kind of a CustomResourceDefinition of a chart,
+// to its lower-case plural resource. The kinds are added before the client
+// is used.
+func (c *KubeClient) AddKind(gvk schema.GroupVersionKind, namespaced bool) {
+	scope := meta.RESTScopeRoot
+	if namespaced {
+		scope = meta.RESTScopeNamespace
+	}
+
+	c.kinds.Add(gvk, scope)
+}
+
+// AddObject adds obj to the objects served, e.g. a resource of the deployed
+// release or a resource changed by another client. The test fails if its
+// kind is not mapped.
+func (c *KubeClient) AddObject(t testing.TB, obj *unstructured.Unstructured) {
+	t.Helper()
+
+	gvk := obj.GroupVersionKind()
+
+	mapping, err := c.mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.store(objectKey{mapping.Resource, obj.GetNamespace(), obj.GetName()}, obj.DeepCopy().Object)
+}
+
+// Object returns the object served for r, nil if there is none.
+func (c *KubeClient) Object(r Resource) *unstructured.Unstructured {
+	gv, err := schema.ParseGroupVersion(r.APIVersion)
+	if err != nil {
+		return nil
+	}
+
+	mapping, err := c.mapper.RESTMapping(schema.GroupKind{Group: gv.Group, Kind: r.Kind}, gv.Version)
+	if err != nil {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	obj, ok := c.objects[objectKey{mapping.Resource, r.Namespace, r.Name}]
+	if !ok {
+		return nil
+	}
+
+	return (&unstructured.Unstructured{Object: obj}).DeepCopy()
+}
+
+// Calls returns the calls of the kube.Interface methods, in order.
+func (c *KubeClient) Calls() []KubeCall {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return append([]KubeCall(nil), c.calls...)
+}
+
+// Requests returns the writes sent to the API server, in the order they were
+// received. The helm kube client writes the resources of a kind
+// concurrently, their writes are in any order.
+func (c *KubeClient) Requests() []APIRequest {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return append([]APIRequest(nil), c.requests...)
+}
+
+// Fail fails the next API requests with the HTTP status, e.g. to test the
+// errors of the API server. A status of 0 serves them again.
+func (c *KubeClient) Fail(status int) {
+	c.api.Fail(status)
+}
+
+// Build builds the resources of the manifests. They are never validated, the
+// API server serves no OpenAPI schema.
+func (c *KubeClient) Build(reader io.Reader, _ bool) (kube.ResourceList, error) {
+	resources, err := c.Client.Build(reader, false)
+	c.record(KubeCall{Method: "Build", Resources: resourcesOf(resources)})
+
+	return resources, err
+}
+
+func (c *KubeClient) Create(resources kube.ResourceList) (*kube.Result, error) {
+	c.record(KubeCall{Method: "Create", Resources: resourcesOf(resources)})
+
+	return c.Client.Create(resources)
+}
+
+func (c *KubeClient) Update(original, target kube.ResourceList, force bool) (*kube.Result, error) {
+	c.record(KubeCall{Method: "Update", Resources: resourcesOf(target), Original: resourcesOf(original), Force: force})
+
+	return c.Client.Update(original, target, force)
+}
+
+func (c *KubeClient) Delete(resources kube.ResourceList) (*kube.Result, []error) {
+	c.record(KubeCall{Method: "Delete", Resources: resourcesOf(resources)})
+
+	return c.Client.Delete(resources)
+}
+
+// Wait returns right away, the resources are ready once written.
+func (c *KubeClient) Wait(resources kube.ResourceList, _ time.Duration) error {
+	c.record(KubeCall{Method: "Wait", Resources: resourcesOf(resources)})
+
+	return nil
+}
+
+// WatchUntilReady returns right away, the hooks are complete once written.
+func (c *KubeClient) WatchUntilReady(resources kube.ResourceList, _ time.Duration) error {
+	c.record(KubeCall{Method: "WatchUntilReady", Resources: resourcesOf(resources)})
+
+	return nil
+}
+
+// WaitAndGetCompletedPodPhase reports the test pods as succeeded.
+func (c *KubeClient) WaitAndGetCompletedPodPhase(name string, _ time.Duration) (corev1.PodPhase, error) {
+	c.record(KubeCall{Method: "WaitAndGetCompletedPodPhase", Resources: []Resource{{APIVersion: "v1", Kind: "Pod", Name: name}}})
+
+	return corev1.PodSucceeded, nil
+}
+
+// IsReachable is always true, the API server serves no discovery.
+func (c *KubeClient) IsReachable() error {
+	return nil
+}
+
+func (c *KubeClient) record(call KubeCall) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.calls = append(c.calls, call)
+}
+
+func resourcesOf(resources kube.ResourceList) []Resource {
+	refs := make([]Resource, 0, len(resources))
+
+	for _, info := range resources {
+		gvk := info.Object.GetObjectKind().GroupVersionKind()
+		refs = append(refs, Resource{
+			APIVersion: gvk.GroupVersion().String(),
+			Kind:       gvk.Kind,
+			Namespace:  info.Namespace,
+			Name:       info.Name,
+		})
+	}
+
+	return refs
+}
+
+// serve is the API server, serving the objects under the paths of their
+// resources. The subresources, e.g. status, are not served.
+func (c *KubeClient) serve(w http.ResponseWriter, r *http.Request) {
+	key, ok := parseObjectPath(r.URL.Path)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	c.mu.Lock()
+	status, obj := c.handle(r.Method, key, apitypes.PatchType(r.Header.Get("Content-Type")), body)
+	c.mu.Unlock()
+
+	data, err := json.Marshal(obj)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, _ = w.Write(data)
+}
+
+// parseObjectPath parses /api/v1/namespaces/default/configmaps/name and
+// /apis/apps/v1/deployments, the name and the namespace being optional.
+func parseObjectPath(path string) (objectKey, bool) {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+
+	var gv schema.GroupVersion
+
+	switch {
+	case len(parts) >= 3 && parts[0] == "api":
+		gv, parts = schema.GroupVersion{Version: parts[1]}, parts[2:]
+	case len(parts) >= 4 && parts[0] == "apis":
+		gv, parts = schema.GroupVersion{Group: parts[1], Version: parts[2]}, parts[3:]
+	default:
+		return objectKey{}, false
+	}
+
+	key := objectKey{}
+
+	// /api/v1/namespaces/default is the namespace itself
+	if len(parts) > 2 && parts[0] == "namespaces" {
+		key.namespace, parts = parts[1], parts[2:]
+	}
+
+	switch len(parts) {
+	case 1:
+	case 2:
+		key.name = parts[1]
+	default:
+		return objectKey{}, false
+	}
+
+	key.resource = gv.WithResource(parts[0])
+
+	return key, true
+}
+
+// handle serves a request and returns its status and the object or the
+// Status written back.
+func (c *KubeClient) handle(method string, key objectKey, patchType apitypes.PatchType,
+	body []byte) (int, interface{}) {
+	gr := key.resource.GroupResource()
+
+	if method == http.MethodGet {
+		if key.name == "" {
+			return c.list(key)
+		}
+
+		obj, ok := c.objects[key]
+		if !ok {
+			return statusOf(apierrors.NewNotFound(gr, key.name))
+		}
+
+		return http.StatusOK, obj
+	}
+
+	var obj map[string]interface{}
+
+	if method == http.MethodPost || method == http.MethodPut {
+		if err := yaml.Unmarshal(body, &obj); err != nil || obj == nil {
+			return statusOf(apierrors.NewBadRequest("the body is not an object"))
+		}
+
+		if key.name == "" {
+			key.name = (&unstructured.Unstructured{Object: obj}).GetName()
+		}
+	}
+
+	req := APIRequest{Resource: Resource{
+		APIVersion: key.resource.GroupVersion().String(),
+		Namespace:  key.namespace,
+		Name:       key.name,
+	}, Body: body}
+
+	if gvk, err := c.mapper.KindFor(key.resource); err == nil {
+		req.Resource.Kind = gvk.Kind
+	}
+
+	switch method {
+	case http.MethodPost:
+		req.Verb = "create"
+	case http.MethodPut:
+		req.Verb = "update"
+	case http.MethodPatch:
+		req.Verb, req.PatchType = "patch", patchType
+	case http.MethodDelete:
+		req.Verb = "delete"
+	default:
+		return statusOf(apierrors.NewMethodNotSupported(gr, method))
+	}
+
+	c.requests = append(c.requests, req)
+
+	live, exists := c.objects[key]
+
+	switch method {
+	case http.MethodPost:
+		if exists {
+			return statusOf(apierrors.NewAlreadyExists(gr, key.name))
+		}
+
+		c.store(key, obj)
+
+		return http.StatusCreated, obj
+	case http.MethodPatch:
+		return c.patch(key, patchType, body)
+	}
+
+	if !exists {
+		return statusOf(apierrors.NewNotFound(gr, key.name))
+	}
+
+	if method == http.MethodDelete {
+		delete(c.objects, key)
+
+		return http.StatusOK, live
+	}
+
+	c.store(key, obj)
+
+	return http.StatusOK, obj
+}
+
+// patch patches the object of key. The apply patches are merged into the
+// object, the fields no longer applied are kept, and the strategic merge
+// patches are only served for the kinds of the client-go scheme, like the
+// API server does.
+func (c *KubeClient) patch(key objectKey, patchType apitypes.PatchType, patch []byte) (int, interface{}) {
+	gr := key.resource.GroupResource()
+
+	live, ok := c.objects[key]
+	if !ok && patchType != apitypes.ApplyPatchType {
+		return statusOf(apierrors.NewNotFound(gr, key.name))
+	}
+
+	liveJSON, err := json.Marshal(live)
+	if err != nil {
+		return statusOf(apierrors.NewInternalError(err))
+	}
+
+	var patched []byte
+
+	switch patchType {
+	case apitypes.JSONPatchType:
+		var p jsonpatch.Patch
+		if p, err = jsonpatch.DecodePatch(patch); err == nil {
+			patched, err = p.Apply(liveJSON)
+		}
+	case apitypes.StrategicMergePatchType:
+		gvk, _ := c.mapper.KindFor(key.resource)
+
+		typed, err := scheme.Scheme.New(gvk)
+		if err != nil {
+			return statusOf(apierrors.NewGenericServerResponse(http.StatusUnsupportedMediaType, "patch", gr, key.name,
+				"the strategic merge patches are not supported by the custom resources", 0, false))
+		}
+
+		patched, err = strategicpatch.StrategicMergePatch(liveJSON, patch, typed)
+		if err != nil {
+			return statusOf(apierrors.NewBadRequest(err.Error()))
+		}
+	case apitypes.ApplyPatchType:
+		var patchJSON []byte
+		if patchJSON, err = yaml.YAMLToJSON(patch); err == nil {
+			if !ok {
+				liveJSON = []byte("{}")
+			}
+
+			patched, err = jsonpatch.MergePatch(liveJSON, patchJSON)
+		}
+	default:
+		patched, err = jsonpatch.MergePatch(liveJSON, patch)
+	}
+
+	obj := map[string]interface{}{}
+
+	if err == nil {
+		err = json.Unmarshal(patched, &obj)
+	}
+
+	if err != nil {
+		return statusOf(apierrors.NewBadRequest(err.Error()))
+	}
+
+	c.store(key, obj)
+
+	if !ok {
+		return http.StatusCreated, obj
+	}
+
+	return http.StatusOK, obj
+}
+
+// list lists the objects of the resource of key, of its namespace if set.
+func (c *KubeClient) list(key objectKey) (int, interface{}) {
+	gvk, err := c.mapper.KindFor(key.resource)
+	if err != nil {
+		return statusOf(apierrors.NewNotFound(key.resource.GroupResource(), ""))
+	}
+
+	var keys []objectKey
+
+	for k := range c.objects {
+		if k.resource == key.resource && (key.namespace == "" || k.namespace == key.namespace) {
+			keys = append(keys, k)
+		}
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].namespace != keys[j].namespace {
+			return keys[i].namespace < keys[j].namespace
+		}
+
+		return keys[i].name < keys[j].name
+	})
+
+	items := make([]interface{}, 0, len(keys))
+	for _, k := range keys {
+		items = append(items, c.objects[k])
+	}
+
+	return http.StatusOK, map[string]interface{}{
+		"apiVersion": key.resource.GroupVersion().String(),
+		"kind":       gvk.Kind + "List",
+		"metadata":   map[string]interface{}{"resourceVersion": strconv.Itoa(c.version)},
+		"items":      items,
+	}
+}
+
+// store stores obj as the object of key with a new resource version.
+func (c *KubeClient) store(key objectKey, obj map[string]interface{}) {
+	c.version++
+
+	u := &unstructured.Unstructured{Object: obj}
+	u.SetName(key.name)
+	u.SetResourceVersion(strconv.Itoa(c.version))
+
+	if key.namespace != "" {
+		u.SetNamespace(key.namespace)
+	}
+
+	c.objects[key] = obj
+}
+
+func statusOf(err *apierrors.StatusError) (int, interface{}) {
+	status := err.ErrStatus
+	status.Kind, status.APIVersion = "Status", "v1"
+
+	return int(status.Code), status
+}
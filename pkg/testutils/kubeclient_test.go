@@ -0,0 +1,140 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testutils
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	apitypes "k8s.io/apimachinery/pkg/types"
+)
+
+const kubeClientManifest = `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+data:
+  size: "%s"
+---
+apiVersion: example.com/v1
+kind: Database
+metadata:
+  name: db
+spec:
+  size: "%s"
+`
+
+func withoutBodies(requests []APIRequest) []APIRequest {
+	for i := range requests {
+		requests[i].Body = nil
+	}
+
+	return requests
+}
+
+func TestKubeClient(t *testing.T) {
+	c := NewKubeClient(t, "default")
+	c.AddKind(schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Database"}, true)
+
+	original, err := c.Build(strings.NewReader(strings.ReplaceAll(kubeClientManifest, "%s", "1")+`---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+spec:
+  ports:
+  - port: 80
+`), true)
+	require.NoError(t, err)
+
+	_, err = c.Create(original)
+	require.NoError(t, err)
+
+	target, err := c.Build(strings.NewReader(strings.ReplaceAll(kubeClientManifest, "%s", "2")+`---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: credentials
+`), true)
+	require.NoError(t, err)
+
+	result, err := c.Update(original, target, false)
+	require.NoError(t, err)
+	assert.Len(t, result.Created, 1)
+	assert.Len(t, result.Updated, 2)
+	assert.Len(t, result.Deleted, 1)
+
+	config := Resource{APIVersion: "v1", Kind: "ConfigMap", Namespace: "default", Name: "config"}
+	db := Resource{APIVersion: "example.com/v1", Kind: "Database", Namespace: "default", Name: "db"}
+	web := Resource{APIVersion: "v1", Kind: "Service", Namespace: "default", Name: "web"}
+	credentials := Resource{APIVersion: "v1", Kind: "Secret", Namespace: "default", Name: "credentials"}
+
+	assert.Equal(t, []APIRequest{
+		{Verb: "create", Resource: config},
+		{Verb: "create", Resource: db},
+		{Verb: "create", Resource: web},
+		{Verb: "patch", Resource: config, PatchType: apitypes.StrategicMergePatchType},
+		{Verb: "patch", Resource: db, PatchType: apitypes.MergePatchType},
+		{Verb: "create", Resource: credentials},
+		{Verb: "delete", Resource: web},
+	}, withoutBodies(c.Requests()))
+
+	var methods []string
+	for _, call := range c.Calls() {
+		methods = append(methods, call.Method)
+	}
+
+	assert.Equal(t, []string{"Build", "Create", "Build", "Update"}, methods)
+	assert.Equal(t, []Resource{config, db, web}, c.Calls()[3].Original)
+
+	if obj := c.Object(config); assert.NotNil(t, obj) {
+		assert.Equal(t, map[string]interface{}{"size": "2"}, obj.Object["data"])
+	}
+
+	if obj := c.Object(db); assert.NotNil(t, obj) {
+		assert.Equal(t, map[string]interface{}{"size": "2"}, obj.Object["spec"])
+	}
+
+	assert.Nil(t, c.Object(web))
+
+	c.Fail(http.StatusServiceUnavailable)
+
+	_, errs := c.Delete(target)
+	assert.NotEmpty(t, errs)
+}
+
+func TestParseObjectPath(t *testing.T) {
+	tests := []struct {
+		path string
+		key  objectKey
+		ok   bool
+	}{
+		{"/api/v1/namespaces/default/configmaps/config", objectKey{schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}, "default", "config"}, true},
+		{"/api/v1/namespaces/default", objectKey{schema.GroupVersionResource{Version: "v1", Resource: "namespaces"}, "", "default"}, true},
+		{"/apis/apps/v1/namespaces/default/deployments", objectKey{schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}, "default", ""}, true},
+		{"/apis/apps/v1/namespaces/default/deployments/web/status", objectKey{}, false},
+		{"/version", objectKey{}, false},
+	}
+
+	for _, test := range tests {
+		key, ok := parseObjectPath(test.path)
+		assert.Equal(t, test.ok, ok, test.path)
+		assert.Equal(t, test.key, key, test.path)
+	}
+}
@@ -0,0 +1,130 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package testutils serves chart sources from test data in process, a helm
+// chart repository and a git server, so that the download of the charts is
+// tested without a network, and records the writes of the releases to an
+// in-process API server with KubeClient, e.g.
+//
+//	repo := testutils.NewChartRepo(t, []string{"../../test/github/subscription-release-test-1"},
+//		testutils.WithBasicAuth("user", "password"))
+//	url := repo.ChartURL("subscription-release-test-1", "0.1.0")
+//
+//	git := testutils.NewGitServer(t, "../../test/github")
+//	url = git.RepoURL()
+//
+//	kubeClient := testutils.NewKubeClient(t, "default")
+//
+// The servers are closed and their data removed when the test completes.
+package testutils
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+)
+
+// Option configures a ChartRepo or a GitServer.
+type Option func(*server)
+
+// WithBasicAuth requires the requests to authenticate with user and password.
+func WithBasicAuth(user, password string) Option {
+	return func(s *server) {
+		s.user = user
+		s.password = password
+	}
+}
+
+// WithTLS serves HTTPS with a self-signed certificate, the clients must skip
+// its verification or trust the certificate of the httptest.Server.
+func WithTLS() Option {
+	return func(s *server) {
+		s.tls = true
+	}
+}
+
+// server is the HTTP server shared by ChartRepo, GitServer and KubeClient.
+// It records the paths requested and fails them with the status set by Fail.
+type server struct {
+	*httptest.Server
+
+	user     string
+	password string
+	tls      bool
+
+	mu       sync.Mutex
+	status   int
+	requests []string
+}
+
+func newServer(handler http.Handler, opts []Option) *server {
+	s := &server{}
+	for _, o := range opts {
+		o(s)
+	}
+
+	s.Server = httptest.NewUnstartedServer(s.wrap(handler))
+
+	if s.tls {
+		s.StartTLS()
+	} else {
+		s.Start()
+	}
+
+	return s
+}
+
+// Fail fails the next requests with the HTTP status, e.g. to test the
+// failover to another source. A status of 0 serves them again.
+func (s *server) Fail(status int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.status = status
+}
+
+// Requests returns the paths requested, in order, the failed requests
+// included.
+func (s *server) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]string(nil), s.requests...)
+}
+
+func (s *server) wrap(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.requests = append(s.requests, r.URL.Path)
+		status := s.status
+		s.mu.Unlock()
+
+		if status != 0 {
+			http.Error(w, http.StatusText(status), status)
+			return
+		}
+
+		if s.user != "" || s.password != "" {
+			user, password, ok := r.BasicAuth()
+			if !ok || user != s.user || password != s.password {
+				w.Header().Set("WWW-Authenticate", `Basic realm="testutils"`)
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+
+				return
+			}
+		}
+
+		handler.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,273 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package tracing records the spans of the reconciles and exports them to an
+// OpenTelemetry collector with OTLP over HTTP, JSON encoded.
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/klog"
+)
+
+const (
+	// ServiceName is the service.name of the exported spans
+	ServiceName = "multicloud-operators-subscription-release"
+
+	// maxQueuedSpans bounds the spans waiting for an export, the next ones
+	// are dropped while the collector is unreachable
+	maxQueuedSpans = 2048
+
+	// exportInterval is how often the queued spans are exported
+	exportInterval = 5 * time.Second
+
+	// OTLP span kind and status codes
+	spanKindInternal = 1
+	statusCodeOK     = 1
+	statusCodeError  = 2
+)
+
+// exporter is the exporter set by Setup, the spans are not recorded if nil
+var exporter *Exporter
+
+// Span is a phase of a reconcile. The nil Span is valid and records nothing,
+// so that the callers do not check if the tracing is enabled.
+type Span struct {
+	traceID    string
+	spanID     string
+	parentID   string
+	name       string
+	start      time.Time
+	attributes map[string]string
+}
+
+type spanKey struct{}
+
+// Start starts the span name, child of the span of ctx if any, and returns
+// the context holding it. attributes are key value pairs.
+func Start(ctx context.Context, name string, attributes ...string) (context.Context, *Span) {
+	if exporter == nil {
+		return ctx, nil
+	}
+
+	span := &Span{
+		spanID:     randomID(8),
+		name:       name,
+		start:      time.Now(),
+		attributes: map[string]string{},
+	}
+
+	if parent, ok := ctx.Value(spanKey{}).(*Span); ok && parent != nil {
+		span.traceID, span.parentID = parent.traceID, parent.spanID
+	} else {
+		span.traceID = randomID(16)
+	}
+
+	for i := 0; i+1 < len(attributes); i += 2 {
+		span.attributes[attributes[i]] = attributes[i+1]
+	}
+
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+// SetAttribute sets the attribute key of s.
+func (s *Span) SetAttribute(key, value string) {
+	if s == nil {
+		return
+	}
+
+	s.attributes[key] = value
+}
+
+// End ends s and queues it for the export, failed if err is not nil.
+func (s *Span) End(err error) {
+	if s == nil || exporter == nil {
+		return
+	}
+
+	exporter.enqueue(otlpSpan(s, time.Now(), err))
+}
+
+// Exporter exports the ended spans to the OTLP/HTTP endpoint of a collector.
+// It is a manager runnable, run by all the replicas.
+type Exporter struct {
+	url    string
+	client *http.Client
+
+	mu      sync.Mutex
+	spans   []interface{}
+	dropped int
+}
+
+// Setup enables the tracing and returns the exporter of the spans to
+// endpoint, e.g. http://otel-collector:4318.
+func Setup(endpoint string) *Exporter {
+	exporter = &Exporter{
+		url:    strings.TrimSuffix(endpoint, "/") + "/v1/traces",
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+
+	return exporter
+}
+
+// Disable disables the tracing, the spans are no longer recorded. The queued
+// ones are not exported.
+func Disable() {
+	exporter = nil
+}
+
+// Start exports the spans every few seconds until stop is closed.
+func (e *Exporter) Start(stop <-chan struct{}) error {
+	ticker := time.NewTicker(exportInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			e.Flush()
+		case <-stop:
+			e.Flush()
+			return nil
+		}
+	}
+}
+
+// NeedLeaderElection is false, the replicas not leading reconcile the
+// HelmReleases of their shard.
+func (e *Exporter) NeedLeaderElection() bool {
+	return false
+}
+
+// Flush exports the queued spans. The spans failing to be exported are
+// dropped.
+func (e *Exporter) Flush() {
+	e.mu.Lock()
+	spans, dropped := e.spans, e.dropped
+	e.spans, e.dropped = nil, 0
+	e.mu.Unlock()
+
+	if dropped != 0 {
+		klog.Warning("Dropped ", dropped, " spans, the export queue was full")
+	}
+
+	if len(spans) == 0 {
+		return
+	}
+
+	if err := e.export(spans); err != nil {
+		klog.Error(err, " - Failed to export ", len(spans), " spans to ", e.url)
+	}
+}
+
+func (e *Exporter) enqueue(span interface{}) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if len(e.spans) >= maxQueuedSpans {
+		e.dropped++
+		return
+	}
+
+	e.spans = append(e.spans, span)
+}
+
+func (e *Exporter) export(spans []interface{}) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"resourceSpans": []interface{}{map[string]interface{}{
+			"resource": map[string]interface{}{
+				"attributes": otlpAttributes(map[string]string{"service.name": ServiceName}),
+			},
+			"scopeSpans": []interface{}{map[string]interface{}{
+				"scope": map[string]interface{}{"name": ServiceName},
+				"spans": spans,
+			}},
+		}},
+	})
+	if err != nil {
+		return err
+	}
+
+	resp, err := e.client.Post(e.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("collector returned %s", resp.Status)
+	}
+
+	return nil
+}
+
+// otlpSpan is the OTLP JSON encoding of s, ended at end.
+func otlpSpan(s *Span, end time.Time, err error) map[string]interface{} {
+	status := map[string]interface{}{"code": statusCodeOK}
+	if err != nil {
+		status = map[string]interface{}{"code": statusCodeError, "message": err.Error()}
+	}
+
+	span := map[string]interface{}{
+		"traceId":           s.traceID,
+		"spanId":            s.spanID,
+		"name":              s.name,
+		"kind":              spanKindInternal,
+		"startTimeUnixNano": strconv.FormatInt(s.start.UnixNano(), 10),
+		"endTimeUnixNano":   strconv.FormatInt(end.UnixNano(), 10),
+		"attributes":        otlpAttributes(s.attributes),
+		"status":            status,
+	}
+
+	if s.parentID != "" {
+		span["parentSpanId"] = s.parentID
+	}
+
+	return span
+}
+
+func otlpAttributes(attributes map[string]string) []interface{} {
+	encoded := make([]interface{}, 0, len(attributes))
+
+	for key, value := range attributes {
+		encoded = append(encoded, map[string]interface{}{
+			"key":   key,
+			"value": map[string]interface{}{"stringValue": value},
+		})
+	}
+
+	return encoded
+}
+
+// randomID returns a random hex encoded id of n bytes, the trace and span
+// ids are 16 and 8 bytes.
+func randomID(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,101 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDisabled(t *testing.T) {
+	ctx, span := Start(context.TODO(), "Reconcile")
+	assert.Nil(t, span)
+	assert.Equal(t, context.TODO(), ctx)
+
+	// the nil span records nothing
+	span.SetAttribute("key", "value")
+	span.End(nil)
+}
+
+func TestExport(t *testing.T) {
+	var received struct {
+		ResourceSpans []struct {
+			ScopeSpans []struct {
+				Spans []struct {
+					TraceID      string `json:"traceId"`
+					SpanID       string `json:"spanId"`
+					ParentSpanID string `json:"parentSpanId"`
+					Name         string `json:"name"`
+					Attributes   []struct {
+						Key   string            `json:"key"`
+						Value map[string]string `json:"value"`
+					} `json:"attributes"`
+					Status struct {
+						Code    int    `json:"code"`
+						Message string `json:"message"`
+					} `json:"status"`
+				} `json:"spans"`
+			} `json:"scopeSpans"`
+		} `json:"resourceSpans"`
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/traces", r.URL.Path)
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+	}))
+	defer server.Close()
+
+	e := Setup(server.URL)
+	defer Disable()
+
+	ctx, root := Start(context.TODO(), "Reconcile", "helmrelease", "default/nginx")
+	_, child := Start(ctx, "FetchChart")
+	child.End(errors.New("not found"))
+	root.End(nil)
+
+	e.Flush()
+
+	if !assert.Len(t, received.ResourceSpans, 1) || !assert.Len(t, received.ResourceSpans[0].ScopeSpans, 1) {
+		return
+	}
+
+	spans := received.ResourceSpans[0].ScopeSpans[0].Spans
+	if !assert.Len(t, spans, 2) {
+		return
+	}
+
+	assert.Equal(t, "FetchChart", spans[0].Name)
+	assert.Equal(t, statusCodeError, spans[0].Status.Code)
+	assert.Equal(t, "not found", spans[0].Status.Message)
+
+	assert.Equal(t, "Reconcile", spans[1].Name)
+	assert.Equal(t, statusCodeOK, spans[1].Status.Code)
+	assert.Empty(t, spans[1].ParentSpanID)
+	assert.Len(t, spans[1].TraceID, 32)
+	assert.Len(t, spans[1].SpanID, 16)
+	assert.Equal(t, "helmrelease", spans[1].Attributes[0].Key)
+	assert.Equal(t, "default/nginx", spans[1].Attributes[0].Value["stringValue"])
+
+	assert.Equal(t, spans[1].TraceID, spans[0].TraceID)
+	assert.Equal(t, spans[1].SpanID, spans[0].ParentSpanID)
+}
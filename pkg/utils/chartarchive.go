@@ -0,0 +1,70 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"helm.sh/helm/v3/pkg/chartutil"
+)
+
+// checkChartArchive reads the chart archive r through without extracting it,
+// and returns an error if it is not a gzipped tar archive of a chart, e.g. a
+// truncated download. The archives are loaded as is by the chart loader.
+func checkChartArchive(r io.Reader) error {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return fmt.Errorf("invalid chart archive: %w", err)
+	}
+
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	found := false
+
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			return fmt.Errorf("invalid chart archive: %w", err)
+		}
+
+		// the Chart.yaml of the chart, not of its subcharts
+		if parts := strings.Split(header.Name, "/"); len(parts) == 2 && parts[1] == chartutil.ChartfileName {
+			found = true
+		}
+
+		if _, err := io.Copy(ioutil.Discard, tr); err != nil {
+			return fmt.Errorf("invalid chart archive: %w", err)
+		}
+	}
+
+	if !found {
+		return fmt.Errorf("invalid chart archive: no %s", chartutil.ChartfileName)
+	}
+
+	return nil
+}
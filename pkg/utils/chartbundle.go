@@ -0,0 +1,123 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+const (
+	// ChartBundleLabel marks the ConfigMaps holding a pre-staged chart archive. Its value is the
+	// name of the chart.
+	ChartBundleLabel = "apps.open-cluster-management.io/chart-bundle"
+	// ChartBundleVersionAnnotation is the version of the chart archive of a bundle
+	ChartBundleVersionAnnotation = "apps.open-cluster-management.io/chart-version"
+)
+
+// GetChartBundle returns the ConfigMap of namespace bundling the highest version of the chart
+// matching the version constraint of the HelmRelease, nil if there is none.
+func GetChartBundle(c client.Reader, namespace string, s *appv1.HelmRelease) (*corev1.ConfigMap, error) {
+	bundles := &corev1.ConfigMapList{}
+
+	err := c.List(context.TODO(), bundles, client.InNamespace(namespace),
+		client.MatchingLabels{ChartBundleLabel: s.Repo.ChartName})
+	if err != nil {
+		return nil, err
+	}
+
+	var constraint *semver.Constraints
+
+	if s.Repo.Version != "" {
+		if constraint, err = semver.NewConstraint(s.Repo.Version); err != nil {
+			return nil, err
+		}
+	}
+
+	var (
+		bundle *corev1.ConfigMap
+		latest *semver.Version
+	)
+
+	for i := range bundles.Items {
+		version, err := semver.NewVersion(bundles.Items[i].GetAnnotations()[ChartBundleVersionAnnotation])
+		if err != nil {
+			klog.V(3).Info("Skip chart bundle ", namespace, "/", bundles.Items[i].Name, " without a valid version: ", err)
+			continue
+		}
+
+		if constraint != nil && !constraint.Check(version) {
+			continue
+		}
+
+		if latest == nil || version.GreaterThan(latest) {
+			bundle, latest = &bundles.Items[i], version
+		}
+	}
+
+	return bundle, nil
+}
+
+// StageChartBundle writes the chart archive of a bundle where the chart of the HelmRelease is
+// downloaded to, and returns the path of the archive. It is not expanded, the chart loader
+// reads it as is.
+func StageChartBundle(bundle *corev1.ConfigMap, chartsDir string, s *appv1.HelmRelease) (chartPath string, err error) {
+	var archive []byte
+
+	for key, data := range bundle.BinaryData {
+		if strings.HasSuffix(key, ".tgz") {
+			archive = data
+			break
+		}
+	}
+
+	if archive == nil {
+		return "", fmt.Errorf("chart bundle %s/%s has no .tgz binary data", bundle.Namespace, bundle.Name)
+	}
+
+	if err := checkChartArchive(bytes.NewReader(archive)); err != nil {
+		klog.Error(err, "- Failed to read chart bundle ", bundle.Namespace, "/", bundle.Name)
+		return "", err
+	}
+
+	destRepo := chartCacheDir(chartsDir, s)
+	if err := os.MkdirAll(destRepo, 0750); err != nil {
+		klog.Error(err, " - Unable to create chartDir: ", destRepo)
+		return "", err
+	}
+
+	chartPath = filepath.Clean(filepath.Join(destRepo, s.Repo.ChartName+".tgz"))
+
+	if err := ioutil.WriteFile(chartPath, archive, 0600); err != nil {
+		klog.Error(err, "- Failed to write chart bundle ", bundle.Namespace, "/", bundle.Name)
+		return "", err
+	}
+
+	return chartPath, nil
+}
@@ -0,0 +1,111 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"sync"
+	"time"
+)
+
+// Defaults of the bounds of the chart downloads running at once
+const (
+	DefaultDownloadConcurrency        = 20
+	DefaultDownloadConcurrencyPerHost = 4
+)
+
+// downloadPool bounds the chart downloads, archives and git clones, running at
+// once, overall and per host, so that the downloads of many HelmReleases run in
+// parallel without overloading a single repo.
+type downloadPool struct {
+	slots   chan struct{}
+	perHost int
+
+	mu    sync.Mutex
+	hosts map[string]chan struct{}
+}
+
+var downloads = newDownloadPool(DefaultDownloadConcurrency, DefaultDownloadConcurrencyPerHost)
+
+// SetDownloadConcurrency bounds the chart downloads running at once to total,
+// and to perHost for the downloads from the same host. It must be called
+// before the first download.
+func SetDownloadConcurrency(total, perHost int) {
+	downloads = newDownloadPool(total, perHost)
+}
+
+// DownloadConcurrency returns the number of chart downloads running at once.
+func DownloadConcurrency() int {
+	return cap(downloads.slots)
+}
+
+func newDownloadPool(total, perHost int) *downloadPool {
+	if total < 1 {
+		total = DefaultDownloadConcurrency
+	}
+
+	if perHost < 1 || perHost > total {
+		perHost = total
+	}
+
+	return &downloadPool{
+		slots:   make(chan struct{}, total),
+		perHost: perHost,
+		hosts:   map[string]chan struct{}{},
+	}
+}
+
+// acquire waits for a download slot of the host of location, then for a slot
+// of the pool, and returns the function releasing them. The slot of the host
+// is taken first so that the downloads waiting for a busy host do not hold the
+// slots of the others.
+func (p *downloadPool) acquire(sourceType, location string) func() {
+	host := sourceHost(location)
+	start := time.Now()
+
+	p.mu.Lock()
+
+	hostSlots, ok := p.hosts[host]
+	if !ok {
+		hostSlots = make(chan struct{}, p.perHost)
+		p.hosts[host] = hostSlots
+	}
+
+	p.mu.Unlock()
+
+	hostSlots <- struct{}{}
+	p.slots <- struct{}{}
+
+	chartDownloadWaitDuration.WithLabelValues(sourceType, host).Observe(time.Since(start).Seconds())
+
+	return func() {
+		<-p.slots
+		<-hostSlots
+	}
+}
+
+// chartDirLocks serializes the downloads to the same chart cache directory,
+// e.g. of a prefetch and a reconcile of the same HelmRelease
+var chartDirLocks sync.Map
+
+// lockChartDir locks the chart cache directory dir and returns the function
+// unlocking it.
+func lockChartDir(dir string) func() {
+	mu, _ := chartDirLocks.LoadOrStore(dir, &sync.Mutex{})
+	mu.(*sync.Mutex).Lock()
+
+	return mu.(*sync.Mutex).Unlock
+}
@@ -0,0 +1,87 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDownloadPool(t *testing.T) {
+	p := newDownloadPool(3, 1)
+
+	first := p.acquire("helmrepo", "https://charts.example.com/nginx-1.0.0.tgz")
+	other := p.acquire("helmrepo", "https://other.example.com/nginx-1.0.0.tgz")
+
+	acquired := make(chan func(), 1)
+
+	go func() {
+		acquired <- p.acquire("helmrepo", "https://charts.example.com/redis-1.0.0.tgz")
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("acquired a second slot of the same host")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	first()
+
+	select {
+	case release := <-acquired:
+		release()
+	case <-time.After(time.Second):
+		t.Fatal("slot of the host not released")
+	}
+
+	other()
+	assert.Empty(t, p.slots)
+}
+
+func TestNewDownloadPool(t *testing.T) {
+	p := newDownloadPool(0, 0)
+	assert.Equal(t, DefaultDownloadConcurrency, cap(p.slots))
+	assert.Equal(t, DefaultDownloadConcurrency, p.perHost)
+
+	p = newDownloadPool(2, 5)
+	assert.Equal(t, 2, p.perHost)
+}
+
+func TestLockChartDir(t *testing.T) {
+	unlock := lockChartDir("/tmp/charts/nginx")
+
+	locked := make(chan struct{})
+
+	go func() {
+		defer lockChartDir("/tmp/charts/nginx")()
+		close(locked)
+	}()
+
+	// another directory is not locked
+	lockChartDir("/tmp/charts/redis")()
+
+	select {
+	case <-locked:
+		t.Fatal("locked the same directory twice")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	unlock()
+	<-locked
+}
@@ -18,6 +18,7 @@ package utils
 
 import (
 	"crypto/tls"
+	"crypto/x509"
 	"fmt"
 	"io"
 	"net"
@@ -32,17 +33,19 @@ import (
 	"gopkg.in/src-d/go-git.v4"
 	"gopkg.in/src-d/go-git.v4/plumbing"
 	githttp "gopkg.in/src-d/go-git.v4/plumbing/transport/http"
-	"helm.sh/helm/v3/pkg/chartutil"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/rest"
 	"k8s.io/klog"
 
 	appv1 "github.com/open-cluster-management/multicloud-operators-subscription-release/pkg/apis/apps/v1"
 )
 
-//GetHelmRepoClient returns an *http.client to access the helm repo
-func GetHelmRepoClient(parentNamespace string, configMap *corev1.ConfigMap, skipCertVerify bool) (rest.HTTPClient, error) {
+// GetHelmRepoClient returns an *http.client to access the helm repo, presenting the client certificate
+// of tlsSecret if not nil
+func GetHelmRepoClient(parentNamespace string, configMap *corev1.ConfigMap, tlsSecret *corev1.Secret,
+	skipCertVerify bool) (rest.HTTPClient, error) {
 	/* #nosec G402 */
 	transport := &http.Transport{
 		Proxy: http.ProxyFromEnvironment,
@@ -91,54 +94,132 @@ func GetHelmRepoClient(parentNamespace string, configMap *corev1.ConfigMap, skip
 		klog.V(5).Info("configMap is nil")
 	}
 
-	httpClient := http.DefaultClient
-	httpClient.Transport = transport
+	if tlsSecret != nil {
+		if err := setClientCertificate(transport.TLSClientConfig, tlsSecret); err != nil {
+			return nil, err
+		}
+	}
+
+	// the transports differ by their certificates, the default client is not shared
+	httpClient := &http.Client{Transport: transport}
 	klog.V(5).Info("InsecureSkipVerify equal ", transport.TLSClientConfig.InsecureSkipVerify)
 
 	return httpClient, nil
 }
 
+// tlsCAKey is the key of the CA in the kubernetes.io/tls Secrets issued by cert-manager
+const tlsCAKey = "ca.crt"
+
+// setClientCertificate sets the client certificate of the kubernetes.io/tls Secret in tlsConfig,
+// and trusts the CA of its ca.crt, if any, in addition to the system ones.
+func setClientCertificate(tlsConfig *tls.Config, tlsSecret *corev1.Secret) error {
+	cert, err := tls.X509KeyPair(tlsSecret.Data[corev1.TLSCertKey], tlsSecret.Data[corev1.TLSPrivateKeyKey])
+	if err != nil {
+		return fmt.Errorf("invalid client certificate in secret %s/%s: %w", tlsSecret.Namespace, tlsSecret.Name, err)
+	}
+
+	tlsConfig.Certificates = []tls.Certificate{cert}
+
+	if ca, ok := tlsSecret.Data[tlsCAKey]; ok {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+
+		if !pool.AppendCertsFromPEM(ca) {
+			return fmt.Errorf("invalid %s in secret %s/%s", tlsCAKey, tlsSecret.Namespace, tlsSecret.Name)
+		}
+
+		tlsConfig.RootCAs = pool
+	}
+
+	return nil
+}
+
 //DownloadChart downloads the charts
 func DownloadChart(configMap *corev1.ConfigMap,
 	secret *corev1.Secret,
+	tlsSecret *corev1.Secret,
 	chartsDir string,
 	s *appv1.HelmRelease) (chartDir string, err error) {
-	destRepo := filepath.Join(chartsDir, s.Name, s.Namespace, s.Repo.ChartName)
+	chartDir, _, err = DownloadChartSource(configMap, secret, tlsSecret, chartsDir, s)
+
+	return chartDir, err
+}
+
+// DownloadChartSource downloads the charts and returns the url of the source that served them.
+// The urls of the source are tried in order, the ones after the first are the alternate sources.
+// The chart is a directory for the git sources, a chart archive for the helm repo sources,
+// both loaded by the helm chart loader.
+func DownloadChartSource(configMap *corev1.ConfigMap,
+	secret *corev1.Secret,
+	tlsSecret *corev1.Secret,
+	chartsDir string,
+	s *appv1.HelmRelease) (chartDir string, source *appv1.HelmAppChartSource, err error) {
+	destRepo := chartCacheDir(chartsDir, s)
+
+	unlock := lockChartDir(destRepo)
+	defer unlock()
+
 	if _, err := os.Stat(destRepo); os.IsNotExist(err) {
 		err := os.MkdirAll(destRepo, 0750)
 		if err != nil {
 			klog.Error(err, " - Unable to create chartDir: ", destRepo)
-			return "", err
+			return "", nil, err
 		}
 	}
 
 	switch strings.ToLower(string(s.Repo.Source.SourceType)) {
 	case string(appv1.HelmRepoSourceType):
-		return DownloadChartFromHelmRepo(configMap, secret, destRepo, s)
+		return downloadChartFromHelmRepo(configMap, secret, tlsSecret, destRepo, s)
 	case string(appv1.GitHubSourceType):
-		return DownloadChartFromGit(configMap, secret, destRepo, s)
+		return downloadChartFromGit(configMap, secret, destRepo, s)
 	case string(appv1.GitSourceType):
-		return DownloadChartFromGit(configMap, secret, destRepo, s)
+		return downloadChartFromGit(configMap, secret, destRepo, s)
 	default:
-		return "", fmt.Errorf("sourceType '%s' unsupported", s.Repo.Source.SourceType)
+		return "", nil, fmt.Errorf("sourceType '%s' unsupported", s.Repo.Source.SourceType)
 	}
 }
 
+// RemoveChartCache removes the charts downloaded for the HelmRelease so that the next download
+// fetches them again
+func RemoveChartCache(chartsDir string, s *appv1.HelmRelease) error {
+	dir := chartCacheDir(chartsDir, s)
+
+	unlock := lockChartDir(dir)
+	defer unlock()
+
+	return os.RemoveAll(dir)
+}
+
+func chartCacheDir(chartsDir string, s *appv1.HelmRelease) string {
+	return filepath.Join(chartsDir, s.Name, s.Namespace, s.Repo.ChartName)
+}
+
 //DownloadChartFromGit downloads a chart into the charsDir
 func DownloadChartFromGit(configMap *corev1.ConfigMap, secret *corev1.Secret, destRepo string, s *appv1.HelmRelease) (chartDir string, err error) {
+	chartDir, _, err = downloadChartFromGit(configMap, secret, destRepo, s)
+
+	return chartDir, err
+}
+
+func downloadChartFromGit(configMap *corev1.ConfigMap,
+	secret *corev1.Secret,
+	destRepo string,
+	s *appv1.HelmRelease) (chartDir string, source *appv1.HelmAppChartSource, err error) {
 	if s.Repo.Source.GitHub == nil && s.Repo.Source.Git == nil {
 		err := fmt.Errorf("git type but Repo.GitHub and Repo.Git is not defined")
-		return "", err
+		return "", nil, err
 	}
 
 	if s.Repo.Source.GitHub != nil {
-		_, err = DownloadGitRepo(configMap, secret, destRepo, s.Repo.Source.GitHub.Urls, s.Repo.Source.GitHub.Branch)
+		_, source, err = downloadGitRepo(configMap, secret, destRepo, s.Repo.Source.GitHub.Urls, s.Repo.Source.GitHub.Branch)
 	} else if s.Repo.Source.Git != nil {
-		_, err = DownloadGitRepo(configMap, secret, destRepo, s.Repo.Source.Git.Urls, s.Repo.Source.Git.Branch)
+		_, source, err = downloadGitRepo(configMap, secret, destRepo, s.Repo.Source.Git.Urls, s.Repo.Source.Git.Branch)
 	}
 
 	if err != nil {
-		return "", err
+		return "", nil, err
 	}
 
 	if s.Repo.Source.GitHub != nil {
@@ -147,7 +228,7 @@ func DownloadChartFromGit(configMap *corev1.ConfigMap, secret *corev1.Secret, de
 		chartDir = filepath.Join(destRepo, s.Repo.Source.Git.ChartPath)
 	}
 
-	return chartDir, nil
+	return chartDir, source, nil
 }
 
 //DownloadGitRepo downloads a git repo into the charsDir
@@ -155,7 +236,20 @@ func DownloadGitRepo(configMap *corev1.ConfigMap,
 	secret *corev1.Secret,
 	destRepo string,
 	urls []string, branch string) (commitID string, err error) {
-	for _, url := range urls {
+	commitID, _, err = downloadGitRepo(configMap, secret, destRepo, urls, branch)
+
+	return commitID, err
+}
+
+func downloadGitRepo(configMap *corev1.ConfigMap,
+	secret *corev1.Secret,
+	destRepo string,
+	urls []string, branch string) (commitID string, source *appv1.HelmAppChartSource, err error) {
+	for i, url := range urls {
+		if i > 0 {
+			observeSourceRetry(string(appv1.GitSourceType), url)
+		}
+
 		options := &git.CloneOptions{
 			URL:               url,
 			Depth:             1,
@@ -183,8 +277,16 @@ func DownloadGitRepo(configMap *corev1.ConfigMap,
 			klog.Error(err, "- Failed to remove all: ", destRepo)
 		}
 
+		release := downloads.acquire(string(appv1.GitSourceType), url)
+		start := time.Now()
 		r, errClone := git.PlainClone(destRepo, false, options)
 
+		release()
+
+		// the cloned repos are not cached, every download clones again
+		chartCacheRequests.WithLabelValues(string(appv1.GitSourceType), cacheMiss).Inc()
+		observeChartDownload(string(appv1.GitSourceType), url, start, errClone)
+
 		if errClone != nil {
 			rErr = os.RemoveAll(destRepo)
 			if rErr != nil {
@@ -213,45 +315,83 @@ func DownloadGitRepo(configMap *corev1.ConfigMap,
 
 		commitID = h.Hash().String()
 		klog.V(5).Info("commitID: ", commitID)
+
+		return commitID, servedBy(string(appv1.GitSourceType), urls, i), nil
 	}
 
 	if err != nil {
 		klog.Error(err, " - All urls failed")
 	}
 
-	return commitID, err
+	return commitID, nil, err
 }
 
 //DownloadChartFromHelmRepo downloads a chart into the chartDir
 func DownloadChartFromHelmRepo(configMap *corev1.ConfigMap,
 	secret *corev1.Secret,
+	tlsSecret *corev1.Secret,
 	destRepo string,
 	s *appv1.HelmRelease) (chartDir string, err error) {
+	chartDir, _, err = downloadChartFromHelmRepo(configMap, secret, tlsSecret, destRepo, s)
+
+	return chartDir, err
+}
+
+func downloadChartFromHelmRepo(configMap *corev1.ConfigMap,
+	secret *corev1.Secret,
+	tlsSecret *corev1.Secret,
+	destRepo string,
+	s *appv1.HelmRelease) (chartDir string, source *appv1.HelmAppChartSource, err error) {
 	if s.Repo.Source.HelmRepo == nil {
 		err := fmt.Errorf("helmrepo type but Spec.HelmRepo is not defined")
-		return "", err
+		return "", nil, err
 	}
 
 	var urlsError string
 
-	for _, url := range s.Repo.Source.HelmRepo.Urls {
-		chartDir, err := downloadChartFromURL(configMap, secret, destRepo, s, url)
+	urls := s.Repo.Source.HelmRepo.Urls
+
+	for i, url := range urls {
+		if i > 0 {
+			observeSourceRetry(string(appv1.HelmRepoSourceType), url)
+		}
+
+		chartDir, err := downloadChartFromURL(configMap, secret, tlsSecret, destRepo, s, url)
 		if err == nil {
-			return chartDir, nil
+			return chartDir, servedBy(string(appv1.HelmRepoSourceType), urls, i), nil
 		}
 
 		urlsError += " - url: " + url + " error: " + err.Error()
 	}
 
-	return "", fmt.Errorf("failed to download chart from helm repo. " + urlsError)
+	return "", nil, fmt.Errorf("failed to download chart from helm repo. " + urlsError)
+}
+
+// servedBy returns the chart source of the download served by the url at index i of urls and
+// counts it as a failover if it is not the first one.
+func servedBy(sourceType string, urls []string, i int) *appv1.HelmAppChartSource {
+	if i > 0 {
+		observeFailover(sourceType, urls[0])
+		klog.Warning("Chart served by alternate source ", sourceLocation(urls[i]),
+			" after the primary source ", sourceLocation(urls[0]), " failed")
+	}
+
+	now := metav1.Now()
+
+	return &appv1.HelmAppChartSource{
+		URL:         sourceLocation(urls[i]),
+		Failover:    i > 0,
+		LastFetched: &now,
+	}
 }
 
 func downloadChartFromURL(configMap *corev1.ConfigMap,
 	secret *corev1.Secret,
+	tlsSecret *corev1.Secret,
 	destRepo string,
 	s *appv1.HelmRelease,
 	url string) (chartDir string, err error) {
-	chartZip, downloadErr := downloadFile(s.Namespace, configMap, url, secret, destRepo, s.Repo.InsecureSkipVerify)
+	chartZip, downloadErr := downloadFile(s.Namespace, configMap, url, secret, tlsSecret, destRepo, s.Repo.InsecureSkipVerify)
 	if downloadErr != nil {
 		klog.Error(downloadErr, " - url: ", url)
 		return "", downloadErr
@@ -263,35 +403,30 @@ func downloadChartFromURL(configMap *corev1.ConfigMap,
 		return "", downloadErr
 	}
 
-	chartDir = filepath.Join(destRepo, s.Repo.ChartName)
-	chartDir = filepath.Clean(chartDir)
-	//Clean before untar
-	err = os.RemoveAll(chartDir)
-	if err != nil {
-		klog.Error(err, "- Failed to remove all: ", chartDir, " for ", chartZip, " using url: ", url)
-	}
+	defer closeHelper(r)
 
-	//Untar
-	err = chartutil.Expand(destRepo, r)
+	// the archive is loaded as is by the chart loader, without being expanded
+	err = checkChartArchive(r)
 	if err != nil {
-		//Remove zip because failed to untar and so probably corrupted
+		//Remove zip because it is probably corrupted
 		rErr := os.RemoveAll(chartZip)
 		if rErr != nil {
 			klog.Error(rErr, "- Failed to remove all: ", chartZip)
 		}
 
-		klog.Error(err, "- Failed to unzip: ", chartZip, " using url: ", url)
+		klog.Error(err, "- Failed to read: ", chartZip, " using url: ", url)
 
 		return "", err
 	}
 
-	return chartDir, nil
+	return filepath.Clean(chartZip), nil
 }
 
 //downloadFile downloads a files and post it in the chartsDir.
 func downloadFile(parentNamespace string, configMap *corev1.ConfigMap,
 	fileURL string,
 	secret *corev1.Secret,
+	tlsSecret *corev1.Secret,
 	chartsDir string,
 	insecureSkipVerify bool) (string, error) {
 	klog.V(4).Info("fileURL: ", fileURL)
@@ -317,7 +452,7 @@ func downloadFile(parentNamespace string, configMap *corev1.ConfigMap,
 	case "file":
 		downloadErr = downloadFileLocal(URLP, chartZip)
 	case "http", "https":
-		downloadErr = downloadFileHTTP(parentNamespace, configMap, fileURL, secret, chartZip, insecureSkipVerify)
+		downloadErr = downloadFileHTTP(parentNamespace, configMap, fileURL, secret, tlsSecret, chartZip, insecureSkipVerify)
 	default:
 		downloadErr = fmt.Errorf("unsupported scheme %s", URLP.Scheme)
 	}
@@ -356,8 +491,11 @@ func downloadFileLocal(urlP *url.URL,
 func downloadFileHTTP(parentNamespace string, configMap *corev1.ConfigMap,
 	fileURL string,
 	secret *corev1.Secret,
+	tlsSecret *corev1.Secret,
 	chartZip string,
-	insecureSkipVerify bool) error {
+	insecureSkipVerify bool) (downloadErr error) {
+	sourceType := string(appv1.HelmRepoSourceType)
+
 	fileInfo, err := os.Stat(chartZip)
 	if fileInfo != nil && fileInfo.IsDir() {
 		downloadErr := fmt.Errorf("expecting chartZip to be a file but it's a directory: %s", chartZip)
@@ -367,7 +505,18 @@ func downloadFileHTTP(parentNamespace string, configMap *corev1.ConfigMap,
 	}
 
 	if os.IsNotExist(err) {
-		httpClient, downloadErr := GetHelmRepoClient(parentNamespace, configMap, insecureSkipVerify)
+		chartCacheRequests.WithLabelValues(sourceType, cacheMiss).Inc()
+
+		release := downloads.acquire(sourceType, fileURL)
+		defer release()
+
+		start := time.Now()
+
+		defer func() {
+			observeChartDownload(sourceType, fileURL, start, downloadErr)
+		}()
+
+		httpClient, downloadErr := GetHelmRepoClient(parentNamespace, configMap, tlsSecret, insecureSkipVerify)
 		if downloadErr != nil {
 			klog.Error(downloadErr, " - Failed to create httpClient")
 			return downloadErr
@@ -382,7 +531,13 @@ func downloadFileHTTP(parentNamespace string, configMap *corev1.ConfigMap,
 		}
 
 		if secret != nil && secret.Data != nil {
-			req.SetBasicAuth(string(secret.Data["user"]), GetPassword(secret))
+			user, password, authErr := GetBasicAuth(secret, fileURL)
+			if authErr != nil {
+				klog.Error(authErr, " - Failed to get the credentials of: ", fileURL)
+				return authErr
+			}
+
+			req.SetBasicAuth(user, password)
 		}
 
 		var resp *http.Response
@@ -415,12 +570,15 @@ func downloadFileHTTP(parentNamespace string, configMap *corev1.ConfigMap,
 		defer closeHelper(out)
 
 		// Write the body to file
-		_, downloadErr = io.Copy(out, resp.Body)
+		written, downloadErr := io.Copy(out, resp.Body)
 		if downloadErr != nil {
 			klog.Error(downloadErr, " - Failed to copy body:", chartZip)
 			return downloadErr
 		}
+
+		chartDownloadBytes.WithLabelValues(sourceType, sourceHost(fileURL)).Add(float64(written))
 	} else {
+		chartCacheRequests.WithLabelValues(sourceType, cacheHit).Inc()
 		klog.V(5).Info("Skip download chartZip already exists: ", chartZip)
 	}
 
@@ -18,7 +18,18 @@ package utils
 
 import (
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
 	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"testing"
@@ -26,6 +37,8 @@ import (
 
 	"github.com/onsi/gomega"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"helm.sh/helm/v3/pkg/chart/loader"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
@@ -162,7 +175,7 @@ func TestDownloadChartGitHub(t *testing.T) {
 
 	defer os.RemoveAll(dir)
 
-	destDir, err := DownloadChart(nil, nil, dir, hr)
+	destDir, err := DownloadChart(nil, nil, nil, dir, hr)
 	assert.NoError(t, err)
 
 	_, err = os.Stat(filepath.Join(destDir, "Chart.yaml"))
@@ -191,7 +204,7 @@ func TestDownloadChartGit(t *testing.T) {
 
 	defer os.RemoveAll(dir)
 
-	destDir, err := DownloadChart(nil, nil, dir, hr)
+	destDir, err := DownloadChart(nil, nil, nil, dir, hr)
 	assert.NoError(t, err)
 
 	_, err = os.Stat(filepath.Join(destDir, "Chart.yaml"))
@@ -220,11 +233,12 @@ func TestDownloadChartHelmRepo(t *testing.T) {
 
 	defer os.RemoveAll(dir)
 
-	destDir, err := DownloadChart(nil, nil, dir, hr)
+	destDir, err := DownloadChart(nil, nil, nil, dir, hr)
 	assert.NoError(t, err)
 
-	_, err = os.Stat(filepath.Join(destDir, "Chart.yaml"))
-	assert.NoError(t, err)
+	chrt, err := loader.Load(destDir)
+	require.NoError(t, err)
+	assert.Equal(t, "subscription-release-test-1", chrt.Name())
 }
 
 func TestDownloadChartHelmRepoContainsInvalidURL(t *testing.T) {
@@ -250,11 +264,12 @@ func TestDownloadChartHelmRepoContainsInvalidURL(t *testing.T) {
 
 	defer os.RemoveAll(dir)
 
-	destDir, err := DownloadChart(nil, nil, dir, hr)
+	destDir, err := DownloadChart(nil, nil, nil, dir, hr)
 	assert.NoError(t, err)
 
-	_, err = os.Stat(filepath.Join(destDir, "Chart.yaml"))
-	assert.NoError(t, err)
+	chrt, err := loader.Load(destDir)
+	require.NoError(t, err)
+	assert.Equal(t, "subscription-release-test-1", chrt.Name())
 }
 
 func TestDownloadChartHelmRepoContainsInvalidURL2(t *testing.T) {
@@ -279,11 +294,12 @@ func TestDownloadChartHelmRepoContainsInvalidURL2(t *testing.T) {
 
 	defer os.RemoveAll(dir)
 
-	destDir, err := DownloadChart(nil, nil, dir, hr)
+	destDir, err := DownloadChart(nil, nil, nil, dir, hr)
 	assert.NoError(t, err)
 
-	_, err = os.Stat(filepath.Join(destDir, "Chart.yaml"))
-	assert.NoError(t, err)
+	chrt, err := loader.Load(destDir)
+	require.NoError(t, err)
+	assert.Equal(t, "subscription-release-test-1", chrt.Name())
 }
 
 func TestDownloadChartHelmRepoAllInvalidURLs(t *testing.T) {
@@ -307,7 +323,7 @@ func TestDownloadChartHelmRepoAllInvalidURLs(t *testing.T) {
 
 	defer os.RemoveAll(dir)
 
-	_, err = DownloadChart(nil, nil, dir, hr)
+	_, err = DownloadChart(nil, nil, nil, dir, hr)
 	assert.Error(t, err)
 }
 
@@ -391,11 +407,12 @@ func TestDownloadChartFromHelmRepoHTTP(t *testing.T) {
 
 	defer os.RemoveAll(dir)
 
-	chartDir, err := DownloadChartFromHelmRepo(nil, nil, dir, hr)
+	chartDir, err := DownloadChartFromHelmRepo(nil, nil, nil, dir, hr)
 	assert.NoError(t, err)
 
-	_, err = os.Stat(filepath.Join(chartDir, "Chart.yaml"))
-	assert.NoError(t, err)
+	chrt, err := loader.Load(chartDir)
+	require.NoError(t, err)
+	assert.Equal(t, "subscription-release-test-1", chrt.Name())
 }
 
 func TestDownloadChartFromHelmRepoLocal(t *testing.T) {
@@ -419,11 +436,50 @@ func TestDownloadChartFromHelmRepoLocal(t *testing.T) {
 
 	defer os.RemoveAll(dir)
 
-	chartDir, err := DownloadChartFromHelmRepo(nil, nil, dir, hr)
+	chartDir, err := DownloadChartFromHelmRepo(nil, nil, nil, dir, hr)
+	assert.NoError(t, err)
+
+	chrt, err := loader.Load(chartDir)
+	require.NoError(t, err)
+	assert.Equal(t, "subscription-release-test-1", chrt.Name())
+}
+
+func TestDownloadChartSourceFailover(t *testing.T) {
+	hr := &appv1.HelmRelease{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "subscription-release-test-1-cr",
+			Namespace: "default",
+		},
+		Repo: appv1.HelmReleaseRepo{
+			Source: &appv1.Source{
+				SourceType: appv1.HelmRepoSourceType,
+				HelmRepo: &appv1.HelmRepo{
+					Urls: []string{
+						"file:../../test/helmrepo/missing-0.1.0.tgz",
+						"file:../../test/helmrepo/subscription-release-test-1-0.1.0.tgz"},
+				},
+			},
+			ChartName: "subscription-release-test-1",
+		},
+	}
+	dir, err := ioutil.TempDir("/tmp", "charts")
+	assert.NoError(t, err)
+
+	defer os.RemoveAll(dir)
+
+	// the chart is served by the alternate source
+	_, source, err := DownloadChartSource(nil, nil, nil, dir, hr)
 	assert.NoError(t, err)
+	assert.Equal(t, "file:../../test/helmrepo/subscription-release-test-1-0.1.0.tgz", source.URL)
+	assert.True(t, source.Failover)
+	assert.NotNil(t, source.LastFetched)
 
-	_, err = os.Stat(filepath.Join(chartDir, "Chart.yaml"))
+	// the primary source serving it is no failover
+	hr.Repo.Source.HelmRepo.Urls = hr.Repo.Source.HelmRepo.Urls[1:]
+
+	_, source, err = DownloadChartSource(nil, nil, nil, dir, hr)
 	assert.NoError(t, err)
+	assert.False(t, source.Failover)
 }
 
 func TestDownloadGitRepo(t *testing.T) {
@@ -442,3 +498,150 @@ func TestDownloadGitRepo(t *testing.T) {
 
 	assert.NotEqual(t, commitID, "")
 }
+
+func TestChartBundle(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	mgr, err := manager.New(cfg, manager.Options{
+		MetricsBindAddress: "0",
+	})
+	assert.NoError(t, err)
+
+	stopMgr, mgrStopped := StartTestManager(mgr, g)
+
+	defer func() {
+		close(stopMgr)
+		mgrStopped.Wait()
+	}()
+
+	c := mgr.GetClient()
+
+	hr := &appv1.HelmRelease{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "subscription-release-test-1-bundle",
+			Namespace: "default",
+		},
+		Repo: appv1.HelmReleaseRepo{
+			ChartName: "subscription-release-test-1",
+			Version:   "~0.1.0",
+		},
+	}
+
+	bundle, err := GetChartBundle(mgr.GetAPIReader(), "default", hr)
+	assert.NoError(t, err)
+	assert.Nil(t, bundle)
+
+	archive, err := ioutil.ReadFile("../../test/helmrepo/subscription-release-test-1-0.1.0.tgz")
+	assert.NoError(t, err)
+
+	for name, version := range map[string]string{"bundle-0-1-0": "0.1.0", "bundle-0-2-0": "0.2.0"} {
+		err = c.Create(context.TODO(), &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        name,
+				Namespace:   "default",
+				Labels:      map[string]string{ChartBundleLabel: hr.Repo.ChartName},
+				Annotations: map[string]string{ChartBundleVersionAnnotation: version},
+			},
+			BinaryData: map[string][]byte{"chart.tgz": archive},
+		})
+		assert.NoError(t, err)
+	}
+
+	bundle, err = GetChartBundle(mgr.GetAPIReader(), "default", hr)
+	assert.NoError(t, err)
+	assert.NotNil(t, bundle)
+	assert.Equal(t, "bundle-0-1-0", bundle.Name)
+
+	dir, err := ioutil.TempDir("/tmp", "charts")
+	assert.NoError(t, err)
+
+	defer os.RemoveAll(dir)
+
+	chartPath, err := StageChartBundle(bundle, dir, hr)
+	assert.NoError(t, err)
+
+	chrt, err := loader.Load(chartPath)
+	require.NoError(t, err)
+	assert.Equal(t, "subscription-release-test-1", chrt.Name())
+}
+
+// newTestCertificate returns a self-signed certificate of 127.0.0.1 and its
+// key, PEM encoded.
+func newTestCertificate(t *testing.T) (certPEM, keyPEM []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "helm-repo"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.NoError(t, err)
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	assert.NoError(t, err)
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+func TestGetHelmRepoClientTLSSecret(t *testing.T) {
+	certPEM, keyPEM := newTestCertificate(t)
+
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	assert.NoError(t, err)
+
+	clientCAs := x509.NewCertPool()
+	clientCAs.AppendCertsFromPEM(certPEM)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.TLS = &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    clientCAs,
+	}
+
+	server.StartTLS()
+	defer server.Close()
+
+	tlsSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "repo-tls", Namespace: "default"},
+		Type:       corev1.SecretTypeTLS,
+		Data: map[string][]byte{
+			corev1.TLSCertKey:       certPEM,
+			corev1.TLSPrivateKeyKey: keyPEM,
+			"ca.crt":                certPEM,
+		},
+	}
+
+	req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+	assert.NoError(t, err)
+
+	httpClient, err := GetHelmRepoClient("default", nil, tlsSecret, false)
+	assert.NoError(t, err)
+
+	resp, err := httpClient.Do(req)
+	if assert.NoError(t, err) {
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	}
+
+	// the server requires the client certificate
+	httpClient, err = GetHelmRepoClient("default", nil, nil, true)
+	assert.NoError(t, err)
+
+	_, err = httpClient.Do(req)
+	assert.Error(t, err)
+
+	tlsSecret.Data[corev1.TLSPrivateKeyKey] = []byte("invalid")
+	_, err = GetHelmRepoClient("default", nil, tlsSecret, false)
+	assert.Error(t, err)
+}
@@ -18,6 +18,11 @@ package utils
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
 
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
@@ -44,6 +49,87 @@ func GetPassword(secret *corev1.Secret) string {
 	return ""
 }
 
+// dockerConfigEntry holds the credentials of a registry in a docker config
+type dockerConfigEntry struct {
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	Auth     string `json:"auth,omitempty"`
+}
+
+// GetBasicAuth returns the user and the password of the secret for the server of repoURL. They are
+// the credentials of that registry in the kubernetes.io/dockerconfigjson and kubernetes.io/dockercfg
+// secrets, the same ones as the imagePullSecrets, and the user, or username as with Flux, and password
+// keys of the others.
+func GetBasicAuth(secret *corev1.Secret, repoURL string) (user, password string, err error) {
+	var auths map[string]dockerConfigEntry
+
+	switch secret.Type {
+	case corev1.SecretTypeDockerConfigJson:
+		config := struct {
+			Auths map[string]dockerConfigEntry `json:"auths"`
+		}{}
+
+		if err := json.Unmarshal(secret.Data[corev1.DockerConfigJsonKey], &config); err != nil {
+			return "", "", fmt.Errorf("invalid %s in secret %s/%s: %w", corev1.DockerConfigJsonKey, secret.Namespace, secret.Name, err)
+		}
+
+		auths = config.Auths
+	case corev1.SecretTypeDockercfg:
+		if err := json.Unmarshal(secret.Data[corev1.DockerConfigKey], &auths); err != nil {
+			return "", "", fmt.Errorf("invalid %s in secret %s/%s: %w", corev1.DockerConfigKey, secret.Namespace, secret.Name, err)
+		}
+	default:
+		user := secret.Data["user"]
+		if len(user) == 0 {
+			user = secret.Data["username"]
+		}
+
+		return string(user), GetPassword(secret), nil
+	}
+
+	host := registryHost(repoURL)
+
+	for registry, entry := range auths {
+		if registryHost(registry) != host {
+			continue
+		}
+
+		if entry.Username != "" || entry.Auth == "" {
+			return entry.Username, entry.Password, nil
+		}
+
+		decoded, err := base64.StdEncoding.DecodeString(entry.Auth)
+		if err != nil {
+			return "", "", fmt.Errorf("invalid auth of registry %s in secret %s/%s: %w", registry, secret.Namespace, secret.Name, err)
+		}
+
+		parts := strings.SplitN(string(decoded), ":", 2)
+		if len(parts) != 2 {
+			return "", "", fmt.Errorf("invalid auth of registry %s in secret %s/%s", registry, secret.Namespace, secret.Name)
+		}
+
+		return parts[0], parts[1], nil
+	}
+
+	klog.V(3).Info("No credentials for ", host, " in secret ", secret.Namespace, "/", secret.Name)
+
+	return "", "", nil
+}
+
+// registryHost returns the host, and port, of a docker config registry key or of a URL
+func registryHost(registry string) string {
+	if !strings.Contains(registry, "://") {
+		registry = "https://" + registry
+	}
+
+	u, err := url.Parse(registry)
+	if err != nil {
+		return registry
+	}
+
+	return strings.ToLower(u.Host)
+}
+
 //GetConfigMap search the config map containing the helm repo client configuration.
 func GetConfigMap(client client.Client, parentNamespace string, configMapRef *corev1.ObjectReference) (configMap *corev1.ConfigMap, err error) {
 	if configMapRef != nil {
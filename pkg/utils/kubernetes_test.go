@@ -17,6 +17,7 @@ limitations under the License.
 package utils
 
 import (
+	"encoding/base64"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -54,3 +55,50 @@ func TestGetPassword(t *testing.T) {
 	pw = GetPassword(secret)
 	assert.Equal(t, "", pw)
 }
+
+func TestGetBasicAuth(t *testing.T) {
+	secret := &corev1.Secret{
+		Data: map[string][]byte{
+			"user":     []byte("user"),
+			"password": []byte("password"),
+		},
+	}
+	user, pw, err := GetBasicAuth(secret, "https://charts.example.com/stable/chart-1.0.0.tgz")
+	assert.NoError(t, err)
+	assert.Equal(t, "user", user)
+	assert.Equal(t, "password", pw)
+
+	// the keys of the Flux secrets
+	secret = &corev1.Secret{
+		Data: map[string][]byte{
+			"username": []byte("flux"),
+			"password": []byte("password"),
+		},
+	}
+	user, _, err = GetBasicAuth(secret, "https://charts.example.com/stable/chart-1.0.0.tgz")
+	assert.NoError(t, err)
+	assert.Equal(t, "flux", user)
+
+	secret = &corev1.Secret{
+		Type: corev1.SecretTypeDockerConfigJson,
+		Data: map[string][]byte{
+			corev1.DockerConfigJsonKey: []byte(`{"auths":{` +
+				`"https://registry.example.com":{"username":"robot","password":"token"},` +
+				`"charts.example.com:8443":{"auth":"` + base64.StdEncoding.EncodeToString([]byte("admin:secret")) + `"}}}`),
+		},
+	}
+	user, pw, err = GetBasicAuth(secret, "https://registry.example.com/chartrepo/library/chart-1.0.0.tgz")
+	assert.NoError(t, err)
+	assert.Equal(t, "robot", user)
+	assert.Equal(t, "token", pw)
+
+	user, pw, err = GetBasicAuth(secret, "https://charts.example.com:8443/chart-1.0.0.tgz")
+	assert.NoError(t, err)
+	assert.Equal(t, "admin", user)
+	assert.Equal(t, "secret", pw)
+
+	user, pw, err = GetBasicAuth(secret, "https://other.example.com/chart-1.0.0.tgz")
+	assert.NoError(t, err)
+	assert.Equal(t, "", user)
+	assert.Equal(t, "", pw)
+}
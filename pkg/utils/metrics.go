@@ -0,0 +1,141 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// chart cache results
+const (
+	cacheHit  = "hit"
+	cacheMiss = "miss"
+)
+
+var (
+	chartDownloadDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "helmrelease_chart_download_duration_seconds",
+		Help:    "Duration of the chart downloads, archives and git clones, by source type and host.",
+		Buckets: []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120},
+	}, []string{"source_type", "host"})
+
+	chartDownloadWaitDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "helmrelease_chart_download_wait_seconds",
+		Help:    "Duration the chart downloads waited for a slot of the download pool, by source type and host.",
+		Buckets: []float64{0.01, 0.1, 0.5, 1, 2.5, 5, 10, 30, 60, 120},
+	}, []string{"source_type", "host"})
+
+	chartDownloadBytes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "helmrelease_chart_download_bytes_total",
+		Help: "Bytes of the chart archives downloaded, by source type and host.",
+	}, []string{"source_type", "host"})
+
+	chartDownloadFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "helmrelease_chart_download_failures_total",
+		Help: "Failed chart downloads, archives and git clones, by source type and host.",
+	}, []string{"source_type", "host"})
+
+	chartCacheRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "helmrelease_chart_cache_requests_total",
+		Help: "Chart archives found in the local chart cache (hit) or downloaded (miss), by source type.",
+	}, []string{"source_type", "result"})
+
+	chartSourceFailovers = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "helmrelease_chart_source_failovers_total",
+		Help: "Chart downloads served by an alternate source because the primary one failed, by source type and primary host.",
+	}, []string{"source_type", "host"})
+
+	chartSourceRetries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "helmrelease_chart_source_retries_total",
+		Help: "Chart downloads retried from an alternate source after the previous sources failed, by source type and host.",
+	}, []string{"source_type", "host"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(chartDownloadDuration, chartDownloadWaitDuration, chartDownloadBytes,
+		chartDownloadFailures, chartCacheRequests, chartSourceFailovers, chartSourceRetries)
+}
+
+// sourceHost is the host of a chart location, the label of its metrics.
+// The rest of the location is left out to bound the number of series and
+// keep the credentials of the URLs out of the metrics.
+func sourceHost(location string) string {
+	if u, err := url.Parse(location); err == nil && u.Host != "" {
+		return u.Host
+	}
+
+	// scp-like git locations, e.g. git@github.com:org/repo.git
+	if at := strings.Index(location, "@"); at >= 0 {
+		location = location[at+1:]
+	}
+
+	if colon := strings.Index(location, ":"); colon > 0 {
+		return location[:colon]
+	}
+
+	return "unknown"
+}
+
+// sourceLocation is a chart location without its credentials and query, as
+// recorded in the status.
+func sourceLocation(location string) string {
+	u, err := url.Parse(location)
+	if err != nil || u.Host == "" {
+		// scp-like git locations, e.g. git@github.com:org/repo.git
+		if at := strings.Index(location, "@"); at >= 0 {
+			return location[at+1:]
+		}
+
+		return location
+	}
+
+	u.User = nil
+	u.RawQuery = ""
+	u.Fragment = ""
+
+	return u.String()
+}
+
+// observeSourceRetry records a chart download retried from the alternate
+// source location.
+func observeSourceRetry(sourceType, location string) {
+	chartSourceRetries.WithLabelValues(sourceType, sourceHost(location)).Inc()
+}
+
+// observeFailover records a chart download served by an alternate source
+// because the primary source location failed.
+func observeFailover(sourceType, primary string) {
+	chartSourceFailovers.WithLabelValues(sourceType, sourceHost(primary)).Inc()
+}
+
+// observeChartDownload records a chart download from location that started
+// at start and failed if err is not nil.
+func observeChartDownload(sourceType, location string, start time.Time, err error) {
+	host := sourceHost(location)
+
+	if err != nil {
+		chartDownloadFailures.WithLabelValues(sourceType, host).Inc()
+		return
+	}
+
+	chartDownloadDuration.WithLabelValues(sourceType, host).Observe(time.Since(start).Seconds())
+}